@@ -1,13 +1,14 @@
 package main
 
 import (
-	// "context"
-	"encoding/json"
+	"context"
 	"encoding/xml"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,7 +16,9 @@ import (
 	"time"
 
 	// "github.com/apple/foundationdb/bindings/go/src/fdb" // TODO: Add FDB support
-	"github.com/gorilla/websocket"
+	"github.com/luxfi/adx/pkg/config"
+	"github.com/luxfi/adx/pkg/jsonx"
+	"github.com/luxfi/adx/pkg/miner"
 	"github.com/luxfi/adx/pkg/rtb"
 	"github.com/luxfi/adx/pkg/vast"
 	"github.com/prebid/openrtb/v20/openrtb2"
@@ -29,32 +32,30 @@ var (
 )
 
 func main() {
-	// Parse flags
-	var (
-		port           = flag.Int("port", 8080, "HTTP server port")
-		wsPort         = flag.Int("ws-port", 8081, "WebSocket server port")
-		fdbCluster     = flag.String("fdb-cluster", "", "FoundationDB cluster file")
-		floorCPM       = flag.Float64("floor-cpm", 0.50, "Floor price CPM")
-		auctionTimeout = flag.Duration("auction-timeout", 100*time.Millisecond, "Auction timeout")
-		version        = flag.Bool("version", false, "Show version information")
-	)
+	// Flags override ADX_* env vars, which override the config file
+	cfg := config.DefaultExchange()
+	loader := config.NewLoader(flag.CommandLine, "exchange", cfg)
+	version := flag.Bool("version", false, "Show version information")
 	flag.Parse()
 
 	if *version {
 		fmt.Printf("ADX Exchange v%s (commit: %s, built: %s)\n", Version, GitCommit, BuildTime)
 		os.Exit(0)
 	}
+	if err := loader.Load(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	log.Printf("Starting ADX Exchange v%s", Version)
 
 	// Initialize FoundationDB
 	// TODO: Add FoundationDB support
 	// var fdbDatabase fdb.Database
-	if *fdbCluster != "" {
+	if cfg.FDBCluster != "" {
 		log.Println("FoundationDB support coming soon. Running in-memory mode.")
 		// fdb.MustAPIVersion(730)
 		// var err error
-		// fdbDatabase, err = fdb.OpenDatabase(*fdbCluster)
+		// fdbDatabase, err = fdb.OpenDatabase(cfg.FDBCluster)
 		// if err != nil {
 		// 	log.Fatalf("Failed to open FoundationDB: %v", err)
 		// }
@@ -64,11 +65,14 @@ func main() {
 	}
 
 	// Create RTB exchange
+	miners := newMinerConns()
 	exchange := &rtb.RTBExchange{
 		DSPs:           make(map[string]*rtb.DSPConnection),
 		SSPs:           make(map[string]*rtb.SSPConnection),
-		AuctionTimeout: *auctionTimeout,
-		FloorPrice:     decimal.NewFromFloat(*floorCPM),
+		AuctionTimeout: time.Duration(cfg.AuctionTimeout),
+		FloorPrice:     decimal.NewFromFloat(cfg.FloorCPM),
+		FloorRules:     rtb.NewFloorRules(cfg.FloorCPM, 1000),
+		Placements:     rtb.NewPlacementStats(),
 		Revenue:        big.NewInt(0),
 		CTVOptimizer: &rtb.CTVOptimizer{
 			PublicaEnabled:           true,
@@ -88,54 +92,180 @@ func main() {
 		},
 		MinerRegistry: &rtb.MinerRegistry{
 			Miners: make(map[string]*rtb.HomeMiner),
+			Notify: miners.send,
 		},
 	}
 
+	exchange.FrequencyCap = cfg.FrequencyCap
+	exchange.Notifier = rtb.NewNotifier(rtb.DefaultNoticeConcurrency)
+	exchange.Feedback = rtb.NewFeedbackReporter(rtb.DefaultNoticeConcurrency, rtb.DefaultFeedbackFlush)
+
+	// Released in this order once the servers have drained
+	var closers []func() error
+	if cfg.ReserveStep > 0 {
+		optimizer := rtb.NewReserveOptimizer(rtb.DefaultReserveConfig)
+		exchange.FloorRules.Optimizer = optimizer
+		exchange.FloorRules.AddRule(rtb.FloorRule{Name: "optimized", Floor: cfg.FloorCPM, Optimized: true})
+		ctx, stop := context.WithCancel(context.Background())
+		go optimizer.Run(ctx, time.Duration(cfg.ReserveStep))
+		closers = append(closers, func() error { stop(); return nil })
+	}
+	closers = append(closers, func() error { exchange.Notifier.Close(); return nil })
+	closers = append(closers, func() error { exchange.Feedback.Close(); return nil })
+	var auditSink io.Writer
+	if cfg.AuditFile != "" {
+		f, err := os.OpenFile(cfg.AuditFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatalf("Failed to open audit file: %v", err)
+		}
+		closers = append(closers, f.Close)
+		auditSink = f
+	}
+	exchange.Audit = rtb.NewAuditLog(rtb.DefaultAuditSize, auditSink)
+	if cfg.SPOWindow > 0 {
+		exchange.SPO = rtb.NewSupplyPathOptimizer()
+		exchange.SPO.Window = time.Duration(cfg.SPOWindow)
+		exchange.SPO.Hold = time.Duration(cfg.SPOHold)
+	}
+	if cfg.SessionDedup > 0 {
+		exchange.PodAssembler.Sessions = rtb.NewSessionDedup(time.Duration(cfg.SessionDedup))
+	}
+	if cfg.InstlFloorCPM > 0 || cfg.RewardFloorCPM > 0 {
+		exchange.PlacementFloors = &rtb.PlacementFloors{Interstitial: cfg.InstlFloorCPM, Rewarded: cfg.RewardFloorCPM}
+	}
+	if len(cfg.CreativePolicies) > 0 {
+		exchange.CreativePolicy = rtb.NewCreativePolicy(creativeLimits(cfg.CreativePolicies["default"]))
+		for id, p := range cfg.CreativePolicies {
+			if id != "default" {
+				exchange.CreativePolicy.SetPlacementLimits(id, creativeLimits(p))
+			}
+		}
+	}
+	if cfg.SkipAdjust != 0 || cfg.NoSkipAdjust != 0 {
+		exchange.SkipPricing = &rtb.SkipPricing{Skippable: cfg.SkipAdjust, NonSkippable: cfg.NoSkipAdjust}
+	}
+	if cfg.FanoutTopK > 0 {
+		exchange.Fanout = rtb.NewFanoutThrottle(cfg.FanoutTopK, cfg.FanoutExplore)
+	}
+	if cfg.FatigueDecay > 0 {
+		curve := rtb.DefaultFatigueCurve
+		curve.Decay = cfg.FatigueDecay
+		if cfg.FatigueHalfLife > 0 {
+			curve.HalfLife = time.Duration(cfg.FatigueHalfLife)
+		}
+		exchange.Fatigue = rtb.NewFatigueModel(curve)
+	}
+	if cfg.Redis != "" {
+		store := rtb.NewRedisFrequencyStore(cfg.Redis)
+		closers = append(closers, store.Close)
+		exchange.FrequencyStore = store
+	}
+	if cfg.PayoutRPC != "" {
+		threshold := decimal.NewFromFloat(cfg.PayoutThreshold).Shift(rtb.PayoutDecimals).BigInt()
+		payouts := miner.NewPayoutScheduler(miner.NewRPCPayoutChain(cfg.PayoutRPC, cfg.PayoutFrom), threshold, time.Duration(cfg.PayoutInterval))
+		payouts.Notify = miners.send
+		exchange.MinerRegistry.Payouts = payouts
+		exchange.MinerRegistry.Share = cfg.MinerShare
+		ctx, stop := context.WithCancel(context.Background())
+		go payouts.Run(ctx)
+		closers = append(closers, func() error { stop(); return nil })
+	}
+
 	// if fdbDatabase != nil {
 	// 	exchange.fdb = fdbDatabase
 	// 	exchange.fdbSpace = "adx"
 	// }
 
-	// Initialize sample DSPs
-	exchange.DSPs["dsp1"] = &rtb.DSPConnection{
-		ID:         "dsp1",
-		Name:       "Sample DSP 1",
-		Endpoint:   "https://dsp1.example.com/bid",
-		QPS:        1000,
-		Timeout:    80 * time.Millisecond,
-		BidderCode: "dsp1",
-		SeatID:     "seat1",
-		// RateLimiter: &rtb.RateLimiter{
-		// 	tokens: 1000,
-		// 	max:    1000,
-		// 	refill: time.Second,
-		// },
+	for _, d := range cfg.DSPs {
+		dsp := &rtb.DSPConnection{
+			ID:          d.ID,
+			Name:        d.Name,
+			Endpoint:    d.Endpoint,
+			QPS:         d.QPS,
+			Timeout:     time.Duration(d.Timeout),
+			BidderCode:  d.BidderCode,
+			SeatID:      d.SeatID,
+			Failover:    d.Failover,
+			Feedback:    rtb.FeedbackConfig{URL: d.FeedbackURL, Mode: d.FeedbackMode},
+			RateLimiter: rtb.NewRateLimiter(d.QPS),
+		}
+		if d.Stream != "" {
+			dsp.Stream = rtb.NewBidStream(d.Stream, d.StreamInFlight)
+		}
+		exchange.DSPs[d.ID] = dsp
 	}
 
-	// HTTP handlers
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/rtb/bid", makeBidHandler(exchange))
-	http.HandleFunc("/vast", makeVASTHandler())
-	http.HandleFunc("/miner/connect", makeMinerHandler(exchange))
+	// Partners managed through the admin API override the config file's
+	partners := rtb.PartnerFile{Path: cfg.PartnersFile}
+	saved, err := partners.Load()
+	if err != nil {
+		log.Fatalf("Failed to load partners: %v", err)
+	}
+	if err := exchange.RestorePartners(saved); err != nil {
+		log.Fatalf("Failed to restore partners: %v", err)
+	}
 
-	// Start HTTP server
-	go func() {
-		addr := fmt.Sprintf(":%d", *port)
-		log.Printf("HTTP server listening on %s", addr)
-		if err := http.ListenAndServe(addr, nil); err != nil {
-			log.Fatalf("HTTP server failed: %v", err)
+	// Without any DSPs, bid against a sample one
+	if len(exchange.DSPs) == 0 {
+		exchange.DSPs["dsp1"] = &rtb.DSPConnection{
+			ID:          "dsp1",
+			Name:        "Sample DSP 1",
+			Endpoint:    "https://dsp1.example.com/bid",
+			QPS:         1000,
+			Timeout:     80 * time.Millisecond,
+			BidderCode:  "dsp1",
+			SeatID:      "seat1",
+			RateLimiter: rtb.NewRateLimiter(1000),
 		}
-	}()
+	}
 
-	// Start WebSocket server for miners
-	go startWebSocketServer(*wsPort, exchange)
+	var admin http.Handler
+	if cfg.AdminToken != "" {
+		h := rtb.NewAdminHandler(exchange, cfg.AdminToken, partners)
+		h.QPS = cfg.AdminQPS
+		admin = h
+	}
+	srv := newServer(exchange, miners, admin)
+	srv.closers = closers
+
+	httpLn, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+	if err != nil {
+		log.Fatalf("HTTP server failed: %v", err)
+	}
+	wsLn, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.WSPort))
+	if err != nil {
+		log.Fatalf("WebSocket server failed: %v", err)
+	}
+	errc := srv.serve(httpLn, wsLn)
 
 	// Wait for shutdown signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	select {
+	case <-sigChan:
+	case err := <-errc:
+		log.Printf("Shutting down after failure: %v", err)
+	}
 
 	log.Println("Shutting down ADX Exchange...")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	if err := srv.shutdown(ctx); err != nil {
+		log.Printf("Unclean shutdown: %v", err)
+	}
+	log.Println("ADX Exchange stopped")
+}
+
+// creativeLimits converts a configured creative policy to the exchange's
+func creativeLimits(c config.CreativePolicyConfig) rtb.CreativeLimits {
+	return rtb.CreativeLimits{
+		MaxBytes:    c.MaxBytes,
+		MaxWidth:    c.MaxWidth,
+		MaxHeight:   c.MaxHeight,
+		MIMETypes:   c.MIMETypes,
+		MaxDuration: time.Duration(c.MaxDuration),
+		Demote:      c.Demote,
+	}
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -151,20 +281,39 @@ func makeBidHandler(exchange *rtb.RTBExchange) http.HandlerFunc {
 		}
 
 		var bidRequest openrtb2.BidRequest
-		if err := json.NewDecoder(r.Body).Decode(&bidRequest); err != nil {
+		if err := jsonx.Decode(r.Body, &bidRequest); err != nil {
 			http.Error(w, "Invalid bid request", http.StatusBadRequest)
 			return
 		}
+		if err := rtb.ValidateBidRequest(&bidRequest); err != nil {
+			rtb.WriteInvalidRequest(w, bidRequest.ID, err)
+			return
+		}
 
-		// ctx := r.Context()
-		bidResponse, err := exchange.BidRequest(nil, &bidRequest) // TODO: Pass context
+		bidResponse, err := exchange.BidRequest(r.Context(), &bidRequest)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(bidResponse)
+		jsonx.Encode(w, bidResponse)
+	}
+}
+
+// makeImpressionHandler confirms an auction's impression, billing its winner
+func makeImpressionHandler(exchange *rtb.RTBExchange) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auctionID := r.URL.Query().Get("auction")
+		if auctionID == "" {
+			http.Error(w, "Missing auction", http.StatusBadRequest)
+			return
+		}
+		if !exchange.ConfirmImpression(auctionID) {
+			http.Error(w, "Unknown auction", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
@@ -227,43 +376,3 @@ func makeVASTHandler() http.HandlerFunc {
 		xml.NewEncoder(w).Encode(vastResp)
 	}
 }
-
-func makeMinerHandler(exchange *rtb.RTBExchange) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Upgrade to WebSocket
-		upgrader := websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
-		}
-
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			log.Printf("WebSocket upgrade failed: %v", err)
-			return
-		}
-		defer conn.Close()
-
-		log.Printf("New miner connected from %s", r.RemoteAddr)
-
-		// Handle miner connection
-		for {
-			var msg map[string]interface{}
-			if err := conn.ReadJSON(&msg); err != nil {
-				log.Printf("Miner disconnected: %v", err)
-				break
-			}
-
-			// Process miner messages
-			log.Printf("Miner message: %v", msg)
-		}
-	}
-}
-
-func startWebSocketServer(port int, exchange *rtb.RTBExchange) {
-	addr := fmt.Sprintf(":%d", port)
-	log.Printf("WebSocket server listening on %s", addr)
-
-	http.HandleFunc("/ws", makeMinerHandler(exchange))
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		log.Fatalf("WebSocket server failed: %v", err)
-	}
-}
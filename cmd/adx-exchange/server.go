@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/luxfi/adx/pkg/health"
+	"github.com/luxfi/adx/pkg/rtb"
+)
+
+// server runs the exchange's HTTP and miner WebSocket listeners
+type server struct {
+	http   *http.Server
+	ws     *http.Server
+	miners *minerConns
+
+	// closers flush and release what auctions write to, in order, once
+	// the servers have stopped
+	closers []func() error
+}
+
+// newServer routes the exchange's endpoints; admin is mounted under
+// /admin/ when not nil
+func newServer(exchange *rtb.RTBExchange, miners *minerConns, admin http.Handler) *server {
+	ready := health.New()
+	ready.Add("dsps", exchange.Ping)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/livez", ready.Livez)
+	mux.HandleFunc("/readyz", ready.Readyz)
+	mux.Handle("/rtb/bid", rtb.NewOpenRTB3Handler(exchange, makeBidHandler(exchange)))
+	mux.HandleFunc("/rtb/impression", makeImpressionHandler(exchange))
+	mux.Handle("/prebid/bid", rtb.NewPrebidHandler(exchange))
+	mux.HandleFunc("/vast", makeVASTHandler())
+	mux.HandleFunc("/miner/connect", makeMinerHandler(exchange, miners))
+	if admin != nil {
+		mux.Handle("/admin/", admin)
+	}
+
+	wsMux := http.NewServeMux()
+	wsMux.HandleFunc("/ws", makeMinerHandler(exchange, miners))
+
+	return &server{
+		http:   &http.Server{Handler: mux},
+		ws:     &http.Server{Handler: wsMux},
+		miners: miners,
+	}
+}
+
+// serve accepts connections on both listeners until shutdown, sending
+// the first error either server fails with
+func (s *server) serve(httpLn, wsLn net.Listener) <-chan error {
+	errc := make(chan error, 2)
+	run := func(srv *http.Server, ln net.Listener, name string) {
+		log.Printf("%s server listening on %s", name, ln.Addr())
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			errc <- fmt.Errorf("%s server: %w", name, err)
+		}
+	}
+	go run(s.http, httpLn, "HTTP")
+	go run(s.ws, wsLn, "WebSocket")
+	return errc
+}
+
+// shutdown stops the exchange in order: both servers stop accepting
+// connections and in-flight auctions drain, then miners are sent a close
+// frame, then the closers run. Work still going at ctx's deadline is cut
+// off, but the closers always run.
+func (s *server) shutdown(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i, srv := range []*http.Server{s.http, s.ws} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = srv.Shutdown(ctx); errs[i] != nil {
+				srv.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	errs[2] = s.miners.closeAll(ctx)
+
+	for _, c := range s.closers {
+		if err := c(); err != nil {
+			errs[3] = errors.Join(errs[3], err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func makeMinerHandler(exchange *rtb.RTBExchange, miners *minerConns) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Upgrade to WebSocket
+		upgrader := websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("WebSocket upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		if !miners.track(conn) {
+			closeMiner(conn, time.Now().Add(time.Second))
+			return
+		}
+		defer miners.untrack(conn)
+
+		log.Printf("New miner connected from %s", r.RemoteAddr)
+
+		// Miners that identify themselves are matched to impressions and
+		// told the results of their auctions
+		q := r.URL.Query()
+		if id := q.Get("miner_id"); id != "" {
+			miners.add(id, conn)
+			if p := exchange.MinerRegistry.Payouts; p != nil && q.Get("wallet") != "" {
+				p.SetPayoutAddress(id, q.Get("wallet"))
+			}
+			exchange.MinerRegistry.Register(&rtb.HomeMiner{
+				ID:            id,
+				WalletAddress: q.Get("wallet"),
+				Country:       q.Get("country"),
+				Region:        q.Get("region"),
+				Active:        true,
+				LastPing:      time.Now(),
+				HealthScore:   1,
+				Earnings:      big.NewInt(0),
+			})
+			defer func() {
+				exchange.MinerRegistry.Unregister(id)
+				miners.remove(id, conn)
+			}()
+		}
+
+		// Handle miner connection
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				log.Printf("Miner disconnected: %v", err)
+				break
+			}
+
+			// Process miner messages
+			log.Printf("Miner message: %v", msg)
+		}
+	}
+}
+
+// minerConns are the connected miners' WebSockets by miner ID
+type minerConns struct {
+	mu    sync.Mutex
+	conns map[string]*minerConn
+
+	// open is every connection, identified or not; once closed is set no
+	// more are taken, and handlers counts those still being served
+	open     map[*websocket.Conn]bool
+	closed   bool
+	handlers sync.WaitGroup
+}
+
+// minerConn serializes writes, which gorilla/websocket requires
+type minerConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func newMinerConns() *minerConns {
+	return &minerConns{
+		conns: make(map[string]*minerConn),
+		open:  make(map[*websocket.Conn]bool),
+	}
+}
+
+// track counts a new connection towards shutdown, refusing it once
+// miners are being closed
+func (c *minerConns) track(conn *websocket.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.open[conn] = true
+	c.handlers.Add(1)
+	return true
+}
+
+// untrack is called as a connection's handler returns
+func (c *minerConns) untrack(conn *websocket.Conn) {
+	c.mu.Lock()
+	delete(c.open, conn)
+	c.mu.Unlock()
+	c.handlers.Done()
+}
+
+func (c *minerConns) add(id string, conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns[id] = &minerConn{conn: conn}
+}
+
+// remove forgets a miner's connection unless it has since reconnected
+func (c *minerConns) remove(id string, conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if mc, ok := c.conns[id]; ok && mc.conn == conn {
+		delete(c.conns, id)
+	}
+}
+
+// send writes a message to a miner, implementing MinerRegistry.Notify
+func (c *minerConns) send(minerID string, msg []byte) error {
+	c.mu.Lock()
+	mc, ok := c.conns[minerID]
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("miner %s not connected", minerID)
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	return mc.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// closeAll sends every miner a close frame and waits for their handlers
+// to return as miners answer it, dropping the connections of any that
+// haven't by ctx's deadline
+func (c *minerConns) closeAll(ctx context.Context) error {
+	c.mu.Lock()
+	c.closed = true
+	conns := make([]*websocket.Conn, 0, len(c.open))
+	for conn := range c.open {
+		conns = append(conns, conn)
+	}
+	c.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Second)
+	}
+	for _, conn := range conns {
+		closeMiner(conn, deadline)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, conn := range conns {
+			conn.Close()
+		}
+		<-done
+		return fmt.Errorf("miners not closed cleanly: %w", ctx.Err())
+	}
+}
+
+// closeMiner tells a miner the exchange is going away. Close frames may be
+// written concurrently with other writes.
+func closeMiner(conn *websocket.Conn, deadline time.Time) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "exchange shutting down")
+	conn.WriteControl(websocket.CloseMessage, msg, deadline)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestShutdown_DrainsAuctionsAndClosesMiners(t *testing.T) {
+	// The DSP holds its bid until shutdown has begun
+	received := make(chan struct{})
+	release := make(chan struct{})
+	dsp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openrtb2.BidRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		close(received)
+		<-release
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{ID: req.ID, SeatBid: []openrtb2.SeatBid{{
+			Seat: "seat1",
+			Bid:  []openrtb2.Bid{{ID: "b1", ImpID: req.Imp[0].ID, Price: 2}},
+		}}})
+	}))
+	defer dsp.Close()
+
+	miners := newMinerConns()
+	exchange := &rtb.RTBExchange{
+		DSPs: map[string]*rtb.DSPConnection{"dsp1": {
+			ID: "dsp1", Endpoint: dsp.URL, Timeout: 2 * time.Second, RateLimiter: rtb.NewRateLimiter(100),
+		}},
+		AuctionTimeout: 2 * time.Second,
+		Revenue:        big.NewInt(0),
+		MinerRegistry:  &rtb.MinerRegistry{Miners: make(map[string]*rtb.HomeMiner), Notify: miners.send},
+		Notifier:       rtb.NewNotifier(rtb.DefaultNoticeConcurrency),
+	}
+	var flushed []string
+	srv := newServer(exchange, miners, nil)
+	srv.closers = []func() error{
+		func() error { exchange.Notifier.Close(); flushed = append(flushed, "notifier"); return nil },
+		func() error { flushed = append(flushed, "store"); return nil },
+	}
+	httpLn, wsLn := listen(t), listen(t)
+	errc := srv.serve(httpLn, wsLn)
+
+	miner, _, err := websocket.DefaultDialer.Dial("ws://"+wsLn.Addr().String()+"/ws?miner_id=m1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer miner.Close()
+	minerClosed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := miner.ReadMessage(); err != nil {
+				minerClosed <- err
+				return
+			}
+		}
+	}()
+
+	type result struct {
+		status int
+		resp   openrtb2.BidResponse
+		err    error
+	}
+	auction := make(chan result, 1)
+	go func() {
+		body := `{"id":"auc-1","imp":[{"id":"1","banner":{}}],"site":{}}`
+		resp, err := http.Post("http://"+httpLn.Addr().String()+"/rtb/bid", "application/json", strings.NewReader(body))
+		if err != nil {
+			auction <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		var r result
+		r.status = resp.StatusCode
+		r.err = json.NewDecoder(resp.Body).Decode(&r.resp)
+		auction <- r
+	}()
+	<-received
+
+	const deadline = 3 * time.Second
+	shutdown := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), deadline)
+		defer cancel()
+		shutdown <- srv.shutdown(ctx)
+	}()
+
+	// New connections are refused while the auction drains
+	time.Sleep(50 * time.Millisecond)
+	if conn, err := net.DialTimeout("tcp", httpLn.Addr().String(), time.Second); err == nil {
+		conn.Close()
+		t.Error("HTTP server accepted a connection after shutdown began")
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned before the auction drained: %v", err)
+	default:
+	}
+	close(release)
+
+	r := <-auction
+	if r.err != nil || r.status != http.StatusOK || len(r.resp.SeatBid) != 1 {
+		t.Fatalf("in-flight auction = %d %+v, %v", r.status, r.resp, r.err)
+	}
+
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+	case <-time.After(deadline + time.Second):
+		t.Fatal("shutdown missed its deadline")
+	}
+	if took := time.Since(start); took > deadline {
+		t.Errorf("shutdown took %s", took)
+	}
+
+	var ce *websocket.CloseError
+	if err := <-minerClosed; !errors.As(err, &ce) || ce.Code != websocket.CloseGoingAway {
+		t.Errorf("miner saw %v, want a going-away close frame", err)
+	}
+	if strings.Join(flushed, ",") != "notifier,store" {
+		t.Errorf("closers ran as %v", flushed)
+	}
+	select {
+	case err := <-errc:
+		t.Errorf("server failed: %v", err)
+	default:
+	}
+}
+
+func TestShutdown_DropsUnresponsiveMiners(t *testing.T) {
+	miners := newMinerConns()
+	exchange := &rtb.RTBExchange{MinerRegistry: &rtb.MinerRegistry{Miners: make(map[string]*rtb.HomeMiner)}}
+	srv := newServer(exchange, miners, nil)
+	httpLn, wsLn := listen(t), listen(t)
+	srv.serve(httpLn, wsLn)
+
+	// A miner that never reads can't answer the close frame
+	miner, _, err := websocket.DefaultDialer.Dial("ws://"+wsLn.Addr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer miner.Close()
+	for {
+		miners.mu.Lock()
+		n := len(miners.open)
+		miners.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := srv.shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown = %v, want the deadline exceeded", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("shutdown took %s past its deadline", took)
+	}
+}
@@ -1,15 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/luxfi/adx/pkg/config"
 	"github.com/luxfi/adx/pkg/miner"
 )
 
+// pidFile records the running miner's process ID for stop
+var pidFile = filepath.Join(os.TempDir(), "adx-miner.pid")
+
 var (
 	Version   = "dev"
 	BuildTime = "unknown"
@@ -59,75 +69,75 @@ func printUsage() {
 	fmt.Println("  --tunnel <type>        Tunnel type (localxpose, ngrok, cloudflare, tailscale, direct)")
 	fmt.Println("  --cache-size <size>    Cache size (e.g., 10GB)")
 	fmt.Println("  --port <port>          Local port (default: 8888)")
+	fmt.Println("  --exchange <url>       Exchange WebSocket (default: ws://localhost:8081/ws)")
+	fmt.Println("  --drain-timeout <dur>  How long stop waits for in-flight ads (default: 30s)")
+	fmt.Println("  --pid-file <path>      Where the running miner's PID is kept")
+	fmt.Println("  --config <path>        YAML or JSON config file; its miner section sets")
+	fmt.Println("                         any of these, overridden by ADX_* env vars")
+	fmt.Println("                         and flags")
 }
 
 func startMiner() {
-	var (
-		wallet    = flag.String("wallet", "", "Wallet address for earnings")
-		tunnel    = flag.String("tunnel", "localxpose", "Tunnel type")
-		cacheSize = flag.String("cache-size", "10GB", "Cache size")
-		port      = flag.Int("port", 8888, "Local port")
-		authToken = flag.String("auth-token", "", "Auth token for tunnel service")
-		subdomain = flag.String("subdomain", "", "Subdomain for tunnel")
-		publicIP  = flag.String("public-ip", "", "Public IP for direct mode")
-		cfToken   = flag.String("cf-token", "", "Cloudflare token")
-	)
+	cfg := config.DefaultMiner()
+	loader := config.NewLoader(flag.CommandLine, "miner", cfg)
+	flag.StringVar(&pidFile, "pid-file", pidFile, "PID file")
 	flag.Parse()
 
-	if *wallet == "" {
-		log.Fatal("Wallet address is required")
+	if err := loader.Load(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
 
 	log.Printf("Starting ADX Miner v%s", Version)
-	log.Printf("Wallet: %s", *wallet)
-	log.Printf("Tunnel: %s", *tunnel)
-	log.Printf("Cache: %s", *cacheSize)
-	log.Printf("Port: %d", *port)
+	log.Printf("Wallet: %s", cfg.Wallet)
+	log.Printf("Tunnel: %s", cfg.Tunnel.Type)
+	log.Printf("Cache: %s", cfg.CacheSize)
+	log.Printf("Port: %d", cfg.Port)
+	log.Printf("Exchange: %s", cfg.Exchange)
 
 	// Create miner configuration
-	config := &miner.Config{
-		WalletAddress: *wallet,
-		LocalPort:     *port,
-		CacheSize:     *cacheSize,
+	minerConfig := &miner.Config{
+		WalletAddress: cfg.Wallet,
+		LocalPort:     cfg.Port,
+		CacheSize:     cfg.CacheSize,
 	}
 
-	// Configure tunnel
+	// Configure tunnel; the type was checked by Load
 	var tunnelConfig miner.TunnelConfig
-	switch *tunnel {
+	switch cfg.Tunnel.Type {
 	case "localxpose":
 		tunnelConfig = miner.TunnelConfig{
 			Type:      miner.TunnelLocalXpose,
-			Subdomain: *subdomain,
+			Subdomain: cfg.Tunnel.Subdomain,
 		}
 	case "ngrok":
 		tunnelConfig = miner.TunnelConfig{
 			Type:      miner.TunnelNgrok,
-			AuthToken: *authToken,
-			Subdomain: *subdomain,
+			AuthToken: cfg.Tunnel.AuthToken,
+			Subdomain: cfg.Tunnel.Subdomain,
 		}
 	case "cloudflare":
 		tunnelConfig = miner.TunnelConfig{
 			Type:      miner.TunnelCloudflare,
-			AuthToken: *cfToken,
+			AuthToken: cfg.Tunnel.CFToken,
 		}
 	case "tailscale":
 		tunnelConfig = miner.TunnelConfig{
 			Type: miner.TunnelTailscale,
 		}
 	case "direct":
-		if *publicIP == "" {
-			log.Fatal("Public IP required for direct mode")
-		}
 		tunnelConfig = miner.TunnelConfig{
 			Type:     miner.TunnelDirectIP,
-			PublicIP: *publicIP,
+			PublicIP: cfg.Tunnel.PublicIP,
 		}
-	default:
-		log.Fatalf("Unknown tunnel type: %s", *tunnel)
 	}
 
 	// Create and start miner
-	m := miner.NewHomeMiner(config, tunnelConfig)
+	m := miner.NewHomeMiner(minerConfig, tunnelConfig)
+	link, err := miner.NewWSLink(cfg.Exchange, m)
+	if err != nil {
+		log.Fatalf("Invalid exchange URL: %v", err)
+	}
+	m.Exchange = link
 
 	// Detect hardware
 	hw := m.DetectHardware()
@@ -149,14 +159,60 @@ func startMiner() {
 	log.Printf("Public URL: %s", m.GetPublicURL())
 	log.Println("Press Ctrl+C to stop")
 
-	// Keep running
-	select {}
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
+		log.Printf("Failed to write PID file: %v", err)
+	}
+	defer os.Remove(pidFile)
+
+	// Run until interrupted or stopped
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	drain := time.Duration(cfg.DrainTimeout)
+	log.Printf("Stopping miner, waiting up to %v for in-flight ads...", drain)
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := m.Stop(ctx); err != nil {
+		log.Printf("Miner stopped uncleanly: %v", err)
+		return
+	}
+	log.Println("Miner stopped")
 }
 
 func stopMiner() {
+	timeout := flag.Duration("timeout", time.Minute, "How long to wait for the miner to exit")
+	flag.StringVar(&pidFile, "pid-file", pidFile, "PID file")
+	flag.Parse()
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		log.Fatalf("No running miner found: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		log.Fatalf("Invalid PID file %s: %v", pidFile, err)
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		log.Fatalf("Miner process %d not found: %v", pid, err)
+	}
+
 	log.Println("Stopping ADX Miner...")
-	// In production, would send signal to running miner process
-	log.Println("Miner stopped")
+	if err := proc.Signal(syscall.SIGTERM); err != nil {
+		log.Fatalf("Failed to signal miner %d: %v", pid, err)
+	}
+
+	// The miner removes its PID file once drained
+	deadline := time.Now().Add(*timeout)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(pidFile); os.IsNotExist(err) {
+			log.Println("Miner stopped")
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Fatalf("Miner %d still running after %v", pid, *timeout)
 }
 
 func showStatus() {
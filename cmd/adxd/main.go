@@ -5,8 +5,12 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,8 +22,11 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/luxfi/adx/pkg/auction"
 	"github.com/luxfi/adx/pkg/blocklace"
+	"github.com/luxfi/adx/pkg/config"
 	"github.com/luxfi/adx/pkg/core"
 	"github.com/luxfi/adx/pkg/da"
+	"github.com/luxfi/adx/pkg/fraud"
+	"github.com/luxfi/adx/pkg/health"
 	"github.com/luxfi/adx/pkg/ids"
 	"github.com/luxfi/adx/pkg/log"
 	"github.com/luxfi/adx/pkg/settlement"
@@ -27,22 +34,12 @@ import (
 )
 
 var (
-	// Node configuration flags
-	dataDir   = flag.String("data-dir", "/tmp/adxd", "Data directory")
-	nodeID    = flag.String("node-id", "", "Node ID")
-	port      = flag.Int("port", 8000, "HTTP port")
-	rpcPort   = flag.Int("rpc-port", 9000, "RPC port")
-	p2pPort   = flag.Int("p2p-port", 10000, "P2P port")
-	networkID = flag.String("network-id", "adx-local", "Network ID")
-	logLevel  = flag.String("log-level", "info", "Log level")
-
-	// Bootstrap configuration
-	bootstrap      = flag.Bool("bootstrap", false, "Run as bootstrap node")
-	bootstrapNodes = flag.String("bootstrap-nodes", "", "Bootstrap nodes (comma-separated)")
-
-	// Node capabilities
-	isMiner = flag.Bool("miner", false, "Enable mining")
-	teeMode = flag.String("tee-mode", "simulated", "TEE mode: simulated, sgx, nitro")
+	// Node configuration, from defaults, the config file, env and flags
+	cfg    = config.DefaultNode()
+	loader = config.NewLoader(flag.CommandLine, "node", cfg)
+
+	errNotMiner = errors.New("node is not a miner")
+	errNoPeers  = errors.New("no peers connected")
 
 	// Version info
 	Version   = "dev"
@@ -66,6 +63,12 @@ type Node struct {
 	FreqMgr   *core.FrequencyManager
 	DALayer   *da.DataAvailability
 
+	// Readiness checks for /readyz
+	health *health.Checker
+
+	// Screens auction API traffic, refusing blocked sources and bidders
+	fraud *fraud.Analyzer
+
 	// Networking
 	httpServer *http.Server
 	rpcServer  *http.Server
@@ -94,17 +97,17 @@ func main() {
 	fmt.Printf("ADX Daemon (adxd) %s (commit: %s, built: %s)\n", Version, GitCommit, BuildTime)
 
 	// Validate configuration
-	if *nodeID == "" {
-		fmt.Println("Error: --node-id is required")
+	if err := loader.Load(); err != nil {
+		fmt.Printf("Error: invalid configuration:\n%v\n", err)
 		os.Exit(1)
 	}
 
 	// Initialize logger
-	logger := initLogger(*logLevel)
+	logger := initLogger(cfg.LogLevel)
 	defer logger.Sync()
 
 	// Create and start node
-	node, err := NewNode(*nodeID, *networkID, logger)
+	node, err := NewNode(cfg.NodeID, cfg.NetworkID, logger)
 	if err != nil {
 		fmt.Printf("Failed to create node: %v\n", err)
 		os.Exit(1)
@@ -160,16 +163,27 @@ func NewNode(nodeID, networkID string, logger log.Logger) (*Node, error) {
 		DALayer:     daLayer,
 		peers:       make(map[ids.NodeID]*Peer),
 		auctions:    make(map[ids.ID]*auction.Auction),
-		isBootstrap: *bootstrap,
-		isMiner:     *isMiner,
+		isBootstrap: cfg.Bootstrap,
+		isMiner:     cfg.Miner,
 		log:         logger,
 	}
 
 	// Initialize miner if enabled
-	if *isMiner {
+	if cfg.Miner {
 		node.Miner = blocklace.NewCordialMiner(nid, dag, logger)
 	}
 
+	fraudCfg := fraud.DefaultConfig()
+	fraudCfg.AutoBlock = true
+	node.fraud = fraud.NewAnalyzer(fraudCfg)
+
+	node.health = health.New()
+	node.health.Add("enclave", func(ctx context.Context) error { return node.Enclave.Ping(ctx) })
+	node.health.Add("da", func(ctx context.Context) error { return node.DALayer.Ping(ctx) })
+	if !node.isBootstrap && len(cfg.BootstrapNodes) > 0 {
+		node.health.Add("peers", node.checkPeers)
+	}
+
 	return node, nil
 }
 
@@ -180,7 +194,7 @@ func (n *Node) Start() error {
 	// Start HTTP server
 	httpRouter := n.setupHTTPRoutes()
 	n.httpServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", *port),
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
 		Handler: httpRouter,
 	}
 
@@ -194,7 +208,7 @@ func (n *Node) Start() error {
 	// Start RPC server
 	rpcRouter := n.setupRPCRoutes()
 	n.rpcServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", *rpcPort),
+		Addr:    fmt.Sprintf(":%d", cfg.RPCPort),
 		Handler: rpcRouter,
 	}
 
@@ -206,8 +220,8 @@ func (n *Node) Start() error {
 	}()
 
 	// Connect to bootstrap nodes
-	if !n.isBootstrap && *bootstrapNodes != "" {
-		n.connectToBootstrapNodes(*bootstrapNodes)
+	if !n.isBootstrap && len(cfg.BootstrapNodes) > 0 {
+		n.connectToBootstrapNodes(cfg.BootstrapNodes)
 	}
 
 	// Start mining if enabled
@@ -241,8 +255,10 @@ func (n *Node) Shutdown(ctx context.Context) error {
 func (n *Node) setupHTTPRoutes() *mux.Router {
 	r := mux.NewRouter()
 
-	// Health check
-	r.HandleFunc("/health", n.handleHealth).Methods("GET")
+	// Liveness and readiness; /health is kept for older probes
+	r.HandleFunc("/livez", n.health.Livez).Methods("GET")
+	r.HandleFunc("/readyz", n.health.Readyz).Methods("GET")
+	r.HandleFunc("/health", n.health.Livez).Methods("GET")
 
 	// Node info
 	r.HandleFunc("/info", n.handleInfo).Methods("GET")
@@ -260,6 +276,12 @@ func (n *Node) setupRPCRoutes() *mux.Router {
 	// Status
 	r.HandleFunc("/status", n.handleStatus).Methods("GET")
 
+	// DAG endpoints
+	r.HandleFunc("/dag/vertex/{auctionID}", n.handleDAGVertex).Methods("GET")
+
+	// DA endpoints
+	r.HandleFunc("/da/transcript/{auctionID}", n.handleGetTranscript).Methods("GET")
+
 	// Auction endpoints
 	r.HandleFunc("/auction/create", n.handleCreateAuction).Methods("POST")
 	r.HandleFunc("/auction/bid", n.handleSubmitBid).Methods("POST")
@@ -278,10 +300,14 @@ func (n *Node) setupRPCRoutes() *mux.Router {
 
 // HTTP Handlers
 
-func (n *Node) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"healthy","node":"%s"}`, n.ID)
+// checkPeers fails until the node has bootstrapped into the network
+func (n *Node) checkPeers(ctx context.Context) error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if len(n.peers) == 0 {
+		return errNoPeers
+	}
+	return nil
 }
 
 func (n *Node) handleInfo(w http.ResponseWriter, r *http.Request) {
@@ -292,15 +318,16 @@ func (n *Node) handleInfo(w http.ResponseWriter, r *http.Request) {
 		"version":      Version,
 		"is_bootstrap": n.isBootstrap,
 		"is_miner":     n.isMiner,
-		"tee_mode":     *teeMode,
+		"tee_mode":     cfg.TEEMode,
 		"num_peers":    len(n.peers),
 		"num_auctions": len(n.auctions),
+		"dag_height":   n.DAG.GetMetrics().Committed,
 	}
 	n.mu.RUnlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `%v`, info)
+	json.NewEncoder(w).Encode(info)
 }
 
 func (n *Node) handleMetrics(w http.ResponseWriter, r *http.Request) {
@@ -326,14 +353,125 @@ func (n *Node) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"network_id": n.NetworkID,
 		"peers":      len(n.peers),
 		"auctions":   len(n.auctions),
-		"dag_height": 0, // Simplified
+		"dag_height": n.DAG.GetMetrics().Committed,
 		"timestamp":  time.Now().Unix(),
 	}
 	n.mu.RUnlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `%v`, status)
+	json.NewEncoder(w).Encode(status)
+}
+
+// parseAuctionID reads the auctionID route variable, answering 400 if it
+// isn't a valid ID
+func parseAuctionID(w http.ResponseWriter, r *http.Request) (ids.ID, bool) {
+	auctionID, err := ids.FromString(mux.Vars(r)["auctionID"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":"invalid auction id"}`)
+		return ids.Empty, false
+	}
+	return auctionID, true
+}
+
+func (n *Node) handleDAGVertex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	auctionID, ok := parseAuctionID(w, r)
+	if !ok {
+		return
+	}
+
+	v, ok := n.DAG.GetAuctionVertex(auctionID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error":"auction outcome not committed"}`)
+		return
+	}
+
+	predecessors := make([]string, 0, len(v.Header.Predecessors))
+	for _, id := range v.Header.Predecessors {
+		predecessors = append(predecessors, id.String())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"auction_id":     auctionID.String(),
+		"vertex_id":      v.Header.ID.String(),
+		"author":         v.Author.String(),
+		"round":          v.Round,
+		"height":         v.Header.Height,
+		"timestamp":      v.Header.Timestamp.Unix(),
+		"delivered":      v.Delivered,
+		"predecessors":   predecessors,
+		"payload_hash":   hex.EncodeToString(v.PayloadHash),
+		"cm_winner":      hex.EncodeToString(v.Auction.CmWinner),
+		"clearing_price": v.Auction.ClearingPrice,
+		"proof_auction":  hex.EncodeToString(v.Auction.ProofAuction),
+		"policy_root":    hex.EncodeToString(v.Auction.PolicyRoot),
+		"da_ptr":         hex.EncodeToString(v.Auction.DAPtr),
+	})
+}
+
+func (n *Node) handleGetTranscript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	auctionID, ok := parseAuctionID(w, r)
+	if !ok {
+		return
+	}
+
+	transcript, ref, err := n.DALayer.RetrieveTranscript(auctionID)
+	switch {
+	case errors.Is(err, da.ErrNoTranscript), errors.Is(err, da.ErrBlobNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error":"transcript not found"}`)
+		return
+	case errors.Is(err, da.ErrInvalidCommitment):
+		n.log.Error("Transcript failed integrity check")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, `{"error":"transcript failed integrity check"}`)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `{"error":"transcript unavailable"}`)
+		return
+	}
+
+	w.Header().Set("X-Blob-ID", ref.BlobID.String())
+	w.Header().Set("X-Content-Hash", hex.EncodeToString(ref.Commitment))
+	w.WriteHeader(http.StatusOK)
+	w.Write(transcript)
+}
+
+// maxRequestBody bounds an auction API request body
+const maxRequestBody = 64 << 10
+
+// defaultAuctionDuration is how long an auction created without a
+// duration stays open
+const defaultAuctionDuration = 100 * time.Millisecond
+
+// clientAddr is the host a request came from
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// screen passes a request's event to the fraud analyzer and reports
+// whether to serve it. Requests from a blocked source or bidder, including
+// the one that tipped it over, are refused.
+func (n *Node) screen(w http.ResponseWriter, e fraud.Event) bool {
+	n.fraud.Observe(e)
+	if n.fraud.Blocked(e.Source) || (e.BidderID != "" && n.fraud.Blocked(e.BidderID)) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `{"error":"blocked"}`)
+		return false
+	}
+	return true
 }
 
 func (n *Node) handleCreateAuction(w http.ResponseWriter, r *http.Request) {
@@ -343,18 +481,26 @@ func (n *Node) handleCreateAuction(w http.ResponseWriter, r *http.Request) {
 		Reserve  uint64 `json:"reserve"`
 		Duration int64  `json:"duration_ms"`
 	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":"invalid request"}`)
+		return
+	}
+	duration := time.Duration(req.Duration) * time.Millisecond
+	if duration <= 0 {
+		duration = defaultAuctionDuration
+	}
 
-	// Simplified - in production, decode JSON properly
-	req.SlotID = ids.GenerateTestID().String()
-	req.Reserve = 1000
-	req.Duration = 100
+	auctionID := ids.GenerateTestID()
+	if !n.screen(w, fraud.Event{Type: fraud.EventAuctionCreated, Source: clientAddr(r), AuctionID: auctionID.String(), Reserve: req.Reserve}) {
+		return
+	}
 
 	// Create auction
-	auctionID := ids.GenerateTestID()
 	auc := auction.NewAuction(
 		auctionID,
 		req.Reserve,
-		time.Duration(req.Duration)*time.Millisecond,
+		duration,
 		n.log,
 	)
 
@@ -369,20 +515,81 @@ func (n *Node) handleCreateAuction(w http.ResponseWriter, r *http.Request) {
 }
 
 func (n *Node) handleSubmitBid(w http.ResponseWriter, r *http.Request) {
-	// Simplified bid submission
-	bidderID := ids.GenerateTestID()
+	var req struct {
+		AuctionID    string `json:"auction_id"`
+		BidderID     string `json:"bidder_id"`
+		Amount       uint64 `json:"amount"`
+		Commitment   string `json:"commitment"`
+		EncryptedBid string `json:"encrypted_bid"`
+		RangeProof   string `json:"range_proof"`
+		Signature    string `json:"signature"`
+		Nonce        uint64 `json:"nonce"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":"invalid request"}`)
+		return
+	}
+	auctionID, err := ids.FromString(req.AuctionID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":"invalid auction id"}`)
+		return
+	}
+	bidderID, err := ids.FromString(req.BidderID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":"invalid bidder id"}`)
+		return
+	}
+	var sealed [3][]byte
+	for i, s := range []string{req.Commitment, req.EncryptedBid, req.RangeProof} {
+		if sealed[i], err = hex.DecodeString(s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":"invalid bid encoding"}`)
+			return
+		}
+	}
 
-	sealedBid := &auction.SealedBid{
-		BidderID:  bidderID,
-		Timestamp: time.Now(),
+	if !n.screen(w, fraud.Event{
+		Type:      fraud.EventBid,
+		Source:    clientAddr(r),
+		AuctionID: req.AuctionID,
+		BidderID:  req.BidderID,
+		Amount:    req.Amount,
+		Nonce:     req.Nonce,
+		Payload:   []byte(req.EncryptedBid + req.Signature),
+	}) {
+		return
 	}
 
-	// Submit to auction (simplified - would need to find actual auction)
+	sealedBid := &auction.SealedBid{
+		BidderID:     bidderID,
+		Commitment:   sealed[0],
+		EncryptedBid: sealed[1],
+		RangeProof:   sealed[2],
+		Timestamp:    time.Now(),
+	}
 	n.mu.Lock()
-	if auc, exists := n.auctions[ids.GenerateTestID()]; exists {
-		auc.SubmitBid(sealedBid)
+	auc, exists := n.auctions[auctionID]
+	if exists {
+		err = auc.SubmitBid(sealedBid)
 	}
 	n.mu.Unlock()
+	switch {
+	case !exists:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error":"auction not found"}`)
+		return
+	case errors.Is(err, auction.ErrAuctionClosed):
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, `{"error":"auction closed"}`)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":"invalid bid"}`)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -454,19 +661,69 @@ func (n *Node) runMiningLoop() {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		// Simplified mining - just log
+	for now := range ticker.C {
+		n.closeExpiredAuctions(now)
 		n.log.Debug("Mining round completed")
 	}
 }
 
-// Connect to bootstrap nodes
-func (n *Node) connectToBootstrapNodes(nodes string) {
-	for _, node := range strings.Split(nodes, ",") {
-		node = strings.TrimSpace(node)
-		if node == "" {
+// closeExpiredAuctions runs every auction that has ended and commits its
+// outcome to the DAG, then drops it; the DAG and DA layer serve it from
+// there. Auctions without a valid bid have nothing to commit and are
+// dropped too. Auctions that fail to commit for any other reason are kept
+// and retried. Only miners commit, so other nodes do nothing.
+func (n *Node) closeExpiredAuctions(now time.Time) {
+	if n.Miner == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for id, auc := range n.auctions {
+		if now.Before(auc.EndTime) {
 			continue
 		}
+		if _, committed := n.DAG.GetAuctionVertex(id); !committed {
+			_, err := n.closeAuction(auc)
+			if err != nil && !errors.Is(err, auction.ErrNoValidBids) {
+				n.log.Error(fmt.Sprintf("Failed to commit auction %s outcome: %v", id, err))
+				continue
+			}
+		}
+		delete(n.auctions, id)
+	}
+}
+
+// closeAuction runs an auction if it hasn't been, stores its transcript in
+// the DA layer and submits its outcome, pointing at the transcript, to the
+// miner
+func (n *Node) closeAuction(auc *auction.Auction) (*blocklace.Vertex, error) {
+	if n.Miner == nil {
+		return nil, errNotMiner
+	}
+	if auc.Outcome == nil {
+		if _, err := auc.RunAuction(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	transcript, err := auc.Transcript()
+	if err != nil {
+		return nil, err
+	}
+	ref, err := n.DALayer.StoreTranscript(auc.ID, transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	header := auc.CreateAuctionHeader()
+	header.DAPtr = ref.BlobID.Bytes()
+	return n.Miner.CommitAuctionOutcome(header)
+}
+
+// Connect to bootstrap nodes
+func (n *Node) connectToBootstrapNodes(nodes []string) {
+	for _, node := range nodes {
 
 		n.log.Info("Connecting to bootstrap node")
 
@@ -0,0 +1,264 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/auction"
+	"github.com/luxfi/adx/pkg/da"
+	"github.com/luxfi/adx/pkg/health"
+	"github.com/luxfi/adx/pkg/ids"
+	"github.com/luxfi/adx/pkg/log"
+	"github.com/luxfi/adx/pkg/tee"
+)
+
+// sealedBid returns a bid the simplified auction decrypts to value
+func sealedBid(value uint64) *auction.SealedBid {
+	commitment := make([]byte, 32)
+	binary.BigEndian.PutUint64(commitment, value)
+	return &auction.SealedBid{BidderID: ids.GenerateTestID(), Commitment: commitment, Timestamp: time.Now()}
+}
+
+func getJSON(t *testing.T, h http.Handler, path string, v interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	return w.Code
+}
+
+func TestClosedAuctionCommittedToDAG(t *testing.T) {
+	cfg.Miner = true
+	defer func() { cfg.Miner = false }()
+
+	node, err := NewNode("miner-1", "adx-test", log.NoOp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpc := node.setupRPCRoutes()
+
+	auc := auction.NewAuction(ids.GenerateTestID(), 1000, time.Minute, log.NoOp())
+	winner := sealedBid(5000)
+	for _, bid := range []*auction.SealedBid{sealedBid(3000), winner, sealedBid(500)} {
+		if err := auc.SubmitBid(bid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unfilled := auction.NewAuction(ids.GenerateTestID(), 1000, time.Minute, log.NoOp())
+	node.auctions[auc.ID] = auc
+	node.auctions[unfilled.ID] = unfilled
+
+	path := "/dag/vertex/" + auc.ID.String()
+	var vertex map[string]interface{}
+	if code := getJSON(t, rpc, path, &vertex); code != http.StatusNotFound {
+		t.Errorf("open auction: status = %d, want 404", code)
+	}
+
+	node.closeExpiredAuctions(auc.EndTime.Add(time.Second))
+
+	if code := getJSON(t, rpc, path, &vertex); code != http.StatusOK {
+		t.Fatalf("closed auction: status = %d, want 200", code)
+	}
+	if auc.Outcome == nil || auc.Outcome.WinnerID != winner.BidderID {
+		t.Fatalf("outcome = %+v, want the 5000 bid to win", auc.Outcome)
+	}
+	if vertex["auction_id"] != auc.ID.String() || vertex["clearing_price"] != float64(3000) || vertex["delivered"] != true {
+		t.Errorf("vertex = %v, want auction %s cleared at 3000", vertex, auc.ID)
+	}
+	if want := hex.EncodeToString(auc.CreateAuctionHeader().CmWinner); vertex["cm_winner"] != want {
+		t.Errorf("cm_winner = %v, want %s", vertex["cm_winner"], want)
+	}
+	if want := hex.EncodeToString(auc.Outcome.ProofCorrect); vertex["proof_auction"] != want {
+		t.Errorf("proof_auction = %v, want %s", vertex["proof_auction"], want)
+	}
+
+	// Committed auctions are dropped, as is the one with no bids
+	if len(node.auctions) != 0 {
+		t.Errorf("%d auctions still pending", len(node.auctions))
+	}
+	if code := getJSON(t, rpc, "/dag/vertex/"+unfilled.ID.String(), &vertex); code != http.StatusNotFound {
+		t.Errorf("unfilled auction: status = %d, want 404", code)
+	}
+
+	// The transcript is stored in the DA layer and the vertex points at it
+	w := httptest.NewRecorder()
+	rpc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/da/transcript/"+auc.ID.String(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("transcript: status = %d, want 200", w.Code)
+	}
+	var transcript auction.AuctionTranscript
+	if err := json.Unmarshal(w.Body.Bytes(), &transcript); err != nil {
+		t.Fatal(err)
+	}
+	if transcript.AuctionID != auc.ID || len(transcript.Bids) != 3 || transcript.Outcome.ClearingPrice != 3000 {
+		t.Errorf("transcript = %+v", transcript)
+	}
+	if blobID := w.Header().Get("X-Blob-ID"); blobID == "" || vertex["da_ptr"] != blobID {
+		t.Errorf("da_ptr = %v, want blob %s", vertex["da_ptr"], blobID)
+	}
+	if m := node.DALayer.GetMetrics(); m.Stored != 1 || m.Retrieved != 1 {
+		t.Errorf("DA metrics = %+v, want 1 stored and 1 retrieved", m)
+	}
+
+	var status map[string]interface{}
+	if code := getJSON(t, rpc, "/status", &status); code != http.StatusOK || status["dag_height"] != float64(1) {
+		t.Errorf("status = %d %v, want dag_height 1", code, status)
+	}
+}
+
+// downBackend is a DA backend that can't be reached
+type downBackend struct{}
+
+func (downBackend) Put(*da.BlobReference, []byte) error { return errors.New("connection refused") }
+func (downBackend) Get(*da.BlobReference) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestReadyz(t *testing.T) {
+	node, err := NewNode("node-1", "adx-test", log.NoOp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := node.setupHTTPRoutes()
+	probe := func(path string) (int, health.Report) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report health.Report
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w.Code, report
+	}
+
+	if code, report := probe("/readyz"); code != http.StatusOK {
+		t.Fatalf("readyz = %d %+v", code, report)
+	}
+
+	// DA layer unreachable
+	node.DALayer = da.NewDataAvailabilityWithBackend(da.DALayerCelestia, downBackend{}, log.NoOp())
+	code, report := probe("/readyz")
+	if code != http.StatusServiceUnavailable || len(report.Failing) != 1 || report.Failing[0] != "da" {
+		t.Errorf("DA down: readyz = %d %+v", code, report)
+	}
+	if code, _ := probe("/livez"); code != http.StatusOK {
+		t.Errorf("DA down: livez = %d", code)
+	}
+
+	// Enclave loses its attestation too
+	node.Enclave.Attested = false
+	code, report = probe("/readyz")
+	if code != http.StatusServiceUnavailable || len(report.Failing) != 2 || report.Checks["enclave"] != tee.ErrNotAttested.Error() {
+		t.Errorf("enclave down: readyz = %d %+v", code, report)
+	}
+	if code, _ := probe("/livez"); code != http.StatusOK {
+		t.Errorf("enclave down: livez = %d", code)
+	}
+}
+
+func TestAuctionAPI_RefusesBlockedBidder(t *testing.T) {
+	node, err := NewNode("node-1", "adx-test", log.NoOp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpc := node.setupRPCRoutes()
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		rpc.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data)))
+		return w
+	}
+
+	w := post("/auction/create", map[string]interface{}{"reserve": 1000, "duration_ms": 60000})
+	var created map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &created); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+
+	// Each change of a sealed bid is a conflict; the second blocks the bidder
+	bidder, other := ids.GenerateTestID().String(), ids.GenerateTestID().String()
+	bid := func(bidderID string, amount uint64) int {
+		return post("/auction/bid", map[string]interface{}{
+			"auction_id": created["auction_id"],
+			"bidder_id":  bidderID,
+			"amount":     amount,
+			"commitment": hex.EncodeToString(sealedBid(amount).Commitment),
+		}).Code
+	}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusForbidden, http.StatusForbidden} {
+		if code := bid(bidder, uint64(2000+i)); code != want {
+			t.Errorf("bid %d: status = %d, want %d", i+1, code, want)
+		}
+	}
+	if code := bid(other, 2000); code != http.StatusOK {
+		t.Errorf("other bidder: status = %d, want 200", code)
+	}
+	if n := len(node.auctions[mustID(t, created["auction_id"])].Bids); n != 3 {
+		t.Errorf("auction holds %d bids, want 3", n)
+	}
+
+	if code := post("/auction/bid", map[string]interface{}{"auction_id": ids.GenerateTestID().String(), "bidder_id": other}).Code; code != http.StatusNotFound {
+		t.Errorf("unknown auction: status = %d, want 404", code)
+	}
+}
+
+func mustID(t *testing.T, s string) ids.ID {
+	t.Helper()
+	id, err := ids.FromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestCloseExpiredAuctions_KeepsUncommitted(t *testing.T) {
+	node, err := NewNode("node-1", "adx-test", log.NoOp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	auc := auction.NewAuction(ids.GenerateTestID(), 1000, time.Minute, log.NoOp())
+	if err := auc.SubmitBid(sealedBid(3000)); err != nil {
+		t.Fatal(err)
+	}
+	node.auctions[auc.ID] = auc
+
+	// A node that isn't a miner leaves auctions alone
+	node.closeExpiredAuctions(auc.EndTime.Add(time.Second))
+	if _, ok := node.auctions[auc.ID]; !ok || auc.Outcome != nil {
+		t.Fatal("non-miner closed the auction")
+	}
+
+	// A miner that can't store the transcript keeps the auction to retry
+	cfg.Miner = true
+	defer func() { cfg.Miner = false }()
+	miner, err := NewNode("miner-1", "adx-test", log.NoOp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	miner.auctions[auc.ID] = auc
+	miner.DALayer = da.NewDataAvailabilityWithBackend(da.DALayerCelestia, downBackend{}, log.NoOp())
+	miner.closeExpiredAuctions(auc.EndTime.Add(time.Second))
+	if _, ok := miner.auctions[auc.ID]; !ok {
+		t.Fatal("uncommitted auction dropped")
+	}
+
+	miner.DALayer = da.NewDataAvailability(da.DALayerLocal, log.NoOp())
+	miner.closeExpiredAuctions(auc.EndTime.Add(time.Second))
+	if _, ok := miner.auctions[auc.ID]; ok {
+		t.Error("committed auction still pending")
+	}
+	if _, ok := miner.DAG.GetAuctionVertex(auc.ID); !ok {
+		t.Error("auction not committed on retry")
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/luxfi/adx/pkg/apikey"
+	"github.com/luxfi/adx/pkg/reqlog"
+)
+
+var (
+	ErrCORSWildcardCredentials = errors.New(`cors: origin "*" cannot be combined with credentials`)
+	ErrCORSOrigin              = errors.New("cors: invalid origin")
+	ErrUnknownEnv              = errors.New("unknown environment")
+)
+
+// CORS flags fall back to ADX_* environment variables, then to the --env
+// profile
+var (
+	allowedOrigins  = flag.String("allowed-origins", os.Getenv("ADX_ALLOWED_ORIGINS"), "Comma-separated CORS origins; https://*.example.com matches subdomains")
+	allowedMethods  = flag.String("allowed-methods", os.Getenv("ADX_ALLOWED_METHODS"), "Comma-separated CORS methods")
+	allowedHeaders  = flag.String("allowed-headers", os.Getenv("ADX_ALLOWED_HEADERS"), "Comma-separated CORS request headers")
+	corsCredentials = flag.Bool("cors-credentials", envBool("ADX_CORS_CREDENTIALS"), "Allow credentialed CORS requests")
+	corsMaxAge      = flag.Duration("cors-max-age", envDuration("ADX_CORS_MAX_AGE"), "How long browsers may cache preflight results")
+)
+
+// corsOptions are the operator's CORS settings; empty fields take the
+// environment's profile
+type corsOptions struct {
+	Origins     []string
+	Methods     []string
+	Headers     []string
+	Credentials bool
+	MaxAge      time.Duration
+}
+
+// corsProfiles are the defaults per --env. Production only admits our own
+// HTTPS origins; both accept API keys in either header.
+var corsProfiles = map[string]corsOptions{
+	"development": {
+		Origins: []string{"http://localhost:3000", "http://localhost:3001", "https://lux.network", "https://app.lux.network"},
+		Methods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		Headers: []string{"Origin", "Content-Type", "Accept", "Authorization", apikey.Header, reqlog.Header},
+		MaxAge:  12 * time.Hour,
+	},
+	"production": {
+		Origins: []string{"https://lux.network", "https://app.lux.network"},
+		Methods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		Headers: []string{"Origin", "Content-Type", "Accept", "Authorization", apikey.Header, reqlog.Header},
+		MaxAge:  time.Hour,
+	},
+}
+
+// corsFromFlags builds the CORS config from flags and environment
+func corsFromFlags(environment string) (cors.Config, error) {
+	return corsConfig(environment, corsOptions{
+		Origins:     splitList(*allowedOrigins),
+		Methods:     splitList(*allowedMethods),
+		Headers:     splitList(*allowedHeaders),
+		Credentials: *corsCredentials,
+		MaxAge:      *corsMaxAge,
+	})
+}
+
+// corsConfig validates opts over the environment's profile. Origins are
+// exact scheme://host[:port] values or https://*.domain patterns, which
+// match any subdomain of domain but not domain itself.
+func corsConfig(environment string, opts corsOptions) (cors.Config, error) {
+	profile, ok := corsProfiles[environment]
+	if !ok {
+		return cors.Config{}, fmt.Errorf("%w %q: want development or production", ErrUnknownEnv, environment)
+	}
+	if len(opts.Origins) == 0 {
+		opts.Origins = profile.Origins
+	}
+	if len(opts.Methods) == 0 {
+		opts.Methods = profile.Methods
+	}
+	if len(opts.Headers) == 0 {
+		opts.Headers = profile.Headers
+	}
+	if opts.MaxAge == 0 {
+		opts.MaxAge = profile.MaxAge
+	}
+
+	config := cors.Config{
+		AllowMethods:     opts.Methods,
+		AllowHeaders:     opts.Headers,
+		ExposeHeaders:    []string{reqlog.Header},
+		AllowCredentials: opts.Credentials,
+		MaxAge:           opts.MaxAge,
+	}
+
+	exact := make(map[string]bool)
+	var suffixes []originPattern
+	for _, origin := range opts.Origins {
+		if origin == "*" {
+			if opts.Credentials {
+				return cors.Config{}, ErrCORSWildcardCredentials
+			}
+			if len(opts.Origins) > 1 {
+				return cors.Config{}, fmt.Errorf(`%w: "*" must be the only origin`, ErrCORSOrigin)
+			}
+			config.AllowAllOrigins = true
+			return config, config.Validate()
+		}
+		p, err := parseOrigin(origin, environment == "production")
+		if err != nil {
+			return cors.Config{}, err
+		}
+		if p.wildcard {
+			suffixes = append(suffixes, p)
+		} else {
+			exact[p.scheme+"://"+p.host] = true
+		}
+	}
+
+	config.AllowOriginFunc = func(origin string) bool {
+		if exact[strings.ToLower(origin)] {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		for _, p := range suffixes {
+			if p.matches(strings.ToLower(u.Scheme), strings.ToLower(u.Host)) {
+				return true
+			}
+		}
+		return false
+	}
+	return config, config.Validate()
+}
+
+// originPattern is a validated allowed origin. For wildcards host is the
+// domain the subdomains must end in, port included.
+type originPattern struct {
+	scheme   string
+	host     string
+	wildcard bool
+}
+
+func (p originPattern) matches(scheme, host string) bool {
+	if scheme != p.scheme {
+		return false
+	}
+	sub := strings.TrimSuffix(host, "."+p.host)
+	return sub != host && sub != "" && !strings.ContainsAny(sub, ":/")
+}
+
+// parseOrigin checks an allowed origin. Wildcards must cover a whole label
+// of a domain with at least two labels, so "*.com" and "app*.example.com"
+// are rejected. Production only allows HTTPS.
+func parseOrigin(origin string, production bool) (originPattern, error) {
+	bad := func(reason string) (originPattern, error) {
+		return originPattern{}, fmt.Errorf("%w %q: %s", ErrCORSOrigin, origin, reason)
+	}
+
+	u, err := url.Parse(strings.ToLower(strings.TrimSpace(origin)))
+	if err != nil || u.Host == "" {
+		return bad("want scheme://host[:port]")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return bad("scheme must be http or https")
+	}
+	if production && u.Scheme != "https" {
+		return bad("production origins must use https")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return bad("origins have no path, query or credentials")
+	}
+
+	p := originPattern{scheme: u.Scheme, host: u.Host}
+	if strings.HasPrefix(u.Host, "*.") {
+		p.wildcard, p.host = true, strings.TrimPrefix(u.Host, "*.")
+	}
+	hostname := strings.Split(p.host, ":")[0]
+	if strings.Contains(p.host, "*") {
+		return bad("wildcards must be a leading *. label")
+	}
+	if p.wildcard && strings.Count(hostname, ".") < 1 {
+		return bad("wildcard must be under a registrable domain")
+	}
+	return p, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+func envDuration(key string) time.Duration {
+	d, _ := time.ParseDuration(os.Getenv(key))
+	return d
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/apikey"
+)
+
+func corsRouter(t *testing.T, environment string, opts corsOptions) *gin.Engine {
+	t.Helper()
+	cfg, err := corsConfig(environment, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(cors.New(cfg))
+	r.GET("/api/v1/vast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func preflight(r http.Handler, origin, header string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/vast", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	if header != "" {
+		req.Header.Set("Access-Control-Request-Headers", header)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS_Origins(t *testing.T) {
+	r := corsRouter(t, "production", corsOptions{
+		Origins:     []string{"https://publisher.example", "https://*.lux.network"},
+		Credentials: true,
+		MaxAge:      10 * time.Minute,
+	})
+
+	for _, tt := range []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://publisher.example", true},
+		{"https://app.lux.network", true},
+		{"https://a.b.lux.network", true},
+		{"https://lux.network", false},
+		{"http://app.lux.network", false},
+		{"https://evil-lux.network", false},
+		{"https://lux.network.evil.example", false},
+		{"https://app.lux.network:8443", false},
+		{"https://other.example", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/vast", nil)
+		req.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		got := w.Header().Get("Access-Control-Allow-Origin")
+		if tt.allowed && (got != tt.origin || w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Credentials") != "true") {
+			t.Errorf("%s: status %d allow-origin %q credentials %q", tt.origin, w.Code, got, w.Header().Get("Access-Control-Allow-Credentials"))
+		}
+		if !tt.allowed && (got != "" || w.Code != http.StatusForbidden) {
+			t.Errorf("%s: status %d allow-origin %q, want 403 without CORS headers", tt.origin, w.Code, got)
+		}
+	}
+
+	w := preflight(r, "https://app.lux.network", "Content-Type")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight: status %d max-age %q", w.Code, w.Header().Get("Access-Control-Max-Age"))
+	}
+}
+
+func TestCORS_Profiles(t *testing.T) {
+	// Production accepts API keys but not localhost by default
+	prod := corsRouter(t, "production", corsOptions{})
+	for _, header := range []string{"Authorization", apikey.Header} {
+		if w := preflight(prod, "https://app.lux.network", header); w.Code != http.StatusNoContent || !strings.Contains(strings.ToLower(w.Header().Get("Access-Control-Allow-Headers")), strings.ToLower(header)) {
+			t.Errorf("production %s preflight: status %d allow-headers %q", header, w.Code, w.Header().Get("Access-Control-Allow-Headers"))
+		}
+	}
+	if w := preflight(prod, "http://localhost:3000", ""); w.Code != http.StatusForbidden {
+		t.Errorf("production localhost preflight: status %d", w.Code)
+	}
+
+	dev := corsRouter(t, "development", corsOptions{})
+	if w := preflight(dev, "http://localhost:3000", "Authorization"); w.Code != http.StatusNoContent || !strings.Contains(strings.ToLower(w.Header().Get("Access-Control-Allow-Headers")), "authorization") {
+		t.Errorf("development preflight: status %d allow-headers %q", w.Code, w.Header().Get("Access-Control-Allow-Headers"))
+	}
+}
+
+func TestCORS_RejectsMisconfiguration(t *testing.T) {
+	for _, environment := range []string{"", "prod", "staging"} {
+		if _, err := corsConfig(environment, corsOptions{}); !errors.Is(err, ErrUnknownEnv) {
+			t.Errorf("environment %q: err = %v", environment, err)
+		}
+	}
+	if _, err := corsConfig("development", corsOptions{Origins: []string{"*"}, Credentials: true}); !errors.Is(err, ErrCORSWildcardCredentials) {
+		t.Errorf(`"*" with credentials: err = %v`, err)
+	}
+	if _, err := corsConfig("development", corsOptions{Origins: []string{"*"}}); err != nil {
+		t.Errorf(`"*" without credentials: %v`, err)
+	}
+
+	for _, origin := range []string{
+		"https://*.com",
+		"https://app*.lux.network",
+		"https://*.*.lux.network",
+		"https://lux.network/path",
+		"ftp://lux.network",
+		"lux.network",
+	} {
+		if _, err := corsConfig("development", corsOptions{Origins: []string{origin}}); !errors.Is(err, ErrCORSOrigin) {
+			t.Errorf("%s: err = %v, want ErrCORSOrigin", origin, err)
+		}
+	}
+	if _, err := corsConfig("production", corsOptions{Origins: []string{"http://app.lux.network"}}); !errors.Is(err, ErrCORSOrigin) {
+		t.Errorf("http origin in production: err = %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/luxfi/adx/pkg/vast"
+)
+
+// eventHandler ingests the tracking beacons emitted in VAST responses
+type eventHandler struct {
+	tracker *analytics.AnalyticsTracker
+	rewards *vast.RewardManager
+	views   *vast.VASTHandler // Records proven views on-chain
+}
+
+// track handles /v1/event. Served impressions are keyed by serve and bid
+// ID, as vast.ImpressionKey, since OpenRTB imp IDs repeat across requests
+// and serves of a cached auction share their bid. Quartile beacons must
+// follow an impression beacon and are counted once per impression, and an
+// impression beacon's pos is its position in an ad pod. A rewarded view's
+// complete beacon carries its reward token and releases the payout. An
+// impression beacon with a pov ticket for the same impression key, its sid
+// and the viewer's wallet records the view on-chain.
+func (h *eventHandler) track(c *gin.Context) {
+	event := c.Query("event")
+	bid := c.Query("bid")
+	if bid == "" {
+		bid = c.Query("imp")
+	}
+	if event == "" || bid == "" {
+		c.JSON(400, gin.H{"error": "event and bid are required"})
+		return
+	}
+	key := vast.ImpressionKey(c.Query("srv"), bid)
+
+	if h.rewards != nil {
+		h.rewards.Progress(key, event)
+	}
+
+	switch event {
+	case "impression":
+		pos, _ := strconv.Atoi(c.Query("pos"))
+		h.tracker.Video.RegisterAt(key, c.Query("cid"), c.Query("crid"), pos)
+		if pov := c.Query("pov"); pov != "" && h.views != nil {
+			chainID, _ := strconv.Atoi(c.Query("chain"))
+			if err := h.views.RecordView(key, pov, c.Query("sid"), c.Query("wallet"), chainID); err != nil {
+				c.JSON(403, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	case "click":
+		h.tracker.TrackEvent(&analytics.Event{
+			Type:         analytics.EventClick,
+			ImpressionID: key,
+			CampaignID:   c.Query("cid"),
+			CreativeID:   c.Query("crid"),
+		})
+	case "error", "verificationNotExecuted":
+		// Player and OMID verification errors are accepted so players
+		// don't retry them
+	default:
+		if _, err := h.tracker.TrackVideoEvent(key, event); err != nil {
+			switch {
+			case errors.Is(err, analytics.ErrUnknownVideoEvent):
+				c.JSON(400, gin.H{"error": err.Error()})
+			case errors.Is(err, analytics.ErrUnknownImpression):
+				c.JSON(404, gin.H{"error": err.Error()})
+			default:
+				c.JSON(500, gin.H{"error": err.Error()})
+			}
+			return
+		}
+	}
+
+	if token := c.Query("rwd"); token != "" && event == "complete" && h.rewards != nil {
+		if _, err := h.rewards.Complete(token, key, c.Query("wallet")); err != nil {
+			c.JSON(403, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Status(204)
+}
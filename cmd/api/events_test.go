@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/luxfi/adx/pkg/vast"
+)
+
+func TestTrackEvent_QuartileSequence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker := analytics.NewAnalyticsTracker()
+	events := &eventHandler{tracker: tracker}
+	reports := &reportHandler{tracker: tracker}
+
+	r := gin.New()
+	r.GET("/v1/event", events.track)
+	r.GET("/reports/performance", reports.getPerformanceReport)
+
+	fire := func(query string, want int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/event?"+query, nil))
+		if w.Code != want {
+			t.Fatalf("%s = %d, want %d", query, w.Code, want)
+		}
+	}
+
+	// Beacons before the impression are rejected
+	fire("event=start&bid=b1", http.StatusNotFound)
+
+	fire("event=impression&imp=1&bid=b1&cid=camp_1&crid=cre_1", http.StatusNoContent)
+	fire("event=impression&imp=1&bid=b2&cid=camp_1&crid=cre_1", http.StatusNoContent)
+	for _, e := range []string{"start", "firstQuartile", "midpoint", "thirdQuartile", "complete", "complete"} {
+		fire("event="+e+"&imp=1&bid=b1", http.StatusNoContent)
+	}
+	fire("event=start&imp=1&bid=b2", http.StatusNoContent)
+	fire("event=rewind&bid=b2", http.StatusBadRequest)
+
+	stats := tracker.Video.Creative("cre_1")
+	if stats.VCR() != 0.5 {
+		t.Errorf("VCR = %v, want 0.5", stats.VCR())
+	}
+	if got := stats.DropOff(); got != [5]float64{1, 0.5, 0.5, 0.5, 0.5} {
+		t.Errorf("DropOff = %v", got)
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/performance?start_date="+today+"&end_date="+today, nil))
+
+	var resp struct {
+		Campaigns []struct {
+			ID        string     `json:"id"`
+			VCR       float64    `json:"vcr"`
+			Quartiles [5]float64 `json:"quartiles"`
+		} `json:"campaigns"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Campaigns) != 1 || resp.Campaigns[0].ID != "camp_1" || resp.Campaigns[0].VCR != 0.5 {
+		t.Errorf("campaigns = %+v", resp.Campaigns)
+	}
+}
+
+func TestTrackEvent_Skip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker := analytics.NewAnalyticsTracker()
+	events := &eventHandler{tracker: tracker}
+
+	r := gin.New()
+	r.GET("/v1/event", events.track)
+	fire := func(query string, want int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/event?"+query, nil))
+		if w.Code != want {
+			t.Fatalf("%s = %d, want %d", query, w.Code, want)
+		}
+	}
+
+	fire("event=skip&bid=b0", http.StatusNotFound)
+
+	// Four impressions: one skipped twice over, three watched, two of
+	// those to the end
+	for _, b := range []string{"b1", "b2", "b3", "b4"} {
+		fire("event=impression&imp=1&bid="+b+"&cid=camp_1&crid=cre_1", http.StatusNoContent)
+		fire("event=start&imp=1&bid="+b, http.StatusNoContent)
+	}
+	fire("event=skip&imp=1&bid=b1", http.StatusNoContent)
+	fire("event=skip&imp=1&bid=b1", http.StatusNoContent)
+	fire("event=complete&imp=1&bid=b2", http.StatusNoContent)
+	fire("event=complete&imp=1&bid=b3", http.StatusNoContent)
+
+	stats := tracker.Video.Creative("cre_1")
+	if stats.Skips != 1 || stats.SkipRate() != 0.25 {
+		t.Errorf("skips = %d, rate %v; want 1, 0.25", stats.Skips, stats.SkipRate())
+	}
+	if got := stats.PostSkipVCR(); got != 2.0/3 {
+		t.Errorf("post-skip VCR = %v, want 2/3", got)
+	}
+}
+
+func TestTrackEvent_CachedServes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker := analytics.NewAnalyticsTracker()
+	events := &eventHandler{tracker: tracker}
+
+	r := gin.New()
+	r.GET("/v1/event", events.track)
+	fire := func(query string, want int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/event?"+query, nil))
+		if w.Code != want {
+			t.Fatalf("%s = %d, want %d", query, w.Code, want)
+		}
+	}
+
+	// Two serves of one cached auction share the bid, but are two
+	// impressions
+	fire("event=impression&imp=1&bid=b1&srv=s1&cid=camp_1&crid=cre_1", http.StatusNoContent)
+	fire("event=impression&imp=1&bid=b1&srv=s2&cid=camp_1&crid=cre_1", http.StatusNoContent)
+	fire("event=complete&imp=1&bid=b1&srv=s1", http.StatusNoContent)
+	fire("event=complete&imp=1&bid=b1&srv=s3", http.StatusNotFound)
+
+	stats := tracker.Video.Creative("cre_1")
+	if stats.VCR() != 0.5 {
+		t.Errorf("VCR = %v, want 0.5 over two impressions", stats.VCR())
+	}
+}
+
+func TestTrackEvent_ProofOfView(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := filepath.Join(t.TempDir(), "pov-keys.json")
+	if err := os.WriteFile(keys, []byte(`{"player-1":"`+hex.EncodeToString(pub)+`"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	verifier := vast.NewPoVVerifier(10 * time.Minute)
+	if err := loadPoVKeys(verifier, keys); err != nil {
+		t.Fatal(err)
+	}
+	views := &vast.VASTHandler{Storage: &MockStorage{}, BlockchainMgr: &MockBlockchain{povVerifier: verifier}}
+	events := &eventHandler{tracker: analytics.NewAnalyticsTracker(), views: views}
+	r := gin.New()
+	r.GET("/v1/event", events.track)
+
+	fire := func(query string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/event?"+query, nil))
+		return w.Code
+	}
+
+	// The ticket is bound to the serve's impression key, not the bid alone
+	pov, _ := vast.SignPoV(key, "player-1", "srv-1:b1", "s1", time.Now())
+	if code := fire("event=impression&srv=srv-2&bid=b1&sid=s1&wallet=0xabc&pov=" + url.QueryEscape(pov)); code != http.StatusForbidden {
+		t.Errorf("ticket for another serve = %d, want 403", code)
+	}
+	if code := fire("event=impression&srv=srv-1&bid=b1&sid=s1&wallet=0xabc&pov=" + url.QueryEscape(pov)); code != http.StatusNoContent {
+		t.Errorf("proven view = %d, want 204", code)
+	}
+}
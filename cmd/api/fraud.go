@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/apikey"
+	"github.com/luxfi/adx/pkg/fraud"
+)
+
+// maxBidBody bounds a bid request body
+const maxBidBody = 1 << 20
+
+// fraudScreen feeds bid traffic to the fraud analyzer and refuses the
+// sources and bidders it has blocked
+type fraudScreen struct {
+	analyzer *fraud.Analyzer
+}
+
+func newFraudScreen() *fraudScreen {
+	cfg := fraud.DefaultConfig()
+	cfg.AutoBlock = true
+	return &fraudScreen{analyzer: fraud.NewAnalyzer(cfg)}
+}
+
+// bids screens the bid routes. The source is the caller's API key, or its
+// address when the API runs without keys, and the auction is the OpenRTB
+// request's ID. The request that gets a source blocked is refused too.
+func (s *fraudScreen) bids(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBidBody))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	e := fraud.Event{Type: fraud.EventBid, Source: c.ClientIP(), Payload: body}
+	if key, ok := apikey.FromContext(c); ok {
+		e.Source, e.BidderID = "key:"+key.ID, key.Owner
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(body, &req) // The handler rejects malformed requests
+	e.AuctionID = req.ID
+
+	s.analyzer.Observe(e)
+	if s.analyzer.Blocked(e.Source) || (e.BidderID != "" && s.analyzer.Blocked(e.BidderID)) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "blocked"})
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFraudScreen_BlocksReplayingSource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	screen := newFraudScreen()
+	r := gin.New()
+	r.POST("/rtb/bid", screen.bids, handleBidRequest)
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rtb/bid", strings.NewReader(body)))
+		return w.Code
+	}
+
+	// Each replay scores a quarter; the fourth blocks the source
+	for i, want := range []int{200, 200, 200, 200, 403} {
+		if code := post(`{"id":"req-1","imp":[{"id":"1"}]}`); code != want {
+			t.Errorf("request %d: status = %d, want %d", i+1, code, want)
+		}
+	}
+	if code := post(`{"id":"req-2","imp":[{"id":"1"}]}`); code != http.StatusForbidden {
+		t.Errorf("fresh request from blocked source: status = %d, want 403", code)
+	}
+	if !screen.analyzer.Blocked("192.0.2.1") {
+		t.Error("source not blocked")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/luxfi/adx/pkg/health"
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/luxfi/adx/pkg/vast"
+)
+
+func TestReadyz_DSPReachability(t *testing.T) {
+	dsp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed) // Bid endpoints only take POST
+	}))
+	defer dsp.Close()
+
+	exchange := &RTBExchangeWrapper{rtbExchange: &rtb.RTBExchange{
+		AuctionTimeout: 50 * time.Millisecond,
+		DSPs:           map[string]*rtb.DSPConnection{"dsp1": {ID: "dsp1", Endpoint: dsp.URL}},
+		Revenue:        big.NewInt(0),
+	}}
+	vastHandler := &vast.VASTHandler{Exchange: exchange, Storage: &MockStorage{}}
+	cfg, err := corsConfig("development", corsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := setupRouter(vastHandler, exchange, analytics.NewAnalyticsTracker(), cfg, nil)
+
+	probe := func(path string) (int, health.Report) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report health.Report
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w.Code, report
+	}
+
+	if code, report := probe("/readyz"); code != http.StatusOK || report.Checks["dsps"] != "ok" || report.Checks["storage"] != "ok" {
+		t.Fatalf("readyz = %d %+v", code, report)
+	}
+
+	dsp.Close()
+	code, report := probe("/readyz")
+	if code != http.StatusServiceUnavailable || len(report.Failing) != 1 || report.Failing[0] != "dsps" {
+		t.Errorf("DSP down: readyz = %d %+v", code, report)
+	}
+	if code, _ := probe("/livez"); code != http.StatusOK {
+		t.Errorf("DSP down: livez = %d", code)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/apikey"
+)
+
+// keyHandler serves self-serve API-key management. The admin token may
+// manage any account's keys; a key may manage its own account's, issuing
+// keys with no more scopes or QPS than it has itself.
+type keyHandler struct {
+	keys       *apikey.Service
+	adminToken string // Only keys manage keys when empty
+}
+
+// bootstrapKey issues a key with every scope when there's neither a live
+// key nor an admin token to issue one with, writing it to path for the
+// operator. It returns false when keys can already be issued.
+func bootstrapKey(keys *apikey.Service, adminToken, path string) (bool, error) {
+	if adminToken != "" || !keys.Empty() {
+		return false, nil
+	}
+	token, _, err := keys.Issue(apikey.Spec{Owner: "admin", Scopes: apikey.Scopes})
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// caller authenticates a key-management request, returning the caller's
+// key, or nil for the admin
+func (h *keyHandler) caller(c *gin.Context) (*apikey.Key, bool) {
+	token := apikey.Token(c.Request)
+	if h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1 {
+		return nil, true
+	}
+	key, err := h.keys.Authenticate(token)
+	switch {
+	case errors.Is(err, apikey.ErrRateLimited):
+		c.Header("Retry-After", "1")
+		c.JSON(429, gin.H{"error": err.Error()})
+		return nil, false
+	case err != nil:
+		c.Header("WWW-Authenticate", `Bearer realm="adx-api"`)
+		c.JSON(401, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return key, true
+}
+
+// owned returns key id if caller may manage it, answering 404 if not so
+// other accounts' key IDs aren't confirmed
+func (h *keyHandler) owned(c *gin.Context, caller *apikey.Key) (*apikey.Key, bool) {
+	key, ok := h.keys.Get(c.Param("id"))
+	if !ok || (caller != nil && key.Owner != caller.Owner) {
+		c.JSON(404, gin.H{"error": apikey.ErrUnknownKey.Error()})
+		return nil, false
+	}
+	return key, true
+}
+
+func (h *keyHandler) issue(c *gin.Context) {
+	caller, ok := h.caller(c)
+	if !ok {
+		return
+	}
+	var spec apikey.Spec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if caller != nil {
+		if spec.Owner == "" {
+			spec.Owner = caller.Owner
+		}
+		if spec.Owner != caller.Owner {
+			c.JSON(403, gin.H{"error": "keys can only be issued for the caller's own account"})
+			return
+		}
+		spec.OwnerType = caller.OwnerType
+		if spec.QPS == 0 || spec.QPS > caller.QPS {
+			spec.QPS = caller.QPS
+		}
+		for _, scope := range spec.Scopes {
+			if !slices.Contains(caller.Scopes, scope) {
+				c.JSON(403, gin.H{"error": apikey.ErrScope.Error() + ": " + string(scope)})
+				return
+			}
+		}
+	}
+
+	token, key, err := h.keys.Issue(spec)
+	switch {
+	case errors.Is(err, apikey.ErrInvalidSpec):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+	default:
+		c.JSON(201, gin.H{"key": key, "api_key": token})
+	}
+}
+
+// list returns the caller's account's keys, or for the admin those of the
+// owner query parameter
+func (h *keyHandler) list(c *gin.Context) {
+	caller, ok := h.caller(c)
+	if !ok {
+		return
+	}
+	owner := c.Query("owner")
+	if caller != nil {
+		owner = caller.Owner
+	}
+	if owner == "" {
+		c.JSON(400, gin.H{"error": "owner is required"})
+		return
+	}
+	keys := h.keys.Keys(owner)
+	if keys == nil {
+		keys = []apikey.Key{}
+	}
+	c.JSON(200, gin.H{"keys": keys})
+}
+
+// rotate replaces a key, the old one working on for grace (a duration,
+// apikey.DefaultGrace when omitted)
+func (h *keyHandler) rotate(c *gin.Context) {
+	caller, ok := h.caller(c)
+	if !ok {
+		return
+	}
+	key, ok := h.owned(c, caller)
+	if !ok {
+		return
+	}
+	var grace time.Duration
+	if s := c.Query("grace"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			c.JSON(400, gin.H{"error": "invalid grace, expected a duration such as 24h"})
+			return
+		}
+		grace = d
+	}
+
+	token, next, err := h.keys.Rotate(key.ID, grace)
+	switch {
+	case errors.Is(err, apikey.ErrRotated):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, apikey.ErrUnknownKey):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+	default:
+		c.JSON(201, gin.H{"key": next, "api_key": token})
+	}
+}
+
+func (h *keyHandler) revoke(c *gin.Context) {
+	caller, ok := h.caller(c)
+	if !ok {
+		return
+	}
+	key, ok := h.owned(c, caller)
+	if !ok {
+		return
+	}
+	err := h.keys.Revoke(key.ID)
+	switch {
+	case errors.Is(err, apikey.ErrUnknownKey):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+	default:
+		c.Status(204)
+	}
+}
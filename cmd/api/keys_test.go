@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/apikey"
+)
+
+func keyRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	keys, err := apikey.NewService(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &keyHandler{keys: keys, adminToken: "admin-secret"}
+	r := gin.New()
+	r.POST("/keys", h.issue)
+	r.GET("/keys", h.list)
+	r.POST("/keys/:id/rotate", h.rotate)
+	r.DELETE("/keys/:id", h.revoke)
+	r.GET("/reports/revenue", keys.Require(apikey.ScopeReportRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/campaigns", keys.Require(apikey.ScopeCampaignWrite), func(c *gin.Context) { c.Status(http.StatusCreated) })
+	return r
+}
+
+type issuedKey struct {
+	Key    apikey.Key `json:"key"`
+	APIKey string     `json:"api_key"`
+}
+
+func keyCall(t *testing.T, r *gin.Engine, method, url, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func issueKey(t *testing.T, r *gin.Engine, token, body string) issuedKey {
+	t.Helper()
+	w := keyCall(t, r, http.MethodPost, "/keys", token, body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue %s = %d: %s", body, w.Code, w.Body.String())
+	}
+	var k issuedKey
+	if err := json.Unmarshal(w.Body.Bytes(), &k); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeys_SelfServe(t *testing.T) {
+	r := keyRouter(t)
+	if w := keyCall(t, r, http.MethodPost, "/keys", "", `{"owner":"adv-1","scopes":["report-read"]}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated issue = %d", w.Code)
+	}
+
+	root := issueKey(t, r, "admin-secret", `{"owner":"adv-1","owner_type":"advertiser","scopes":["campaign-write","report-read"],"qps":10}`)
+
+	// The account issues itself a narrower key, owner and type filled in
+	reader := issueKey(t, r, root.APIKey, `{"scopes":["report-read"],"qps":100}`)
+	if reader.Key.Owner != "adv-1" || reader.Key.OwnerType != apikey.OwnerAdvertiser || reader.Key.QPS != 10 {
+		t.Errorf("self-issued key = %+v", reader.Key)
+	}
+	if w := keyCall(t, r, http.MethodGet, "/reports/revenue", reader.APIKey, ""); w.Code != http.StatusOK {
+		t.Errorf("report-read key on reports = %d", w.Code)
+	}
+	if w := keyCall(t, r, http.MethodPost, "/campaigns", reader.APIKey, ""); w.Code != http.StatusForbidden {
+		t.Errorf("report-read key on campaigns = %d, want 403", w.Code)
+	}
+
+	// No escalating past the caller's own scopes or account
+	for _, body := range []string{
+		`{"scopes":["campaign-write"]}`,
+		`{"scopes":["bid-submit"]}`,
+		`{"owner":"adv-2","scopes":["report-read"]}`,
+	} {
+		if w := keyCall(t, r, http.MethodPost, "/keys", reader.APIKey, body); w.Code != http.StatusForbidden {
+			t.Errorf("issue %s = %d, want 403", body, w.Code)
+		}
+	}
+
+	var list struct{ Keys []apikey.Key }
+	w := keyCall(t, r, http.MethodGet, "/keys", reader.APIKey, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Keys) != 2 {
+		t.Errorf("list = %d %s", w.Code, w.Body.String())
+	}
+
+	// Other accounts' keys aren't visible
+	other := issueKey(t, r, "admin-secret", `{"owner":"adv-2","scopes":["report-read"]}`)
+	if w := keyCall(t, r, http.MethodDelete, "/keys/"+other.Key.ID, root.APIKey, ""); w.Code != http.StatusNotFound {
+		t.Errorf("revoke another account's key = %d, want 404", w.Code)
+	}
+}
+
+func TestKeys_Rotate(t *testing.T) {
+	r := keyRouter(t)
+	old := issueKey(t, r, "admin-secret", `{"owner":"pub-1","scopes":["report-read"]}`)
+
+	w := keyCall(t, r, http.MethodPost, "/keys/"+old.Key.ID+"/rotate?grace=1h", old.APIKey, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("rotate = %d: %s", w.Code, w.Body.String())
+	}
+	var next issuedKey
+	if err := json.Unmarshal(w.Body.Bytes(), &next); err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"old": old.APIKey, "new": next.APIKey} {
+		if w := keyCall(t, r, http.MethodGet, "/reports/revenue", token, ""); w.Code != http.StatusOK {
+			t.Errorf("%s key in grace window = %d", name, w.Code)
+		}
+	}
+	if w := keyCall(t, r, http.MethodPost, "/keys/"+old.Key.ID+"/rotate", next.APIKey, ""); w.Code != http.StatusConflict {
+		t.Errorf("second rotation = %d, want 409", w.Code)
+	}
+	if w := keyCall(t, r, http.MethodPost, "/keys/"+next.Key.ID+"/rotate?grace=soon", next.APIKey, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("bad grace = %d, want 400", w.Code)
+	}
+}
+
+func TestKeys_RevokedRejectedImmediately(t *testing.T) {
+	r := keyRouter(t)
+	k := issueKey(t, r, "admin-secret", `{"owner":"adv-1","scopes":["campaign-write"]}`)
+	if w := keyCall(t, r, http.MethodPost, "/campaigns", k.APIKey, ""); w.Code != http.StatusCreated {
+		t.Fatalf("before revocation = %d", w.Code)
+	}
+
+	// A key may revoke itself
+	if w := keyCall(t, r, http.MethodDelete, "/keys/"+k.Key.ID, k.APIKey, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke = %d: %s", w.Code, w.Body.String())
+	}
+	if w := keyCall(t, r, http.MethodPost, "/campaigns", k.APIKey, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key = %d, want 401", w.Code)
+	}
+	if w := keyCall(t, r, http.MethodGet, "/keys", k.APIKey, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key listing keys = %d, want 401", w.Code)
+	}
+}
+
+func TestBootstrapKey(t *testing.T) {
+	keys, err := apikey.NewService(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.json.bootstrap")
+	if issued, err := bootstrapKey(keys, "admin-secret", path); issued || err != nil {
+		t.Fatalf("with an admin token: issued %v, err %v", issued, err)
+	}
+	if issued, err := bootstrapKey(keys, "", path); !issued || err != nil {
+		t.Fatalf("with neither: issued %v, err %v", issued, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, scope := range apikey.Scopes {
+		if _, err := keys.Authorize(strings.TrimSpace(string(data)), scope); err != nil {
+			t.Errorf("bootstrap key lacks %s: %v", scope, err)
+		}
+	}
+	if issued, _ := bootstrapKey(keys, "", path); issued {
+		t.Error("issued a second bootstrap key")
+	}
+}
@@ -2,19 +2,39 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/luxfi/adx/pkg/apikey"
+	"github.com/luxfi/adx/pkg/chainvm"
+	"github.com/luxfi/adx/pkg/creative"
+	"github.com/luxfi/adx/pkg/health"
+	"github.com/luxfi/adx/pkg/reqlog/reqgin"
 	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/luxfi/adx/pkg/storage"
+	"github.com/luxfi/adx/pkg/tracing"
 	"github.com/luxfi/adx/pkg/vast"
 	"github.com/shopspring/decimal"
 )
@@ -24,16 +44,69 @@ var (
 	env    = flag.String("env", "development", "Environment (development/production)")
 	rtbURL = flag.String("rtb", "http://localhost:9090", "RTB exchange URL")
 	cdnURL = flag.String("cdn", "https://cdn.lux.network", "CDN base URL")
+	geoCSV = flag.String("geoip-csv", "", "GeoIP network CSV (network,country,region,city)")
+	otlp   = flag.String("otlp-endpoint", "", "OTLP/HTTP collector host:port for tracing (disabled when empty)")
+
+	asi     = flag.String("asi", "lux.network", "Exchange domain publishers list in ads.txt")
+	sellers = flag.String("sellers", "", "sellers.json to publish; enables ads.txt checks on bid requests")
+
+	schainComplete = flag.Bool("schain-require-complete", false, "Reject bid requests whose schain doesn't reach back to the publisher")
+	schainUnknown  = flag.Bool("schain-reject-unknown", false, "Reject bid requests whose schain has a node its ad system's sellers.json doesn't list")
+
+	tcfVendorID = flag.Int("tcf-vendor-id", 0, "Our IAB Global Vendor List ID, checked against TCF consent (GDPR requests get no ad when 0)")
+
+	rewardAmount = flag.Float64("reward-amount", 0.01, "Payout per completed rewarded video view")
+	vastCacheTTL = flag.Duration("vast-cache-ttl", 0, "How long identical non-personalized VAST requests share an auction (0 disables)")
+	receiptKey   = flag.String("receipt-key", "", "File holding the hex ed25519 seed served-ad receipts are signed with (no receipts when empty)")
+
+	skadnKey      = flag.String("skadn-key", "", "File holding the PEM EC private key SKAdNetwork ads are signed with (no SKAdNetwork ads when empty)")
+	skadnNetworks = flag.String("skadn-networks", "", "JSON file mapping bidder seats to the SKAdNetwork IDs they're registered under")
+
+	povKeys = flag.String("pov-keys", "", "JSON file mapping player key IDs to the hex ed25519 keys proofs of view are signed with (no views recorded on-chain when empty)")
+
+	ffmpeg           = flag.String("ffmpeg", "ffmpeg", "ffmpeg binary creatives are transcoded with")
+	transcodeDir     = flag.String("transcode-dir", "./static/creatives", "Directory transcoded renditions are written to")
+	transcodeWorkers = flag.Int("transcode-workers", 2, "Creatives transcoded at once")
+
+	eventsDB       = flag.String("events-db", "", "Badger directory analytics events are persisted to (kept in memory when empty)")
+	eventRetention = flag.Duration("event-retention", 0, "How long raw analytics events are kept (0 keeps them)")
+	piiRetention   = flag.Duration("pii-retention", 0, "How long user IDs and device identifiers in events are kept before they're hashed or dropped (0 keeps them)")
+	piiSaltFile    = flag.String("pii-salt-file", "", "File holding the secret user IDs are hashed with (a per-process salt when empty)")
+
+	forecastToken = flag.String("forecast-token", "", "Bearer token for the inventory forecast API (disabled when empty)")
+
+	apiKeysFile   = flag.String("api-keys", "", "File hashed API keys are kept in; campaign, creative, wallet, report and bid routes need a key scoped for them when set, and user erasure is only served with keys")
+	apiAdminToken = flag.String("api-admin-token", "", "Bearer token that manages every account's API keys (keys only manage their own account's when empty)")
 )
 
 func main() {
 	flag.Parse()
 
+	// Checked first, since --env also picks tracing and gin modes
+	corsCfg, err := corsFromFlags(*env)
+	if err != nil {
+		log.Fatalf("Invalid CORS configuration: %v", err)
+	}
+
+	// Structured request logs, tagged with X-ADX-Request-ID by reqlog
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint:    *otlp,
+		Insecure:    *env != "production",
+		ServiceName: "adx-api",
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize RTB exchange wrapper
 	exchange := &RTBExchangeWrapper{
 		rtbExchange: &rtb.RTBExchange{
 			AuctionTimeout: 100 * time.Millisecond,
 			FloorPrice:     decimal.NewFromFloat(0.01),
+			FloorRules:     rtb.NewFloorRules(0.01, 1000),
 			DSPs:           make(map[string]*rtb.DSPConnection),
 			SSPs:           make(map[string]*rtb.SSPConnection),
 			Revenue:        big.NewInt(0),
@@ -43,17 +116,120 @@ func main() {
 	// Initialize mock DSPs for testing
 	initMockDSPs(exchange.rtbExchange)
 
+	if *sellers != "" {
+		sc, err := loadSupplyChain(*sellers, *asi)
+		if err != nil {
+			log.Fatalf("Failed to load sellers.json: %v", err)
+		}
+		sc.SChainPolicy = rtb.SChainPolicy{RequireComplete: *schainComplete, RejectUnknown: *schainUnknown}
+		exchange.rtbExchange.SupplyChain = sc
+	}
+
+	tracker := analytics.NewAnalyticsTracker()
+	if *eventsDB != "" {
+		db, err := storage.NewStorage("badger", *eventsDB)
+		if err != nil {
+			log.Fatalf("Failed to open events database: %v", err)
+		}
+		defer db.Close()
+		tracker = analytics.NewAnalyticsTrackerWithStorage(analytics.NewDatabaseStorage(db.GetDatabase()))
+	}
+	salt, err := loadPIISalt(*piiSaltFile)
+	if err != nil {
+		log.Fatalf("Failed to load PII salt: %v", err)
+	}
+	retention, err := tracker.EnableRetention(analytics.RetentionPolicy{Events: *eventRetention, PII: *piiRetention, Salt: salt})
+	if err != nil {
+		log.Fatalf("Failed to enable retention: %v", err)
+	}
+	sweepCtx, stopSweeps := context.WithCancel(context.Background())
+	defer stopSweeps()
+	if *eventRetention > 0 || *piiRetention > 0 {
+		go retention.Run(sweepCtx, time.Hour)
+	}
+	go tracker.Video.Run(sweepCtx, analytics.DefaultVideoImpressionTTL)
+	exchange.rtbExchange.FloorRules.Source = tracker
+	exchange.rtbExchange.Losses = tracker
+
 	// Create VAST handler
+	povVerifier := vast.NewPoVVerifier(10 * time.Minute)
+	if err := loadPoVKeys(povVerifier, *povKeys); err != nil {
+		log.Fatalf("Failed to load proof-of-view keys: %v", err)
+	}
+	blockchain := &MockBlockchain{povVerifier: povVerifier}
+	// Reward tokens are only redeemable on this instance, which holds the
+	// pending views, so a per-process key is enough
+	rewardKey := make([]byte, 32)
+	if _, err := rand.Read(rewardKey); err != nil {
+		log.Fatalf("Failed to generate reward key: %v", err)
+	}
 	vastHandler := &vast.VASTHandler{
 		Exchange:      exchange,
 		Storage:       &MockStorage{},
-		Analytics:     &MockAnalytics{},
+		Analytics:     &trackerAnalytics{tracker: tracker},
 		PrivacyMgr:    &MockPrivacy{},
-		BlockchainMgr: &MockBlockchain{},
+		BlockchainMgr: blockchain,
+		Floors:        exchange.rtbExchange.FloorRules,
+		Rewards:       vast.NewRewardManager(rewardKey, blockchain, *rewardAmount, time.Hour),
+		VendorID:      *tcfVendorID,
+	}
+	if *tcfVendorID == 0 {
+		log.Printf("No -tcf-vendor-id set; GDPR requests get no ad")
+	}
+
+	// Request volume seen by VAST auctions feeds inventory forecasts
+	forecaster := chainvm.NewVolumeForecaster()
+	vastHandler.Auctions = forecaster
+
+	if *vastCacheTTL > 0 {
+		vastHandler.Cache = vast.NewResponseCache(*vastCacheTTL, 0)
+	}
+
+	if *receiptKey != "" {
+		key, err := loadReceiptKey(*receiptKey)
+		if err != nil {
+			log.Fatalf("Failed to load receipt key: %v", err)
+		}
+		vastHandler.Receipts = vast.NewReceiptSigner(key)
+	}
+
+	// Install postbacks are always taken; ads are only signed with a key
+	skadn, err := loadSKAdN(*skadnKey, *skadnNetworks)
+	if err != nil {
+		log.Fatalf("Failed to load SKAdNetwork config: %v", err)
+	}
+	vastHandler.SKAdN = skadn
+
+	// Load GeoIP table if configured
+	if *geoCSV != "" {
+		resolver, err := loadGeoResolver(*geoCSV)
+		if err != nil {
+			log.Fatalf("Failed to load GeoIP data: %v", err)
+		}
+		vastHandler.GeoResolver = vast.NewCachingGeoResolver(resolver, 100000)
+	}
+
+	var keys *apikey.Service
+	if *apiKeysFile != "" {
+		keys, err = apikey.NewService(apikey.File{Path: *apiKeysFile})
+		if err != nil {
+			log.Fatalf("Failed to load API keys: %v", err)
+		}
+		bootstrap := *apiKeysFile + ".bootstrap"
+		issued, err := bootstrapKey(keys, *apiAdminToken, bootstrap)
+		if err != nil {
+			log.Fatalf("Failed to issue bootstrap API key: %v", err)
+		}
+		if issued {
+			log.Printf("No API keys or admin token; wrote a key with every scope to %s", bootstrap)
+		}
 	}
 
 	// Setup Gin router
-	router := setupRouter(vastHandler, exchange)
+	router := setupRouter(vastHandler, exchange, tracker, corsCfg, keys)
+	if *forecastToken != "" {
+		router.POST("/api/v1/forecast", gin.WrapH(&chainvm.ForecastHandler{Forecaster: forecaster, Token: *forecastToken}))
+	}
 
 	// Start server
 	srv := &http.Server{
@@ -89,27 +265,199 @@ func main() {
 	log.Println("Server exiting")
 }
 
-func setupRouter(vastHandler *vast.VASTHandler, exchange *RTBExchangeWrapper) *gin.Engine {
+// loadSupplyChain reads the sellers we publish from a sellers.json file
+func loadSupplyChain(path, asi string) (*rtb.SupplyChain, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var doc rtb.SellersJSON
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return nil, err
+	}
+	sc := rtb.NewSupplyChain(asi)
+	sc.ContactEmail, sc.ContactAddress, sc.Identifiers = doc.ContactEmail, doc.ContactAddress, doc.Identifiers
+	for _, s := range doc.Sellers {
+		sc.AddSeller(s)
+	}
+	sc.AdsTxt = rtb.NewAdsTxtValidator(rtb.DefaultAdsTxtTTL)
+	sc.Sellers = rtb.NewSellersJSONCache(rtb.DefaultAdsTxtTTL)
+	return sc, nil
+}
+
+// loadReceiptKey reads a hex ed25519 seed
+func loadReceiptKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("seed is %d bytes, want %d", len(seed), ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// loadSKAdN creates the SKAdNetwork manager, signing with the PEM EC key
+// at keyPath and registering the seats in the JSON map at networksPath
+func loadSKAdN(keyPath, networksPath string) (*vast.SKAdNetworkManager, error) {
+	if keyPath == "" {
+		if networksPath != "" {
+			return nil, errors.New("-skadn-networks needs -skadn-key to sign with")
+		}
+		return vast.NewSKAdNetworkManager(nil), nil
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", keyPath)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	m := vast.NewSKAdNetworkManager(key)
+	if networksPath == "" {
+		return m, nil
+	}
+	data, err = os.ReadFile(networksPath)
+	if err != nil {
+		return nil, err
+	}
+	var seats map[string][]string
+	if err := json.Unmarshal(data, &seats); err != nil {
+		return nil, fmt.Errorf("%s: %w", networksPath, err)
+	}
+	for seat, ids := range seats {
+		m.RegisterSeat(seat, ids)
+	}
+	return m, nil
+}
+
+// loadPoVKeys trusts the player attestation keys in path, a JSON object
+// of key IDs to hex ed25519 public keys
+func loadPoVKeys(v *vast.PoVVerifier, path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var keys map[string]string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for id, h := range keys {
+		pub, err := hex.DecodeString(h)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("%s: key %s is not a hex ed25519 public key", path, id)
+		}
+		v.RegisterKey(id, ed25519.PublicKey(pub))
+	}
+	return nil
+}
+
+// loadPIISalt reads the secret user IDs are hashed with. Without one a
+// random salt is used, so hashes don't match across restarts.
+func loadPIISalt(path string) ([]byte, error) {
+	if path == "" {
+		salt := make([]byte, 32)
+		_, err := rand.Read(salt)
+		return salt, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	salt := []byte(strings.TrimSpace(string(data)))
+	if len(salt) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return salt, nil
+}
+
+func loadGeoResolver(path string) (*vast.CIDRGeoResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	resolver := vast.NewCIDRGeoResolver()
+	if err := resolver.LoadCSV(f); err != nil {
+		return nil, err
+	}
+	return resolver, nil
+}
+
+// setupRouter builds the API's routes. With keys, campaign, creative,
+// wallet, report and bid routes need an API key holding their scope, keys are
+// managed under /api/v1/keys and privacy-admin keys may erase users.
+func setupRouter(vastHandler *vast.VASTHandler, exchange *RTBExchangeWrapper, tracker *analytics.AnalyticsTracker, corsCfg cors.Config, keys *apikey.Service) *gin.Engine {
 	if *env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	creatives := newCreativeUploader(
+		creative.NewValidator(creative.DefaultPolicy(), &creative.FFProbe{}),
+		nil,
+		os.TempDir(),
+	)
+	transcodeConfig := creative.DefaultTranscodeConfig
+	transcodeConfig.Workers = *transcodeWorkers
+	transcodeConfig.OutputDir = *transcodeDir
+	transcodeConfig.BaseURL = *cdnURL + "/creatives"
+	transcodes := creative.NewTranscodeQueue(transcodeConfig, &creative.FFmpeg{Binary: *ffmpeg}, &creative.FFProbe{}, creatives)
+	creatives.queue, creatives.jobs = transcodes, transcodes
+	reports := &reportHandler{tracker: tracker}
+	privacy := &privacyHandler{stores: []userStore{tracker}}
+	if store, ok := exchange.rtbExchange.Identity.(userStore); ok {
+		privacy.stores = append(privacy.stores, store)
+	}
+	if store, ok := vastHandler.Storage.(userStore); ok {
+		privacy.stores = append(privacy.stores, store)
+	}
+	events := &eventHandler{tracker: tracker, rewards: vastHandler.Rewards, views: vastHandler}
+	screen := newFraudScreen()
+
 	router := gin.Default()
 
-	// CORS configuration
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:3001", "https://lux.network", "https://app.lux.network"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
-	router.Use(cors.New(config))
-
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status": "healthy",
-			"time":   time.Now().Unix(),
-		})
-	})
+	scope := func(s apikey.Scope) gin.HandlerFunc {
+		if keys == nil {
+			return func(c *gin.Context) { c.Next() }
+		}
+		return keys.Require(s)
+	}
+
+	router.Use(cors.New(corsCfg))
+	router.Use(reqgin.Middleware())
+
+	// Liveness and readiness; /health is kept for older probes
+	ready := health.New()
+	ready.Add("dsps", exchange.rtbExchange.Ping)
+	if storage, ok := vastHandler.Storage.(health.Pinger); ok {
+		ready.Add("storage", storage.Ping)
+	}
+	router.GET("/livez", gin.WrapF(ready.Livez))
+	router.GET("/readyz", gin.WrapF(ready.Readyz))
+	router.GET("/health", gin.WrapF(ready.Livez))
+
+	// VAST tracking beacons
+	router.GET("/v1/event", events.track)
+
+	// Supply-chain transparency
+	if sc := exchange.rtbExchange.SupplyChain; sc != nil {
+		router.GET("/sellers.json", gin.WrapH(sc))
+	}
 
 	// API routes
 	api := router.Group("/api/v1")
@@ -118,32 +466,55 @@ func setupRouter(vastHandler *vast.VASTHandler, exchange *RTBExchangeWrapper) *g
 		api.GET("/vast", vastHandler.HandleVASTRequest)
 		api.POST("/vast", vastHandler.HandleVASTRequest)
 
+		// SKAdNetwork install postbacks
+		api.POST("/skadn/postback", vastHandler.HandleSKAdNPostback)
+
+		// Key publishers verify served-ad receipts against
+		api.GET("/receipts/key", vastHandler.HandleReceiptKey)
+
 		// Campaign management
-		api.POST("/campaigns", createCampaign)
-		api.GET("/campaigns", listCampaigns)
-		api.GET("/campaigns/:id", getCampaign)
-		api.PUT("/campaigns/:id", updateCampaign)
-		api.DELETE("/campaigns/:id", deleteCampaign)
+		api.POST("/campaigns", scope(apikey.ScopeCampaignWrite), createCampaign)
+		api.GET("/campaigns", scope(apikey.ScopeCampaignWrite), listCampaigns)
+		api.GET("/campaigns/:id", scope(apikey.ScopeCampaignWrite), getCampaign)
+		api.PUT("/campaigns/:id", scope(apikey.ScopeCampaignWrite), updateCampaign)
+		api.DELETE("/campaigns/:id", scope(apikey.ScopeCampaignWrite), deleteCampaign)
 
 		// Creative management
-		api.POST("/creatives", uploadCreative)
-		api.GET("/creatives", listCreatives)
-		api.GET("/creatives/:id", getCreative)
+		api.POST("/creatives", scope(apikey.ScopeCampaignWrite), creatives.uploadCreative)
+		api.GET("/creatives", scope(apikey.ScopeCampaignWrite), listCreatives)
+		api.GET("/creatives/:id", scope(apikey.ScopeCampaignWrite), getCreative)
+		api.GET("/transcodes/:id", scope(apikey.ScopeCampaignWrite), creatives.transcodeStatus)
 
 		// Reporting
-		api.GET("/reports/impressions", getImpressionReport)
-		api.GET("/reports/revenue", getRevenueReport)
-		api.GET("/reports/performance", getPerformanceReport)
+		api.GET("/reports/impressions", scope(apikey.ScopeReportRead), reports.getImpressionReport)
+		api.GET("/reports/revenue", scope(apikey.ScopeReportRead), reports.getRevenueReport)
+		api.GET("/reports/performance", scope(apikey.ScopeReportRead), reports.getPerformanceReport)
+		api.GET("/reports/losses", scope(apikey.ScopeReportRead), reports.getLossReport)
+		api.GET("/reports/export", scope(apikey.ScopeReportRead), reports.getExport)
 
 		// Wallet integration
-		api.POST("/wallet/connect", connectWallet)
-		api.POST("/wallet/deposit", depositFunds)
-		api.POST("/wallet/withdraw", withdrawFunds)
-		api.GET("/wallet/balance", getWalletBalance)
+		api.POST("/wallet/connect", scope(apikey.ScopeCampaignWrite), connectWallet)
+		api.POST("/wallet/deposit", scope(apikey.ScopeCampaignWrite), depositFunds)
+		api.POST("/wallet/withdraw", scope(apikey.ScopeCampaignWrite), withdrawFunds)
+		api.GET("/wallet/balance", scope(apikey.ScopeCampaignWrite), getWalletBalance)
 
 		// RTB endpoints
-		api.POST("/rtb/bid", handleBidRequest)
-		api.GET("/rtb/stats", getRTBStats(exchange))
+		api.POST("/rtb/bid", scope(apikey.ScopeBidSubmit), screen.bids, handleBidRequest)
+		api.POST("/prebid/bid", scope(apikey.ScopeBidSubmit), screen.bids, gin.WrapH(rtb.NewPrebidHandler(exchange.rtbExchange)))
+		api.GET("/rtb/stats", scope(apikey.ScopeReportRead), getRTBStats(exchange))
+
+		// Self-serve API keys
+		if keys != nil {
+			k := &keyHandler{keys: keys, adminToken: *apiAdminToken}
+			api.POST("/keys", k.issue)
+			api.GET("/keys", k.list)
+			api.POST("/keys/:id/rotate", k.rotate)
+			api.DELETE("/keys/:id", k.revoke)
+
+			// Privacy; erasing any user's data needs an admin key, so
+			// there's no erasure route without keys
+			api.DELETE("/privacy/users/:id", keys.Require(apikey.ScopePrivacyAdmin), privacy.eraseUser)
+		}
 	}
 
 	// Static files for creatives
@@ -264,27 +635,140 @@ func deleteCampaign(c *gin.Context) {
 }
 
 // Creative handlers
-func uploadCreative(c *gin.Context) {
+
+// creativeUploader validates uploads, dedupes them by content hash and queues
+// them for transcoding
+type creativeUploader struct {
+	validator *creative.Validator
+	queue     creative.TranscodeEnqueuer
+	jobs      creative.TranscodeTracker // nil when job status isn't tracked
+	dir       string
+
+	mu     sync.Mutex
+	byHash map[string]gin.H
+	byID   map[string]gin.H
+}
+
+func newCreativeUploader(validator *creative.Validator, queue creative.TranscodeEnqueuer, dir string) *creativeUploader {
+	return &creativeUploader{
+		validator: validator,
+		queue:     queue,
+		dir:       dir,
+		byHash:    make(map[string]gin.H),
+		byID:      make(map[string]gin.H),
+	}
+}
+
+func (u *creativeUploader) uploadCreative(c *gin.Context) {
 	file, err := c.FormFile("file")
 	if err != nil {
 		c.JSON(400, gin.H{"error": "No file uploaded"})
 		return
 	}
 
-	// Save file (in production, upload to CDN)
-	filename := fmt.Sprintf("creative_%d_%s", time.Now().Unix(), file.Filename)
+	// Reject on the declared size before writing anything to disk
+	if err := u.validator.CheckSize(file.Size); err != nil {
+		c.JSON(creativeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	creative := gin.H{
-		"id":         fmt.Sprintf("cre_%d", time.Now().Unix()),
+	id := fmt.Sprintf("cre_%d", time.Now().UnixNano())
+	filename := fmt.Sprintf("creative_%d_%s", time.Now().Unix(), filepath.Base(file.Filename))
+	path := filepath.Join(u.dir, id+filepath.Ext(file.Filename))
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to store upload"})
+		return
+	}
+
+	meta, err := u.validator.Validate(c.Request.Context(), path)
+	if err != nil {
+		os.Remove(path)
+		c.JSON(creativeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	u.mu.Lock()
+	existing, dup := u.byHash[meta.ContentHash]
+	if dup {
+		// Held while encoding; the record changes when transcoding finishes
+		c.JSON(200, existing)
+	}
+	u.mu.Unlock()
+	if dup {
+		os.Remove(path)
+		return
+	}
+
+	job := creative.NewTranscodeJob(id, path, meta)
+	result := gin.H{
+		"id":         id,
 		"filename":   filename,
 		"url":        fmt.Sprintf("%s/creatives/%s", *cdnURL, filename),
 		"type":       c.PostForm("type"),
-		"duration":   c.PostForm("duration"),
-		"size":       file.Size,
+		"size":       meta.Size,
+		"metadata":   meta,
+		"status":     "transcoding",
+		"job_id":     job.ID,
 		"created_at": time.Now(),
 	}
 
-	c.JSON(201, creative)
+	// Recorded before enqueueing so a fast transcode finds the record
+	u.mu.Lock()
+	u.byHash[meta.ContentHash] = result
+	u.byID[id] = result
+	u.mu.Unlock()
+
+	if err := u.queue.Enqueue(job); err != nil {
+		u.mu.Lock()
+		delete(u.byHash, meta.ContentHash)
+		delete(u.byID, id)
+		u.mu.Unlock()
+		c.JSON(503, gin.H{"error": "Transcoding unavailable"})
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	c.JSON(201, result)
+}
+
+// SetMediaFiles implements creative.CreativeStore, marking the creative
+// ready with its renditions
+func (u *creativeUploader) SetMediaFiles(creativeID string, files []creative.MediaFile) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	result, ok := u.byID[creativeID]
+	if !ok {
+		return fmt.Errorf("creative %s not found", creativeID)
+	}
+	result["media_files"] = files
+	result["status"] = "ready"
+	return nil
+}
+
+// transcodeStatus reports a transcode job's progress
+func (u *creativeUploader) transcodeStatus(c *gin.Context) {
+	if u.jobs == nil {
+		c.JSON(404, gin.H{"error": "Transcode jobs are not tracked"})
+		return
+	}
+	status, ok := u.jobs.Status(c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "Transcode job not found"})
+		return
+	}
+	c.JSON(200, status)
+}
+
+func creativeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, creative.ErrTooLarge):
+		return 413
+	case errors.Is(err, creative.ErrUnsupportedType):
+		return 415
+	default:
+		return 422
+	}
 }
 
 func listCreatives(c *gin.Context) {
@@ -328,77 +812,6 @@ func getCreative(c *gin.Context) {
 	c.JSON(200, creative)
 }
 
-// Reporting handlers
-func getImpressionReport(c *gin.Context) {
-	report := gin.H{
-		"period": gin.H{
-			"start": c.Query("start_date"),
-			"end":   c.Query("end_date"),
-		},
-		"data": []gin.H{
-			{"date": "2024-08-25", "impressions": 125000, "clicks": 1250, "ctr": 0.01},
-			{"date": "2024-08-26", "impressions": 135000, "clicks": 1485, "ctr": 0.011},
-			{"date": "2024-08-27", "impressions": 142000, "clicks": 1562, "ctr": 0.011},
-		},
-		"totals": gin.H{
-			"impressions": 402000,
-			"clicks":      4297,
-			"ctr":         0.0107,
-		},
-	}
-
-	c.JSON(200, report)
-}
-
-func getRevenueReport(c *gin.Context) {
-	report := gin.H{
-		"period": gin.H{
-			"start": c.Query("start_date"),
-			"end":   c.Query("end_date"),
-		},
-		"data": []gin.H{
-			{"date": "2024-08-25", "revenue": 250.00, "cost": 200.00, "profit": 50.00},
-			{"date": "2024-08-26", "revenue": 270.00, "cost": 216.00, "profit": 54.00},
-			{"date": "2024-08-27", "revenue": 284.00, "cost": 227.20, "profit": 56.80},
-		},
-		"totals": gin.H{
-			"revenue": 804.00,
-			"cost":    643.20,
-			"profit":  160.80,
-		},
-	}
-
-	c.JSON(200, report)
-}
-
-func getPerformanceReport(c *gin.Context) {
-	report := gin.H{
-		"campaigns": []gin.H{
-			{
-				"id":          "camp_1",
-				"name":        "Holiday Campaign",
-				"impressions": 250000,
-				"clicks":      2500,
-				"ctr":         0.01,
-				"cpm":         2.00,
-				"spend":       500.00,
-			},
-		},
-		"creatives": []gin.H{
-			{
-				"id":          "cre_1",
-				"name":        "Holiday Video",
-				"impressions": 150000,
-				"clicks":      1800,
-				"ctr":         0.012,
-				"vcr":         0.85, // Video completion rate
-			},
-		},
-	}
-
-	c.JSON(200, report)
-}
-
 // Wallet handlers
 func connectWallet(c *gin.Context) {
 	var req struct {
@@ -494,14 +907,26 @@ func handleBidRequest(c *gin.Context) {
 
 func getRTBStats(exchange *RTBExchangeWrapper) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.JSON(200, gin.H{
+		stats := gin.H{
 			"impressions": exchange.rtbExchange.ImpressionCount,
 			"bids":        exchange.rtbExchange.BidCount,
 			"wins":        exchange.rtbExchange.WinCount,
 			"revenue":     exchange.rtbExchange.Revenue.String(),
 			"dsps":        len(exchange.rtbExchange.DSPs),
 			"ssps":        len(exchange.rtbExchange.SSPs),
-		})
+			"panics":      atomic.LoadUint64(&exchange.rtbExchange.PanicCount),
+		}
+		if spo := exchange.rtbExchange.SPO; spo != nil {
+			stats["spo_dedup_hits"] = atomic.LoadUint64(&spo.Hits)
+		}
+		if fb := exchange.rtbExchange.Feedback; fb != nil {
+			stats["feedback_delivered"] = atomic.LoadUint64(&fb.Delivered)
+			stats["feedback_failed"] = atomic.LoadUint64(&fb.Failed)
+		}
+		if fanout := exchange.rtbExchange.Fanout; fanout != nil {
+			stats["fanout"] = fanout.Stats()
+		}
+		c.JSON(200, stats)
 	}
 }
 
@@ -516,12 +941,8 @@ func (m *MockStorage) GetImpression(id string) (*vast.ImpressionRecord, error) {
 	return &vast.ImpressionRecord{}, nil
 }
 
-type MockAnalytics struct{}
-
-func (m *MockAnalytics) TrackImpression(imp *vast.ImpressionRecord) {}
-func (m *MockAnalytics) TrackClick(clickID, impID string)           {}
-func (m *MockAnalytics) GetMetrics(start, end time.Time) map[string]interface{} {
-	return map[string]interface{}{}
+func (m *MockStorage) Ping(ctx context.Context) error {
+	return nil
 }
 
 type MockPrivacy struct{}
@@ -533,13 +954,18 @@ func (m *MockPrivacy) AnonymizeData(data interface{}) interface{} {
 	return data
 }
 
-type MockBlockchain struct{}
+type MockBlockchain struct {
+	povVerifier *vast.PoVVerifier
+}
 
 func (m *MockBlockchain) RecordImpression(imp *vast.ImpressionRecord, wallet string, chainID int) error {
 	return nil
 }
-func (m *MockBlockchain) VerifyProofOfView(pov string) bool {
-	return true
+func (m *MockBlockchain) VerifyProofOfView(pov, impressionID, sessionID string) bool {
+	if m.povVerifier == nil {
+		return false
+	}
+	return m.povVerifier.VerifyProofOfView(pov, impressionID, sessionID)
 }
 func (m *MockBlockchain) ProcessPayment(wallet string, amount float64, chainID int) error {
 	return nil
@@ -552,8 +978,8 @@ type RTBExchangeWrapper struct {
 
 // RunAuction implements vast.RTBExchange interface
 func (w *RTBExchangeWrapper) RunAuction(ctx context.Context, req *vast.OpenRTBRequest) (*vast.OpenRTBResponse, error) {
-	// Simple mock auction
-	return &vast.OpenRTBResponse{
+	// Simple mock auction, billed as any serve
+	resp := &vast.OpenRTBResponse{
 		ID: req.ID,
 		SeatBid: []vast.SeatBid{
 			{
@@ -571,7 +997,28 @@ func (w *RTBExchangeWrapper) RunAuction(ctx context.Context, req *vast.OpenRTBRe
 				},
 			},
 		},
-	}, nil
+	}
+	return resp, w.BillServe(ctx, req, resp)
+}
+
+// BillServe implements vast.ServeBiller, charging the exchange for each
+// ad of a serve
+func (w *RTBExchangeWrapper) BillServe(ctx context.Context, req *vast.OpenRTBRequest, resp *vast.OpenRTBResponse) error {
+	for _, sb := range resp.SeatBid {
+		for _, b := range sb.Bid {
+			w.rtbExchange.RecordServe(req.ID, &rtb.Bid{
+				ID:         b.ID,
+				ImpID:      b.ImpID,
+				Price:      b.Price,
+				AdID:       b.AdID,
+				CreativeID: b.CrID,
+				CampaignID: b.CID,
+				SeatID:     sb.Seat,
+				NURL:       b.NURL,
+			})
+		}
+	}
+	return nil
 }
 
 func initMockDSPs(exchange *rtb.RTBExchange) {
@@ -601,5 +1048,5 @@ func initMockDSPs(exchange *rtb.RTBExchange) {
 
 // NewRateLimiter creates a new rate limiter
 func NewRateLimiter(qps int) *rtb.RateLimiter {
-	return &rtb.RateLimiter{}
+	return rtb.NewRateLimiter(qps)
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+)
+
+// userStore is a store holding data keyed by user or device ID
+type userStore interface {
+	Erase(id string) (int, error)
+}
+
+// privacyHandler serves data subject requests against every store keyed by
+// user: analytics events, the identity graph and stored impressions
+type privacyHandler struct {
+	stores []userStore
+}
+
+// eraseUser deletes everything stored under a user or device ID, for a
+// right-to-erasure request. Erasure is idempotent, so a request failing
+// part way is retried whole.
+func (h *privacyHandler) eraseUser(c *gin.Context) {
+	n := 0
+	for _, store := range h.stores {
+		erased, err := store.Erase(c.Param("id"))
+		switch {
+		case errors.Is(err, analytics.ErrRetentionUnsupported):
+			c.JSON(501, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		n += erased
+	}
+	c.JSON(200, gin.H{"erased": n})
+}
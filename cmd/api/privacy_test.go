@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/luxfi/adx/pkg/apikey"
+	"github.com/luxfi/adx/pkg/rtb"
+)
+
+func TestEraseUser_AdminOnlyEveryStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker := analytics.NewAnalyticsTracker()
+	if _, err := tracker.EnableRetention(analytics.RetentionPolicy{Salt: []byte("salt")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.TrackEvent(&analytics.Event{Type: analytics.EventImpression, UserID: "user-1"}); err != nil {
+		t.Fatal(err)
+	}
+	graph := rtb.NewIdentityGraph()
+	graph.Link("key-1", rtb.UserID{Type: rtb.IDTypeUID, Value: "user-1"}, rtb.UserID{Type: rtb.IDTypeIFA, Value: "ifa-1"})
+
+	keys, err := apikey.NewService(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privacy := &privacyHandler{stores: []userStore{tracker, graph}}
+	r := gin.New()
+	r.DELETE("/privacy/users/:id", keys.Require(apikey.ScopePrivacyAdmin), privacy.eraseUser)
+
+	reader, _, _ := keys.Issue(apikey.Spec{Owner: "pub-1", Scopes: []apikey.Scope{apikey.ScopeReportRead}})
+	admin, _, _ := keys.Issue(apikey.Spec{Owner: "ops", Scopes: []apikey.Scope{apikey.ScopePrivacyAdmin}})
+
+	if w := keyCall(t, r, http.MethodDelete, "/privacy/users/user-1", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated erasure = %d, want 401", w.Code)
+	}
+	if w := keyCall(t, r, http.MethodDelete, "/privacy/users/user-1", reader, ""); w.Code != http.StatusForbidden {
+		t.Errorf("report-read erasure = %d, want 403", w.Code)
+	}
+	w := keyCall(t, r, http.MethodDelete, "/privacy/users/user-1", admin, "")
+	if w.Code != http.StatusOK || w.Body.String() != `{"erased":3}` {
+		t.Errorf("admin erasure = %d %s, want the event and both linked IDs", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/luxfi/adx/pkg/vast"
+	"github.com/shopspring/decimal"
+)
+
+// defaultReportDays is the range used when start_date is omitted
+const defaultReportDays = 7
+
+// reportHandler serves reports aggregated from tracked events
+type reportHandler struct {
+	tracker *analytics.AnalyticsTracker
+}
+
+// parseReportFilter reads start_date/end_date (YYYY-MM-DD, end inclusive)
+// and the optional campaign_id/publisher_id filters
+func parseReportFilter(c *gin.Context) (analytics.ReportFilter, error) {
+	filter := analytics.ReportFilter{
+		CampaignID:  c.Query("campaign_id"),
+		PublisherID: c.Query("publisher_id"),
+		End:         time.Now().UTC(),
+	}
+
+	if s := c.Query("end_date"); s != "" {
+		end, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return filter, errors.New("invalid end_date, expected YYYY-MM-DD")
+		}
+		filter.End = end
+	}
+	filter.Start = filter.End.AddDate(0, 0, -(defaultReportDays - 1))
+	if s := c.Query("start_date"); s != "" {
+		start, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return filter, errors.New("invalid start_date, expected YYYY-MM-DD")
+		}
+		filter.Start = start
+	}
+	if filter.End.Before(filter.Start) {
+		return filter, analytics.ErrInvalidRange
+	}
+
+	return filter, nil
+}
+
+func (h *reportHandler) report(c *gin.Context) (*analytics.Report, bool) {
+	filter, err := parseReportFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	report, err := h.tracker.Report(filter)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return report, true
+}
+
+func reportPeriod(r *analytics.Report) gin.H {
+	return gin.H{
+		"start": r.Start.Format(time.DateOnly),
+		"end":   r.End.AddDate(0, 0, -1).Format(time.DateOnly),
+	}
+}
+
+func impressionRow(row *analytics.ReportRow) gin.H {
+	return gin.H{
+		"impressions": row.Impressions,
+		"clicks":      row.Clicks,
+		"ctr":         row.CTR,
+		"completions": row.Completions,
+		"vcr":         row.VCR,
+	}
+}
+
+func revenueRow(row *analytics.ReportRow) gin.H {
+	return gin.H{
+		"revenue": row.Revenue.InexactFloat64(),
+		"cost":    row.Cost.InexactFloat64(),
+		"profit":  row.Profit.InexactFloat64(),
+		"cpm":     row.CPM,
+	}
+}
+
+func (h *reportHandler) getImpressionReport(c *gin.Context) {
+	report, ok := h.report(c)
+	if !ok {
+		return
+	}
+
+	data := make([]gin.H, 0, len(report.Days))
+	for _, day := range report.Days {
+		row := impressionRow(day)
+		row["date"] = day.Key
+		data = append(data, row)
+	}
+
+	c.JSON(200, gin.H{
+		"period": reportPeriod(report),
+		"data":   data,
+		"totals": impressionRow(report.Totals),
+	})
+}
+
+func (h *reportHandler) getRevenueReport(c *gin.Context) {
+	report, ok := h.report(c)
+	if !ok {
+		return
+	}
+
+	data := make([]gin.H, 0, len(report.Days))
+	for _, day := range report.Days {
+		row := revenueRow(day)
+		row["date"] = day.Key
+		data = append(data, row)
+	}
+
+	c.JSON(200, gin.H{
+		"period": reportPeriod(report),
+		"data":   data,
+		"totals": revenueRow(report.Totals),
+	})
+}
+
+func (h *reportHandler) getPerformanceReport(c *gin.Context) {
+	filter, err := parseReportFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	campaigns, err := h.tracker.Breakdown(filter, func(e *analytics.Event) string { return e.CampaignID })
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	creatives, err := h.tracker.Breakdown(filter, func(e *analytics.Event) string { return e.CreativeID })
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"campaigns": performanceRows(campaigns, h.tracker.Video.Campaign),
+		"creatives": performanceRows(creatives, h.tracker.Video.Creative),
+	})
+}
+
+// getLossReport counts bids lost over the range by reason, for the DSP
+// in dsp_id or every DSP
+func (h *reportHandler) getLossReport(c *gin.Context) {
+	filter, err := parseReportFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Whole days, end date included, as the other reports count them
+	tr := analytics.TimeRange{
+		Start: filter.Start.UTC().Truncate(24 * time.Hour),
+		End:   filter.End.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1),
+	}
+	breakdown, err := h.tracker.GetLossReasonBreakdown(c.Query("dsp_id"), tr)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, breakdown)
+}
+
+// exportTypes is the Content-Type and file extension of each export format
+var exportTypes = map[analytics.ExportFormat][2]string{
+	analytics.ExportCSV:     {"text/csv", "csv"},
+	analytics.ExportParquet: {"application/vnd.apache.parquet", "parquet"},
+}
+
+// getExport streams the raw events over the range as CSV or Parquet,
+// filtered like the other reports and by event_type. An export cut short
+// by max_rows ends with an X-Export-Cursor trailer, passed back as cursor
+// to fetch the rest.
+func (h *reportHandler) getExport(c *gin.Context) {
+	filter, err := parseReportFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := analytics.ExportRequest{
+		Filter: analytics.QueryFilter{
+			StartTime: filter.Start.UTC().Truncate(24 * time.Hour),
+			EndTime:   filter.End.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1),
+		},
+		Format: analytics.ExportFormat(c.DefaultQuery("format", string(analytics.ExportCSV))),
+		Cursor: c.Query("cursor"),
+	}
+	if filter.PublisherID != "" {
+		req.Filter.PublisherIDs = []string{filter.PublisherID}
+	}
+	if filter.CampaignID != "" {
+		req.Filter.CampaignIDs = []string{filter.CampaignID}
+	}
+	for _, t := range c.QueryArray("event_type") {
+		req.Filter.EventTypes = append(req.Filter.EventTypes, analytics.EventType(t))
+	}
+	if s := c.Query("max_rows"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid max_rows"})
+			return
+		}
+		req.MaxRows = n
+	}
+	typ, ok := exportTypes[req.Format]
+	if !ok {
+		c.JSON(400, gin.H{"error": "format must be csv or parquet"})
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", typ[0])
+	header.Set("Content-Disposition", `attachment; filename="events.`+typ[1]+`"`)
+	header.Set("Trailer", "X-Export-Cursor")
+	res, err := h.tracker.Export(c.Request.Context(), c.Writer, req)
+	if err != nil && !c.Writer.Written() {
+		header.Del("Content-Type")
+		header.Del("Content-Disposition")
+		header.Del("Trailer")
+		status := 500
+		if errors.Is(err, analytics.ErrInvalidCursor) {
+			status = 400
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if res != nil && res.Cursor != "" {
+		header.Set("X-Export-Cursor", res.Cursor)
+	}
+}
+
+// performanceRows prefers quartile beacon stats for VCR when the group has
+// any, since those are de-duped per served impression, and adds their skip
+// rates
+func performanceRows(rows []*analytics.ReportRow, quartiles func(string) analytics.QuartileStats) []gin.H {
+	out := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		r := gin.H{
+			"id":          row.Key,
+			"impressions": row.Impressions,
+			"clicks":      row.Clicks,
+			"ctr":         row.CTR,
+			"cpm":         row.CPM,
+			"spend":       row.Revenue.InexactFloat64(),
+			"vcr":         row.VCR,
+		}
+		if q := quartiles(row.Key); q.Impressions > 0 {
+			r["vcr"] = q.VCR()
+			r["quartiles"] = q.DropOff()
+			r["skip_rate"] = q.SkipRate()
+			r["post_skip_vcr"] = q.PostSkipVCR()
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// trackerAnalytics feeds VAST impressions and clicks into the tracker that
+// backs the reports
+type trackerAnalytics struct {
+	tracker *analytics.AnalyticsTracker
+}
+
+func (t *trackerAnalytics) TrackImpression(imp *vast.ImpressionRecord) {
+	t.tracker.TrackEvent(&analytics.Event{
+		Type:         analytics.EventImpression,
+		Timestamp:    imp.Timestamp,
+		PublisherID:  imp.AppToken,
+		ImpressionID: imp.ID,
+		CampaignID:   imp.CampaignID,
+		CreativeID:   imp.CreativeID,
+		DeviceType:   imp.Device.Type,
+		GeoCountry:   imp.Location.Country,
+		Price:        decimal.NewFromFloat(imp.Revenue),
+	})
+}
+
+func (t *trackerAnalytics) TrackClick(clickID, impID string) {
+	t.tracker.TrackEvent(&analytics.Event{
+		Type:         analytics.EventClick,
+		ImpressionID: impID,
+		Metadata:     map[string]interface{}{"click_id": clickID},
+	})
+}
+
+func (t *trackerAnalytics) GetMetrics(start, end time.Time) map[string]interface{} {
+	report, err := t.tracker.Report(analytics.ReportFilter{Start: start, End: end})
+	if err != nil {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"impressions": report.Totals.Impressions,
+		"clicks":      report.Totals.Clicks,
+		"ctr":         report.Totals.CTR,
+		"revenue":     report.Totals.Revenue.InexactFloat64(),
+		"vcr":         report.Totals.VCR,
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/luxfi/adx/pkg/vast"
+	"github.com/shopspring/decimal"
+)
+
+func seededReports(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	tracker := analytics.NewAnalyticsTracker()
+	day1 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2025, 3, 2, 23, 30, 0, 0, time.UTC)
+
+	events := []*analytics.Event{
+		// camp_a on day 1: 2 impressions at $10/$6 CPM, 1 click, 1 completion
+		{Type: analytics.EventImpression, Timestamp: day1, CampaignID: "camp_a", CreativeID: "cre_1", PublisherID: "pub_1", Price: decimal.NewFromInt(10), Cost: decimal.NewFromInt(6)},
+		{Type: analytics.EventImpression, Timestamp: day1, CampaignID: "camp_a", CreativeID: "cre_1", PublisherID: "pub_1", Price: decimal.NewFromInt(10), Cost: decimal.NewFromInt(6)},
+		{Type: analytics.EventClick, Timestamp: day1, CampaignID: "camp_a", CreativeID: "cre_1", PublisherID: "pub_1"},
+		{Type: analytics.EventComplete, Timestamp: day1, CampaignID: "camp_a", CreativeID: "cre_1", PublisherID: "pub_1"},
+		// camp_b on day 2: 2 impressions at $20/$12 CPM
+		{Type: analytics.EventImpression, Timestamp: day2, CampaignID: "camp_b", CreativeID: "cre_2", PublisherID: "pub_2", Price: decimal.NewFromInt(20), Cost: decimal.NewFromInt(12)},
+		{Type: analytics.EventImpression, Timestamp: day2, CampaignID: "camp_b", CreativeID: "cre_2", PublisherID: "pub_2", Price: decimal.NewFromInt(20), Cost: decimal.NewFromInt(12)},
+		{Type: analytics.EventComplete, Timestamp: day2, CampaignID: "camp_b", CreativeID: "cre_2", PublisherID: "pub_2"},
+		// Outside every queried range
+		{Type: analytics.EventImpression, Timestamp: day1.AddDate(0, -1, 0), CampaignID: "camp_a", Price: decimal.NewFromInt(100)},
+	}
+	for _, e := range events {
+		if err := tracker.TrackEvent(e); err != nil {
+			t.Fatalf("TrackEvent: %v", err)
+		}
+	}
+
+	h := &reportHandler{tracker: tracker}
+	r := gin.New()
+	r.GET("/reports/impressions", h.getImpressionReport)
+	r.GET("/reports/revenue", h.getRevenueReport)
+	r.GET("/reports/performance", h.getPerformanceReport)
+	r.GET("/reports/export", h.getExport)
+	return r
+}
+
+type reportResponse struct {
+	Period map[string]string        `json:"period"`
+	Data   []map[string]interface{} `json:"data"`
+	Totals map[string]float64       `json:"totals"`
+}
+
+func getReport(t *testing.T, r *gin.Engine, url string, wantStatus int) reportResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != wantStatus {
+		t.Fatalf("GET %s = %d, want %d: %s", url, w.Code, wantStatus, w.Body.String())
+	}
+	var resp reportResponse
+	if wantStatus == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp
+}
+
+func TestImpressionReport(t *testing.T) {
+	r := seededReports(t)
+	resp := getReport(t, r, "/reports/impressions?start_date=2025-03-01&end_date=2025-03-03", http.StatusOK)
+
+	if len(resp.Data) != 3 {
+		t.Fatalf("got %d days, want 3", len(resp.Data))
+	}
+	if resp.Data[0]["date"] != "2025-03-01" || resp.Data[0]["impressions"].(float64) != 2 {
+		t.Errorf("day 1 = %v", resp.Data[0])
+	}
+	if resp.Data[2]["impressions"].(float64) != 0 {
+		t.Errorf("day 3 should be empty, got %v", resp.Data[2])
+	}
+	if resp.Totals["impressions"] != 4 || resp.Totals["clicks"] != 1 {
+		t.Errorf("totals = %v", resp.Totals)
+	}
+	if resp.Totals["ctr"] != 0.25 || resp.Totals["vcr"] != 0.5 {
+		t.Errorf("ctr/vcr = %v/%v, want 0.25/0.5", resp.Totals["ctr"], resp.Totals["vcr"])
+	}
+}
+
+func TestRevenueReport(t *testing.T) {
+	r := seededReports(t)
+	resp := getReport(t, r, "/reports/revenue?start_date=2025-03-01&end_date=2025-03-02", http.StatusOK)
+
+	// 2 x $10 CPM + 2 x $20 CPM = $0.06 revenue; costs are 60%
+	if resp.Totals["revenue"] != 0.06 || resp.Totals["cost"] != 0.036 || resp.Totals["profit"] != 0.024 {
+		t.Errorf("totals = %v", resp.Totals)
+	}
+	if resp.Totals["cpm"] != 15 {
+		t.Errorf("cpm = %v, want 15", resp.Totals["cpm"])
+	}
+
+	resp = getReport(t, r, "/reports/revenue?start_date=2025-03-01&end_date=2025-03-02&campaign_id=camp_b", http.StatusOK)
+	if resp.Totals["revenue"] != 0.04 || resp.Data[0]["revenue"].(float64) != 0 {
+		t.Errorf("campaign filter totals = %v, day 1 = %v", resp.Totals, resp.Data[0])
+	}
+
+	resp = getReport(t, r, "/reports/revenue?start_date=2025-03-01&end_date=2025-03-02&publisher_id=pub_1", http.StatusOK)
+	if resp.Totals["revenue"] != 0.02 {
+		t.Errorf("publisher filter totals = %v", resp.Totals)
+	}
+}
+
+func TestReport_EmptyRange(t *testing.T) {
+	r := seededReports(t)
+	resp := getReport(t, r, "/reports/revenue?start_date=2024-01-01&end_date=2024-01-01", http.StatusOK)
+
+	if len(resp.Data) != 1 {
+		t.Fatalf("got %d days, want 1", len(resp.Data))
+	}
+	for k, v := range resp.Totals {
+		if v != 0 {
+			t.Errorf("totals[%s] = %v, want 0", k, v)
+		}
+	}
+}
+
+func TestReport_BadRange(t *testing.T) {
+	r := seededReports(t)
+	getReport(t, r, "/reports/impressions?start_date=2025-03-05&end_date=2025-03-01", http.StatusBadRequest)
+	getReport(t, r, "/reports/impressions?start_date=03/01/2025", http.StatusBadRequest)
+}
+
+func TestPerformanceReport(t *testing.T) {
+	r := seededReports(t)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/performance?start_date=2025-03-01&end_date=2025-03-02", nil))
+
+	var resp struct {
+		Campaigns []map[string]interface{} `json:"campaigns"`
+		Creatives []map[string]interface{} `json:"creatives"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Campaigns) != 2 || len(resp.Creatives) != 2 {
+		t.Fatalf("campaigns=%d creatives=%d, want 2 each", len(resp.Campaigns), len(resp.Creatives))
+	}
+	a := resp.Campaigns[0]
+	if a["id"] != "camp_a" || a["impressions"].(float64) != 2 || a["cpm"].(float64) != 10 || a["vcr"].(float64) != 0.5 {
+		t.Errorf("camp_a = %v", a)
+	}
+}
+
+func TestExportReport(t *testing.T) {
+	r := seededReports(t)
+	export := func(query string) ([][]string, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/export?start_date=2025-03-01&end_date=2025-03-02"+query, nil))
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/csv" {
+			t.Fatalf("status %d, %s: %s", resp.StatusCode, resp.Header.Get("Content-Type"), w.Body)
+		}
+		rows, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows[1:], resp.Trailer.Get("X-Export-Cursor")
+	}
+
+	first, cursor := export("&max_rows=4")
+	if len(first) != 4 || cursor == "" {
+		t.Fatalf("first part: %d rows, cursor %q", len(first), cursor)
+	}
+	rest, cursor := export("&max_rows=4&cursor=" + url.QueryEscape(cursor))
+	if len(rest) != 3 || cursor != "" {
+		t.Errorf("second part: %d rows, cursor %q; want the 3 left", len(rest), cursor)
+	}
+	if clicks, _ := export("&event_type=click"); len(clicks) != 1 {
+		t.Errorf("%d clicks exported", len(clicks))
+	}
+
+	for _, query := range []string{"&format=xlsx", "&cursor=bogus", "&max_rows=-1"} {
+		getReport(t, r, "/reports/export?start_date=2025-03-01"+query, http.StatusBadRequest)
+	}
+}
+
+func TestTrackerAnalytics_Impression(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker := analytics.NewAnalyticsTracker()
+	day := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	a := &trackerAnalytics{tracker: tracker}
+	a.TrackImpression(&vast.ImpressionRecord{ID: "s1:b1", Timestamp: day, AppToken: "pub_1", CampaignID: "camp_a", CreativeID: "cre_1", Revenue: 12})
+	a.TrackImpression(&vast.ImpressionRecord{ID: "s2:b1", Timestamp: day, AppToken: "pub_1", CampaignID: "camp_a", CreativeID: "cre_1", Revenue: 8})
+
+	h := &reportHandler{tracker: tracker}
+	r := gin.New()
+	r.GET("/reports/revenue", h.getRevenueReport)
+	r.GET("/reports/performance", h.getPerformanceReport)
+
+	resp := getReport(t, r, "/reports/revenue?start_date=2025-03-01&end_date=2025-03-01&campaign_id=camp_a", http.StatusOK)
+	if resp.Totals["revenue"] != 0.02 || resp.Totals["cpm"] != 10 {
+		t.Errorf("camp_a totals = %v", resp.Totals)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/performance?start_date=2025-03-01&end_date=2025-03-01", nil))
+	var perf struct {
+		Creatives []map[string]interface{} `json:"creatives"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &perf); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(perf.Creatives) != 1 || perf.Creatives[0]["id"] != "cre_1" || perf.Creatives[0]["impressions"].(float64) != 2 {
+		t.Errorf("creatives = %v", perf.Creatives)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/luxfi/adx/pkg/reqlog"
+	"github.com/luxfi/adx/pkg/reqlog/reqgin"
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/luxfi/adx/pkg/vast"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// exchangeBridge runs the VAST auction through the real RTB exchange, then
+// answers with a canned bid so the VAST layer serves an ad
+type exchangeBridge struct {
+	exchange *rtb.RTBExchange
+}
+
+func (b *exchangeBridge) RunAuction(ctx context.Context, req *vast.OpenRTBRequest) (*vast.OpenRTBResponse, error) {
+	if _, err := b.exchange.BidRequest(ctx, &openrtb2.BidRequest{ID: req.ID, Imp: []openrtb2.Imp{{ID: "1"}}}); err != nil {
+		return nil, err
+	}
+	return &vast.OpenRTBResponse{
+		ID: req.ID,
+		SeatBid: []vast.SeatBid{{
+			Seat: "dsp1",
+			Bid:  []vast.Bid{{ID: "b1", ImpID: "1", Price: 2.5, ADomain: []string{"example.com"}, ADURL: "https://cdn.example/ad.mp4"}},
+		}},
+	}, nil
+}
+
+func TestRequestID_CorrelatesVASTAndRTBLogs(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(prev)
+
+	exchange := &rtb.RTBExchange{
+		AuctionTimeout: 50 * time.Millisecond,
+		DSPs:           make(map[string]*rtb.DSPConnection),
+		Revenue:        big.NewInt(0),
+	}
+
+	h := &vast.VASTHandler{
+		Exchange:      &exchangeBridge{exchange: exchange},
+		Storage:       &MockStorage{},
+		Analytics:     &trackerAnalytics{tracker: analytics.NewAnalyticsTracker()},
+		PrivacyMgr:    &MockPrivacy{},
+		BlockchainMgr: &MockBlockchain{povVerifier: vast.NewPoVVerifier(time.Minute)},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(reqgin.Middleware())
+	r.GET("/api/v1/vast", h.HandleVASTRequest)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/api/v1/vast?apptoken=pub-1&os=ios&osver=17&devicemodel=iPhone&dnt=1&al=l&zoneid=7", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	id := w.Header().Get(reqlog.Header)
+	if id == "" {
+		t.Fatal("missing request ID header")
+	}
+
+	layers := map[string]bool{}
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("bad log line %q: %v", sc.Text(), err)
+		}
+		if line[reqlog.KeyRequestID] != id {
+			t.Errorf("log line %v lacks request ID %s", line, id)
+		}
+		switch line["msg"] {
+		case "vast ad served":
+			layers["vast"] = line[reqlog.KeyPublisher] == "pub-1"
+		case "auction no fill", "auction cleared":
+			layers["rtb"] = line[reqlog.KeyAuction] != nil
+		}
+	}
+	if !layers["vast"] || !layers["rtb"] {
+		t.Errorf("correlated layers = %v, want vast and rtb; logs:\n%s", layers, buf.String())
+	}
+}
@@ -0,0 +1,160 @@
+package analytics
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/luxfi/database"
+)
+
+// eventPrefix keys stored events, followed by their timestamp and a
+// sequence number so keys sort by time
+var eventPrefix = []byte("event/")
+
+// DatabaseStorage persists events to a key-value database, such as the
+// badger database pkg/storage opens, so they outlive the process
+type DatabaseStorage struct {
+	db database.Database
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// NewDatabaseStorage stores events in db
+func NewDatabaseStorage(db database.Database) *DatabaseStorage {
+	return &DatabaseStorage{db: db, seq: uint64(time.Now().UnixNano())}
+}
+
+func eventKey(at time.Time, seq uint64) []byte {
+	key := make([]byte, len(eventPrefix)+16)
+	copy(key, eventPrefix)
+	binary.BigEndian.PutUint64(key[len(eventPrefix):], uint64(max(at.UnixNano(), 0)))
+	binary.BigEndian.PutUint64(key[len(eventPrefix)+8:], seq)
+	return key
+}
+
+// keyTime is the timestamp an event key sorts by
+func keyTime(key []byte) time.Time {
+	if len(key) < len(eventPrefix)+8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[len(eventPrefix):])))
+}
+
+// Store saves an event
+func (s *DatabaseStorage) Store(event *Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.seq++
+	seq := s.seq
+	s.mu.Unlock()
+	return s.db.Put(eventKey(event.Timestamp, seq), value)
+}
+
+// Query retrieves events matching filter, oldest first
+func (s *DatabaseStorage) Query(filter QueryFilter) ([]*Event, error) {
+	results := make([]*Event, 0)
+	skip := filter.Offset
+	err := s.scan(filter.StartTime, filter.EndTime, func(_ []byte, e *Event) error {
+		if !matchesFilter(e, filter) {
+			return nil
+		}
+		if skip > 0 {
+			skip--
+			return nil
+		}
+		if filter.Limit <= 0 || len(results) < filter.Limit {
+			results = append(results, e)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// Aggregate performs aggregation queries
+func (s *DatabaseStorage) Aggregate(metric string, groupBy []string, timeRange TimeRange) (map[string]interface{}, error) {
+	// Simplified aggregation, as InMemoryStorage's
+	return map[string]interface{}{
+		"metric":    metric,
+		"timeRange": timeRange,
+		"result":    0,
+	}, nil
+}
+
+// Purge deletes the events from before cutoff
+func (s *DatabaseStorage) Purge(cutoff time.Time) (int, error) {
+	batch := s.db.NewBatch()
+	n := 0
+	err := s.scan(time.Time{}, cutoff, func(key []byte, _ *Event) error {
+		n++
+		return batch.Delete(key)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, batch.Write()
+}
+
+// Scrub rewrites the events from before cutoff with scrub
+func (s *DatabaseStorage) Scrub(cutoff time.Time, scrub func(*Event) bool) (int, error) {
+	batch := s.db.NewBatch()
+	n := 0
+	err := s.scan(time.Time{}, cutoff, func(key []byte, e *Event) error {
+		if !scrub(e) {
+			return nil
+		}
+		value, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		n++
+		return batch.Put(key, value)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, batch.Write()
+}
+
+// Erase deletes every event match reports true for
+func (s *DatabaseStorage) Erase(match func(*Event) bool) (int, error) {
+	batch := s.db.NewBatch()
+	n := 0
+	err := s.scan(time.Time{}, time.Time{}, func(key []byte, e *Event) error {
+		if !match(e) {
+			return nil
+		}
+		n++
+		return batch.Delete(key)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, batch.Write()
+}
+
+// scan calls fn with each event in [start, end), and its key, oldest
+// first; a zero end scans to the last event
+func (s *DatabaseStorage) scan(start, end time.Time, fn func(key []byte, e *Event) error) error {
+	it := s.db.NewIteratorWithStartAndPrefix(eventKey(start, 0), eventPrefix)
+	defer it.Release()
+	for it.Next() {
+		if !end.IsZero() && !keyTime(it.Key()).Before(end) {
+			break
+		}
+		var e Event
+		if err := json.Unmarshal(it.Value(), &e); err != nil {
+			return err
+		}
+		// Iterator keys are only valid until Next
+		if err := fn(append([]byte(nil), it.Key()...), &e); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
@@ -0,0 +1,160 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DealUnderPaceThreshold is the share of its even-pace volume a deal must
+// have delivered to not be flagged as under-pacing
+const DealUnderPaceThreshold = 0.9
+
+var (
+	// ErrNoDealLedger is returned for deal reports before a ledger is set
+	ErrNoDealLedger = errors.New("no deal ledger")
+
+	// ErrUnknownDeal is returned by a DealLedger for deals it doesn't hold
+	ErrUnknownDeal = errors.New("unknown deal")
+)
+
+// DealTerms are a deal's booked terms and what has been settled against it
+type DealTerms struct {
+	StartTime time.Time
+	EndTime   time.Time
+
+	// TargetImpressions is the guaranteed or target volume over the whole
+	// flight; zero for a deal without one
+	TargetImpressions uint64
+
+	// FloorCPM is the deal floor, or a guaranteed deal's fixed CPM
+	FloorCPM decimal.Decimal
+
+	// SettledSpend is the spend settled on the deal within the report range
+	SettledSpend decimal.Decimal
+}
+
+// DealLedger holds the deals reported on, e.g. PG deals and their escrow
+type DealLedger interface {
+	// Deal returns a deal's terms, with the spend settled in tr
+	Deal(dealID string, tr TimeRange) (DealTerms, error)
+}
+
+// DealReport is a deal's delivery over a time range, reconciled against
+// its settlements
+type DealReport struct {
+	DealID string
+	Start  time.Time
+	End    time.Time
+
+	Bids        uint64
+	BidsAtFloor uint64 // Bids at or above the deal floor
+	Wins        uint64
+	Impressions uint64
+	Spend       decimal.Decimal // Charged for the range's impressions
+	WinRate     float64         // Wins per bid at or above the floor
+	CPM         float64
+
+	Target      uint64  // Volume booked for the flight
+	Expected    uint64  // Volume due by End at an even pace
+	Fill        float64 // Impressions / Target
+	Pace        float64 // Impressions / Expected
+	UnderPacing bool
+
+	SettledSpend decimal.Decimal
+	SpendDelta   decimal.Decimal // Spend - SettledSpend
+	Reconciled   bool
+}
+
+// SetDealLedger sets where deal reports look up deal terms
+func (a *AnalyticsTracker) SetDealLedger(l DealLedger) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.deals = l
+}
+
+// GetDealReport aggregates a deal's bids, wins and impressions in tr,
+// measures delivery against the deal's target, and reconciles the spend
+// reported by events with what was settled
+func (a *AnalyticsTracker) GetDealReport(dealID string, tr TimeRange) (*DealReport, error) {
+	if tr.End.Before(tr.Start) {
+		return nil, ErrInvalidRange
+	}
+	a.mu.RLock()
+	ledger := a.deals
+	a.mu.RUnlock()
+	if ledger == nil {
+		return nil, ErrNoDealLedger
+	}
+	terms, err := ledger.Deal(dealID, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.Query(QueryFilter{
+		StartTime:  tr.Start,
+		EndTime:    tr.End,
+		EventTypes: []EventType{EventBid, EventWin, EventImpression},
+		DealIDs:    []string{dealID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &DealReport{DealID: dealID, Start: tr.Start, End: tr.End, Target: terms.TargetImpressions}
+	for _, e := range events {
+		switch e.Type {
+		case EventBid:
+			r.Bids++
+			if e.Price.GreaterThanOrEqual(terms.FloorCPM) {
+				r.BidsAtFloor++
+			}
+		case EventWin:
+			r.Wins++
+		case EventImpression:
+			r.Impressions++
+			r.Spend = r.Spend.Add(e.Price.Div(thousand))
+		}
+	}
+	if r.BidsAtFloor > 0 {
+		r.WinRate = float64(r.Wins) / float64(r.BidsAtFloor)
+	}
+	if r.Impressions > 0 {
+		r.CPM = r.Spend.Mul(thousand).InexactFloat64() / float64(r.Impressions)
+	}
+
+	if r.Target > 0 {
+		r.Fill = float64(r.Impressions) / float64(r.Target)
+		r.Expected = expectedDelivery(terms, tr)
+		if r.Expected > 0 {
+			r.Pace = float64(r.Impressions) / float64(r.Expected)
+			r.UnderPacing = r.Pace < DealUnderPaceThreshold
+		}
+	}
+
+	r.SettledSpend = terms.SettledSpend
+	r.SpendDelta = r.Spend.Sub(terms.SettledSpend)
+	r.Reconciled = r.SpendDelta.IsZero()
+	return r, nil
+}
+
+// expectedDelivery is the share of a deal's target due in the part of its
+// flight tr covers, delivering evenly over the flight
+func expectedDelivery(terms DealTerms, tr TimeRange) uint64 {
+	flight := terms.EndTime.Sub(terms.StartTime)
+	if flight <= 0 {
+		return terms.TargetImpressions
+	}
+	start, end := tr.Start, tr.End
+	if start.Before(terms.StartTime) {
+		start = terms.StartTime
+	}
+	if end.After(terms.EndTime) {
+		end = terms.EndTime
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return uint64(float64(terms.TargetImpressions) * float64(end.Sub(start)) / float64(flight))
+}
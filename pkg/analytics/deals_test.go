@@ -0,0 +1,113 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// dealBook is a DealLedger over fixed terms
+type dealBook map[string]DealTerms
+
+func (b dealBook) Deal(dealID string, tr TimeRange) (DealTerms, error) {
+	terms, ok := b[dealID]
+	if !ok {
+		return DealTerms{}, ErrUnknownDeal
+	}
+	return terms, nil
+}
+
+// seedDeal tracks n bids at cpm on a deal, winning and serving the first
+// wins of them, spread over the hours after start
+func seedDeal(t *testing.T, a *AnalyticsTracker, dealID string, start time.Time, n, wins int, cpm string) {
+	t.Helper()
+	price := decimal.RequireFromString(cpm)
+	for i := 0; i < n; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		events := []*Event{{Type: EventBid, Timestamp: at, DealID: dealID, Price: price}}
+		if i < wins {
+			events = append(events,
+				&Event{Type: EventWin, Timestamp: at, DealID: dealID, Price: price},
+				&Event{Type: EventImpression, Timestamp: at.Add(time.Second), DealID: dealID, Price: price},
+			)
+		}
+		for _, e := range events {
+			if err := a.TrackEvent(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestDealReport_DetectsUnderDelivery(t *testing.T) {
+	flight := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	a := NewAnalyticsTracker()
+	a.SetDealLedger(dealBook{
+		"pg-1": {
+			StartTime:         flight,
+			EndTime:           flight.AddDate(0, 0, 10),
+			TargetImpressions: 200,
+			FloorCPM:          decimal.NewFromInt(10),
+			SettledSpend:      decimal.RequireFromString("0.96"),
+		},
+	})
+
+	// Half the flight has passed and 80 of the 100 impressions due were
+	// served, at 12 CPM; 20 bids under the floor all lost
+	seedDeal(t, a, "pg-1", flight, 100, 80, "12")
+	seedDeal(t, a, "pg-1", flight.Add(30*time.Minute), 20, 0, "8")
+	// Another deal's and open-auction events don't count
+	seedDeal(t, a, "pg-2", flight, 10, 10, "12")
+	seedDeal(t, a, "", flight, 10, 10, "12")
+
+	r, err := a.GetDealReport("pg-1", TimeRange{Start: flight, End: flight.AddDate(0, 0, 5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Bids != 120 || r.BidsAtFloor != 100 || r.Wins != 80 || r.Impressions != 80 {
+		t.Errorf("bids %d (%d at floor), wins %d, impressions %d", r.Bids, r.BidsAtFloor, r.Wins, r.Impressions)
+	}
+	if r.WinRate != 0.8 || r.CPM != 12 || !r.Spend.Equal(decimal.RequireFromString("0.96")) {
+		t.Errorf("win rate %v, cpm %v, spend %s", r.WinRate, r.CPM, r.Spend)
+	}
+	if r.Target != 200 || r.Expected != 100 || r.Fill != 0.4 || r.Pace != 0.8 {
+		t.Errorf("target %d, expected %d, fill %v, pace %v", r.Target, r.Expected, r.Fill, r.Pace)
+	}
+	if !r.UnderPacing {
+		t.Error("80% of the due volume not flagged as under-pacing")
+	}
+	if !r.Reconciled || !r.SpendDelta.IsZero() {
+		t.Errorf("spend %s vs settled %s not reconciled", r.Spend, r.SettledSpend)
+	}
+
+	// Over the first day only, the deal is ahead of pace
+	r, err = a.GetDealReport("pg-1", TimeRange{Start: flight, End: flight.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Expected != 20 || r.Impressions != 24 || r.UnderPacing {
+		t.Errorf("first day: expected %d, impressions %d, under-pacing %v", r.Expected, r.Impressions, r.UnderPacing)
+	}
+	// ...but its whole settled spend is more than the day's events report
+	if r.Reconciled || !r.SpendDelta.Equal(decimal.RequireFromString("-0.672")) {
+		t.Errorf("spend delta = %s, reconciled %v", r.SpendDelta, r.Reconciled)
+	}
+}
+
+func TestDealReport_Errors(t *testing.T) {
+	a := NewAnalyticsTracker()
+	tr := TimeRange{Start: time.Now().Add(-time.Hour), End: time.Now()}
+	if _, err := a.GetDealReport("pg-1", tr); !errors.Is(err, ErrNoDealLedger) {
+		t.Errorf("without a ledger: err = %v", err)
+	}
+
+	a.SetDealLedger(dealBook{})
+	if _, err := a.GetDealReport("pg-1", tr); !errors.Is(err, ErrUnknownDeal) {
+		t.Errorf("unknown deal: err = %v", err)
+	}
+	if _, err := a.GetDealReport("pg-1", TimeRange{Start: tr.End, End: tr.Start}); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("inverted range: err = %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package analytics
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Export defaults
+const (
+	DefaultExportChunk     = time.Hour
+	DefaultExportPageSize  = 1000
+	DefaultExportGroupRows = 10000
+)
+
+var (
+	// ErrUnsupportedFormat is returned for an export format other than
+	// ExportCSV or ExportParquet
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+	// ErrInvalidCursor is returned for a cursor no export of the range
+	// could have returned
+	ErrInvalidCursor = errors.New("invalid export cursor")
+)
+
+// ExportFormat is the file format events are exported in
+type ExportFormat string
+
+const (
+	ExportCSV     ExportFormat = "csv"
+	ExportParquet ExportFormat = "parquet"
+)
+
+// ExportRequest selects the events to export and how. Filter's StartTime
+// and EndTime bound the export; its Limit and Offset are ignored.
+type ExportRequest struct {
+	Filter   QueryFilter
+	Format   ExportFormat  // ExportCSV when empty
+	Chunk    time.Duration // Time window queried at once; DefaultExportChunk when zero
+	PageSize int           // Events read per query; DefaultExportPageSize when zero
+
+	// GroupRows is how many rows a Parquet row group holds, the most a
+	// Parquet export buffers; DefaultExportGroupRows when zero
+	GroupRows int
+
+	MaxRows uint64 // Rows written before the export stops, unlimited when zero
+	Cursor  string // Where a previous export stopped; the start when empty
+}
+
+// ExportResult is what an export wrote
+type ExportResult struct {
+	Rows   uint64
+	Cursor string // Resumes the export where it stopped; empty once complete
+}
+
+// exportPosition is where in the range an export is: the window being
+// read and how many of its events have been written
+type exportPosition struct {
+	start, end time.Time
+	offset     int
+}
+
+func (p exportPosition) cursor() string {
+	s := fmt.Sprintf("%d.%d.%d", p.start.UnixNano(), p.end.UnixNano(), p.offset)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func (r ExportRequest) withDefaults() ExportRequest {
+	if r.Format == "" {
+		r.Format = ExportCSV
+	}
+	if r.Chunk <= 0 {
+		r.Chunk = DefaultExportChunk
+	}
+	if r.PageSize <= 0 {
+		r.PageSize = DefaultExportPageSize
+	}
+	if r.GroupRows <= 0 {
+		r.GroupRows = DefaultExportGroupRows
+	}
+	return r
+}
+
+// window is the chunk of the range starting at start
+func (r ExportRequest) window(start time.Time) exportPosition {
+	end := start.Add(r.Chunk)
+	if end.After(r.Filter.EndTime) {
+		end = r.Filter.EndTime
+	}
+	return exportPosition{start: start, end: end}
+}
+
+// position is where the export starts: its cursor, or the first chunk
+func (r ExportRequest) position() (exportPosition, error) {
+	if r.Cursor == "" {
+		return r.window(r.Filter.StartTime), nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(r.Cursor)
+	if err != nil {
+		return exportPosition{}, ErrInvalidCursor
+	}
+	var start, end int64
+	var p exportPosition
+	if _, err := fmt.Sscanf(string(raw), "%d.%d.%d", &start, &end, &p.offset); err != nil {
+		return exportPosition{}, ErrInvalidCursor
+	}
+	p.start, p.end = time.Unix(0, start), time.Unix(0, end)
+	if p.offset < 0 || p.start.Before(r.Filter.StartTime) || p.end.After(r.Filter.EndTime) || !p.end.After(p.start) {
+		return exportPosition{}, ErrInvalidCursor
+	}
+	return p, nil
+}
+
+// exportWriter encodes rows in an export's format
+type exportWriter interface {
+	write(e *Event) error
+	close() error
+}
+
+// Export streams the events req selects to w, oldest chunk first, one row
+// per event with a column for each dimension and metric. It reads the
+// range a chunk and page at a time, so however long the range, no more
+// than a page of events (or for Parquet, a row group) is held at once.
+//
+// Every export writes a complete file. One stopped by MaxRows, or by ctx
+// between pages, returns a Cursor that a later export of the same request
+// resumes from, writing the remaining rows as a new file; a stopped
+// context's error is returned alongside it. Events stored into a chunk
+// while it's being exported may be missed or repeated, so ranges are best
+// exported once they've closed.
+func (a *AnalyticsTracker) Export(ctx context.Context, w io.Writer, req ExportRequest) (*ExportResult, error) {
+	req = req.withDefaults()
+	if !req.Filter.EndTime.After(req.Filter.StartTime) {
+		return nil, ErrInvalidRange
+	}
+	pos, err := req.position()
+	if err != nil {
+		return nil, err
+	}
+
+	var out exportWriter
+	switch req.Format {
+	case ExportCSV:
+		out, err = newCSVExport(w)
+	case ExportParquet:
+		out, err = newParquetExport(w, req.GroupRows)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, req.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ExportResult{}
+	for pos.start.Before(req.Filter.EndTime) {
+		if ctx.Err() != nil || (req.MaxRows > 0 && result.Rows >= req.MaxRows) {
+			result.Cursor = pos.cursor()
+			break
+		}
+
+		limit := req.PageSize
+		if req.MaxRows > 0 {
+			limit = int(min(uint64(limit), req.MaxRows-result.Rows))
+		}
+		q := req.Filter
+		q.StartTime, q.EndTime = pos.start, pos.end
+		q.Limit, q.Offset = limit, pos.offset
+		events, err := a.storage.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			if err := out.write(e); err != nil {
+				return nil, err
+			}
+		}
+		result.Rows += uint64(len(events))
+
+		if len(events) < limit {
+			pos = req.window(pos.end)
+		} else {
+			pos.offset += len(events)
+		}
+	}
+
+	if err := out.close(); err != nil {
+		return nil, err
+	}
+	return result, ctx.Err()
+}
+
+// exportColumn is one column of an export: the event's timestamp, a
+// dimension read as text or a metric read as a decimal
+type exportColumn struct {
+	name   string
+	text   func(*Event) string
+	metric func(*Event) decimal.Decimal
+}
+
+// exportTimestamp is the name of the first column, every export's
+// timestamp, which the table below leaves out
+const exportTimestamp = "timestamp"
+
+var exportColumns = []exportColumn{
+	{name: "type", text: func(e *Event) string { return string(e.Type) }},
+	{name: "publisher_id", text: func(e *Event) string { return e.PublisherID }},
+	{name: "placement_id", text: func(e *Event) string { return e.PlacementID }},
+	{name: "campaign_id", text: func(e *Event) string { return e.CampaignID }},
+	{name: "creative_id", text: func(e *Event) string { return e.CreativeID }},
+	{name: "deal_id", text: func(e *Event) string { return e.DealID }},
+	{name: "impression_id", text: func(e *Event) string { return e.ImpressionID }},
+	{name: "dsp_id", text: func(e *Event) string { return e.DSPID }},
+	{name: "miner_id", text: func(e *Event) string { return e.MinerID }},
+	{name: "user_id", text: func(e *Event) string { return e.UserID }},
+	{name: "device_type", text: func(e *Event) string { return e.DeviceType }},
+	{name: "geo_country", text: func(e *Event) string { return e.GeoCountry }},
+	{name: "loss_reason", text: func(e *Event) string { return string(e.LossReason) }},
+	{name: "price_cpm", metric: func(e *Event) decimal.Decimal { return e.Price }},
+	{name: "cost_cpm", metric: func(e *Event) decimal.Decimal { return e.Cost }},
+}
+
+// csvExport writes a header row, then one row per event with timestamps
+// in RFC 3339 and metrics as exact decimals
+type csvExport struct {
+	w   *csv.Writer
+	row []string
+}
+
+func newCSVExport(w io.Writer) (*csvExport, error) {
+	c := &csvExport{w: csv.NewWriter(w), row: make([]string, len(exportColumns)+1)}
+	c.row[0] = exportTimestamp
+	for i, col := range exportColumns {
+		c.row[i+1] = col.name
+	}
+	return c, c.w.Write(c.row)
+}
+
+func (c *csvExport) write(e *Event) error {
+	c.row[0] = e.Timestamp.UTC().Format(time.RFC3339Nano)
+	for i, col := range exportColumns {
+		if col.text != nil {
+			c.row[i+1] = col.text(e)
+		} else {
+			c.row[i+1] = col.metric(e).String()
+		}
+	}
+	return c.w.Write(c.row)
+}
+
+func (c *csvExport) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
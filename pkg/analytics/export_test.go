@@ -0,0 +1,277 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/luxfi/database/memdb"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/shopspring/decimal"
+)
+
+// pagedStorage records the most events any one query returned
+type pagedStorage struct {
+	StorageBackend
+	queries int
+	maxPage int
+}
+
+func (s *pagedStorage) Query(filter QueryFilter) ([]*Event, error) {
+	events, err := s.StorageBackend.Query(filter)
+	s.queries++
+	s.maxPage = max(s.maxPage, len(events))
+	return events, err
+}
+
+// seedExport stores 5 impressions an hour for pub-1 over days, plus
+// events the export filters out, and returns the range
+func seedExport(t *testing.T, storage StorageBackend, days int) QueryFilter {
+	t.Helper()
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, days)
+	store := func(e *Event) {
+		if err := storage.Store(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for at := start; at.Before(end); at = at.Add(time.Hour) {
+		for i := range 5 {
+			store(&Event{Type: EventImpression, Timestamp: at.Add(time.Duration(i) * time.Minute), PublisherID: "pub-1",
+				ImpressionID: fmt.Sprintf("%s-%d", at.Format(time.RFC3339), i), Price: decimal.NewFromFloat(2.5)})
+		}
+		store(&Event{Type: EventImpression, Timestamp: at, PublisherID: "pub-2", ImpressionID: "other"})
+	}
+	store(&Event{Type: EventImpression, Timestamp: end, PublisherID: "pub-1", ImpressionID: "after"})
+	return QueryFilter{StartTime: start, EndTime: end, PublisherIDs: []string{"pub-1"}}
+}
+
+// csvIDs reads back a CSV export's impression IDs
+func csvIDs(t *testing.T, data []byte) []string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 || records[0][0] != exportTimestamp || records[0][7] != "impression_id" {
+		t.Fatalf("header = %v", records)
+	}
+	var ids []string
+	for _, r := range records[1:] {
+		ids = append(ids, r[7])
+	}
+	return ids
+}
+
+func TestExport_CSV(t *testing.T) {
+	for name, newStorage := range map[string]func() StorageBackend{
+		"memory":   func() StorageBackend { return NewInMemoryStorage() },
+		"database": func() StorageBackend { return NewDatabaseStorage(memdb.New()) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			storage := &pagedStorage{StorageBackend: newStorage()}
+			filter := seedExport(t, storage, 3)
+			a := NewAnalyticsTrackerWithStorage(storage)
+
+			var buf bytes.Buffer
+			res, err := a.Export(context.Background(), &buf, ExportRequest{Filter: filter, PageSize: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := csvIDs(t, buf.Bytes())
+			if res.Rows != 72*5 || len(ids) != 72*5 || res.Cursor != "" {
+				t.Fatalf("exported %d rows (%d read back), cursor %q; want %d", res.Rows, len(ids), res.Cursor, 72*5)
+			}
+			seen := make(map[string]bool)
+			for _, id := range ids {
+				if seen[id] || id == "other" || id == "after" {
+					t.Fatalf("row %q exported", id)
+				}
+				seen[id] = true
+			}
+			if storage.maxPage > 2 {
+				t.Errorf("a query returned %d events, over the page size", storage.maxPage)
+			}
+
+			row, _ := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+			if got := row[1]; got[0] != "2026-03-01T00:00:00Z" || got[1] != "impression" || got[14] != "2.5" {
+				t.Errorf("first row = %v", got)
+			}
+		})
+	}
+}
+
+func TestExport_BoundedMemory(t *testing.T) {
+	var pages []int
+	for _, days := range []int{1, 30} {
+		storage := &pagedStorage{StorageBackend: NewInMemoryStorage()}
+		filter := seedExport(t, storage, days)
+		var buf bytes.Buffer
+		res, err := NewAnalyticsTrackerWithStorage(storage).Export(context.Background(), &buf,
+			ExportRequest{Filter: filter, Format: ExportParquet, PageSize: 3, GroupRows: 50})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Rows != uint64(days*24*5) {
+			t.Errorf("%d days: %d rows", days, res.Rows)
+		}
+		for _, g := range parquetGroups(t, buf.Bytes()) {
+			if g > 50 {
+				t.Errorf("%d days: row group of %d rows", days, g)
+			}
+		}
+		pages = append(pages, storage.maxPage)
+	}
+	// A month reads no more at once than a day does
+	if pages[0] != 3 || pages[1] != 3 {
+		t.Errorf("largest pages = %v, want 3 for both ranges", pages)
+	}
+}
+
+func TestExport_Resume(t *testing.T) {
+	storage := NewInMemoryStorage()
+	filter := seedExport(t, storage, 2)
+	a := NewAnalyticsTrackerWithStorage(storage)
+
+	seen := make(map[string]bool)
+	req := ExportRequest{Filter: filter, PageSize: 4, MaxRows: 7}
+	for parts := 1; ; parts++ {
+		var buf bytes.Buffer
+		res, err := a.Export(context.Background(), &buf, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := csvIDs(t, buf.Bytes())
+		if uint64(len(ids)) != res.Rows || res.Rows > 7 {
+			t.Fatalf("part %d: %d rows, %d read back", parts, res.Rows, len(ids))
+		}
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("part %d repeated %s", parts, id)
+			}
+			seen[id] = true
+		}
+		if res.Cursor == "" {
+			break
+		}
+		if parts > 100 {
+			t.Fatal("export never finished")
+		}
+		req.Cursor = res.Cursor
+	}
+	if len(seen) != 48*5 {
+		t.Errorf("resumed export wrote %d rows, want %d", len(seen), 48*5)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := a.Export(ctx, &bytes.Buffer{}, ExportRequest{Filter: filter})
+	if !errors.Is(err, context.Canceled) || res == nil || res.Cursor == "" || res.Rows != 0 {
+		t.Errorf("cancelled export = %+v, %v", res, err)
+	}
+
+	for _, req := range []ExportRequest{
+		{Filter: filter, Cursor: "not a cursor"},
+		{Filter: filter, Cursor: exportPosition{start: filter.StartTime.Add(-time.Hour), end: filter.StartTime}.cursor()},
+	} {
+		if _, err := a.Export(context.Background(), &bytes.Buffer{}, req); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: %v", req.Cursor, err)
+		}
+	}
+	if _, err := a.Export(context.Background(), &bytes.Buffer{}, ExportRequest{Filter: filter, Format: "xlsx"}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("xlsx: %v", err)
+	}
+}
+
+func TestExport_Parquet(t *testing.T) {
+	storage := NewInMemoryStorage()
+	filter := seedExport(t, storage, 2)
+	storage.Store(&Event{Type: EventImpression, Timestamp: filter.StartTime.Add(time.Second), PublisherID: "pub-1",
+		ImpressionID: "precise", Price: decimal.RequireFromString("0.1234565"), Cost: decimal.RequireFromString("1234567.89")})
+	var buf bytes.Buffer
+	res, err := NewAnalyticsTrackerWithStorage(storage).Export(context.Background(), &buf,
+		ExportRequest{Filter: filter, Format: ExportParquet, GroupRows: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := openParquet(t, buf.Bytes())
+
+	if f.NumRows() != int64(res.Rows) || res.Rows != 48*5+1 {
+		t.Fatalf("file holds %d rows, export %d", f.NumRows(), res.Rows)
+	}
+	if n := len(f.RowGroups()); n != 3 {
+		t.Errorf("%d row groups, want 3", n)
+	}
+	schema := f.Schema()
+	if len(schema.Columns()) != len(exportColumns)+1 {
+		t.Fatalf("schema = %v", schema)
+	}
+	for _, name := range []string{"price_cpm", "cost_cpm"} {
+		leaf, _ := schema.Lookup(name)
+		typ := leaf.Node.Type()
+		dec, ok := typ.LogicalType().Value.(*format.DecimalType)
+		if typ.Kind() != parquet.Int64 || !ok || dec.Scale != ExportMoneyScale {
+			t.Errorf("%s type = %v", name, typ)
+		}
+	}
+	if leaf, _ := schema.Lookup(exportTimestamp); !isTimestamp(leaf.Node.Type()) {
+		t.Errorf("timestamp type = %v", leaf.Node.Type())
+	}
+
+	// Read every row back
+	type row struct {
+		Timestamp    time.Time `parquet:"timestamp"`
+		ImpressionID string    `parquet:"impression_id"`
+		PublisherID  string    `parquet:"publisher_id"`
+		Price        int64     `parquet:"price_cpm"`
+		Cost         int64     `parquet:"cost_cpm"`
+	}
+	rows, err := parquet.Read[row](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]row)
+	for _, r := range rows {
+		seen[r.ImpressionID] = r
+	}
+	if len(seen) != 48*5+1 {
+		t.Errorf("read back %d impression IDs, want %d", len(seen), 48*5+1)
+	}
+	first := seen[filter.StartTime.Format(time.RFC3339)+"-0"]
+	if !first.Timestamp.Equal(filter.StartTime) || first.PublisherID != "pub-1" || first.Price != 2_500_000 {
+		t.Errorf("first row = %+v", first)
+	}
+	// Money is exact to the scale, rounded past it
+	if r := seen["precise"]; r.Price != 123457 || r.Cost != 1_234_567_890_000 {
+		t.Errorf("precise row = %+v", r)
+	}
+}
+
+func isTimestamp(typ parquet.Type) bool {
+	_, ok := typ.LogicalType().Value.(*format.TimestampType)
+	return ok
+}
+
+// openParquet opens an export with a Parquet reader
+func openParquet(t *testing.T, data []byte) *parquet.File {
+	t.Helper()
+	f, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a Parquet file: %v", err)
+	}
+	return f
+}
+
+// parquetGroups is the row count of each of a Parquet file's row groups
+func parquetGroups(t *testing.T, data []byte) []int {
+	var rows []int
+	for _, g := range openParquet(t, data).RowGroups() {
+		rows = append(rows, int(g.NumRows()))
+	}
+	return rows
+}
@@ -0,0 +1,147 @@
+package analytics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// FloorWindow is how long clearing prices count towards floors. A week
+	// gives each hour of the day seven days of history.
+	FloorWindow = 7 * 24 * time.Hour
+
+	// FloorSuggestionPercentile is the clearing-price percentile suggested
+	// as a floor
+	FloorSuggestionPercentile = 40
+
+	// MinFloorSamples is how many prices an hour needs before its own
+	// distribution is used instead of the placement's whole day
+	MinFloorSamples = 10
+
+	// maxFloorSamples caps the prices kept per placement and hour
+	maxFloorSamples = 1000
+)
+
+// FloorPercentiles summarizes a placement's recent clearing prices
+type FloorPercentiles struct {
+	P25     decimal.Decimal `json:"p25"`
+	P50     decimal.Decimal `json:"p50"`
+	P75     decimal.Decimal `json:"p75"`
+	Samples int             `json:"samples"`
+}
+
+type clearingPrice struct {
+	price decimal.Decimal
+	at    time.Time
+}
+
+// floorStats keeps recent clearing prices per placement and UTC hour
+type floorStats struct {
+	mu     sync.Mutex
+	prices map[string]*[24][]clearingPrice
+}
+
+func newFloorStats() *floorStats {
+	return &floorStats{prices: make(map[string]*[24][]clearingPrice)}
+}
+
+func (f *floorStats) record(placementID string, price decimal.Decimal, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hours, ok := f.prices[placementID]
+	if !ok {
+		hours = new([24][]clearingPrice)
+		f.prices[placementID] = hours
+	}
+	h := at.UTC().Hour()
+	samples := append(hours[h], clearingPrice{price: price, at: at})
+	if len(samples) > maxFloorSamples {
+		samples = samples[len(samples)-maxFloorSamples:]
+	}
+	hours[h] = samples
+}
+
+// window returns the placement's unexpired prices for hour, or for every
+// hour when hour is negative
+func (f *floorStats) window(placementID string, hour int, now time.Time) []decimal.Decimal {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hours, ok := f.prices[placementID]
+	if !ok {
+		return nil
+	}
+	since := now.Add(-FloorWindow)
+	var out []decimal.Decimal
+	for h := range hours {
+		if hour >= 0 && h != hour {
+			continue
+		}
+		// Samples are in arrival order, so expired ones lead
+		samples := hours[h]
+		i := 0
+		for i < len(samples) && !samples[i].at.After(since) {
+			i++
+		}
+		hours[h] = samples[i:]
+		for _, s := range hours[h] {
+			out = append(out, s.price)
+		}
+	}
+	return out
+}
+
+// RecordClearingPrice records the price an auction for placementID cleared
+// at, feeding floor percentiles and suggestions
+func (a *AnalyticsTracker) RecordClearingPrice(placementID string, price decimal.Decimal, at time.Time) {
+	if placementID == "" || !price.IsPositive() {
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	a.floors.record(placementID, price, at)
+}
+
+// ClearingPercentiles returns the placement's clearing-price percentiles for
+// the UTC hour of now, over the last FloorWindow
+func (a *AnalyticsTracker) ClearingPercentiles(placementID string, now time.Time) FloorPercentiles {
+	sorted := sortedPrices(a.floors.window(placementID, now.UTC().Hour(), now))
+	return FloorPercentiles{
+		P25:     percentile(sorted, 25),
+		P50:     percentile(sorted, 50),
+		P75:     percentile(sorted, 75),
+		Samples: len(sorted),
+	}
+}
+
+// GetFloorSuggestion suggests a floor for placementID at now: the
+// FloorSuggestionPercentile clearing price for that hour of day, or across
+// the placement's whole day when the hour has fewer than MinFloorSamples
+// prices. It is zero when the placement has no history.
+func (a *AnalyticsTracker) GetFloorSuggestion(placementID string, now time.Time) decimal.Decimal {
+	prices := a.floors.window(placementID, now.UTC().Hour(), now)
+	if len(prices) < MinFloorSamples {
+		prices = a.floors.window(placementID, -1, now)
+	}
+	return percentile(sortedPrices(prices), FloorSuggestionPercentile)
+}
+
+func sortedPrices(prices []decimal.Decimal) []decimal.Decimal {
+	sort.Slice(prices, func(i, j int) bool { return prices[i].LessThan(prices[j]) })
+	return prices
+}
+
+// percentile uses nearest-rank, matching the rtb floor engine
+func percentile(sorted []decimal.Decimal, p float64) decimal.Decimal {
+	if len(sorted) == 0 {
+		return decimal.Zero
+	}
+	rank := int(p / 100 * float64(len(sorted)))
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestFloorSuggestion_TracksDistributions(t *testing.T) {
+	a := NewAnalyticsTracker()
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	prime := day.Add(20 * time.Hour)
+	morning := day.Add(8 * time.Hour)
+
+	// Prime time on the CTV placement clears at 11-30, mornings at 1-10,
+	// and the display placement at 0.10-1.00, spread over the week
+	for i := 0; i < 20; i++ {
+		back := time.Duration(i%7) * 24 * time.Hour
+		a.RecordClearingPrice("ctv-preroll", decimal.NewFromInt(int64(11+i)), prime.Add(-back))
+		a.RecordClearingPrice("ctv-preroll", decimal.NewFromFloat(float64(1+i%10)), morning.Add(-back))
+		a.RecordClearingPrice("display-top", decimal.NewFromFloat(float64(1+i%10)/10), prime.Add(-back))
+	}
+
+	p := a.ClearingPercentiles("ctv-preroll", prime)
+	if p.Samples != 20 || !p.P25.Equal(decimal.NewFromInt(16)) || !p.P50.Equal(decimal.NewFromInt(21)) || !p.P75.Equal(decimal.NewFromInt(26)) {
+		t.Errorf("prime time percentiles = %+v", p)
+	}
+
+	for _, tt := range []struct {
+		placement string
+		at        time.Time
+		want      string
+	}{
+		{"ctv-preroll", prime, "19"},
+		{"ctv-preroll", morning, "5"},
+		{"display-top", prime, "0.5"},
+		// Hours without enough history use the placement's whole day
+		{"ctv-preroll", day.Add(3 * time.Hour), "9"},
+		{"unknown", prime, "0"},
+	} {
+		got := a.GetFloorSuggestion(tt.placement, tt.at)
+		if want := decimal.RequireFromString(tt.want); !got.Equal(want) {
+			t.Errorf("%s at %02d:00: suggestion = %s, want %s", tt.placement, tt.at.Hour(), got, want)
+		}
+	}
+
+	// Prices age out after FloorWindow
+	if got := a.GetFloorSuggestion("ctv-preroll", prime.Add(FloorWindow+7*24*time.Hour)); !got.IsZero() {
+		t.Errorf("suggestion after the window = %s, want 0", got)
+	}
+}
+
+func TestFloorSuggestion_FollowsNewPrices(t *testing.T) {
+	a := NewAnalyticsTracker()
+	now := time.Date(2025, 3, 3, 20, 50, 0, 0, time.UTC)
+	for i := 0; i < 50; i++ {
+		a.RecordClearingPrice("ctv-preroll", decimal.NewFromInt(5), now.Add(-time.Duration(i)*time.Minute))
+	}
+	before := a.GetFloorSuggestion("ctv-preroll", now)
+
+	// Demand doubles
+	for i := 0; i < 100; i++ {
+		a.RecordClearingPrice("ctv-preroll", decimal.NewFromInt(10), now)
+	}
+	after := a.GetFloorSuggestion("ctv-preroll", now)
+	if !before.Equal(decimal.NewFromInt(5)) || !after.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("suggestion %s -> %s, want 5 -> 10", before, after)
+	}
+
+	// Non-positive prices and missing placements are ignored
+	a.RecordClearingPrice("ctv-preroll", decimal.Zero, now)
+	a.RecordClearingPrice("", decimal.NewFromInt(1), now)
+	if p := a.ClearingPercentiles("ctv-preroll", now); p.Samples != 150 {
+		t.Errorf("samples = %d, want 150", p.Samples)
+	}
+}
@@ -0,0 +1,84 @@
+package analytics
+
+import "time"
+
+// LossReason is why a bid didn't win an auction
+type LossReason string
+
+// Loss reasons, as the exchange classifies them
+const (
+	LossOutbid            LossReason = "outbid"             // Eligible, but a higher bid won
+	LossLostToDeal        LossReason = "lost_to_deal"       // Eligible, but a deal bid won
+	LossBelowFloor        LossReason = "below_floor"        // Under the impression's floor
+	LossDealMismatch      LossReason = "deal_mismatch"      // For a deal the impression doesn't offer
+	LossCategoryBlocked   LossReason = "category_blocked"   // A category the publisher blocks
+	LossAdvertiserBlocked LossReason = "advertiser_blocked" // An advertiser the publisher blocks
+	LossBrandUnsafe       LossReason = "brand_unsafe"       // Excluded for the page
+	LossCapped            LossReason = "capped"             // Over a seat, budget or frequency cap
+	LossQuality           LossReason = "quality"            // Creative failed the quality gate
+	LossCurrency          LossReason = "currency"           // Priced in a currency the exchange doesn't clear in
+	LossFiltered          LossReason = "filtered"           // Dropped for any other reason
+)
+
+// LossReasonBreakdown counts a DSP's lost bids by reason, overall and
+// per placement
+type LossReasonBreakdown struct {
+	DSP        string                           `json:"dsp,omitempty"` // Every DSP when empty
+	Start      time.Time                        `json:"start"`
+	End        time.Time                        `json:"end"`
+	Total      uint64                           `json:"total"`
+	Reasons    map[LossReason]uint64            `json:"reasons"`
+	Placements map[string]map[LossReason]uint64 `json:"placements"`
+}
+
+// RecordLoss tracks a lost bid as a loss event, stored and streamed
+func (a *AnalyticsTracker) RecordLoss(dspID, placementID, reason string, at time.Time) {
+	event := &Event{
+		Type:        EventLoss,
+		Timestamp:   at,
+		DSPID:       dspID,
+		PlacementID: placementID,
+		LossReason:  LossReason(reason),
+	}
+	a.publish(event)
+	a.storage.Store(event)
+}
+
+// GetLossReasonBreakdown counts the bids dspID lost in tr by reason, or
+// every DSP's when dspID is empty
+func (a *AnalyticsTracker) GetLossReasonBreakdown(dspID string, tr TimeRange) (*LossReasonBreakdown, error) {
+	if tr.End.Before(tr.Start) {
+		return nil, ErrInvalidRange
+	}
+	filter := QueryFilter{StartTime: tr.Start, EndTime: tr.End, EventTypes: []EventType{EventLoss}}
+	if dspID != "" {
+		filter.DSPIDs = []string{dspID}
+	}
+	events, err := a.storage.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &LossReasonBreakdown{
+		DSP:        dspID,
+		Start:      tr.Start,
+		End:        tr.End,
+		Reasons:    make(map[LossReason]uint64),
+		Placements: make(map[string]map[LossReason]uint64),
+	}
+	for _, e := range events {
+		reason := e.LossReason
+		if reason == "" {
+			reason = LossFiltered
+		}
+		b.Total++
+		b.Reasons[reason]++
+		p, ok := b.Placements[e.PlacementID]
+		if !ok {
+			p = make(map[LossReason]uint64)
+			b.Placements[e.PlacementID] = p
+		}
+		p[reason]++
+	}
+	return b, nil
+}
@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// ExportMoneyScale is the decimal places Parquet exports keep of money
+// columns, stored as DECIMAL(18, ExportMoneyScale) INT64
+const ExportMoneyScale = 6
+
+// parquetExport writes a Parquet file with a required column per export
+// column: the timestamp as TIMESTAMP_MICROS, dimensions as UTF-8 strings
+// and metrics as DECIMAL. The writer buffers a row group at a time and
+// writes the footer indexing them on close.
+type parquetExport struct {
+	w   *parquet.Writer
+	row parquet.Row
+
+	// Each leaf column's value, in schema order
+	values []func(e *Event) parquet.Value
+}
+
+func newParquetExport(w io.Writer, groupRows int) (*parquetExport, error) {
+	fields := parquet.Group{exportTimestamp: parquet.Timestamp(parquet.Microsecond)}
+	for _, col := range exportColumns {
+		if col.text != nil {
+			fields[col.name] = parquet.String()
+		} else {
+			fields[col.name] = parquet.Decimal(ExportMoneyScale, 18, parquet.Int64Type)
+		}
+	}
+	schema := parquet.NewSchema("event", fields)
+
+	p := &parquetExport{
+		w: parquet.NewWriter(w, schema, parquet.MaxRowsPerRowGroup(int64(groupRows)), parquet.CreatedBy("luxfi/adx", "", "")),
+	}
+	for _, path := range schema.Columns() {
+		p.values = append(p.values, parquetValue(path[0]))
+	}
+	p.row = make(parquet.Row, len(p.values))
+	return p, nil
+}
+
+// parquetValue reads the named column's value from an event
+func parquetValue(name string) func(e *Event) parquet.Value {
+	if name == exportTimestamp {
+		return func(e *Event) parquet.Value { return parquet.Int64Value(e.Timestamp.UnixMicro()) }
+	}
+	for _, col := range exportColumns {
+		if col.name != name {
+			continue
+		}
+		if col.text != nil {
+			return func(e *Event) parquet.Value { return parquet.ByteArrayValue([]byte(col.text(e))) }
+		}
+		return func(e *Event) parquet.Value {
+			return parquet.Int64Value(col.metric(e).Shift(ExportMoneyScale).Round(0).IntPart())
+		}
+	}
+	panic("analytics: no export column " + name)
+}
+
+func (p *parquetExport) write(e *Event) error {
+	for i, value := range p.values {
+		p.row[i] = value(e).Level(0, 0, i)
+	}
+	_, err := p.w.WriteRows([]parquet.Row{p.row})
+	return err
+}
+
+// close writes the last row group and the footer
+func (p *parquetExport) close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,186 @@
+package analytics
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidRange is returned when a report's end precedes its start
+var ErrInvalidRange = errors.New("report end precedes start")
+
+var thousand = decimal.NewFromInt(1000)
+
+// ReportFilter selects the events a report aggregates. Start and End are
+// truncated to UTC days; End is inclusive of its day.
+type ReportFilter struct {
+	Start       time.Time
+	End         time.Time
+	CampaignID  string
+	PublisherID string
+}
+
+// ReportRow aggregates delivery and money for one group of events
+type ReportRow struct {
+	Key         string
+	Impressions uint64
+	Clicks      uint64
+	Completions uint64
+	Revenue     decimal.Decimal
+	Cost        decimal.Decimal
+	Profit      decimal.Decimal
+	CTR         float64
+	CPM         float64
+	VCR         float64
+}
+
+// Report is a daily breakdown plus totals for a date range
+type Report struct {
+	Start  time.Time
+	End    time.Time
+	Days   []*ReportRow // one row per day, keyed by YYYY-MM-DD
+	Totals *ReportRow
+}
+
+// TrackEvent stores an event and updates the real-time counters
+func (a *AnalyticsTracker) TrackEvent(event *Event) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	switch event.Type {
+	case EventImpression:
+		a.TotalImpressions.Add(1)
+	case EventClick:
+		a.TotalClicks.Add(1)
+	case EventComplete:
+		a.TotalCompletions.Add(1)
+	}
+
+	return a.storage.Store(event)
+}
+
+// Report aggregates stored impressions, clicks and completions by day.
+// Every day in the range gets a row, so an empty range yields zeroes.
+func (a *AnalyticsTracker) Report(filter ReportFilter) (*Report, error) {
+	start, end, err := filter.days()
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.Query(filter.query(start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[string]*ReportRow)
+	var days []*ReportRow
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		row := &ReportRow{Key: d.Format(time.DateOnly)}
+		byDay[row.Key] = row
+		days = append(days, row)
+	}
+
+	totals := &ReportRow{}
+	for _, e := range events {
+		if row, ok := byDay[e.Timestamp.UTC().Format(time.DateOnly)]; ok {
+			row.add(e)
+		}
+		totals.add(e)
+	}
+
+	for _, row := range days {
+		row.finalize()
+	}
+	totals.finalize()
+
+	return &Report{Start: start, End: end, Days: days, Totals: totals}, nil
+}
+
+// Breakdown aggregates the same events as Report, grouped by key(event).
+// Events for which key returns "" are skipped. Rows are sorted by key.
+func (a *AnalyticsTracker) Breakdown(filter ReportFilter, key func(*Event) string) ([]*ReportRow, error) {
+	start, end, err := filter.days()
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.Query(filter.query(start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*ReportRow)
+	for _, e := range events {
+		k := key(e)
+		if k == "" {
+			continue
+		}
+		row, ok := groups[k]
+		if !ok {
+			row = &ReportRow{Key: k}
+			groups[k] = row
+		}
+		row.add(e)
+	}
+
+	rows := make([]*ReportRow, 0, len(groups))
+	for _, row := range groups {
+		row.finalize()
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+
+	return rows, nil
+}
+
+// days returns the half-open [start, end) range of whole UTC days
+func (f ReportFilter) days() (time.Time, time.Time, error) {
+	start := f.Start.UTC().Truncate(24 * time.Hour)
+	end := f.End.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, ErrInvalidRange
+	}
+	return start, end, nil
+}
+
+func (f ReportFilter) query(start, end time.Time) QueryFilter {
+	q := QueryFilter{
+		StartTime:  start,
+		EndTime:    end,
+		EventTypes: []EventType{EventImpression, EventClick, EventComplete},
+	}
+	if f.CampaignID != "" {
+		q.CampaignIDs = []string{f.CampaignID}
+	}
+	if f.PublisherID != "" {
+		q.PublisherIDs = []string{f.PublisherID}
+	}
+	return q
+}
+
+func (r *ReportRow) add(e *Event) {
+	switch e.Type {
+	case EventImpression:
+		r.Impressions++
+		r.Revenue = r.Revenue.Add(e.Price.Div(thousand))
+		r.Cost = r.Cost.Add(e.Cost.Div(thousand))
+	case EventClick:
+		r.Clicks++
+	case EventComplete:
+		r.Completions++
+	}
+}
+
+func (r *ReportRow) finalize() {
+	r.Profit = r.Revenue.Sub(r.Cost)
+	if r.Impressions == 0 {
+		return
+	}
+	imps := float64(r.Impressions)
+	r.CTR = float64(r.Clicks) / imps
+	r.VCR = float64(r.Completions) / imps
+	r.CPM = r.Revenue.Mul(thousand).InexactFloat64() / imps
+}
@@ -0,0 +1,228 @@
+package analytics
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrRetentionUnsupported is returned for a storage backend that can't
+// purge or rewrite its events
+var ErrRetentionUnsupported = errors.New("storage backend does not support retention")
+
+// RetentionStore is a StorageBackend whose events can be purged and
+// rewritten, such as InMemoryStorage and DatabaseStorage
+type RetentionStore interface {
+	// Purge deletes the events from before cutoff
+	Purge(cutoff time.Time) (int, error)
+	// Scrub passes each event from before cutoff to scrub, saving the
+	// ones it reports changing
+	Scrub(cutoff time.Time, scrub func(*Event) bool) (int, error)
+	// Erase deletes every event match reports true for
+	Erase(match func(*Event) bool) (int, error)
+}
+
+// piiMetadataKeys are the event metadata that identify a person or device
+var piiMetadataKeys = []string{"ip", "ipv6", "ifa", "device_id", "user_agent", "ua", "lat", "lon", "wallet"}
+
+// hashedPrefix marks a UserID that has already been hashed
+const hashedPrefix = "h:"
+
+// RetentionPolicy is how long raw events and the personal data in them
+// are kept. Aggregates the tracker keeps in memory, and the quartile and
+// time series rollups, are never purged.
+type RetentionPolicy struct {
+	Events time.Duration // Events are deleted this long after they happen; kept when zero
+	PII    time.Duration // UserIDs are hashed and device metadata dropped after this; kept when zero
+	Salt   []byte        // Keys the UserID hash, so hashes can't be reversed by lookup
+}
+
+// RetentionStats counts what a sweep did
+type RetentionStats struct {
+	Purged   int `json:"purged"`
+	Scrubbed int `json:"scrubbed"`
+}
+
+// RetentionManager enforces a RetentionPolicy on a storage backend and
+// erases a person's events on request
+type RetentionManager struct {
+	Policy RetentionPolicy
+
+	store RetentionStore
+	now   func() time.Time
+}
+
+// NewRetentionManager enforces policy on storage, which must be a
+// RetentionStore
+func NewRetentionManager(storage StorageBackend, policy RetentionPolicy) (*RetentionManager, error) {
+	store, ok := storage.(RetentionStore)
+	if !ok {
+		return nil, ErrRetentionUnsupported
+	}
+	return &RetentionManager{Policy: policy, store: store, now: time.Now}, nil
+}
+
+// Sweep purges events past the retention window and scrubs personal data
+// from those past the PII window
+func (m *RetentionManager) Sweep() (RetentionStats, error) {
+	var stats RetentionStats
+	now := m.now()
+	if m.Policy.Events > 0 {
+		n, err := m.store.Purge(now.Add(-m.Policy.Events))
+		if err != nil {
+			return stats, err
+		}
+		stats.Purged = n
+	}
+	if m.Policy.PII > 0 {
+		n, err := m.store.Scrub(now.Add(-m.Policy.PII), m.scrub)
+		if err != nil {
+			return stats, err
+		}
+		stats.Scrubbed = n
+	}
+	return stats, nil
+}
+
+// Run sweeps every interval until ctx is done
+func (m *RetentionManager) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := m.Sweep()
+			if err != nil {
+				slog.Warn("retention sweep failed", "error", err)
+				continue
+			}
+			if stats.Purged > 0 || stats.Scrubbed > 0 {
+				slog.Info("retention sweep", "purged", stats.Purged, "scrubbed", stats.Scrubbed)
+			}
+		}
+	}
+}
+
+// Erase deletes every event identifying id, as a UserID, hashed or not,
+// or as a device identifier in the event's metadata
+func (m *RetentionManager) Erase(id string) (int, error) {
+	if id == "" {
+		return 0, nil
+	}
+	hashed := m.hash(id)
+	return m.store.Erase(func(e *Event) bool {
+		if e.UserID == id || e.UserID == hashed {
+			return true
+		}
+		for _, k := range piiMetadataKeys {
+			if v, ok := e.Metadata[k].(string); ok && v == id {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// scrub hashes e's UserID and drops its device metadata, reporting
+// whether anything changed
+func (m *RetentionManager) scrub(e *Event) bool {
+	changed := false
+	if e.UserID != "" && !strings.HasPrefix(e.UserID, hashedPrefix) {
+		e.UserID = m.hash(e.UserID)
+		changed = true
+	}
+	if hasPII(e.Metadata) {
+		// The map may be shared with the event as it was tracked
+		meta := make(map[string]interface{}, len(e.Metadata))
+		for k, v := range e.Metadata {
+			if !slices.Contains(piiMetadataKeys, k) {
+				meta[k] = v
+			}
+		}
+		e.Metadata = meta
+		changed = true
+	}
+	return changed
+}
+
+func hasPII(meta map[string]interface{}) bool {
+	for _, k := range piiMetadataKeys {
+		if _, ok := meta[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *RetentionManager) hash(id string) string {
+	mac := hmac.New(sha256.New, m.Policy.Salt)
+	mac.Write([]byte(id))
+	return hashedPrefix + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// EnableRetention enforces policy on the tracker's storage, returning the
+// manager to sweep it with. Erase works once it's enabled.
+func (a *AnalyticsTracker) EnableRetention(policy RetentionPolicy) (*RetentionManager, error) {
+	m, err := NewRetentionManager(a.storage, policy)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.retention = m
+	a.mu.Unlock()
+	return m, nil
+}
+
+// Erase deletes every stored event identifying id, for a right-to-erasure
+// request. It fails with ErrRetentionUnsupported until EnableRetention.
+func (a *AnalyticsTracker) Erase(id string) (int, error) {
+	a.mu.RLock()
+	m := a.retention
+	a.mu.RUnlock()
+	if m == nil {
+		return 0, ErrRetentionUnsupported
+	}
+	return m.Erase(id)
+}
+
+// Purge deletes the events from before cutoff
+func (s *InMemoryStorage) Purge(cutoff time.Time) (int, error) {
+	return s.Erase(func(e *Event) bool { return e.Timestamp.Before(cutoff) })
+}
+
+// Scrub rewrites the events from before cutoff with scrub
+func (s *InMemoryStorage) Scrub(cutoff time.Time, scrub func(*Event) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for i := range s.events {
+		if s.events[i].Timestamp.Before(cutoff) && scrub(&s.events[i]) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Erase deletes the events match reports true for
+func (s *InMemoryStorage) Erase(match func(*Event) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A new slice, since Query hands out pointers into the old one
+	kept := make([]Event, 0, len(s.events))
+	for i := range s.events {
+		if !match(&s.events[i]) {
+			kept = append(kept, s.events[i])
+		}
+	}
+	n := len(s.events) - len(kept)
+	s.events = kept
+	return n, nil
+}
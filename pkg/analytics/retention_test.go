@@ -0,0 +1,93 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/database/memdb"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+func TestRetention(t *testing.T) {
+	for name, newStorage := range map[string]func() StorageBackend{
+		"memory":   func() StorageBackend { return NewInMemoryStorage() },
+		"database": func() StorageBackend { return NewDatabaseStorage(memdb.New()) },
+	} {
+		t.Run(name, func(t *testing.T) { testRetention(t, newStorage()) })
+	}
+}
+
+func testRetention(t *testing.T, storage StorageBackend) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := NewAnalyticsTrackerWithStorage(storage)
+	for _, e := range []*Event{
+		{Type: EventImpression, Timestamp: now.AddDate(0, 0, -40), ImpressionID: "old", UserID: "user-1"},
+		{Type: EventImpression, Timestamp: now.AddDate(0, 0, -10), ImpressionID: "mid", UserID: "user-2",
+			Metadata: map[string]interface{}{"ifa": "ifa-2", "pod": true}},
+		{Type: EventClick, Timestamp: now.Add(-time.Hour), ImpressionID: "recent", UserID: "user-1"},
+		{Type: EventImpression, Timestamp: now.Add(-time.Hour), ImpressionID: "device", Metadata: map[string]interface{}{"ifa": "ifa-3"}},
+	} {
+		if err := storage.Store(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Aggregates kept outside the raw events
+	a.TrackResponse(&openrtb2.BidResponse{SeatBid: []openrtb2.SeatBid{{Bid: []openrtb2.Bid{{Price: 2}}}}}, time.Millisecond)
+	a.Video.Register("mid", "camp-1", "cre-1")
+	a.Video.Record("mid", VideoComplete)
+
+	m, err := a.EnableRetention(RetentionPolicy{Events: 30 * 24 * time.Hour, PII: 7 * 24 * time.Hour, Salt: []byte("salt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return now }
+
+	stats, err := m.Sweep()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (RetentionStats{Purged: 1, Scrubbed: 1}) {
+		t.Errorf("sweep = %+v, want 1 purged and 1 scrubbed", stats)
+	}
+	all := func() map[string]*Event {
+		t.Helper()
+		events, err := storage.Query(QueryFilter{StartTime: now.AddDate(-1, 0, 0), EndTime: now.Add(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		byID := make(map[string]*Event)
+		for _, e := range events {
+			byID[e.ImpressionID] = e
+		}
+		return byID
+	}
+	events := all()
+	if _, ok := events["old"]; ok || len(events) != 3 {
+		t.Fatalf("events after sweep = %v, want the one past retention purged", events)
+	}
+	mid := events["mid"]
+	if !strings.HasPrefix(mid.UserID, hashedPrefix) || mid.Metadata["ifa"] != nil || mid.Metadata["pod"] != true {
+		t.Errorf("scrubbed event = %+v, want the user hashed and the device ID dropped", mid)
+	}
+	if events["recent"].UserID != "user-1" {
+		t.Errorf("recent event user = %q, want it kept", events["recent"].UserID)
+	}
+	if a.TotalImpressions.Load() != 1 || a.Video.Total().Completes != 1 {
+		t.Errorf("aggregates = %d impressions, %+v; want them kept", a.TotalImpressions.Load(), a.Video.Total())
+	}
+
+	// Erasure finds users by hashed ID too, and devices by identifier
+	for id, want := range map[string]string{"user-2": "mid", "user-1": "recent", "ifa-3": "device"} {
+		n, err := a.Erase(id)
+		if err != nil || n != 1 {
+			t.Errorf("Erase(%s) = %d, %v; want 1", id, n, err)
+		}
+		if _, ok := all()[want]; ok {
+			t.Errorf("Erase(%s) left %s", id, want)
+		}
+	}
+	if left := all(); len(left) != 0 {
+		t.Errorf("events left = %v", left)
+	}
+}
@@ -0,0 +1,132 @@
+package analytics
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy is what happens to an event when the stream's buffer is
+// full
+type OverflowPolicy string
+
+const (
+	// OverflowDropNewest drops the event, so tracking never slows the
+	// request path
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowBlock waits up to the block timeout for room before dropping
+	OverflowBlock OverflowPolicy = "block"
+)
+
+// Event stream defaults
+const (
+	DefaultStreamBuffer   = 10000
+	DefaultBlockTimeout   = 5 * time.Millisecond
+	DefaultDropWarnRate   = 0.01
+	DefaultDropWarnWindow = 10 * time.Second
+)
+
+// StreamConfig sizes the event stream and sets how it sheds load
+type StreamConfig struct {
+	BufferSize   int            // DefaultStreamBuffer when zero
+	Overflow     OverflowPolicy // OverflowDropNewest when empty
+	BlockTimeout time.Duration  // For OverflowBlock; DefaultBlockTimeout when zero
+
+	// A warning is logged for each DropWarnWindow in which more than
+	// DropWarnRate of events were dropped
+	DropWarnRate   float64       // DefaultDropWarnRate when zero
+	DropWarnWindow time.Duration // DefaultDropWarnWindow when zero
+}
+
+func (c StreamConfig) withDefaults() StreamConfig {
+	if c.BufferSize <= 0 {
+		c.BufferSize = DefaultStreamBuffer
+	}
+	if c.Overflow == "" {
+		c.Overflow = OverflowDropNewest
+	}
+	if c.BlockTimeout <= 0 {
+		c.BlockTimeout = DefaultBlockTimeout
+	}
+	if c.DropWarnRate <= 0 {
+		c.DropWarnRate = DefaultDropWarnRate
+	}
+	if c.DropWarnWindow <= 0 {
+		c.DropWarnWindow = DefaultDropWarnWindow
+	}
+	return c
+}
+
+// streamStats counts events offered to the stream and dropped from it
+type streamStats struct {
+	dropped atomic.Uint64
+
+	// Counts for the current warning window
+	windowStart   atomic.Int64 // Unix nanoseconds
+	windowSent    atomic.Uint64
+	windowDropped atomic.Uint64
+}
+
+// ConfigureStream replaces EventStream with one sized and policed by cfg.
+// Call it before starting any consumer.
+func (a *AnalyticsTracker) ConfigureStream(cfg StreamConfig) {
+	a.stream = cfg.withDefaults()
+	a.EventStream = make(chan *Event, a.stream.BufferSize)
+}
+
+// DroppedEvents is how many events the stream has dropped because its
+// consumers fell behind
+func (a *AnalyticsTracker) DroppedEvents() uint64 {
+	return a.streamStats.dropped.Load()
+}
+
+// publish offers an event to the stream under its overflow policy
+func (a *AnalyticsTracker) publish(event *Event) {
+	select {
+	case a.EventStream <- event:
+		a.countSent(false)
+		return
+	default:
+	}
+
+	if a.stream.Overflow == OverflowBlock {
+		timer := time.NewTimer(a.stream.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case a.EventStream <- event:
+			a.countSent(false)
+			return
+		case <-timer.C:
+		}
+	}
+	a.streamStats.dropped.Add(1)
+	a.countSent(true)
+}
+
+// countSent tallies an event in the warning window, warning once the
+// window closes if too many were dropped
+func (a *AnalyticsTracker) countSent(dropped bool) {
+	s := &a.streamStats
+	s.windowSent.Add(1)
+	if dropped {
+		s.windowDropped.Add(1)
+	}
+
+	now := time.Now().UnixNano()
+	start := s.windowStart.Load()
+	if start == 0 {
+		s.windowStart.CompareAndSwap(0, now)
+		return
+	}
+	if time.Duration(now-start) < a.stream.DropWarnWindow || !s.windowStart.CompareAndSwap(start, now) {
+		return
+	}
+	sent, drops := s.windowSent.Swap(0), s.windowDropped.Swap(0)
+	if sent == 0 {
+		return
+	}
+	if rate := float64(drops) / float64(sent); rate > a.stream.DropWarnRate {
+		slog.Warn("analytics events dropped", "dropped", drops, "events", sent, "rate", rate,
+			"buffer", cap(a.EventStream), "overflow", a.stream.Overflow)
+	}
+}
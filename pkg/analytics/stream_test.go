@@ -0,0 +1,87 @@
+package analytics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+func streamRequest() *openrtb2.BidRequest {
+	return &openrtb2.BidRequest{ID: "req-1", Imp: []openrtb2.Imp{{ID: "1"}}}
+}
+
+// drainSlowly consumes the stream one event per interval until stop closes
+func drainSlowly(a *AnalyticsTracker, interval time.Duration, stop <-chan struct{}) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-a.EventStream:
+				time.Sleep(interval)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return &wg
+}
+
+func TestEventStream_DropsWhenSaturated(t *testing.T) {
+	a := NewAnalyticsTracker()
+	a.ConfigureStream(StreamConfig{BufferSize: 4})
+	stop := make(chan struct{})
+	wg := drainSlowly(a, 10*time.Millisecond, stop)
+
+	for i := 0; i < 100; i++ {
+		a.TrackRequest(streamRequest())
+	}
+	close(stop)
+	wg.Wait()
+
+	dropped := a.DroppedEvents()
+	if dropped < 50 || dropped >= 100 {
+		t.Errorf("dropped = %d of 100 into a buffer of 4, want most", dropped)
+	}
+	if got := a.GetRealTimeMetrics()["dropped_events"]; got != dropped {
+		t.Errorf("real-time dropped_events = %v, want %d", got, dropped)
+	}
+	want := fmt.Sprintf("adx_analytics_events_dropped_total %d", dropped)
+	if !strings.Contains(a.ExportMetrics(), want) {
+		t.Errorf("export missing %q", want)
+	}
+}
+
+func TestEventStream_BlockWithTimeout(t *testing.T) {
+	a := NewAnalyticsTracker()
+	a.ConfigureStream(StreamConfig{BufferSize: 1, Overflow: OverflowBlock, BlockTimeout: 50 * time.Millisecond})
+	stop := make(chan struct{})
+	wg := drainSlowly(a, time.Millisecond, stop)
+
+	// A consumer keeping up within the timeout loses nothing
+	for i := 0; i < 20; i++ {
+		a.TrackRequest(streamRequest())
+	}
+	close(stop)
+	wg.Wait()
+	if n := a.DroppedEvents(); n != 0 {
+		t.Errorf("dropped = %d with a consumer inside the block timeout", n)
+	}
+
+	// With no consumer, the event is dropped once the timeout passes
+	a.ConfigureStream(StreamConfig{BufferSize: 1, Overflow: OverflowBlock, BlockTimeout: 5 * time.Millisecond})
+	a.TrackRequest(streamRequest())
+	start := time.Now()
+	a.TrackRequest(streamRequest())
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("returned after %s, before the block timeout", elapsed)
+	}
+	if n := a.DroppedEvents(); n != 1 {
+		t.Errorf("dropped = %d, want 1", n)
+	}
+}
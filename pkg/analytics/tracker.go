@@ -33,9 +33,15 @@ type AnalyticsTracker struct {
 	// Pod metrics for CTV
 	PodMetrics *PodMetrics
 
+	// Quartile beacons per served video impression
+	Video *QuartileTracker
+
 	// Time series data
 	TimeSeries *TimeSeriesData
 
+	// Clearing prices per placement and hour, for floor suggestions
+	floors *floorStats
+
 	// Publisher metrics
 	PublisherMetrics map[string]*PublisherStats
 
@@ -48,11 +54,19 @@ type AnalyticsTracker struct {
 	// Mutex for maps
 	mu sync.RWMutex
 
-	// Event stream for real-time analytics
+	// Event stream for real-time analytics; see ConfigureStream
 	EventStream chan *Event
+	stream      StreamConfig
+	streamStats streamStats
 
 	// Storage backend (FoundationDB when ready)
 	storage StorageBackend
+
+	// Deal terms and settlements, for deal reports
+	deals DealLedger
+
+	// Purges and scrubs stored events; see EnableRetention
+	retention *RetentionManager
 }
 
 // PodMetrics tracks CTV ad pod performance
@@ -143,13 +157,18 @@ type Event struct {
 	Timestamp    time.Time
 	PublisherID  string
 	PlacementID  string
+	CampaignID   string
+	CreativeID   string
+	DealID       string // PMP or guaranteed deal the bid was made on
 	ImpressionID string
 	DSPID        string
 	MinerID      string
 	UserID       string
 	DeviceType   string
 	GeoCountry   string
-	Price        decimal.Decimal
+	Price        decimal.Decimal // CPM charged to the advertiser
+	Cost         decimal.Decimal // CPM paid out to the publisher
+	LossReason   LossReason      // Why the bid lost, for EventLoss
 	Metadata     map[string]interface{}
 }
 
@@ -160,6 +179,7 @@ const (
 	EventRequest    EventType = "request"
 	EventBid        EventType = "bid"
 	EventWin        EventType = "win"
+	EventLoss       EventType = "loss"
 	EventImpression EventType = "impression"
 	EventClick      EventType = "click"
 	EventComplete   EventType = "complete"
@@ -185,9 +205,12 @@ type QueryFilter struct {
 	EndTime      time.Time
 	EventTypes   []EventType
 	PublisherIDs []string
+	CampaignIDs  []string
 	DSPIDs       []string
 	MinerIDs     []string
+	DealIDs      []string
 	Limit        int
+	Offset       int // Matching events skipped before the first returned, for paging
 }
 
 // TimeRange for aggregations
@@ -201,6 +224,7 @@ type TimeRange struct {
 func NewAnalyticsTracker() *AnalyticsTracker {
 	return &AnalyticsTracker{
 		PodMetrics: &PodMetrics{},
+		Video:      NewQuartileTracker(),
 		TimeSeries: &TimeSeriesData{
 			Buckets:    make(map[int64]*MetricBucket),
 			BucketSize: 1 * time.Minute,
@@ -208,11 +232,20 @@ func NewAnalyticsTracker() *AnalyticsTracker {
 		PublisherMetrics: make(map[string]*PublisherStats),
 		DSPMetrics:       make(map[string]*DSPStats),
 		MinerMetrics:     make(map[string]*MinerStats),
-		EventStream:      make(chan *Event, 10000),
+		floors:           newFloorStats(),
+		EventStream:      make(chan *Event, DefaultStreamBuffer),
+		stream:           StreamConfig{}.withDefaults(),
 		storage:          NewInMemoryStorage(), // Default to in-memory
 	}
 }
 
+// NewAnalyticsTrackerWithStorage creates a tracker persisting events to storage
+func NewAnalyticsTrackerWithStorage(storage StorageBackend) *AnalyticsTracker {
+	a := NewAnalyticsTracker()
+	a.storage = storage
+	return a
+}
+
 // TrackRequest tracks an incoming bid request
 func (a *AnalyticsTracker) TrackRequest(request *openrtb2.BidRequest) {
 	a.TotalRequests.Add(1)
@@ -232,11 +265,7 @@ func (a *AnalyticsTracker) TrackRequest(request *openrtb2.BidRequest) {
 	}
 
 	// Send to event stream
-	select {
-	case a.EventStream <- event:
-	default:
-		// Buffer full, drop event
-	}
+	a.publish(event)
 
 	// Update time series
 	a.updateTimeSeries(event)
@@ -321,6 +350,7 @@ func (a *AnalyticsTracker) GetRealTimeMetrics() map[string]interface{} {
 		"avg_latency_ms":    float64(a.AverageLatency.Load()) / 1000.0,
 		"p95_latency_ms":    float64(a.P95Latency.Load()) / 1000.0,
 		"p99_latency_ms":    float64(a.P99Latency.Load()) / 1000.0,
+		"dropped_events":    a.DroppedEvents(),
 		"pod_metrics": map[string]interface{}{
 			"total_pods":      a.PodMetrics.TotalPods.Load(),
 			"avg_pod_size":    a.PodMetrics.AveragePodSize.Load(),
@@ -519,8 +549,13 @@ func (s *InMemoryStorage) Query(filter QueryFilter) ([]*Event, error) {
 	defer s.mu.RUnlock()
 
 	results := make([]*Event, 0)
+	skip := filter.Offset
 	for i := range s.events {
-		if s.matchesFilter(&s.events[i], filter) {
+		if matchesFilter(&s.events[i], filter) {
+			if skip > 0 {
+				skip--
+				continue
+			}
 			results = append(results, &s.events[i])
 			if len(results) >= filter.Limit && filter.Limit > 0 {
 				break
@@ -541,8 +576,18 @@ func (s *InMemoryStorage) Aggregate(metric string, groupBy []string, timeRange T
 	}, nil
 }
 
-func (s *InMemoryStorage) matchesFilter(event *Event, filter QueryFilter) bool {
-	if !event.Timestamp.After(filter.StartTime) || !event.Timestamp.Before(filter.EndTime) {
+// matchesFilter reports whether event is one filter selects
+func matchesFilter(event *Event, filter QueryFilter) bool {
+	// Ranges are half-open: [StartTime, EndTime)
+	if event.Timestamp.Before(filter.StartTime) || !event.Timestamp.Before(filter.EndTime) {
+		return false
+	}
+
+	if !matchesAny(filter.PublisherIDs, event.PublisherID) ||
+		!matchesAny(filter.CampaignIDs, event.CampaignID) ||
+		!matchesAny(filter.DSPIDs, event.DSPID) ||
+		!matchesAny(filter.MinerIDs, event.MinerID) ||
+		!matchesAny(filter.DealIDs, event.DealID) {
 		return false
 	}
 
@@ -562,6 +607,19 @@ func (s *InMemoryStorage) matchesFilter(event *Event, filter QueryFilter) bool {
 	return true
 }
 
+// matchesAny reports whether id is in ids; an empty list matches everything
+func matchesAny(ids []string, id string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
 // ExportMetrics exports metrics in Prometheus format
 func (a *AnalyticsTracker) ExportMetrics() string {
 	metrics := fmt.Sprintf(`# HELP adx_requests_total Total number of ad requests
@@ -591,6 +649,10 @@ adx_pods_total %d
 # HELP adx_pod_completion_rate Pod completion rate
 # TYPE adx_pod_completion_rate gauge
 adx_pod_completion_rate %.4f
+
+# HELP adx_analytics_events_dropped_total Analytics events dropped because the event stream was full
+# TYPE adx_analytics_events_dropped_total counter
+adx_analytics_events_dropped_total %d
 `,
 		a.TotalRequests.Load(),
 		a.TotalImpressions.Load(),
@@ -599,6 +661,7 @@ adx_pod_completion_rate %.4f
 		float64(a.AverageLatency.Load())/1000.0,
 		a.PodMetrics.TotalPods.Load(),
 		float64(a.PodMetrics.PodCompletionRate.Load())/100.0,
+		a.DroppedEvents(),
 	)
 
 	return metrics
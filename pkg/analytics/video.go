@@ -0,0 +1,378 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownVideoEvent = errors.New("unknown video event")
+	ErrUnknownImpression = errors.New("unknown impression")
+)
+
+// VAST linear tracking events, in playback order
+const (
+	VideoStart         = "start"
+	VideoFirstQuartile = "firstQuartile"
+	VideoMidpoint      = "midpoint"
+	VideoThirdQuartile = "thirdQuartile"
+	VideoComplete      = "complete"
+	VideoSkip          = "skip" // Viewer skipped a skippable ad
+)
+
+const (
+	// DefaultVideoImpressionTTL is how long an impression's beacons are
+	// accepted; a served ad's beacons all arrive well within it
+	DefaultVideoImpressionTTL = time.Hour
+
+	// DefaultMaxVideoImpressions caps the impressions a tracker holds
+	DefaultMaxVideoImpressions = 1 << 20
+)
+
+// videoEvents maps each tracking event to its bit in an impression's mask
+var videoEvents = map[string]uint8{
+	VideoStart:         1 << 0,
+	VideoFirstQuartile: 1 << 1,
+	VideoMidpoint:      1 << 2,
+	VideoThirdQuartile: 1 << 3,
+	VideoComplete:      1 << 4,
+	VideoSkip:          1 << 5,
+}
+
+// QuartileStats counts how far served impressions played
+type QuartileStats struct {
+	Impressions   uint64 `json:"impressions"`
+	Starts        uint64 `json:"starts"`
+	FirstQuartile uint64 `json:"first_quartile"`
+	Midpoint      uint64 `json:"midpoint"`
+	ThirdQuartile uint64 `json:"third_quartile"`
+	Completes     uint64 `json:"completes"`
+	Skips         uint64 `json:"skips"`
+}
+
+// VCR is the video completion rate: completes over impressions
+func (s QuartileStats) VCR() float64 {
+	if s.Impressions == 0 {
+		return 0
+	}
+	return float64(s.Completes) / float64(s.Impressions)
+}
+
+// SkipRate is the fraction of impressions the viewer skipped
+func (s QuartileStats) SkipRate() float64 {
+	if s.Impressions == 0 {
+		return 0
+	}
+	return float64(s.Skips) / float64(s.Impressions)
+}
+
+// PostSkipVCR is the completion rate of the impressions that weren't
+// skipped: how often a viewer who stayed past the skip button watched to
+// the end
+func (s QuartileStats) PostSkipVCR() float64 {
+	if s.Impressions <= s.Skips {
+		return 0
+	}
+	return float64(s.Completes) / float64(s.Impressions-s.Skips)
+}
+
+// DropOff returns the fraction of impressions reaching start, each quartile
+// and complete, in playback order
+func (s QuartileStats) DropOff() [5]float64 {
+	var out [5]float64
+	if s.Impressions == 0 {
+		return out
+	}
+	imps := float64(s.Impressions)
+	for i, n := range []uint64{s.Starts, s.FirstQuartile, s.Midpoint, s.ThirdQuartile, s.Completes} {
+		out[i] = float64(n) / imps
+	}
+	return out
+}
+
+func (s *QuartileStats) add(event string) {
+	switch event {
+	case VideoStart:
+		s.Starts++
+	case VideoFirstQuartile:
+		s.FirstQuartile++
+	case VideoMidpoint:
+		s.Midpoint++
+	case VideoThirdQuartile:
+		s.ThirdQuartile++
+	case VideoComplete:
+		s.Completes++
+	case VideoSkip:
+		s.Skips++
+	}
+}
+
+type videoImpression struct {
+	campaignID string
+	creativeID string
+	position   int
+	seen       uint8
+	registered time.Time
+}
+
+// QuartileTracker de-dupes quartile beacons per impression and rolls them
+// up per campaign, creative and ad pod position
+type QuartileTracker struct {
+	// MaxImpressions caps the impressions held; registering past it
+	// forgets the oldest. 0 is unbounded.
+	MaxImpressions int
+
+	mu          sync.Mutex
+	impressions map[string]*videoImpression
+	order       []string // Impression IDs, oldest registered first
+	total       QuartileStats
+	campaigns   map[string]*QuartileStats
+	creatives   map[string]*QuartileStats
+	positions   map[int]*QuartileStats
+}
+
+// NewQuartileTracker creates an empty tracker holding up to
+// DefaultMaxVideoImpressions impressions
+func NewQuartileTracker() *QuartileTracker {
+	return &QuartileTracker{
+		MaxImpressions: DefaultMaxVideoImpressions,
+		impressions:    make(map[string]*videoImpression),
+		campaigns:      make(map[string]*QuartileStats),
+		creatives:      make(map[string]*QuartileStats),
+		positions:      make(map[int]*QuartileStats),
+	}
+}
+
+// Register records a served impression; quartile beacons for unregistered
+// impressions are rejected. Registering twice is a no-op.
+func (q *QuartileTracker) Register(impressionID, campaignID, creativeID string) bool {
+	return q.RegisterAt(impressionID, campaignID, creativeID, 0)
+}
+
+// RegisterAt records a served impression played at a position in an ad
+// pod, counting from 1; 0 is outside a pod
+func (q *QuartileTracker) RegisterAt(impressionID, campaignID, creativeID string, position int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.impressions[impressionID]; ok {
+		return false
+	}
+	for q.MaxImpressions > 0 && len(q.impressions) >= q.MaxImpressions {
+		q.forgetOldest()
+	}
+	q.order = append(q.order, impressionID)
+	q.impressions[impressionID] = &videoImpression{
+		campaignID: campaignID,
+		creativeID: creativeID,
+		position:   position,
+		registered: time.Now(),
+	}
+
+	q.total.Impressions++
+	if campaignID != "" {
+		q.statsFor(q.campaigns, campaignID).Impressions++
+	}
+	if creativeID != "" {
+		q.statsFor(q.creatives, creativeID).Impressions++
+	}
+	if position > 0 {
+		q.positionStats(position).Impressions++
+	}
+	return true
+}
+
+// Record counts a tracking event for an impression. It returns false when
+// the beacon was already counted.
+func (q *QuartileTracker) Record(impressionID, event string) (bool, error) {
+	bit, ok := videoEvents[event]
+	if !ok {
+		return false, ErrUnknownVideoEvent
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	imp, ok := q.impressions[impressionID]
+	if !ok {
+		return false, ErrUnknownImpression
+	}
+	if imp.seen&bit != 0 {
+		return false, nil
+	}
+	imp.seen |= bit
+
+	q.total.add(event)
+	if imp.campaignID != "" {
+		q.statsFor(q.campaigns, imp.campaignID).add(event)
+	}
+	if imp.creativeID != "" {
+		q.statsFor(q.creatives, imp.creativeID).add(event)
+	}
+	if imp.position > 0 {
+		q.positionStats(imp.position).add(event)
+	}
+	return true, nil
+}
+
+// Lookup returns the campaign and creative an impression was registered with
+func (q *QuartileTracker) Lookup(impressionID string) (campaignID, creativeID string, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	imp, ok := q.impressions[impressionID]
+	if !ok {
+		return "", "", false
+	}
+	return imp.campaignID, imp.creativeID, true
+}
+
+// Total returns stats across all impressions
+func (q *QuartileTracker) Total() QuartileStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.total
+}
+
+// Campaign returns stats for one campaign
+func (q *QuartileTracker) Campaign(id string) QuartileStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if s, ok := q.campaigns[id]; ok {
+		return *s
+	}
+	return QuartileStats{}
+}
+
+// Creative returns stats for one creative
+func (q *QuartileTracker) Creative(id string) QuartileStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if s, ok := q.creatives[id]; ok {
+		return *s
+	}
+	return QuartileStats{}
+}
+
+// Position returns stats for one ad pod position, counting from 1
+func (q *QuartileTracker) Position(pos int) QuartileStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if s, ok := q.positions[pos]; ok {
+		return *s
+	}
+	return QuartileStats{}
+}
+
+// PositionVCR is the completion rate at each ad pod position, first
+// position first, up to the furthest seen. A position nothing has played
+// at takes the rate of the one before it.
+func (q *QuartileTracker) PositionVCR() []float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	last := 0
+	for pos := range q.positions {
+		if pos > last {
+			last = pos
+		}
+	}
+	curve := make([]float64, last)
+	for i := range curve {
+		s, ok := q.positions[i+1]
+		switch {
+		case ok && s.Impressions > 0:
+			curve[i] = s.VCR()
+		case i > 0:
+			curve[i] = curve[i-1]
+		}
+	}
+	return curve
+}
+
+// Prune forgets impressions registered before cutoff. Their beacons are
+// still counted in the rollups; late beacons for them are rejected.
+func (q *QuartileTracker) Prune(cutoff time.Time) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pruned := 0
+	for len(q.order) > 0 && q.impressions[q.order[0]].registered.Before(cutoff) {
+		q.forgetOldest()
+		pruned++
+	}
+	return pruned
+}
+
+// Run prunes impressions older than ttl, checking every quarter ttl,
+// until ctx is done
+func (q *QuartileTracker) Run(ctx context.Context, ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n := q.Prune(now.Add(-ttl)); n > 0 {
+				slog.Debug("pruned video impressions", "count", n)
+			}
+		}
+	}
+}
+
+// forgetOldest drops the earliest registered impression
+func (q *QuartileTracker) forgetOldest() {
+	delete(q.impressions, q.order[0])
+	q.order[0] = ""
+	q.order = q.order[1:]
+}
+
+func (q *QuartileTracker) positionStats(pos int) *QuartileStats {
+	s, ok := q.positions[pos]
+	if !ok {
+		s = &QuartileStats{}
+		q.positions[pos] = s
+	}
+	return s
+}
+
+func (q *QuartileTracker) statsFor(m map[string]*QuartileStats, id string) *QuartileStats {
+	s, ok := m[id]
+	if !ok {
+		s = &QuartileStats{}
+		m[id] = s
+	}
+	return s
+}
+
+// TrackVideoEvent records a quartile or skip beacon. Completions are also stored as
+// EventComplete so date-ranged reports can compute VCR, and the running
+// completion rate feeds PodMetrics.
+func (a *AnalyticsTracker) TrackVideoEvent(impressionID, event string) (bool, error) {
+	counted, err := a.Video.Record(impressionID, event)
+	if err != nil || !counted {
+		return counted, err
+	}
+
+	if event == VideoComplete {
+		campaignID, creativeID, _ := a.Video.Lookup(impressionID)
+		a.TotalCompletions.Add(1)
+		if err := a.storage.Store(&Event{
+			Type:         EventComplete,
+			Timestamp:    time.Now(),
+			ImpressionID: impressionID,
+			CampaignID:   campaignID,
+			CreativeID:   creativeID,
+		}); err != nil {
+			return true, err
+		}
+	}
+
+	total := a.Video.Total()
+	if total.Impressions > 0 {
+		a.PodMetrics.PodCompletionRate.Store(total.Completes * 100 / total.Impressions)
+	}
+	return true, nil
+}
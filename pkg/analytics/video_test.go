@@ -0,0 +1,174 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuartileTracker_DropOff(t *testing.T) {
+	q := NewQuartileTracker()
+
+	// Four impressions of one creative: all start, three reach midpoint,
+	// two complete
+	sequences := map[string][]string{
+		"b1": {VideoStart, VideoFirstQuartile, VideoMidpoint, VideoThirdQuartile, VideoComplete},
+		"b2": {VideoStart, VideoFirstQuartile, VideoMidpoint, VideoThirdQuartile, VideoComplete},
+		"b3": {VideoStart, VideoFirstQuartile, VideoMidpoint},
+		"b4": {VideoStart},
+	}
+	for id, events := range sequences {
+		q.Register(id, "camp_1", "cre_1")
+		for _, e := range events {
+			if _, err := q.Record(id, e); err != nil {
+				t.Fatalf("Record(%s, %s): %v", id, e, err)
+			}
+		}
+	}
+
+	stats := q.Creative("cre_1")
+	if stats.Impressions != 4 || stats.Completes != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if got := stats.VCR(); got != 0.5 {
+		t.Errorf("VCR = %v, want 0.5", got)
+	}
+	want := [5]float64{1, 0.75, 0.75, 0.5, 0.5}
+	if got := stats.DropOff(); got != want {
+		t.Errorf("DropOff = %v, want %v", got, want)
+	}
+	if got := q.Campaign("camp_1"); got != stats {
+		t.Errorf("campaign stats = %+v, want %+v", got, stats)
+	}
+}
+
+func TestQuartileTracker_Dedupe(t *testing.T) {
+	q := NewQuartileTracker()
+	q.Register("b1", "camp_1", "cre_1")
+	if q.Register("b1", "camp_1", "cre_1") {
+		t.Error("re-registering an impression should be a no-op")
+	}
+
+	if counted, _ := q.Record("b1", VideoComplete); !counted {
+		t.Error("first complete should count")
+	}
+	if counted, _ := q.Record("b1", VideoComplete); counted {
+		t.Error("repeated complete should not count")
+	}
+	if got := q.Total(); got.Impressions != 1 || got.Completes != 1 {
+		t.Errorf("total = %+v", got)
+	}
+}
+
+func TestQuartileTracker_Validation(t *testing.T) {
+	q := NewQuartileTracker()
+	q.Register("b1", "", "")
+
+	if _, err := q.Record("b1", "halfway"); !errors.Is(err, ErrUnknownVideoEvent) {
+		t.Errorf("err = %v, want %v", err, ErrUnknownVideoEvent)
+	}
+	if _, err := q.Record("missing", VideoStart); !errors.Is(err, ErrUnknownImpression) {
+		t.Errorf("err = %v, want %v", err, ErrUnknownImpression)
+	}
+}
+
+func TestTrackVideoEvent_PodCompletionRate(t *testing.T) {
+	a := NewAnalyticsTracker()
+	a.Video.Register("b1", "camp_1", "cre_1")
+	a.Video.Register("b2", "camp_1", "cre_1")
+
+	for _, e := range []string{VideoStart, VideoComplete, VideoComplete} {
+		if _, err := a.TrackVideoEvent("b1", e); err != nil {
+			t.Fatalf("TrackVideoEvent: %v", err)
+		}
+	}
+
+	if got := a.PodMetrics.PodCompletionRate.Load(); got != 50 {
+		t.Errorf("PodCompletionRate = %d, want 50", got)
+	}
+	if got := a.TotalCompletions.Load(); got != 1 {
+		t.Errorf("TotalCompletions = %d, want 1", got)
+	}
+}
+
+func TestQuartileTracker_PositionVCR(t *testing.T) {
+	q := NewQuartileTracker()
+	plays := []struct {
+		id       string
+		pos      int
+		complete bool
+	}{
+		{"a1", 1, true}, {"a2", 1, true}, {"b1", 2, true}, {"b2", 2, false},
+		{"d1", 4, true}, {"d2", 4, false}, {"d3", 4, false}, {"d4", 4, false},
+		{"solo", 0, true},
+	}
+	for _, p := range plays {
+		q.RegisterAt(p.id, "camp_1", "cre_1", p.pos)
+		if p.complete {
+			q.Record(p.id, VideoComplete)
+		}
+	}
+
+	if got := q.Position(2); got.Impressions != 2 || got.Completes != 1 {
+		t.Errorf("position 2 = %+v", got)
+	}
+	// Nothing played third, so it takes the second position's rate
+	want := []float64{1, 0.5, 0.5, 0.25}
+	got := q.PositionVCR()
+	if len(got) != len(want) {
+		t.Fatalf("PositionVCR = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("PositionVCR = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestQuartileTracker_Bounded(t *testing.T) {
+	q := NewQuartileTracker()
+	q.MaxImpressions = 2
+	for _, id := range []string{"imp-1", "imp-2", "imp-3"} {
+		q.Register(id, "camp", "cr")
+	}
+	if _, err := q.Record("imp-1", VideoStart); !errors.Is(err, ErrUnknownImpression) {
+		t.Errorf("oldest impression: err = %v, want %v", err, ErrUnknownImpression)
+	}
+	for _, id := range []string{"imp-2", "imp-3"} {
+		if ok, err := q.Record(id, VideoStart); !ok || err != nil {
+			t.Errorf("%s: %v, %v", id, ok, err)
+		}
+	}
+	// Forgotten impressions still count in the rollups
+	if s := q.Campaign("camp"); s.Impressions != 3 || s.Starts != 2 {
+		t.Errorf("campaign stats = %+v", s)
+	}
+
+	if n := q.Prune(time.Now().Add(time.Second)); n != 2 {
+		t.Errorf("pruned %d, want 2", n)
+	}
+	if n := q.Prune(time.Now().Add(time.Second)); n != 0 {
+		t.Errorf("pruned %d again, want 0", n)
+	}
+}
+
+func TestQuartileTracker_RunPrunes(t *testing.T) {
+	q := NewQuartileTracker()
+	q.Register("imp-1", "camp", "cr")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, 20*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, _, ok := q.Lookup("imp-1"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("impression not pruned")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,416 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package apikey issues, rotates and revokes the API keys advertisers,
+// publishers and DSPs call the exchange with. Keys carry scopes and a rate
+// limit; only their SHA-256 hashes are kept, so a leaked store can't be
+// replayed. A rotated key keeps working for a grace window beside its
+// replacement, while a revoked one stops at once.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service defaults
+const (
+	DefaultQPS   = 50
+	DefaultGrace = 24 * time.Hour
+)
+
+var (
+	// ErrInvalidKey is returned for a key that's malformed, unknown,
+	// revoked or past its rotation grace window
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrScope is returned for a key without the scope a call needs
+	ErrScope = errors.New("API key lacks scope")
+	// ErrRateLimited is returned once a key has spent its rate limit
+	ErrRateLimited = errors.New("API key rate limit exceeded")
+	// ErrUnknownKey is returned when rotating or revoking a key ID that
+	// isn't live
+	ErrUnknownKey = errors.New("unknown API key")
+	// ErrRotated is returned when rotating a key already replaced
+	ErrRotated = errors.New("API key already rotated")
+	// ErrInvalidSpec is returned when issuing a key without an owner or
+	// scope, or with an unknown one
+	ErrInvalidSpec = errors.New("invalid API key spec")
+)
+
+// Scope is something a key is allowed to do
+type Scope string
+
+const (
+	ScopeCampaignWrite Scope = "campaign-write"
+	ScopeReportRead    Scope = "report-read"
+	ScopeBidSubmit     Scope = "bid-submit"
+	ScopePrivacyAdmin  Scope = "privacy-admin" // Acting on data subject requests for any user
+)
+
+// Scopes lists every scope a key can be issued
+var Scopes = []Scope{ScopeCampaignWrite, ScopeReportRead, ScopeBidSubmit, ScopePrivacyAdmin}
+
+// OwnerType is the kind of account a key acts for
+type OwnerType string
+
+const (
+	OwnerAdvertiser OwnerType = "advertiser"
+	OwnerPublisher  OwnerType = "publisher"
+	OwnerDSP        OwnerType = "dsp"
+)
+
+// Spec is what a key is issued for
+type Spec struct {
+	Owner     string    `json:"owner"`
+	OwnerType OwnerType `json:"owner_type"`
+	Scopes    []Scope   `json:"scopes"`
+	QPS       int       `json:"qps,omitempty"` // DefaultQPS when zero
+}
+
+// Key is an issued key's metadata; the secret itself is only returned
+// when the key is issued
+type Key struct {
+	ID string `json:"id"`
+	Spec
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires,omitzero"`     // End of the grace window once rotated
+	RotatedTo string    `json:"rotated_to,omitempty"` // The replacement's ID
+}
+
+// Has reports whether the key carries scope
+func (k *Key) Has(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// Record is a key as stored: its metadata and the hash of its secret
+type Record struct {
+	Key
+	Hash string `json:"hash"` // Hex SHA-256 of the full key
+}
+
+// Store persists keys across restarts
+type Store interface {
+	Load() ([]Record, error)
+	Save([]Record) error
+}
+
+// Service holds the live keys and authenticates calls made with them
+type Service struct {
+	Store Store // Changes aren't persisted when nil
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]*Record
+	buckets map[string]*bucket
+}
+
+// bucket is a key's token bucket, refilled at its QPS
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewService creates a service with store's keys, or none when store is
+// nil
+func NewService(store Store) (*Service, error) {
+	s := &Service{
+		Store:   store,
+		now:     time.Now,
+		keys:    make(map[string]*Record),
+		buckets: make(map[string]*bucket),
+	}
+	if store == nil {
+		return s, nil
+	}
+	records, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		s.keys[records[i].ID] = &records[i]
+	}
+	return s, nil
+}
+
+// Issue creates a key for spec, returning the key to hand its owner
+func (s *Service) Issue(spec Spec) (string, *Key, error) {
+	if spec.Owner == "" || len(spec.Scopes) == 0 || spec.QPS < 0 {
+		return "", nil, fmt.Errorf("%w: owner and scopes are required", ErrInvalidSpec)
+	}
+	for _, scope := range spec.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", nil, fmt.Errorf("%w: scope %q", ErrInvalidSpec, scope)
+		}
+	}
+	if spec.QPS == 0 {
+		spec.QPS = DefaultQPS
+	}
+	spec.Scopes = slices.Clone(spec.Scopes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, key, err := s.issue(spec)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.save(); err != nil {
+		delete(s.keys, key.ID)
+		return "", nil, err
+	}
+	return token, key, nil
+}
+
+// issue adds a key for spec; s.mu must be held
+func (s *Service) issue(spec Spec) (string, *Key, error) {
+	id, secret := make([]byte, 8), make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	r := &Record{Key: Key{ID: hex.EncodeToString(id), Spec: spec, Created: s.now()}}
+	token := "adx_" + r.ID + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	r.Hash = hashKey(token)
+	s.keys[r.ID] = r
+	key := r.Key
+	return token, &key, nil
+}
+
+// Rotate replaces key id with a new key of the same spec. The old key
+// keeps working until grace has passed, DefaultGrace when zero, so its
+// owner can roll the new one out.
+func (s *Service) Rotate(id string, grace time.Duration) (string, *Key, error) {
+	if grace <= 0 {
+		grace = DefaultGrace
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.live(id)
+	if !ok {
+		return "", nil, ErrUnknownKey
+	}
+	if old.RotatedTo != "" {
+		return "", nil, fmt.Errorf("%w: to %s", ErrRotated, old.RotatedTo)
+	}
+	token, key, err := s.issue(old.Spec)
+	if err != nil {
+		return "", nil, err
+	}
+	prev := *old
+	old.RotatedTo, old.Expires = key.ID, s.now().Add(grace)
+	if err := s.save(); err != nil {
+		*old = prev
+		delete(s.keys, key.ID)
+		return "", nil, err
+	}
+	return token, key, nil
+}
+
+// Revoke deletes key id; calls made with it fail from then on
+func (s *Service) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.live(id)
+	if !ok {
+		return ErrUnknownKey
+	}
+	delete(s.keys, id)
+	delete(s.buckets, id)
+	if err := s.save(); err != nil {
+		s.keys[id] = r
+		return err
+	}
+	return nil
+}
+
+// Get returns key id if it's live
+func (s *Service) Get(id string) (*Key, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.live(id)
+	if !ok {
+		return nil, false
+	}
+	key := r.Key
+	return &key, true
+}
+
+// Keys lists owner's live keys, oldest first
+func (s *Service) Keys(owner string) []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []Key
+	for id, r := range s.keys {
+		if _, ok := s.live(id); ok && r.Owner == owner {
+			keys = append(keys, r.Key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b Key) int { return a.Created.Compare(b.Created) })
+	return keys
+}
+
+// Empty reports whether no key is live, so none can issue others
+func (s *Service) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.keys {
+		if _, ok := s.live(id); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Authenticate returns the key token is, spending one call of its rate
+// limit
+func (s *Service) Authenticate(token string) (*Key, error) {
+	id, ok := keyID(token)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.live(id)
+	if !ok || subtle.ConstantTimeCompare([]byte(hashKey(token)), []byte(r.Hash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if !s.allow(r) {
+		return nil, ErrRateLimited
+	}
+	key := r.Key
+	return &key, nil
+}
+
+// Authorize authenticates token and checks it carries scope
+func (s *Service) Authorize(token string, scope Scope) (*Key, error) {
+	key, err := s.Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+	if !key.Has(scope) {
+		return nil, fmt.Errorf("%w: %s", ErrScope, scope)
+	}
+	return key, nil
+}
+
+// live returns key id unless it's unknown or past its grace window,
+// forgetting it in that case; s.mu must be held
+func (s *Service) live(id string) (*Record, bool) {
+	r, ok := s.keys[id]
+	if !ok {
+		return nil, false
+	}
+	if !r.Expires.IsZero() && !s.now().Before(r.Expires) {
+		delete(s.keys, id)
+		delete(s.buckets, id)
+		return nil, false
+	}
+	return r, true
+}
+
+// allow takes a token from r's bucket; s.mu must be held
+func (s *Service) allow(r *Record) bool {
+	now := s.now()
+	qps := float64(r.QPS)
+	b, ok := s.buckets[r.ID]
+	if !ok {
+		b = &bucket{tokens: qps, last: now}
+		s.buckets[r.ID] = b
+	}
+	b.tokens = min(qps, b.tokens+now.Sub(b.last).Seconds()*qps)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// save persists the keys; s.mu must be held
+func (s *Service) save() error {
+	if s.Store == nil {
+		return nil
+	}
+	records := make([]Record, 0, len(s.keys))
+	for _, r := range s.keys {
+		records = append(records, *r)
+	}
+	slices.SortFunc(records, func(a, b Record) int { return strings.Compare(a.ID, b.ID) })
+	return s.Store.Save(records)
+}
+
+// keyID reads the ID out of an adx_<id>_<secret> key
+func keyID(token string) (string, bool) {
+	rest, ok := strings.CutPrefix(token, "adx_")
+	if !ok {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	return id, ok && id != "" && secret != ""
+}
+
+func hashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// File persists keys as JSON
+type File struct {
+	Path string
+}
+
+// Load reads the keys; a missing file holds none
+func (f File) Load() ([]Record, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	return records, nil
+}
+
+// Save writes the keys, replacing the file atomically so a crash never
+// leaves it half written. The file is readable by its owner alone.
+func (f File) Save(records []Record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
@@ -0,0 +1,201 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testService(t *testing.T) (*Service, *time.Time) {
+	t.Helper()
+	s, err := NewService(File{Path: filepath.Join(t.TempDir(), "keys.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func issue(t *testing.T, s *Service, spec Spec) (string, *Key) {
+	t.Helper()
+	token, key, err := s.Issue(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, key
+}
+
+// call makes a request with token to a route requiring scope
+func call(s *Service, scope Scope, token string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", s.Require(scope), func(c *gin.Context) {
+		if _, ok := FromContext(c); !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestService_Scopes(t *testing.T) {
+	s, _ := testService(t)
+	token, key := issue(t, s, Spec{Owner: "pub-1", OwnerType: OwnerPublisher, Scopes: []Scope{ScopeReportRead}})
+
+	if code := call(s, ScopeReportRead, token); code != http.StatusOK {
+		t.Errorf("report-read = %d, want 200", code)
+	}
+	for _, scope := range []Scope{ScopeCampaignWrite, ScopeBidSubmit} {
+		if code := call(s, scope, token); code != http.StatusForbidden {
+			t.Errorf("%s = %d, want 403", scope, code)
+		}
+	}
+	forged := token[:len(token)-2] + "xx"
+	for _, bad := range []string{"", "nonsense", forged, "adx_" + key.ID + "_"} {
+		if code := call(s, ScopeReportRead, bad); code != http.StatusUnauthorized {
+			t.Errorf("key %q = %d, want 401", bad, code)
+		}
+	}
+
+	// Also accepted in X-API-Key
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(Header, token)
+	if Token(req) != token {
+		t.Error("X-API-Key not read")
+	}
+
+	for _, spec := range []Spec{
+		{Scopes: []Scope{ScopeReportRead}},
+		{Owner: "pub-1"},
+		{Owner: "pub-1", Scopes: []Scope{"admin"}},
+	} {
+		if _, _, err := s.Issue(spec); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("issue %+v: %v", spec, err)
+		}
+	}
+}
+
+func TestService_RateLimit(t *testing.T) {
+	s, now := testService(t)
+	token, _ := issue(t, s, Spec{Owner: "dsp-1", OwnerType: OwnerDSP, Scopes: []Scope{ScopeBidSubmit}, QPS: 2})
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := call(s, ScopeBidSubmit, token); code != want {
+			t.Errorf("call %d = %d, want %d", i, code, want)
+		}
+	}
+	*now = now.Add(time.Second)
+	if code := call(s, ScopeBidSubmit, token); code != http.StatusOK {
+		t.Errorf("after refill = %d", code)
+	}
+}
+
+func TestService_RotationGrace(t *testing.T) {
+	s, now := testService(t)
+	old, key := issue(t, s, Spec{Owner: "adv-1", OwnerType: OwnerAdvertiser, Scopes: []Scope{ScopeCampaignWrite, ScopeReportRead}})
+
+	*now = now.Add(time.Minute)
+	next, rotated, err := s.Rotate(key.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Owner != "adv-1" || len(rotated.Scopes) != 2 || rotated.ID == key.ID {
+		t.Errorf("rotated key = %+v", rotated)
+	}
+	if _, _, err := s.Rotate(key.ID, time.Hour); !errors.Is(err, ErrRotated) {
+		t.Errorf("second rotation: %v", err)
+	}
+
+	// Both work through the grace window
+	*now = now.Add(59 * time.Minute)
+	for name, token := range map[string]string{"old": old, "new": next} {
+		if _, err := s.Authorize(token, ScopeCampaignWrite); err != nil {
+			t.Errorf("%s key in grace window: %v", name, err)
+		}
+	}
+	if keys := s.Keys("adv-1"); len(keys) != 2 || keys[0].RotatedTo != rotated.ID {
+		t.Errorf("keys = %+v", keys)
+	}
+
+	// Then only the new one
+	*now = now.Add(time.Minute)
+	if _, err := s.Authenticate(old); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("old key after grace: %v", err)
+	}
+	if _, err := s.Authenticate(next); err != nil {
+		t.Errorf("new key after grace: %v", err)
+	}
+	if keys := s.Keys("adv-1"); len(keys) != 1 || keys[0].ID != rotated.ID {
+		t.Errorf("keys after grace = %+v", keys)
+	}
+}
+
+func TestService_Revoke(t *testing.T) {
+	s, _ := testService(t)
+	token, key := issue(t, s, Spec{Owner: "dsp-1", OwnerType: OwnerDSP, Scopes: []Scope{ScopeBidSubmit}})
+	if code := call(s, ScopeBidSubmit, token); code != http.StatusOK {
+		t.Fatalf("before revocation = %d", code)
+	}
+	if err := s.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := call(s, ScopeBidSubmit, token); code != http.StatusUnauthorized {
+		t.Errorf("revoked key = %d, want 401", code)
+	}
+	if err := s.Revoke(key.ID); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("second revocation: %v", err)
+	}
+
+	// Revoking a rotated key doesn't wait out its grace window
+	old, key := issue(t, s, Spec{Owner: "dsp-1", Scopes: []Scope{ScopeBidSubmit}})
+	if _, _, err := s.Rotate(key.ID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(old); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("revoked key in grace window: %v", err)
+	}
+}
+
+func TestService_StoresHashes(t *testing.T) {
+	s, _ := testService(t)
+	token, key := issue(t, s, Spec{Owner: "pub-1", Scopes: []Scope{ScopeReportRead}})
+
+	path := s.Store.(File).Path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := strings.SplitN(token, "_", 3)[2]
+	if strings.Contains(string(data), secret) || !strings.Contains(string(data), hashKey(token)) {
+		t.Errorf("stored keys = %s", data)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, %v", fi.Mode(), err)
+	}
+
+	reloaded, err := NewService(File{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reloaded.Authorize(token, ScopeReportRead); err != nil || got.ID != key.ID {
+		t.Errorf("reloaded key = %+v, %v", got, err)
+	}
+}
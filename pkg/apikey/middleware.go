@@ -0,0 +1,55 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries a key when the Authorization header can't
+const Header = "X-API-Key"
+
+// contextKey is where Require leaves the caller's key on the gin context
+const contextKey = "apikey"
+
+// Token reads the key a request presents, as a bearer token or in Header
+func Token(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.Header.Get(Header)
+}
+
+// Require admits requests whose key carries scope, answering 401 for a
+// missing or invalid key, 403 for one without the scope and 429 once its
+// rate limit is spent
+func (s *Service) Require(scope Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := s.Authorize(Token(c.Request), scope)
+		switch {
+		case errors.Is(err, ErrRateLimited):
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrScope):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case err != nil:
+			c.Header("WWW-Authenticate", `Bearer realm="adx-api"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.Set(contextKey, key)
+			c.Next()
+		}
+	}
+}
+
+// FromContext returns the key Require admitted the request with
+func FromContext(c *gin.Context) (*Key, bool) {
+	v, ok := c.Get(contextKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := v.(*Key)
+	return key, ok
+}
@@ -4,10 +4,12 @@
 package auction
 
 import (
+	"encoding/json"
 	"errors"
 	"sort"
 	"time"
 
+	"github.com/luxfi/adx/pkg/auction/tiebreak"
 	"github.com/luxfi/adx/pkg/core"
 	"github.com/luxfi/adx/pkg/crypto"
 	"github.com/luxfi/adx/pkg/ids"
@@ -19,6 +21,7 @@ var (
 	ErrNoValidBids      = errors.New("no valid bids")
 	ErrAuctionClosed    = errors.New("auction closed")
 	ErrInvalidThreshold = errors.New("viewability threshold not met")
+	ErrNoOutcome        = errors.New("auction has no outcome")
 )
 
 // Auction represents a second-price sealed-bid auction
@@ -50,6 +53,18 @@ type AuctionOutcome struct {
 	ProofCorrect  []byte // ZK proof of correct auction execution
 }
 
+// AuctionTranscript is the record of a closed auction kept in the DA layer:
+// the bids as they were sealed and the outcome with its proof
+type AuctionTranscript struct {
+	AuctionID  ids.ID          `json:"auction_id"`
+	StartTime  time.Time       `json:"start_time"`
+	EndTime    time.Time       `json:"end_time"`
+	Reserve    uint64          `json:"reserve"`
+	PolicyRoot []byte          `json:"policy_root"`
+	Bids       []*SealedBid    `json:"bids"`
+	Outcome    *AuctionOutcome `json:"outcome"`
+}
+
 // NewAuction creates a new auction instance
 func NewAuction(id ids.ID, reserve uint64, duration time.Duration, logger log.Logger) *Auction {
 	now := time.Now()
@@ -84,38 +99,63 @@ func (a *Auction) SubmitBid(bid *SealedBid) error {
 
 // RunAuction executes the auction and determines the winner
 func (a *Auction) RunAuction(decryptionKey []byte) (*AuctionOutcome, error) {
+	outcome, _, err := a.run(decryptionKey)
+	return outcome, err
+}
+
+// enclaveResult is what the auction saw once bids were decrypted: every
+// sealed bid's value, in submission order, and where the winner is
+type enclaveResult struct {
+	bids   []*DecryptedBid // nil where decryption failed
+	winner int
+}
+
+// run decrypts the bids, ranks those meeting the reserve and prices the
+// winner at the runner-up's bid, or the reserve when there is none
+func (a *Auction) run(decryptionKey []byte) (*AuctionOutcome, *enclaveResult, error) {
 	if len(a.Bids) == 0 {
-		return nil, ErrNoValidBids
+		return nil, nil, ErrNoValidBids
 	}
 
 	// Decrypt and validate bids
-	validBids := make([]*DecryptedBid, 0)
+	result := &enclaveResult{bids: make([]*DecryptedBid, len(a.Bids))}
+	valid := make([]int, 0)
 	hpke := crypto.NewHPKE()
 
-	for _, sealedBid := range a.Bids {
+	for i, sealedBid := range a.Bids {
 		// In production, this would be done in a TEE or with MPC
 		decrypted, err := a.decryptBid(sealedBid, decryptionKey, hpke)
 		if err != nil {
 			a.log.Debug("Failed to decrypt bid")
 			continue
 		}
+		result.bids[i] = decrypted
 
 		if decrypted.Value >= a.Reserve {
-			validBids = append(validBids, decrypted)
+			valid = append(valid, i)
 		}
 	}
 
-	if len(validBids) == 0 {
-		return nil, ErrNoValidBids
+	if len(valid) == 0 {
+		return nil, nil, ErrNoValidBids
 	}
 
-	// Sort bids by value (descending)
-	sort.Slice(validBids, func(i, j int) bool {
-		return validBids[i].Value > validBids[j].Value
+	// Sort bids by value (descending), ties in tiebreak order
+	sort.Slice(valid, func(i, j int) bool {
+		a, b := result.bids[valid[i]], result.bids[valid[j]]
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		return tiebreak.Compare(a.BidderID[:], a.BidID, b.BidderID[:], b.BidID) < 0
 	})
+	validBids := make([]*DecryptedBid, len(valid))
+	for i, idx := range valid {
+		validBids[i] = result.bids[idx]
+	}
 
 	// Determine winner and second price
 	winner := validBids[0]
+	result.winner = valid[0]
 	clearingPrice := a.Reserve
 
 	if len(validBids) > 1 {
@@ -135,15 +175,17 @@ func (a *Auction) RunAuction(decryptionKey []byte) (*AuctionOutcome, error) {
 	a.Outcome = outcome
 	a.log.Info("Auction finalized")
 
-	return outcome, nil
+	return outcome, result, nil
 }
 
 // DecryptedBid represents a decrypted bid
 type DecryptedBid struct {
 	BidderID   ids.ID
+	BidID      []byte // The sealed bid's commitment, which identifies it
 	Value      uint64
 	CreativeID ids.ID
 	Targeting  []byte
+	Timestamp  time.Time
 }
 
 // decryptBid decrypts a sealed bid (in production, done in TEE)
@@ -163,8 +205,10 @@ func (a *Auction) decryptBid(sealed *SealedBid, key []byte, hpke crypto.HPKE) (*
 
 	return &DecryptedBid{
 		BidderID:   sealed.BidderID,
+		BidID:      sealed.Commitment,
 		Value:      value % 10000, // Simplified for testing
 		CreativeID: ids.GenerateTestID(),
+		Timestamp:  sealed.Timestamp,
 	}, nil
 }
 
@@ -194,6 +238,23 @@ func (a *Auction) generateAuctionProof(bids []*DecryptedBid, winner *DecryptedBi
 	return proof
 }
 
+// Transcript encodes the closed auction's transcript
+func (a *Auction) Transcript() ([]byte, error) {
+	if a.Outcome == nil {
+		return nil, ErrNoOutcome
+	}
+
+	return json.Marshal(&AuctionTranscript{
+		AuctionID:  a.ID,
+		StartTime:  a.StartTime,
+		EndTime:    a.EndTime,
+		Reserve:    a.Reserve,
+		PolicyRoot: a.PolicyRoot,
+		Bids:       a.Bids,
+		Outcome:    a.Outcome,
+	})
+}
+
 // CreateAuctionHeader creates a header for the auction outcome
 func (a *Auction) CreateAuctionHeader() *core.AuctionHeader {
 	if a.Outcome == nil {
@@ -222,10 +283,11 @@ func (a *Auction) CreateAuctionHeader() *core.AuctionHeader {
 			Timestamp: time.Now(),
 			Height:    1,
 		},
-		AuctionID:    a.ID,
-		CmInputs:     cmInputs,
-		CmWinner:     cmWinner,
-		ProofAuction: a.Outcome.ProofCorrect,
-		PolicyRoot:   a.PolicyRoot,
+		AuctionID:     a.ID,
+		CmInputs:      cmInputs,
+		CmWinner:      cmWinner,
+		ClearingPrice: a.Outcome.ClearingPrice,
+		ProofAuction:  a.Outcome.ProofCorrect,
+		PolicyRoot:    a.PolicyRoot,
 	}
 }
@@ -17,6 +17,7 @@ import (
 var (
 	ErrCircuitNotSetup = errors.New("circuit not initialized")
 	ErrProofGenFailed  = errors.New("proof generation failed")
+	ErrTooManyBids     = errors.New("more bids than the circuit proves")
 )
 
 // Halo2Auction represents an auction with Halo2 ZK proofs
@@ -72,13 +73,13 @@ func (ha *Halo2Auction) RunAuctionWithHalo2(decryptionKey []byte) (*Halo2Auction
 	defer ha.mu.Unlock()
 
 	// Run base auction to get outcome
-	outcome, err := ha.RunAuction(decryptionKey)
+	outcome, result, err := ha.run(decryptionKey)
 	if err != nil {
 		return nil, err
 	}
 
 	// Prepare witness for Halo2 proof
-	witness, err := ha.prepareWitness(outcome)
+	witness, err := ha.prepareWitness(outcome, result)
 	if err != nil {
 		return nil, err
 	}
@@ -94,13 +95,9 @@ func (ha *Halo2Auction) RunAuctionWithHalo2(decryptionKey []byte) (*Halo2Auction
 	proofID := ids.GenerateTestID()
 	ha.proofs[proofID] = proof
 
-	// Create public inputs for verification
-	publicInputs := &halo2.AuctionPublicInputs{
-		NumBids:       len(ha.Bids),
-		Reserve:       ha.Reserve,
-		ClearingPrice: outcome.ClearingPrice,
-		WinnerCommit:  proof.WitnessCommitments[len(ha.Bids)],
-	}
+	// Public inputs come from the served outcome, so the proof only
+	// verifies if it attests to the same winner and price
+	publicInputs := ha.PublicInputs(result.winner, outcome)
 
 	// Verify proof
 	valid := ha.circuit.Verify(ha.vk, publicInputs, proof)
@@ -113,34 +110,33 @@ func (ha *Halo2Auction) RunAuctionWithHalo2(decryptionKey []byte) (*Halo2Auction
 
 	return &Halo2AuctionOutcome{
 		AuctionOutcome: *outcome,
+		WinnerIndex:    result.winner,
 		Halo2Proof:     proof,
 		ProofID:        proofID,
 		VerifyingKey:   ha.vk,
 	}, nil
 }
 
-// prepareWitness converts auction data to Halo2 witness format
-func (ha *Halo2Auction) prepareWitness(outcome *AuctionOutcome) (*halo2.AuctionWitness, error) {
-	// Decrypt all bids
-	decryptedBids := make([]*big.Int, 0, len(ha.Bids))
-	winnerIndex := -1
-	secondHighest := big.NewInt(0)
-
-	for i, sealedBid := range ha.Bids {
-		// In production, decrypt actual bid values
-		// For now, use simulated values
-		bidValue := big.NewInt(int64(100 + i*50))
-		decryptedBids = append(decryptedBids, bidValue)
+// prepareWitness converts auction data to Halo2 witness format: the
+// decrypted bids in submission order, zero where decryption failed, and
+// the winner the auction picked, ties included
+func (ha *Halo2Auction) prepareWitness(outcome *AuctionOutcome, result *enclaveResult) (*halo2.AuctionWitness, error) {
+	if len(result.bids) > ha.circuit.NumBids {
+		return nil, ErrTooManyBids
+	}
 
-		if sealedBid.BidderID == outcome.WinnerID {
-			winnerIndex = i
+	decryptedBids := make([]*big.Int, 0, ha.circuit.NumBids)
+	secondHighest := big.NewInt(0)
+	for i, bid := range result.bids {
+		bidValue := big.NewInt(0)
+		if bid != nil {
+			bidValue.SetUint64(bid.Value)
 		}
+		decryptedBids = append(decryptedBids, bidValue)
 
-		// Track second highest
-		if bidValue.Cmp(big.NewInt(int64(outcome.WinningBid))) < 0 {
-			if bidValue.Cmp(secondHighest) > 0 {
-				secondHighest = bidValue
-			}
+		// The runner-up may have tied the winner
+		if i != result.winner && bidValue.Cmp(secondHighest) > 0 {
+			secondHighest = bidValue
 		}
 	}
 
@@ -149,20 +145,26 @@ func (ha *Halo2Auction) prepareWitness(outcome *AuctionOutcome) (*halo2.AuctionW
 		decryptedBids = append(decryptedBids, big.NewInt(0))
 	}
 
-	// If second highest is less than reserve, use reserve
-	if secondHighest.Cmp(big.NewInt(int64(ha.Reserve))) < 0 {
-		secondHighest = big.NewInt(int64(ha.Reserve))
-	}
-
 	return &halo2.AuctionWitness{
 		Bids:          decryptedBids,
-		WinnerIndex:   winnerIndex,
-		WinningBid:    big.NewInt(int64(outcome.WinningBid)),
+		WinnerIndex:   result.winner,
+		WinningBid:    new(big.Int).SetUint64(outcome.WinningBid),
 		SecondPrice:   secondHighest,
-		ClearingPrice: big.NewInt(int64(outcome.ClearingPrice)),
+		ClearingPrice: new(big.Int).SetUint64(outcome.ClearingPrice),
 	}, nil
 }
 
+// PublicInputs are what a proof of the auction is verified against: the
+// winner at winnerIndex among the sealed bids, and the outcome's price
+func (ha *Halo2Auction) PublicInputs(winnerIndex int, outcome *AuctionOutcome) *halo2.AuctionPublicInputs {
+	return &halo2.AuctionPublicInputs{
+		NumBids:       len(ha.Bids),
+		Reserve:       ha.Reserve,
+		ClearingPrice: outcome.ClearingPrice,
+		WinnerCommit:  ha.circuit.WinnerCommitment(winnerIndex, new(big.Int).SetUint64(outcome.WinningBid)),
+	}
+}
+
 // VerifyHalo2Proof verifies a Halo2 auction proof
 func (ha *Halo2Auction) VerifyHalo2Proof(
 	proofID ids.ID,
@@ -188,6 +190,7 @@ func (ha *Halo2Auction) GetVerifyingKey() *halo2.VerifyingKey {
 // Halo2AuctionOutcome represents auction outcome with Halo2 proof
 type Halo2AuctionOutcome struct {
 	AuctionOutcome
+	WinnerIndex  int // Winner's position among the sealed bids
 	Halo2Proof   *halo2.Halo2Proof
 	ProofID      ids.ID
 	VerifyingKey *halo2.VerifyingKey
@@ -4,10 +4,12 @@
 package auction
 
 import (
+	"encoding/binary"
 	"math/big"
 	"testing"
 	"time"
 
+	"github.com/luxfi/adx/pkg/auction/tiebreak"
 	"github.com/luxfi/adx/pkg/ids"
 	"github.com/luxfi/adx/pkg/log"
 	"github.com/luxfi/adx/pkg/proof/halo2"
@@ -56,7 +58,7 @@ func TestHalo2Auction(t *testing.T) {
 		NumBids:       5,
 		Reserve:       reserve,
 		ClearingPrice: outcome.ClearingPrice,
-		WinnerCommit:  outcome.Halo2Proof.WitnessCommitments[5], // After 5 bid commitments
+		WinnerCommit:  auction.circuit.WinnerCommitment(outcome.WinnerIndex, new(big.Int).SetUint64(outcome.WinningBid)),
 	}
 
 	valid := auction.VerifyHalo2Proof(outcome.ProofID, publicInputs)
@@ -68,6 +70,108 @@ func TestHalo2Auction(t *testing.T) {
 	require.False(valid)
 }
 
+// valueBid seals a bid the auction decrypts to value
+func valueBid(value uint64) *SealedBid {
+	commitment := make([]byte, 8)
+	binary.BigEndian.PutUint64(commitment, value)
+	return &SealedBid{BidderID: ids.GenerateTestID(), Commitment: commitment, Timestamp: time.Now()}
+}
+
+func TestHalo2Auction_ProofMatchesServedAuction(t *testing.T) {
+	require := require.New(t)
+
+	tests := []struct {
+		name     string
+		values   []uint64
+		winners  []int // Indexes tied for the win
+		clearing uint64
+	}{
+		{"second price", []uint64{300, 450, 50, 200}, []int{1}, 300},
+		{"tie", []uint64{300, 500, 500, 50}, []int{1, 2}, 500},
+		{"reserve", []uint64{250, 50}, []int{0}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auction, err := NewHalo2Auction(ids.GenerateTestID(), 100, time.Minute, log.NoOp())
+			require.NoError(err)
+			for _, v := range tt.values {
+				require.NoError(auction.SubmitBid(valueBid(v)))
+			}
+
+			outcome, err := auction.RunAuctionWithHalo2([]byte("key"))
+			require.NoError(err)
+
+			// The proof attests to the served winner and price
+			winner := outcome.WinnerIndex
+			require.Contains(tt.winners, winner)
+			if len(tt.winners) > 1 {
+				other := auction.Bids[tt.winners[0]+tt.winners[1]-winner]
+				require.Negative(tiebreak.Compare(auction.Bids[winner].BidderID[:], auction.Bids[winner].Commitment, other.BidderID[:], other.Commitment))
+			}
+			require.Equal(auction.Bids[winner].BidderID, outcome.WinnerID)
+			require.Equal(tt.values[winner], outcome.WinningBid)
+			require.Equal(tt.clearing, outcome.ClearingPrice)
+			require.Equal(new(big.Int).SetUint64(outcome.WinningBid), outcome.Halo2Proof.Evaluations["winner_bid"])
+			require.Equal(new(big.Int).SetUint64(outcome.ClearingPrice), outcome.Halo2Proof.Evaluations["clearing_price"])
+			require.True(auction.VerifyHalo2Proof(outcome.ProofID, auction.PublicInputs(winner, &outcome.AuctionOutcome)))
+
+			// Claiming another bidder won fails verification, even one that
+			// tied
+			for i, v := range tt.values {
+				if i == winner {
+					continue
+				}
+				swapped := outcome.AuctionOutcome
+				swapped.WinnerID, swapped.WinningBid = auction.Bids[i].BidderID, v
+				require.False(auction.VerifyHalo2Proof(outcome.ProofID, auction.PublicInputs(i, &swapped)), "bid %d", i)
+			}
+
+			// Nor can a proof be made naming an outbid bidder the winner
+			_, result, err := auction.run([]byte("key"))
+			require.NoError(err)
+			for i, v := range tt.values {
+				if v == outcome.WinningBid {
+					continue
+				}
+				result.winner = i
+				swapped := outcome.AuctionOutcome
+				swapped.WinningBid = v
+				witness, err := auction.prepareWitness(&swapped, result)
+				require.NoError(err)
+				_, err = auction.circuit.Prove(auction.pk, witness)
+				require.ErrorIs(err, halo2.ErrProvingFailed, "bid %d", i)
+			}
+		})
+	}
+}
+
+func TestHalo2Auction_SameBidderTie(t *testing.T) {
+	require := require.New(t)
+
+	// One bidder's two bids at the same value; the commitments differ past
+	// the value, and sha256 of the later one's sorts lower
+	bidder := ids.GenerateTestID()
+	now := time.Now()
+	early := &SealedBid{BidderID: bidder, Commitment: []byte{0, 0, 0, 0, 0, 0, 1, 244, 'b'}, Timestamp: now}
+	late := &SealedBid{BidderID: bidder, Commitment: []byte{0, 0, 0, 0, 0, 0, 1, 244, 'b', '-', '2'}, Timestamp: now.Add(time.Second)}
+	require.Negative(tiebreak.Compare(bidder[:], late.Commitment, bidder[:], early.Commitment))
+
+	for _, bids := range [][]*SealedBid{{early, late}, {late, early}} {
+		auction, err := NewHalo2Auction(ids.GenerateTestID(), 100, time.Minute, log.NoOp())
+		require.NoError(err)
+		for _, bid := range bids {
+			require.NoError(auction.SubmitBid(bid))
+		}
+		outcome, err := auction.RunAuctionWithHalo2([]byte("key"))
+		require.NoError(err)
+
+		// The served winner and the proof's witness agree on the bid
+		require.Same(late, auction.Bids[outcome.WinnerIndex])
+		require.Equal(uint64(500), outcome.WinningBid)
+		require.True(auction.VerifyHalo2Proof(outcome.ProofID, auction.PublicInputs(outcome.WinnerIndex, &outcome.AuctionOutcome)))
+	}
+}
+
 func TestHalo2BudgetManager(t *testing.T) {
 	require := require.New(t)
 	logger := log.NoOp()
@@ -0,0 +1,37 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package tiebreak orders equal-price bids deterministically so auction
+// outcomes can be reproduced by proofs and audits.
+//
+// Among bids at the same price, the bid whose bidder ID has the lowest
+// SHA-256 hash wins. If the hashes are equal (the same bidder bid twice),
+// the bid whose bid ID has the lowest hash wins. Hashing keeps the order
+// independent of how IDs are assigned, and of when or in what order bids
+// arrived, while still being reproducible by anyone.
+package tiebreak
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Key returns the value bidders are ordered by on a price tie
+func Key(bidderID []byte) [32]byte {
+	return sha256.Sum256(bidderID)
+}
+
+// Compare orders two equal-price bids, each given by its bidder ID and
+// bid ID. It returns a negative number when a wins, positive when b wins
+// and zero when they are the same bid.
+func Compare(aBidder, aBid, bBidder, bBid []byte) int {
+	ka, kb := Key(aBidder), Key(bBidder)
+	if c := bytes.Compare(ka[:], kb[:]); c != 0 {
+		return c
+	}
+	ka, kb = Key(aBid), Key(bBid)
+	if c := bytes.Compare(ka[:], kb[:]); c != 0 {
+		return c
+	}
+	return bytes.Compare(aBid, bBid)
+}
@@ -0,0 +1,27 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tiebreak
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	// sha256("dsp-b") sorts below sha256("dsp-a"), whatever the bid IDs
+	if c := Compare([]byte("dsp-b"), []byte("b-2"), []byte("dsp-a"), []byte("b")); c >= 0 {
+		t.Errorf("Compare(dsp-b, dsp-a) = %d, want dsp-b to win on hash", c)
+	}
+	if c := Compare([]byte("dsp-a"), []byte("b"), []byte("dsp-b"), []byte("b-2")); c <= 0 {
+		t.Errorf("Compare(dsp-a, dsp-b) = %d, want dsp-b to win on hash", c)
+	}
+
+	// Same bidder: sha256("b") sorts below sha256("b-2")
+	if c := Compare([]byte("dsp-a"), []byte("b"), []byte("dsp-a"), []byte("b-2")); c >= 0 {
+		t.Errorf("Compare(b, b-2) = %d, want b to win on hash", c)
+	}
+	if c := Compare([]byte("dsp-a"), []byte("b-2"), []byte("dsp-a"), []byte("b")); c <= 0 {
+		t.Errorf("Compare(b-2, b) = %d, want b to win on hash", c)
+	}
+	if c := Compare([]byte("dsp-a"), []byte("b"), []byte("dsp-a"), []byte("b")); c != 0 {
+		t.Errorf("the same bid should compare equal, got %d", c)
+	}
+}
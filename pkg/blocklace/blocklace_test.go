@@ -165,6 +165,46 @@ func TestDAGReorganization(t *testing.T) {
 	require.Greater(len(sequence), 10)
 }
 
+func TestCommitAuctionOutcome(t *testing.T) {
+	require := require.New(t)
+	logger := log.NoOp()
+
+	dag := NewDAG(logger)
+	miner := NewCordialMiner(ids.GenerateNodeID(), dag, logger)
+
+	// An unrelated header already in the DAG is referenced as a predecessor
+	_, err := miner.ProposeHeader(core.HeaderTypeSettlement, nil)
+	require.NoError(err)
+
+	auctionID := ids.GenerateTestID()
+	v, err := miner.CommitAuctionOutcome(&core.AuctionHeader{
+		AuctionID:     auctionID,
+		CmWinner:      []byte("winner"),
+		ClearingPrice: 3000,
+		ProofAuction:  []byte("proof"),
+	})
+	require.NoError(err)
+	require.True(v.Delivered)
+	require.Len(v.Predecessors, 1)
+	require.Len(v.PayloadHash, 32)
+
+	got, ok := dag.GetAuctionVertex(auctionID)
+	require.True(ok)
+	require.Equal(v, got)
+	require.Equal(core.HeaderTypeAuction, got.Header.Type)
+	require.Equal(uint64(3000), got.Auction.ClearingPrice)
+	require.Equal([]byte("winner"), got.Auction.CmWinner)
+	require.Equal(2, dag.GetMetrics().Committed)
+
+	// An auction's outcome is committed once
+	_, err = miner.CommitAuctionOutcome(&core.AuctionHeader{AuctionID: auctionID, ClearingPrice: 1})
+	require.ErrorIs(err, ErrOutcomeCommitted)
+	require.Equal(2, dag.GetMetrics().Committed)
+
+	_, ok = dag.GetAuctionVertex(ids.GenerateTestID())
+	require.False(ok)
+}
+
 func BenchmarkDAGAddHeader(b *testing.B) {
 	logger := log.NoOp()
 	dag := NewDAG(logger)
@@ -37,6 +37,9 @@ type DAG struct {
 	sequence  []*Vertex // Total order
 	delivered map[ids.ID]bool
 
+	// Auction outcomes by auction ID
+	auctions map[ids.ID]*Vertex
+
 	// Metrics
 	height uint64
 	width  int
@@ -58,6 +61,9 @@ type Vertex struct {
 	// Payload reference
 	PayloadHash []byte
 	PayloadPtr  []byte // DA layer pointer
+
+	// Auction is the committed outcome for auction vertices
+	Auction *core.AuctionHeader
 }
 
 // NewDAG creates a new Blocklace DAG
@@ -69,6 +75,7 @@ func NewDAG(logger log.Logger) *DAG {
 		byzantine:     make(map[ids.NodeID]bool),
 		sequence:      make([]*Vertex, 0),
 		delivered:     make(map[ids.ID]bool),
+		auctions:      make(map[ids.ID]*Vertex),
 		log:           logger,
 	}
 
@@ -96,9 +103,15 @@ func (d *DAG) AddHeader(header *core.BaseHeader, author ids.NodeID) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	_, err := d.addHeader(header, author)
+	return err
+}
+
+// addHeader adds a header and returns its vertex. d.mu must be held.
+func (d *DAG) addHeader(header *core.BaseHeader, author ids.NodeID) (*Vertex, error) {
 	// Check for duplicate
-	if _, exists := d.vertices[header.ID]; exists {
-		return nil // Already have it
+	if v, exists := d.vertices[header.ID]; exists {
+		return v, nil // Already have it
 	}
 
 	// Verify predecessors
@@ -106,7 +119,7 @@ func (d *DAG) AddHeader(header *core.BaseHeader, author ids.NodeID) error {
 	for _, predID := range header.Predecessors {
 		pred, exists := d.vertices[predID]
 		if !exists {
-			return ErrInvalidPredecessor
+			return nil, ErrInvalidPredecessor
 		}
 		predecessors = append(predecessors, pred)
 	}
@@ -123,7 +136,7 @@ func (d *DAG) AddHeader(header *core.BaseHeader, author ids.NodeID) error {
 	// Check for equivocation
 	if d.detectEquivocation(vertex) {
 		d.handleEquivocation(vertex)
-		return ErrEquivocation
+		return nil, ErrEquivocation
 	}
 
 	// Add to graph
@@ -142,7 +155,7 @@ func (d *DAG) AddHeader(header *core.BaseHeader, author ids.NodeID) error {
 
 	d.log.Debug("Vertex added")
 
-	return nil
+	return vertex, nil
 }
 
 // detectEquivocation checks if a vertex represents equivocation
@@ -274,6 +287,7 @@ func (d *DAG) GetMetrics() DAGMetrics {
 		Height:        d.height,
 		Width:         d.width,
 		Delivered:     len(d.sequence),
+		Committed:     len(d.sequence) - 1, // Excluding genesis
 		Byzantine:     len(d.byzantine),
 		Equivocations: len(d.equivocations),
 	}
@@ -286,6 +300,7 @@ type DAGMetrics struct {
 	Height        uint64
 	Width         int
 	Delivered     int
+	Committed     int
 	Byzantine     int
 	Equivocations int
 }
@@ -295,7 +310,8 @@ type CordialMiner struct {
 	ID  ids.NodeID
 	DAG *DAG
 
-	// Local state
+	// Local state; mu serializes proposals so each takes its own round
+	mu      sync.Mutex
 	round   uint64
 	pending []*core.BaseHeader
 
@@ -317,6 +333,9 @@ func NewCordialMiner(id ids.NodeID, dag *DAG, logger log.Logger) *CordialMiner {
 
 // ProposeHeader creates a new header referencing current tips
 func (m *CordialMiner) ProposeHeader(headerType core.HeaderType, data []byte) (*core.BaseHeader, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	tips := m.DAG.GetTips()
 
 	// Reference all tips as predecessors
@@ -370,6 +389,8 @@ func (m *CordialMiner) ReceiveHeader(header *core.BaseHeader, sender ids.NodeID)
 	}
 
 	// Update local round
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	sequence := m.DAG.GetSequence()
 	if len(sequence) > 0 {
 		lastDelivered := sequence[len(sequence)-1]
@@ -0,0 +1,95 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package blocklace
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/luxfi/adx/pkg/core"
+	"github.com/luxfi/adx/pkg/ids"
+)
+
+var ErrOutcomeCommitted = errors.New("auction outcome already committed")
+
+// AddAuctionHeader adds an auction outcome header and indexes its vertex by
+// auction ID
+func (d *DAG) AddAuctionHeader(header *core.AuctionHeader, author ids.NodeID) (*Vertex, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if v, exists := d.auctions[header.AuctionID]; exists {
+		if v.Header.ID == header.ID {
+			return v, nil
+		}
+		return nil, ErrOutcomeCommitted
+	}
+
+	payload, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := d.addHeader(&header.BaseHeader, author)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(payload)
+	v.Auction = header
+	v.PayloadHash = hash[:]
+	v.PayloadPtr = header.DAPtr
+	d.auctions[header.AuctionID] = v
+
+	return v, nil
+}
+
+// GetAuctionVertex returns the vertex committing an auction's outcome
+func (d *DAG) GetAuctionVertex(auctionID ids.ID) (*Vertex, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	v, ok := d.auctions[auctionID]
+	return v, ok
+}
+
+// CommitAuctionOutcome proposes an auction outcome header referencing the
+// current tips and adds it to the local DAG
+func (m *CordialMiner) CommitAuctionOutcome(header *core.AuctionHeader) (*Vertex, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.DAG.GetAuctionVertex(header.AuctionID); exists {
+		return nil, ErrOutcomeCommitted
+	}
+
+	tips := m.DAG.GetTips()
+	predecessors := make([]ids.ID, 0, len(tips))
+	for _, tip := range tips {
+		predecessors = append(predecessors, tip.Header.ID)
+	}
+
+	header.Type = core.HeaderTypeAuction
+	header.ID = ids.GenerateTestID()
+	header.Timestamp = time.Now()
+	header.Predecessors = predecessors
+	header.Height = m.round
+
+	v, err := m.DAG.AddAuctionHeader(header, m.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.broadcast != nil {
+		m.broadcast(&header.BaseHeader)
+	}
+
+	m.round++
+
+	m.log.Debug("Auction outcome committed")
+
+	return v, nil
+}
@@ -6,21 +6,31 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/oracle"
 	"github.com/shopspring/decimal"
 )
 
 // PendingRelease represents a time-locked fund release
 type PendingRelease struct {
-	Publisher   string          `json:"publisher"`
-	Amount      decimal.Decimal `json:"amount"`
-	ReleaseTime time.Time       `json:"release_time"`
+	Publisher     string          `json:"publisher"`
+	Amount        decimal.Decimal `json:"amount"`
+	ReleaseTime   time.Time       `json:"release_time"`
+	ReservationID string          `json:"reservation_id,omitempty"` // Settlement the holdback came from
 }
 
-// VMState represents the state of the VM
+// VMState represents the state of the VM. Its accessors are safe for
+// concurrent use. The managers also hold tx across each RPC, exclusively
+// if it modifies records and shared if it only reads them, so what they
+// check can't change before they act on it.
 type VMState struct {
+	mu sync.RWMutex // Guards the maps and pendingReleases
+	tx sync.RWMutex
+
 	adSlots           map[uint64]*AdSlot
 	adSlotOrders      map[string]*AdSlotOrder
 	adMM_Pools        map[uint64]*AdMM_Pool
@@ -28,6 +38,7 @@ type VMState struct {
 	reservations      map[string]*Reservation
 	publisherBalances map[string]decimal.Decimal
 	pendingReleases   []PendingRelease
+	slotExpiries      map[uint64]*SlotExpiry
 }
 
 // AdMM_Pool represents an automated market maker pool for ad slots
@@ -42,11 +53,17 @@ type AdMM_Pool struct {
 	LPTokenSupply decimal.Decimal `json:"lp_token_supply"`
 	LastPrice     decimal.Decimal `json:"last_price"`
 	TimeDecayRate decimal.Decimal `json:"time_decay_rate"` // λ in pricing formula
+	FeeBps        uint16          `json:"fee_bps"`         // Swap fee, kept in the reserves
+	FeesAUSD      decimal.Decimal `json:"fees_ausd"`       // Fees accrued from AUSD paid in
+	FeesSlots     decimal.Decimal `json:"fees_slots"`      // Fees accrued from slots sold in
 	CreatedAt     time.Time       `json:"created_at"`
+	Closed        bool            `json:"closed"` // Slot expired; only AUSD is left to withdraw
 }
 
 // SetAdSlot stores an ad slot in the state
 func (v *VMState) SetAdSlot(slot *AdSlot) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	if v.adSlots == nil {
 		v.adSlots = make(map[uint64]*AdSlot)
 	}
@@ -56,18 +73,22 @@ func (v *VMState) SetAdSlot(slot *AdSlot) error {
 
 // GetAdSlot retrieves an ad slot from the state
 func (v *VMState) GetAdSlot(id uint64) (*AdSlot, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 	if v.adSlots == nil {
-		return nil, fmt.Errorf("ad slot not found")
+		return nil, ErrSlotNotFound
 	}
 	slot, ok := v.adSlots[id]
 	if !ok {
-		return nil, fmt.Errorf("ad slot not found")
+		return nil, ErrSlotNotFound
 	}
 	return slot, nil
 }
 
 // SetAdSlotOrder stores an order in the state
 func (v *VMState) SetAdSlotOrder(order *AdSlotOrder) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	if v.adSlotOrders == nil {
 		v.adSlotOrders = make(map[string]*AdSlotOrder)
 	}
@@ -77,18 +98,35 @@ func (v *VMState) SetAdSlotOrder(order *AdSlotOrder) error {
 
 // GetAdSlotOrder retrieves an order from the state
 func (v *VMState) GetAdSlotOrder(orderID string) (*AdSlotOrder, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 	if v.adSlotOrders == nil {
-		return nil, fmt.Errorf("order not found")
+		return nil, ErrOrderNotFound
 	}
 	order, ok := v.adSlotOrders[orderID]
 	if !ok {
-		return nil, fmt.Errorf("order not found")
+		return nil, ErrOrderNotFound
 	}
 	return order, nil
 }
 
+// SlotOrders returns every order placed on a slot
+func (v *VMState) SlotOrders(slotID uint64) []*AdSlotOrder {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	var out []*AdSlotOrder
+	for _, o := range v.adSlotOrders {
+		if o.SlotID == slotID {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
 // SetAdMM_Pool stores an AMM pool in the state
 func (v *VMState) SetAdMM_Pool(slotID uint64, pool *AdMM_Pool) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	if v.adMM_Pools == nil {
 		v.adMM_Pools = make(map[uint64]*AdMM_Pool)
 	}
@@ -98,6 +136,8 @@ func (v *VMState) SetAdMM_Pool(slotID uint64, pool *AdMM_Pool) error {
 
 // GetAdMM_Pool retrieves an AMM pool from the state
 func (v *VMState) GetAdMM_Pool(slotID uint64) (*AdMM_Pool, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 	if v.adMM_Pools == nil {
 		return nil, false
 	}
@@ -107,6 +147,8 @@ func (v *VMState) GetAdMM_Pool(slotID uint64) (*AdMM_Pool, bool) {
 
 // SetCampaign stores a campaign in the state
 func (v *VMState) SetCampaign(campaignID string, campaign *Campaign) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	if v.campaigns == nil {
 		v.campaigns = make(map[string]*Campaign)
 	}
@@ -116,6 +158,8 @@ func (v *VMState) SetCampaign(campaignID string, campaign *Campaign) error {
 
 // GetCampaign retrieves a campaign from the state
 func (v *VMState) GetCampaign(campaignID string) (*Campaign, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 	if v.campaigns == nil {
 		return nil, false
 	}
@@ -125,6 +169,8 @@ func (v *VMState) GetCampaign(campaignID string) (*Campaign, bool) {
 
 // SetReservation stores a reservation in the state
 func (v *VMState) SetReservation(reservationID string, reservation *Reservation) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	if v.reservations == nil {
 		v.reservations = make(map[string]*Reservation)
 	}
@@ -134,6 +180,8 @@ func (v *VMState) SetReservation(reservationID string, reservation *Reservation)
 
 // GetReservation retrieves a reservation from the state
 func (v *VMState) GetReservation(reservationID string) (*Reservation, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 	if v.reservations == nil {
 		return nil, false
 	}
@@ -141,8 +189,35 @@ func (v *VMState) GetReservation(reservationID string) (*Reservation, bool) {
 	return reservation, ok
 }
 
+// CampaignIDs returns the IDs of all stored campaigns, sorted
+func (v *VMState) CampaignIDs() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	ids := make([]string, 0, len(v.campaigns))
+	for id := range v.campaigns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CampaignReservations returns every reservation made against a campaign
+func (v *VMState) CampaignReservations(campaignID string) []*Reservation {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	var out []*Reservation
+	for _, r := range v.reservations {
+		if r.CampaignID == campaignID {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
 // SetPublisherBalance sets a publisher's balance
 func (v *VMState) SetPublisherBalance(publisher string, balance decimal.Decimal) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	if v.publisherBalances == nil {
 		v.publisherBalances = make(map[string]decimal.Decimal)
 	}
@@ -152,6 +227,8 @@ func (v *VMState) SetPublisherBalance(publisher string, balance decimal.Decimal)
 
 // GetPublisherBalance gets a publisher's balance
 func (v *VMState) GetPublisherBalance(publisher string) decimal.Decimal {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 	if v.publisherBalances == nil {
 		return decimal.Zero
 	}
@@ -164,15 +241,47 @@ func (v *VMState) GetPublisherBalance(publisher string) decimal.Decimal {
 
 // AddPendingRelease adds a pending release to the queue
 func (v *VMState) AddPendingRelease(publisher string, amount decimal.Decimal, releaseTime time.Time) error {
-	release := PendingRelease{
+	v.addRelease(PendingRelease{
 		Publisher:   publisher,
 		Amount:      amount,
 		ReleaseTime: releaseTime,
-	}
-	v.pendingReleases = append(v.pendingReleases, release)
+	})
 	return nil
 }
 
+func (v *VMState) addRelease(r PendingRelease) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.pendingReleases = append(v.pendingReleases, r)
+}
+
+// PendingRelease returns the holdback held for a reservation, leaving it
+// queued
+func (v *VMState) PendingRelease(reservationID string) (PendingRelease, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, r := range v.pendingReleases {
+		if r.ReservationID == reservationID {
+			return r, true
+		}
+	}
+	return PendingRelease{}, false
+}
+
+// TakePendingRelease removes and returns the holdback held for a
+// reservation
+func (v *VMState) TakePendingRelease(reservationID string) (PendingRelease, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i, r := range v.pendingReleases {
+		if r.ReservationID == reservationID {
+			v.pendingReleases = append(v.pendingReleases[:i], v.pendingReleases[i+1:]...)
+			return r, true
+		}
+	}
+	return PendingRelease{}, false
+}
+
 // Request and response types for RPC methods
 type RevealBidRequest struct {
 	AuctionID     string          `json:"auction_id"`
@@ -198,6 +307,7 @@ type CreateAdMM_PoolRequest struct {
 	InitialAUSD       decimal.Decimal `json:"initial_ausd"`
 	InitialSlots      uint64          `json:"initial_slots"`
 	TimeDecayRate     decimal.Decimal `json:"time_decay_rate"`
+	FeeBps            uint16          `json:"fee_bps,omitempty"` // DefaultSwapFeeBps when zero
 	LiquidityProvider string          `json:"liquidity_provider"`
 }
 
@@ -260,6 +370,30 @@ type AdSlotManager struct {
 	state  *VMState
 	dex    *dex.Engine
 	nextID uint64
+
+	// SettlementAsset is the AUSD asset ID flash fills settle in
+	SettlementAsset string
+
+	// Escrow pays flash fills out of advertisers' reservations; it must
+	// share the manager's state, and flash fills are refused without it
+	Escrow *EscrowManager
+
+	// prices values pools in USD against benchmark CPMs
+	prices oracle.PriceOracle
+}
+
+// DefaultSettlementAsset is the asset ID ad slots settle in by default
+const DefaultSettlementAsset = "AUSD"
+
+// NewAdSlotManager creates a manager over state, using engine as the token
+// registry and matching engine
+func NewAdSlotManager(state *VMState, engine *dex.Engine) *AdSlotManager {
+	return &AdSlotManager{state: state, dex: engine, SettlementAsset: DefaultSettlementAsset, prices: oracle.NewStatic()}
+}
+
+// SetPriceOracle sets the reference prices pool stats are quoted against
+func (a *AdSlotManager) SetPriceOracle(o oracle.PriceOracle) {
+	a.prices = o
 }
 
 // estimateOrderFill estimates how much of an order will be filled
@@ -343,15 +477,27 @@ type AdSlotOrder struct {
 
 // CreateAdSlot - Mint new perishable ad inventory tokens
 func (a *AdSlotManager) CreateAdSlot(ctx context.Context, req *CreateAdSlotRequest) (*CreateAdSlotResponse, error) {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
 	// Validate time window
 	if req.StartTime.After(req.EndTime) {
-		return nil, fmt.Errorf("invalid time window")
+		return nil, ErrInvalidWindow
 	}
 	if req.EndTime.Before(time.Now()) {
-		return nil, fmt.Errorf("window already ended")
+		return nil, fmt.Errorf("%w: already ended", ErrInvalidWindow)
 	}
 	if req.MaxImpressions == 0 {
-		return nil, fmt.Errorf("no impressions")
+		return nil, fmt.Errorf("max_impressions: %w", ErrZeroQuantity)
+	}
+	if err := checkQuantity("max_impressions", req.MaxImpressions); err != nil {
+		return nil, err
+	}
+	if err := checkAmount("floor_cpm", req.FloorCPM); err != nil {
+		return nil, err
+	}
+	if err := checkViewability(req.MinViewability); err != nil {
+		return nil, err
 	}
 
 	// Generate deterministic targeting hash
@@ -392,27 +538,36 @@ func (a *AdSlotManager) CreateAdSlot(ctx context.Context, req *CreateAdSlotReque
 
 // PlaceOrder - Place limit/market order for ad slots
 func (a *AdSlotManager) PlaceOrder(ctx context.Context, req *PlaceOrderRequest) (*PlaceOrderResponse, error) {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
 	// Validate slot exists and is active
 	slot, err := a.state.GetAdSlot(req.SlotID)
 	if err != nil {
-		return nil, fmt.Errorf("slot not found: %v", err)
+		return nil, err
 	}
 	if !slot.Active {
-		return nil, fmt.Errorf("slot inactive")
+		return nil, ErrSlotInactive
 	}
 	if time.Now().After(slot.EndTime) {
-		return nil, fmt.Errorf("slot expired")
+		return nil, ErrSlotExpired
 	}
 
 	// Validate order
 	if req.Quantity == 0 {
-		return nil, fmt.Errorf("invalid quantity")
+		return nil, fmt.Errorf("quantity: %w", ErrZeroQuantity)
+	}
+	if err := checkQuantity("quantity", req.Quantity); err != nil {
+		return nil, err
+	}
+	if err := checkAmount("limit_price", req.LimitPrice); err != nil {
+		return nil, err
 	}
 
 	// Check price constraints
 	currentPrice := a.calculateCurrentPrice(slot)
 	if req.IsBuy && req.LimitPrice.LessThan(currentPrice) {
-		return nil, fmt.Errorf("bid below current price")
+		return nil, fmt.Errorf("%w %s", ErrBidBelowPrice, currentPrice)
 	}
 
 	// Create order
@@ -439,7 +594,7 @@ func (a *AdSlotManager) PlaceOrder(ctx context.Context, req *PlaceOrderRequest)
 	// Handle commit-reveal orders
 	if req.OrderType == "commit-reveal" {
 		if req.CommitHash == "" {
-			return nil, fmt.Errorf("commit hash required")
+			return nil, ErrCommitRequired
 		}
 		order.CommitHash = req.CommitHash
 	}
@@ -463,21 +618,28 @@ func (a *AdSlotManager) PlaceOrder(ctx context.Context, req *PlaceOrderRequest)
 
 // RevealBid - Reveal sealed bid in commit-reveal auction
 func (a *AdSlotManager) RevealBid(ctx context.Context, req *RevealBidRequest) (*RevealBidResponse, error) {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
+	if err := checkAmount("revealed_price", req.RevealedPrice); err != nil {
+		return nil, err
+	}
+
 	order, err := a.state.GetAdSlotOrder(req.OrderID)
 	if err != nil {
-		return nil, fmt.Errorf("order not found: %v", err)
+		return nil, err
 	}
 	if order.OrderType != "commit-reveal" {
-		return nil, fmt.Errorf("not a commit-reveal order")
+		return nil, ErrNotCommitReveal
 	}
 	if order.Revealed {
-		return nil, fmt.Errorf("already revealed")
+		return nil, ErrAlreadyRevealed
 	}
 
 	// Validate commitment
 	expectedHash := a.hashCommitment(req.RevealedPrice, req.Nonce)
 	if expectedHash != order.CommitHash {
-		return nil, fmt.Errorf("invalid reveal")
+		return nil, ErrInvalidReveal
 	}
 
 	// Update order with revealed price
@@ -493,17 +655,44 @@ func (a *AdSlotManager) RevealBid(ctx context.Context, req *RevealBidRequest) (*
 	}, nil
 }
 
-// CreateAdMM_Pool - Create AMM pool for continuous liquidity
+// CreateAdMM_Pool - Create AMM pool for continuous liquidity, funded by
+// the liquidity provider
 func (a *AdSlotManager) CreateAdMM_Pool(ctx context.Context, req *CreateAdMM_PoolRequest) (*CreateAdMM_PoolResponse, error) {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
+	if err := checkAmount("initial_ausd", req.InitialAUSD); err != nil {
+		return nil, err
+	}
+	if req.InitialAUSD.IsZero() {
+		return nil, fmt.Errorf("initial_ausd: %w", ErrZeroAmount)
+	}
+	if err := checkQuantity("initial_slots", req.InitialSlots); err != nil {
+		return nil, err
+	}
+	if err := checkRate("time_decay_rate", req.TimeDecayRate, MaxTimeDecayRate); err != nil {
+		return nil, err
+	}
+	feeBps := req.FeeBps
+	if feeBps == 0 {
+		feeBps = DefaultSwapFeeBps
+	}
+	if feeBps > MaxSwapFeeBps {
+		return nil, fmt.Errorf("fee_bps: %w", ErrRateOutOfRange)
+	}
+	if req.LiquidityProvider == "" {
+		return nil, ErrNoLiquidityProvider
+	}
+
 	// Validate slot
 	_, err := a.state.GetAdSlot(req.SlotID)
 	if err != nil {
-		return nil, fmt.Errorf("slot not found: %v", err)
+		return nil, err
 	}
 
 	// Check for existing pool
 	if _, exists := a.state.GetAdMM_Pool(req.SlotID); exists {
-		return nil, fmt.Errorf("pool already exists")
+		return nil, ErrPoolExists
 	}
 
 	// Calculate initial price and LP tokens
@@ -512,23 +701,33 @@ func (a *AdSlotManager) CreateAdMM_Pool(ctx context.Context, req *CreateAdMM_Poo
 	lpTokensValue := req.InitialAUSD.Mul(decimal.NewFromInt(int64(req.InitialSlots)))
 	lpTokens := decimal.NewFromFloat(math.Sqrt(lpTokensValue.InexactFloat64()))
 
-	pool := &AdMM_Pool{
+	// The provider deposits both reserves into the pool's account for their
+	// LP tokens, all or nothing, before the pool exists
+	tx := &dexTx{dex: a.dex}
+	pooled := poolAccount(req.SlotID)
+	if err := tx.transfer(a.SettlementAsset, req.LiquidityProvider, pooled, req.InitialAUSD); err != nil {
+		tx.rollback()
+		return nil, fmt.Errorf("%w: AUSD deposit: %v", ErrInsufficientFunds, err)
+	}
+	if err := tx.transfer(fmt.Sprintf("adslot-%d", req.SlotID), req.LiquidityProvider, pooled, decimal.NewFromInt(int64(req.InitialSlots))); err != nil {
+		tx.rollback()
+		return nil, fmt.Errorf("%w: slot deposit: %v", ErrInsufficientLiquidity, err)
+	}
+	if err := tx.mint(lpAsset(req.SlotID), req.LiquidityProvider, lpTokens); err != nil {
+		tx.rollback()
+		return nil, fmt.Errorf("failed to mint LP tokens: %v", err)
+	}
+
+	a.state.SetAdMM_Pool(req.SlotID, &AdMM_Pool{
 		SlotID:        req.SlotID,
 		ReserveAUSD:   req.InitialAUSD,
 		ReserveSlots:  req.InitialSlots,
 		LastPrice:     initialPrice,
 		LPTokenSupply: lpTokens,
 		TimeDecayRate: req.TimeDecayRate,
+		FeeBps:        feeBps,
 		CreatedAt:     time.Now(),
-	}
-
-	a.state.SetAdMM_Pool(req.SlotID, pool)
-
-	// Transfer initial liquidity would happen here
-	// Note: transferAUSD method needs to be implemented
-	// if err := a.transferAUSD(req.LiquidityProvider, "pool", req.InitialAUSD); err != nil {
-	//     return nil, fmt.Errorf("AUSD transfer failed: %v", err)
-	// }
+	})
 
 	return &CreateAdMM_PoolResponse{
 		Success:      true,
@@ -538,30 +737,92 @@ func (a *AdSlotManager) CreateAdMM_Pool(ctx context.Context, req *CreateAdMM_Poo
 	}, nil
 }
 
-// SwapAdMM - Execute AMM swap (continuous liquidity)
+// SwapAdMM - Execute AMM swap (continuous liquidity) for the caller, who
+// pays in from and is paid out of the pool's account
 func (a *AdSlotManager) SwapAdMM(ctx context.Context, req *SwapAdMM_Request) (*SwapAdMM_Response, error) {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
 	pool, exists := a.state.GetAdMM_Pool(req.SlotID)
 	if !exists {
-		return nil, fmt.Errorf("pool not found")
+		return nil, ErrPoolNotFound
+	}
+	if pool.Closed {
+		return nil, ErrSlotExpired
 	}
 
-	slot, _ := a.state.GetAdSlot(req.SlotID)
+	if _, err := a.state.GetAdSlot(req.SlotID); err != nil {
+		return nil, err
+	}
 
-	// Calculate swap with time decay
-	swapAmount := a.calculateAMM_Swap(pool, slot, uint64(req.AmountIn.IntPart()), req.BuyAUSD)
+	// Slots are indivisible: selling takes a whole number of them, and
+	// buying delivers the whole slots the AUSD covers
+	var slotsIn uint64
+	var err error
+	if req.BuyAUSD {
+		if slotsIn, err = wholeQuantity("amount_in", req.AmountIn); err != nil {
+			return nil, err
+		}
+		if slotsIn > MaxQuantity-pool.ReserveSlots {
+			return nil, fmt.Errorf("amount_in: %w", ErrQuantityTooLarge)
+		}
+	} else {
+		if err := checkAmount("amount_in", req.AmountIn); err != nil {
+			return nil, err
+		}
+		if req.AmountIn.IsZero() {
+			return nil, fmt.Errorf("amount_in: %w", ErrZeroAmount)
+		}
+	}
+	if err := checkAmount("min_amount_out", req.MinAmountOut); err != nil {
+		return nil, err
+	}
+	trader, ok := Caller(ctx)
+	if !ok {
+		return nil, ErrNoCaller
+	}
+
+	// The fee comes off the input before pricing, but the whole input
+	// joins the reserves, so fees grow the invariant
+	fee := req.AmountIn.Mul(decimal.NewFromInt(int64(pool.FeeBps))).Div(decimal.NewFromInt(10000))
+
+	swapAmount := a.calculateAMM_Swap(pool, req.AmountIn.Sub(fee), req.BuyAUSD)
+	if !req.BuyAUSD {
+		swapAmount = swapAmount.Floor()
+	}
 	if swapAmount.LessThanOrEqual(decimal.Zero) {
-		return nil, fmt.Errorf("insufficient liquidity")
+		return nil, ErrInsufficientLiquidity
+	}
+	if swapAmount.LessThan(req.MinAmountOut) {
+		return nil, fmt.Errorf("%w: %s out, minimum %s", ErrSlippageExceeded, swapAmount, req.MinAmountOut)
+	}
+
+	// Execute swap: the whole input moves into the pool's account and the
+	// output out of it
+	token := fmt.Sprintf("adslot-%d", req.SlotID)
+	assetIn, assetOut := a.SettlementAsset, token
+	if req.BuyAUSD {
+		assetIn, assetOut = token, a.SettlementAsset
+	}
+	tx := &dexTx{dex: a.dex}
+	if err := tx.transfer(assetIn, trader, poolAccount(req.SlotID), req.AmountIn); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInsufficientFunds, err)
+	}
+	if err := tx.transfer(assetOut, poolAccount(req.SlotID), trader, swapAmount); err != nil {
+		tx.rollback()
+		return nil, fmt.Errorf("%w: %v", ErrInsufficientLiquidity, err)
 	}
 
-	// Execute swap
 	if req.BuyAUSD {
 		// Selling slots for AUSD
-		pool.ReserveSlots += uint64(req.AmountIn.IntPart())
+		pool.ReserveSlots += slotsIn
 		pool.ReserveAUSD = pool.ReserveAUSD.Sub(swapAmount)
+		pool.FeesSlots = pool.FeesSlots.Add(fee)
 	} else {
 		// Buying slots with AUSD
 		pool.ReserveAUSD = pool.ReserveAUSD.Add(req.AmountIn)
 		pool.ReserveSlots -= uint64(swapAmount.IntPart())
+		pool.FeesAUSD = pool.FeesAUSD.Add(fee)
 	}
 
 	// Update pool price
@@ -581,20 +842,26 @@ func (a *AdSlotManager) SwapAdMM(ctx context.Context, req *SwapAdMM_Request) (*S
 
 // RecordDelivery - Record impression delivery (burns tokens)
 func (a *AdSlotManager) RecordDelivery(ctx context.Context, req *RecordDeliveryRequest) (*RecordDeliveryResponse, error) {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
 	slot, err := a.state.GetAdSlot(req.AdSlotID)
 	if err != nil {
-		return nil, fmt.Errorf("slot not found: %v", err)
+		return nil, err
 	}
 
 	// Validate delivery window
 	now := time.Now()
 	if now.Before(slot.StartTime) || now.After(slot.EndTime) {
-		return nil, fmt.Errorf("outside delivery window")
+		return nil, ErrOutsideWindow
 	}
 
 	// Check capacity
-	if slot.DeliveredImprs+req.Count > slot.MaxImpressions {
-		return nil, fmt.Errorf("exceeds capacity")
+	if err := checkQuantity("count", req.Count); err != nil {
+		return nil, err
+	}
+	if req.Count > slot.MaxImpressions-slot.DeliveredImprs {
+		return nil, ErrExceedsCapacity
 	}
 
 	// Record delivery
@@ -639,76 +906,90 @@ func (a *AdSlotManager) hashTargeting(targeting TargetingPredicate) string {
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+// DefaultTimeDecayRate is the λ used for slots that have no AMM pool, or
+// whose pool doesn't set one
+const DefaultTimeDecayRate = 3.0
+
+// decayRate is the λ a slot's value decays at, given its pool if it has
+// one
+func decayRate(pool *AdMM_Pool) float64 {
+	if pool == nil || pool.TimeDecayRate.IsZero() {
+		return DefaultTimeDecayRate
+	}
+	return pool.TimeDecayRate.InexactFloat64()
+}
+
 func (a *AdSlotManager) calculateCurrentPrice(slot *AdSlot) decimal.Decimal {
-	now := time.Now()
+	return a.priceAt(slot, time.Now())
+}
 
+// priceAt is the spot price: the floor decayed by the curve that values
+// the slot's pool reserves on withdrawal
+func (a *AdSlotManager) priceAt(slot *AdSlot, now time.Time) decimal.Decimal {
 	// Expired = worthless
 	if now.After(slot.EndTime) || !slot.Active {
 		return decimal.Zero
 	}
 
-	// Not started = full price
-	if now.Before(slot.StartTime) {
-		return slot.FloorCPM
-	}
-
-	// Linear time decay
-	timeRemaining := slot.EndTime.Sub(now).Seconds()
-	totalWindow := slot.EndTime.Sub(slot.StartTime).Seconds()
-
-	if totalWindow <= 0 {
-		return slot.FloorCPM
+	var pool *AdMM_Pool
+	if a.state != nil {
+		pool, _ = a.state.GetAdMM_Pool(slot.ID)
 	}
 
-	// Price = floor + (50% premium * time_remaining / total_window)
-	premium := slot.FloorCPM.Div(decimal.NewFromInt(2))
-	timeRatio := decimal.NewFromFloat(timeRemaining / totalWindow)
-
-	return slot.FloorCPM.Add(premium.Mul(timeRatio))
+	return slot.FloorCPM.Mul(timeDecay(slot.StartTime, slot.EndTime, now, decayRate(pool)))
 }
 
-func (a *AdSlotManager) calculateAMM_Swap(pool *AdMM_Pool, slot *AdSlot, amountIn uint64, buyAUSD bool) decimal.Decimal {
-	// Constant product AMM with time decay: k = reserves_ausd * reserves_slots * time_factor
+// calculateAMM_Swap prices a swap on the constant product
+// reserves_ausd * reserves_slots = k. The reserves are real funds in the
+// pool's account, so the invariant isn't decayed: time decay only enters
+// the slots' valuation, in priceAt and RemoveLiquidity.
+func (a *AdSlotManager) calculateAMM_Swap(pool *AdMM_Pool, amountIn decimal.Decimal, buyAUSD bool) decimal.Decimal {
 	if pool.ReserveAUSD.LessThanOrEqual(decimal.Zero) || pool.ReserveSlots == 0 {
 		return decimal.Zero
 	}
 
-	// Apply time decay to effective reserves
-	timeDecay := a.calculateTimeDecay(slot, pool.TimeDecayRate)
-	effectiveK := pool.ReserveAUSD.Mul(decimal.NewFromInt(int64(pool.ReserveSlots))).Mul(timeDecay)
+	k := pool.ReserveAUSD.Mul(decimal.NewFromInt(int64(pool.ReserveSlots)))
 
 	if buyAUSD {
 		// Selling slots for AUSD: new_slots = old_slots + amount_in
-		newSlots := decimal.NewFromInt(int64(pool.ReserveSlots + amountIn))
-		newAUSD := effectiveK.Div(newSlots)
+		newSlots := decimal.NewFromInt(int64(pool.ReserveSlots)).Add(amountIn)
+		newAUSD := k.Div(newSlots)
 		return pool.ReserveAUSD.Sub(newAUSD)
 	} else {
 		// Buying slots with AUSD: new_ausd = old_ausd + amount_in
-		newAUSD := pool.ReserveAUSD.Add(decimal.NewFromInt(int64(amountIn)))
-		newSlots := effectiveK.Div(newAUSD)
+		newAUSD := pool.ReserveAUSD.Add(amountIn)
+		newSlots := k.Div(newAUSD)
 		return decimal.NewFromInt(int64(pool.ReserveSlots)).Sub(newSlots)
 	}
 }
 
-func (a *AdSlotManager) calculateTimeDecay(slot *AdSlot, decayRate decimal.Decimal) decimal.Decimal {
-	now := time.Now()
-	if now.After(slot.EndTime) {
+// timeDecay returns the fraction of value left at now, falling from 1 at
+// start to 0 at end:
+//
+//	f(t) = (e^(-λt) - e^(-λ)) / (1 - e^(-λ)),  t = elapsed / window
+//
+// Larger λ front-loads the decay; as λ approaches 0 the curve becomes
+// linear. The result is clamped to [0, 1].
+func timeDecay(start, end, now time.Time, lambda float64) decimal.Decimal {
+	window := end.Sub(start).Seconds()
+	if window <= 0 || !now.After(start) {
+		return decimal.NewFromInt(1)
+	}
+	if !now.Before(end) {
 		return decimal.Zero
 	}
 
-	timeRemaining := slot.EndTime.Sub(now).Seconds()
-	totalWindow := slot.EndTime.Sub(slot.StartTime).Seconds()
+	t := now.Sub(start).Seconds() / window
 
-	if totalWindow <= 0 {
-		return decimal.NewFromInt(1)
+	var f float64
+	if math.Abs(lambda) < 1e-9 {
+		f = 1 - t
+	} else {
+		tail := math.Exp(-lambda)
+		f = (math.Exp(-lambda*t) - tail) / (1 - tail)
 	}
 
-	// Exponential decay: e^(-λ * (1 - time_remaining/total_window))
-	normalizedTime := decimal.NewFromFloat(1.0 - (timeRemaining / totalWindow))
-	exponent := decayRate.Mul(normalizedTime).Neg()
-
-	// Approximate e^x for small x
-	return decimal.NewFromInt(1).Add(exponent)
+	return decimal.NewFromFloat(math.Min(1, math.Max(0, f)))
 }
 
 // calculateSlippage calculates actual vs expected slippage
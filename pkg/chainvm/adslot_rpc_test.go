@@ -0,0 +1,89 @@
+package chainvm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTimeDecay_Curve(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	if got := timeDecay(start, end, start, 3); !got.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("decay at start = %s, want 1", got)
+	}
+	if got := timeDecay(start, end, end, 3); !got.IsZero() {
+		t.Errorf("decay at expiry = %s, want 0", got)
+	}
+	if got := timeDecay(start, end, end.Add(-time.Millisecond), 3).InexactFloat64(); got > 1e-6 {
+		t.Errorf("decay just before expiry = %v, want ~0", got)
+	}
+
+	// Monotonic decreasing and never negative, even for steep curves
+	for _, lambda := range []float64{0, 0.5, 3, 50} {
+		prev := 2.0
+		for m := 0; m <= 60; m++ {
+			f := timeDecay(start, end, start.Add(time.Duration(m)*time.Minute), lambda).InexactFloat64()
+			if f < 0 || f > 1 {
+				t.Fatalf("λ=%v m=%d: decay %v out of [0,1]", lambda, m, f)
+			}
+			if f > prev {
+				t.Fatalf("λ=%v m=%d: decay increased %v -> %v", lambda, m, prev, f)
+			}
+			prev = f
+		}
+	}
+}
+
+func TestTimeDecay_Lambda(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	mid := start.Add(30 * time.Minute)
+
+	linear := timeDecay(start, end, mid, 0).InexactFloat64()
+	gentle := timeDecay(start, end, mid, 1).InexactFloat64()
+	steep := timeDecay(start, end, mid, 5).InexactFloat64()
+
+	if linear != 0.5 {
+		t.Errorf("λ=0 midpoint = %v, want 0.5", linear)
+	}
+	if !(steep < gentle && gentle < linear) {
+		t.Errorf("larger λ should decay faster: λ=0 %v, λ=1 %v, λ=5 %v", linear, gentle, steep)
+	}
+}
+
+func TestPriceAt(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	slot := &AdSlot{
+		ID:        1,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		FloorCPM:  decimal.NewFromInt(10),
+		Active:    true,
+	}
+	state := &VMState{}
+	a := &AdSlotManager{state: state}
+
+	if got := a.priceAt(slot, start); !got.Equal(slot.FloorCPM) {
+		t.Errorf("price at start = %s, want floor", got)
+	}
+	if got := a.priceAt(slot, slot.EndTime.Add(time.Second)); !got.IsZero() {
+		t.Errorf("price after expiry = %s, want 0", got)
+	}
+
+	// A pool that doesn't set λ decays at the default, like no pool
+	mid := start.Add(30 * time.Minute)
+	before := a.priceAt(slot, mid)
+	state.SetAdMM_Pool(slot.ID, &AdMM_Pool{})
+	if got := a.priceAt(slot, mid); !got.Equal(before) {
+		t.Errorf("midpoint price with a zero-λ pool = %s, want %s", got, before)
+	}
+
+	// The pool's λ drives the spot price too
+	state.SetAdMM_Pool(slot.ID, &AdMM_Pool{TimeDecayRate: decimal.NewFromInt(10)})
+	if after := a.priceAt(slot, mid); !after.LessThan(before) {
+		t.Errorf("steeper pool λ should lower the midpoint price: %s -> %s", before, after)
+	}
+}
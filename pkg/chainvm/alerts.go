@@ -0,0 +1,114 @@
+package chainvm
+
+import (
+	"time"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+// ErrBudgetExhausted is returned by ReserveBudget when a campaign can't
+// cover the reservation or has been auto-paused. Bidders should stop
+// bidding for the campaign until it is refunded.
+var ErrBudgetExhausted = rpcerr.New(rpcerr.Exhausted, "budget_exhausted", "campaign budget exhausted")
+
+// Budget alert kinds
+const (
+	AlertThreshold = "threshold"
+	AlertPaused    = "paused"
+	AlertResumed   = "resumed"
+)
+
+// DefaultAlertThresholds are the committed-budget fractions that alert
+var DefaultAlertThresholds = []float64{0.8, 0.95}
+
+// BudgetAlert reports a campaign crossing a spend threshold or being
+// paused or resumed
+type BudgetAlert struct {
+	CampaignID string          `json:"campaign_id"`
+	Advertiser string          `json:"advertiser"`
+	Kind       string          `json:"kind"`
+	Threshold  float64         `json:"threshold,omitempty"` // For AlertThreshold
+	Committed  float64         `json:"committed"`           // Fraction of total not available
+	Available  decimal.Decimal `json:"available"`
+	Time       time.Time       `json:"time"`
+}
+
+// AlertSink receives budget alerts. Emit is called synchronously from the
+// escrow RPCs, so implementations should not block.
+type AlertSink interface {
+	Emit(alert BudgetAlert)
+}
+
+// AlertPolicy configures budget alerts and auto-pause
+type AlertPolicy struct {
+	// Thresholds are committed-budget fractions, ascending. Each fires
+	// once until the campaign is refunded below it.
+	Thresholds []float64
+
+	// PauseBelow deactivates a campaign once its available budget drops
+	// below this amount, so it can't win pods it can't fully pay for.
+	// Zero disables auto-pause.
+	PauseBelow decimal.Decimal
+}
+
+// SetAlerts configures budget alerting. A nil sink disables alerts but
+// auto-pause still applies.
+func (e *EscrowManager) SetAlerts(sink AlertSink, policy AlertPolicy) {
+	e.alerts = sink
+	e.alertPolicy = policy
+}
+
+// committedFraction is the share of a campaign's total that is reserved,
+// spent or escrowed
+func committedFraction(c *Campaign) float64 {
+	if !c.TotalBudget.IsPositive() {
+		return 0
+	}
+	return 1 - c.AvailableBudget.Div(c.TotalBudget).InexactFloat64()
+}
+
+// checkBudget fires threshold alerts and applies auto-pause after a
+// campaign's available budget changes
+func (e *EscrowManager) checkBudget(c *Campaign) {
+	committed := committedFraction(c)
+
+	level := 0
+	for _, threshold := range e.alertPolicy.Thresholds {
+		if committed >= threshold {
+			level++
+		}
+	}
+	for i := c.AlertLevel; i < level; i++ {
+		e.emit(c, AlertThreshold, e.alertPolicy.Thresholds[i], committed)
+	}
+	// A top-up re-arms thresholds the campaign has dropped back under
+	c.AlertLevel = level
+
+	floor := e.alertPolicy.PauseBelow
+	switch {
+	case floor.IsPositive() && c.Active && c.AvailableBudget.LessThan(floor):
+		c.Active = false
+		c.AutoPaused = true
+		e.emit(c, AlertPaused, 0, committed)
+	case c.AutoPaused && !c.AvailableBudget.LessThan(floor):
+		c.Active = true
+		c.AutoPaused = false
+		e.emit(c, AlertResumed, 0, committed)
+	}
+}
+
+func (e *EscrowManager) emit(c *Campaign, kind string, threshold, committed float64) {
+	if e.alerts == nil {
+		return
+	}
+	e.alerts.Emit(BudgetAlert{
+		CampaignID: c.ID,
+		Advertiser: c.Advertiser,
+		Kind:       kind,
+		Threshold:  threshold,
+		Committed:  committed,
+		Available:  c.AvailableBudget,
+		Time:       time.Now(),
+	})
+}
@@ -0,0 +1,90 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+type recordingSink struct {
+	alerts []BudgetAlert
+}
+
+func (s *recordingSink) Emit(alert BudgetAlert) {
+	s.alerts = append(s.alerts, alert)
+}
+
+func TestBudgetAlerts_ThresholdsAndAutoPause(t *testing.T) {
+	ctx := context.Background()
+	e := newFundedEscrow(t, 100)
+	sink := &recordingSink{}
+	e.SetAlerts(sink, AlertPolicy{
+		Thresholds: DefaultAlertThresholds,
+		PauseBelow: decimal.NewFromInt(5),
+	})
+
+	// Drain in steps of 10: 80% is crossed on the 8th reservation
+	for i := 1; i <= 8; i++ {
+		reserve(t, e, fmt.Sprintf("r%d", i), "10")
+	}
+	if len(sink.alerts) != 1 || sink.alerts[0].Kind != AlertThreshold || sink.alerts[0].Threshold != 0.8 {
+		t.Fatalf("alerts after 80%% = %+v", sink.alerts)
+	}
+
+	// 96% committed crosses 95%; 4 AUSD left is below the pause floor
+	reserve(t, e, "r9", "10")
+	reserve(t, e, "r10", "6")
+	if len(sink.alerts) != 3 {
+		t.Fatalf("alerts = %+v", sink.alerts)
+	}
+	if a := sink.alerts[1]; a.Kind != AlertThreshold || a.Threshold != 0.95 {
+		t.Errorf("second alert = %+v", a)
+	}
+	if a := sink.alerts[2]; a.Kind != AlertPaused || !a.Available.Equal(decimal.NewFromInt(4)) {
+		t.Errorf("third alert = %+v", a)
+	}
+
+	c, _ := e.state.GetCampaign("c1")
+	if c.Active || !c.AutoPaused {
+		t.Fatalf("campaign active=%v autoPaused=%v, want paused", c.Active, c.AutoPaused)
+	}
+
+	_, err := e.ReserveBudget(ctx, &ReserveBudgetRequest{
+		ReservationID: "r11",
+		CampaignID:    "c1",
+		Amount:        decimal.NewFromInt(1),
+		TTLSeconds:    10,
+	})
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("reserve on paused campaign: %v, want %v", err, ErrBudgetExhausted)
+	}
+
+	// Refunding resumes the campaign and re-arms the thresholds
+	e.dex.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	if _, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100)}); err != nil {
+		t.Fatalf("FundCampaign: %v", err)
+	}
+	if !c.Active || c.AutoPaused || c.AlertLevel != 0 {
+		t.Errorf("after refund active=%v autoPaused=%v level=%d", c.Active, c.AutoPaused, c.AlertLevel)
+	}
+	if last := sink.alerts[len(sink.alerts)-1]; last.Kind != AlertResumed {
+		t.Errorf("last alert = %+v, want resumed", last)
+	}
+}
+
+func TestReserveBudget_Exhausted(t *testing.T) {
+	e := newFundedEscrow(t, 10)
+
+	_, err := e.ReserveBudget(context.Background(), &ReserveBudgetRequest{
+		ReservationID: "r1",
+		CampaignID:    "c1",
+		Amount:        decimal.NewFromInt(11),
+		TTLSeconds:    10,
+	})
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("err = %v, want %v", err, ErrBudgetExhausted)
+	}
+}
@@ -0,0 +1,1621 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: chainvm/v1/chainvm.proto
+
+package chainvmpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type TargetingPredicate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GeoTargets    []string               `protobuf:"bytes,1,rep,name=geo_targets,json=geoTargets,proto3" json:"geo_targets,omitempty"`
+	DeviceTypes   []string               `protobuf:"bytes,2,rep,name=device_types,json=deviceTypes,proto3" json:"device_types,omitempty"`
+	Categories    []string               `protobuf:"bytes,3,rep,name=categories,proto3" json:"categories,omitempty"`
+	MinAge        uint32                 `protobuf:"varint,4,opt,name=min_age,json=minAge,proto3" json:"min_age,omitempty"`
+	MaxAge        uint32                 `protobuf:"varint,5,opt,name=max_age,json=maxAge,proto3" json:"max_age,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TargetingPredicate) Reset() {
+	*x = TargetingPredicate{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TargetingPredicate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TargetingPredicate) ProtoMessage() {}
+
+func (x *TargetingPredicate) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TargetingPredicate.ProtoReflect.Descriptor instead.
+func (*TargetingPredicate) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *TargetingPredicate) GetGeoTargets() []string {
+	if x != nil {
+		return x.GeoTargets
+	}
+	return nil
+}
+
+func (x *TargetingPredicate) GetDeviceTypes() []string {
+	if x != nil {
+		return x.DeviceTypes
+	}
+	return nil
+}
+
+func (x *TargetingPredicate) GetCategories() []string {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *TargetingPredicate) GetMinAge() uint32 {
+	if x != nil {
+		return x.MinAge
+	}
+	return 0
+}
+
+func (x *TargetingPredicate) GetMaxAge() uint32 {
+	if x != nil {
+		return x.MaxAge
+	}
+	return 0
+}
+
+type CreateAdSlotRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Publisher      string                 `protobuf:"bytes,1,opt,name=publisher,proto3" json:"publisher,omitempty"`
+	Placement      string                 `protobuf:"bytes,2,opt,name=placement,proto3" json:"placement,omitempty"`
+	Targeting      *TargetingPredicate    `protobuf:"bytes,3,opt,name=targeting,proto3" json:"targeting,omitempty"`
+	StartTime      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime        *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	MaxImpressions uint64                 `protobuf:"varint,6,opt,name=max_impressions,json=maxImpressions,proto3" json:"max_impressions,omitempty"`
+	MinViewability float64                `protobuf:"fixed64,7,opt,name=min_viewability,json=minViewability,proto3" json:"min_viewability,omitempty"`
+	FloorCpm       string                 `protobuf:"bytes,8,opt,name=floor_cpm,json=floorCpm,proto3" json:"floor_cpm,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CreateAdSlotRequest) Reset() {
+	*x = CreateAdSlotRequest{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateAdSlotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateAdSlotRequest) ProtoMessage() {}
+
+func (x *CreateAdSlotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateAdSlotRequest.ProtoReflect.Descriptor instead.
+func (*CreateAdSlotRequest) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateAdSlotRequest) GetPublisher() string {
+	if x != nil {
+		return x.Publisher
+	}
+	return ""
+}
+
+func (x *CreateAdSlotRequest) GetPlacement() string {
+	if x != nil {
+		return x.Placement
+	}
+	return ""
+}
+
+func (x *CreateAdSlotRequest) GetTargeting() *TargetingPredicate {
+	if x != nil {
+		return x.Targeting
+	}
+	return nil
+}
+
+func (x *CreateAdSlotRequest) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *CreateAdSlotRequest) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *CreateAdSlotRequest) GetMaxImpressions() uint64 {
+	if x != nil {
+		return x.MaxImpressions
+	}
+	return 0
+}
+
+func (x *CreateAdSlotRequest) GetMinViewability() float64 {
+	if x != nil {
+		return x.MinViewability
+	}
+	return 0
+}
+
+func (x *CreateAdSlotRequest) GetFloorCpm() string {
+	if x != nil {
+		return x.FloorCpm
+	}
+	return ""
+}
+
+type CreateAdSlotResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	SlotId        uint64                 `protobuf:"varint,2,opt,name=slot_id,json=slotId,proto3" json:"slot_id,omitempty"`
+	TokenId       string                 `protobuf:"bytes,3,opt,name=token_id,json=tokenId,proto3" json:"token_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateAdSlotResponse) Reset() {
+	*x = CreateAdSlotResponse{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateAdSlotResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateAdSlotResponse) ProtoMessage() {}
+
+func (x *CreateAdSlotResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateAdSlotResponse.ProtoReflect.Descriptor instead.
+func (*CreateAdSlotResponse) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateAdSlotResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *CreateAdSlotResponse) GetSlotId() uint64 {
+	if x != nil {
+		return x.SlotId
+	}
+	return 0
+}
+
+func (x *CreateAdSlotResponse) GetTokenId() string {
+	if x != nil {
+		return x.TokenId
+	}
+	return ""
+}
+
+type PlaceOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OrderId       string                 `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	TraderId      string                 `protobuf:"bytes,2,opt,name=trader_id,json=traderId,proto3" json:"trader_id,omitempty"`
+	SlotId        uint64                 `protobuf:"varint,3,opt,name=slot_id,json=slotId,proto3" json:"slot_id,omitempty"`
+	IsBuy         bool                   `protobuf:"varint,4,opt,name=is_buy,json=isBuy,proto3" json:"is_buy,omitempty"`
+	OrderType     string                 `protobuf:"bytes,5,opt,name=order_type,json=orderType,proto3" json:"order_type,omitempty"`
+	LimitPrice    string                 `protobuf:"bytes,6,opt,name=limit_price,json=limitPrice,proto3" json:"limit_price,omitempty"`
+	Quantity      uint64                 `protobuf:"varint,7,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	CommitHash    string                 `protobuf:"bytes,9,opt,name=commit_hash,json=commitHash,proto3" json:"commit_hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaceOrderRequest) Reset() {
+	*x = PlaceOrderRequest{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaceOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaceOrderRequest) ProtoMessage() {}
+
+func (x *PlaceOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaceOrderRequest.ProtoReflect.Descriptor instead.
+func (*PlaceOrderRequest) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PlaceOrderRequest) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *PlaceOrderRequest) GetTraderId() string {
+	if x != nil {
+		return x.TraderId
+	}
+	return ""
+}
+
+func (x *PlaceOrderRequest) GetSlotId() uint64 {
+	if x != nil {
+		return x.SlotId
+	}
+	return 0
+}
+
+func (x *PlaceOrderRequest) GetIsBuy() bool {
+	if x != nil {
+		return x.IsBuy
+	}
+	return false
+}
+
+func (x *PlaceOrderRequest) GetOrderType() string {
+	if x != nil {
+		return x.OrderType
+	}
+	return ""
+}
+
+func (x *PlaceOrderRequest) GetLimitPrice() string {
+	if x != nil {
+		return x.LimitPrice
+	}
+	return ""
+}
+
+func (x *PlaceOrderRequest) GetQuantity() uint64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *PlaceOrderRequest) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *PlaceOrderRequest) GetCommitHash() string {
+	if x != nil {
+		return x.CommitHash
+	}
+	return ""
+}
+
+type PlaceOrderResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	OrderId       string                 `protobuf:"bytes,2,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	CurrentPrice  string                 `protobuf:"bytes,3,opt,name=current_price,json=currentPrice,proto3" json:"current_price,omitempty"`
+	EstimatedFill string                 `protobuf:"bytes,4,opt,name=estimated_fill,json=estimatedFill,proto3" json:"estimated_fill,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaceOrderResponse) Reset() {
+	*x = PlaceOrderResponse{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaceOrderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaceOrderResponse) ProtoMessage() {}
+
+func (x *PlaceOrderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaceOrderResponse.ProtoReflect.Descriptor instead.
+func (*PlaceOrderResponse) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PlaceOrderResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *PlaceOrderResponse) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *PlaceOrderResponse) GetCurrentPrice() string {
+	if x != nil {
+		return x.CurrentPrice
+	}
+	return ""
+}
+
+func (x *PlaceOrderResponse) GetEstimatedFill() string {
+	if x != nil {
+		return x.EstimatedFill
+	}
+	return ""
+}
+
+type RevealBidRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AuctionId     string                 `protobuf:"bytes,1,opt,name=auction_id,json=auctionId,proto3" json:"auction_id,omitempty"`
+	BidId         string                 `protobuf:"bytes,2,opt,name=bid_id,json=bidId,proto3" json:"bid_id,omitempty"`
+	OrderId       string                 `protobuf:"bytes,3,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Reveal        []byte                 `protobuf:"bytes,4,opt,name=reveal,proto3" json:"reveal,omitempty"`
+	RevealedPrice string                 `protobuf:"bytes,5,opt,name=revealed_price,json=revealedPrice,proto3" json:"revealed_price,omitempty"`
+	Nonce         string                 `protobuf:"bytes,6,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevealBidRequest) Reset() {
+	*x = RevealBidRequest{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevealBidRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevealBidRequest) ProtoMessage() {}
+
+func (x *RevealBidRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevealBidRequest.ProtoReflect.Descriptor instead.
+func (*RevealBidRequest) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RevealBidRequest) GetAuctionId() string {
+	if x != nil {
+		return x.AuctionId
+	}
+	return ""
+}
+
+func (x *RevealBidRequest) GetBidId() string {
+	if x != nil {
+		return x.BidId
+	}
+	return ""
+}
+
+func (x *RevealBidRequest) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *RevealBidRequest) GetReveal() []byte {
+	if x != nil {
+		return x.Reveal
+	}
+	return nil
+}
+
+func (x *RevealBidRequest) GetRevealedPrice() string {
+	if x != nil {
+		return x.RevealedPrice
+	}
+	return ""
+}
+
+func (x *RevealBidRequest) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+type RevealBidResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	RevealedPrice string                 `protobuf:"bytes,3,opt,name=revealed_price,json=revealedPrice,proto3" json:"revealed_price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevealBidResponse) Reset() {
+	*x = RevealBidResponse{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevealBidResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevealBidResponse) ProtoMessage() {}
+
+func (x *RevealBidResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevealBidResponse.ProtoReflect.Descriptor instead.
+func (*RevealBidResponse) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RevealBidResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RevealBidResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RevealBidResponse) GetRevealedPrice() string {
+	if x != nil {
+		return x.RevealedPrice
+	}
+	return ""
+}
+
+type RecordDeliveryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SlotId        uint64                 `protobuf:"varint,1,opt,name=slot_id,json=slotId,proto3" json:"slot_id,omitempty"`
+	Impressions   uint64                 `protobuf:"varint,2,opt,name=impressions,proto3" json:"impressions,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordDeliveryRequest) Reset() {
+	*x = RecordDeliveryRequest{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordDeliveryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordDeliveryRequest) ProtoMessage() {}
+
+func (x *RecordDeliveryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordDeliveryRequest.ProtoReflect.Descriptor instead.
+func (*RecordDeliveryRequest) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RecordDeliveryRequest) GetSlotId() uint64 {
+	if x != nil {
+		return x.SlotId
+	}
+	return 0
+}
+
+func (x *RecordDeliveryRequest) GetImpressions() uint64 {
+	if x != nil {
+		return x.Impressions
+	}
+	return 0
+}
+
+func (x *RecordDeliveryRequest) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type RecordDeliveryResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Success         bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message         string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	DeliveredCount  uint64                 `protobuf:"varint,3,opt,name=delivered_count,json=deliveredCount,proto3" json:"delivered_count,omitempty"`
+	TotalDelivered  uint64                 `protobuf:"varint,4,opt,name=total_delivered,json=totalDelivered,proto3" json:"total_delivered,omitempty"`
+	RemainingSupply uint64                 `protobuf:"varint,5,opt,name=remaining_supply,json=remainingSupply,proto3" json:"remaining_supply,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RecordDeliveryResponse) Reset() {
+	*x = RecordDeliveryResponse{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordDeliveryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordDeliveryResponse) ProtoMessage() {}
+
+func (x *RecordDeliveryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordDeliveryResponse.ProtoReflect.Descriptor instead.
+func (*RecordDeliveryResponse) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RecordDeliveryResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RecordDeliveryResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RecordDeliveryResponse) GetDeliveredCount() uint64 {
+	if x != nil {
+		return x.DeliveredCount
+	}
+	return 0
+}
+
+func (x *RecordDeliveryResponse) GetTotalDelivered() uint64 {
+	if x != nil {
+		return x.TotalDelivered
+	}
+	return 0
+}
+
+func (x *RecordDeliveryResponse) GetRemainingSupply() uint64 {
+	if x != nil {
+		return x.RemainingSupply
+	}
+	return 0
+}
+
+type FundCampaignRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CampaignId    string                 `protobuf:"bytes,1,opt,name=campaign_id,json=campaignId,proto3" json:"campaign_id,omitempty"`
+	Advertiser    string                 `protobuf:"bytes,2,opt,name=advertiser,proto3" json:"advertiser,omitempty"`
+	Amount        string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	HoldbackBps   uint32                 `protobuf:"varint,4,opt,name=holdback_bps,json=holdbackBps,proto3" json:"holdback_bps,omitempty"`
+	FlightStart   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=flight_start,json=flightStart,proto3" json:"flight_start,omitempty"`
+	FlightEnd     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=flight_end,json=flightEnd,proto3" json:"flight_end,omitempty"`
+	Pacing        string                 `protobuf:"bytes,7,opt,name=pacing,proto3" json:"pacing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FundCampaignRequest) Reset() {
+	*x = FundCampaignRequest{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FundCampaignRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FundCampaignRequest) ProtoMessage() {}
+
+func (x *FundCampaignRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FundCampaignRequest.ProtoReflect.Descriptor instead.
+func (*FundCampaignRequest) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FundCampaignRequest) GetCampaignId() string {
+	if x != nil {
+		return x.CampaignId
+	}
+	return ""
+}
+
+func (x *FundCampaignRequest) GetAdvertiser() string {
+	if x != nil {
+		return x.Advertiser
+	}
+	return ""
+}
+
+func (x *FundCampaignRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *FundCampaignRequest) GetHoldbackBps() uint32 {
+	if x != nil {
+		return x.HoldbackBps
+	}
+	return 0
+}
+
+func (x *FundCampaignRequest) GetFlightStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FlightStart
+	}
+	return nil
+}
+
+func (x *FundCampaignRequest) GetFlightEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FlightEnd
+	}
+	return nil
+}
+
+func (x *FundCampaignRequest) GetPacing() string {
+	if x != nil {
+		return x.Pacing
+	}
+	return ""
+}
+
+type FundCampaignResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Success         bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	NewTotalBudget  string                 `protobuf:"bytes,2,opt,name=new_total_budget,json=newTotalBudget,proto3" json:"new_total_budget,omitempty"`
+	AvailableBudget string                 `protobuf:"bytes,3,opt,name=available_budget,json=availableBudget,proto3" json:"available_budget,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *FundCampaignResponse) Reset() {
+	*x = FundCampaignResponse{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FundCampaignResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FundCampaignResponse) ProtoMessage() {}
+
+func (x *FundCampaignResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FundCampaignResponse.ProtoReflect.Descriptor instead.
+func (*FundCampaignResponse) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FundCampaignResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *FundCampaignResponse) GetNewTotalBudget() string {
+	if x != nil {
+		return x.NewTotalBudget
+	}
+	return ""
+}
+
+func (x *FundCampaignResponse) GetAvailableBudget() string {
+	if x != nil {
+		return x.AvailableBudget
+	}
+	return ""
+}
+
+type ReservationMeta struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Placement      string                 `protobuf:"bytes,1,opt,name=placement,proto3" json:"placement,omitempty"`
+	Geo            string                 `protobuf:"bytes,2,opt,name=geo,proto3" json:"geo,omitempty"`
+	DeviceType     string                 `protobuf:"bytes,3,opt,name=device_type,json=deviceType,proto3" json:"device_type,omitempty"`
+	Categories     []string               `protobuf:"bytes,4,rep,name=categories,proto3" json:"categories,omitempty"`
+	MinViewability float64                `protobuf:"fixed64,5,opt,name=min_viewability,json=minViewability,proto3" json:"min_viewability,omitempty"`
+	UserHash       string                 `protobuf:"bytes,6,opt,name=user_hash,json=userHash,proto3" json:"user_hash,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ReservationMeta) Reset() {
+	*x = ReservationMeta{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReservationMeta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReservationMeta) ProtoMessage() {}
+
+func (x *ReservationMeta) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReservationMeta.ProtoReflect.Descriptor instead.
+func (*ReservationMeta) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ReservationMeta) GetPlacement() string {
+	if x != nil {
+		return x.Placement
+	}
+	return ""
+}
+
+func (x *ReservationMeta) GetGeo() string {
+	if x != nil {
+		return x.Geo
+	}
+	return ""
+}
+
+func (x *ReservationMeta) GetDeviceType() string {
+	if x != nil {
+		return x.DeviceType
+	}
+	return ""
+}
+
+func (x *ReservationMeta) GetCategories() []string {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *ReservationMeta) GetMinViewability() float64 {
+	if x != nil {
+		return x.MinViewability
+	}
+	return 0
+}
+
+func (x *ReservationMeta) GetUserHash() string {
+	if x != nil {
+		return x.UserHash
+	}
+	return ""
+}
+
+type ReserveBudgetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReservationId string                 `protobuf:"bytes,1,opt,name=reservation_id,json=reservationId,proto3" json:"reservation_id,omitempty"`
+	CampaignId    string                 `protobuf:"bytes,2,opt,name=campaign_id,json=campaignId,proto3" json:"campaign_id,omitempty"`
+	Publisher     string                 `protobuf:"bytes,3,opt,name=publisher,proto3" json:"publisher,omitempty"`
+	Amount        string                 `protobuf:"bytes,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	TtlSeconds    uint32                 `protobuf:"varint,5,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	Metadata      *ReservationMeta       `protobuf:"bytes,6,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReserveBudgetRequest) Reset() {
+	*x = ReserveBudgetRequest{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReserveBudgetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReserveBudgetRequest) ProtoMessage() {}
+
+func (x *ReserveBudgetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReserveBudgetRequest.ProtoReflect.Descriptor instead.
+func (*ReserveBudgetRequest) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ReserveBudgetRequest) GetReservationId() string {
+	if x != nil {
+		return x.ReservationId
+	}
+	return ""
+}
+
+func (x *ReserveBudgetRequest) GetCampaignId() string {
+	if x != nil {
+		return x.CampaignId
+	}
+	return ""
+}
+
+func (x *ReserveBudgetRequest) GetPublisher() string {
+	if x != nil {
+		return x.Publisher
+	}
+	return ""
+}
+
+func (x *ReserveBudgetRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *ReserveBudgetRequest) GetTtlSeconds() uint32 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *ReserveBudgetRequest) GetMetadata() *ReservationMeta {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type ReserveBudgetResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Success         bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Expires         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=expires,proto3" json:"expires,omitempty"`
+	RemainingBudget string                 `protobuf:"bytes,3,opt,name=remaining_budget,json=remainingBudget,proto3" json:"remaining_budget,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ReserveBudgetResponse) Reset() {
+	*x = ReserveBudgetResponse{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReserveBudgetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReserveBudgetResponse) ProtoMessage() {}
+
+func (x *ReserveBudgetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReserveBudgetResponse.ProtoReflect.Descriptor instead.
+func (*ReserveBudgetResponse) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ReserveBudgetResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *ReserveBudgetResponse) GetExpires() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Expires
+	}
+	return nil
+}
+
+func (x *ReserveBudgetResponse) GetRemainingBudget() string {
+	if x != nil {
+		return x.RemainingBudget
+	}
+	return ""
+}
+
+type SettleReceiptRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	ReservationId     string                 `protobuf:"bytes,1,opt,name=reservation_id,json=reservationId,proto3" json:"reservation_id,omitempty"`
+	VerificationProof string                 `protobuf:"bytes,2,opt,name=verification_proof,json=verificationProof,proto3" json:"verification_proof,omitempty"`
+	Amount            string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *SettleReceiptRequest) Reset() {
+	*x = SettleReceiptRequest{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SettleReceiptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SettleReceiptRequest) ProtoMessage() {}
+
+func (x *SettleReceiptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SettleReceiptRequest.ProtoReflect.Descriptor instead.
+func (*SettleReceiptRequest) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SettleReceiptRequest) GetReservationId() string {
+	if x != nil {
+		return x.ReservationId
+	}
+	return ""
+}
+
+func (x *SettleReceiptRequest) GetVerificationProof() string {
+	if x != nil {
+		return x.VerificationProof
+	}
+	return ""
+}
+
+func (x *SettleReceiptRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+type SettleReceiptResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Success          bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	PaidAmount       string                 `protobuf:"bytes,2,opt,name=paid_amount,json=paidAmount,proto3" json:"paid_amount,omitempty"`
+	HoldbackAmount   string                 `protobuf:"bytes,3,opt,name=holdback_amount,json=holdbackAmount,proto3" json:"holdback_amount,omitempty"`
+	PublisherBalance string                 `protobuf:"bytes,4,opt,name=publisher_balance,json=publisherBalance,proto3" json:"publisher_balance,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *SettleReceiptResponse) Reset() {
+	*x = SettleReceiptResponse{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SettleReceiptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SettleReceiptResponse) ProtoMessage() {}
+
+func (x *SettleReceiptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SettleReceiptResponse.ProtoReflect.Descriptor instead.
+func (*SettleReceiptResponse) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SettleReceiptResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *SettleReceiptResponse) GetPaidAmount() string {
+	if x != nil {
+		return x.PaidAmount
+	}
+	return ""
+}
+
+func (x *SettleReceiptResponse) GetHoldbackAmount() string {
+	if x != nil {
+		return x.HoldbackAmount
+	}
+	return ""
+}
+
+func (x *SettleReceiptResponse) GetPublisherBalance() string {
+	if x != nil {
+		return x.PublisherBalance
+	}
+	return ""
+}
+
+type CreatePGDealRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	CampaignId       string                 `protobuf:"bytes,1,opt,name=campaign_id,json=campaignId,proto3" json:"campaign_id,omitempty"`
+	DealId           string                 `protobuf:"bytes,2,opt,name=deal_id,json=dealId,proto3" json:"deal_id,omitempty"`
+	Publisher        string                 `protobuf:"bytes,3,opt,name=publisher,proto3" json:"publisher,omitempty"`
+	StartTime        *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime          *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	TotalImpressions uint64                 `protobuf:"varint,6,opt,name=total_impressions,json=totalImpressions,proto3" json:"total_impressions,omitempty"`
+	FixedCpm         string                 `protobuf:"bytes,7,opt,name=fixed_cpm,json=fixedCpm,proto3" json:"fixed_cpm,omitempty"`
+	PenaltyRate      string                 `protobuf:"bytes,8,opt,name=penalty_rate,json=penaltyRate,proto3" json:"penalty_rate,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *CreatePGDealRequest) Reset() {
+	*x = CreatePGDealRequest{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePGDealRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePGDealRequest) ProtoMessage() {}
+
+func (x *CreatePGDealRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePGDealRequest.ProtoReflect.Descriptor instead.
+func (*CreatePGDealRequest) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *CreatePGDealRequest) GetCampaignId() string {
+	if x != nil {
+		return x.CampaignId
+	}
+	return ""
+}
+
+func (x *CreatePGDealRequest) GetDealId() string {
+	if x != nil {
+		return x.DealId
+	}
+	return ""
+}
+
+func (x *CreatePGDealRequest) GetPublisher() string {
+	if x != nil {
+		return x.Publisher
+	}
+	return ""
+}
+
+func (x *CreatePGDealRequest) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *CreatePGDealRequest) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *CreatePGDealRequest) GetTotalImpressions() uint64 {
+	if x != nil {
+		return x.TotalImpressions
+	}
+	return 0
+}
+
+func (x *CreatePGDealRequest) GetFixedCpm() string {
+	if x != nil {
+		return x.FixedCpm
+	}
+	return ""
+}
+
+func (x *CreatePGDealRequest) GetPenaltyRate() string {
+	if x != nil {
+		return x.PenaltyRate
+	}
+	return ""
+}
+
+type CreatePGDealResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	EscrowAmount  string                 `protobuf:"bytes,2,opt,name=escrow_amount,json=escrowAmount,proto3" json:"escrow_amount,omitempty"`
+	DealId        string                 `protobuf:"bytes,3,opt,name=deal_id,json=dealId,proto3" json:"deal_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePGDealResponse) Reset() {
+	*x = CreatePGDealResponse{}
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePGDealResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePGDealResponse) ProtoMessage() {}
+
+func (x *CreatePGDealResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chainvm_v1_chainvm_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePGDealResponse.ProtoReflect.Descriptor instead.
+func (*CreatePGDealResponse) Descriptor() ([]byte, []int) {
+	return file_chainvm_v1_chainvm_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *CreatePGDealResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *CreatePGDealResponse) GetEscrowAmount() string {
+	if x != nil {
+		return x.EscrowAmount
+	}
+	return ""
+}
+
+func (x *CreatePGDealResponse) GetDealId() string {
+	if x != nil {
+		return x.DealId
+	}
+	return ""
+}
+
+var File_chainvm_v1_chainvm_proto protoreflect.FileDescriptor
+
+const file_chainvm_v1_chainvm_proto_rawDesc = "" +
+	"\n" +
+	"\x18chainvm/v1/chainvm.proto\x12\n" +
+	"chainvm.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xaa\x01\n" +
+	"\x12TargetingPredicate\x12\x1f\n" +
+	"\vgeo_targets\x18\x01 \x03(\tR\n" +
+	"geoTargets\x12!\n" +
+	"\fdevice_types\x18\x02 \x03(\tR\vdeviceTypes\x12\x1e\n" +
+	"\n" +
+	"categories\x18\x03 \x03(\tR\n" +
+	"categories\x12\x17\n" +
+	"\amin_age\x18\x04 \x01(\rR\x06minAge\x12\x17\n" +
+	"\amax_age\x18\x05 \x01(\rR\x06maxAge\"\xf0\x02\n" +
+	"\x13CreateAdSlotRequest\x12\x1c\n" +
+	"\tpublisher\x18\x01 \x01(\tR\tpublisher\x12\x1c\n" +
+	"\tplacement\x18\x02 \x01(\tR\tplacement\x12<\n" +
+	"\ttargeting\x18\x03 \x01(\v2\x1e.chainvm.v1.TargetingPredicateR\ttargeting\x129\n" +
+	"\n" +
+	"start_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x12'\n" +
+	"\x0fmax_impressions\x18\x06 \x01(\x04R\x0emaxImpressions\x12'\n" +
+	"\x0fmin_viewability\x18\a \x01(\x01R\x0eminViewability\x12\x1b\n" +
+	"\tfloor_cpm\x18\b \x01(\tR\bfloorCpm\"d\n" +
+	"\x14CreateAdSlotResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x17\n" +
+	"\aslot_id\x18\x02 \x01(\x04R\x06slotId\x12\x19\n" +
+	"\btoken_id\x18\x03 \x01(\tR\atokenId\"\xb3\x02\n" +
+	"\x11PlaceOrderRequest\x12\x19\n" +
+	"\border_id\x18\x01 \x01(\tR\aorderId\x12\x1b\n" +
+	"\ttrader_id\x18\x02 \x01(\tR\btraderId\x12\x17\n" +
+	"\aslot_id\x18\x03 \x01(\x04R\x06slotId\x12\x15\n" +
+	"\x06is_buy\x18\x04 \x01(\bR\x05isBuy\x12\x1d\n" +
+	"\n" +
+	"order_type\x18\x05 \x01(\tR\torderType\x12\x1f\n" +
+	"\vlimit_price\x18\x06 \x01(\tR\n" +
+	"limitPrice\x12\x1a\n" +
+	"\bquantity\x18\a \x01(\x04R\bquantity\x129\n" +
+	"\n" +
+	"expires_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x1f\n" +
+	"\vcommit_hash\x18\t \x01(\tR\n" +
+	"commitHash\"\x95\x01\n" +
+	"\x12PlaceOrderResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x19\n" +
+	"\border_id\x18\x02 \x01(\tR\aorderId\x12#\n" +
+	"\rcurrent_price\x18\x03 \x01(\tR\fcurrentPrice\x12%\n" +
+	"\x0eestimated_fill\x18\x04 \x01(\tR\restimatedFill\"\xb8\x01\n" +
+	"\x10RevealBidRequest\x12\x1d\n" +
+	"\n" +
+	"auction_id\x18\x01 \x01(\tR\tauctionId\x12\x15\n" +
+	"\x06bid_id\x18\x02 \x01(\tR\x05bidId\x12\x19\n" +
+	"\border_id\x18\x03 \x01(\tR\aorderId\x12\x16\n" +
+	"\x06reveal\x18\x04 \x01(\fR\x06reveal\x12%\n" +
+	"\x0erevealed_price\x18\x05 \x01(\tR\rrevealedPrice\x12\x14\n" +
+	"\x05nonce\x18\x06 \x01(\tR\x05nonce\"n\n" +
+	"\x11RevealBidResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12%\n" +
+	"\x0erevealed_price\x18\x03 \x01(\tR\rrevealedPrice\"\x8c\x01\n" +
+	"\x15RecordDeliveryRequest\x12\x17\n" +
+	"\aslot_id\x18\x01 \x01(\x04R\x06slotId\x12 \n" +
+	"\vimpressions\x18\x02 \x01(\x04R\vimpressions\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\xc9\x01\n" +
+	"\x16RecordDeliveryResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12'\n" +
+	"\x0fdelivered_count\x18\x03 \x01(\x04R\x0edeliveredCount\x12'\n" +
+	"\x0ftotal_delivered\x18\x04 \x01(\x04R\x0etotalDelivered\x12)\n" +
+	"\x10remaining_supply\x18\x05 \x01(\x04R\x0fremainingSupply\"\xa3\x02\n" +
+	"\x13FundCampaignRequest\x12\x1f\n" +
+	"\vcampaign_id\x18\x01 \x01(\tR\n" +
+	"campaignId\x12\x1e\n" +
+	"\n" +
+	"advertiser\x18\x02 \x01(\tR\n" +
+	"advertiser\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\tR\x06amount\x12!\n" +
+	"\fholdback_bps\x18\x04 \x01(\rR\vholdbackBps\x12=\n" +
+	"\fflight_start\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vflightStart\x129\n" +
+	"\n" +
+	"flight_end\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tflightEnd\x12\x16\n" +
+	"\x06pacing\x18\a \x01(\tR\x06pacing\"\x85\x01\n" +
+	"\x14FundCampaignResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12(\n" +
+	"\x10new_total_budget\x18\x02 \x01(\tR\x0enewTotalBudget\x12)\n" +
+	"\x10available_budget\x18\x03 \x01(\tR\x0favailableBudget\"\xc8\x01\n" +
+	"\x0fReservationMeta\x12\x1c\n" +
+	"\tplacement\x18\x01 \x01(\tR\tplacement\x12\x10\n" +
+	"\x03geo\x18\x02 \x01(\tR\x03geo\x12\x1f\n" +
+	"\vdevice_type\x18\x03 \x01(\tR\n" +
+	"deviceType\x12\x1e\n" +
+	"\n" +
+	"categories\x18\x04 \x03(\tR\n" +
+	"categories\x12'\n" +
+	"\x0fmin_viewability\x18\x05 \x01(\x01R\x0eminViewability\x12\x1b\n" +
+	"\tuser_hash\x18\x06 \x01(\tR\buserHash\"\xee\x01\n" +
+	"\x14ReserveBudgetRequest\x12%\n" +
+	"\x0ereservation_id\x18\x01 \x01(\tR\rreservationId\x12\x1f\n" +
+	"\vcampaign_id\x18\x02 \x01(\tR\n" +
+	"campaignId\x12\x1c\n" +
+	"\tpublisher\x18\x03 \x01(\tR\tpublisher\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\tR\x06amount\x12\x1f\n" +
+	"\vttl_seconds\x18\x05 \x01(\rR\n" +
+	"ttlSeconds\x127\n" +
+	"\bmetadata\x18\x06 \x01(\v2\x1b.chainvm.v1.ReservationMetaR\bmetadata\"\x92\x01\n" +
+	"\x15ReserveBudgetResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x124\n" +
+	"\aexpires\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\aexpires\x12)\n" +
+	"\x10remaining_budget\x18\x03 \x01(\tR\x0fremainingBudget\"\x84\x01\n" +
+	"\x14SettleReceiptRequest\x12%\n" +
+	"\x0ereservation_id\x18\x01 \x01(\tR\rreservationId\x12-\n" +
+	"\x12verification_proof\x18\x02 \x01(\tR\x11verificationProof\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\tR\x06amount\"\xa8\x01\n" +
+	"\x15SettleReceiptResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x1f\n" +
+	"\vpaid_amount\x18\x02 \x01(\tR\n" +
+	"paidAmount\x12'\n" +
+	"\x0fholdback_amount\x18\x03 \x01(\tR\x0eholdbackAmount\x12+\n" +
+	"\x11publisher_balance\x18\x04 \x01(\tR\x10publisherBalance\"\xcc\x02\n" +
+	"\x13CreatePGDealRequest\x12\x1f\n" +
+	"\vcampaign_id\x18\x01 \x01(\tR\n" +
+	"campaignId\x12\x17\n" +
+	"\adeal_id\x18\x02 \x01(\tR\x06dealId\x12\x1c\n" +
+	"\tpublisher\x18\x03 \x01(\tR\tpublisher\x129\n" +
+	"\n" +
+	"start_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x12+\n" +
+	"\x11total_impressions\x18\x06 \x01(\x04R\x10totalImpressions\x12\x1b\n" +
+	"\tfixed_cpm\x18\a \x01(\tR\bfixedCpm\x12!\n" +
+	"\fpenalty_rate\x18\b \x01(\tR\vpenaltyRate\"n\n" +
+	"\x14CreatePGDealResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12#\n" +
+	"\rescrow_amount\x18\x02 \x01(\tR\fescrowAmount\x12\x17\n" +
+	"\adeal_id\x18\x03 \x01(\tR\x06dealId2\x9e\x05\n" +
+	"\aChainVM\x12Q\n" +
+	"\fCreateAdSlot\x12\x1f.chainvm.v1.CreateAdSlotRequest\x1a .chainvm.v1.CreateAdSlotResponse\x12K\n" +
+	"\n" +
+	"PlaceOrder\x12\x1d.chainvm.v1.PlaceOrderRequest\x1a\x1e.chainvm.v1.PlaceOrderResponse\x12H\n" +
+	"\tRevealBid\x12\x1c.chainvm.v1.RevealBidRequest\x1a\x1d.chainvm.v1.RevealBidResponse\x12W\n" +
+	"\x0eRecordDelivery\x12!.chainvm.v1.RecordDeliveryRequest\x1a\".chainvm.v1.RecordDeliveryResponse\x12Q\n" +
+	"\fFundCampaign\x12\x1f.chainvm.v1.FundCampaignRequest\x1a .chainvm.v1.FundCampaignResponse\x12T\n" +
+	"\rReserveBudget\x12 .chainvm.v1.ReserveBudgetRequest\x1a!.chainvm.v1.ReserveBudgetResponse\x12T\n" +
+	"\rSettleReceipt\x12 .chainvm.v1.SettleReceiptRequest\x1a!.chainvm.v1.SettleReceiptResponse\x12Q\n" +
+	"\fCreatePGDeal\x12\x1f.chainvm.v1.CreatePGDealRequest\x1a .chainvm.v1.CreatePGDealResponseB6Z4github.com/luxfi/adx/pkg/chainvm/chainvmpb;chainvmpbb\x06proto3"
+
+var (
+	file_chainvm_v1_chainvm_proto_rawDescOnce sync.Once
+	file_chainvm_v1_chainvm_proto_rawDescData []byte
+)
+
+func file_chainvm_v1_chainvm_proto_rawDescGZIP() []byte {
+	file_chainvm_v1_chainvm_proto_rawDescOnce.Do(func() {
+		file_chainvm_v1_chainvm_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_chainvm_v1_chainvm_proto_rawDesc), len(file_chainvm_v1_chainvm_proto_rawDesc)))
+	})
+	return file_chainvm_v1_chainvm_proto_rawDescData
+}
+
+var file_chainvm_v1_chainvm_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_chainvm_v1_chainvm_proto_goTypes = []any{
+	(*TargetingPredicate)(nil),     // 0: chainvm.v1.TargetingPredicate
+	(*CreateAdSlotRequest)(nil),    // 1: chainvm.v1.CreateAdSlotRequest
+	(*CreateAdSlotResponse)(nil),   // 2: chainvm.v1.CreateAdSlotResponse
+	(*PlaceOrderRequest)(nil),      // 3: chainvm.v1.PlaceOrderRequest
+	(*PlaceOrderResponse)(nil),     // 4: chainvm.v1.PlaceOrderResponse
+	(*RevealBidRequest)(nil),       // 5: chainvm.v1.RevealBidRequest
+	(*RevealBidResponse)(nil),      // 6: chainvm.v1.RevealBidResponse
+	(*RecordDeliveryRequest)(nil),  // 7: chainvm.v1.RecordDeliveryRequest
+	(*RecordDeliveryResponse)(nil), // 8: chainvm.v1.RecordDeliveryResponse
+	(*FundCampaignRequest)(nil),    // 9: chainvm.v1.FundCampaignRequest
+	(*FundCampaignResponse)(nil),   // 10: chainvm.v1.FundCampaignResponse
+	(*ReservationMeta)(nil),        // 11: chainvm.v1.ReservationMeta
+	(*ReserveBudgetRequest)(nil),   // 12: chainvm.v1.ReserveBudgetRequest
+	(*ReserveBudgetResponse)(nil),  // 13: chainvm.v1.ReserveBudgetResponse
+	(*SettleReceiptRequest)(nil),   // 14: chainvm.v1.SettleReceiptRequest
+	(*SettleReceiptResponse)(nil),  // 15: chainvm.v1.SettleReceiptResponse
+	(*CreatePGDealRequest)(nil),    // 16: chainvm.v1.CreatePGDealRequest
+	(*CreatePGDealResponse)(nil),   // 17: chainvm.v1.CreatePGDealResponse
+	(*timestamppb.Timestamp)(nil),  // 18: google.protobuf.Timestamp
+}
+var file_chainvm_v1_chainvm_proto_depIdxs = []int32{
+	0,  // 0: chainvm.v1.CreateAdSlotRequest.targeting:type_name -> chainvm.v1.TargetingPredicate
+	18, // 1: chainvm.v1.CreateAdSlotRequest.start_time:type_name -> google.protobuf.Timestamp
+	18, // 2: chainvm.v1.CreateAdSlotRequest.end_time:type_name -> google.protobuf.Timestamp
+	18, // 3: chainvm.v1.PlaceOrderRequest.expires_at:type_name -> google.protobuf.Timestamp
+	18, // 4: chainvm.v1.RecordDeliveryRequest.timestamp:type_name -> google.protobuf.Timestamp
+	18, // 5: chainvm.v1.FundCampaignRequest.flight_start:type_name -> google.protobuf.Timestamp
+	18, // 6: chainvm.v1.FundCampaignRequest.flight_end:type_name -> google.protobuf.Timestamp
+	11, // 7: chainvm.v1.ReserveBudgetRequest.metadata:type_name -> chainvm.v1.ReservationMeta
+	18, // 8: chainvm.v1.ReserveBudgetResponse.expires:type_name -> google.protobuf.Timestamp
+	18, // 9: chainvm.v1.CreatePGDealRequest.start_time:type_name -> google.protobuf.Timestamp
+	18, // 10: chainvm.v1.CreatePGDealRequest.end_time:type_name -> google.protobuf.Timestamp
+	1,  // 11: chainvm.v1.ChainVM.CreateAdSlot:input_type -> chainvm.v1.CreateAdSlotRequest
+	3,  // 12: chainvm.v1.ChainVM.PlaceOrder:input_type -> chainvm.v1.PlaceOrderRequest
+	5,  // 13: chainvm.v1.ChainVM.RevealBid:input_type -> chainvm.v1.RevealBidRequest
+	7,  // 14: chainvm.v1.ChainVM.RecordDelivery:input_type -> chainvm.v1.RecordDeliveryRequest
+	9,  // 15: chainvm.v1.ChainVM.FundCampaign:input_type -> chainvm.v1.FundCampaignRequest
+	12, // 16: chainvm.v1.ChainVM.ReserveBudget:input_type -> chainvm.v1.ReserveBudgetRequest
+	14, // 17: chainvm.v1.ChainVM.SettleReceipt:input_type -> chainvm.v1.SettleReceiptRequest
+	16, // 18: chainvm.v1.ChainVM.CreatePGDeal:input_type -> chainvm.v1.CreatePGDealRequest
+	2,  // 19: chainvm.v1.ChainVM.CreateAdSlot:output_type -> chainvm.v1.CreateAdSlotResponse
+	4,  // 20: chainvm.v1.ChainVM.PlaceOrder:output_type -> chainvm.v1.PlaceOrderResponse
+	6,  // 21: chainvm.v1.ChainVM.RevealBid:output_type -> chainvm.v1.RevealBidResponse
+	8,  // 22: chainvm.v1.ChainVM.RecordDelivery:output_type -> chainvm.v1.RecordDeliveryResponse
+	10, // 23: chainvm.v1.ChainVM.FundCampaign:output_type -> chainvm.v1.FundCampaignResponse
+	13, // 24: chainvm.v1.ChainVM.ReserveBudget:output_type -> chainvm.v1.ReserveBudgetResponse
+	15, // 25: chainvm.v1.ChainVM.SettleReceipt:output_type -> chainvm.v1.SettleReceiptResponse
+	17, // 26: chainvm.v1.ChainVM.CreatePGDeal:output_type -> chainvm.v1.CreatePGDealResponse
+	19, // [19:27] is the sub-list for method output_type
+	11, // [11:19] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_chainvm_v1_chainvm_proto_init() }
+func file_chainvm_v1_chainvm_proto_init() {
+	if File_chainvm_v1_chainvm_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chainvm_v1_chainvm_proto_rawDesc), len(file_chainvm_v1_chainvm_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_chainvm_v1_chainvm_proto_goTypes,
+		DependencyIndexes: file_chainvm_v1_chainvm_proto_depIdxs,
+		MessageInfos:      file_chainvm_v1_chainvm_proto_msgTypes,
+	}.Build()
+	File_chainvm_v1_chainvm_proto = out.File
+	file_chainvm_v1_chainvm_proto_goTypes = nil
+	file_chainvm_v1_chainvm_proto_depIdxs = nil
+}
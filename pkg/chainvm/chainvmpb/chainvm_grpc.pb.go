@@ -0,0 +1,387 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: chainvm/v1/chainvm.proto
+
+package chainvmpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ChainVM_CreateAdSlot_FullMethodName   = "/chainvm.v1.ChainVM/CreateAdSlot"
+	ChainVM_PlaceOrder_FullMethodName     = "/chainvm.v1.ChainVM/PlaceOrder"
+	ChainVM_RevealBid_FullMethodName      = "/chainvm.v1.ChainVM/RevealBid"
+	ChainVM_RecordDelivery_FullMethodName = "/chainvm.v1.ChainVM/RecordDelivery"
+	ChainVM_FundCampaign_FullMethodName   = "/chainvm.v1.ChainVM/FundCampaign"
+	ChainVM_ReserveBudget_FullMethodName  = "/chainvm.v1.ChainVM/ReserveBudget"
+	ChainVM_SettleReceipt_FullMethodName  = "/chainvm.v1.ChainVM/SettleReceipt"
+	ChainVM_CreatePGDeal_FullMethodName   = "/chainvm.v1.ChainVM/CreatePGDeal"
+)
+
+// ChainVMClient is the client API for ChainVM service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ChainVMClient interface {
+	CreateAdSlot(ctx context.Context, in *CreateAdSlotRequest, opts ...grpc.CallOption) (*CreateAdSlotResponse, error)
+	PlaceOrder(ctx context.Context, in *PlaceOrderRequest, opts ...grpc.CallOption) (*PlaceOrderResponse, error)
+	RevealBid(ctx context.Context, in *RevealBidRequest, opts ...grpc.CallOption) (*RevealBidResponse, error)
+	RecordDelivery(ctx context.Context, in *RecordDeliveryRequest, opts ...grpc.CallOption) (*RecordDeliveryResponse, error)
+	FundCampaign(ctx context.Context, in *FundCampaignRequest, opts ...grpc.CallOption) (*FundCampaignResponse, error)
+	ReserveBudget(ctx context.Context, in *ReserveBudgetRequest, opts ...grpc.CallOption) (*ReserveBudgetResponse, error)
+	SettleReceipt(ctx context.Context, in *SettleReceiptRequest, opts ...grpc.CallOption) (*SettleReceiptResponse, error)
+	CreatePGDeal(ctx context.Context, in *CreatePGDealRequest, opts ...grpc.CallOption) (*CreatePGDealResponse, error)
+}
+
+type chainVMClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChainVMClient(cc grpc.ClientConnInterface) ChainVMClient {
+	return &chainVMClient{cc}
+}
+
+func (c *chainVMClient) CreateAdSlot(ctx context.Context, in *CreateAdSlotRequest, opts ...grpc.CallOption) (*CreateAdSlotResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateAdSlotResponse)
+	err := c.cc.Invoke(ctx, ChainVM_CreateAdSlot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainVMClient) PlaceOrder(ctx context.Context, in *PlaceOrderRequest, opts ...grpc.CallOption) (*PlaceOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PlaceOrderResponse)
+	err := c.cc.Invoke(ctx, ChainVM_PlaceOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainVMClient) RevealBid(ctx context.Context, in *RevealBidRequest, opts ...grpc.CallOption) (*RevealBidResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevealBidResponse)
+	err := c.cc.Invoke(ctx, ChainVM_RevealBid_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainVMClient) RecordDelivery(ctx context.Context, in *RecordDeliveryRequest, opts ...grpc.CallOption) (*RecordDeliveryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RecordDeliveryResponse)
+	err := c.cc.Invoke(ctx, ChainVM_RecordDelivery_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainVMClient) FundCampaign(ctx context.Context, in *FundCampaignRequest, opts ...grpc.CallOption) (*FundCampaignResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FundCampaignResponse)
+	err := c.cc.Invoke(ctx, ChainVM_FundCampaign_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainVMClient) ReserveBudget(ctx context.Context, in *ReserveBudgetRequest, opts ...grpc.CallOption) (*ReserveBudgetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReserveBudgetResponse)
+	err := c.cc.Invoke(ctx, ChainVM_ReserveBudget_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainVMClient) SettleReceipt(ctx context.Context, in *SettleReceiptRequest, opts ...grpc.CallOption) (*SettleReceiptResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SettleReceiptResponse)
+	err := c.cc.Invoke(ctx, ChainVM_SettleReceipt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainVMClient) CreatePGDeal(ctx context.Context, in *CreatePGDealRequest, opts ...grpc.CallOption) (*CreatePGDealResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreatePGDealResponse)
+	err := c.cc.Invoke(ctx, ChainVM_CreatePGDeal_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChainVMServer is the server API for ChainVM service.
+// All implementations must embed UnimplementedChainVMServer
+// for forward compatibility.
+type ChainVMServer interface {
+	CreateAdSlot(context.Context, *CreateAdSlotRequest) (*CreateAdSlotResponse, error)
+	PlaceOrder(context.Context, *PlaceOrderRequest) (*PlaceOrderResponse, error)
+	RevealBid(context.Context, *RevealBidRequest) (*RevealBidResponse, error)
+	RecordDelivery(context.Context, *RecordDeliveryRequest) (*RecordDeliveryResponse, error)
+	FundCampaign(context.Context, *FundCampaignRequest) (*FundCampaignResponse, error)
+	ReserveBudget(context.Context, *ReserveBudgetRequest) (*ReserveBudgetResponse, error)
+	SettleReceipt(context.Context, *SettleReceiptRequest) (*SettleReceiptResponse, error)
+	CreatePGDeal(context.Context, *CreatePGDealRequest) (*CreatePGDealResponse, error)
+	mustEmbedUnimplementedChainVMServer()
+}
+
+// UnimplementedChainVMServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedChainVMServer struct{}
+
+func (UnimplementedChainVMServer) CreateAdSlot(context.Context, *CreateAdSlotRequest) (*CreateAdSlotResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAdSlot not implemented")
+}
+func (UnimplementedChainVMServer) PlaceOrder(context.Context, *PlaceOrderRequest) (*PlaceOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PlaceOrder not implemented")
+}
+func (UnimplementedChainVMServer) RevealBid(context.Context, *RevealBidRequest) (*RevealBidResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevealBid not implemented")
+}
+func (UnimplementedChainVMServer) RecordDelivery(context.Context, *RecordDeliveryRequest) (*RecordDeliveryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordDelivery not implemented")
+}
+func (UnimplementedChainVMServer) FundCampaign(context.Context, *FundCampaignRequest) (*FundCampaignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FundCampaign not implemented")
+}
+func (UnimplementedChainVMServer) ReserveBudget(context.Context, *ReserveBudgetRequest) (*ReserveBudgetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveBudget not implemented")
+}
+func (UnimplementedChainVMServer) SettleReceipt(context.Context, *SettleReceiptRequest) (*SettleReceiptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SettleReceipt not implemented")
+}
+func (UnimplementedChainVMServer) CreatePGDeal(context.Context, *CreatePGDealRequest) (*CreatePGDealResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePGDeal not implemented")
+}
+func (UnimplementedChainVMServer) mustEmbedUnimplementedChainVMServer() {}
+func (UnimplementedChainVMServer) testEmbeddedByValue()                 {}
+
+// UnsafeChainVMServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChainVMServer will
+// result in compilation errors.
+type UnsafeChainVMServer interface {
+	mustEmbedUnimplementedChainVMServer()
+}
+
+func RegisterChainVMServer(s grpc.ServiceRegistrar, srv ChainVMServer) {
+	// If the following call pancis, it indicates UnimplementedChainVMServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ChainVM_ServiceDesc, srv)
+}
+
+func _ChainVM_CreateAdSlot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAdSlotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainVMServer).CreateAdSlot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChainVM_CreateAdSlot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainVMServer).CreateAdSlot(ctx, req.(*CreateAdSlotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainVM_PlaceOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlaceOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainVMServer).PlaceOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChainVM_PlaceOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainVMServer).PlaceOrder(ctx, req.(*PlaceOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainVM_RevealBid_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevealBidRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainVMServer).RevealBid(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChainVM_RevealBid_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainVMServer).RevealBid(ctx, req.(*RevealBidRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainVM_RecordDelivery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordDeliveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainVMServer).RecordDelivery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChainVM_RecordDelivery_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainVMServer).RecordDelivery(ctx, req.(*RecordDeliveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainVM_FundCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FundCampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainVMServer).FundCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChainVM_FundCampaign_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainVMServer).FundCampaign(ctx, req.(*FundCampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainVM_ReserveBudget_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveBudgetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainVMServer).ReserveBudget(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChainVM_ReserveBudget_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainVMServer).ReserveBudget(ctx, req.(*ReserveBudgetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainVM_SettleReceipt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SettleReceiptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainVMServer).SettleReceipt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChainVM_SettleReceipt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainVMServer).SettleReceipt(ctx, req.(*SettleReceiptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainVM_CreatePGDeal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePGDealRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainVMServer).CreatePGDeal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChainVM_CreatePGDeal_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainVMServer).CreatePGDeal(ctx, req.(*CreatePGDealRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ChainVM_ServiceDesc is the grpc.ServiceDesc for ChainVM service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ChainVM_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chainvm.v1.ChainVM",
+	HandlerType: (*ChainVMServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateAdSlot",
+			Handler:    _ChainVM_CreateAdSlot_Handler,
+		},
+		{
+			MethodName: "PlaceOrder",
+			Handler:    _ChainVM_PlaceOrder_Handler,
+		},
+		{
+			MethodName: "RevealBid",
+			Handler:    _ChainVM_RevealBid_Handler,
+		},
+		{
+			MethodName: "RecordDelivery",
+			Handler:    _ChainVM_RecordDelivery_Handler,
+		},
+		{
+			MethodName: "FundCampaign",
+			Handler:    _ChainVM_FundCampaign_Handler,
+		},
+		{
+			MethodName: "ReserveBudget",
+			Handler:    _ChainVM_ReserveBudget_Handler,
+		},
+		{
+			MethodName: "SettleReceipt",
+			Handler:    _ChainVM_SettleReceipt_Handler,
+		},
+		{
+			MethodName: "CreatePGDeal",
+			Handler:    _ChainVM_CreatePGDeal_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "chainvm/v1/chainvm.proto",
+}
@@ -0,0 +1,124 @@
+package chainvm
+
+import (
+	"time"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+// HoldbackWindow is how long a settlement's holdback stays clawable
+const HoldbackWindow = 48 * time.Hour
+
+var (
+	ErrReservationNotFound = rpcerr.New(rpcerr.NotFound, "reservation_not_found", "reservation not found")
+	ErrNotSettled          = rpcerr.New(rpcerr.Precondition, "not_settled", "reservation not settled")
+)
+
+// Clawback is the AUSD returned to a campaign from a settled reservation
+type Clawback struct {
+	Holdback decimal.Decimal `json:"holdback"` // Withheld and never paid out
+	Reversed decimal.Decimal `json:"reversed"` // Taken back from the publisher balance
+}
+
+// Total is the amount refunded to the campaign
+func (c Clawback) Total() decimal.Decimal {
+	return c.Holdback.Add(c.Reversed)
+}
+
+// Reservation returns a copy of a stored reservation
+func (e *EscrowManager) Reservation(reservationID string) (*Reservation, bool) {
+	e.state.tx.RLock()
+	defer e.state.tx.RUnlock()
+
+	r, ok := e.state.GetReservation(reservationID)
+	if !ok {
+		return nil, false
+	}
+	cp := *r
+	return &cp, true
+}
+
+// ClawbackSettlement refunds a settled reservation to its campaign. The
+// pending holdback is cancelled; with reverseStreamed the payment already
+// streamed to the publisher is debited too, as far as their balance
+// covers it.
+func (e *EscrowManager) ClawbackSettlement(reservationID string, reverseStreamed bool) (Clawback, error) {
+	e.state.tx.Lock()
+	defer e.state.tx.Unlock()
+
+	var out Clawback
+
+	reservation, ok := e.state.GetReservation(reservationID)
+	if !ok {
+		return out, ErrReservationNotFound
+	}
+	if !reservation.Settled {
+		return out, ErrNotSettled
+	}
+
+	// Work out the refund first; nothing changes until it's recorded
+	refundable := reservation.Amount.Sub(reservation.Refunded)
+	release, pending := e.state.PendingRelease(reservationID)
+	if pending {
+		out.Holdback = decimal.Min(release.Amount, refundable)
+	}
+	balance := e.state.GetPublisherBalance(reservation.Publisher)
+	if reverseStreamed {
+		out.Reversed = decimal.Max(decimal.Zero, decimal.Min(refundable.Sub(out.Holdback), balance))
+	}
+
+	refund := out.Total()
+	if err := e.record(EntryDispute, reservationID,
+		Posting{Account: HoldbackAccount(reservation.Publisher), Amount: out.Holdback.Neg()},
+		Posting{Account: PublisherAccount(reservation.Publisher), Amount: out.Reversed.Neg()},
+		Posting{Account: CampaignAccount(reservation.CampaignID), Amount: refund},
+	); err != nil {
+		return Clawback{}, err
+	}
+
+	if pending {
+		e.state.TakePendingRelease(reservationID)
+	}
+	if out.Reversed.IsPositive() {
+		e.state.SetPublisherBalance(reservation.Publisher, balance.Sub(out.Reversed))
+	}
+	if refund.IsZero() {
+		return out, nil
+	}
+	reservation.Refunded = reservation.Refunded.Add(refund)
+	e.state.SetReservation(reservationID, reservation)
+
+	if campaign, ok := e.state.GetCampaign(reservation.CampaignID); ok {
+		campaign.SpentBudget = campaign.SpentBudget.Sub(refund)
+		campaign.AvailableBudget = campaign.AvailableBudget.Add(refund)
+		e.checkBudget(campaign)
+		e.state.SetCampaign(campaign.ID, campaign)
+	}
+
+	return out, nil
+}
+
+// ReleaseHoldback pays a settlement's pending holdback to the publisher
+// immediately, e.g. once a dispute against it is rejected
+func (e *EscrowManager) ReleaseHoldback(reservationID string) (decimal.Decimal, error) {
+	e.state.tx.Lock()
+	defer e.state.tx.Unlock()
+
+	reservation, ok := e.state.GetReservation(reservationID)
+	if !ok {
+		return decimal.Zero, ErrReservationNotFound
+	}
+
+	release, ok := e.state.PendingRelease(reservationID)
+	if !ok {
+		return decimal.Zero, nil
+	}
+	if err := e.record(EntryPayout, reservationID, transfer(HoldbackAccount(reservation.Publisher), PublisherAccount(reservation.Publisher), release.Amount)...); err != nil {
+		return decimal.Zero, err
+	}
+	e.state.TakePendingRelease(reservationID)
+	balance := e.state.GetPublisherBalance(reservation.Publisher)
+	e.state.SetPublisherBalance(reservation.Publisher, balance.Add(release.Amount))
+	return release.Amount, nil
+}
@@ -0,0 +1,44 @@
+package chainvm
+
+import (
+	"fmt"
+
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/shopspring/decimal"
+)
+
+// Deal implements analytics.DealLedger over PG deals. Settled spend is
+// what the deal's settled reservations paid, less any clawback, counting
+// reservations settled within tr.
+func (e *EscrowManager) Deal(dealID string, tr analytics.TimeRange) (analytics.DealTerms, error) {
+	e.state.tx.RLock()
+	defer e.state.tx.RUnlock()
+
+	for _, id := range e.state.CampaignIDs() {
+		campaign, ok := e.state.GetCampaign(id)
+		if !ok {
+			continue
+		}
+		for _, deal := range campaign.GuaranteedDeals {
+			if deal.ID != dealID {
+				continue
+			}
+			terms := analytics.DealTerms{
+				StartTime:         deal.StartTime,
+				EndTime:           deal.EndTime,
+				TargetImpressions: deal.TotalImprs,
+				FloorCPM:          deal.FixedCPM,
+				SettledSpend:      decimal.Zero,
+			}
+			for _, r := range e.state.CampaignReservations(id) {
+				if !r.Settled || r.Metadata.DealID != dealID ||
+					r.SettledAt.Before(tr.Start) || !r.SettledAt.Before(tr.End) {
+					continue
+				}
+				terms.SettledSpend = terms.SettledSpend.Add(r.Amount.Sub(r.Refunded))
+			}
+			return terms, nil
+		}
+	}
+	return analytics.DealTerms{}, fmt.Errorf("%w: %s", analytics.ErrUnknownDeal, dealID)
+}
@@ -0,0 +1,83 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/shopspring/decimal"
+)
+
+func TestDeal_ReconcilesWithSettlements(t *testing.T) {
+	ctx := context.Background()
+	e := newFundedEscrow(t, 100)
+	start := time.Now().Add(-time.Hour)
+	if _, err := e.CreatePGDeal(ctx, &CreatePGDealRequest{
+		CampaignID:       "c1",
+		DealID:           "pg-1",
+		Publisher:        "pub-1",
+		StartTime:        start,
+		EndTime:          start.Add(10 * time.Hour),
+		TotalImpressions: 1000,
+		FixedCPM:         decimal.NewFromInt(20),
+	}); err != nil {
+		t.Fatalf("CreatePGDeal: %v", err)
+	}
+
+	tracker := analytics.NewAnalyticsTracker()
+	tracker.SetDealLedger(e)
+
+	// Five deal impressions served and settled at the fixed CPM, plus one
+	// open-auction impression that isn't the deal's
+	for i := 0; i < 6; i++ {
+		id := fmt.Sprintf("r%d", i)
+		meta := ReservationMeta{DealID: "pg-1"}
+		if i == 5 {
+			meta.DealID = ""
+		}
+		if _, err := e.ReserveBudget(ctx, &ReserveBudgetRequest{
+			ReservationID: id,
+			CampaignID:    "c1",
+			Publisher:     "pub-1",
+			Amount:        decimal.RequireFromString("0.02"),
+			TTLSeconds:    10,
+			Metadata:      meta,
+		}); err != nil {
+			t.Fatalf("ReserveBudget: %v", err)
+		}
+		if _, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: id, VerificationProof: strings.Repeat("p", 32)}); err != nil {
+			t.Fatalf("SettleReceipt: %v", err)
+		}
+		tracker.TrackEvent(&analytics.Event{Type: analytics.EventImpression, DealID: meta.DealID, Price: decimal.NewFromInt(20)})
+	}
+
+	tr := analytics.TimeRange{Start: start, End: time.Now().Add(time.Minute)}
+	r, err := tracker.GetDealReport("pg-1", tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Impressions != 5 || !r.SettledSpend.Equal(decimal.RequireFromString("0.1")) || !r.Reconciled {
+		t.Errorf("impressions %d, spend %s, settled %s, reconciled %v", r.Impressions, r.Spend, r.SettledSpend, r.Reconciled)
+	}
+	// 100 of 1000 impressions were due an hour into a ten hour flight
+	if r.Target != 1000 || r.Expected < 100 || r.Expected > 101 || !r.UnderPacing {
+		t.Errorf("target %d, expected %d, under-pacing %v", r.Target, r.Expected, r.UnderPacing)
+	}
+
+	// A clawed-back impression no longer reconciles with the events
+	if _, err := e.ClawbackSettlement("r0", true); err != nil {
+		t.Fatalf("ClawbackSettlement: %v", err)
+	}
+	r, _ = tracker.GetDealReport("pg-1", tr)
+	if r.Reconciled || !r.SpendDelta.Equal(decimal.RequireFromString("0.02")) {
+		t.Errorf("after clawback: delta %s, reconciled %v", r.SpendDelta, r.Reconciled)
+	}
+
+	if _, err := tracker.GetDealReport("pg-2", tr); !errors.Is(err, analytics.ErrUnknownDeal) {
+		t.Errorf("unknown deal: err = %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package chainvm
+
+import "github.com/luxfi/adx/pkg/rpcerr"
+
+// Errors returned by the AdSlotManager and EscrowManager RPCs that aren't
+// declared next to the feature they belong to. rpcerr.Code and
+// rpcerr.IsRetryable classify any of them.
+var (
+	ErrSlotNotFound          = rpcerr.New(rpcerr.NotFound, "slot_not_found", "ad slot not found")
+	ErrOrderNotFound         = rpcerr.New(rpcerr.NotFound, "order_not_found", "order not found")
+	ErrPoolNotFound          = rpcerr.New(rpcerr.NotFound, "pool_not_found", "pool not found")
+	ErrPoolExists            = rpcerr.New(rpcerr.Conflict, "pool_exists", "pool already exists")
+	ErrSlotInactive          = rpcerr.New(rpcerr.Precondition, "slot_inactive", "slot inactive")
+	ErrSlotExpired           = rpcerr.New(rpcerr.Precondition, "slot_expired", "slot expired")
+	ErrOutsideWindow         = rpcerr.New(rpcerr.Precondition, "outside_window", "outside delivery window")
+	ErrExceedsCapacity       = rpcerr.New(rpcerr.Exhausted, "exceeds_capacity", "exceeds capacity")
+	ErrInsufficientLiquidity = rpcerr.New(rpcerr.Exhausted, "insufficient_liquidity", "insufficient liquidity")
+	ErrCommitRequired        = rpcerr.New(rpcerr.Invalid, "commit_required", "commit hash required")
+	ErrNotCommitReveal       = rpcerr.New(rpcerr.Precondition, "not_commit_reveal", "not a commit-reveal order")
+	ErrAlreadyRevealed       = rpcerr.New(rpcerr.Precondition, "already_revealed", "already revealed")
+	ErrInvalidReveal         = rpcerr.New(rpcerr.Invalid, "invalid_reveal", "invalid reveal")
+	ErrInvalidTargeting      = rpcerr.New(rpcerr.Invalid, "invalid_targeting", "invalid targeting")
+
+	// ErrBidBelowPrice is retryable: slot prices decay toward the window's
+	// end, so the same bid may clear later
+	ErrBidBelowPrice = rpcerr.NewRetryable(rpcerr.Precondition, "bid_below_price", "bid below current price")
+
+	ErrNotCampaignOwner   = rpcerr.New(rpcerr.PermissionDenied, "not_campaign_owner", "only campaign owner can fund")
+	ErrInsufficientFunds  = rpcerr.New(rpcerr.Precondition, "insufficient_funds", "insufficient AUSD balance")
+	ErrCampaignInactive   = rpcerr.New(rpcerr.Precondition, "campaign_inactive", "campaign inactive")
+	ErrInsufficientBudget = rpcerr.New(rpcerr.Exhausted, "insufficient_budget", "insufficient budget")
+	ErrReservationExists  = rpcerr.New(rpcerr.Conflict, "reservation_exists", "reservation already exists")
+	ErrReservationExpired = rpcerr.New(rpcerr.Precondition, "reservation_expired", "reservation expired")
+	ErrAlreadySettled     = rpcerr.New(rpcerr.Precondition, "already_settled", "already settled")
+	ErrExceedsReservation = rpcerr.New(rpcerr.Invalid, "exceeds_reservation", "amount exceeds reservation")
+	ErrInvalidProof       = rpcerr.New(rpcerr.Invalid, "invalid_proof", "delivery verification failed")
+	ErrNoLiquidityPath    = rpcerr.New(rpcerr.Precondition, "no_liquidity_path", "no AUSD liquidity path")
+	ErrSlippageExceeded   = rpcerr.NewRetryable(rpcerr.Precondition, "slippage_exceeded", "swap slippage exceeds tolerance")
+)
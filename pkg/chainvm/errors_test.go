@@ -0,0 +1,134 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+func TestRPCErrors_Typed(t *testing.T) {
+	ctx := context.Background()
+	a, slotID := newTestPool(t)
+	a.dex.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	e := NewEscrowManager(a.state, a.dex, "AUSD")
+	if _, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(50)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.ReserveBudget(ctx, &ReserveBudgetRequest{ReservationID: "r1", CampaignID: "c1", Publisher: "pub-1", Amount: decimal.NewFromInt(1), TTLSeconds: 5}); err != nil {
+		t.Fatal(err)
+	}
+	proof := strings.Repeat("p", 32)
+
+	tests := []struct {
+		name      string
+		call      func() error
+		want      error
+		retryable bool
+	}{
+		{"slot not found", func() error {
+			_, err := a.PlaceOrder(ctx, &PlaceOrderRequest{SlotID: 99, Quantity: 1})
+			return err
+		}, ErrSlotNotFound, false},
+		{"bid below price", func() error {
+			_, err := a.PlaceOrder(ctx, &PlaceOrderRequest{SlotID: slotID, IsBuy: true, Quantity: 1, LimitPrice: decimal.RequireFromString("0.0001")})
+			return err
+		}, ErrBidBelowPrice, true},
+		{"order not found", func() error {
+			_, err := a.RevealBid(ctx, &RevealBidRequest{OrderID: "nope"})
+			return err
+		}, ErrOrderNotFound, false},
+		{"pool exists", func() error {
+			_, err := a.CreateAdMM_Pool(ctx, &CreateAdMM_PoolRequest{SlotID: slotID, InitialAUSD: decimal.NewFromInt(1), InitialSlots: 1, LiquidityProvider: "pub-1"})
+			return err
+		}, ErrPoolExists, false},
+		{"pool not found", func() error {
+			_, err := a.SwapAdMM(ctx, &SwapAdMM_Request{SlotID: 99, AmountIn: decimal.NewFromInt(1)})
+			return err
+		}, ErrPoolNotFound, false},
+		{"exceeds capacity", func() error {
+			_, err := a.RecordDelivery(ctx, &RecordDeliveryRequest{SlotID: slotID, Count: 1001})
+			return err
+		}, ErrExceedsCapacity, false},
+		{"invalid window", func() error {
+			_, err := a.CreateAdSlot(ctx, &CreateAdSlotRequest{StartTime: time.Now().Add(time.Hour), EndTime: time.Now(), MaxImpressions: 1})
+			return err
+		}, ErrInvalidWindow, false},
+		{"not campaign owner", func() error {
+			_, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-2", Amount: decimal.NewFromInt(1)})
+			return err
+		}, ErrNotCampaignOwner, false},
+		{"insufficient funds", func() error {
+			_, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(500)})
+			return err
+		}, ErrInsufficientFunds, false},
+		{"TTL too long", func() error {
+			_, err := e.ReserveBudget(ctx, &ReserveBudgetRequest{ReservationID: "r2", CampaignID: "c1", Amount: decimal.NewFromInt(1), TTLSeconds: 60})
+			return err
+		}, ErrTTLTooLong, false},
+		{"reservation exists", func() error {
+			_, err := e.ReserveBudget(ctx, &ReserveBudgetRequest{ReservationID: "r1", CampaignID: "c1", Amount: decimal.NewFromInt(1), TTLSeconds: 5})
+			return err
+		}, ErrReservationExists, false},
+		{"campaign inactive", func() error {
+			_, err := e.ReserveBudget(ctx, &ReserveBudgetRequest{ReservationID: "r3", CampaignID: "c9", Amount: decimal.NewFromInt(1), TTLSeconds: 5})
+			return err
+		}, ErrCampaignInactive, false},
+		{"budget exhausted", func() error {
+			_, err := e.ReserveBudget(ctx, &ReserveBudgetRequest{ReservationID: "r4", CampaignID: "c1", Amount: decimal.NewFromInt(100), TTLSeconds: 5})
+			return err
+		}, ErrBudgetExhausted, false},
+		{"reservation not found", func() error {
+			_, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "nope", VerificationProof: proof})
+			return err
+		}, ErrReservationNotFound, false},
+		{"invalid proof", func() error {
+			_, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "r1", VerificationProof: "short"})
+			return err
+		}, ErrInvalidProof, false},
+		{"exceeds reservation", func() error {
+			_, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "r1", VerificationProof: proof, Amount: decimal.NewNullDecimal(decimal.NewFromInt(2))})
+			return err
+		}, ErrExceedsReservation, false},
+		{"insufficient budget", func() error {
+			_, err := e.CreatePGDeal(ctx, &CreatePGDealRequest{CampaignID: "c1", DealID: "pg", TotalImpressions: 1_000_000, FixedCPM: decimal.NewFromInt(10)})
+			return err
+		}, ErrInsufficientBudget, false},
+		{"campaign not found", func() error {
+			_, err := e.CreatePGDeal(ctx, &CreatePGDealRequest{CampaignID: "c9", DealID: "pg", TotalImpressions: 1, FixedCPM: decimal.NewFromInt(10)})
+			return err
+		}, ErrCampaignNotFound, false},
+		{"no liquidity path", func() error {
+			_, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(1), Asset: "DOGE"})
+			return err
+		}, ErrNoLiquidityPath, false},
+	}
+	for _, tt := range tests {
+		err := tt.call()
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if rpcerr.IsRetryable(err) != tt.retryable {
+			t.Errorf("%s: retryable = %v, want %v", tt.name, !tt.retryable, tt.retryable)
+		}
+		if want, _ := rpcerr.As(tt.want); rpcerr.Code(err) != want.Code {
+			t.Errorf("%s: code = %s, want %s", tt.name, rpcerr.Code(err), want.Code)
+		}
+	}
+
+	// Settling twice is a state conflict; after the first the second is terminal
+	if _, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "r1", VerificationProof: proof}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "r1", VerificationProof: proof}); !errors.Is(err, ErrAlreadySettled) || rpcerr.IsRetryable(err) {
+		t.Errorf("double settle: err = %v", err)
+	}
+	if !IsValidationError(ErrTTLTooLong) || IsValidationError(ErrAlreadySettled) {
+		t.Error("IsValidationError doesn't follow the error kind")
+	}
+}
@@ -16,20 +16,40 @@ type EscrowManager struct {
 	state  *VMState
 	dex    *dex.Engine
 	ausdID string
+
+	alerts      AlertSink
+	alertPolicy AlertPolicy
+	pacer       *Pacer
+	targeting   *TargetingEvaluator
+	idem        *idempotencyStore
+	ledger      *Ledger
+}
+
+// NewEscrowManager creates an escrow manager settling in the ausdID asset
+func NewEscrowManager(state *VMState, engine *dex.Engine, ausdID string) *EscrowManager {
+	return &EscrowManager{state: state, dex: engine, ausdID: ausdID, targeting: NewTargetingEvaluator(),
+		idem: newIdempotencyStore(DefaultIdempotencyWindow, DefaultIdempotencyCapacity)}
 }
 
 // Campaign represents a pre-funded advertising campaign
 type Campaign struct {
-	ID              string          `json:"id"`
-	Advertiser      string          `json:"advertiser"`
-	TotalBudget     decimal.Decimal `json:"total_budget"`
-	AvailableBudget decimal.Decimal `json:"available_budget"`
-	ReservedBudget  decimal.Decimal `json:"reserved_budget"`
-	SpentBudget     decimal.Decimal `json:"spent_budget"`
-	Active          bool            `json:"active"`
-	HoldbackBps     uint16          `json:"holdback_bps"` // Basis points for fraud protection
-	Created         time.Time       `json:"created"`
-	GuaranteedDeals []PGDeal        `json:"guaranteed_deals,omitempty"`
+	ID              string             `json:"id"`
+	Advertiser      string             `json:"advertiser"`
+	TotalBudget     decimal.Decimal    `json:"total_budget"`
+	AvailableBudget decimal.Decimal    `json:"available_budget"`
+	ReservedBudget  decimal.Decimal    `json:"reserved_budget"`
+	SpentBudget     decimal.Decimal    `json:"spent_budget"`
+	Active          bool               `json:"active"`
+	HoldbackBps     uint16             `json:"holdback_bps"` // Basis points for fraud protection
+	AutoPaused      bool               `json:"auto_paused,omitempty"`
+	AlertLevel      int                `json:"alert_level,omitempty"` // Budget alert thresholds already fired
+	FlightStart     time.Time          `json:"flight_start,omitempty"`
+	FlightEnd       time.Time          `json:"flight_end,omitempty"`
+	Pacing          PacingMode         `json:"pacing,omitempty"`
+	Targeting       TargetingPredicate `json:"targeting"`
+	Created         time.Time          `json:"created"`
+	GuaranteedDeals []PGDeal           `json:"guaranteed_deals,omitempty"`
+	Fundings        []Funding          `json:"fundings,omitempty"`
 }
 
 // Reservation represents atomic impression reservation with TTL
@@ -40,6 +60,8 @@ type Reservation struct {
 	Amount     decimal.Decimal `json:"amount"`
 	Expires    time.Time       `json:"expires"`
 	Settled    bool            `json:"settled"`
+	SettledAt  time.Time       `json:"settled_at,omitempty"`
+	Refunded   decimal.Decimal `json:"refunded,omitempty"` // Returned to the campaign at or after settlement
 	Metadata   ReservationMeta `json:"metadata"`
 }
 
@@ -51,6 +73,7 @@ type ReservationMeta struct {
 	Categories  []string `json:"categories"`
 	Viewability float64  `json:"min_viewability"`
 	UserHash    string   `json:"user_hash,omitempty"` // Privacy-preserving user identifier
+	DealID      string   `json:"deal_id,omitempty"`   // Deal the impression was bought on
 }
 
 // PGDeal represents programmatic guaranteed deal
@@ -70,12 +93,33 @@ type PGDeal struct {
 
 // FundCampaign - Pre-fund campaign in AUSD (eliminates payment risk)
 func (e *EscrowManager) FundCampaign(ctx context.Context, req *FundCampaignRequest) (*FundCampaignResponse, error) {
+	return idempotent(e.idem, "FundCampaign", req.IdempotencyKey, req, func() (*FundCampaignResponse, error) {
+		return e.fundCampaign(ctx, req)
+	})
+}
+
+func (e *EscrowManager) fundCampaign(ctx context.Context, req *FundCampaignRequest) (*FundCampaignResponse, error) {
+	e.state.tx.Lock()
+	defer e.state.tx.Unlock()
+
 	// Validate request
 	if req.Amount.LessThanOrEqual(decimal.Zero) {
-		return nil, fmt.Errorf("amount must be positive")
+		return nil, fmt.Errorf("amount: %w", ErrZeroAmount)
+	}
+	if err := checkAmount("amount", req.Amount); err != nil {
+		return nil, err
 	}
 	if req.HoldbackBps > 2000 {
-		return nil, fmt.Errorf("holdback cannot exceed 20%%")
+		return nil, fmt.Errorf("holdback_bps: %w (max 20%%)", ErrRateOutOfRange)
+	}
+	if req.MaxSlippageBps >= 10000 {
+		return nil, fmt.Errorf("max_slippage_bps: %w", ErrRateOutOfRange)
+	}
+	if err := checkPacing(req.Pacing, req.FlightStart, req.FlightEnd); err != nil {
+		return nil, err
+	}
+	if _, err := e.targeting.Compile(req.Targeting); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTargeting, err)
 	}
 
 	// Check/create campaign
@@ -85,6 +129,10 @@ func (e *EscrowManager) FundCampaign(ctx context.Context, req *FundCampaignReque
 			ID:              req.CampaignID,
 			Advertiser:      req.Advertiser,
 			HoldbackBps:     req.HoldbackBps,
+			FlightStart:     req.FlightStart,
+			FlightEnd:       req.FlightEnd,
+			Pacing:          req.Pacing,
+			Targeting:       req.Targeting,
 			Created:         time.Now(),
 			Active:          true,
 			TotalBudget:     decimal.Zero,
@@ -93,17 +141,29 @@ func (e *EscrowManager) FundCampaign(ctx context.Context, req *FundCampaignReque
 			SpentBudget:     decimal.Zero,
 		}
 	} else if campaign.Advertiser != req.Advertiser {
-		return nil, fmt.Errorf("only campaign owner can fund")
+		return nil, ErrNotCampaignOwner
 	}
 
-	// Execute AUSD transfer to escrow
-	if err := e.transferAUSD(req.Advertiser, "escrow", req.Amount); err != nil {
-		return nil, fmt.Errorf("AUSD transfer failed: %v", err)
+	// Swap other assets to AUSD, then transfer it to escrow
+	funding, err := e.fundingAUSD(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.transferAUSD(req.Advertiser, "escrow", funding.AUSD); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInsufficientFunds, err)
+	}
+	if err := e.record(EntryFunding, req.CampaignID, transfer(AdvertiserAccount(req.Advertiser), CampaignAccount(req.CampaignID), funding.AUSD)...); err != nil {
+		if rerr := e.transferAUSD("escrow", req.Advertiser, funding.AUSD); rerr != nil {
+			return nil, fmt.Errorf("%w; returning funds: %v", err, rerr)
+		}
+		return nil, err
 	}
 
 	// Update campaign budgets
-	campaign.TotalBudget = campaign.TotalBudget.Add(req.Amount)
-	campaign.AvailableBudget = campaign.AvailableBudget.Add(req.Amount)
+	campaign.TotalBudget = campaign.TotalBudget.Add(funding.AUSD)
+	campaign.AvailableBudget = campaign.AvailableBudget.Add(funding.AUSD)
+	campaign.Fundings = append(campaign.Fundings, funding)
+	e.checkBudget(campaign)
 
 	// Save state
 	e.state.SetCampaign(req.CampaignID, campaign)
@@ -112,30 +172,50 @@ func (e *EscrowManager) FundCampaign(ctx context.Context, req *FundCampaignReque
 		Success:         true,
 		NewTotalBudget:  campaign.TotalBudget,
 		AvailableBudget: campaign.AvailableBudget,
+		Funded:          funding.AUSD,
+		SwapRate:        funding.Rate,
 	}, nil
 }
 
 // ReserveBudget - Atomic reservation for impression (1-2s TTL)
 func (e *EscrowManager) ReserveBudget(ctx context.Context, req *ReserveBudgetRequest) (*ReserveBudgetResponse, error) {
+	return idempotent(e.idem, "ReserveBudget", req.IdempotencyKey, req, func() (*ReserveBudgetResponse, error) {
+		return e.reserveBudget(ctx, req)
+	})
+}
+
+func (e *EscrowManager) reserveBudget(ctx context.Context, req *ReserveBudgetRequest) (*ReserveBudgetResponse, error) {
+	e.state.tx.Lock()
+	defer e.state.tx.Unlock()
+
 	if req.TTLSeconds > 10 {
-		return nil, fmt.Errorf("TTL too long (max 10s)")
+		return nil, ErrTTLTooLong
 	}
 	if req.Amount.LessThanOrEqual(decimal.Zero) {
-		return nil, fmt.Errorf("amount must be positive")
+		return nil, fmt.Errorf("amount: %w", ErrZeroAmount)
+	}
+	if err := checkAmount("amount", req.Amount); err != nil {
+		return nil, err
 	}
 
 	// Check for duplicate reservation
 	if _, exists := e.state.GetReservation(req.ReservationID); exists {
-		return nil, fmt.Errorf("reservation already exists")
+		return nil, ErrReservationExists
 	}
 
 	// Validate campaign
 	campaign, exists := e.state.GetCampaign(req.CampaignID)
+	if exists && campaign.AutoPaused {
+		return nil, ErrBudgetExhausted
+	}
 	if !exists || !campaign.Active {
-		return nil, fmt.Errorf("campaign inactive")
+		return nil, ErrCampaignInactive
 	}
 	if campaign.AvailableBudget.LessThan(req.Amount) {
-		return nil, fmt.Errorf("insufficient budget")
+		return nil, ErrBudgetExhausted
+	}
+	if e.pacer != nil && !e.pacer.Allow(campaign, req.Amount, e.pacer.now()) {
+		return nil, ErrPacingThrottled
 	}
 
 	// Create reservation with TTL
@@ -149,9 +229,14 @@ func (e *EscrowManager) ReserveBudget(ctx context.Context, req *ReserveBudgetReq
 		Metadata:   req.Metadata,
 	}
 
+	if err := e.record(EntryReservation, req.ReservationID, transfer(CampaignAccount(req.CampaignID), EscrowAccount(req.CampaignID), req.Amount)...); err != nil {
+		return nil, err
+	}
+
 	// Lock budget atomically
 	campaign.AvailableBudget = campaign.AvailableBudget.Sub(req.Amount)
 	campaign.ReservedBudget = campaign.ReservedBudget.Add(req.Amount)
+	e.checkBudget(campaign)
 
 	// Save state
 	e.state.SetCampaign(req.CampaignID, campaign)
@@ -166,33 +251,64 @@ func (e *EscrowManager) ReserveBudget(ctx context.Context, req *ReserveBudgetReq
 
 // SettleReceipt - Pay publisher on verified delivery (T+0/T+1 settlement)
 func (e *EscrowManager) SettleReceipt(ctx context.Context, req *SettleReceiptRequest) (*SettleReceiptResponse, error) {
+	return idempotent(e.idem, "SettleReceipt", req.IdempotencyKey, req, func() (*SettleReceiptResponse, error) {
+		return e.settleReceipt(ctx, req)
+	})
+}
+
+func (e *EscrowManager) settleReceipt(ctx context.Context, req *SettleReceiptRequest) (*SettleReceiptResponse, error) {
+	e.state.tx.Lock()
+	defer e.state.tx.Unlock()
+
 	// Get reservation
 	reservation, exists := e.state.GetReservation(req.ReservationID)
 	if !exists {
-		return nil, fmt.Errorf("reservation not found")
+		return nil, ErrReservationNotFound
 	}
 	if reservation.Settled {
-		return nil, fmt.Errorf("already settled")
+		return nil, ErrAlreadySettled
 	}
 	if time.Now().After(reservation.Expires) {
-		return nil, fmt.Errorf("reservation expired")
+		return nil, ErrReservationExpired
 	}
 
 	// Verify delivery proof
 	if err := e.verifyDeliveryProof(req.VerificationProof, reservation); err != nil {
-		return nil, fmt.Errorf("delivery verification failed: %v", err)
+		return nil, err
+	}
+
+	// Settle the full reservation unless a lower amount was measured
+	amount := reservation.Amount
+	if req.Amount.Valid {
+		if err := checkAmount("amount", req.Amount.Decimal); err != nil {
+			return nil, err
+		}
+		if req.Amount.Decimal.GreaterThan(reservation.Amount) {
+			return nil, ErrExceedsReservation
+		}
+		amount = req.Amount.Decimal
 	}
+	unspent := reservation.Amount.Sub(amount)
 
 	// Get campaign
 	campaign, _ := e.state.GetCampaign(reservation.CampaignID)
 
 	// Calculate streaming settlement vs holdback
-	holdbackAmount := reservation.Amount.Mul(decimal.NewFromInt(int64(campaign.HoldbackBps))).Div(decimal.NewFromInt(10000))
-	immediateAmount := reservation.Amount.Sub(holdbackAmount)
+	holdbackAmount := amount.Mul(decimal.NewFromInt(int64(campaign.HoldbackBps))).Div(decimal.NewFromInt(10000))
+	immediateAmount := amount.Sub(holdbackAmount)
+
+	if err := e.recordSettlement(reservation, immediateAmount, holdbackAmount, unspent); err != nil {
+		return nil, err
+	}
 
-	// Update campaign accounting
+	// Update campaign accounting; any unspent part returns to available
 	campaign.ReservedBudget = campaign.ReservedBudget.Sub(reservation.Amount)
-	campaign.SpentBudget = campaign.SpentBudget.Add(reservation.Amount)
+	campaign.SpentBudget = campaign.SpentBudget.Add(amount)
+	if unspent.IsPositive() {
+		campaign.AvailableBudget = campaign.AvailableBudget.Add(unspent)
+		reservation.Refunded = unspent
+		e.checkBudget(campaign)
+	}
 
 	// Stream payment to publisher (T+0 settlement)
 	publisherBalance := e.state.GetPublisherBalance(reservation.Publisher)
@@ -201,11 +317,12 @@ func (e *EscrowManager) SettleReceipt(ctx context.Context, req *SettleReceiptReq
 
 	// Schedule holdback release (24-48hr fraud window)
 	if holdbackAmount.GreaterThan(decimal.Zero) {
-		e.scheduleHoldbackRelease(reservation.Publisher, holdbackAmount, 48*time.Hour)
+		e.scheduleHoldbackRelease(reservation, holdbackAmount, HoldbackWindow)
 	}
 
 	// Mark settled
 	reservation.Settled = true
+	reservation.SettledAt = time.Now()
 
 	// Save state
 	e.state.SetCampaign(reservation.CampaignID, campaign)
@@ -221,9 +338,22 @@ func (e *EscrowManager) SettleReceipt(ctx context.Context, req *SettleReceiptReq
 
 // CreatePGDeal - Create programmatic guaranteed deal with escrow
 func (e *EscrowManager) CreatePGDeal(ctx context.Context, req *CreatePGDealRequest) (*CreatePGDealResponse, error) {
+	e.state.tx.Lock()
+	defer e.state.tx.Unlock()
+
+	if err := checkQuantity("total_impressions", req.TotalImpressions); err != nil {
+		return nil, err
+	}
+	if err := checkAmount("fixed_cpm", req.FixedCPM); err != nil {
+		return nil, err
+	}
+	if err := checkRate("penalty_rate", req.PenaltyRate, 1); err != nil {
+		return nil, err
+	}
+
 	campaign, exists := e.state.GetCampaign(req.CampaignID)
 	if !exists {
-		return nil, fmt.Errorf("campaign not found")
+		return nil, ErrCampaignNotFound
 	}
 
 	// Calculate total escrow needed (impressions * CPM + penalty buffer)
@@ -232,7 +362,7 @@ func (e *EscrowManager) CreatePGDeal(ctx context.Context, req *CreatePGDealReque
 	escrowAmount := totalCost.Add(penaltyBuffer)
 
 	if campaign.AvailableBudget.LessThan(escrowAmount) {
-		return nil, fmt.Errorf("insufficient budget for PG deal")
+		return nil, fmt.Errorf("%w for PG deal", ErrInsufficientBudget)
 	}
 
 	deal := PGDeal{
@@ -246,9 +376,14 @@ func (e *EscrowManager) CreatePGDeal(ctx context.Context, req *CreatePGDealReque
 		PenaltyRate:  req.PenaltyRate,
 	}
 
+	if err := e.record(EntryReservation, req.DealID, transfer(CampaignAccount(req.CampaignID), EscrowAccount(req.CampaignID), escrowAmount)...); err != nil {
+		return nil, err
+	}
+
 	// Lock budget for PG deal
 	campaign.AvailableBudget = campaign.AvailableBudget.Sub(escrowAmount)
 	campaign.GuaranteedDeals = append(campaign.GuaranteedDeals, deal)
+	e.checkBudget(campaign)
 
 	e.state.SetCampaign(req.CampaignID, campaign)
 
@@ -261,6 +396,23 @@ func (e *EscrowManager) CreatePGDeal(ctx context.Context, req *CreatePGDealReque
 
 // Helper functions
 
+// recordSettlement posts a reservation's settlement as one balanced entry:
+// escrow pays the publisher, withholds the holdback and returns what's
+// unspent to the campaign
+func (e *EscrowManager) recordSettlement(reservation *Reservation, paid, holdback, unspent decimal.Decimal) error {
+	postings := []Posting{
+		{Account: EscrowAccount(reservation.CampaignID), Amount: paid.Add(holdback).Add(unspent).Neg()},
+		{Account: PublisherAccount(reservation.Publisher), Amount: paid},
+	}
+	if holdback.IsPositive() {
+		postings = append(postings, Posting{Account: HoldbackAccount(reservation.Publisher), Amount: holdback})
+	}
+	if unspent.IsPositive() {
+		postings = append(postings, Posting{Account: CampaignAccount(reservation.CampaignID), Amount: unspent})
+	}
+	return e.record(EntrySettlement, reservation.ID, postings...)
+}
+
 func (e *EscrowManager) transferAUSD(from, to string, amount decimal.Decimal) error {
 	// Interface with DEX engine for AUSD transfers
 	return e.dex.TransferAsset(e.ausdID, from, to, amount)
@@ -274,21 +426,26 @@ func (e *EscrowManager) verifyDeliveryProof(proof string, reservation *Reservati
 
 	// Simplified verification - production would use more sophisticated proof system
 	if len(proof) < 32 {
-		return fmt.Errorf("invalid proof format")
+		return fmt.Errorf("%w: invalid proof format", ErrInvalidProof)
 	}
 
 	// Verify proof contains reservation ID (anti-replay)
 	if len(proofHash) != len(expectedHash) {
-		return fmt.Errorf("proof verification failed")
+		return ErrInvalidProof
 	}
 
 	return nil
 }
 
-func (e *EscrowManager) scheduleHoldbackRelease(publisher string, amount decimal.Decimal, delay time.Duration) {
+func (e *EscrowManager) scheduleHoldbackRelease(reservation *Reservation, amount decimal.Decimal, delay time.Duration) {
 	// In production: create timelock transaction for holdback release
 	// For now, add to pending releases
-	e.state.AddPendingRelease(publisher, amount, time.Now().Add(delay))
+	e.state.addRelease(PendingRelease{
+		Publisher:     reservation.Publisher,
+		Amount:        amount,
+		ReleaseTime:   time.Now().Add(delay),
+		ReservationID: reservation.ID,
+	})
 }
 
 // Request/Response types for RPC
@@ -298,12 +455,30 @@ type FundCampaignRequest struct {
 	Advertiser  string          `json:"advertiser"`
 	Amount      decimal.Decimal `json:"amount"`
 	HoldbackBps uint16          `json:"holdback_bps"`
+
+	// Asset is what Amount is denominated in, AUSD if empty. Other assets
+	// are swapped to AUSD on the dex, paying at least the spot price less
+	// MaxSlippageBps (DefaultFundingSlippageBps if zero).
+	Asset          string `json:"asset,omitempty"`
+	MaxSlippageBps uint16 `json:"max_slippage_bps,omitempty"`
+
+	// Flight, pacing and targeting apply when the campaign is first funded
+	FlightStart time.Time          `json:"flight_start,omitempty"`
+	FlightEnd   time.Time          `json:"flight_end,omitempty"`
+	Pacing      PacingMode         `json:"pacing,omitempty"`
+	Targeting   TargetingPredicate `json:"targeting"`
+
+	// IdempotencyKey, if set, makes retries with the same key return the
+	// first response instead of repeating the state change
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 type FundCampaignResponse struct {
 	Success         bool            `json:"success"`
 	NewTotalBudget  decimal.Decimal `json:"new_total_budget"`
 	AvailableBudget decimal.Decimal `json:"available_budget"`
+	Funded          decimal.Decimal `json:"funded"`    // AUSD credited
+	SwapRate        decimal.Decimal `json:"swap_rate"` // AUSD per unit of the funding asset
 }
 
 type ReserveBudgetRequest struct {
@@ -313,6 +488,8 @@ type ReserveBudgetRequest struct {
 	Amount        decimal.Decimal `json:"amount"`
 	TTLSeconds    uint32          `json:"ttl_seconds"`
 	Metadata      ReservationMeta `json:"metadata"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // As for FundCampaignRequest
 }
 
 type ReserveBudgetResponse struct {
@@ -324,6 +501,12 @@ type ReserveBudgetResponse struct {
 type SettleReceiptRequest struct {
 	ReservationID     string `json:"reservation_id"`
 	VerificationProof string `json:"verification_proof"`
+
+	// Amount, if set, settles less than the reserved amount (e.g. priced by
+	// measured viewability); the rest returns to the campaign
+	Amount decimal.NullDecimal `json:"amount"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // As for FundCampaignRequest
 }
 
 type SettleReceiptResponse struct {
@@ -0,0 +1,171 @@
+package chainvm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SlotExpiry records how an ad slot closed out once its window passed,
+// for publisher reporting on unsold inventory
+type SlotExpiry struct {
+	SlotID         uint64          `json:"slot_id"`
+	Publisher      string          `json:"publisher"`
+	Placement      string          `json:"placement"`
+	EndTime        time.Time       `json:"end_time"`
+	MaxImpressions uint64          `json:"max_impressions"`
+	Delivered      uint64          `json:"delivered"`
+	Unsold         uint64          `json:"unsold"`         // Impressions never delivered
+	UnsoldFloor    decimal.Decimal `json:"unsold_floor"`   // Unsold impressions at the floor CPM
+	Burned         decimal.Decimal `json:"burned"`         // Slot tokens burned, across every holder
+	ExpiredOrders  int             `json:"expired_orders"` // Resting orders closed
+	PoolSlots      uint64          `json:"pool_slots"`     // Slot reserve the pool wrote off
+	SettledAt      time.Time       `json:"settled_at"`
+}
+
+// SetSlotExpiry stores a slot's expiry record
+func (v *VMState) SetSlotExpiry(e *SlotExpiry) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.slotExpiries == nil {
+		v.slotExpiries = make(map[uint64]*SlotExpiry)
+	}
+	v.slotExpiries[e.SlotID] = e
+}
+
+// GetSlotExpiry retrieves a slot's expiry record
+func (v *VMState) GetSlotExpiry(slotID uint64) (*SlotExpiry, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	e, ok := v.slotExpiries[slotID]
+	return e, ok
+}
+
+// AdSlotIDs returns the IDs of all stored ad slots, sorted
+func (v *VMState) AdSlotIDs() []uint64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	ids := make([]uint64, 0, len(v.adSlots))
+	for id := range v.adSlots {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// ExpireSlots closes out every active slot whose window ended by now:
+// the slot is deactivated, its undelivered tokens are burned wherever they
+// are held, resting orders on it expire and its pool's slot reserve is
+// written off to its final value of zero, leaving LPs the AUSD. Slots
+// already closed out are skipped, so sweeping again is harmless.
+func (a *AdSlotManager) ExpireSlots(now time.Time) []*SlotExpiry {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
+	var expired []*SlotExpiry
+	for _, id := range a.state.AdSlotIDs() {
+		slot, err := a.state.GetAdSlot(id)
+		if err != nil {
+			continue
+		}
+		if now.Before(slot.EndTime) {
+			continue
+		}
+		if _, done := a.state.GetSlotExpiry(id); done {
+			continue
+		}
+		expired = append(expired, a.expireSlot(slot, now))
+	}
+	return expired
+}
+
+func (a *AdSlotManager) expireSlot(slot *AdSlot, now time.Time) *SlotExpiry {
+	unsold := slot.MaxImpressions - slot.DeliveredImprs
+	rec := &SlotExpiry{
+		SlotID:         slot.ID,
+		Publisher:      slot.Publisher,
+		Placement:      slot.Placement,
+		EndTime:        slot.EndTime,
+		MaxImpressions: slot.MaxImpressions,
+		Delivered:      slot.DeliveredImprs,
+		Unsold:         unsold,
+		UnsoldFloor:    slot.FloorCPM.Mul(decimal.NewFromInt(int64(unsold))).Div(decimal.NewFromInt(1000)),
+		SettledAt:      now,
+	}
+
+	slot.Active = false
+	a.state.SetAdSlot(slot)
+
+	// Delivery burns tokens as it goes, so what's left is the unsold supply
+	rec.Burned = a.dex.BurnSupply(fmt.Sprintf("adslot-%d", slot.ID))
+
+	for _, o := range a.state.SlotOrders(slot.ID) {
+		if o.Status == "active" {
+			o.Status = "expired"
+			a.state.SetAdSlotOrder(o)
+			rec.ExpiredOrders++
+		}
+	}
+
+	if pool, ok := a.state.GetAdMM_Pool(slot.ID); ok && !pool.Closed {
+		rec.PoolSlots = pool.ReserveSlots
+		pool.ReserveSlots = 0
+		pool.FeesSlots = decimal.Zero
+		pool.LastPrice = decimal.Zero
+		pool.Closed = true
+		a.state.SetAdMM_Pool(slot.ID, pool)
+	}
+
+	a.state.SetSlotExpiry(rec)
+	return rec
+}
+
+// UnsoldInventory returns a publisher's expired slots with their unsold
+// impressions, oldest slot first
+func (a *AdSlotManager) UnsoldInventory(publisher string) []*SlotExpiry {
+	a.state.tx.RLock()
+	defer a.state.tx.RUnlock()
+
+	var out []*SlotExpiry
+	for _, id := range a.state.AdSlotIDs() {
+		if e, ok := a.state.GetSlotExpiry(id); ok && e.Publisher == publisher {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ExpirySweeper periodically closes out expired ad slots
+type ExpirySweeper struct {
+	Slots    *AdSlotManager
+	Interval time.Duration
+
+	// OnExpire is called for each slot closed out
+	OnExpire func(*SlotExpiry)
+}
+
+// Run sweeps every Interval until ctx is cancelled
+func (s *ExpirySweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.sweepOnce(now)
+		}
+	}
+}
+
+func (s *ExpirySweeper) sweepOnce(now time.Time) {
+	for _, e := range s.Slots.ExpireSlots(now) {
+		if s.OnExpire != nil {
+			s.OnExpire(e)
+		}
+	}
+}
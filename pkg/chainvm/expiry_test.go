@@ -0,0 +1,122 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+func TestExpireSlots_ClosesOutPartialDelivery(t *testing.T) {
+	ctx := context.Background()
+	engine := dex.NewEngine()
+	a := NewAdSlotManager(&VMState{}, engine)
+	now := time.Now()
+	slot, err := a.CreateAdSlot(ctx, &CreateAdSlotRequest{
+		Publisher:      "pub-1",
+		StartTime:      now.Add(-time.Hour),
+		EndTime:        now.Add(time.Hour),
+		MaxImpressions: 1000,
+		FloorCPM:       decimal.NewFromInt(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := slot.SlotID
+	token := slot.TokenID
+
+	if _, err := a.RecordDelivery(ctx, &RecordDeliveryRequest{AdSlotID: id, SlotID: id, Count: 400}); err != nil {
+		t.Fatal(err)
+	}
+	// Part of the residual supply sits with a buyer
+	if err := engine.TransferAsset(token, "pub-1", "dsp-1", decimal.NewFromInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.PlaceOrder(ctx, &PlaceOrderRequest{OrderID: "o-1", TraderID: "dsp-1", SlotID: id, IsBuy: true, OrderType: "buy", LimitPrice: decimal.NewFromInt(5), Quantity: 50}); err != nil {
+		t.Fatal(err)
+	}
+	engine.SetBalance("AUSD", "lp-1", decimal.NewFromInt(500))
+	if err := engine.TransferAsset(token, "pub-1", "lp-1", decimal.NewFromInt(200)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.CreateAdMM_Pool(ctx, &CreateAdMM_PoolRequest{SlotID: id, InitialAUSD: decimal.NewFromInt(500), InitialSlots: 200, LiquidityProvider: "lp-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := a.ExpireSlots(now); len(got) != 0 {
+		t.Fatalf("expired %d slots before the window ended", len(got))
+	}
+
+	expired := a.ExpireSlots(now.Add(2 * time.Hour))
+	if len(expired) != 1 {
+		t.Fatalf("expired %d slots, want 1", len(expired))
+	}
+	rec := expired[0]
+	if rec.Delivered != 400 || rec.Unsold != 600 || !rec.UnsoldFloor.Equal(decimal.RequireFromString("1.2")) {
+		t.Errorf("record = delivered %d, unsold %d worth %s", rec.Delivered, rec.Unsold, rec.UnsoldFloor)
+	}
+	if !rec.Burned.Equal(decimal.NewFromInt(600)) || rec.ExpiredOrders != 1 || rec.PoolSlots != 200 {
+		t.Errorf("record = burned %s, %d orders, %d pool slots", rec.Burned, rec.ExpiredOrders, rec.PoolSlots)
+	}
+
+	if s, _ := a.state.GetAdSlot(id); s.Active {
+		t.Error("slot still active")
+	}
+	for _, holder := range []string{"pub-1", "dsp-1"} {
+		if b := engine.GetBalance(token, holder); !b.IsZero() {
+			t.Errorf("%s still holds %s slot tokens", holder, b)
+		}
+	}
+	if o, _ := a.state.GetAdSlotOrder("o-1"); o.Status != "expired" {
+		t.Errorf("order status = %q", o.Status)
+	}
+	pool, _ := a.state.GetAdMM_Pool(id)
+	if !pool.Closed || pool.ReserveSlots != 0 || !pool.LastPrice.IsZero() {
+		t.Errorf("pool = closed %v, %d slots at %s", pool.Closed, pool.ReserveSlots, pool.LastPrice)
+	}
+	if _, err := a.SwapAdMM(ctx, &SwapAdMM_Request{SlotID: id, AmountIn: decimal.NewFromInt(10)}); !errors.Is(err, ErrSlotExpired) {
+		t.Errorf("swap on a closed pool: %v", err)
+	}
+	lp, _ := a.RemoveLiquidity(WithCaller(ctx, "lp-1"), id, pool.LPTokenSupply)
+	if lp == nil || !lp.AUSDOut.Equal(decimal.NewFromInt(500)) || lp.SlotsOut != 0 {
+		t.Errorf("closing LP withdrew %+v, want the AUSD alone", lp)
+	}
+
+	// Idempotent
+	if again := a.ExpireSlots(now.Add(3 * time.Hour)); len(again) != 0 {
+		t.Errorf("second sweep expired %d slots", len(again))
+	}
+	if report := a.UnsoldInventory("pub-1"); len(report) != 1 || report[0] != rec {
+		t.Errorf("unsold inventory = %v", report)
+	}
+}
+
+func TestExpirySweeper_Run(t *testing.T) {
+	a := NewAdSlotManager(&VMState{}, dex.NewEngine())
+	now := time.Now()
+	if _, err := a.CreateAdSlot(context.Background(), &CreateAdSlotRequest{
+		Publisher:      "pub-1",
+		StartTime:      now,
+		EndTime:        now.Add(20 * time.Millisecond),
+		MaxImpressions: 10,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan *SlotExpiry, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go (&ExpirySweeper{Slots: a, Interval: 10 * time.Millisecond, OnExpire: func(e *SlotExpiry) { got <- e }}).Run(ctx)
+
+	select {
+	case e := <-got:
+		if e.Unsold != 10 {
+			t.Errorf("unsold = %d, want 10", e.Unsold)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sweeper never expired the slot")
+	}
+}
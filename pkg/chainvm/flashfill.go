@@ -0,0 +1,258 @@
+package chainvm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+// FlashLoanFeeBps is the lender's fee on a flash borrow, in basis points of
+// the borrowed inventory's ask value
+const FlashLoanFeeBps = 30
+
+var (
+	// ErrNoFlashListing is returned when the lender has no flash-borrowable
+	// listing covering the quantity
+	ErrNoFlashListing = rpcerr.New(rpcerr.NotFound, "no_flash_listing", "no flash loan listing")
+
+	// ErrFlashUnderpaid is returned, and the fill reverted, when settlement
+	// proceeds don't cover the borrow plus fee
+	ErrFlashUnderpaid = rpcerr.New(rpcerr.Precondition, "flash_underpaid", "flash fill proceeds do not cover repayment")
+
+	// ErrFlashNoEscrow is returned when the manager has no escrow sharing
+	// its state to pay flash fills from
+	ErrFlashNoEscrow = rpcerr.New(rpcerr.Precondition, "flash_no_escrow", "no escrow to pay flash fills from")
+
+	// ErrNotReservationPublisher is returned when a borrower delivers
+	// against a reservation made for another publisher
+	ErrNotReservationPublisher = rpcerr.New(rpcerr.PermissionDenied, "not_reservation_publisher", "reservation is for another publisher")
+)
+
+// FlashFillRequest borrows a lender's listed slot tokens, delivers them and
+// repays the lender from the payment for the delivery. The advertiser pays
+// through a budget reservation they made for the borrower, settled in full.
+type FlashFillRequest struct {
+	SlotID        uint64 `json:"slot_id"`
+	Lender        string `json:"lender"`         // Seller of a FlashLoanOK listing
+	Borrower      string `json:"borrower"`       // Needs no AUSD up front
+	ReservationID string `json:"reservation_id"` // The advertiser's reservation for the borrower
+	Quantity      uint64 `json:"quantity"`       // Impressions delivered
+}
+
+type FlashFillResponse struct {
+	Success  bool            `json:"success"`
+	Proceeds decimal.Decimal `json:"proceeds"` // Paid to the borrower
+	Holdback decimal.Decimal `json:"holdback"` // Withheld for the fraud window, released to the borrower later
+	Repaid   decimal.Decimal `json:"repaid"`   // Paid to the lender, fee included
+	Fee      decimal.Decimal `json:"fee"`
+	Profit   decimal.Decimal `json:"profit"` // Left with the borrower
+}
+
+// FlashFill borrows slot tokens, records their delivery (burning them),
+// settles the reservation to the borrower out of escrow and repays the
+// lender the ask value plus FlashLoanFeeBps, as one transaction. If any
+// step fails or the borrower would end up short, every step is undone.
+func (a *AdSlotManager) FlashFill(ctx context.Context, req *FlashFillRequest) (*FlashFillResponse, error) {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
+	if a.Escrow == nil || a.Escrow.state != a.state {
+		return nil, ErrFlashNoEscrow
+	}
+	if err := checkQuantity("quantity", req.Quantity); err != nil {
+		return nil, err
+	}
+	reservation, err := a.Escrow.flashReservation(req.ReservationID, req.Borrower)
+	if err != nil {
+		return nil, err
+	}
+
+	slot, err := a.state.GetAdSlot(req.SlotID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if now.Before(slot.StartTime) || now.After(slot.EndTime) {
+		return nil, ErrOutsideWindow
+	}
+	if req.Quantity > slot.MaxImpressions-slot.DeliveredImprs {
+		return nil, ErrExceedsCapacity
+	}
+	listing := flashListing(slot, req.Lender, req.Quantity)
+	if listing == nil {
+		return nil, ErrNoFlashListing
+	}
+
+	qty := decimal.NewFromInt(int64(req.Quantity))
+	principal := listing.AskPrice.Mul(qty).Div(decimal.NewFromInt(1000))
+	fee := principal.Mul(decimal.NewFromInt(FlashLoanFeeBps)).Div(decimal.NewFromInt(10000))
+	repay := principal.Add(fee)
+
+	token := fmt.Sprintf("adslot-%d", slot.ID)
+	ausd := a.SettlementAsset
+	ausdBefore := a.dex.GetBalance(ausd, req.Borrower)
+	tokensBefore := a.dex.GetBalance(token, req.Borrower)
+
+	tx := &dexTx{dex: a.dex}
+	fail := func(err error) (*FlashFillResponse, error) {
+		tx.rollback()
+		return nil, err
+	}
+
+	// Borrow
+	if err := tx.transfer(token, req.Lender, req.Borrower, qty); err != nil {
+		return fail(fmt.Errorf("borrow: %v", err))
+	}
+	listing.Quantity -= req.Quantity
+	delivered := slot.DeliveredImprs
+	tx.undo = append(tx.undo, func() {
+		listing.Quantity += req.Quantity
+		slot.DeliveredImprs = delivered
+	})
+
+	// Deliver
+	slot.DeliveredImprs += req.Quantity
+	if err := tx.burn(token, req.Borrower, qty); err != nil {
+		return fail(fmt.Errorf("deliver: %v", err))
+	}
+
+	// Settle
+	proceeds, holdback, err := a.Escrow.settleFlash(tx, reservation)
+	if err != nil {
+		return fail(fmt.Errorf("settle: %w", err))
+	}
+
+	// Repay, from the proceeds alone
+	if proceeds.LessThan(repay) {
+		return fail(fmt.Errorf("%w: proceeds %s, owed %s", ErrFlashUnderpaid, proceeds, repay))
+	}
+	if repay.IsPositive() {
+		if err := tx.transfer(ausd, req.Borrower, req.Lender, repay); err != nil {
+			return fail(fmt.Errorf("repay: %v", err))
+		}
+	}
+
+	// The borrower must hold no borrowed tokens and no less AUSD than
+	// before, or the lender was repaid out of pocket
+	profit := a.dex.GetBalance(ausd, req.Borrower).Sub(ausdBefore)
+	if profit.IsNegative() || !a.dex.GetBalance(token, req.Borrower).Equal(tokensBefore) {
+		return fail(fmt.Errorf("%w: post-state unbalanced", ErrFlashUnderpaid))
+	}
+
+	// The ledger entry goes last, as the one step that can't be undone
+	if err := a.Escrow.recordSettlement(reservation, proceeds, holdback, decimal.Zero); err != nil {
+		return fail(err)
+	}
+	if holdback.IsPositive() {
+		a.Escrow.scheduleHoldbackRelease(reservation, holdback, HoldbackWindow)
+	}
+	a.state.SetAdSlot(slot)
+	return &FlashFillResponse{
+		Success:  true,
+		Proceeds: proceeds,
+		Holdback: holdback,
+		Repaid:   repay,
+		Fee:      fee,
+		Profit:   profit,
+	}, nil
+}
+
+// flashReservation returns the open reservation id made for publisher;
+// e.state.tx must be held
+func (e *EscrowManager) flashReservation(id, publisher string) (*Reservation, error) {
+	reservation, ok := e.state.GetReservation(id)
+	switch {
+	case !ok:
+		return nil, ErrReservationNotFound
+	case reservation.Settled:
+		return nil, ErrAlreadySettled
+	case time.Now().After(reservation.Expires):
+		return nil, ErrReservationExpired
+	case reservation.Publisher != publisher:
+		return nil, ErrNotReservationPublisher
+	}
+	if _, ok := e.state.GetCampaign(reservation.CampaignID); !ok {
+		return nil, ErrCampaignNotFound
+	}
+	return reservation, nil
+}
+
+// settleFlash settles reservation in full as part of tx, paying all but
+// the campaign's holdback from escrow to the publisher's AUSD account
+func (e *EscrowManager) settleFlash(tx *dexTx, reservation *Reservation) (paid, holdback decimal.Decimal, err error) {
+	campaign, _ := e.state.GetCampaign(reservation.CampaignID)
+	holdback = reservation.Amount.Mul(decimal.NewFromInt(int64(campaign.HoldbackBps))).Div(decimal.NewFromInt(10000))
+	paid = reservation.Amount.Sub(holdback)
+	if paid.IsPositive() {
+		if err := tx.transfer(e.ausdID, "escrow", reservation.Publisher, paid); err != nil {
+			return decimal.Zero, decimal.Zero, err
+		}
+	}
+
+	reserved, spent := campaign.ReservedBudget, campaign.SpentBudget
+	campaign.ReservedBudget = reserved.Sub(reservation.Amount)
+	campaign.SpentBudget = spent.Add(reservation.Amount)
+	reservation.Settled, reservation.SettledAt = true, time.Now()
+	tx.undo = append(tx.undo, func() {
+		campaign.ReservedBudget, campaign.SpentBudget = reserved, spent
+		reservation.Settled, reservation.SettledAt = false, time.Time{}
+	})
+	return paid, holdback, nil
+}
+
+// flashListing finds the lender's flash-borrowable listing covering qty
+func flashListing(slot *AdSlot, lender string, qty uint64) *SecondaryListing {
+	for i := range slot.SecondaryMarkets {
+		l := &slot.SecondaryMarkets[i]
+		if l.SellerID == lender && l.FlashLoanOK && l.Quantity >= qty {
+			return l
+		}
+	}
+	return nil
+}
+
+// dexTx applies dex and state changes that can be undone as a unit
+type dexTx struct {
+	dex  *dex.Engine
+	undo []func()
+}
+
+// transfer moves amount, nothing for a zero amount
+func (tx *dexTx) transfer(asset, from, to string, amount decimal.Decimal) error {
+	if amount.IsZero() {
+		return nil
+	}
+	if err := tx.dex.TransferAsset(asset, from, to, amount); err != nil {
+		return err
+	}
+	tx.undo = append(tx.undo, func() { tx.dex.TransferAsset(asset, to, from, amount) })
+	return nil
+}
+
+func (tx *dexTx) mint(asset, account string, amount decimal.Decimal) error {
+	if err := tx.dex.MintAsset(asset, account, amount); err != nil {
+		return err
+	}
+	tx.undo = append(tx.undo, func() { tx.dex.BurnAsset(asset, account, amount) })
+	return nil
+}
+
+func (tx *dexTx) burn(asset, account string, amount decimal.Decimal) error {
+	if err := tx.dex.BurnAsset(asset, account, amount); err != nil {
+		return err
+	}
+	tx.undo = append(tx.undo, func() { tx.dex.MintAsset(asset, account, amount) })
+	return nil
+}
+
+// rollback undoes every applied change, latest first
+func (tx *dexTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
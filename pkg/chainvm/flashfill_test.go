@@ -0,0 +1,193 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+// flashSetup lists 1000 of the lender's tokens for flash borrowing at an
+// ask of 8 CPM, with the payer's campaign c1 funded with 100 AUSD
+func flashSetup(t *testing.T, holdbackBps uint16) (*AdSlotManager, *dex.Engine, uint64) {
+	t.Helper()
+	engine := dex.NewEngine()
+	state := &VMState{}
+	a := NewAdSlotManager(state, engine)
+	a.Escrow = NewEscrowManager(state, engine, "AUSD")
+	a.Escrow.SetLedger(NewLedger())
+	slot, err := a.CreateAdSlot(context.Background(), &CreateAdSlotRequest{
+		Publisher:      "lender",
+		StartTime:      time.Now().Add(-time.Minute),
+		EndTime:        time.Now().Add(time.Hour),
+		MaxImpressions: 5000,
+		FloorCPM:       decimal.NewFromInt(5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := a.state.GetAdSlot(slot.SlotID)
+	s.SecondaryMarkets = []SecondaryListing{
+		{SellerID: "lender", Quantity: 1000, AskPrice: decimal.NewFromInt(8)},
+		{SellerID: "lender", Quantity: 1000, AskPrice: decimal.NewFromInt(8), FlashLoanOK: true},
+	}
+	engine.SetBalance("AUSD", "payer", decimal.NewFromInt(100))
+	if _, err := a.Escrow.FundCampaign(context.Background(), &FundCampaignRequest{CampaignID: "c1", Advertiser: "payer", Amount: decimal.NewFromInt(100), HoldbackBps: holdbackBps}); err != nil {
+		t.Fatal(err)
+	}
+	return a, engine, slot.SlotID
+}
+
+// flashReserve reserves amount of c1's budget for publisher
+func flashReserve(t *testing.T, a *AdSlotManager, id, publisher string, amount int64) {
+	t.Helper()
+	if _, err := a.Escrow.ReserveBudget(context.Background(), &ReserveBudgetRequest{ReservationID: id, CampaignID: "c1", Publisher: publisher, Amount: decimal.NewFromInt(amount), TTLSeconds: 10}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkBalances(t *testing.T, engine *dex.Engine, balances map[[2]string]string) {
+	t.Helper()
+	for k, want := range balances {
+		if got := engine.GetBalance(k[0], k[1]); !got.Equal(decimal.RequireFromString(want)) {
+			t.Errorf("%s balance of %s = %s, want %s", k[0], k[1], got, want)
+		}
+	}
+}
+
+func TestFlashFill_Atomic(t *testing.T) {
+	a, engine, slotID := flashSetup(t, 0)
+	flashReserve(t, a, "r1", "arb", 10)
+
+	resp, err := a.FlashFill(context.Background(), &FlashFillRequest{
+		SlotID:        slotID,
+		Lender:        "lender",
+		Borrower:      "arb",
+		ReservationID: "r1",
+		Quantity:      1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The 10 reserved is paid; the lender is owed 8 plus 0.3%
+	want := map[string]string{"proceeds": "10", "repaid": "8.024", "fee": "0.024", "profit": "1.976"}
+	got := map[string]decimal.Decimal{"proceeds": resp.Proceeds, "repaid": resp.Repaid, "fee": resp.Fee, "profit": resp.Profit}
+	for k, v := range want {
+		if !got[k].Equal(decimal.RequireFromString(v)) {
+			t.Errorf("%s = %s, want %s", k, got[k], v)
+		}
+	}
+
+	checkBalances(t, engine, map[[2]string]string{
+		{"AUSD", "arb"}:        "1.976",
+		{"AUSD", "lender"}:     "8.024",
+		{"AUSD", "payer"}:      "0",
+		{"AUSD", "escrow"}:     "90",
+		{"adslot-0", "lender"}: "4000",
+		{"adslot-0", "arb"}:    "0",
+	})
+	slot, _ := a.state.GetAdSlot(slotID)
+	if slot.DeliveredImprs != 1000 || slot.SecondaryMarkets[1].Quantity != 0 || slot.SecondaryMarkets[0].Quantity != 1000 {
+		t.Errorf("delivered %d, listings %+v", slot.DeliveredImprs, slot.SecondaryMarkets)
+	}
+	campaign, _ := a.state.GetCampaign("c1")
+	if !campaign.ReservedBudget.IsZero() || !campaign.SpentBudget.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("campaign reserved %s, spent %s", campaign.ReservedBudget, campaign.SpentBudget)
+	}
+	ledger := a.Escrow.Ledger()
+	if bal := ledger.Balance(PublisherAccount("arb")); !bal.Equal(decimal.NewFromInt(10)) || !ledger.Total().IsZero() {
+		t.Errorf("ledger publisher balance %s, total %s", bal, ledger.Total())
+	}
+
+	// A reservation pays once
+	_, err = a.FlashFill(context.Background(), &FlashFillRequest{SlotID: slotID, Lender: "lender", Borrower: "arb", ReservationID: "r1", Quantity: 1})
+	if !errors.Is(err, ErrAlreadySettled) {
+		t.Errorf("reused reservation: err = %v, want %v", err, ErrAlreadySettled)
+	}
+}
+
+func TestFlashFill_Holdback(t *testing.T) {
+	a, engine, slotID := flashSetup(t, 1000)
+	flashReserve(t, a, "r1", "arb", 10)
+
+	resp, err := a.FlashFill(context.Background(), &FlashFillRequest{SlotID: slotID, Lender: "lender", Borrower: "arb", ReservationID: "r1", Quantity: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Proceeds.Equal(decimal.NewFromInt(9)) || !resp.Holdback.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("proceeds %s, holdback %s", resp.Proceeds, resp.Holdback)
+	}
+	checkBalances(t, engine, map[[2]string]string{{"AUSD", "arb"}: "0.976", {"AUSD", "escrow"}: "91"})
+	if release, ok := a.state.TakePendingRelease("r1"); !ok || release.Publisher != "arb" || !release.Amount.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("pending release = %+v, %v", release, ok)
+	}
+}
+
+func TestFlashFill_RevertsWhenUnderpaid(t *testing.T) {
+	a, engine, slotID := flashSetup(t, 0)
+	// The borrower has funds, but a flash fill mustn't dip into them
+	engine.SetBalance("AUSD", "arb", decimal.NewFromInt(50))
+	flashReserve(t, a, "r1", "arb", 8) // Doesn't cover the fee
+
+	_, err := a.FlashFill(context.Background(), &FlashFillRequest{
+		SlotID:        slotID,
+		Lender:        "lender",
+		Borrower:      "arb",
+		ReservationID: "r1",
+		Quantity:      1000,
+	})
+	if !errors.Is(err, ErrFlashUnderpaid) {
+		t.Fatalf("err = %v, want %v", err, ErrFlashUnderpaid)
+	}
+
+	checkBalances(t, engine, map[[2]string]string{
+		{"AUSD", "arb"}:        "50",
+		{"AUSD", "lender"}:     "0",
+		{"AUSD", "escrow"}:     "100",
+		{"adslot-0", "lender"}: "5000",
+		{"adslot-0", "arb"}:    "0",
+	})
+	slot, _ := a.state.GetAdSlot(slotID)
+	if slot.DeliveredImprs != 0 || slot.SecondaryMarkets[1].Quantity != 1000 {
+		t.Errorf("delivered %d, listing %+v after revert", slot.DeliveredImprs, slot.SecondaryMarkets[1])
+	}
+	reservation, _ := a.state.GetReservation("r1")
+	campaign, _ := a.state.GetCampaign("c1")
+	if reservation.Settled || !campaign.ReservedBudget.Equal(decimal.NewFromInt(8)) || !campaign.SpentBudget.IsZero() {
+		t.Errorf("after revert: settled %v, reserved %s, spent %s", reservation.Settled, campaign.ReservedBudget, campaign.SpentBudget)
+	}
+	if n := len(a.Escrow.Ledger().Entries()); n != 2 {
+		t.Errorf("%d ledger entries after revert, want funding and reservation", n)
+	}
+}
+
+func TestFlashFill_NeedsPayersReservation(t *testing.T) {
+	a, _, slotID := flashSetup(t, 0)
+	flashReserve(t, a, "r1", "other-publisher", 20)
+
+	for _, tt := range []struct {
+		name string
+		req  FlashFillRequest
+		want error
+	}{
+		{"another publisher's reservation", FlashFillRequest{Borrower: "arb", ReservationID: "r1", Quantity: 1000}, ErrNotReservationPublisher},
+		{"unknown reservation", FlashFillRequest{Borrower: "arb", ReservationID: "r2", Quantity: 1000}, ErrReservationNotFound},
+		// Only flash-borrowable listings lend
+		{"oversized borrow", FlashFillRequest{Borrower: "other-publisher", ReservationID: "r1", Quantity: 1001}, ErrNoFlashListing},
+	} {
+		tt.req.SlotID, tt.req.Lender = slotID, "lender"
+		if _, err := a.FlashFill(context.Background(), &tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	a.Escrow = nil
+	_, err := a.FlashFill(context.Background(), &FlashFillRequest{SlotID: slotID, Lender: "lender", Borrower: "other-publisher", ReservationID: "r1", Quantity: 1000})
+	if !errors.Is(err, ErrFlashNoEscrow) {
+		t.Errorf("no escrow: err = %v, want %v", err, ErrFlashNoEscrow)
+	}
+}
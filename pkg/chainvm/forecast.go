@@ -0,0 +1,296 @@
+package chainvm
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/luxfi/adx/pkg/vast"
+)
+
+// DefaultForecastHistory is how much request volume a VolumeForecaster
+// keeps and forecasts from
+const DefaultForecastHistory = 28 * 24 * time.Hour
+
+// ForecastRequest asks how much inventory a campaign could buy
+type ForecastRequest struct {
+	Targeting TargetingPredicate `json:"targeting"`
+	Start     time.Time          `json:"start"` // Flight window
+	End       time.Time          `json:"end"`
+	BidCPM    float64            `json:"bid_cpm"`
+	FloorCPM  float64            `json:"floor_cpm,omitempty"` // Lowest price the campaign would clear at
+}
+
+// ForecastRange is a low, mid and high estimate
+type ForecastRange struct {
+	Low  float64 `json:"low"`
+	Mid  float64 `json:"mid"`
+	High float64 `json:"high"`
+}
+
+// Forecast is the inventory a campaign's targeting matches over its flight,
+// and how much of it the bid would win
+type Forecast struct {
+	Impressions ForecastRange `json:"impressions"` // Matching requests
+	Wins        ForecastRange `json:"wins"`        // Matching requests won at the bid
+	WinRate     float64       `json:"win_rate"`
+	Overlap     float64       `json:"overlap"`      // Share of all requests the targeting matches
+	HistoryDays int           `json:"history_days"` // Days of volume the forecast is drawn from
+}
+
+// Forecaster estimates a campaign's inventory before it launches
+type Forecaster interface {
+	Forecast(req ForecastRequest) (*Forecast, error)
+}
+
+// VolumeForecaster forecasts from the request volume it has recorded,
+// bucketed by the dimensions campaigns target: geo, device type, content
+// category, birth year and audience segments. A forecast runs the
+// campaign's compiled targeting over each bucket, so it matches exactly
+// the requests the campaign would have.
+type VolumeForecaster struct {
+	History time.Duration // DefaultForecastHistory when zero
+
+	targeting *TargetingEvaluator
+	now       func() time.Time
+
+	mu      sync.RWMutex
+	buckets map[string]*volumeBucket
+}
+
+// volumeBucket is the volume of requests alike in every targeted dimension
+type volumeBucket struct {
+	req  *vast.OpenRTBRequest // Carries only the targeted dimensions
+	days map[int64]*dayVolume
+}
+
+type dayVolume struct {
+	requests uint64
+	clearing map[int64]uint64 // Auctions by clearing CPM in cents, 0 unfilled
+}
+
+// NewVolumeForecaster creates a forecaster with no history
+func NewVolumeForecaster() *VolumeForecaster {
+	return &VolumeForecaster{
+		targeting: NewTargetingEvaluator(),
+		now:       time.Now,
+		buckets:   make(map[string]*volumeBucket),
+	}
+}
+
+func (f *VolumeForecaster) history() time.Duration {
+	if f.History > 0 {
+		return f.History
+	}
+	return DefaultForecastHistory
+}
+
+func unixDay(t time.Time) int64 {
+	return t.Unix() / 86400
+}
+
+// Record counts a request made at, which cleared at clearingCPM or went
+// unfilled at zero. Days older than History are dropped.
+func (f *VolumeForecaster) Record(req *vast.OpenRTBRequest, clearingCPM float64, at time.Time) {
+	key, profile := volumeProfile(req)
+	day := unixDay(at)
+	oldest := unixDay(at.Add(-f.history()))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.buckets[key]
+	if !ok {
+		b = &volumeBucket{req: profile, days: make(map[int64]*dayVolume)}
+		f.buckets[key] = b
+	}
+	v, ok := b.days[day]
+	if !ok {
+		v = &dayVolume{clearing: make(map[int64]uint64)}
+		b.days[day] = v
+		for d := range b.days {
+			if d < oldest {
+				delete(b.days, d)
+			}
+		}
+	}
+	v.requests++
+	v.clearing[int64(math.Round(clearingCPM*100))]++
+}
+
+// ObserveAuction records a VAST auction at its top bid
+func (f *VolumeForecaster) ObserveAuction(req *vast.OpenRTBRequest, resp *vast.OpenRTBResponse) {
+	var clearing float64
+	if resp != nil {
+		for _, sb := range resp.SeatBid {
+			for _, bid := range sb.Bid {
+				clearing = max(clearing, bid.Price)
+			}
+		}
+	}
+	f.Record(req, clearing, f.now())
+}
+
+// Forecast scales the daily volume matching req's targeting to its flight.
+// Mid is the mean day and low and high one standard deviation either side.
+// A request counts as won when the bid reaches both the floor and the
+// price it cleared at.
+func (f *VolumeForecaster) Forecast(req ForecastRequest) (*Forecast, error) {
+	if !req.End.After(req.Start) {
+		return nil, fmt.Errorf("%w: flight ends before it starts", ErrInvalidWindow)
+	}
+	if req.BidCPM < 0 || req.FloorCPM < 0 {
+		return nil, fmt.Errorf("bid_cpm: %w", ErrNegativeAmount)
+	}
+	match, err := f.targeting.Compile(req.Targeting)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTargeting, err)
+	}
+
+	now := f.now()
+	oldest := unixDay(now.Add(-f.history()))
+	bidCents := int64(math.Round(req.BidCPM * 100))
+	floorCents := int64(math.Round(req.FloorCPM * 100))
+
+	daily := make(map[int64]float64) // Matching requests by day
+	var total, matched, won uint64
+	f.mu.RLock()
+	for _, b := range f.buckets {
+		ok, _ := match(b.req)
+		for day, v := range b.days {
+			if day < oldest {
+				continue
+			}
+			total += v.requests
+			var n uint64
+			if ok {
+				n = v.requests
+				for cents, auctions := range v.clearing {
+					if bidCents >= floorCents && bidCents >= cents {
+						won += auctions
+					}
+				}
+			}
+			matched += n
+			daily[day] += float64(n)
+		}
+	}
+	f.mu.RUnlock()
+
+	out := &Forecast{HistoryDays: len(daily)}
+	if total == 0 {
+		return out, nil
+	}
+	var mean, variance float64
+	for _, n := range daily {
+		mean += n
+	}
+	mean /= float64(len(daily))
+	for _, n := range daily {
+		variance += (n - mean) * (n - mean)
+	}
+	sd := math.Sqrt(variance / float64(len(daily)))
+
+	days := req.End.Sub(req.Start).Hours() / 24
+	out.Impressions = ForecastRange{Low: max(mean-sd, 0) * days, Mid: mean * days, High: (mean + sd) * days}
+	out.Overlap = float64(matched) / float64(total)
+	if matched > 0 {
+		out.WinRate = float64(won) / float64(matched)
+	}
+	out.Wins = ForecastRange{
+		Low:  out.Impressions.Low * out.WinRate,
+		Mid:  out.Impressions.Mid * out.WinRate,
+		High: out.Impressions.High * out.WinRate,
+	}
+	return out, nil
+}
+
+// volumeProfile reduces req to the dimensions targeting reads, returning
+// them as a bucket key and as a request targeting can be run over
+func volumeProfile(req *vast.OpenRTBRequest) (string, *vast.OpenRTBRequest) {
+	geo := requestGeo(req)
+	var cats []string
+	if req.Site != nil {
+		cats = append(append(append(cats, req.Site.Cat...), req.Site.SectionCat...), req.Site.PageCat...)
+	}
+	if req.App != nil {
+		cats = append(append(append(cats, req.App.Cat...), req.App.SectionCat...), req.App.PageCat...)
+	}
+	slices.Sort(cats)
+	cats = slices.Compact(cats)
+
+	var segments []string
+	for _, data := range req.User.Data {
+		for _, seg := range data.Segment {
+			segments = append(segments, data.ID+"/"+seg.ID)
+		}
+	}
+	slices.Sort(segments)
+	segments = slices.Compact(segments)
+
+	profile := &vast.OpenRTBRequest{
+		Device: vast.Device{DeviceType: req.Device.DeviceType, Geo: vast.Geo{Country: geo.Country, Region: geo.Region}},
+		User:   vast.User{YOB: req.User.YOB},
+	}
+	if len(cats) > 0 {
+		profile.Site = &vast.Site{Cat: cats}
+	}
+	for _, s := range segments {
+		dataID, segID, _ := strings.Cut(s, "/")
+		profile.User.Data = append(profile.User.Data, vast.Data{ID: dataID, Segment: []vast.Segment{{ID: segID}}})
+	}
+
+	key := strings.Join([]string{
+		strings.ToUpper(geo.Country), strings.ToUpper(geo.Region),
+		fmt.Sprint(req.Device.DeviceType), fmt.Sprint(req.User.YOB),
+		strings.Join(cats, ","), strings.Join(segments, ","),
+	}, "|")
+	return key, profile
+}
+
+// ForecastHandler serves forecasts over HTTP to holders of Token, which
+// must be presented as a bearer token: a ForecastRequest is POSTed and
+// the Forecast returned as JSON.
+type ForecastHandler struct {
+	Forecaster Forecaster
+	Token      string // Every request is refused when empty
+}
+
+// ServeHTTP authenticates and answers a forecast request
+func (h *ForecastHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || h.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="adx-forecast"`)
+		writeForecastJSON(w, http.StatusUnauthorized, rpcerr.Body{Error: "unauthorized", Code: "unauthenticated"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeForecastJSON(w, http.StatusMethodNotAllowed, rpcerr.Body{Error: "method not allowed", Code: "method_not_allowed"})
+		return
+	}
+
+	var req ForecastRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeForecastJSON(w, http.StatusBadRequest, rpcerr.Body{Error: err.Error(), Code: "invalid_json"})
+		return
+	}
+	forecast, err := h.Forecaster.Forecast(req)
+	if err != nil {
+		writeForecastJSON(w, rpcerr.HTTPStatus(err), rpcerr.BodyOf(err))
+		return
+	}
+	writeForecastJSON(w, http.StatusOK, forecast)
+}
+
+func writeForecastJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,157 @@
+package chainvm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/vast"
+)
+
+// seededForecaster has a week of history: each day 100 US CTV requests
+// clearing at $2, 50 US phone requests at $4 and 50 UK phone requests
+// going unfilled
+func seededForecaster(t *testing.T) (*VolumeForecaster, time.Time) {
+	t.Helper()
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	f := NewVolumeForecaster()
+	f.now = func() time.Time { return now }
+	record := func(country string, deviceType, n int, clearing float64, at time.Time) {
+		for range n {
+			req := geoRequest(country, "")
+			req.Device.DeviceType = deviceType
+			f.Record(req, clearing, at)
+		}
+	}
+	for d := 1; d <= 7; d++ {
+		at := now.AddDate(0, 0, -d)
+		record("USA", vast.DeviceTypeCTV, 100, 2, at)
+		record("USA", vast.DeviceTypePhone, 50, 4, at)
+		record("GBR", vast.DeviceTypePhone, 50, 0, at)
+	}
+	// Older than the history kept
+	record("USA", vast.DeviceTypeCTV, 1000, 2, now.Add(-DefaultForecastHistory-48*time.Hour))
+	return f, now
+}
+
+func TestVolumeForecaster_TargetingBreadth(t *testing.T) {
+	f, now := seededForecaster(t)
+	flight := func(targeting TargetingPredicate) *Forecast {
+		t.Helper()
+		fc, err := f.Forecast(ForecastRequest{Targeting: targeting, Start: now, End: now.AddDate(0, 0, 10), BidCPM: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fc
+	}
+
+	ctv := flight(TargetingPredicate{GeoTargets: []string{"USA"}, DeviceTypes: []string{"ctv"}})
+	us := flight(TargetingPredicate{GeoTargets: []string{"USA"}})
+	all := flight(TargetingPredicate{})
+	if ctv.Impressions.Mid != 1000 || us.Impressions.Mid != 1500 || all.Impressions.Mid != 2000 {
+		t.Errorf("mid impressions = %v, %v, %v; want 1000, 1500, 2000 over 10 days",
+			ctv.Impressions.Mid, us.Impressions.Mid, all.Impressions.Mid)
+	}
+	if ctv.Overlap != 0.5 || us.Overlap != 0.75 || all.Overlap != 1 {
+		t.Errorf("overlap = %v, %v, %v", ctv.Overlap, us.Overlap, all.Overlap)
+	}
+	if all.HistoryDays != 7 {
+		t.Errorf("history days = %d, want 7", all.HistoryDays)
+	}
+	// Steady volume every day leaves no spread
+	if r := us.Impressions; r.Low != r.Mid || r.High != r.Mid {
+		t.Errorf("range = %+v", r)
+	}
+	if none := flight(TargetingPredicate{GeoTargets: []string{"FRA"}}); none.Impressions.High != 0 || none.Wins.High != 0 {
+		t.Errorf("untargeted geo forecast %+v", none)
+	}
+}
+
+func TestVolumeForecaster_Bid(t *testing.T) {
+	f, now := seededForecaster(t)
+	us := TargetingPredicate{GeoTargets: []string{"USA"}}
+	at := func(bid, floor float64) *Forecast {
+		t.Helper()
+		fc, err := f.Forecast(ForecastRequest{Targeting: us, Start: now, End: now.AddDate(0, 0, 1), BidCPM: bid, FloorCPM: floor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fc
+	}
+
+	low, mid, high := at(1, 0), at(3, 0), at(5, 0)
+	if low.WinRate != 0 || mid.WinRate != 2.0/3 || high.WinRate != 1 {
+		t.Errorf("win rate = %v, %v, %v; want 0, 2/3, 1", low.WinRate, mid.WinRate, high.WinRate)
+	}
+	if mid.Wins.Mid != 100 || high.Wins.Mid != 150 {
+		t.Errorf("wins = %v, %v; want 100, 150", mid.Wins.Mid, high.Wins.Mid)
+	}
+	if mid.Impressions != high.Impressions {
+		t.Error("bid changed the inventory available")
+	}
+	if below := at(3, 4); below.WinRate != 0 {
+		t.Errorf("bid under the floor wins %v", below.WinRate)
+	}
+
+	if _, err := f.Forecast(ForecastRequest{Targeting: us, Start: now, End: now, BidCPM: 1}); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("empty flight: %v", err)
+	}
+	if _, err := f.Forecast(ForecastRequest{Targeting: TargetingPredicate{DeviceTypes: []string{"fridge"}}, Start: now, End: now.Add(time.Hour)}); !errors.Is(err, ErrInvalidTargeting) {
+		t.Errorf("bad targeting: %v", err)
+	}
+}
+
+func TestVolumeForecaster_VariableVolume(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	f := NewVolumeForecaster()
+	f.now = func() time.Time { return now }
+	for d, n := range []int{10, 30} {
+		for range n {
+			f.Record(geoRequest("USA", ""), 1, now.AddDate(0, 0, -d-1))
+		}
+	}
+	fc, err := f.Forecast(ForecastRequest{Start: now, End: now.AddDate(0, 0, 2), BidCPM: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := fc.Impressions; r.Low != 20 || r.Mid != 40 || r.High != 60 {
+		t.Errorf("impressions = %+v, want 20/40/60", r)
+	}
+}
+
+func TestForecastHandler(t *testing.T) {
+	f, now := seededForecaster(t)
+	h := &ForecastHandler{Forecaster: f, Token: "secret"}
+	post := func(token string, req ForecastRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/forecast", bytes.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	req := ForecastRequest{Targeting: TargetingPredicate{GeoTargets: []string{"USA"}}, Start: now, End: now.AddDate(0, 0, 1), BidCPM: 5}
+
+	for _, token := range []string{"", "wrong"} {
+		if w := post(token, req); w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d", token, w.Code)
+		}
+	}
+
+	w := post("secret", req)
+	var fc Forecast
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &fc) != nil || fc.Impressions.Mid != 150 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	req.End = req.Start
+	if w := post("secret", req); w.Code != http.StatusBadRequest {
+		t.Errorf("empty flight: status %d", w.Code)
+	}
+}
@@ -0,0 +1,57 @@
+package chainvm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultFundingSlippageBps is the slippage tolerated when swapping a
+// non-AUSD funding asset, unless the request sets its own
+const DefaultFundingSlippageBps = 100
+
+// Funding records one deposit into a campaign, and the swap that
+// converted it to AUSD if it was funded in another asset
+type Funding struct {
+	Asset    string          `json:"asset"`
+	AmountIn decimal.Decimal `json:"amount_in"`
+	AUSD     decimal.Decimal `json:"ausd"`
+	Rate     decimal.Decimal `json:"rate"` // AUSD per unit of Asset
+	Time     time.Time       `json:"time"`
+}
+
+// fundingAUSD converts a funding request to AUSD in the advertiser's
+// account, swapping through the dex when it's in another asset. The swap
+// must pay at least the pool's spot price less the slippage tolerance.
+func (e *EscrowManager) fundingAUSD(req *FundCampaignRequest) (Funding, error) {
+	funding := Funding{Asset: e.ausdID, AmountIn: req.Amount, AUSD: req.Amount, Rate: decimal.NewFromInt(1), Time: time.Now()}
+	if req.Asset == "" || req.Asset == e.ausdID {
+		return funding, nil
+	}
+
+	_, spot, err := e.dex.Quote(req.Asset, e.ausdID, req.Amount)
+	if err != nil {
+		return funding, fmt.Errorf("%w: %w", ErrNoLiquidityPath, err)
+	}
+	slippage := req.MaxSlippageBps
+	if slippage == 0 {
+		slippage = DefaultFundingSlippageBps
+	}
+	tolerance := decimal.NewFromInt(int64(10000 - int(slippage))).Div(decimal.NewFromInt(10000))
+	minOut := req.Amount.Mul(spot).Mul(tolerance)
+
+	out, err := e.dex.Swap(req.Advertiser, req.Asset, e.ausdID, req.Amount, minOut)
+	if err != nil {
+		if errors.Is(err, dex.ErrSlippage) {
+			return funding, fmt.Errorf("%w: %w", ErrSlippageExceeded, err)
+		}
+		return funding, fmt.Errorf("%w: swap %s to %s: %v", ErrInsufficientFunds, req.Asset, e.ausdID, err)
+	}
+	funding.Asset = req.Asset
+	funding.AUSD = out
+	funding.Rate = out.Div(req.Amount)
+	return funding, nil
+}
@@ -0,0 +1,58 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+func TestFundCampaign_SwapsToAUSD(t *testing.T) {
+	ctx := context.Background()
+	engine := dex.NewEngine()
+	// 10,000 LUX against 20,000 AUSD: a spot price of 2 AUSD
+	engine.SetBalance("LUX", "mm", decimal.NewFromInt(10000))
+	engine.SetBalance("AUSD", "mm", decimal.NewFromInt(20000))
+	if err := engine.CreatePool("mm", "LUX", "AUSD", decimal.NewFromInt(10000), decimal.NewFromInt(20000)); err != nil {
+		t.Fatal(err)
+	}
+	engine.SetBalance("LUX", "adv-1", decimal.NewFromInt(1000))
+	e := NewEscrowManager(&VMState{}, engine, "AUSD")
+
+	resp, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(50), Asset: "LUX"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 50 LUX at 2 AUSD less price impact: 20000*50/10050 = 99.50
+	want := decimal.NewFromInt(100)
+	if resp.Funded.Sub(want).Abs().GreaterThan(want.Mul(decimal.RequireFromString("0.01"))) || !resp.Funded.LessThan(want) {
+		t.Errorf("funded %s AUSD, want within 1%% of %s", resp.Funded, want)
+	}
+	campaign, _ := e.state.GetCampaign("c1")
+	if !campaign.AvailableBudget.Equal(resp.Funded) || !engine.GetBalance("AUSD", "escrow").Equal(resp.Funded) {
+		t.Errorf("budget %s, escrow holds %s, swapped %s", campaign.AvailableBudget, engine.GetBalance("AUSD", "escrow"), resp.Funded)
+	}
+	if !engine.GetBalance("LUX", "adv-1").Equal(decimal.NewFromInt(950)) || !engine.GetBalance("AUSD", "adv-1").IsZero() {
+		t.Errorf("advertiser left with %s LUX, %s AUSD", engine.GetBalance("LUX", "adv-1"), engine.GetBalance("AUSD", "adv-1"))
+	}
+	if len(campaign.Fundings) != 1 || campaign.Fundings[0].Asset != "LUX" || !campaign.Fundings[0].Rate.Equal(resp.SwapRate) {
+		t.Errorf("fundings = %+v", campaign.Fundings)
+	}
+
+	// 900 LUX moves the price ~8%, past a 5% tolerance
+	_, err = e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(900), Asset: "LUX", MaxSlippageBps: 500})
+	if !errors.Is(err, dex.ErrSlippage) {
+		t.Errorf("large swap: err = %v, want %v", err, dex.ErrSlippage)
+	}
+
+	engine.SetBalance("DOGE", "adv-1", decimal.NewFromInt(1000))
+	_, err = e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(10), Asset: "DOGE"})
+	if !errors.Is(err, dex.ErrNoLiquidity) {
+		t.Errorf("unpooled asset: err = %v, want %v", err, dex.ErrNoLiquidity)
+	}
+	if campaign, _ := e.state.GetCampaign("c1"); !campaign.TotalBudget.Equal(resp.Funded) || len(campaign.Fundings) != 1 {
+		t.Errorf("rejected fundings changed the campaign: %s, %d fundings", campaign.TotalBudget, len(campaign.Fundings))
+	}
+}
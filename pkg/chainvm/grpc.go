@@ -0,0 +1,339 @@
+package chainvm
+
+//go:generate protoc -I ../../api/proto --go_out=../.. --go_opt=module=github.com/luxfi/adx --go-grpc_out=../.. --go-grpc_opt=module=github.com/luxfi/adx chainvm/v1/chainvm.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/luxfi/adx/pkg/chainvm/chainvmpb"
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCServer serves the AdSlotManager and EscrowManager RPC methods over
+// gRPC. Decimal fields travel as strings.
+type GRPCServer struct {
+	chainvmpb.UnimplementedChainVMServer
+
+	Slots  *AdSlotManager
+	Escrow *EscrowManager
+}
+
+// NewGRPCServer creates a gRPC server with the ChainVM service registered.
+// Interceptors run in the order given.
+func NewGRPCServer(slots *AdSlotManager, escrow *EscrowManager, interceptors ...grpc.UnaryServerInterceptor) *grpc.Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	chainvmpb.RegisterChainVMServer(s, &GRPCServer{Slots: slots, Escrow: escrow})
+	return s
+}
+
+// AuthInterceptor rejects calls whose "authorization" metadata is not
+// "Bearer <token>" for one of the given tokens
+func AuthInterceptor(tokens ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, v := range md.Get("authorization") {
+			presented, ok := strings.CutPrefix(v, "Bearer ")
+			if !ok {
+				continue
+			}
+			for _, token := range tokens {
+				if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+					return handler(ctx, req)
+				}
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+	}
+}
+
+// LoggingInterceptor logs each call's method, status code and latency
+func LoggingInterceptor(logf func(format string, args ...any)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logf("grpc %s %s %s", info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+// parseDecimal reads a decimal string field; empty means zero
+func parseDecimal(field, s string) (decimal.Decimal, error) {
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero, status.Errorf(codes.InvalidArgument, "%s: invalid decimal %q", field, s)
+	}
+	return d, nil
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// idempotencyKey returns the call's "idempotency-key" metadata, which
+// escrow RPCs use to deduplicate retries
+func idempotencyKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get("idempotency-key"); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// rpcError maps a manager error to a gRPC status. Typed errors carry their
+// own status code and an ErrorInfo detail with their code; anything else
+// depends on VM state and is a failed precondition.
+func rpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if _, ok := rpcerr.As(err); ok {
+		return rpcerr.Status(err).Err()
+	}
+	return status.Error(codes.FailedPrecondition, err.Error())
+}
+
+func (s *GRPCServer) CreateAdSlot(ctx context.Context, in *chainvmpb.CreateAdSlotRequest) (*chainvmpb.CreateAdSlotResponse, error) {
+	floor, err := parseDecimal("floor_cpm", in.GetFloorCpm())
+	if err != nil {
+		return nil, err
+	}
+
+	req := &CreateAdSlotRequest{
+		Publisher:      in.GetPublisher(),
+		Placement:      in.GetPlacement(),
+		StartTime:      fromTimestamp(in.GetStartTime()),
+		EndTime:        fromTimestamp(in.GetEndTime()),
+		MaxImpressions: in.GetMaxImpressions(),
+		MinViewability: in.GetMinViewability(),
+		FloorCPM:       floor,
+	}
+	if t := in.GetTargeting(); t != nil {
+		req.Targeting = TargetingPredicate{
+			GeoTargets:  t.GetGeoTargets(),
+			DeviceTypes: t.GetDeviceTypes(),
+			Categories:  t.GetCategories(),
+			MinAge:      t.GetMinAge(),
+			MaxAge:      t.GetMaxAge(),
+		}
+	}
+
+	resp, err := s.Slots.CreateAdSlot(ctx, req)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &chainvmpb.CreateAdSlotResponse{
+		Success: resp.Success,
+		SlotId:  resp.SlotID,
+		TokenId: resp.TokenID,
+	}, nil
+}
+
+func (s *GRPCServer) PlaceOrder(ctx context.Context, in *chainvmpb.PlaceOrderRequest) (*chainvmpb.PlaceOrderResponse, error) {
+	price, err := parseDecimal("limit_price", in.GetLimitPrice())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Slots.PlaceOrder(ctx, &PlaceOrderRequest{
+		OrderID:    in.GetOrderId(),
+		TraderID:   in.GetTraderId(),
+		SlotID:     in.GetSlotId(),
+		IsBuy:      in.GetIsBuy(),
+		OrderType:  in.GetOrderType(),
+		LimitPrice: price,
+		Quantity:   in.GetQuantity(),
+		ExpiresAt:  fromTimestamp(in.GetExpiresAt()),
+		CommitHash: in.GetCommitHash(),
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &chainvmpb.PlaceOrderResponse{
+		Success:       resp.Success,
+		OrderId:       resp.OrderID,
+		CurrentPrice:  resp.CurrentPrice.String(),
+		EstimatedFill: resp.EstimatedFill.String(),
+	}, nil
+}
+
+func (s *GRPCServer) RevealBid(ctx context.Context, in *chainvmpb.RevealBidRequest) (*chainvmpb.RevealBidResponse, error) {
+	price, err := parseDecimal("revealed_price", in.GetRevealedPrice())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Slots.RevealBid(ctx, &RevealBidRequest{
+		AuctionID:     in.GetAuctionId(),
+		BidID:         in.GetBidId(),
+		OrderID:       in.GetOrderId(),
+		Reveal:        in.GetReveal(),
+		RevealedPrice: price,
+		Nonce:         in.GetNonce(),
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &chainvmpb.RevealBidResponse{
+		Success:       resp.Success,
+		Message:       resp.Message,
+		RevealedPrice: resp.RevealedPrice.String(),
+	}, nil
+}
+
+func (s *GRPCServer) RecordDelivery(ctx context.Context, in *chainvmpb.RecordDeliveryRequest) (*chainvmpb.RecordDeliveryResponse, error) {
+	resp, err := s.Slots.RecordDelivery(ctx, &RecordDeliveryRequest{
+		AdSlotID:    in.GetSlotId(),
+		SlotID:      in.GetSlotId(),
+		Impressions: in.GetImpressions(),
+		Count:       in.GetImpressions(),
+		Timestamp:   fromTimestamp(in.GetTimestamp()),
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &chainvmpb.RecordDeliveryResponse{
+		Success:         resp.Success,
+		Message:         resp.Message,
+		DeliveredCount:  resp.DeliveredCount,
+		TotalDelivered:  resp.TotalDelivered,
+		RemainingSupply: resp.RemainingSupply,
+	}, nil
+}
+
+func (s *GRPCServer) FundCampaign(ctx context.Context, in *chainvmpb.FundCampaignRequest) (*chainvmpb.FundCampaignResponse, error) {
+	amount, err := parseDecimal("amount", in.GetAmount())
+	if err != nil {
+		return nil, err
+	}
+	if in.GetHoldbackBps() > 0xFFFF {
+		return nil, status.Error(codes.InvalidArgument, "holdback_bps out of range")
+	}
+
+	resp, err := s.Escrow.FundCampaign(ctx, &FundCampaignRequest{
+		CampaignID:  in.GetCampaignId(),
+		Advertiser:  in.GetAdvertiser(),
+		Amount:      amount,
+		HoldbackBps: uint16(in.GetHoldbackBps()),
+		FlightStart: fromTimestamp(in.GetFlightStart()),
+		FlightEnd:   fromTimestamp(in.GetFlightEnd()),
+		Pacing:      PacingMode(in.GetPacing()),
+
+		IdempotencyKey: idempotencyKey(ctx),
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &chainvmpb.FundCampaignResponse{
+		Success:         resp.Success,
+		NewTotalBudget:  resp.NewTotalBudget.String(),
+		AvailableBudget: resp.AvailableBudget.String(),
+	}, nil
+}
+
+func (s *GRPCServer) ReserveBudget(ctx context.Context, in *chainvmpb.ReserveBudgetRequest) (*chainvmpb.ReserveBudgetResponse, error) {
+	amount, err := parseDecimal("amount", in.GetAmount())
+	if err != nil {
+		return nil, err
+	}
+
+	req := &ReserveBudgetRequest{
+		ReservationID: in.GetReservationId(),
+		CampaignID:    in.GetCampaignId(),
+		Publisher:     in.GetPublisher(),
+		Amount:        amount,
+		TTLSeconds:    in.GetTtlSeconds(),
+
+		IdempotencyKey: idempotencyKey(ctx),
+	}
+	if m := in.GetMetadata(); m != nil {
+		req.Metadata = ReservationMeta{
+			Placement:   m.GetPlacement(),
+			Geo:         m.GetGeo(),
+			DeviceType:  m.GetDeviceType(),
+			Categories:  m.GetCategories(),
+			Viewability: m.GetMinViewability(),
+			UserHash:    m.GetUserHash(),
+		}
+	}
+
+	resp, err := s.Escrow.ReserveBudget(ctx, req)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &chainvmpb.ReserveBudgetResponse{
+		Success:         resp.Success,
+		Expires:         timestamppb.New(resp.Expires),
+		RemainingBudget: resp.RemainingBudget.String(),
+	}, nil
+}
+
+func (s *GRPCServer) SettleReceipt(ctx context.Context, in *chainvmpb.SettleReceiptRequest) (*chainvmpb.SettleReceiptResponse, error) {
+	req := &SettleReceiptRequest{
+		ReservationID:     in.GetReservationId(),
+		VerificationProof: in.GetVerificationProof(),
+		IdempotencyKey:    idempotencyKey(ctx),
+	}
+	if in.GetAmount() != "" {
+		amount, err := parseDecimal("amount", in.GetAmount())
+		if err != nil {
+			return nil, err
+		}
+		req.Amount = decimal.NewNullDecimal(amount)
+	}
+
+	resp, err := s.Escrow.SettleReceipt(ctx, req)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &chainvmpb.SettleReceiptResponse{
+		Success:          resp.Success,
+		PaidAmount:       resp.PaidAmount.String(),
+		HoldbackAmount:   resp.HoldbackAmount.String(),
+		PublisherBalance: resp.PublisherBalance.String(),
+	}, nil
+}
+
+func (s *GRPCServer) CreatePGDeal(ctx context.Context, in *chainvmpb.CreatePGDealRequest) (*chainvmpb.CreatePGDealResponse, error) {
+	cpm, err := parseDecimal("fixed_cpm", in.GetFixedCpm())
+	if err != nil {
+		return nil, err
+	}
+	penalty, err := parseDecimal("penalty_rate", in.GetPenaltyRate())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Escrow.CreatePGDeal(ctx, &CreatePGDealRequest{
+		CampaignID:       in.GetCampaignId(),
+		DealID:           in.GetDealId(),
+		Publisher:        in.GetPublisher(),
+		StartTime:        fromTimestamp(in.GetStartTime()),
+		EndTime:          fromTimestamp(in.GetEndTime()),
+		TotalImpressions: in.GetTotalImpressions(),
+		FixedCPM:         cpm,
+		PenaltyRate:      penalty,
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &chainvmpb.CreatePGDealResponse{
+		Success:      resp.Success,
+		EscrowAmount: resp.EscrowAmount.String(),
+		DealId:       resp.DealID,
+	}, nil
+}
@@ -0,0 +1,177 @@
+package chainvm
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/chainvm/chainvmpb"
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testToken = "secret"
+
+func newTestClient(t *testing.T, engine *dex.Engine) (chainvmpb.ChainVMClient, *[]string) {
+	t.Helper()
+
+	state := &VMState{}
+	var logged []string
+	srv := NewGRPCServer(
+		NewAdSlotManager(state, engine),
+		NewEscrowManager(state, engine, "AUSD"),
+		LoggingInterceptor(func(format string, args ...any) {
+			logged = append(logged, format)
+		}),
+		AuthInterceptor(testToken),
+	)
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return chainvmpb.NewChainVMClient(conn), &logged
+}
+
+func authed() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+testToken)
+}
+
+func TestGRPC_Auth(t *testing.T) {
+	client, logged := newTestClient(t, dex.NewEngine())
+
+	_, err := client.FundCampaign(context.Background(), &chainvmpb.FundCampaignRequest{CampaignId: "c1"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("err = %v, want Unauthenticated", err)
+	}
+	if len(*logged) != 1 {
+		t.Errorf("logged %d calls, want 1", len(*logged))
+	}
+}
+
+func TestGRPC_SlotAndOrder(t *testing.T) {
+	client, _ := newTestClient(t, dex.NewEngine())
+	ctx := authed()
+
+	now := time.Now()
+	slot, err := client.CreateAdSlot(ctx, &chainvmpb.CreateAdSlotRequest{
+		Publisher:      "pub-1",
+		Placement:      "ctv-preroll",
+		Targeting:      &chainvmpb.TargetingPredicate{GeoTargets: []string{"US"}},
+		StartTime:      timestamppb.New(now.Add(-time.Minute)),
+		EndTime:        timestamppb.New(now.Add(time.Hour)),
+		MaxImpressions: 1000,
+		FloorCpm:       "12.345678901234567890",
+	})
+	if err != nil {
+		t.Fatalf("CreateAdSlot: %v", err)
+	}
+	if !slot.Success || !strings.HasPrefix(slot.TokenId, "adslot-") {
+		t.Fatalf("slot = %+v", slot)
+	}
+
+	order, err := client.PlaceOrder(ctx, &chainvmpb.PlaceOrderRequest{
+		OrderId:    "o1",
+		TraderId:   "buyer-1",
+		SlotId:     slot.SlotId,
+		IsBuy:      true,
+		OrderType:  "limit",
+		LimitPrice: "20",
+		Quantity:   10,
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	price, err := decimal.NewFromString(order.CurrentPrice)
+	if err != nil || !price.IsPositive() || price.GreaterThan(decimal.RequireFromString("12.345678901234567890")) {
+		t.Errorf("current price = %q", order.CurrentPrice)
+	}
+
+	_, err = client.PlaceOrder(ctx, &chainvmpb.PlaceOrderRequest{SlotId: slot.SlotId, Quantity: 1, LimitPrice: "1.2.3"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("malformed decimal err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGRPC_FundReserveSettle(t *testing.T) {
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(1000))
+	client, _ := newTestClient(t, engine)
+	ctx := authed()
+
+	fund, err := client.FundCampaign(ctx, &chainvmpb.FundCampaignRequest{
+		CampaignId:  "c1",
+		Advertiser:  "adv-1",
+		Amount:      "100.10",
+		HoldbackBps: 1000,
+	})
+	if err != nil {
+		t.Fatalf("FundCampaign: %v", err)
+	}
+	if fund.AvailableBudget != "100.1" {
+		t.Errorf("available = %s, want 100.1", fund.AvailableBudget)
+	}
+
+	_, err = client.FundCampaign(ctx, &chainvmpb.FundCampaignRequest{CampaignId: "c1", Advertiser: "adv-1", Amount: "1e40"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("oversized amount err = %v, want InvalidArgument", err)
+	}
+
+	reserve, err := client.ReserveBudget(ctx, &chainvmpb.ReserveBudgetRequest{
+		ReservationId: "r1",
+		CampaignId:    "c1",
+		Publisher:     "pub-1",
+		Amount:        "0.01",
+		TtlSeconds:    5,
+	})
+	if err != nil {
+		t.Fatalf("ReserveBudget: %v", err)
+	}
+	if reserve.RemainingBudget != "100.09" || reserve.Expires.AsTime().Before(time.Now()) {
+		t.Errorf("reserve = %+v", reserve)
+	}
+
+	// A retry carrying the same idempotency key replays the settlement
+	keyed := metadata.AppendToOutgoingContext(ctx, "idempotency-key", "settle-r1")
+	for i := 0; i < 2; i++ {
+		settle, err := client.SettleReceipt(keyed, &chainvmpb.SettleReceiptRequest{
+			ReservationId:     "r1",
+			VerificationProof: strings.Repeat("p", 32),
+		})
+		if err != nil {
+			t.Fatalf("SettleReceipt #%d: %v", i+1, err)
+		}
+		if settle.PaidAmount != "0.009" || settle.HoldbackAmount != "0.001" || settle.PublisherBalance != "0.009" {
+			t.Errorf("settle #%d = %+v", i+1, settle)
+		}
+	}
+
+	_, err = client.SettleReceipt(ctx, &chainvmpb.SettleReceiptRequest{ReservationId: "r1", VerificationProof: strings.Repeat("p", 32)})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("double settle err = %v, want FailedPrecondition", err)
+	}
+	if typed, ok := rpcerr.FromStatus(status.Convert(err)); !ok || typed.Code != "already_settled" || typed.Retryable {
+		t.Errorf("double settle details = %+v, %v", typed, ok)
+	}
+}
@@ -0,0 +1,125 @@
+package chainvm
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+)
+
+const (
+	// DefaultIdempotencyWindow is how long a key's response is replayed
+	DefaultIdempotencyWindow = 24 * time.Hour
+
+	// DefaultIdempotencyCapacity bounds how many keys are remembered
+	DefaultIdempotencyCapacity = 100_000
+)
+
+// ErrIdempotencyKeyReused is returned when a key is presented again with a
+// different method or request than it was first used for
+var ErrIdempotencyKeyReused = rpcerr.New(rpcerr.Conflict, "idempotency_key_reused", "idempotency key reused for a different request")
+
+// idempotencyStore remembers responses by idempotency key so a retried
+// request gets the original response instead of re-running its state
+// change. Failed calls aren't remembered, so they can be retried. Keys
+// expire after the window, and the oldest are evicted beyond capacity.
+type idempotencyStore struct {
+	mu       sync.Mutex
+	window   time.Duration
+	capacity int
+	entries  map[string]*idempotencyEntry
+	order    []string // Keys, oldest first
+	now      func() time.Time
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	expires     time.Time
+	done        chan struct{} // Closed once resp and err are set
+	resp        any
+	err         error
+}
+
+func newIdempotencyStore(window time.Duration, capacity int) *idempotencyStore {
+	if window <= 0 {
+		window = DefaultIdempotencyWindow
+	}
+	if capacity <= 0 {
+		capacity = DefaultIdempotencyCapacity
+	}
+	return &idempotencyStore{
+		window:   window,
+		capacity: capacity,
+		entries:  make(map[string]*idempotencyEntry),
+		now:      time.Now,
+	}
+}
+
+// SetIdempotencyWindow sets how long, and for how many keys, escrow RPC
+// responses are replayed. Keys already remembered are forgotten.
+func (e *EscrowManager) SetIdempotencyWindow(window time.Duration, capacity int) {
+	e.idem = newIdempotencyStore(window, capacity)
+}
+
+// idempotent runs fn once per key. A retry with the same key and request
+// returns the first call's response, waiting for it if it's still running.
+// An empty key always runs fn.
+func idempotent[T any](s *idempotencyStore, method, key string, req any, fn func() (T, error)) (T, error) {
+	if key == "" || s == nil {
+		return fn()
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	fingerprint := method + ":" + string(body)
+
+	s.mu.Lock()
+	now := s.now()
+	s.prune(now)
+	if entry, ok := s.entries[key]; ok {
+		s.mu.Unlock()
+		if entry.fingerprint != fingerprint {
+			var zero T
+			return zero, ErrIdempotencyKeyReused
+		}
+		<-entry.done
+		resp, _ := entry.resp.(T)
+		return resp, entry.err
+	}
+	entry := &idempotencyEntry{fingerprint: fingerprint, expires: now.Add(s.window), done: make(chan struct{})}
+	s.entries[key] = entry
+	s.order = append(s.order, key)
+	s.mu.Unlock()
+
+	resp, err := fn()
+	entry.resp, entry.err = resp, err
+	close(entry.done)
+	if err != nil {
+		s.mu.Lock()
+		if s.entries[key] == entry {
+			delete(s.entries, key)
+		}
+		s.mu.Unlock()
+	}
+	return resp, err
+}
+
+// prune drops expired keys and, if the store is full, the oldest ones
+func (s *idempotencyStore) prune(now time.Time) {
+	n := 0
+	for ; n < len(s.order); n++ {
+		key := s.order[n]
+		entry, ok := s.entries[key]
+		if !ok {
+			continue // Failed call, already removed
+		}
+		if now.Before(entry.expires) && len(s.entries) < s.capacity {
+			break
+		}
+		delete(s.entries, key)
+	}
+	s.order = s.order[n:]
+}
@@ -0,0 +1,124 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+func TestFundCampaign_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(1000))
+	e := NewEscrowManager(&VMState{}, engine, "AUSD")
+
+	req := &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100), IdempotencyKey: "fund-1"}
+	first, err := e.FundCampaign(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A retry, e.g. after the first response timed out, replays it
+	retry, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100), IdempotencyKey: "fund-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry != first {
+		t.Error("retry didn't return the original response")
+	}
+	campaign, _ := e.state.GetCampaign("c1")
+	if !campaign.TotalBudget.Equal(decimal.NewFromInt(100)) || !engine.GetBalance("AUSD", "adv-1").Equal(decimal.NewFromInt(900)) {
+		t.Errorf("budget %s, advertiser balance %s after retry", campaign.TotalBudget, engine.GetBalance("AUSD", "adv-1"))
+	}
+
+	// The same key can't be reused for a different request
+	req.Amount = decimal.NewFromInt(50)
+	if _, err := e.FundCampaign(ctx, req); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("reused key: err = %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+
+	// A new key funds again
+	if _, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100), IdempotencyKey: "fund-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if campaign, _ := e.state.GetCampaign("c1"); !campaign.TotalBudget.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("budget = %s after a second key, want 200", campaign.TotalBudget)
+	}
+}
+
+func TestIdempotency_ConcurrentRetriesRunOnce(t *testing.T) {
+	s := newIdempotencyStore(time.Minute, 10)
+	var mu sync.Mutex
+	runs := 0
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = idempotent(s, "m", "k", nil, func() (int, error) {
+				mu.Lock()
+				runs++
+				mu.Unlock()
+				<-release
+				return 42, nil
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if runs != 1 {
+		t.Errorf("ran %d times, want 1", runs)
+	}
+	for i, r := range results {
+		if r != 42 {
+			t.Errorf("call %d got %d", i, r)
+		}
+	}
+}
+
+func TestIdempotency_ExpiryAndFailures(t *testing.T) {
+	now := time.Now()
+	s := newIdempotencyStore(time.Minute, 2)
+	s.now = func() time.Time { return now }
+	runs := 0
+	call := func(key string, fail bool) {
+		idempotent(s, "m", key, nil, func() (int, error) {
+			runs++
+			if fail {
+				return 0, errors.New("boom")
+			}
+			return runs, nil
+		})
+	}
+
+	// Failures aren't remembered, so the retry runs
+	call("a", true)
+	call("a", false)
+	call("a", false)
+	if runs != 2 {
+		t.Fatalf("runs = %d, want 2", runs)
+	}
+
+	// Past the window the key runs again
+	now = now.Add(2 * time.Minute)
+	call("a", false)
+	if runs != 3 {
+		t.Errorf("runs = %d after expiry, want 3", runs)
+	}
+
+	// Beyond capacity the oldest key is forgotten
+	call("b", false)
+	call("c", false)
+	if _, ok := s.entries["a"]; ok || len(s.entries) > 2 {
+		t.Errorf("store holds %d keys, a kept: %v", len(s.entries), ok)
+	}
+}
@@ -0,0 +1,334 @@
+package chainvm
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrUnbalanced is a ledger entry whose postings don't sum to zero
+	ErrUnbalanced = rpcerr.New(rpcerr.Invalid, "ledger_unbalanced", "ledger entry does not balance")
+	// ErrLedgerClosed is a post to a closed ledger
+	ErrLedgerClosed = rpcerr.New(rpcerr.Precondition, "ledger_closed", "ledger closed")
+)
+
+// Ledger entry kinds
+const (
+	EntryFunding     = "funding"     // AUSD deposited into a campaign
+	EntryReservation = "reservation" // Budget locked in escrow for an impression or PG deal
+	EntrySettlement  = "settlement"  // Escrow paid to a publisher, less any holdback, and any unspent part returned
+	EntryPayout      = "payout"      // Holdback released to a publisher
+	EntryPenalty     = "penalty"     // Charged to a publisher for under-delivery
+	EntryDispute     = "dispute"     // Holdback or payment clawed back to a campaign
+)
+
+// AdvertiserAccount is the advertiser's AUSD outside the exchange; it
+// goes negative by what they've funded
+func AdvertiserAccount(advertiser string) string { return "advertiser:" + advertiser }
+
+// CampaignAccount is a campaign's available budget
+func CampaignAccount(campaignID string) string { return "campaign:" + campaignID }
+
+// EscrowAccount is a campaign's budget locked by reservations and PG deals
+func EscrowAccount(campaignID string) string { return "escrow:" + campaignID }
+
+// HoldbackAccount is a publisher's earnings withheld for the fraud window
+func HoldbackAccount(publisher string) string { return "holdback:" + publisher }
+
+// PublisherAccount is a publisher's settled balance
+func PublisherAccount(publisher string) string { return "publisher:" + publisher }
+
+// Posting moves Amount into an account; a negative amount moves it out
+type Posting struct {
+	Account string          `json:"account"`
+	Amount  decimal.Decimal `json:"amount"`
+}
+
+// LedgerEntry is one movement of funds. Its postings sum to zero, so
+// funds are only ever moved between accounts, never created or lost.
+type LedgerEntry struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Ref      string    `json:"ref"` // Campaign, reservation or deal moved for
+	Postings []Posting `json:"postings"`
+}
+
+// TimeRange bounds a statement; a zero Start or End leaves it open
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (tr TimeRange) contains(t time.Time) bool {
+	return (tr.Start.IsZero() || !t.Before(tr.Start)) && (tr.End.IsZero() || t.Before(tr.End))
+}
+
+// StatementLine is one entry's effect on a statement's account
+type StatementLine struct {
+	Seq     uint64          `json:"seq"`
+	Time    time.Time       `json:"time"`
+	Kind    string          `json:"kind"`
+	Ref     string          `json:"ref"`
+	Amount  decimal.Decimal `json:"amount"`
+	Balance decimal.Decimal `json:"balance"` // After this line
+}
+
+// Statement is an account's movements over a time range, between its
+// balance at the start and at the end
+type Statement struct {
+	Account string          `json:"account"`
+	Range   TimeRange       `json:"-"`
+	Opening decimal.Decimal `json:"opening"`
+	Lines   []StatementLine `json:"lines"`
+	Closing decimal.Decimal `json:"closing"`
+}
+
+// Ledger is an append-only, double-entry record of every movement of
+// escrowed funds. Opened on a file, each entry is synced to it before
+// it's applied, so the record survives a crash.
+type Ledger struct {
+	mu      sync.RWMutex
+	entries []LedgerEntry
+	file    ledgerFile
+	size    int64 // Of the file's whole entries
+	closed  bool
+	now     func() time.Time
+}
+
+// ledgerFile is the file a ledger appends its entries to
+type ledgerFile interface {
+	io.WriteCloser
+	Sync() error
+	Truncate(size int64) error
+}
+
+// NewLedger creates a ledger held in memory
+func NewLedger() *Ledger {
+	return &Ledger{now: time.Now}
+}
+
+// OpenLedger opens the ledger persisted at path, replaying its entries,
+// and appends new ones to it. A missing file is an empty ledger. A last
+// entry torn by a crash mid-write was never applied, so it's cut off;
+// damage anywhere else is an error.
+func OpenLedger(path string) (*Ledger, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Ledger{file: f, now: time.Now}
+	r := bufio.NewReaderSize(f, 64*1024)
+	var end int64 // Of the last whole entry
+	cut := func() (*Ledger, error) {
+		if err := f.Truncate(end); err != nil {
+			f.Close()
+			return nil, err
+		}
+		l.size = end
+		return l, nil
+	}
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// Entries are written with their newline, so anything after
+			// the last one is torn
+			if len(line) > 0 {
+				return cut()
+			}
+			l.size = end
+			return l, nil
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var entry LedgerEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if _, perr := r.Peek(1); perr == io.EOF {
+				return cut()
+			}
+			f.Close()
+			return nil, fmt.Errorf("%s: entry %d: %w", path, len(l.entries)+1, err)
+		}
+		if err := checkBalanced(entry.Postings); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: entry %d: %w", path, entry.Seq, err)
+		}
+		l.entries = append(l.entries, entry)
+		end += int64(len(line))
+	}
+}
+
+// Close closes the ledger's file, if it has one
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Post records an entry of postings, which must balance. Zero postings are
+// dropped, and nothing is recorded if none are left.
+func (l *Ledger) Post(kind, ref string, postings ...Posting) error {
+	var kept []Posting
+	for _, p := range postings {
+		if !p.Amount.IsZero() {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	if err := checkBalanced(kept); err != nil {
+		return fmt.Errorf("%s %s: %w", kind, ref, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrLedgerClosed
+	}
+	entry := LedgerEntry{Seq: uint64(len(l.entries)) + 1, Time: l.now().UTC(), Kind: kind, Ref: ref, Postings: kept}
+	if l.file != nil {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := l.append(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// append writes and syncs an entry's line. A failed write may leave some
+// or all of the line in the file, where it would be replayed, or tear the
+// file if more entries followed, so the file is cut back to its last whole
+// entry; if even that fails, the ledger is closed to further posts.
+func (l *Ledger) append(line []byte) error {
+	_, err := l.file.Write(line)
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err == nil {
+		l.size += int64(len(line))
+		return nil
+	}
+	if terr := l.file.Truncate(l.size); terr != nil {
+		l.closed = true
+		return fmt.Errorf("%w; ledger closed, cutting back failed: %v", err, terr)
+	}
+	return err
+}
+
+// Entries returns every entry, oldest first
+func (l *Ledger) Entries() []LedgerEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]LedgerEntry(nil), l.entries...)
+}
+
+// Balance is an account's balance after every entry
+func (l *Ledger) Balance(account string) decimal.Decimal {
+	return l.GetStatement(account, TimeRange{}).Closing
+}
+
+// Total is the sum of every account's balance, which is always zero
+func (l *Ledger) Total() decimal.Decimal {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	total := decimal.Zero
+	for _, e := range l.entries {
+		for _, p := range e.Postings {
+			total = total.Add(p.Amount)
+		}
+	}
+	return total
+}
+
+// GetStatement lists an account's movements within tr
+func (l *Ledger) GetStatement(account string, tr TimeRange) Statement {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	st := Statement{Account: account, Range: tr, Opening: decimal.Zero}
+	balance := decimal.Zero
+	for _, e := range l.entries {
+		if !tr.End.IsZero() && !e.Time.Before(tr.End) {
+			break
+		}
+		amount := decimal.Zero
+		for _, p := range e.Postings {
+			if p.Account == account {
+				amount = amount.Add(p.Amount)
+			}
+		}
+		if amount.IsZero() {
+			continue
+		}
+		balance = balance.Add(amount)
+		if !tr.contains(e.Time) {
+			st.Opening = balance
+			continue
+		}
+		st.Lines = append(st.Lines, StatementLine{Seq: e.Seq, Time: e.Time, Kind: e.Kind, Ref: e.Ref, Amount: amount, Balance: balance})
+	}
+	st.Closing = balance
+	return st
+}
+
+func checkBalanced(postings []Posting) error {
+	sum := decimal.Zero
+	for _, p := range postings {
+		if p.Account == "" {
+			return errors.New("posting without an account")
+		}
+		sum = sum.Add(p.Amount)
+	}
+	if !sum.IsZero() {
+		return fmt.Errorf("%w: off by %s", ErrUnbalanced, sum)
+	}
+	return nil
+}
+
+// SetLedger records every movement of escrowed funds in l from now on;
+// nothing is recorded when it's nil
+func (e *EscrowManager) SetLedger(l *Ledger) {
+	e.ledger = l
+}
+
+// Ledger returns the escrow's ledger, nil if none is set
+func (e *EscrowManager) Ledger() *Ledger {
+	return e.ledger
+}
+
+// record posts an entry to the ledger, if there is one
+func (e *EscrowManager) record(kind, ref string, postings ...Posting) error {
+	if e.ledger == nil {
+		return nil
+	}
+	if err := e.ledger.Post(kind, ref, postings...); err != nil {
+		return fmt.Errorf("ledger: %w", err)
+	}
+	return nil
+}
+
+// transfer is the pair of postings moving amount from one account to another
+func transfer(from, to string, amount decimal.Decimal) []Posting {
+	return []Posting{{Account: from, Amount: amount.Neg()}, {Account: to, Amount: amount}}
+}
@@ -0,0 +1,299 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+func TestLedger_SettlementLifecycle(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	ledger, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	e := NewEscrowManager(&VMState{}, engine, "AUSD")
+	e.SetLedger(ledger)
+	proof := strings.Repeat("p", 32)
+
+	if _, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100), HoldbackBps: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	reserve(t, e, "r1", "10")
+	reserve(t, e, "r2", "4")
+	// r1 delivers 8 of its 10: 7.2 paid now, 0.8 held back, 2 returned
+	if _, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "r1", VerificationProof: proof, Amount: decimal.NewNullDecimal(decimal.NewFromInt(8))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.ReleaseHoldback("r1"); err != nil {
+		t.Fatal(err)
+	}
+	// r2 is disputed: its 0.4 holdback and 3.6 payment both come back
+	if _, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "r2", VerificationProof: proof}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.ClawbackSettlement("r2", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ledger.Total().IsZero() {
+		t.Errorf("ledger totals %s, want zero", ledger.Total())
+	}
+	campaign, _ := e.state.GetCampaign("c1")
+	balances := map[string]decimal.Decimal{
+		AdvertiserAccount("adv-1"): decimal.NewFromInt(-100),
+		CampaignAccount("c1"):      campaign.AvailableBudget,
+		EscrowAccount("c1"):        campaign.ReservedBudget,
+		HoldbackAccount("pub-1"):   decimal.Zero,
+		PublisherAccount("pub-1"):  e.state.GetPublisherBalance("pub-1"),
+	}
+	for account, want := range balances {
+		if got := ledger.Balance(account); !got.Equal(want) {
+			t.Errorf("%s balance = %s, want %s", account, got, want)
+		}
+	}
+	if got := ledger.Balance(CampaignAccount("c1")); !got.Equal(decimal.NewFromInt(92)) {
+		t.Errorf("campaign balance = %s, want 92", got)
+	}
+
+	st := ledger.GetStatement(PublisherAccount("pub-1"), TimeRange{})
+	kinds := make([]string, len(st.Lines))
+	sum := st.Opening
+	for i, line := range st.Lines {
+		kinds[i] = line.Kind
+		sum = sum.Add(line.Amount)
+		if !line.Balance.Equal(sum) {
+			t.Errorf("line %d balance = %s, want running total %s", i, line.Balance, sum)
+		}
+	}
+	if got := strings.Join(kinds, " "); got != "settlement payout settlement dispute" {
+		t.Errorf("publisher statement = %s", got)
+	}
+	if !sum.Equal(st.Closing) {
+		t.Errorf("statement lines sum to %s, closing %s", sum, st.Closing)
+	}
+
+	// A range after the last entry opens and closes at the final balance
+	later := ledger.GetStatement(PublisherAccount("pub-1"), TimeRange{Start: time.Now().Add(time.Hour)})
+	if len(later.Lines) != 0 || !later.Opening.Equal(st.Closing) || !later.Closing.Equal(st.Closing) {
+		t.Errorf("later statement = %+v", later)
+	}
+
+	// The ledger survives a restart
+	if err := ledger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if len(reopened.Entries()) != len(ledger.Entries()) {
+		t.Fatalf("reopened with %d entries, want %d", len(reopened.Entries()), len(ledger.Entries()))
+	}
+	for account, want := range balances {
+		if got := reopened.Balance(account); !got.Equal(want) {
+			t.Errorf("reopened %s balance = %s, want %s", account, got, want)
+		}
+	}
+}
+
+func TestLedger_RejectsUnbalanced(t *testing.T) {
+	l := NewLedger()
+	err := l.Post(EntryPenalty, "d1",
+		Posting{Account: PublisherAccount("pub-1"), Amount: decimal.NewFromInt(-5)},
+		Posting{Account: CampaignAccount("c1"), Amount: decimal.NewFromInt(4)},
+	)
+	if !errors.Is(err, ErrUnbalanced) {
+		t.Errorf("err = %v, want %v", err, ErrUnbalanced)
+	}
+	if len(l.Entries()) != 0 {
+		t.Error("unbalanced entry recorded")
+	}
+	if err := l.Post(EntryPenalty, "d1", transfer(PublisherAccount("pub-1"), CampaignAccount("c1"), decimal.NewFromInt(5))...); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Balance(CampaignAccount("c1")); !got.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("campaign balance = %s, want 5", got)
+	}
+}
+
+func TestOpenLedger_TornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	l, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{"c1", "c2"} {
+		if err := l.Post(EntryFunding, ref, transfer(AdvertiserAccount("adv-1"), CampaignAccount(ref), decimal.NewFromInt(5))...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash mid-write leaves part of a third entry
+	if err := os.WriteFile(path, append(append([]byte(nil), data...), `{"seq":3,"kind":"fund`...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err = OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(l.Entries()); n != 2 {
+		t.Fatalf("reopened with %d entries, want 2", n)
+	}
+	if err := l.Post(EntryFunding, "c3", transfer(AdvertiserAccount("adv-1"), CampaignAccount("c3"), decimal.NewFromInt(5))...); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	l, err = OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(l.Entries()); n != 3 || !l.Balance(AdvertiserAccount("adv-1")).Equal(decimal.NewFromInt(-15)) {
+		t.Errorf("after appending past the torn entry: %d entries, advertiser %s", n, l.Balance(AdvertiserAccount("adv-1")))
+	}
+	l.Close()
+
+	// Damage before the last entry isn't a torn write
+	lines := strings.SplitAfter(string(data), "\n")
+	if err := os.WriteFile(path, []byte("garbage\n"+lines[1]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenLedger(path); err == nil {
+		t.Error("opened a ledger with a corrupt entry")
+	}
+}
+
+// flakyFile is a ledger file whose writes land only partly while failing
+// is set, and whose truncation fails while truncateErr is
+type flakyFile struct {
+	data        []byte
+	failing     bool
+	truncateErr error
+}
+
+func (f *flakyFile) Write(p []byte) (int, error) {
+	if f.failing {
+		f.data = append(f.data, p[:len(p)/2]...)
+		return len(p) / 2, errors.New("disk full")
+	}
+	f.data = append(f.data, p...)
+	return len(p), nil
+}
+
+func (f *flakyFile) Sync() error  { return nil }
+func (f *flakyFile) Close() error { return nil }
+
+func (f *flakyFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	f.data = f.data[:size]
+	return nil
+}
+
+func TestLedger_FailedWriteCutBack(t *testing.T) {
+	file := &flakyFile{}
+	l := &Ledger{file: file, now: time.Now}
+	fund := func(ref string) error {
+		return l.Post(EntryFunding, ref, transfer(AdvertiserAccount("adv-1"), CampaignAccount(ref), decimal.NewFromInt(5))...)
+	}
+	if err := fund("c1"); err != nil {
+		t.Fatal(err)
+	}
+	written := len(file.data)
+
+	// The torn line is cut off, so it's neither applied nor replayed
+	file.failing = true
+	if err := fund("c2"); err == nil {
+		t.Fatal("post succeeded on a failing write")
+	}
+	if len(file.data) != written || len(l.Entries()) != 1 {
+		t.Fatalf("after a failed write: %d bytes, %d entries; want %d bytes, 1 entry", len(file.data), len(l.Entries()), written)
+	}
+
+	// Later entries follow the last whole one
+	file.failing = false
+	if err := fund("c3"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	if err := os.WriteFile(path, file.data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if entries := reopened.Entries(); len(entries) != 2 || entries[1].Ref != "c3" {
+		t.Errorf("replayed %+v, want c1 and c3", entries)
+	}
+
+	// A line that can't be cut off closes the ledger
+	file.failing, file.truncateErr = true, errors.New("read-only")
+	if err := fund("c4"); err == nil {
+		t.Fatal("post succeeded on a failing write")
+	}
+	file.failing = false
+	if err := fund("c5"); !errors.Is(err, ErrLedgerClosed) {
+		t.Errorf("post after a failed cut: err = %v, want %v", err, ErrLedgerClosed)
+	}
+}
+
+func TestLedger_ClosedChangesNothing(t *testing.T) {
+	ctx := context.Background()
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	e := NewEscrowManager(&VMState{}, engine, "AUSD")
+	ledger := NewLedger()
+	e.SetLedger(ledger)
+	if _, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(50), HoldbackBps: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	reserve(t, e, "r1", "10")
+	if _, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "r1", VerificationProof: strings.Repeat("p", 32)}); err != nil {
+		t.Fatal(err)
+	}
+	ledger.Close()
+
+	if err := ledger.Post(EntryFunding, "c1", transfer(AdvertiserAccount("adv-1"), CampaignAccount("c1"), decimal.NewFromInt(1))...); !errors.Is(err, ErrLedgerClosed) {
+		t.Errorf("post after close: err = %v, want %v", err, ErrLedgerClosed)
+	}
+	if _, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(50)}); !errors.Is(err, ErrLedgerClosed) {
+		t.Errorf("funding: err = %v, want %v", err, ErrLedgerClosed)
+	}
+	if _, err := e.ClawbackSettlement("r1", true); !errors.Is(err, ErrLedgerClosed) {
+		t.Errorf("clawback: err = %v, want %v", err, ErrLedgerClosed)
+	}
+	if _, err := e.ReleaseHoldback("r1"); !errors.Is(err, ErrLedgerClosed) {
+		t.Errorf("release: err = %v, want %v", err, ErrLedgerClosed)
+	}
+
+	checkBalances(t, engine, map[[2]string]string{{"AUSD", "adv-1"}: "50", {"AUSD", "escrow"}: "50"})
+	campaign, _ := e.state.GetCampaign("c1")
+	if !campaign.TotalBudget.Equal(decimal.NewFromInt(50)) || !campaign.SpentBudget.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("campaign total %s, spent %s", campaign.TotalBudget, campaign.SpentBudget)
+	}
+	if got := e.state.GetPublisherBalance("pub-1"); !got.Equal(decimal.NewFromInt(9)) {
+		t.Errorf("publisher balance = %s, want 9", got)
+	}
+	if release, ok := e.state.PendingRelease("r1"); !ok || !release.Amount.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("pending release = %+v, %v", release, ok)
+	}
+}
@@ -0,0 +1,215 @@
+package chainvm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/luxfi/adx/pkg/oracle"
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrNoCaller is returned by RPCs acting for an account when the
+	// context doesn't carry one
+	ErrNoCaller = rpcerr.New(rpcerr.PermissionDenied, "no_caller", "no caller in context")
+
+	// ErrInsufficientLP is returned when withdrawing more LP tokens than
+	// the caller holds
+	ErrInsufficientLP = rpcerr.New(rpcerr.Precondition, "insufficient_lp", "insufficient LP tokens")
+
+	// ErrNoLiquidityProvider is returned when creating a pool with no
+	// provider to deposit its reserves
+	ErrNoLiquidityProvider = rpcerr.New(rpcerr.Invalid, "no_liquidity_provider", "liquidity provider required")
+)
+
+const (
+	// DefaultSwapFeeBps is the swap fee of pools created without one
+	DefaultSwapFeeBps = 30
+
+	// MaxSwapFeeBps bounds a pool's swap fee
+	MaxSwapFeeBps = 1000
+)
+
+type callerKey struct{}
+
+// WithCaller returns a context carrying the account an RPC acts for
+func WithCaller(ctx context.Context, account string) context.Context {
+	return context.WithValue(ctx, callerKey{}, account)
+}
+
+// Caller returns the account ctx acts for
+func Caller(ctx context.Context) (string, bool) {
+	account, ok := ctx.Value(callerKey{}).(string)
+	return account, ok && account != ""
+}
+
+// lpAsset is the dex asset ID of a slot pool's LP tokens
+func lpAsset(slotID uint64) string {
+	return fmt.Sprintf("adlp-%d", slotID)
+}
+
+// poolAccount is the dex account holding a slot pool's reserves
+func poolAccount(slotID uint64) string {
+	return fmt.Sprintf("adpool-%d", slotID)
+}
+
+type RemoveLiquidityResponse struct {
+	Success   bool            `json:"success"`
+	AUSDOut   decimal.Decimal `json:"ausd_out"`
+	SlotsOut  uint64          `json:"slots_out"`
+	SlotValue decimal.Decimal `json:"slot_value"` // SlotsOut at the decayed spot price
+	LPBurned  decimal.Decimal `json:"lp_burned"`
+	NewPrice  decimal.Decimal `json:"new_price"`
+
+	// The share of accrued swap fees included in the amounts out
+	FeesAUSD  decimal.Decimal `json:"fees_ausd"`
+	FeesSlots decimal.Decimal `json:"fees_slots"`
+}
+
+// PoolStats summarizes a slot pool for liquidity providers
+type PoolStats struct {
+	SlotID        uint64          `json:"slot_id"`
+	ReserveAUSD   decimal.Decimal `json:"reserve_ausd"`
+	ReserveSlots  uint64          `json:"reserve_slots"`
+	LPTokenSupply decimal.Decimal `json:"lp_token_supply"`
+	Price         decimal.Decimal `json:"price"`
+	FeeBps        uint16          `json:"fee_bps"`
+	FeesAUSD      decimal.Decimal `json:"fees_ausd"`  // Accrued and not yet withdrawn
+	FeesSlots     decimal.Decimal `json:"fees_slots"` // Accrued and not yet withdrawn
+
+	// From the price oracle, when it has them
+	PriceUSD     decimal.Decimal `json:"price_usd"`
+	ReserveUSD   decimal.Decimal `json:"reserve_usd"`   // The AUSD reserve in USD
+	BenchmarkCPM decimal.Decimal `json:"benchmark_cpm"` // Market CPM for the slot's format, USD
+}
+
+// PoolStats reports a slot pool's reserves and accrued fees
+func (a *AdSlotManager) PoolStats(ctx context.Context, slotID uint64) (*PoolStats, error) {
+	a.state.tx.RLock()
+	defer a.state.tx.RUnlock()
+
+	pool, exists := a.state.GetAdMM_Pool(slotID)
+	if !exists {
+		return nil, ErrPoolNotFound
+	}
+	stats := &PoolStats{
+		SlotID:        slotID,
+		ReserveAUSD:   pool.ReserveAUSD,
+		ReserveSlots:  pool.ReserveSlots,
+		LPTokenSupply: pool.LPTokenSupply,
+		Price:         pool.LastPrice,
+		FeeBps:        pool.FeeBps,
+		FeesAUSD:      pool.FeesAUSD,
+		FeesSlots:     pool.FeesSlots,
+	}
+	if a.prices == nil {
+		return stats, nil
+	}
+	if rate, err := a.prices.AUSDUSD(ctx); err == nil {
+		stats.PriceUSD = pool.LastPrice.Mul(rate.Price)
+		stats.ReserveUSD = pool.ReserveAUSD.Mul(rate.Price)
+	}
+	if slot, err := a.state.GetAdSlot(slotID); err == nil {
+		if cpm, err := a.prices.BenchmarkCPM(ctx, oracle.FormatOf(slot.Placement)); err == nil {
+			stats.BenchmarkCPM = cpm.Price
+		}
+	}
+	return stats, nil
+}
+
+// RemoveLiquidity burns the caller's LP tokens for their pro-rata share of
+// the pool's reserves, paid out of the pool's account. Slot tokens are
+// perishable: the share is valued at the time-decayed price, and once the
+// slot's window has closed the slot reserve is worthless and only AUSD is
+// returned. Slots are paid in whole tokens, the fraction staying in the
+// pool, except that the last LP drains it.
+func (a *AdSlotManager) RemoveLiquidity(ctx context.Context, slotID uint64, lpTokens decimal.Decimal) (*RemoveLiquidityResponse, error) {
+	a.state.tx.Lock()
+	defer a.state.tx.Unlock()
+
+	caller, ok := Caller(ctx)
+	if !ok {
+		return nil, ErrNoCaller
+	}
+	if err := checkAmount("lp_tokens", lpTokens); err != nil {
+		return nil, err
+	}
+	if lpTokens.IsZero() {
+		return nil, fmt.Errorf("lp_tokens: %w", ErrZeroAmount)
+	}
+
+	pool, exists := a.state.GetAdMM_Pool(slotID)
+	if !exists {
+		return nil, ErrPoolNotFound
+	}
+	slot, err := a.state.GetAdSlot(slotID)
+	if err != nil {
+		return nil, err
+	}
+	if a.dex.GetBalance(lpAsset(slotID), caller).LessThan(lpTokens) || lpTokens.GreaterThan(pool.LPTokenSupply) {
+		return nil, ErrInsufficientLP
+	}
+
+	now := time.Now()
+	reserveSlots := pool.ReserveSlots
+	expired := !now.Before(slot.EndTime)
+	if expired {
+		reserveSlots = 0
+	}
+
+	// Accrued fees sit in the reserves, so each LP's share includes theirs
+	var ausdOut, feesAUSD, feesSlots decimal.Decimal
+	var slotsOut uint64
+	if lpTokens.Equal(pool.LPTokenSupply) {
+		ausdOut, slotsOut = pool.ReserveAUSD, reserveSlots
+		feesAUSD, feesSlots = pool.FeesAUSD, pool.FeesSlots
+	} else {
+		share := lpTokens.Div(pool.LPTokenSupply)
+		ausdOut = pool.ReserveAUSD.Mul(share)
+		slotsOut = uint64(decimal.NewFromInt(int64(reserveSlots)).Mul(share).IntPart())
+		feesAUSD, feesSlots = pool.FeesAUSD.Mul(share), pool.FeesSlots.Mul(share)
+	}
+
+	// Burn the LP tokens and pay out of the pool's account as one
+	tx := &dexTx{dex: a.dex}
+	if err := tx.burn(lpAsset(slotID), caller, lpTokens); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInsufficientLP, err)
+	}
+	if err := tx.transfer(a.SettlementAsset, poolAccount(slotID), caller, ausdOut); err != nil {
+		tx.rollback()
+		return nil, fmt.Errorf("%w: AUSD: %v", ErrInsufficientLiquidity, err)
+	}
+	if err := tx.transfer(fmt.Sprintf("adslot-%d", slotID), poolAccount(slotID), caller, decimal.NewFromInt(int64(slotsOut))); err != nil {
+		tx.rollback()
+		return nil, fmt.Errorf("%w: slots: %v", ErrInsufficientLiquidity, err)
+	}
+
+	slotValue := decimal.Zero
+	if !expired {
+		decay := timeDecay(slot.StartTime, slot.EndTime, now, decayRate(pool))
+		slotValue = pool.LastPrice.Mul(decimal.NewFromInt(int64(slotsOut))).Mul(decay)
+	}
+
+	pool.ReserveAUSD = pool.ReserveAUSD.Sub(ausdOut)
+	pool.ReserveSlots = reserveSlots - slotsOut
+	pool.LPTokenSupply = pool.LPTokenSupply.Sub(lpTokens)
+	pool.FeesAUSD = pool.FeesAUSD.Sub(feesAUSD)
+	pool.FeesSlots = pool.FeesSlots.Sub(feesSlots)
+	if pool.ReserveSlots > 0 {
+		pool.LastPrice = pool.ReserveAUSD.Div(decimal.NewFromInt(int64(pool.ReserveSlots)))
+	}
+	a.state.SetAdMM_Pool(slotID, pool)
+
+	return &RemoveLiquidityResponse{
+		Success:   true,
+		AUSDOut:   ausdOut,
+		SlotsOut:  slotsOut,
+		SlotValue: slotValue,
+		LPBurned:  lpTokens,
+		NewPrice:  pool.LastPrice,
+		FeesAUSD:  feesAUSD,
+		FeesSlots: feesSlots,
+	}, nil
+}
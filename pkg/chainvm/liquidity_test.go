@@ -0,0 +1,349 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/oracle"
+	"github.com/shopspring/decimal"
+)
+
+// newLPPool creates a 1000 AUSD / 1000 slot pool, halfway through its
+// slot's window, whose 1000 LP tokens are held by "lp-1", who keeps another
+// 1000 AUSD to swap with
+func newLPPool(t *testing.T) (*AdSlotManager, *dex.Engine, uint64) {
+	t.Helper()
+	engine := dex.NewEngine()
+	a := NewAdSlotManager(&VMState{}, engine)
+	now := time.Now()
+	slot, err := a.CreateAdSlot(context.Background(), &CreateAdSlotRequest{
+		Publisher:      "pub-1",
+		StartTime:      now.Add(-time.Hour),
+		EndTime:        now.Add(time.Hour),
+		MaxImpressions: 5000,
+		FloorCPM:       decimal.NewFromInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetBalance("AUSD", "lp-1", decimal.NewFromInt(2000))
+	if err := engine.TransferAsset(slot.TokenID, "pub-1", "lp-1", decimal.NewFromInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := a.CreateAdMM_Pool(context.Background(), &CreateAdMM_PoolRequest{
+		SlotID:            slot.SlotID,
+		InitialAUSD:       decimal.NewFromInt(1000),
+		InitialSlots:      1000,
+		LiquidityProvider: "lp-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.LPTokens.Equal(decimal.NewFromInt(1000)) || !engine.GetBalance(lpAsset(slot.SlotID), "lp-1").Equal(resp.LPTokens) {
+		t.Fatalf("minted %s LP tokens, provider holds %s", resp.LPTokens, engine.GetBalance(lpAsset(slot.SlotID), "lp-1"))
+	}
+	return a, engine, slot.SlotID
+}
+
+func TestRemoveLiquidity_ProRata(t *testing.T) {
+	a, engine, slotID := newLPPool(t)
+	if err := engine.TransferAsset(lpAsset(slotID), "lp-1", "lp-2", decimal.NewFromInt(250)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := a.RemoveLiquidity(WithCaller(context.Background(), "lp-2"), slotID, decimal.NewFromInt(250))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.AUSDOut.Equal(decimal.NewFromInt(250)) || resp.SlotsOut != 250 {
+		t.Errorf("withdrew %s AUSD and %d slots, want a quarter of the pool", resp.AUSDOut, resp.SlotsOut)
+	}
+	// Halfway through the window the slots are worth a fraction of the
+	// pool price
+	decay := timeDecay(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), time.Now(), DefaultTimeDecayRate)
+	if want := decimal.NewFromInt(250).Mul(decay); resp.SlotValue.Sub(want).Abs().GreaterThan(decimal.RequireFromString("0.01")) {
+		t.Errorf("slot value = %s, want ~%s", resp.SlotValue, want)
+	}
+
+	pool, _ := a.state.GetAdMM_Pool(slotID)
+	if !pool.ReserveAUSD.Equal(decimal.NewFromInt(750)) || pool.ReserveSlots != 750 || !pool.LPTokenSupply.Equal(decimal.NewFromInt(750)) {
+		t.Errorf("pool after withdrawal = %s AUSD, %d slots, %s LP", pool.ReserveAUSD, pool.ReserveSlots, pool.LPTokenSupply)
+	}
+	if !engine.GetBalance(lpAsset(slotID), "lp-2").IsZero() {
+		t.Error("LP tokens not burned")
+	}
+	// The share is paid out of the pool's account
+	for _, b := range []struct {
+		asset, account string
+		want           int64
+	}{
+		{"AUSD", "lp-2", 250},
+		{"adslot-0", "lp-2", 250},
+		{"AUSD", poolAccount(slotID), 750},
+		{"adslot-0", poolAccount(slotID), 750},
+	} {
+		if got := engine.GetBalance(b.asset, b.account); !got.Equal(decimal.NewFromInt(b.want)) {
+			t.Errorf("%s balance of %s = %s, want %d", b.asset, b.account, got, b.want)
+		}
+	}
+}
+
+func TestRemoveLiquidity_SoleLPDrains(t *testing.T) {
+	a, engine, slotID := newLPPool(t)
+	ctx := WithCaller(context.Background(), "lp-1")
+
+	// A swap leaves reserves that don't split into whole slots evenly
+	if _, err := a.SwapAdMM(ctx, &SwapAdMM_Request{SlotID: slotID, AmountIn: decimal.RequireFromString("33.3")}); err != nil {
+		t.Fatal(err)
+	}
+	pool, _ := a.state.GetAdMM_Pool(slotID)
+	wantAUSD, wantSlots := pool.ReserveAUSD, pool.ReserveSlots
+
+	resp, err := a.RemoveLiquidity(ctx, slotID, decimal.NewFromInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.AUSDOut.Equal(wantAUSD) || resp.SlotsOut != wantSlots {
+		t.Errorf("drained %s AUSD and %d slots, want %s and %d", resp.AUSDOut, resp.SlotsOut, wantAUSD, wantSlots)
+	}
+	if !pool.ReserveAUSD.IsZero() || pool.ReserveSlots != 0 || !pool.LPTokenSupply.IsZero() {
+		t.Errorf("pool not empty: %s AUSD, %d slots, %s LP", pool.ReserveAUSD, pool.ReserveSlots, pool.LPTokenSupply)
+	}
+	// The pool's account held exactly its reserves; the LP's slots are
+	// back whole, the fee's fraction included
+	if b := engine.GetBalance("AUSD", poolAccount(slotID)); !b.IsZero() {
+		t.Errorf("pool account left with %s AUSD", b)
+	}
+	if b := engine.GetBalance("AUSD", "lp-1"); !b.Equal(decimal.NewFromInt(2000)) {
+		t.Errorf("LP holds %s AUSD after draining, want the 2000 it started with", b)
+	}
+}
+
+func TestRemoveLiquidity_AfterExpiry(t *testing.T) {
+	a, _, slotID := newLPPool(t)
+	slot, _ := a.state.GetAdSlot(slotID)
+	slot.EndTime = time.Now().Add(-time.Second)
+
+	resp, err := a.RemoveLiquidity(WithCaller(context.Background(), "lp-1"), slotID, decimal.NewFromInt(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Expired slots can't be delivered, so only AUSD comes back
+	if !resp.AUSDOut.Equal(decimal.NewFromInt(500)) || resp.SlotsOut != 0 || !resp.SlotValue.IsZero() {
+		t.Errorf("withdrew %s AUSD and %d slots worth %s", resp.AUSDOut, resp.SlotsOut, resp.SlotValue)
+	}
+}
+
+func TestRemoveLiquidity_Insufficient(t *testing.T) {
+	a, engine, slotID := newLPPool(t)
+	engine.TransferAsset(lpAsset(slotID), "lp-1", "lp-2", decimal.NewFromInt(100))
+
+	if _, err := a.RemoveLiquidity(WithCaller(context.Background(), "lp-2"), slotID, decimal.NewFromInt(101)); !errors.Is(err, ErrInsufficientLP) {
+		t.Errorf("overdrawn: err = %v, want %v", err, ErrInsufficientLP)
+	}
+	if _, err := a.RemoveLiquidity(context.Background(), slotID, decimal.NewFromInt(1)); !errors.Is(err, ErrNoCaller) {
+		t.Errorf("no caller: err = %v, want %v", err, ErrNoCaller)
+	}
+
+	pool, _ := a.state.GetAdMM_Pool(slotID)
+	if !pool.ReserveAUSD.Equal(decimal.NewFromInt(1000)) || !pool.LPTokenSupply.Equal(decimal.NewFromInt(1000)) ||
+		!engine.GetBalance(lpAsset(slotID), "lp-2").Equal(decimal.NewFromInt(100)) ||
+		!engine.GetBalance("AUSD", poolAccount(slotID)).Equal(decimal.NewFromInt(1000)) {
+		t.Error("rejected withdrawal changed the pool")
+	}
+}
+
+func TestSwapAdMM_SmallSwapMovesLittleReserve(t *testing.T) {
+	// Halfway through the window, when the slots have decayed to a
+	// fraction of their value
+	a, engine, slotID := newLPPool(t)
+	trader := WithCaller(context.Background(), "lp-1")
+
+	// 9.97 AUSD priced: 1000 - 1e6/1009.97 = 9.87 slots
+	buy, err := a.SwapAdMM(trader, &SwapAdMM_Request{SlotID: slotID, AmountIn: decimal.NewFromInt(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !buy.AmountOut.Equal(decimal.NewFromInt(9)) {
+		t.Errorf("10 AUSD bought %s slots, want 9", buy.AmountOut)
+	}
+
+	// 0.997 slots priced: 1010 * 0.997/991.997 = 1.015 AUSD
+	sell, err := a.SwapAdMM(trader, &SwapAdMM_Request{SlotID: slotID, AmountIn: decimal.NewFromInt(1), BuyAUSD: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sell.AmountOut.Sub(decimal.RequireFromString("1.015")).Abs().GreaterThan(decimal.RequireFromString("0.001")) {
+		t.Errorf("1 slot sold for %s AUSD, want ~1.015", sell.AmountOut)
+	}
+
+	if got := engine.GetBalance("AUSD", poolAccount(slotID)); got.LessThan(decimal.NewFromInt(1008)) {
+		t.Errorf("pool holds %s AUSD after two small swaps", got)
+	}
+}
+
+func TestSwapAdMM_MinAmountOut(t *testing.T) {
+	a, engine, slotID := newLPPool(t)
+	trader := WithCaller(context.Background(), "lp-1")
+
+	// 10 AUSD buys 9 slots
+	_, err := a.SwapAdMM(trader, &SwapAdMM_Request{SlotID: slotID, AmountIn: decimal.NewFromInt(10), MinAmountOut: decimal.NewFromInt(10)})
+	if !errors.Is(err, ErrSlippageExceeded) {
+		t.Fatalf("err = %v, want ErrSlippageExceeded", err)
+	}
+	if got := engine.GetBalance("AUSD", "lp-1"); !got.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("rejected swap left the trader %s AUSD", got)
+	}
+	if pool, _ := a.state.GetAdMM_Pool(slotID); pool.ReserveSlots != 1000 || !pool.ReserveAUSD.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("rejected swap changed reserves to %s AUSD, %d slots", pool.ReserveAUSD, pool.ReserveSlots)
+	}
+
+	resp, err := a.SwapAdMM(trader, &SwapAdMM_Request{SlotID: slotID, AmountIn: decimal.NewFromInt(10), MinAmountOut: decimal.NewFromInt(9)})
+	if err != nil || !resp.AmountOut.Equal(decimal.NewFromInt(9)) {
+		t.Fatalf("swap at the minimum = %+v, %v", resp, err)
+	}
+}
+
+func TestSwapFees_AccrueToLPs(t *testing.T) {
+	engine := dex.NewEngine()
+	a := NewAdSlotManager(&VMState{}, engine)
+	ctx := context.Background()
+	slot, err := a.CreateAdSlot(ctx, &CreateAdSlotRequest{
+		Publisher:      "pub-1",
+		StartTime:      time.Now().Add(time.Hour),
+		EndTime:        time.Now().Add(2 * time.Hour),
+		MaxImpressions: 5000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetBalance("AUSD", "lp-1", decimal.NewFromInt(1000))
+	engine.TransferAsset(slot.TokenID, "pub-1", "lp-1", decimal.NewFromInt(1000))
+	if _, err := a.CreateAdMM_Pool(ctx, &CreateAdMM_PoolRequest{
+		SlotID:            slot.SlotID,
+		InitialAUSD:       decimal.NewFromInt(1000),
+		InitialSlots:      1000,
+		LiquidityProvider: "lp-1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	engine.TransferAsset(lpAsset(slot.SlotID), "lp-1", "lp-2", decimal.NewFromInt(250))
+
+	// A trader buys slots with 100 AUSD, then sells them all back
+	engine.SetBalance("AUSD", "dsp-1", decimal.NewFromInt(100))
+	trader := WithCaller(ctx, "dsp-1")
+	buy, err := a.SwapAdMM(trader, &SwapAdMM_Request{SlotID: slot.SlotID, AmountIn: decimal.NewFromInt(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 99.7 AUSD is priced: 1000 - 1e6/1099.7 = 90.66 slots
+	if !buy.AmountOut.Equal(decimal.NewFromInt(90)) {
+		t.Fatalf("bought %s slots, want 90", buy.AmountOut)
+	}
+	if !engine.GetBalance(slot.TokenID, "dsp-1").Equal(buy.AmountOut) {
+		t.Errorf("trader holds %s slots, bought %s", engine.GetBalance(slot.TokenID, "dsp-1"), buy.AmountOut)
+	}
+	sell, err := a.SwapAdMM(trader, &SwapAdMM_Request{SlotID: slot.SlotID, AmountIn: buy.AmountOut, BuyAUSD: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !engine.GetBalance("AUSD", "dsp-1").Equal(sell.AmountOut) || !engine.GetBalance(slot.TokenID, "dsp-1").IsZero() {
+		t.Errorf("trader holds %s AUSD and %s slots after selling", engine.GetBalance("AUSD", "dsp-1"), engine.GetBalance(slot.TokenID, "dsp-1"))
+	}
+
+	stats, err := a.PoolStats(ctx, slot.SlotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.FeeBps != DefaultSwapFeeBps || !stats.FeesAUSD.Equal(decimal.RequireFromString("0.3")) || !stats.FeesSlots.Equal(decimal.RequireFromString("0.27")) {
+		t.Errorf("stats = %+v", stats)
+	}
+	// The round trip leaves the slots where they were and more AUSD
+	if stats.ReserveSlots != 1000 || !stats.ReserveAUSD.GreaterThan(decimal.NewFromInt(1001)) {
+		t.Errorf("reserves after round trip = %s AUSD, %d slots", stats.ReserveAUSD, stats.ReserveSlots)
+	}
+
+	resp, err := a.RemoveLiquidity(WithCaller(ctx, "lp-2"), slot.SlotID, decimal.NewFromInt(250))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A quarter of the pool: the 250/250 deposited plus a quarter of the fees
+	if resp.SlotsOut != 250 || !resp.AUSDOut.Equal(stats.ReserveAUSD.Mul(decimal.RequireFromString("0.25"))) || !resp.AUSDOut.GreaterThan(decimal.NewFromInt(250)) {
+		t.Errorf("withdrew %s AUSD and %d slots", resp.AUSDOut, resp.SlotsOut)
+	}
+	if !resp.FeesAUSD.Equal(decimal.RequireFromString("0.075")) || !resp.FeesSlots.Equal(decimal.RequireFromString("0.0675")) {
+		t.Errorf("fee share = %s AUSD, %s slots", resp.FeesAUSD, resp.FeesSlots)
+	}
+	if stats, _ := a.PoolStats(ctx, slot.SlotID); !stats.FeesAUSD.Equal(decimal.RequireFromString("0.225")) {
+		t.Errorf("fees left for the other LP = %s", stats.FeesAUSD)
+	}
+
+	if _, err := a.CreateAdMM_Pool(ctx, &CreateAdMM_PoolRequest{
+		SlotID: slot.SlotID + 1, InitialAUSD: decimal.NewFromInt(1), InitialSlots: 1, FeeBps: MaxSwapFeeBps + 1,
+	}); !errors.Is(err, ErrRateOutOfRange) {
+		t.Errorf("oversized fee: err = %v", err)
+	}
+}
+
+func TestPoolStats_OraclePrices(t *testing.T) {
+	a, _, slotID := newLPPool(t)
+	a.SetPriceOracle(&oracle.Static{
+		AUSD: decimal.RequireFromString("0.98"),
+		CPMs: map[oracle.Format]decimal.Decimal{oracle.FormatBanner: decimal.NewFromInt(2)},
+	})
+
+	stats, err := a.PoolStats(context.Background(), slotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.ReserveUSD.Equal(decimal.NewFromInt(980)) || !stats.PriceUSD.Equal(stats.Price.Mul(decimal.RequireFromString("0.98"))) {
+		t.Errorf("reserve %s USD, price %s USD", stats.ReserveUSD, stats.PriceUSD)
+	}
+	if !stats.BenchmarkCPM.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("benchmark CPM = %s, want 2", stats.BenchmarkCPM)
+	}
+}
+
+func TestCreateAdMM_Pool_DepositsOrNothing(t *testing.T) {
+	engine := dex.NewEngine()
+	a := NewAdSlotManager(&VMState{}, engine)
+	ctx := context.Background()
+	slot, err := a.CreateAdSlot(ctx, &CreateAdSlotRequest{
+		Publisher:      "pub-1",
+		StartTime:      time.Now(),
+		EndTime:        time.Now().Add(time.Hour),
+		MaxImpressions: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetBalance("AUSD", "pub-1", decimal.NewFromInt(1000))
+
+	// The AUSD moves before the slots turn out short, and moves back
+	req := &CreateAdMM_PoolRequest{SlotID: slot.SlotID, InitialAUSD: decimal.NewFromInt(1000), InitialSlots: 200, LiquidityProvider: "pub-1"}
+	if _, err := a.CreateAdMM_Pool(ctx, req); !errors.Is(err, ErrInsufficientLiquidity) {
+		t.Fatalf("short of slots: err = %v, want %v", err, ErrInsufficientLiquidity)
+	}
+	if _, ok := a.state.GetAdMM_Pool(slot.SlotID); ok {
+		t.Error("pool stored after a failed deposit")
+	}
+	if b := engine.GetBalance("AUSD", "pub-1"); !b.Equal(decimal.NewFromInt(1000)) || !engine.GetBalance(lpAsset(slot.SlotID), "pub-1").IsZero() {
+		t.Errorf("after a failed deposit the provider holds %s AUSD and %s LP tokens", b, engine.GetBalance(lpAsset(slot.SlotID), "pub-1"))
+	}
+
+	req.LiquidityProvider = ""
+	if _, err := a.CreateAdMM_Pool(ctx, req); !errors.Is(err, ErrNoLiquidityProvider) {
+		t.Errorf("no provider: err = %v, want %v", err, ErrNoLiquidityProvider)
+	}
+
+	req.LiquidityProvider, req.InitialSlots = "pub-1", 100
+	if _, err := a.CreateAdMM_Pool(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if engine.GetBalance(slot.TokenID, poolAccount(slot.SlotID)).IntPart() != 100 || !engine.GetBalance("AUSD", "pub-1").IsZero() {
+		t.Error("reserves not deposited into the pool's account")
+	}
+}
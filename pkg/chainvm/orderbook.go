@@ -0,0 +1,102 @@
+package chainvm
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// OrderBookLevel is the resting quantity at one price
+type OrderBookLevel struct {
+	Price    decimal.Decimal `json:"price"`
+	Quantity uint64          `json:"quantity"`
+	Orders   int             `json:"orders"`
+}
+
+// OrderBook is a snapshot of a slot's resting orders and reference prices
+type OrderBook struct {
+	SlotID    uint64              `json:"slot_id"`
+	Bids      []OrderBookLevel    `json:"bids"` // Highest price first
+	Asks      []OrderBookLevel    `json:"asks"` // Lowest price first
+	BestBid   decimal.NullDecimal `json:"best_bid"`
+	BestAsk   decimal.NullDecimal `json:"best_ask"`
+	Spread    decimal.NullDecimal `json:"spread"`     // Set when both sides rest
+	SpotPrice decimal.Decimal     `json:"spot_price"` // Time-decayed floor
+	AMMPrice  decimal.NullDecimal `json:"amm_price"`  // Pool mid-price, if the slot has a pool
+	Timestamp time.Time           `json:"timestamp"`
+}
+
+// GetOrderBook aggregates a slot's active orders into price levels, up to
+// depth levels a side; depth <= 0 returns every level. Unrevealed sealed
+// bids have no price yet and are left out.
+func (a *AdSlotManager) GetOrderBook(slotID uint64, depth int) (*OrderBook, error) {
+	a.state.tx.RLock()
+	defer a.state.tx.RUnlock()
+
+	slot, err := a.state.GetAdSlot(slotID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	book := &OrderBook{SlotID: slotID, SpotPrice: a.priceAt(slot, now), Timestamp: now}
+
+	bids := make(map[string]*OrderBookLevel)
+	asks := make(map[string]*OrderBookLevel)
+	for _, o := range a.state.SlotOrders(slotID) {
+		remaining := o.Quantity - o.FilledQty
+		if o.Status != "active" || remaining == 0 || o.FilledQty > o.Quantity {
+			continue
+		}
+		if !o.ExpiresAt.IsZero() && !now.Before(o.ExpiresAt) {
+			continue
+		}
+		if o.OrderType == "commit-reveal" && !o.Revealed {
+			continue
+		}
+		levels := asks
+		if o.IsBuy {
+			levels = bids
+		}
+		key := o.LimitPrice.String()
+		level, ok := levels[key]
+		if !ok {
+			level = &OrderBookLevel{Price: o.LimitPrice}
+			levels[key] = level
+		}
+		level.Quantity += remaining
+		level.Orders++
+	}
+
+	book.Bids = bookSide(bids, depth, func(x, y decimal.Decimal) bool { return x.GreaterThan(y) })
+	book.Asks = bookSide(asks, depth, func(x, y decimal.Decimal) bool { return x.LessThan(y) })
+	if len(book.Bids) > 0 {
+		book.BestBid = decimal.NewNullDecimal(book.Bids[0].Price)
+	}
+	if len(book.Asks) > 0 {
+		book.BestAsk = decimal.NewNullDecimal(book.Asks[0].Price)
+	}
+	if book.BestBid.Valid && book.BestAsk.Valid {
+		book.Spread = decimal.NewNullDecimal(book.BestAsk.Decimal.Sub(book.BestBid.Decimal))
+	}
+
+	if pool, ok := a.state.GetAdMM_Pool(slotID); ok && pool.ReserveSlots > 0 {
+		book.AMMPrice = decimal.NewNullDecimal(pool.ReserveAUSD.Div(decimal.NewFromInt(int64(pool.ReserveSlots))))
+	}
+
+	return book, nil
+}
+
+// bookSide orders one side's levels best first and trims them to depth
+func bookSide(levels map[string]*OrderBookLevel, depth int, better func(x, y decimal.Decimal) bool) []OrderBookLevel {
+	out := make([]OrderBookLevel, 0, len(levels))
+	for _, l := range levels {
+		out = append(out, *l)
+	}
+	sort.Slice(out, func(i, j int) bool { return better(out[i].Price, out[j].Price) })
+	if depth > 0 && len(out) > depth {
+		out = out[:depth]
+	}
+	return out
+}
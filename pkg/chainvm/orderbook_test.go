@@ -0,0 +1,119 @@
+package chainvm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+func TestGetOrderBook(t *testing.T) {
+	ctx := context.Background()
+	a := NewAdSlotManager(&VMState{}, dex.NewEngine())
+	now := time.Now()
+	slot, err := a.CreateAdSlot(ctx, &CreateAdSlotRequest{
+		Publisher:      "pub-1",
+		StartTime:      now.Add(-9 * time.Hour),
+		EndTime:        now.Add(time.Hour),
+		MaxImpressions: 10000,
+		FloorCPM:       decimal.NewFromInt(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := []struct {
+		buy   bool
+		price string
+		qty   uint64
+		typ   string
+		exp   time.Time
+	}{
+		{true, "1.5", 100, "limit", time.Time{}},
+		{true, "1.50", 50, "limit", time.Time{}},
+		{true, "1.25", 10, "limit", time.Time{}},
+		{true, "1", 500, "limit", time.Time{}},
+		{false, "1.75", 40, "limit", time.Time{}},
+		{false, "2", 30, "limit", time.Time{}},
+		{false, "1.75", 5, "limit", time.Time{}},
+		// Neither an expired order nor an unrevealed sealed bid rests
+		{true, "1.7", 1000, "limit", now.Add(time.Millisecond)},
+		{true, "1.7", 1000, "commit-reveal", time.Time{}},
+	}
+	for i, o := range orders {
+		if _, err := a.PlaceOrder(ctx, &PlaceOrderRequest{
+			OrderID:    fmt.Sprintf("o%d", i),
+			SlotID:     slot.SlotID,
+			IsBuy:      o.buy,
+			OrderType:  o.typ,
+			LimitPrice: decimal.RequireFromString(o.price),
+			Quantity:   o.qty,
+			ExpiresAt:  o.exp,
+			CommitHash: "c",
+		}); err != nil {
+			t.Fatalf("order %d: %v", i, err)
+		}
+	}
+	// A partly filled ask rests with what's left
+	partial, _ := a.state.GetAdSlotOrder("o5")
+	partial.FilledQty = 20
+	a.state.SetAdMM_Pool(slot.SlotID, &AdMM_Pool{ReserveAUSD: decimal.NewFromInt(300), ReserveSlots: 200})
+	time.Sleep(2 * time.Millisecond)
+
+	book, err := a.GetOrderBook(slot.SlotID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBids := []OrderBookLevel{{decimal.RequireFromString("1.5"), 150, 2}, {decimal.RequireFromString("1.25"), 10, 1}}
+	wantAsks := []OrderBookLevel{{decimal.RequireFromString("1.75"), 45, 2}, {decimal.NewFromInt(2), 10, 1}}
+	for side, pair := range map[string][2][]OrderBookLevel{"bids": {book.Bids, wantBids}, "asks": {book.Asks, wantAsks}} {
+		got, want := pair[0], pair[1]
+		if len(got) != len(want) {
+			t.Fatalf("%s = %+v, want %+v", side, got, want)
+		}
+		for i := range want {
+			if !got[i].Price.Equal(want[i].Price) || got[i].Quantity != want[i].Quantity || got[i].Orders != want[i].Orders {
+				t.Errorf("%s level %d = %+v, want %+v", side, i, got[i], want[i])
+			}
+		}
+	}
+
+	if !book.BestBid.Decimal.Equal(decimal.RequireFromString("1.5")) ||
+		!book.BestAsk.Decimal.Equal(decimal.RequireFromString("1.75")) ||
+		!book.Spread.Decimal.Equal(decimal.RequireFromString("0.25")) {
+		t.Errorf("best bid %v, best ask %v, spread %v", book.BestBid, book.BestAsk, book.Spread)
+	}
+	if !book.AMMPrice.Valid || !book.AMMPrice.Decimal.Equal(decimal.RequireFromString("1.5")) {
+		t.Errorf("AMM price = %v, want 1.5", book.AMMPrice)
+	}
+	// Nine tenths through its window the slot has decayed well below floor
+	if !book.SpotPrice.IsPositive() || !book.SpotPrice.LessThan(decimal.RequireFromString("0.1")) {
+		t.Errorf("spot price = %s", book.SpotPrice)
+	}
+
+	// Without a depth every level is returned
+	if book, _ := a.GetOrderBook(slot.SlotID, 0); len(book.Bids) != 3 {
+		t.Errorf("full depth bids = %+v", book.Bids)
+	}
+	if _, err := a.GetOrderBook(99, 5); err == nil {
+		t.Error("order book for an unknown slot")
+	}
+}
+
+func TestGetOrderBook_OneSided(t *testing.T) {
+	state := &VMState{}
+	a := NewAdSlotManager(state, dex.NewEngine())
+	state.SetAdSlot(&AdSlot{ID: 1, EndTime: time.Now().Add(time.Hour), Active: true})
+	state.SetAdSlotOrder(&AdSlotOrder{OrderID: "b", SlotID: 1, IsBuy: true, LimitPrice: decimal.NewFromInt(3), Quantity: 5, Status: "active"})
+
+	book, err := a.GetOrderBook(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !book.BestBid.Valid || book.BestAsk.Valid || book.Spread.Valid || book.AMMPrice.Valid {
+		t.Errorf("one-sided book = %+v", book)
+	}
+}
@@ -0,0 +1,153 @@
+package chainvm
+
+import (
+	"time"
+
+	"github.com/luxfi/adx/pkg/entropy"
+	"github.com/luxfi/adx/pkg/rpcerr"
+	metrics "github.com/luxfi/metric"
+	"github.com/shopspring/decimal"
+)
+
+// ErrPacingThrottled is returned by ReserveBudget when a reservation would
+// put an evenly paced campaign ahead of its spend curve. Unlike
+// ErrBudgetExhausted it is transient.
+var ErrPacingThrottled = rpcerr.NewRetryable(rpcerr.Exhausted, "pacing_throttled", "campaign ahead of pacing schedule")
+
+// PacingMode controls how fast a campaign may commit its budget
+type PacingMode string
+
+const (
+	// PacingASAP spends as fast as demand arrives
+	PacingASAP PacingMode = "asap"
+	// PacingEven spreads spend linearly across the flight
+	PacingEven PacingMode = "even"
+)
+
+// DefaultPacingBucket is the granularity of the even-pacing schedule
+const DefaultPacingBucket = time.Hour
+
+// Pacer throttles reservations for evenly paced campaigns. The allowed
+// spend at any time is the budget pro-rated to the end of the current
+// bucket; reservations that overshoot it pass with a probability that
+// falls to zero at one bucket's worth of overshoot, so spend never runs
+// more than a bucket ahead of the curve.
+type Pacer struct {
+	Bucket time.Duration
+
+	// Gauge, if set, receives each campaign's committed/target ratio
+	// labelled by campaign ID
+	Gauge metrics.GaugeVec
+
+	rand func() float64
+	now  func() time.Time
+}
+
+// NewPacer creates a pacer with the given bucket size
+func NewPacer(bucket time.Duration) *Pacer {
+	if bucket <= 0 {
+		bucket = DefaultPacingBucket
+	}
+	return &Pacer{Bucket: bucket, rand: entropy.Crypto().Float64, now: time.Now}
+}
+
+// PaceStatus compares a campaign's committed spend with its schedule
+type PaceStatus struct {
+	Committed decimal.Decimal `json:"committed"`
+	Target    decimal.Decimal `json:"target"` // Linear target at the current time
+	Ratio     float64         `json:"ratio"`  // Committed / Target; 1 is on pace
+}
+
+// pacedBudget is the budget the schedule spreads: everything not locked
+// in PG deals, which deliver on their own schedule
+func pacedBudget(c *Campaign) (budget, committed decimal.Decimal) {
+	committed = c.ReservedBudget.Add(c.SpentBudget)
+	return committed.Add(c.AvailableBudget), committed
+}
+
+// flightFraction is the elapsed share of the flight at t, in [0, 1]
+func flightFraction(c *Campaign, t time.Time) decimal.Decimal {
+	flight := c.FlightEnd.Sub(c.FlightStart)
+	switch {
+	case flight <= 0 || !t.Before(c.FlightEnd):
+		return decimal.NewFromInt(1)
+	case !t.After(c.FlightStart):
+		return decimal.Zero
+	}
+	return decimal.NewFromInt(int64(t.Sub(c.FlightStart))).Div(decimal.NewFromInt(int64(flight)))
+}
+
+func paced(c *Campaign) bool {
+	return c.Pacing == PacingEven && c.FlightEnd.After(c.FlightStart)
+}
+
+// Status reports a campaign's pace against its linear schedule
+func (p *Pacer) Status(c *Campaign, now time.Time) PaceStatus {
+	budget, committed := pacedBudget(c)
+	target := budget.Mul(flightFraction(c, now))
+
+	status := PaceStatus{Committed: committed, Target: target}
+	if target.IsPositive() {
+		status.Ratio = committed.Div(target).InexactFloat64()
+	} else if committed.IsPositive() {
+		status.Ratio = 1
+	}
+	return status
+}
+
+// Allow reports whether reserving amount keeps the campaign on pace
+func (p *Pacer) Allow(c *Campaign, amount decimal.Decimal, now time.Time) bool {
+	if !paced(c) {
+		return true
+	}
+	defer p.observe(c, now)
+
+	if now.Before(c.FlightStart) {
+		return false
+	}
+
+	budget, committed := pacedBudget(c)
+	elapsed := now.Sub(c.FlightStart)
+	bucketEnd := c.FlightStart.Add((elapsed/p.Bucket + 1) * p.Bucket)
+	allowed := budget.Mul(flightFraction(c, bucketEnd))
+
+	overshoot := committed.Add(amount).Sub(allowed)
+	if !overshoot.IsPositive() {
+		return true
+	}
+
+	perBucket := budget.Mul(decimal.NewFromInt(int64(p.Bucket))).Div(decimal.NewFromInt(int64(c.FlightEnd.Sub(c.FlightStart))))
+	if !perBucket.IsPositive() {
+		return false
+	}
+	pass := 1 - overshoot.Div(perBucket).InexactFloat64()
+	return pass > 0 && p.rand() < pass
+}
+
+func (p *Pacer) observe(c *Campaign, now time.Time) {
+	if p.Gauge == nil {
+		return
+	}
+	p.Gauge.WithLabelValues(c.ID).Set(p.Status(c, now).Ratio)
+}
+
+// SetPacer enables pacing for campaigns funded with PacingEven
+func (e *EscrowManager) SetPacer(p *Pacer) {
+	e.pacer = p
+}
+
+// CampaignPace reports a campaign's pace against its schedule
+func (e *EscrowManager) CampaignPace(campaignID string) (PaceStatus, error) {
+	e.state.tx.RLock()
+	defer e.state.tx.RUnlock()
+
+	c, ok := e.state.GetCampaign(campaignID)
+	if !ok {
+		return PaceStatus{}, ErrCampaignNotFound
+	}
+	p := e.pacer
+	if p == nil {
+		p = NewPacer(0)
+	}
+	return p.Status(c, p.now()), nil
+}
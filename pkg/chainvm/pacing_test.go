@@ -0,0 +1,114 @@
+package chainvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/entropy"
+	"github.com/shopspring/decimal"
+)
+
+// simulatePacing funds a 10-hour, 1000 AUSD campaign and replays bursty
+// hourly demand against it, returning committed spend after each hour
+func simulatePacing(t *testing.T, mode PacingMode, demand []int) []decimal.Decimal {
+	t.Helper()
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(1000))
+	e := NewEscrowManager(&VMState{}, engine, "AUSD")
+	p := NewPacer(time.Hour)
+	p.rand = entropy.Seeded(1).Float64
+	p.now = func() time.Time { return clock }
+	e.SetPacer(p)
+
+	if _, err := e.FundCampaign(context.Background(), &FundCampaignRequest{
+		CampaignID:  "c1",
+		Advertiser:  "adv-1",
+		Amount:      decimal.NewFromInt(1000),
+		FlightStart: start,
+		FlightEnd:   start.Add(10 * time.Hour),
+		Pacing:      mode,
+	}); err != nil {
+		t.Fatalf("FundCampaign: %v", err)
+	}
+
+	committed := make([]decimal.Decimal, len(demand))
+	for hour, n := range demand {
+		clock = start.Add(time.Duration(hour)*time.Hour + 10*time.Minute)
+		for i := 0; i < n; i++ {
+			_, err := e.ReserveBudget(context.Background(), &ReserveBudgetRequest{
+				ReservationID: fmt.Sprintf("h%d-%d", hour, i),
+				CampaignID:    "c1",
+				Amount:        decimal.NewFromInt(5),
+				TTLSeconds:    10,
+			})
+			if err != nil && !errors.Is(err, ErrPacingThrottled) && !errors.Is(err, ErrBudgetExhausted) {
+				t.Fatalf("ReserveBudget: %v", err)
+			}
+		}
+		status, _ := e.CampaignPace("c1")
+		committed[hour] = status.Committed
+	}
+	return committed
+}
+
+func TestPacing_EvenTracksCurve(t *testing.T) {
+	// 300 AUSD of demand in the first hour, a lull, then steady demand
+	demand := []int{60, 0, 0, 40, 40, 40, 10, 40, 40, 40}
+	perHour := decimal.NewFromInt(100)
+
+	committed := simulatePacing(t, PacingEven, demand)
+	for hour, c := range committed {
+		curve := perHour.Mul(decimal.NewFromInt(int64(hour + 1)))
+		if c.GreaterThan(curve.Add(perHour)) {
+			t.Errorf("hour %d: committed %s, more than a bucket over the %s curve", hour, c, curve)
+		}
+	}
+	if !committed[0].GreaterThanOrEqual(perHour) {
+		t.Errorf("hour 0: committed %s, want at least the %s target", committed[0], perHour)
+	}
+	// Paced spend recovers after the lull rather than being lost
+	if !committed[5].GreaterThanOrEqual(decimal.NewFromInt(500)) {
+		t.Errorf("hour 5: committed %s, want caught up to 500", committed[5])
+	}
+}
+
+func TestPacing_ASAPBurns(t *testing.T) {
+	committed := simulatePacing(t, PacingASAP, []int{60, 0, 0, 40, 40})
+	if !committed[0].Equal(decimal.NewFromInt(300)) {
+		t.Errorf("hour 0: committed %s, want the whole 300 burst", committed[0])
+	}
+	if !committed[4].Equal(decimal.NewFromInt(700)) {
+		t.Errorf("hour 4: committed %s, want 700", committed[4])
+	}
+}
+
+func TestPacer_Status(t *testing.T) {
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := &Campaign{
+		ID:              "c1",
+		AvailableBudget: decimal.NewFromInt(850),
+		SpentBudget:     decimal.NewFromInt(150),
+		FlightStart:     start,
+		FlightEnd:       start.Add(10 * time.Hour),
+		Pacing:          PacingEven,
+	}
+
+	status := NewPacer(time.Hour).Status(c, start.Add(time.Hour))
+	if !status.Target.Equal(decimal.NewFromInt(100)) || status.Ratio != 1.5 {
+		t.Errorf("status = %+v, want target 100 ratio 1.5", status)
+	}
+
+	if err := checkPacing(PacingEven, start, start); !errors.Is(err, ErrInvalidPacing) {
+		t.Errorf("empty flight: %v", err)
+	}
+	if err := checkPacing("fast", start, start.Add(time.Hour)); !errors.Is(err, ErrInvalidPacing) {
+		t.Errorf("unknown mode: %v", err)
+	}
+}
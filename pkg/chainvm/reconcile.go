@@ -0,0 +1,177 @@
+package chainvm
+
+import (
+	"context"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+// ErrCampaignNotFound is returned for operations on an unknown campaign
+var ErrCampaignNotFound = rpcerr.New(rpcerr.NotFound, "campaign_not_found", "campaign not found")
+
+// CampaignLedger is a campaign's budget split. Every funded AUSD is in
+// exactly one bucket, so Total = Available + Reserved + Spent + Escrowed.
+type CampaignLedger struct {
+	Total     decimal.Decimal `json:"total"`
+	Available decimal.Decimal `json:"available"`
+	Reserved  decimal.Decimal `json:"reserved"`
+	Spent     decimal.Decimal `json:"spent"`
+	Escrowed  decimal.Decimal `json:"escrowed"` // Locked for PG deals
+}
+
+// Sub returns the per-bucket difference l - o
+func (l CampaignLedger) Sub(o CampaignLedger) CampaignLedger {
+	return CampaignLedger{
+		Total:     l.Total.Sub(o.Total),
+		Available: l.Available.Sub(o.Available),
+		Reserved:  l.Reserved.Sub(o.Reserved),
+		Spent:     l.Spent.Sub(o.Spent),
+		Escrowed:  l.Escrowed.Sub(o.Escrowed),
+	}
+}
+
+// IsZero reports whether every bucket is zero
+func (l CampaignLedger) IsZero() bool {
+	return l.Total.IsZero() && l.Available.IsZero() && l.Reserved.IsZero() &&
+		l.Spent.IsZero() && l.Escrowed.IsZero()
+}
+
+// Reconciliation compares a campaign's recorded budgets with the ones
+// implied by its reservations and PG deals
+type Reconciliation struct {
+	CampaignID string         `json:"campaign_id"`
+	Recorded   CampaignLedger `json:"recorded"`
+	Expected   CampaignLedger `json:"expected"`
+	Delta      CampaignLedger `json:"delta"` // Recorded - Expected
+	Repaired   bool           `json:"repaired,omitempty"`
+}
+
+// Balanced reports whether the recorded budgets match the ledger
+func (r *Reconciliation) Balanced() bool {
+	return r.Delta.IsZero()
+}
+
+// ReconcileCampaign recomputes a campaign's budget buckets from its
+// reservations and PG deals and reports any drift. TotalBudget is taken as
+// the funded amount; settled reservations count as spent less any
+// clawback, unsettled ones as reserved, and deal escrow as escrowed.
+func (e *EscrowManager) ReconcileCampaign(campaignID string) (*Reconciliation, error) {
+	e.state.tx.RLock()
+	defer e.state.tx.RUnlock()
+	return e.reconcile(campaignID)
+}
+
+func (e *EscrowManager) reconcile(campaignID string) (*Reconciliation, error) {
+	campaign, ok := e.state.GetCampaign(campaignID)
+	if !ok {
+		return nil, ErrCampaignNotFound
+	}
+
+	expected := CampaignLedger{Total: campaign.TotalBudget}
+	for _, r := range e.state.CampaignReservations(campaignID) {
+		if r.Settled {
+			expected.Spent = expected.Spent.Add(r.Amount.Sub(r.Refunded))
+		} else {
+			expected.Reserved = expected.Reserved.Add(r.Amount)
+		}
+	}
+	for _, deal := range campaign.GuaranteedDeals {
+		expected.Escrowed = expected.Escrowed.Add(deal.EscrowAmount)
+	}
+	expected.Available = expected.Total.Sub(expected.Reserved).Sub(expected.Spent).Sub(expected.Escrowed)
+
+	// Deal escrow isn't tracked on the campaign, so the recorded figure is
+	// whatever the other buckets leave unaccounted for
+	recorded := CampaignLedger{
+		Total:     campaign.TotalBudget,
+		Available: campaign.AvailableBudget,
+		Reserved:  campaign.ReservedBudget,
+		Spent:     campaign.SpentBudget,
+	}
+	recorded.Escrowed = recorded.Total.Sub(recorded.Available).Sub(recorded.Reserved).Sub(recorded.Spent)
+
+	return &Reconciliation{
+		CampaignID: campaignID,
+		Recorded:   recorded,
+		Expected:   expected,
+		Delta:      recorded.Sub(expected),
+	}, nil
+}
+
+// RepairCampaign reconciles a campaign and, if it has drifted, resets its
+// budgets to the ledger values. The returned report shows the pre-repair
+// state.
+func (e *EscrowManager) RepairCampaign(campaignID string) (*Reconciliation, error) {
+	e.state.tx.Lock()
+	defer e.state.tx.Unlock()
+
+	rec, err := e.reconcile(campaignID)
+	if err != nil || rec.Balanced() {
+		return rec, err
+	}
+
+	campaign, _ := e.state.GetCampaign(campaignID)
+	campaign.AvailableBudget = rec.Expected.Available
+	campaign.ReservedBudget = rec.Expected.Reserved
+	campaign.SpentBudget = rec.Expected.Spent
+	e.state.SetCampaign(campaignID, campaign)
+
+	rec.Repaired = true
+	return rec, nil
+}
+
+// Audit reconciles every campaign and returns those that have drifted
+func (e *EscrowManager) Audit() []*Reconciliation {
+	e.state.tx.RLock()
+	defer e.state.tx.RUnlock()
+
+	var drifted []*Reconciliation
+	for _, id := range e.state.CampaignIDs() {
+		if rec, err := e.reconcile(id); err == nil && !rec.Balanced() {
+			drifted = append(drifted, rec)
+		}
+	}
+	return drifted
+}
+
+// Auditor periodically audits escrow accounting
+type Auditor struct {
+	Escrow   *EscrowManager
+	Interval time.Duration
+
+	// OnDrift is called for each campaign violating the invariant
+	OnDrift func(*Reconciliation)
+
+	// Repair resets drifted campaigns to their ledger values
+	Repair bool
+}
+
+// Run audits every Interval until ctx is cancelled
+func (a *Auditor) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.auditOnce()
+		}
+	}
+}
+
+func (a *Auditor) auditOnce() {
+	for _, rec := range a.Escrow.Audit() {
+		if a.Repair {
+			if repaired, err := a.Escrow.RepairCampaign(rec.CampaignID); err == nil {
+				rec = repaired
+			}
+		}
+		if a.OnDrift != nil {
+			a.OnDrift(rec)
+		}
+	}
+}
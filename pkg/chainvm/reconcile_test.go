@@ -0,0 +1,166 @@
+package chainvm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+func newFundedEscrow(t *testing.T, amount int64) *EscrowManager {
+	t.Helper()
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(amount))
+	e := NewEscrowManager(&VMState{}, engine, "AUSD")
+	if _, err := e.FundCampaign(context.Background(), &FundCampaignRequest{
+		CampaignID: "c1",
+		Advertiser: "adv-1",
+		Amount:     decimal.NewFromInt(amount),
+	}); err != nil {
+		t.Fatalf("FundCampaign: %v", err)
+	}
+	return e
+}
+
+func reserve(t *testing.T, e *EscrowManager, id, amount string) {
+	t.Helper()
+	if _, err := e.ReserveBudget(context.Background(), &ReserveBudgetRequest{
+		ReservationID: id,
+		CampaignID:    "c1",
+		Publisher:     "pub-1",
+		Amount:        decimal.RequireFromString(amount),
+		TTLSeconds:    10,
+	}); err != nil {
+		t.Fatalf("ReserveBudget(%s): %v", id, err)
+	}
+}
+
+func TestReconcileCampaign_Balanced(t *testing.T) {
+	ctx := context.Background()
+	e := newFundedEscrow(t, 100)
+
+	reserve(t, e, "r1", "1.5")
+	reserve(t, e, "r2", "2.25")
+	if _, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: "r1", VerificationProof: strings.Repeat("p", 32)}); err != nil {
+		t.Fatalf("SettleReceipt: %v", err)
+	}
+	if _, err := e.CreatePGDeal(ctx, &CreatePGDealRequest{
+		CampaignID:       "c1",
+		DealID:           "d1",
+		TotalImpressions: 1000,
+		FixedCPM:         decimal.NewFromInt(10),
+		PenaltyRate:      decimal.RequireFromString("0.1"),
+	}); err != nil {
+		t.Fatalf("CreatePGDeal: %v", err)
+	}
+
+	rec, err := e.ReconcileCampaign("c1")
+	if err != nil {
+		t.Fatalf("ReconcileCampaign: %v", err)
+	}
+	if !rec.Balanced() {
+		t.Fatalf("delta = %+v, want balanced", rec.Delta)
+	}
+	if !rec.Expected.Spent.Equal(decimal.RequireFromString("1.5")) ||
+		!rec.Expected.Reserved.Equal(decimal.RequireFromString("2.25")) ||
+		!rec.Expected.Escrowed.Equal(decimal.NewFromInt(11)) {
+		t.Errorf("expected = %+v", rec.Expected)
+	}
+
+	if _, err := e.ReconcileCampaign("missing"); err != ErrCampaignNotFound {
+		t.Errorf("err = %v, want %v", err, ErrCampaignNotFound)
+	}
+}
+
+func TestReconcileCampaign_DetectsDrift(t *testing.T) {
+	e := newFundedEscrow(t, 100)
+	reserve(t, e, "r1", "4")
+
+	// Settlement half-applied: the reservation is marked settled but the
+	// budgets were never moved
+	r, _ := e.state.GetReservation("r1")
+	r.Settled = true
+	// And a stray debit from available
+	c, _ := e.state.GetCampaign("c1")
+	c.AvailableBudget = c.AvailableBudget.Sub(decimal.RequireFromString("0.75"))
+
+	rec, err := e.ReconcileCampaign("c1")
+	if err != nil {
+		t.Fatalf("ReconcileCampaign: %v", err)
+	}
+	if rec.Balanced() {
+		t.Fatal("drift not detected")
+	}
+	want := map[string][2]decimal.Decimal{
+		"reserved":  {rec.Delta.Reserved, decimal.NewFromInt(4)},
+		"spent":     {rec.Delta.Spent, decimal.NewFromInt(-4)},
+		"available": {rec.Delta.Available, decimal.RequireFromString("-0.75")},
+		"escrowed":  {rec.Delta.Escrowed, decimal.RequireFromString("0.75")},
+		"total":     {rec.Delta.Total, decimal.Zero},
+	}
+	for bucket, v := range want {
+		if !v[0].Equal(v[1]) {
+			t.Errorf("%s delta = %s, want %s", bucket, v[0], v[1])
+		}
+	}
+
+	drifted := e.Audit()
+	if len(drifted) != 1 || drifted[0].CampaignID != "c1" {
+		t.Fatalf("Audit = %+v", drifted)
+	}
+
+	repaired, err := e.RepairCampaign("c1")
+	if err != nil || !repaired.Repaired {
+		t.Fatalf("RepairCampaign = %+v, %v", repaired, err)
+	}
+	if after, _ := e.ReconcileCampaign("c1"); !after.Balanced() {
+		t.Errorf("still drifted after repair: %+v", after.Delta)
+	}
+	if !c.AvailableBudget.Equal(decimal.NewFromInt(96)) || !c.SpentBudget.Equal(decimal.NewFromInt(4)) {
+		t.Errorf("repaired campaign = %+v", c)
+	}
+}
+
+func TestAuditor_Run(t *testing.T) {
+	e := newFundedEscrow(t, 100)
+	c, _ := e.state.GetCampaign("c1")
+	c.ReservedBudget = decimal.NewFromInt(1)
+
+	found := make(chan *Reconciliation, 1)
+	a := &Auditor{
+		Escrow:   e,
+		Interval: time.Millisecond,
+		OnDrift: func(rec *Reconciliation) {
+			select {
+			case found <- rec:
+			default:
+			}
+		},
+		Repair: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case rec := <-found:
+		if !rec.Repaired || !rec.Delta.Reserved.Equal(decimal.NewFromInt(1)) {
+			t.Errorf("rec = %+v", rec)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("auditor did not report drift")
+	}
+	cancel()
+	<-done
+
+	if !c.ReservedBudget.IsZero() {
+		t.Errorf("ReservedBudget = %s, want 0 after repair", c.ReservedBudget)
+	}
+}
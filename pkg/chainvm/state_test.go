@@ -0,0 +1,96 @@
+package chainvm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/vast"
+	"github.com/shopspring/decimal"
+)
+
+func TestEscrow_ConcurrentFundReserveSettle(t *testing.T) {
+	const workers, rounds = 16, 20
+	ctx := context.Background()
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(workers*rounds*10))
+	e := NewEscrowManager(&VMState{}, engine, "AUSD")
+	e.SetLedger(NewLedger())
+	proof := strings.Repeat("p", 32)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				id := fmt.Sprintf("r%d-%d", w, i)
+				if _, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(10), HoldbackBps: 1000}); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := e.ReserveBudget(ctx, &ReserveBudgetRequest{ReservationID: id, CampaignID: "c1", Publisher: fmt.Sprintf("pub-%d", w%4), Amount: decimal.NewFromInt(1), TTLSeconds: 10}); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := e.SettleReceipt(ctx, &SettleReceiptRequest{ReservationID: id, VerificationProof: proof}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	// Reports read the records as they're written
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			e.Audit()
+			e.EligibleCampaigns(&vast.OpenRTBRequest{})
+			e.Reservation("r1-1")
+		}
+	}()
+	wg.Wait()
+	close(done)
+	readers.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	settled := workers * rounds
+	campaign, _ := e.state.GetCampaign("c1")
+	if want := decimal.NewFromInt(workers * rounds * 10); !campaign.TotalBudget.Equal(want) {
+		t.Errorf("total budget = %s, want %s", campaign.TotalBudget, want)
+	}
+	if want := decimal.NewFromInt(int64(settled)); !campaign.SpentBudget.Equal(want) || !campaign.ReservedBudget.IsZero() {
+		t.Errorf("spent %s, reserved %s; want %s and 0", campaign.SpentBudget, campaign.ReservedBudget, want)
+	}
+	if rec, err := e.ReconcileCampaign("c1"); err != nil || !rec.Balanced() {
+		t.Errorf("reconciliation = %+v, %v", rec, err)
+	}
+	paid := decimal.Zero
+	for p := 0; p < 4; p++ {
+		paid = paid.Add(e.state.GetPublisherBalance(fmt.Sprintf("pub-%d", p)))
+	}
+	if want := decimal.NewFromFloat(0.9).Mul(decimal.NewFromInt(int64(settled))); !paid.Equal(want) {
+		t.Errorf("publishers paid %s, want %s", paid, want)
+	}
+	if len(e.state.pendingReleases) != settled {
+		t.Errorf("%d holdbacks pending, want %d", len(e.state.pendingReleases), settled)
+	}
+	if total := e.Ledger().Total(); !total.IsZero() {
+		t.Errorf("ledger totals %s", total)
+	}
+}
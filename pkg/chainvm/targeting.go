@@ -0,0 +1,280 @@
+package chainvm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luxfi/adx/pkg/vast"
+)
+
+// SegmentsField is the TargetingPredicate custom field listing audience
+// segments; a request matches when the user belongs to any of them. Each
+// entry is a segment ID, or "dataID/segmentID" to pin the data provider.
+const SegmentsField = "segments"
+
+// TargetingMatcher reports whether a request satisfies compiled targeting,
+// and if not, why
+type TargetingMatcher func(req *vast.OpenRTBRequest) (bool, string)
+
+// TargetingEvaluator compiles campaign targeting into matchers and picks
+// the campaigns eligible for a request
+type TargetingEvaluator struct {
+	now func() time.Time
+}
+
+// NewTargetingEvaluator creates a targeting evaluator
+func NewTargetingEvaluator() *TargetingEvaluator {
+	return &TargetingEvaluator{now: time.Now}
+}
+
+type targetingRule func(req *vast.OpenRTBRequest) string
+
+// Compile turns t into a matcher. Empty criteria match every request.
+//
+// Geo targets are country codes as the request carries them, or
+// "country-region"; a leading "!" excludes instead. Device types are
+// "mobile", "phone", "tablet", "desktop", "ctv" and "stb". Categories
+// match site or app categories, a tier-1 category covering its
+// subcategories. Age bounds are inclusive and need the user's birth year.
+func (e *TargetingEvaluator) Compile(t TargetingPredicate) (TargetingMatcher, error) {
+	var rules []targetingRule
+	if len(t.GeoTargets) > 0 {
+		rules = append(rules, geoRule(t.GeoTargets))
+	}
+	if len(t.DeviceTypes) > 0 {
+		rule, err := deviceRule(t.DeviceTypes)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if len(t.Categories) > 0 {
+		rules = append(rules, categoryRule(t.Categories))
+	}
+	if t.MinAge > 0 || t.MaxAge > 0 {
+		if t.MaxAge > 0 && t.MinAge > t.MaxAge {
+			return nil, fmt.Errorf("min age %d above max age %d", t.MinAge, t.MaxAge)
+		}
+		rules = append(rules, e.ageRule(int(t.MinAge), int(t.MaxAge)))
+	}
+	if raw, ok := t.CustomFields[SegmentsField]; ok {
+		segments, err := stringList(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", SegmentsField, err)
+		}
+		if len(segments) > 0 {
+			rules = append(rules, segmentRule(segments))
+		}
+	}
+
+	return func(req *vast.OpenRTBRequest) (bool, string) {
+		for _, rule := range rules {
+			if reason := rule(req); reason != "" {
+				return false, reason
+			}
+		}
+		return true, ""
+	}, nil
+}
+
+// Evaluate compiles t and matches req against it
+func (e *TargetingEvaluator) Evaluate(t TargetingPredicate, req *vast.OpenRTBRequest) (bool, string) {
+	match, err := e.Compile(t)
+	if err != nil {
+		return false, err.Error()
+	}
+	return match(req)
+}
+
+// Eligible filters campaigns to those whose targeting matches req,
+// returning why each of the others was excluded by campaign ID
+func (e *TargetingEvaluator) Eligible(campaigns []*Campaign, req *vast.OpenRTBRequest) ([]*Campaign, map[string]string) {
+	var eligible []*Campaign
+	excluded := make(map[string]string)
+	for _, c := range campaigns {
+		if ok, reason := e.Evaluate(c.Targeting, req); ok {
+			eligible = append(eligible, c)
+		} else {
+			excluded[c.ID] = reason
+		}
+	}
+	return eligible, excluded
+}
+
+func geoRule(targets []string) targetingRule {
+	var include, exclude []string
+	for _, t := range targets {
+		if rest, ok := strings.CutPrefix(t, "!"); ok {
+			exclude = append(exclude, rest)
+		} else {
+			include = append(include, t)
+		}
+	}
+	return func(req *vast.OpenRTBRequest) string {
+		geo := requestGeo(req)
+		country, region := strings.ToUpper(geo.Country), strings.ToUpper(geo.Region)
+		matches := func(target string) bool {
+			target = strings.ToUpper(target)
+			return country != "" && (target == country || (region != "" && target == country+"-"+region))
+		}
+		for _, t := range exclude {
+			if matches(t) {
+				return fmt.Sprintf("geo %s excluded", t)
+			}
+		}
+		if len(include) == 0 {
+			return ""
+		}
+		for _, t := range include {
+			if matches(t) {
+				return ""
+			}
+		}
+		if country == "" {
+			return "geo unknown"
+		}
+		return fmt.Sprintf("geo %s not targeted", country)
+	}
+}
+
+// requestGeo prefers the device's location over the user's home location
+func requestGeo(req *vast.OpenRTBRequest) vast.Geo {
+	if req.Device.Geo.Country == "" && req.User.Geo != nil {
+		return *req.User.Geo
+	}
+	return req.Device.Geo
+}
+
+var deviceTypeNames = map[string][]int{
+	"mobile":  {vast.DeviceTypeMobileTablet, vast.DeviceTypePhone, vast.DeviceTypeTablet},
+	"phone":   {vast.DeviceTypePhone},
+	"tablet":  {vast.DeviceTypeTablet},
+	"desktop": {vast.DeviceTypePC},
+	"ctv":     {vast.DeviceTypeCTV, vast.DeviceTypeConnectedDevice, vast.DeviceTypeSetTopBox},
+	"stb":     {vast.DeviceTypeSetTopBox},
+}
+
+func deviceRule(names []string) (targetingRule, error) {
+	allowed := make(map[int]bool)
+	for _, name := range names {
+		types, ok := deviceTypeNames[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown device type %q", name)
+		}
+		for _, t := range types {
+			allowed[t] = true
+		}
+	}
+	return func(req *vast.OpenRTBRequest) string {
+		if !allowed[req.Device.DeviceType] {
+			return fmt.Sprintf("device type %d not targeted", req.Device.DeviceType)
+		}
+		return ""
+	}, nil
+}
+
+func categoryRule(categories []string) targetingRule {
+	return func(req *vast.OpenRTBRequest) string {
+		var cats []string
+		if req.Site != nil {
+			cats = append(append(append(cats, req.Site.Cat...), req.Site.SectionCat...), req.Site.PageCat...)
+		}
+		if req.App != nil {
+			cats = append(append(append(cats, req.App.Cat...), req.App.SectionCat...), req.App.PageCat...)
+		}
+		for _, cat := range cats {
+			parent, _, _ := strings.Cut(cat, "-")
+			for _, want := range categories {
+				if strings.EqualFold(want, cat) || strings.EqualFold(want, parent) {
+					return ""
+				}
+			}
+		}
+		return "content category not targeted"
+	}
+}
+
+func (e *TargetingEvaluator) ageRule(min, max int) targetingRule {
+	return func(req *vast.OpenRTBRequest) string {
+		if req.User.YOB <= 0 {
+			return "age unknown"
+		}
+		// The birthday may be later this year, so the age is one of two
+		// values; only reject when both are out of range
+		oldest := e.now().Year() - req.User.YOB
+		youngest := oldest - 1
+		if min > 0 && oldest < min {
+			return fmt.Sprintf("age %d below %d", oldest, min)
+		}
+		if max > 0 && youngest > max {
+			return fmt.Sprintf("age %d above %d", youngest, max)
+		}
+		return ""
+	}
+}
+
+func segmentRule(segments []string) targetingRule {
+	return func(req *vast.OpenRTBRequest) string {
+		for _, data := range req.User.Data {
+			for _, seg := range data.Segment {
+				for _, want := range segments {
+					if dataID, segID, ok := strings.Cut(want, "/"); ok {
+						if dataID == data.ID && segID == seg.ID {
+							return ""
+						}
+					} else if want == seg.ID {
+						return ""
+					}
+				}
+			}
+		}
+		return "user in no targeted segment"
+	}
+}
+
+// stringList reads a custom field holding strings, as decoded from JSON
+// or set directly
+func stringList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("want strings, got %T", item)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	case string:
+		return []string{v}, nil
+	}
+	return nil, fmt.Errorf("want a list of strings, got %T", v)
+}
+
+// EligibleCampaigns lists the active, funded, in-flight campaigns whose
+// targeting matches req, so only they are offered to the auction. The
+// campaigns are copies, safe to read as the escrow goes on updating them.
+func (e *EscrowManager) EligibleCampaigns(req *vast.OpenRTBRequest) []*Campaign {
+	e.state.tx.RLock()
+	defer e.state.tx.RUnlock()
+
+	now := e.targeting.now()
+	var live []*Campaign
+	for _, id := range e.state.CampaignIDs() {
+		c, ok := e.state.GetCampaign(id)
+		if !ok || !c.Active || c.AutoPaused || !c.AvailableBudget.IsPositive() {
+			continue
+		}
+		if (!c.FlightStart.IsZero() && now.Before(c.FlightStart)) || (!c.FlightEnd.IsZero() && !now.Before(c.FlightEnd)) {
+			continue
+		}
+		cp := *c
+		live = append(live, &cp)
+	}
+	eligible, _ := e.targeting.Eligible(live, req)
+	return eligible
+}
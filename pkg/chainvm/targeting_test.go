@@ -0,0 +1,187 @@
+package chainvm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/vast"
+	"github.com/shopspring/decimal"
+)
+
+func fixedEvaluator(now time.Time) *TargetingEvaluator {
+	e := NewTargetingEvaluator()
+	e.now = func() time.Time { return now }
+	return e
+}
+
+func geoRequest(country, region string) *vast.OpenRTBRequest {
+	return &vast.OpenRTBRequest{Device: vast.Device{Geo: vast.Geo{Country: country, Region: region}}}
+}
+
+func TestTargeting_Geo(t *testing.T) {
+	e := NewTargetingEvaluator()
+	match, err := e.Compile(TargetingPredicate{GeoTargets: []string{"USA", "CAN", "!USA-CA"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		country, region string
+		want            bool
+	}{
+		{"included country", "USA", "NY", true},
+		{"other included country", "can", "", true},
+		{"excluded region", "USA", "CA", false},
+		{"not targeted", "GBR", "", false},
+		{"unknown", "", "", false},
+	}
+	for _, tt := range tests {
+		ok, reason := match(geoRequest(tt.country, tt.region))
+		if ok != tt.want {
+			t.Errorf("%s: match = %v (%s), want %v", tt.name, ok, reason, tt.want)
+		}
+		if !ok && reason == "" {
+			t.Errorf("%s: no reason given", tt.name)
+		}
+	}
+
+	// Exclusion alone allows everywhere else, and the user's geo is used
+	// when the device has none
+	match, _ = e.Compile(TargetingPredicate{GeoTargets: []string{"!GBR"}})
+	req := &vast.OpenRTBRequest{User: vast.User{Geo: &vast.Geo{Country: "GBR"}}}
+	if ok, _ := match(req); ok {
+		t.Error("excluded user geo matched")
+	}
+	if ok, _ := match(geoRequest("FRA", "")); !ok {
+		t.Error("exclusion-only targeting rejected another country")
+	}
+}
+
+func TestTargeting_AgeRange(t *testing.T) {
+	e := fixedEvaluator(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	match, err := e.Compile(TargetingPredicate{MinAge: 18, MaxAge: 34})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		yob  int
+		want bool
+	}{
+		{2008, false}, // 16 or 17
+		{2007, true},  // 17 or 18: may already be 18
+		{2000, true},
+		{1991, true},  // 33 or 34
+		{1990, true},  // 34 or 35: may still be 34
+		{1989, false}, // 35 or 36
+		{0, false},    // unknown
+	} {
+		req := &vast.OpenRTBRequest{User: vast.User{YOB: tt.yob}}
+		if ok, reason := match(req); ok != tt.want {
+			t.Errorf("yob %d: match = %v (%s), want %v", tt.yob, ok, reason, tt.want)
+		}
+	}
+
+	if _, err := e.Compile(TargetingPredicate{MinAge: 40, MaxAge: 30}); err == nil {
+		t.Error("inverted age range compiled")
+	}
+}
+
+func TestTargeting_Segments(t *testing.T) {
+	e := NewTargetingEvaluator()
+	// As decoded from JSON
+	match, err := e.Compile(TargetingPredicate{CustomFields: map[string]interface{}{
+		SegmentsField: []interface{}{"auto-intenders", "dmp-1/sports"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := func(data ...vast.Data) *vast.OpenRTBRequest {
+		return &vast.OpenRTBRequest{User: vast.User{Data: data}}
+	}
+	tests := []struct {
+		name string
+		req  *vast.OpenRTBRequest
+		want bool
+	}{
+		{"segment ID", user(vast.Data{ID: "any", Segment: []vast.Segment{{ID: "auto-intenders"}}}), true},
+		{"provider and segment", user(vast.Data{ID: "dmp-1", Segment: []vast.Segment{{ID: "news"}, {ID: "sports"}}}), true},
+		{"segment from another provider", user(vast.Data{ID: "dmp-2", Segment: []vast.Segment{{ID: "sports"}}}), false},
+		{"other segments", user(vast.Data{ID: "dmp-1", Segment: []vast.Segment{{ID: "news"}}}), false},
+		{"no data", user(), false},
+	}
+	for _, tt := range tests {
+		if ok, reason := match(tt.req); ok != tt.want {
+			t.Errorf("%s: match = %v (%s), want %v", tt.name, ok, reason, tt.want)
+		}
+	}
+
+	if _, err := e.Compile(TargetingPredicate{CustomFields: map[string]interface{}{SegmentsField: 7}}); err == nil {
+		t.Error("non-string segments compiled")
+	}
+}
+
+func TestTargeting_DeviceAndCategory(t *testing.T) {
+	e := NewTargetingEvaluator()
+	match, err := e.Compile(TargetingPredicate{DeviceTypes: []string{"CTV"}, Categories: []string{"IAB17"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &vast.OpenRTBRequest{
+		Device: vast.Device{DeviceType: vast.DeviceTypeCTV},
+		App:    &vast.App{Cat: []string{"IAB17-12"}},
+	}
+	if ok, reason := match(req); !ok {
+		t.Errorf("CTV sports app rejected: %s", reason)
+	}
+	req.Device.DeviceType = vast.DeviceTypePhone
+	if ok, _ := match(req); ok {
+		t.Error("phone matched CTV targeting")
+	}
+
+	if _, err := e.Compile(TargetingPredicate{DeviceTypes: []string{"fridge"}}); err == nil {
+		t.Error("unknown device type compiled")
+	}
+}
+
+func TestEligibleCampaigns(t *testing.T) {
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(300))
+	e := NewEscrowManager(&VMState{}, engine, "AUSD")
+
+	fund := func(id string, targeting TargetingPredicate) {
+		t.Helper()
+		if _, err := e.FundCampaign(context.Background(), &FundCampaignRequest{
+			CampaignID: id,
+			Advertiser: "adv-1",
+			Amount:     decimal.NewFromInt(100),
+			Targeting:  targeting,
+		}); err != nil {
+			t.Fatalf("FundCampaign %s: %v", id, err)
+		}
+	}
+	fund("us", TargetingPredicate{GeoTargets: []string{"USA"}})
+	fund("uk", TargetingPredicate{GeoTargets: []string{"GBR"}})
+	fund("run-of-network", TargetingPredicate{})
+
+	var ids []string
+	for _, c := range e.EligibleCampaigns(geoRequest("USA", "")) {
+		ids = append(ids, c.ID)
+	}
+	if len(ids) != 2 || ids[0] != "run-of-network" || ids[1] != "us" {
+		t.Errorf("eligible = %v, want [run-of-network us]", ids)
+	}
+
+	if _, err := e.FundCampaign(context.Background(), &FundCampaignRequest{
+		CampaignID: "bad",
+		Advertiser: "adv-1",
+		Amount:     decimal.NewFromInt(1),
+		Targeting:  TargetingPredicate{DeviceTypes: []string{"fridge"}},
+	}); err == nil {
+		t.Error("campaign funded with invalid targeting")
+	}
+}
@@ -0,0 +1,145 @@
+package chainvm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+// Input bounds for RPC requests. Amounts are AUSD; quantities are
+// impressions or slot tokens. Both stay well inside int64 so conversions
+// to decimal and the dex engine can't overflow.
+const (
+	MaxQuantity      = uint64(1_000_000_000_000)
+	MaxDecimalPlaces = 18
+	MaxTimeDecayRate = 50
+)
+
+// MaxAmount is the largest AUSD amount a single request may carry
+var MaxAmount = decimal.New(1, 15)
+
+var (
+	ErrNegativeAmount     = rpcerr.New(rpcerr.Invalid, "negative_amount", "amount is negative")
+	ErrZeroAmount         = rpcerr.New(rpcerr.Invalid, "zero_amount", "amount must be positive")
+	ErrAmountTooLarge     = rpcerr.New(rpcerr.Invalid, "amount_too_large", "amount exceeds maximum")
+	ErrTooPrecise         = rpcerr.New(rpcerr.Invalid, "too_precise", "amount has too many decimal places")
+	ErrZeroQuantity       = rpcerr.New(rpcerr.Invalid, "zero_quantity", "quantity is zero")
+	ErrQuantityTooLarge   = rpcerr.New(rpcerr.Invalid, "quantity_too_large", "quantity exceeds maximum")
+	ErrFractionalQuantity = rpcerr.New(rpcerr.Invalid, "fractional_quantity", "quantity is not a whole number")
+	ErrRateOutOfRange     = rpcerr.New(rpcerr.Invalid, "rate_out_of_range", "rate out of range")
+	ErrViewabilityRange   = rpcerr.New(rpcerr.Invalid, "viewability_out_of_range", "viewability must be between 0 and 1")
+	ErrInvalidPacing      = rpcerr.New(rpcerr.Invalid, "invalid_pacing", "invalid pacing")
+	ErrInvalidWindow      = rpcerr.New(rpcerr.Invalid, "invalid_window", "invalid time window")
+	ErrTTLTooLong         = rpcerr.New(rpcerr.Invalid, "ttl_too_long", "TTL too long (max 10s)")
+)
+
+// IsValidationError reports whether err came from request input checks
+// rather than VM state
+func IsValidationError(err error) bool {
+	e, ok := rpcerr.As(err)
+	return ok && e.Kind == rpcerr.Invalid
+}
+
+// checkAmount accepts zero or a positive amount within MaxAmount and
+// MaxDecimalPlaces
+func checkAmount(field string, d decimal.Decimal) error {
+	if d.IsNegative() {
+		return fmt.Errorf("%s: %w", field, ErrNegativeAmount)
+	}
+	if err := checkScale(d, MaxAmount); err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	if d.GreaterThan(MaxAmount) {
+		return fmt.Errorf("%s: %w", field, ErrAmountTooLarge)
+	}
+	if !d.Equal(d.Truncate(MaxDecimalPlaces)) {
+		return fmt.Errorf("%s: %w", field, ErrTooPrecise)
+	}
+	return nil
+}
+
+// checkScale rejects exponents far outside the accepted range before any
+// comparison rescales them; "1e-2000000000" would otherwise allocate a
+// two-billion-digit integer
+func checkScale(d, max decimal.Decimal) error {
+	if d.IsZero() {
+		return nil
+	}
+	// d >= 10^(digits+exp-1), so this magnitude already exceeds max
+	if int64(d.NumDigits())+int64(d.Exponent())-1 > int64(max.NumDigits())+int64(max.Exponent()) {
+		return ErrAmountTooLarge
+	}
+	if int64(d.Exponent()) < -2*MaxDecimalPlaces {
+		return ErrTooPrecise
+	}
+	return nil
+}
+
+// checkQuantity accepts a non-zero quantity within MaxQuantity
+func checkQuantity(field string, q uint64) error {
+	if q == 0 {
+		return fmt.Errorf("%s: %w", field, ErrZeroQuantity)
+	}
+	if q > MaxQuantity {
+		return fmt.Errorf("%s: %w", field, ErrQuantityTooLarge)
+	}
+	return nil
+}
+
+// wholeQuantity converts a decimal slot quantity to uint64, rejecting
+// fractions rather than truncating them
+func wholeQuantity(field string, d decimal.Decimal) (uint64, error) {
+	max := decimal.NewFromInt(int64(MaxQuantity))
+	if d.IsNegative() {
+		return 0, fmt.Errorf("%s: %w", field, ErrNegativeAmount)
+	}
+	if err := checkScale(d, max); err != nil {
+		if errors.Is(err, ErrAmountTooLarge) {
+			err = ErrQuantityTooLarge
+		}
+		return 0, fmt.Errorf("%s: %w", field, err)
+	}
+	if !d.Equal(d.Truncate(0)) {
+		return 0, fmt.Errorf("%s: %w", field, ErrFractionalQuantity)
+	}
+	if d.GreaterThan(max) {
+		return 0, fmt.Errorf("%s: %w", field, ErrQuantityTooLarge)
+	}
+	q := uint64(d.IntPart())
+	return q, checkQuantity(field, q)
+}
+
+// checkRate accepts a decimal in [0, max]
+func checkRate(field string, d decimal.Decimal, max int64) error {
+	if d.IsNegative() || d.GreaterThan(decimal.NewFromInt(max)) {
+		return fmt.Errorf("%s: %w", field, ErrRateOutOfRange)
+	}
+	return nil
+}
+
+// checkViewability accepts a finite fraction in [0, 1]
+func checkViewability(v float64) error {
+	if math.IsNaN(v) || v < 0 || v > 1 {
+		return ErrViewabilityRange
+	}
+	return nil
+}
+
+// checkPacing accepts ASAP (or unset) pacing, or even pacing over a
+// non-empty flight
+func checkPacing(mode PacingMode, start, end time.Time) error {
+	switch mode {
+	case "", PacingASAP:
+		return nil
+	case PacingEven:
+		if !end.After(start) {
+			return fmt.Errorf("%w: even pacing needs a flight window", ErrInvalidPacing)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown mode %q", ErrInvalidPacing, mode)
+}
@@ -0,0 +1,177 @@
+package chainvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+)
+
+func TestCheckAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"0", nil},
+		{"12.5", nil},
+		{"1000000000000000", nil},
+		{"0.000000000000000001", nil},
+		{"1.0000000000000000000000", nil},
+		{"-0.01", ErrNegativeAmount},
+		{"1000000000000000.01", ErrAmountTooLarge},
+		{"1e2000000000", ErrAmountTooLarge},
+		{"0.0000000000000000001", ErrTooPrecise},
+		{"1e-2000000000", ErrTooPrecise},
+	}
+	for _, tt := range tests {
+		err := checkAmount("amount", decimal.RequireFromString(tt.in))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("checkAmount(%s) = %v, want %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestWholeQuantity(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+		err  error
+	}{
+		{"5", 5, nil},
+		{"5.000", 5, nil},
+		{"0", 0, ErrZeroQuantity},
+		{"2.5", 0, ErrFractionalQuantity},
+		{"-3", 0, ErrNegativeAmount},
+		{"18446744073709551617", 0, ErrQuantityTooLarge},
+		{"1e400", 0, ErrQuantityTooLarge},
+	}
+	for _, tt := range tests {
+		got, err := wholeQuantity("amount_in", decimal.RequireFromString(tt.in))
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("wholeQuantity(%s) = %d, %v; want %d, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func newTestPool(t *testing.T) (*AdSlotManager, uint64) {
+	t.Helper()
+	a := NewAdSlotManager(&VMState{}, dex.NewEngine())
+	slot, err := a.CreateAdSlot(context.Background(), &CreateAdSlotRequest{
+		Publisher:      "pub-1",
+		StartTime:      time.Now().Add(-time.Minute),
+		EndTime:        time.Now().Add(time.Hour),
+		MaxImpressions: 1000,
+		FloorCPM:       decimal.NewFromInt(10),
+	})
+	if err != nil {
+		t.Fatalf("CreateAdSlot: %v", err)
+	}
+	a.dex.SetBalance("AUSD", "pub-1", decimal.NewFromInt(1000))
+	_, err = a.CreateAdMM_Pool(context.Background(), &CreateAdMM_PoolRequest{
+		SlotID:            slot.SlotID,
+		InitialAUSD:       decimal.NewFromInt(1000),
+		InitialSlots:      1000,
+		TimeDecayRate:     decimal.NewFromFloat(0.0001),
+		LiquidityProvider: "pub-1",
+	})
+	if err != nil {
+		t.Fatalf("CreateAdMM_Pool: %v", err)
+	}
+	return a, slot.SlotID
+}
+
+func TestSwapAdMM_RejectsBadAmounts(t *testing.T) {
+	a, slotID := newTestPool(t)
+	pool, _ := a.state.GetAdMM_Pool(slotID)
+	before := *pool
+
+	tests := []struct {
+		name    string
+		amount  string
+		buyAUSD bool
+		want    error
+	}{
+		{"fractional slots", "2.5", true, ErrFractionalQuantity},
+		{"negative slots", "-10", true, ErrNegativeAmount},
+		{"overflowing slots", "18446744073709551616", true, ErrQuantityTooLarge},
+		{"negative AUSD", "-10", false, ErrNegativeAmount},
+		{"huge AUSD", "1e30", false, ErrAmountTooLarge},
+	}
+	for _, tt := range tests {
+		_, err := a.SwapAdMM(context.Background(), &SwapAdMM_Request{
+			SlotID:   slotID,
+			AmountIn: decimal.RequireFromString(tt.amount),
+			BuyAUSD:  tt.buyAUSD,
+		})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if pool.ReserveSlots != before.ReserveSlots || !pool.ReserveAUSD.Equal(before.ReserveAUSD) {
+		t.Errorf("rejected swaps changed reserves: %d/%s -> %d/%s",
+			before.ReserveSlots, before.ReserveAUSD, pool.ReserveSlots, pool.ReserveAUSD)
+	}
+}
+
+func TestSwapAdMM_FractionalAUSD(t *testing.T) {
+	a, slotID := newTestPool(t)
+	a.dex.SetBalance("AUSD", "dsp-1", decimal.NewFromInt(20))
+
+	// 10.5 AUSD used to be truncated to 10 when pricing the swap
+	resp, err := a.SwapAdMM(WithCaller(context.Background(), "dsp-1"), &SwapAdMM_Request{
+		SlotID:   slotID,
+		AmountIn: decimal.RequireFromString("10.5"),
+	})
+	if err != nil {
+		t.Fatalf("SwapAdMM: %v", err)
+	}
+	if !resp.AmountOut.Equal(resp.AmountOut.Floor()) || !resp.AmountOut.IsPositive() {
+		t.Errorf("AmountOut = %s, want a positive whole number of slots", resp.AmountOut)
+	}
+
+	pool, _ := a.state.GetAdMM_Pool(slotID)
+	if !pool.ReserveAUSD.Equal(decimal.RequireFromString("1010.5")) {
+		t.Errorf("ReserveAUSD = %s, want 1010.5", pool.ReserveAUSD)
+	}
+	if pool.ReserveSlots != 1000-uint64(resp.AmountOut.IntPart()) {
+		t.Errorf("ReserveSlots = %d, want %d", pool.ReserveSlots, 1000-resp.AmountOut.IntPart())
+	}
+}
+
+func TestRPCBounds(t *testing.T) {
+	ctx := context.Background()
+	a, slotID := newTestPool(t)
+	e := NewEscrowManager(a.state, a.dex, "AUSD")
+
+	// Amounts decoded from JSON go through the same checks
+	var order PlaceOrderRequest
+	if err := json.Unmarshal([]byte(`{"slot_id":0,"quantity":1,"limit_price":"-5"}`), &order); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	order.SlotID = slotID
+	if _, err := a.PlaceOrder(ctx, &order); !errors.Is(err, ErrNegativeAmount) {
+		t.Errorf("PlaceOrder negative price: %v", err)
+	}
+
+	if _, err := a.PlaceOrder(ctx, &PlaceOrderRequest{SlotID: slotID, Quantity: math.MaxUint64, LimitPrice: decimal.NewFromInt(20)}); !errors.Is(err, ErrQuantityTooLarge) {
+		t.Errorf("PlaceOrder huge quantity: %v", err)
+	}
+	if _, err := a.CreateAdSlot(ctx, &CreateAdSlotRequest{EndTime: time.Now().Add(time.Hour), MaxImpressions: 1, MinViewability: math.NaN()}); !errors.Is(err, ErrViewabilityRange) {
+		t.Errorf("CreateAdSlot NaN viewability: %v", err)
+	}
+	if _, err := a.RecordDelivery(ctx, &RecordDeliveryRequest{AdSlotID: slotID, SlotID: slotID, Count: math.MaxUint64}); !errors.Is(err, ErrQuantityTooLarge) {
+		t.Errorf("RecordDelivery overflowing count: %v", err)
+	}
+	if _, err := e.FundCampaign(ctx, &FundCampaignRequest{CampaignID: "c1", Amount: decimal.RequireFromString("1e20")}); !errors.Is(err, ErrAmountTooLarge) {
+		t.Errorf("FundCampaign huge amount: %v", err)
+	}
+	if _, err := e.CreatePGDeal(ctx, &CreatePGDealRequest{CampaignID: "c1", TotalImpressions: 10, PenaltyRate: decimal.NewFromInt(-1)}); !errors.Is(err, ErrRateOutOfRange) {
+		t.Errorf("CreatePGDeal negative penalty: %v", err)
+	}
+}
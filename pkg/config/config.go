@@ -0,0 +1,293 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package config resolves each binary's settings from, in increasing
+// precedence, built-in defaults, a shared YAML or JSON config file, ADX_*
+// environment variables and command-line flags, and validates the result
+// at startup.
+//
+// A section is a struct whose fields carry a yaml tag naming them in the
+// file, and optionally an env tag naming their environment variable and a
+// flag tag naming their command-line flag, with the usage in a help tag.
+// The config file holds one top-level key per section, so all binaries can
+// share it.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile names the config file when -config isn't given
+const EnvFile = "ADX_CONFIG"
+
+// Section is a validated group of settings
+type Section interface {
+	Validate() error
+}
+
+// Duration is a time.Duration written as "250ms" or "1m30s" in files, env
+// vars and flags
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// String returns the duration as time.Duration formats it
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// FieldError is a setting that failed to parse or validate
+type FieldError struct {
+	Field string // As named in the config file, e.g. "exchange.floor_cpm"
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Loader binds a section to a flag set. Create it before the flags are
+// parsed, then call Load.
+type Loader struct {
+	name    string
+	section Section
+	fs      *flag.FlagSet
+	file    *string
+	fields  []field
+}
+
+// field is a bound setting
+type field struct {
+	path  string // File path, e.g. "exchange.port"
+	env   string
+	value reflect.Value
+	flag  *flagValue // nil if the field has no flag
+}
+
+// NewLoader registers section's flags, and -config, on fs. section must
+// be a pointer to a struct holding its defaults; name is its key in the
+// config file.
+func NewLoader(fs *flag.FlagSet, name string, section Section) *Loader {
+	l := &Loader{name: name, section: section, fs: fs}
+	l.file = fs.String("config", "", "YAML or JSON config file (default $"+EnvFile+")")
+	l.bind(reflect.ValueOf(section).Elem(), name)
+	return l
+}
+
+// bind walks a struct's fields, recursing into nested structs
+func (l *Loader) bind(v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if !sf.IsExported() || key == "-" || key == "" {
+			continue
+		}
+		fv := v.Field(i)
+		path := prefix + "." + key
+		if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(Duration(0)) {
+			l.bind(fv, path)
+			continue
+		}
+		f := field{path: path, env: sf.Tag.Get("env"), value: fv}
+		if name := sf.Tag.Get("flag"); name != "" {
+			f.flag = &flagValue{value: fv}
+			l.fs.Var(f.flag, name, sf.Tag.Get("help"))
+		}
+		l.fields = append(l.fields, f)
+	}
+}
+
+// flagValue records a flag's raw value when it's set, to be applied after
+// the file and environment; it reports the field's current value as its
+// default
+type flagValue struct {
+	value reflect.Value
+	raw   *string
+}
+
+func (f *flagValue) String() string {
+	// flag compares this against a zero flagValue's to decide whether to
+	// print the default
+	if f == nil || !f.value.IsValid() || f.value.IsZero() {
+		return ""
+	}
+	return format(f.value)
+}
+
+func (f *flagValue) Set(s string) error {
+	// Parse now so a bad flag fails at flag.Parse with the usual message
+	probe := reflect.New(f.value.Type()).Elem()
+	if err := parse(probe, s); err != nil {
+		return err
+	}
+	f.raw = &s
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be given as a bare -flag
+func (f *flagValue) IsBoolFlag() bool {
+	return f.value.Kind() == reflect.Bool
+}
+
+// Load applies the config file, environment and parsed flags over the
+// section's defaults, then validates it
+func (l *Loader) Load() error {
+	if !l.fs.Parsed() {
+		return errors.New("config: Load called before flags were parsed")
+	}
+	path := *l.file
+	if path == "" {
+		path = os.Getenv(EnvFile)
+	}
+	if path != "" {
+		if err := l.loadFile(path); err != nil {
+			return err
+		}
+	}
+	for _, f := range l.fields {
+		if f.env == "" {
+			continue
+		}
+		if s, ok := os.LookupEnv(f.env); ok {
+			if err := parse(f.value, s); err != nil {
+				return &FieldError{Field: f.path, Err: fmt.Errorf("$%s: %w", f.env, err)}
+			}
+		}
+	}
+	for _, f := range l.fields {
+		if f.flag != nil && f.flag.raw != nil {
+			if err := parse(f.value, *f.flag.raw); err != nil {
+				return &FieldError{Field: f.path, Err: err}
+			}
+		}
+	}
+	return l.section.Validate()
+}
+
+// loadFile decodes the section's key of a config file over the section.
+// JSON is read as YAML, of which it's a subset. Unknown keys are errors,
+// to catch typos.
+func (l *Loader) loadFile(path string) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return fmt.Errorf("config: %s: unsupported format %q", path, ext)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	var doc map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	node, ok := doc[l.name]
+	if !ok {
+		return nil
+	}
+	// Re-encode the section so unknown keys within it are rejected
+	section, err := yaml.Marshal(&node)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	dec := yaml.NewDecoder(strings.NewReader(string(section)))
+	dec.KnownFields(true)
+	if err := dec.Decode(l.section); err != nil {
+		return fmt.Errorf("config: %s: %s: %w", path, l.name, err)
+	}
+	return nil
+}
+
+// parse sets v from its string form
+func parse(v reflect.Value, s string) error {
+	switch v.Type() {
+	case reflect.TypeOf(Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// format is the string form parse reads
+func format(v reflect.Value) string {
+	if d, ok := v.Interface().(Duration); ok {
+		return d.String()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if s, ok := v.Interface().([]string); ok {
+			return strings.Join(s, ",")
+		}
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(t *testing.T, section Section, name string, args ...string) error {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	l := NewLoader(fs, name, section)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return l.Load()
+}
+
+const sharedYAML = `
+exchange:
+  port: 9001
+  ws_port: 9002
+  floor_cpm: 1.25
+  auction_timeout: 150ms
+  frequency_cap: 3
+  dsps:
+    - id: dsp1
+      endpoint: https://dsp1.example/bid
+      qps: 500
+      timeout: 80ms
+miner:
+  wallet: "0xabc"
+  tunnel:
+    type: ngrok
+    auth_token: t0k
+`
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfig(t, "adx.yaml", sharedYAML)
+	t.Setenv("ADX_FLOOR_CPM", "2.5")
+	t.Setenv("ADX_AUCTION_TIMEOUT", "200ms")
+
+	cfg := DefaultExchange()
+	if err := load(t, cfg, "exchange", "-config", path, "-auction-timeout", "250ms"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"default", cfg.Port != 0 && cfg.Redis == "", true},
+		{"file over default", cfg.Port, 9001},
+		{"file over default", cfg.FrequencyCap, 3},
+		{"env over file", cfg.FloorCPM, 2.5},
+		{"flag over env", cfg.AuctionTimeout, Duration(250 * time.Millisecond)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if len(cfg.DSPs) != 1 || cfg.DSPs[0].QPS != 500 || cfg.DSPs[0].Timeout != Duration(80*time.Millisecond) {
+		t.Errorf("dsps = %+v", cfg.DSPs)
+	}
+
+	// Nested sections bind too, and the file may come from the environment
+	t.Setenv(EnvFile, path)
+	t.Setenv("ADX_TUNNEL_SUBDOMAIN", "ads")
+	miner := DefaultMiner()
+	if err := load(t, miner, "miner", "-port", "7000"); err != nil {
+		t.Fatal(err)
+	}
+	if miner.Wallet != "0xabc" || miner.Port != 7000 || miner.CacheSize != "10GB" ||
+		miner.Tunnel.Type != "ngrok" || miner.Tunnel.AuthToken != "t0k" || miner.Tunnel.Subdomain != "ads" {
+		t.Errorf("miner = %+v", miner)
+	}
+}
+
+func TestLoad_JSON(t *testing.T) {
+	path := writeConfig(t, "adx.json", `{"node": {"node_id": "n1", "bootstrap": true, "bootstrap_nodes": ["a:1", "b:2"]}}`)
+	t.Setenv("ADX_BOOTSTRAP_NODES", "c:3, d:4")
+
+	cfg := DefaultNode()
+	if err := load(t, cfg, "node", "-config", path, "-bootstrap=false"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.NodeID != "n1" || cfg.Bootstrap || strings.Join(cfg.BootstrapNodes, " ") != "c:3 d:4" || cfg.RPCPort != 9000 {
+		t.Errorf("node = %+v", cfg)
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		file  string
+		field string
+		want  error
+	}{
+		{"timeout too long", []string{"-auction-timeout", "30s"}, nil, "", "exchange.auction_timeout", ErrOutOfRange},
+		{"zero timeout", nil, map[string]string{"ADX_AUCTION_TIMEOUT": "0s"}, "", "exchange.auction_timeout", ErrOutOfRange},
+		{"negative floor", []string{"-floor-cpm", "-0.5"}, nil, "", "exchange.floor_cpm", ErrNegative},
+		{"port", []string{"-port", "70000"}, nil, "", "exchange.port", ErrOutOfRange},
+		{"payouts without an account", []string{"-payout-rpc", "http://node:8545"}, nil, "", "exchange.payout_from", ErrRequired},
+		{"miner share over 1", []string{"-miner-share", "1.5"}, nil, "", "exchange.miner_share", ErrOutOfRange},
+		{"relative dsp endpoint", nil, nil, "exchange:\n  dsps:\n    - id: d\n      endpoint: /bid\n", "exchange.dsps[0].endpoint", ErrInvalid},
+		{"negative creative bytes", nil, nil, "exchange:\n  creative_policies:\n    preroll:\n      max_bytes: -1\n", "exchange.creative_policies[preroll].max_bytes", ErrNegative},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeConfig(t, "adx.yaml", tt.file))
+			}
+			err := load(t, DefaultExchange(), "exchange", args...)
+			var fe *FieldError
+			if !errors.As(err, &fe) || fe.Field != tt.field || !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %s: %v", err, tt.field, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_Rejects(t *testing.T) {
+	// Typos in the file are caught rather than ignored
+	path := writeConfig(t, "adx.yaml", "exchange:\n  flor_cpm: 2\n")
+	if err := load(t, DefaultExchange(), "exchange", "-config", path); err == nil || !strings.Contains(err.Error(), "flor_cpm") {
+		t.Errorf("unknown key err = %v", err)
+	}
+
+	t.Setenv("ADX_EXCHANGE_PORT", "eighty")
+	if err := load(t, DefaultExchange(), "exchange"); err == nil || !strings.Contains(err.Error(), "ADX_EXCHANGE_PORT") {
+		t.Errorf("bad env err = %v", err)
+	}
+
+	if err := load(t, DefaultExchange(), "exchange", "-auction-timeout", "soon"); err == nil {
+		t.Error("bad flag accepted")
+	}
+	if err := load(t, DefaultExchange(), "exchange", "-config", writeConfig(t, "adx.toml", "")); err == nil {
+		t.Error("unsupported format accepted")
+	}
+}
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"time"
+)
+
+var (
+	ErrOutOfRange = errors.New("out of range")
+	ErrNegative   = errors.New("must not be negative")
+	ErrRequired   = errors.New("required")
+	ErrInvalid    = errors.New("invalid")
+)
+
+// Auction timeouts outside this range are almost certainly a unit mistake
+const (
+	MinAuctionTimeout = time.Millisecond
+	MaxAuctionTimeout = 10 * time.Second
+)
+
+// ExchangeConfig configures adx-exchange
+type ExchangeConfig struct {
+	Port            int      `yaml:"port" env:"ADX_EXCHANGE_PORT" flag:"port" help:"HTTP server port"`
+	WSPort          int      `yaml:"ws_port" env:"ADX_EXCHANGE_WS_PORT" flag:"ws-port" help:"WebSocket server port"`
+	FDBCluster      string   `yaml:"fdb_cluster" env:"ADX_FDB_CLUSTER" flag:"fdb-cluster" help:"FoundationDB cluster file"`
+	FloorCPM        float64  `yaml:"floor_cpm" env:"ADX_FLOOR_CPM" flag:"floor-cpm" help:"Floor price CPM"`
+	InstlFloorCPM   float64  `yaml:"instl_floor_cpm" env:"ADX_INSTL_FLOOR_CPM" flag:"instl-floor-cpm" help:"Floor price CPM for interstitial impressions (0 uses the floor rules)"`
+	RewardFloorCPM  float64  `yaml:"reward_floor_cpm" env:"ADX_REWARD_FLOOR_CPM" flag:"reward-floor-cpm" help:"Floor price CPM for rewarded impressions (0 uses the floor rules)"`
+	AuctionTimeout  Duration `yaml:"auction_timeout" env:"ADX_AUCTION_TIMEOUT" flag:"auction-timeout" help:"Auction timeout"`
+	Redis           string   `yaml:"redis" env:"ADX_REDIS" flag:"redis" help:"Redis address for frequency caps shared across nodes"`
+	FrequencyCap    int      `yaml:"frequency_cap" env:"ADX_FREQUENCY_CAP" flag:"frequency-cap" help:"Impressions per user and campaign per day (0 disables)"`
+	ReserveStep     Duration `yaml:"reserve_step" env:"ADX_RESERVE_STEP" flag:"reserve-step" help:"How often to tune per-placement reserves from auction revenue (0 disables)"`
+	SPOWindow       Duration `yaml:"spo_window" env:"ADX_SPO_WINDOW" flag:"spo-window" help:"How long copies of an impression over other supply paths are folded into one auction (0 disables)"`
+	SPOHold         Duration `yaml:"spo_hold" env:"ADX_SPO_HOLD" flag:"spo-hold" help:"How long an impression waits for copies over cheaper supply paths before DSPs are asked"`
+	FatigueDecay    float64  `yaml:"fatigue_decay" env:"ADX_FATIGUE_DECAY" flag:"fatigue-decay" help:"Share of a creative's bid each recent exposure to the user takes off, 0 to 1 (0 disables)"`
+	FatigueHalfLife Duration `yaml:"fatigue_half_life" env:"ADX_FATIGUE_HALF_LIFE" flag:"fatigue-half-life" help:"How long until an exposure counts half towards creative fatigue (6h when zero)"`
+	FanoutTopK      int      `yaml:"fanout_top_k" env:"ADX_FANOUT_TOP_K" flag:"fanout-top-k" help:"DSPs each auction is sent to, the likeliest to bid and win for the placement and country (0 sends to all)"`
+	FanoutExplore   float64  `yaml:"fanout_explore" env:"ADX_FANOUT_EXPLORE" flag:"fanout-explore" help:"Share of the DSPs outside the top K also sent each auction, 0 to 1"`
+	SkipAdjust      float64  `yaml:"skip_adjust" env:"ADX_SKIP_ADJUST" flag:"skip-adjust" help:"Fraction bids for skippable video are repriced by, e.g. -0.2 for 20% less"`
+	NoSkipAdjust    float64  `yaml:"no_skip_adjust" env:"ADX_NO_SKIP_ADJUST" flag:"no-skip-adjust" help:"Fraction bids for non-skippable video are repriced by, e.g. 0.1 for 10% more"`
+	SessionDedup    Duration `yaml:"session_dedup" env:"ADX_SESSION_DEDUP" flag:"session-dedup" help:"How long a creative served in a viewing session is kept out of its later ad pods (0 disables)"`
+	AdminToken      string   `yaml:"admin_token" env:"ADX_ADMIN_TOKEN" flag:"admin-token" help:"Bearer token for the partner admin API (disabled when empty)"`
+	AdminQPS        int      `yaml:"admin_qps" env:"ADX_ADMIN_QPS" flag:"admin-qps" help:"Admin API requests per second per client"`
+	PartnersFile    string   `yaml:"partners_file" env:"ADX_PARTNERS_FILE" flag:"partners-file" help:"File the admin API persists DSPs and SSPs to"`
+	AuditFile       string   `yaml:"audit_file" env:"ADX_AUDIT_FILE" flag:"audit-file" help:"File every auction's audit record is appended to as a JSON line (empty keeps recent auctions in memory only)"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" env:"ADX_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" help:"How long shutdown waits for in-flight auctions and miners"`
+	PayoutRPC       string   `yaml:"payout_rpc" env:"ADX_PAYOUT_RPC" flag:"payout-rpc" help:"EVM JSON-RPC endpoint miners are paid through (payouts disabled when empty)"`
+	PayoutFrom      string   `yaml:"payout_from" env:"ADX_PAYOUT_FROM" flag:"payout-from" help:"Account miners are paid from, whose key the payout_rpc node holds"`
+	PayoutThreshold float64  `yaml:"payout_threshold" env:"ADX_PAYOUT_THRESHOLD" flag:"payout-threshold" help:"Miner earnings paid out as soon as they're reached (0 pays on the interval only)"`
+	PayoutInterval  Duration `yaml:"payout_interval" env:"ADX_PAYOUT_INTERVAL" flag:"payout-interval" help:"How often every miner's earnings are paid out (24h when zero)"`
+	MinerShare      float64  `yaml:"miner_share" env:"ADX_MINER_SHARE" flag:"miner-share" help:"Share of each impression's clearing price credited to the miner serving it, 0 to 1"`
+
+	// DSPs are the demand partners to connect to; file only
+	DSPs []DSPConfig `yaml:"dsps"`
+
+	// CreativePolicies cap winning creatives by placement tag ID, the
+	// "default" entry applying to every other placement; file only
+	CreativePolicies map[string]CreativePolicyConfig `yaml:"creative_policies"`
+}
+
+// DSPConfig defines a demand partner
+type DSPConfig struct {
+	ID         string   `yaml:"id"`
+	Name       string   `yaml:"name"`
+	Endpoint   string   `yaml:"endpoint"`
+	QPS        int      `yaml:"qps"`
+	Timeout    Duration `yaml:"timeout"`
+	BidderCode string   `yaml:"bidder_code"`
+	SeatID     string   `yaml:"seat_id"`
+	Failover   []string `yaml:"failover"` // Endpoints tried in order when endpoint can't be reached
+
+	Stream         string `yaml:"stream"`           // ws(s) URL of the DSP's bid stream
+	StreamInFlight int    `yaml:"stream_in_flight"` // Requests awaiting bids on the stream at once
+
+	FeedbackURL  string `yaml:"feedback_url"`
+	FeedbackMode string `yaml:"feedback_mode"` // impression or aggregate
+}
+
+// CreativePolicyConfig limits a placement's creatives; a zero limit is
+// unenforced
+type CreativePolicyConfig struct {
+	MaxBytes    int64    `yaml:"max_bytes"`
+	MaxWidth    int64    `yaml:"max_width"`
+	MaxHeight   int64    `yaml:"max_height"`
+	MIMETypes   []string `yaml:"mime_types"`
+	MaxDuration Duration `yaml:"max_duration"`
+	Demote      bool     `yaml:"demote"` // Serve a creative over the limits when no compliant bid is left
+}
+
+// DefaultExchange is adx-exchange's configuration without a file, env or
+// flags
+func DefaultExchange() *ExchangeConfig {
+	return &ExchangeConfig{
+		Port:            8080,
+		WSPort:          8081,
+		FloorCPM:        0.50,
+		AuctionTimeout:  Duration(100 * time.Millisecond),
+		FanoutExplore:   0.1,
+		AdminQPS:        10,
+		PartnersFile:    "partners.json",
+		ShutdownTimeout: Duration(15 * time.Second),
+	}
+}
+
+// Validate implements Section
+func (c *ExchangeConfig) Validate() error {
+	var errs []error
+	errs = append(errs, checkPort("exchange.port", c.Port), checkPort("exchange.ws_port", c.WSPort))
+	if c.FloorCPM < 0 {
+		errs = append(errs, &FieldError{"exchange.floor_cpm", fmt.Errorf("%w: %v", ErrNegative, c.FloorCPM)})
+	}
+	if c.InstlFloorCPM < 0 {
+		errs = append(errs, &FieldError{"exchange.instl_floor_cpm", fmt.Errorf("%w: %v", ErrNegative, c.InstlFloorCPM)})
+	}
+	if c.RewardFloorCPM < 0 {
+		errs = append(errs, &FieldError{"exchange.reward_floor_cpm", fmt.Errorf("%w: %v", ErrNegative, c.RewardFloorCPM)})
+	}
+	if t := time.Duration(c.AuctionTimeout); t < MinAuctionTimeout || t > MaxAuctionTimeout {
+		errs = append(errs, &FieldError{"exchange.auction_timeout",
+			fmt.Errorf("%w: %s, want %s to %s", ErrOutOfRange, t, MinAuctionTimeout, MaxAuctionTimeout)})
+	}
+	if c.FrequencyCap < 0 {
+		errs = append(errs, &FieldError{"exchange.frequency_cap", fmt.Errorf("%w: %d", ErrNegative, c.FrequencyCap)})
+	}
+	if c.ReserveStep < 0 {
+		errs = append(errs, &FieldError{"exchange.reserve_step", fmt.Errorf("%w: %s", ErrNegative, c.ReserveStep)})
+	}
+	if c.SPOWindow < 0 {
+		errs = append(errs, &FieldError{"exchange.spo_window", fmt.Errorf("%w: %s", ErrNegative, c.SPOWindow)})
+	}
+	if c.SPOHold < 0 || (c.SPOWindow > 0 && c.SPOHold >= c.SPOWindow) {
+		errs = append(errs, &FieldError{"exchange.spo_hold", fmt.Errorf("%w: %s, want under spo_window", ErrOutOfRange, c.SPOHold)})
+	}
+	if c.FatigueDecay < 0 || c.FatigueDecay >= 1 {
+		errs = append(errs, &FieldError{"exchange.fatigue_decay", fmt.Errorf("%w: %v, want 0 to 1", ErrOutOfRange, c.FatigueDecay)})
+	}
+	if c.FatigueHalfLife < 0 {
+		errs = append(errs, &FieldError{"exchange.fatigue_half_life", fmt.Errorf("%w: %s", ErrNegative, c.FatigueHalfLife)})
+	}
+	if c.SessionDedup < 0 {
+		errs = append(errs, &FieldError{"exchange.session_dedup", fmt.Errorf("%w: %s", ErrNegative, c.SessionDedup)})
+	}
+	if c.SkipAdjust < -1 {
+		errs = append(errs, &FieldError{"exchange.skip_adjust", fmt.Errorf("%w: %v, want -1 or more", ErrOutOfRange, c.SkipAdjust)})
+	}
+	if c.NoSkipAdjust < -1 {
+		errs = append(errs, &FieldError{"exchange.no_skip_adjust", fmt.Errorf("%w: %v, want -1 or more", ErrOutOfRange, c.NoSkipAdjust)})
+	}
+	if c.FanoutTopK < 0 {
+		errs = append(errs, &FieldError{"exchange.fanout_top_k", fmt.Errorf("%w: %d", ErrNegative, c.FanoutTopK)})
+	}
+	if c.FanoutExplore < 0 || c.FanoutExplore > 1 {
+		errs = append(errs, &FieldError{"exchange.fanout_explore", fmt.Errorf("%w: %v, want 0 to 1", ErrOutOfRange, c.FanoutExplore)})
+	}
+	if c.ShutdownTimeout < 0 {
+		errs = append(errs, &FieldError{"exchange.shutdown_timeout", fmt.Errorf("%w: %s", ErrNegative, c.ShutdownTimeout)})
+	}
+	if c.AdminQPS < 0 {
+		errs = append(errs, &FieldError{"exchange.admin_qps", fmt.Errorf("%w: %d", ErrNegative, c.AdminQPS)})
+	}
+	if c.PayoutRPC != "" {
+		if u, err := url.Parse(c.PayoutRPC); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, &FieldError{"exchange.payout_rpc", fmt.Errorf("%w: %q is not an absolute URL", ErrInvalid, c.PayoutRPC)})
+		}
+		if c.PayoutFrom == "" {
+			errs = append(errs, &FieldError{"exchange.payout_from", ErrRequired})
+		}
+	}
+	if c.PayoutThreshold < 0 {
+		errs = append(errs, &FieldError{"exchange.payout_threshold", fmt.Errorf("%w: %v", ErrNegative, c.PayoutThreshold)})
+	}
+	if c.PayoutInterval < 0 {
+		errs = append(errs, &FieldError{"exchange.payout_interval", fmt.Errorf("%w: %s", ErrNegative, c.PayoutInterval)})
+	}
+	if c.MinerShare < 0 || c.MinerShare > 1 {
+		errs = append(errs, &FieldError{"exchange.miner_share", fmt.Errorf("%w: %v, want 0 to 1", ErrOutOfRange, c.MinerShare)})
+	}
+	seen := make(map[string]bool)
+	for i, d := range c.DSPs {
+		path := fmt.Sprintf("exchange.dsps[%d]", i)
+		switch {
+		case d.ID == "":
+			errs = append(errs, &FieldError{path + ".id", ErrRequired})
+		case seen[d.ID]:
+			errs = append(errs, &FieldError{path + ".id", fmt.Errorf("%w: duplicate %q", ErrInvalid, d.ID)})
+		}
+		seen[d.ID] = true
+		if u, err := url.Parse(d.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, &FieldError{path + ".endpoint", fmt.Errorf("%w: %q is not an absolute URL", ErrInvalid, d.Endpoint)})
+		}
+		for j, f := range d.Failover {
+			if u, err := url.Parse(f); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = append(errs, &FieldError{fmt.Sprintf("%s.failover[%d]", path, j), fmt.Errorf("%w: %q is not an absolute URL", ErrInvalid, f)})
+			}
+		}
+		if d.Stream != "" {
+			if u, err := url.Parse(d.Stream); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+				errs = append(errs, &FieldError{path + ".stream", fmt.Errorf("%w: %q is not a ws(s) URL", ErrInvalid, d.Stream)})
+			}
+		}
+		if d.StreamInFlight < 0 {
+			errs = append(errs, &FieldError{path + ".stream_in_flight", fmt.Errorf("%w: %d", ErrNegative, d.StreamInFlight)})
+		}
+		if d.QPS < 0 {
+			errs = append(errs, &FieldError{path + ".qps", fmt.Errorf("%w: %d", ErrNegative, d.QPS)})
+		}
+		if t := time.Duration(d.Timeout); t < 0 || t > MaxAuctionTimeout {
+			errs = append(errs, &FieldError{path + ".timeout", fmt.Errorf("%w: %s", ErrOutOfRange, t)})
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.CreativePolicies)) {
+		p := c.CreativePolicies[id]
+		path := fmt.Sprintf("exchange.creative_policies[%s]", id)
+		for _, f := range []struct {
+			key   string
+			value int64
+		}{{"max_bytes", p.MaxBytes}, {"max_width", p.MaxWidth}, {"max_height", p.MaxHeight}, {"max_duration", int64(p.MaxDuration)}} {
+			if f.value < 0 {
+				errs = append(errs, &FieldError{path + "." + f.key, fmt.Errorf("%w: %d", ErrNegative, f.value)})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NodeConfig configures adxd
+type NodeConfig struct {
+	DataDir        string   `yaml:"data_dir" env:"ADX_DATA_DIR" flag:"data-dir" help:"Data directory"`
+	NodeID         string   `yaml:"node_id" env:"ADX_NODE_ID" flag:"node-id" help:"Node ID"`
+	Port           int      `yaml:"port" env:"ADX_NODE_PORT" flag:"port" help:"HTTP port"`
+	RPCPort        int      `yaml:"rpc_port" env:"ADX_RPC_PORT" flag:"rpc-port" help:"RPC port"`
+	P2PPort        int      `yaml:"p2p_port" env:"ADX_P2P_PORT" flag:"p2p-port" help:"P2P port"`
+	NetworkID      string   `yaml:"network_id" env:"ADX_NETWORK_ID" flag:"network-id" help:"Network ID"`
+	LogLevel       string   `yaml:"log_level" env:"ADX_LOG_LEVEL" flag:"log-level" help:"Log level"`
+	Bootstrap      bool     `yaml:"bootstrap" env:"ADX_BOOTSTRAP" flag:"bootstrap" help:"Run as bootstrap node"`
+	BootstrapNodes []string `yaml:"bootstrap_nodes" env:"ADX_BOOTSTRAP_NODES" flag:"bootstrap-nodes" help:"Bootstrap nodes (comma-separated)"`
+	Miner          bool     `yaml:"miner" env:"ADX_MINER" flag:"miner" help:"Enable mining"`
+	TEEMode        string   `yaml:"tee_mode" env:"ADX_TEE_MODE" flag:"tee-mode" help:"TEE mode: simulated, sgx, nitro"`
+}
+
+// DefaultNode is adxd's configuration without a file, env or flags
+func DefaultNode() *NodeConfig {
+	return &NodeConfig{
+		DataDir:   "/tmp/adxd",
+		Port:      8000,
+		RPCPort:   9000,
+		P2PPort:   10000,
+		NetworkID: "adx-local",
+		LogLevel:  "info",
+		TEEMode:   "simulated",
+	}
+}
+
+// Validate implements Section
+func (c *NodeConfig) Validate() error {
+	var errs []error
+	if c.NodeID == "" {
+		errs = append(errs, &FieldError{"node.node_id", ErrRequired})
+	}
+	errs = append(errs,
+		checkPort("node.port", c.Port),
+		checkPort("node.rpc_port", c.RPCPort),
+		checkPort("node.p2p_port", c.P2PPort),
+		checkOneOf("node.log_level", c.LogLevel, "debug", "info", "warn", "error"),
+		checkOneOf("node.tee_mode", c.TEEMode, "simulated", "sgx", "nitro"),
+	)
+	return errors.Join(errs...)
+}
+
+// MinerConfig configures adx-miner start
+type MinerConfig struct {
+	Wallet       string   `yaml:"wallet" env:"ADX_MINER_WALLET" flag:"wallet" help:"Wallet address for earnings"`
+	Port         int      `yaml:"port" env:"ADX_MINER_PORT" flag:"port" help:"Local port"`
+	CacheSize    string   `yaml:"cache_size" env:"ADX_MINER_CACHE_SIZE" flag:"cache-size" help:"Cache size"`
+	DrainTimeout Duration `yaml:"drain_timeout" env:"ADX_MINER_DRAIN_TIMEOUT" flag:"drain-timeout" help:"How long to wait for in-flight ads on stop"`
+	Exchange     string   `yaml:"exchange" env:"ADX_MINER_EXCHANGE" flag:"exchange" help:"Exchange WebSocket URL impressions are reported to"`
+
+	Tunnel TunnelConfig `yaml:"tunnel"`
+}
+
+// TunnelConfig is how a miner is reached from the internet
+type TunnelConfig struct {
+	Type      string `yaml:"type" env:"ADX_TUNNEL" flag:"tunnel" help:"Tunnel type"`
+	AuthToken string `yaml:"auth_token" env:"ADX_TUNNEL_AUTH_TOKEN" flag:"auth-token" help:"Auth token for tunnel service"`
+	Subdomain string `yaml:"subdomain" env:"ADX_TUNNEL_SUBDOMAIN" flag:"subdomain" help:"Subdomain for tunnel"`
+	PublicIP  string `yaml:"public_ip" env:"ADX_TUNNEL_PUBLIC_IP" flag:"public-ip" help:"Public IP for direct mode"`
+	CFToken   string `yaml:"cf_token" env:"ADX_TUNNEL_CF_TOKEN" flag:"cf-token" help:"Cloudflare token"`
+}
+
+// DefaultMiner is adx-miner's configuration without a file, env or flags
+func DefaultMiner() *MinerConfig {
+	return &MinerConfig{
+		Port:         8888,
+		CacheSize:    "10GB",
+		DrainTimeout: Duration(30 * time.Second),
+		Exchange:     "ws://localhost:8081/ws",
+		Tunnel:       TunnelConfig{Type: "localxpose"},
+	}
+}
+
+// Validate implements Section
+func (c *MinerConfig) Validate() error {
+	var errs []error
+	if c.Wallet == "" {
+		errs = append(errs, &FieldError{"miner.wallet", ErrRequired})
+	}
+	errs = append(errs,
+		checkPort("miner.port", c.Port),
+		checkOneOf("miner.tunnel.type", c.Tunnel.Type, "localxpose", "ngrok", "cloudflare", "tailscale", "direct"),
+	)
+	if c.DrainTimeout < 0 {
+		errs = append(errs, &FieldError{"miner.drain_timeout", fmt.Errorf("%w: %s", ErrNegative, c.DrainTimeout)})
+	}
+	if u, err := url.Parse(c.Exchange); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		errs = append(errs, &FieldError{"miner.exchange", fmt.Errorf("%w: %q is not a ws(s) URL", ErrInvalid, c.Exchange)})
+	}
+	if c.Tunnel.Type == "direct" && c.Tunnel.PublicIP == "" {
+		errs = append(errs, &FieldError{"miner.tunnel.public_ip", fmt.Errorf("%w for direct mode", ErrRequired)})
+	}
+	return errors.Join(errs...)
+}
+
+func checkPort(field string, port int) error {
+	if port < 1 || port > 65535 {
+		return &FieldError{field, fmt.Errorf("%w: %d, want 1 to 65535", ErrOutOfRange, port)}
+	}
+	return nil
+}
+
+func checkOneOf(field, value string, allowed ...string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return &FieldError{field, fmt.Errorf("%w: %q, want one of %v", ErrInvalid, value, allowed)}
+}
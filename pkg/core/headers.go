@@ -48,12 +48,13 @@ type BidHeader struct {
 type AuctionHeader struct {
 	BaseHeader
 
-	AuctionID    ids.ID   `json:"auction_id"`
-	CmInputs     [][]byte `json:"cm_inputs"`     // Commitments of all accepted bids
-	CmWinner     []byte   `json:"cm_winner"`     // Commitment to (winner_id, winning_bid, price)
-	ProofAuction []byte   `json:"proof_auction"` // ZK proof of correct auction
-	PolicyRoot   []byte   `json:"policy_root"`   // Commitment to policy snapshot
-	DAPtr        []byte   `json:"da_ptr"`        // Pointer to data availability layer
+	AuctionID     ids.ID   `json:"auction_id"`
+	CmInputs      [][]byte `json:"cm_inputs"` // Commitments of all accepted bids
+	CmWinner      []byte   `json:"cm_winner"` // Commitment to (winner_id, winning_bid, price)
+	ClearingPrice uint64   `json:"clearing_price"`
+	ProofAuction  []byte   `json:"proof_auction"` // ZK proof of correct auction
+	PolicyRoot    []byte   `json:"policy_root"`   // Commitment to policy snapshot
+	DAPtr         []byte   `json:"da_ptr"`        // Pointer to data availability layer
 }
 
 // ImpressionHeader logs an ad impression with viewability proof
@@ -0,0 +1,271 @@
+// Package creative validates uploaded ad creatives and prepares them for
+// transcoding into the renditions served in VAST responses.
+package creative
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmptyFile       = errors.New("creative file is empty")
+	ErrTooLarge        = errors.New("creative exceeds maximum size")
+	ErrUnsupportedType = errors.New("unsupported creative type")
+	ErrNoVideoStream   = errors.New("creative has no video stream")
+	ErrDuration        = errors.New("creative duration out of bounds")
+	ErrDimensions      = errors.New("creative dimensions out of bounds")
+	ErrCodec           = errors.New("unsupported video codec")
+)
+
+// Policy bounds what an uploaded creative may be
+type Policy struct {
+	MaxBytes         int64
+	AllowedMIMETypes []string
+	AllowedCodecs    []string // empty allows any codec
+	MinDuration      time.Duration
+	MaxDuration      time.Duration
+	MinWidth         int
+	MinHeight        int
+	MaxWidth         int
+	MaxHeight        int
+}
+
+// DefaultPolicy accepts CTV-grade video up to 2 minutes and 500MB
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxBytes:         500 << 20,
+		AllowedMIMETypes: []string{"video/mp4", "video/webm", "video/quicktime"},
+		AllowedCodecs:    []string{"h264", "hevc", "vp8", "vp9", "av1"},
+		MinDuration:      5 * time.Second,
+		MaxDuration:      120 * time.Second,
+		MinWidth:         640,
+		MinHeight:        360,
+		MaxWidth:         3840,
+		MaxHeight:        2160,
+	}
+}
+
+// ProbeResult is the subset of container/stream metadata we validate on
+type ProbeResult struct {
+	Duration   time.Duration
+	Width      int
+	Height     int
+	VideoCodec string
+	Bitrate    int // bits per second
+}
+
+// Prober extracts media metadata from a file
+type Prober interface {
+	Probe(ctx context.Context, path string) (*ProbeResult, error)
+}
+
+// Metadata describes a validated creative
+type Metadata struct {
+	ContentHash string        `json:"content_hash"`
+	MIMEType    string        `json:"mime_type"`
+	Size        int64         `json:"size"`
+	Duration    time.Duration `json:"duration"`
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	VideoCodec  string        `json:"video_codec,omitempty"`
+	Bitrate     int           `json:"bitrate,omitempty"`
+}
+
+// Validator checks uploaded files against a Policy
+type Validator struct {
+	Policy Policy
+	Prober Prober // nil skips media probing
+}
+
+// NewValidator creates a validator
+func NewValidator(policy Policy, prober Prober) *Validator {
+	return &Validator{Policy: policy, Prober: prober}
+}
+
+// CheckSize rejects a declared upload size before the body is stored
+func (v *Validator) CheckSize(size int64) error {
+	if size <= 0 {
+		return ErrEmptyFile
+	}
+	if v.Policy.MaxBytes > 0 && size > v.Policy.MaxBytes {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// Validate sniffs, hashes and probes the file at path
+func (v *Validator) Validate(ctx context.Context, path string) (*Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := v.CheckSize(info.Size()); err != nil {
+		return nil, err
+	}
+
+	// Sniff from content rather than trusting the client's Content-Type
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	mimeType := sniffMIME(head[:n])
+	if !contains(v.Policy.AllowedMIMETypes, mimeType) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, mimeType)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{
+		ContentHash: hex.EncodeToString(hash.Sum(nil)),
+		MIMEType:    mimeType,
+		Size:        info.Size(),
+	}
+
+	if v.Prober == nil {
+		return meta, nil
+	}
+	probe, err := v.Prober.Probe(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkProbe(probe); err != nil {
+		return nil, err
+	}
+	meta.Duration = probe.Duration
+	meta.Width = probe.Width
+	meta.Height = probe.Height
+	meta.VideoCodec = probe.VideoCodec
+	meta.Bitrate = probe.Bitrate
+
+	return meta, nil
+}
+
+func (v *Validator) checkProbe(p *ProbeResult) error {
+	if p.Width == 0 || p.Height == 0 {
+		return ErrNoVideoStream
+	}
+	if p.Duration < v.Policy.MinDuration ||
+		(v.Policy.MaxDuration > 0 && p.Duration > v.Policy.MaxDuration) {
+		return fmt.Errorf("%w: %s", ErrDuration, p.Duration)
+	}
+	if p.Width < v.Policy.MinWidth || p.Height < v.Policy.MinHeight ||
+		(v.Policy.MaxWidth > 0 && p.Width > v.Policy.MaxWidth) ||
+		(v.Policy.MaxHeight > 0 && p.Height > v.Policy.MaxHeight) {
+		return fmt.Errorf("%w: %dx%d", ErrDimensions, p.Width, p.Height)
+	}
+	if len(v.Policy.AllowedCodecs) > 0 && !contains(v.Policy.AllowedCodecs, p.VideoCodec) {
+		return fmt.Errorf("%w: %s", ErrCodec, p.VideoCodec)
+	}
+	return nil
+}
+
+// sniffMIME extends http.DetectContentType with the QuickTime brand, which
+// it reports as generic MP4
+func sniffMIME(head []byte) string {
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" && string(head[8:12]) == "qt  " {
+		return "video/quicktime"
+	}
+	mimeType := http.DetectContentType(head)
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return mimeType
+}
+
+// FFProbe probes media with the ffprobe binary
+type FFProbe struct {
+	Binary string // defaults to "ffprobe"
+}
+
+// Probe implements Prober
+func (p *FFProbe) Probe(ctx context.Context, path string) (*ProbeResult, error) {
+	bin := p.Binary
+	if bin == "" {
+		bin = "ffprobe"
+	}
+	out, err := exec.CommandContext(ctx, bin,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return parseFFProbe(out)
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		BitRate   string `json:"bit_rate"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+func parseFFProbe(data []byte) (*ProbeResult, error) {
+	var out ffprobeOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid ffprobe output: %w", err)
+	}
+
+	result := &ProbeResult{}
+	if secs, err := strconv.ParseFloat(out.Format.Duration, 64); err == nil {
+		result.Duration = time.Duration(secs * float64(time.Second))
+	}
+	result.Bitrate, _ = strconv.Atoi(out.Format.BitRate)
+
+	for _, s := range out.Streams {
+		if s.CodecType != "video" {
+			continue
+		}
+		result.Width = s.Width
+		result.Height = s.Height
+		result.VideoCodec = s.CodecName
+		if br, err := strconv.Atoi(s.BitRate); err == nil && br > 0 {
+			result.Bitrate = br
+		}
+		break
+	}
+	return result, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
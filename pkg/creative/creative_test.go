@@ -0,0 +1,161 @@
+package creative
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// minimalMP4 is an ftyp box followed by padding; enough for content sniffing
+var minimalMP4 = append([]byte{
+	0x00, 0x00, 0x00, 0x18, 'f', 't', 'y', 'p',
+	'i', 's', 'o', 'm', 0x00, 0x00, 0x02, 0x00,
+	'i', 's', 'o', 'm', 'm', 'p', '4', '1',
+}, make([]byte, 1024)...)
+
+type fakeProber struct {
+	result *ProbeResult
+}
+
+func (p *fakeProber) Probe(ctx context.Context, path string) (*ProbeResult, error) {
+	return p.result, nil
+}
+
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func validProbe() *ProbeResult {
+	return &ProbeResult{
+		Duration:   30 * time.Second,
+		Width:      1920,
+		Height:     1080,
+		VideoCodec: "h264",
+		Bitrate:    5000000,
+	}
+}
+
+func TestValidator_ValidMP4(t *testing.T) {
+	v := NewValidator(DefaultPolicy(), &fakeProber{result: validProbe()})
+	path := writeTemp(t, "ad.mp4", minimalMP4)
+
+	meta, err := v.Validate(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if meta.MIMEType != "video/mp4" {
+		t.Errorf("MIME = %q, want video/mp4", meta.MIMEType)
+	}
+	if meta.Width != 1920 || meta.Duration != 30*time.Second || meta.VideoCodec != "h264" {
+		t.Errorf("metadata = %+v", meta)
+	}
+	if len(meta.ContentHash) != 64 {
+		t.Errorf("content hash = %q", meta.ContentHash)
+	}
+
+	// Identical bytes hash identically, which is what dedupe relies on
+	again, _ := v.Validate(context.Background(), writeTemp(t, "copy.mp4", minimalMP4))
+	if again.ContentHash != meta.ContentHash {
+		t.Error("identical files should have the same content hash")
+	}
+}
+
+func TestValidator_Oversized(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.MaxBytes = 512
+	v := NewValidator(policy, &fakeProber{result: validProbe()})
+
+	if err := v.CheckSize(1024); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("CheckSize err = %v, want %v", err, ErrTooLarge)
+	}
+	if _, err := v.Validate(context.Background(), writeTemp(t, "big.mp4", minimalMP4)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Validate err = %v, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestValidator_NonVideo(t *testing.T) {
+	v := NewValidator(DefaultPolicy(), &fakeProber{result: validProbe()})
+
+	path := writeTemp(t, "ad.mp4", []byte("this is definitely not a video file"))
+	if _, err := v.Validate(context.Background(), path); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("err = %v, want %v", err, ErrUnsupportedType)
+	}
+
+	if _, err := v.Validate(context.Background(), writeTemp(t, "empty.mp4", nil)); !errors.Is(err, ErrEmptyFile) {
+		t.Errorf("err = %v, want %v", err, ErrEmptyFile)
+	}
+}
+
+func TestValidator_ProbeBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*ProbeResult)
+		wantErr error
+	}{
+		{"too long", func(p *ProbeResult) { p.Duration = 10 * time.Minute }, ErrDuration},
+		{"too short", func(p *ProbeResult) { p.Duration = time.Second }, ErrDuration},
+		{"too small", func(p *ProbeResult) { p.Width, p.Height = 320, 180 }, ErrDimensions},
+		{"audio only", func(p *ProbeResult) { p.Width, p.Height = 0, 0 }, ErrNoVideoStream},
+		{"codec", func(p *ProbeResult) { p.VideoCodec = "mpeg2video" }, ErrCodec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := validProbe()
+			tt.mutate(probe)
+			v := NewValidator(DefaultPolicy(), &fakeProber{result: probe})
+
+			_, err := v.Validate(context.Background(), writeTemp(t, "ad.mp4", minimalMP4))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFFProbe(t *testing.T) {
+	out := []byte(`{
+		"streams": [
+			{"codec_type": "audio", "codec_name": "aac", "bit_rate": "128000"},
+			{"codec_type": "video", "codec_name": "h264", "width": 1280, "height": 720, "bit_rate": "2400000"}
+		],
+		"format": {"duration": "15.015000", "bit_rate": "2600000"}
+	}`)
+
+	p, err := parseFFProbe(out)
+	if err != nil {
+		t.Fatalf("parseFFProbe: %v", err)
+	}
+	if p.Width != 1280 || p.Height != 720 || p.VideoCodec != "h264" || p.Bitrate != 2400000 {
+		t.Errorf("probe = %+v", p)
+	}
+	if p.Duration != 15015*time.Millisecond {
+		t.Errorf("duration = %v", p.Duration)
+	}
+}
+
+func TestMemoryQueue(t *testing.T) {
+	q := NewMemoryQueue()
+	job := NewTranscodeJob("cre_1", "/tmp/cre_1.mp4", &Metadata{ContentHash: "abc"})
+	if err := q.Enqueue(job); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len = %d, want 1", q.Len())
+	}
+	jobs := q.Drain()
+	if len(jobs) != 1 || jobs[0].CreativeID != "cre_1" || len(jobs[0].Renditions) != len(DefaultLadder) {
+		t.Errorf("drained %+v", jobs)
+	}
+	if q.Len() != 0 {
+		t.Error("queue should be empty after drain")
+	}
+}
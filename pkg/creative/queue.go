@@ -0,0 +1,342 @@
+package creative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull   = errors.New("transcode queue is full")
+	ErrQueueClosed = errors.New("transcode queue is closed")
+)
+
+// JobState is where a transcode job is in its lifecycle
+type JobState string
+
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+// MediaFile is a transcoded rendition as it's served in VAST
+type MediaFile struct {
+	URL      string `json:"url"`
+	Delivery string `json:"delivery"` // "progressive" or "streaming"
+	Type     string `json:"type"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Bitrate  int    `json:"bitrate,omitempty"` // kbps
+	Codec    string `json:"codec,omitempty"`
+}
+
+// JobStatus is a transcode job's progress, for polling
+type JobStatus struct {
+	ID         string      `json:"id"`
+	CreativeID string      `json:"creative_id"`
+	State      JobState    `json:"state"`
+	Attempts   int         `json:"attempts"`
+	Error      string      `json:"error,omitempty"`
+	MediaFiles []MediaFile `json:"media_files,omitempty"`
+	Updated    time.Time   `json:"updated"`
+}
+
+// Transcoder renders one rendition of a source file into dir and returns
+// the output's file name
+type Transcoder interface {
+	Transcode(ctx context.Context, src string, r Rendition, dir, name string) (string, error)
+}
+
+// TranscodeTracker reports the progress of enqueued jobs
+type TranscodeTracker interface {
+	Status(jobID string) (JobStatus, bool)
+}
+
+// CreativeStore receives a creative's renditions once they're transcoded
+type CreativeStore interface {
+	SetMediaFiles(creativeID string, files []MediaFile) error
+}
+
+// TranscodeConfig sizes a TranscodeQueue and says where renditions go
+type TranscodeConfig struct {
+	Workers     int           // Jobs transcoded at once
+	Backlog     int           // Jobs queued beyond the running ones before Enqueue fails
+	MaxAttempts int           // Tries per job before it's marked failed
+	RetryDelay  time.Duration // Wait before retrying a failed job
+	OutputDir   string        // Static directory renditions are written to
+	BaseURL     string        // URL OutputDir is served under
+}
+
+// DefaultTranscodeConfig runs two jobs at once and tries each three times
+var DefaultTranscodeConfig = TranscodeConfig{
+	Workers:     2,
+	Backlog:     100,
+	MaxAttempts: 3,
+	RetryDelay:  10 * time.Second,
+	OutputDir:   "./static/creatives",
+}
+
+// TranscodeQueue transcodes creatives into their rendition ladder on a
+// bounded worker pool. Failed jobs are retried up to MaxAttempts; outputs
+// are probed, when there's a Prober, and handed to the Store.
+type TranscodeQueue struct {
+	config     TranscodeConfig
+	transcoder Transcoder
+	prober     Prober        // nil takes rendition specs as-is
+	store      CreativeStore // nil only tracks job status
+
+	jobs   chan *TranscodeJob
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	status map[string]*JobStatus
+}
+
+// NewTranscodeQueue starts a queue's workers
+func NewTranscodeQueue(config TranscodeConfig, transcoder Transcoder, prober Prober, store CreativeStore) *TranscodeQueue {
+	if config.Workers <= 0 {
+		config.Workers = DefaultTranscodeConfig.Workers
+	}
+	if config.Backlog <= 0 {
+		config.Backlog = DefaultTranscodeConfig.Backlog
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &TranscodeQueue{
+		config:     config,
+		transcoder: transcoder,
+		prober:     prober,
+		store:      store,
+		jobs:       make(chan *TranscodeJob, config.Backlog),
+		ctx:        ctx,
+		cancel:     cancel,
+		status:     make(map[string]*JobStatus),
+	}
+	for i := 0; i < config.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue implements TranscodeEnqueuer
+func (q *TranscodeQueue) Enqueue(job *TranscodeJob) error {
+	if q.ctx.Err() != nil {
+		return ErrQueueClosed
+	}
+	q.mu.Lock()
+	q.status[job.ID] = &JobStatus{ID: job.ID, CreativeID: job.CreativeID, State: JobQueued, Updated: time.Now()}
+	q.mu.Unlock()
+
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		q.mu.Lock()
+		delete(q.status, job.ID)
+		q.mu.Unlock()
+		return ErrQueueFull
+	}
+}
+
+// Status returns a job's progress
+func (q *TranscodeQueue) Status(jobID string) (JobStatus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s, ok := q.status[jobID]
+	if !ok {
+		return JobStatus{}, false
+	}
+	out := *s
+	out.MediaFiles = append([]MediaFile(nil), s.MediaFiles...)
+	return out, true
+}
+
+// Close stops the workers, cancelling running transcodes, and waits for
+// them to exit
+func (q *TranscodeQueue) Close() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *TranscodeQueue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case job := <-q.jobs:
+			q.run(job)
+		}
+	}
+}
+
+// run makes one attempt at job, scheduling a retry if it fails and has
+// attempts left
+func (q *TranscodeQueue) run(job *TranscodeJob) {
+	attempts := q.update(job.ID, func(s *JobStatus) {
+		s.State = JobRunning
+		s.Attempts++
+	})
+
+	files, err := q.transcode(job)
+	if err == nil && q.store != nil {
+		err = q.store.SetMediaFiles(job.CreativeID, files)
+	}
+	if err == nil {
+		q.update(job.ID, func(s *JobStatus) {
+			s.State = JobDone
+			s.Error = ""
+			s.MediaFiles = files
+		})
+		return
+	}
+
+	if attempts >= q.config.MaxAttempts || q.ctx.Err() != nil {
+		q.update(job.ID, func(s *JobStatus) {
+			s.State = JobFailed
+			s.Error = err.Error()
+		})
+		return
+	}
+	q.update(job.ID, func(s *JobStatus) {
+		s.State = JobQueued
+		s.Error = err.Error()
+	})
+	q.wg.Add(1)
+	go q.retry(job)
+}
+
+func (q *TranscodeQueue) retry(job *TranscodeJob) {
+	defer q.wg.Done()
+	timer := time.NewTimer(q.config.RetryDelay)
+	defer timer.Stop()
+
+	select {
+	case <-q.ctx.Done():
+	case <-timer.C:
+		select {
+		case <-q.ctx.Done():
+		case q.jobs <- job:
+			return
+		}
+	}
+	q.update(job.ID, func(s *JobStatus) { s.State = JobFailed })
+}
+
+// update applies fn to a job's status and returns its attempts
+func (q *TranscodeQueue) update(jobID string, fn func(*JobStatus)) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := q.status[jobID]
+	fn(s)
+	s.Updated = time.Now()
+	return s.Attempts
+}
+
+// transcode renders every rendition of job and describes the outputs
+func (q *TranscodeQueue) transcode(job *TranscodeJob) ([]MediaFile, error) {
+	if err := os.MkdirAll(q.config.OutputDir, 0o755); err != nil {
+		return nil, err
+	}
+	files := make([]MediaFile, 0, len(job.Renditions))
+	for _, r := range job.Renditions {
+		name := renditionName(job.CreativeID, r)
+		out, err := q.transcoder.Transcode(q.ctx, job.SourcePath, r, q.config.OutputDir, name)
+		if err != nil {
+			return nil, fmt.Errorf("rendition %s/%s: %w", r.Name, r.Container, err)
+		}
+		file := MediaFile{
+			URL:      q.config.BaseURL + "/" + out,
+			Delivery: "progressive",
+			Type:     "video/" + r.Container,
+			Width:    r.Width,
+			Height:   r.Height,
+			Bitrate:  r.Bitrate,
+		}
+		if r.Container == "hls" {
+			file.Delivery = "streaming"
+			file.Type = "application/x-mpegURL"
+		}
+		if q.prober != nil && r.Container != "hls" {
+			probe, err := q.prober.Probe(q.ctx, filepath.Join(q.config.OutputDir, out))
+			if err != nil {
+				return nil, fmt.Errorf("rendition %s/%s: %w", r.Name, r.Container, err)
+			}
+			file.Width, file.Height, file.Codec = probe.Width, probe.Height, probe.VideoCodec
+			if probe.Bitrate > 0 {
+				file.Bitrate = probe.Bitrate / 1000
+			}
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// renditionName is the output file name for a creative's rendition; the
+// MP4, WebM and HLS copies of a size differ only by extension, as the VAST
+// handler expects
+func renditionName(creativeID string, r Rendition) string {
+	ext := r.Container
+	if ext == "hls" {
+		ext = "m3u8"
+	}
+	return fmt.Sprintf("%s_%s.%s", creativeID, r.Name, ext)
+}
+
+// FFmpeg transcodes with the ffmpeg binary
+type FFmpeg struct {
+	Binary string // defaults to "ffmpeg"
+}
+
+// Transcode implements Transcoder
+func (f *FFmpeg) Transcode(ctx context.Context, src string, r Rendition, dir, name string) (string, error) {
+	bin := f.Binary
+	if bin == "" {
+		bin = "ffmpeg"
+	}
+	out, err := exec.CommandContext(ctx, bin, ffmpegArgs(src, r, filepath.Join(dir, name))...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg failed: %w: %s", err, tail(out, 512))
+	}
+	return name, nil
+}
+
+// ffmpegArgs scales src to fit the rendition, padding to its aspect ratio
+func ffmpegArgs(src string, r Rendition, dst string) []string {
+	scale := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2",
+		r.Width, r.Height, r.Width, r.Height)
+	args := []string{"-y", "-v", "error", "-i", src, "-vf", scale}
+	if r.Bitrate > 0 {
+		args = append(args, "-b:v", strconv.Itoa(r.Bitrate)+"k")
+	}
+	switch r.Container {
+	case "webm":
+		args = append(args, "-c:v", "libvpx-vp9", "-c:a", "libopus")
+	case "hls":
+		args = append(args, "-c:v", "libx264", "-c:a", "aac",
+			"-f", "hls", "-hls_time", "6", "-hls_playlist_type", "vod")
+	default:
+		args = append(args, "-c:v", "libx264", "-c:a", "aac", "-movflags", "+faststart")
+	}
+	return append(args, dst)
+}
+
+func tail(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[len(b)-n:]
+	}
+	return b
+}
@@ -0,0 +1,240 @@
+package creative
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTranscoder fails the first failures calls, then blocks each call
+// until gate yields, tracking how many run at once
+type fakeTranscoder struct {
+	gate     chan struct{}
+	failures int
+
+	mu          sync.Mutex
+	calls       int
+	inFlight    int
+	maxInFlight int
+}
+
+func (f *fakeTranscoder) Transcode(ctx context.Context, src string, r Rendition, dir, name string) (string, error) {
+	f.mu.Lock()
+	f.calls++
+	if f.calls <= f.failures {
+		f.mu.Unlock()
+		return "", errors.New("encoder crashed")
+	}
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	if f.gate != nil {
+		select {
+		case <-f.gate:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	return name, nil
+}
+
+type fakeStore struct {
+	mu    sync.Mutex
+	files map[string][]MediaFile
+}
+
+func (s *fakeStore) SetMediaFiles(creativeID string, files []MediaFile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string][]MediaFile)
+	}
+	s.files[creativeID] = files
+	return nil
+}
+
+func testJob(creativeID string, renditions ...Rendition) *TranscodeJob {
+	job := NewTranscodeJob(creativeID, "/src/"+creativeID+".mp4", nil)
+	if len(renditions) > 0 {
+		job.Renditions = renditions
+	}
+	return job
+}
+
+func waitState(t *testing.T, q *TranscodeQueue, jobID string, want JobState) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s, _ := q.Status(jobID)
+		if s.State == want {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", jobID, s.State, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTranscodeQueue_Lifecycle(t *testing.T) {
+	tc := &fakeTranscoder{gate: make(chan struct{})}
+	store := &fakeStore{}
+	q := NewTranscodeQueue(TranscodeConfig{Workers: 1, OutputDir: t.TempDir(), BaseURL: "https://cdn/creatives"},
+		tc, &fakeProber{result: &ProbeResult{Width: 640, Height: 352, VideoCodec: "h264", Bitrate: 980000}}, store)
+	defer q.Close()
+
+	first, second := testJob("cre_1"), testJob("cre_2")
+	if err := q.Enqueue(first); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	waitState(t, q, first.ID, JobRunning)
+	if err := q.Enqueue(second); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	// The only worker is busy
+	if s, _ := q.Status(second.ID); s.State != JobQueued {
+		t.Errorf("second job is %s while the worker is busy, want queued", s.State)
+	}
+
+	for range DefaultLadder {
+		tc.gate <- struct{}{}
+	}
+	s := waitState(t, q, first.ID, JobDone)
+	if s.Attempts != 1 || len(s.MediaFiles) != len(DefaultLadder) {
+		t.Fatalf("status = %+v", s)
+	}
+	mp4 := s.MediaFiles[1]
+	if mp4.URL != "https://cdn/creatives/cre_1_m.mp4" || mp4.Type != "video/mp4" || mp4.Delivery != "progressive" {
+		t.Errorf("m rendition = %+v", mp4)
+	}
+	// Probed values win over the ladder's
+	if mp4.Height != 352 || mp4.Bitrate != 980 || mp4.Codec != "h264" {
+		t.Errorf("m rendition not probed: %+v", mp4)
+	}
+	hls := s.MediaFiles[len(s.MediaFiles)-1]
+	if hls.URL != "https://cdn/creatives/cre_1_hls.m3u8" || hls.Delivery != "streaming" || hls.Type != "application/x-mpegURL" {
+		t.Errorf("hls rendition = %+v", hls)
+	}
+
+	store.mu.Lock()
+	stored := len(store.files["cre_1"])
+	store.mu.Unlock()
+	if stored != len(DefaultLadder) {
+		t.Errorf("store has %d files for cre_1, want %d", stored, len(DefaultLadder))
+	}
+
+	waitState(t, q, second.ID, JobRunning)
+	if _, ok := q.Status("missing"); ok {
+		t.Error("status for unknown job")
+	}
+}
+
+func TestTranscodeQueue_ConcurrencyLimit(t *testing.T) {
+	tc := &fakeTranscoder{gate: make(chan struct{})}
+	q := NewTranscodeQueue(TranscodeConfig{Workers: 2, OutputDir: t.TempDir()}, tc, nil, nil)
+	defer q.Close()
+
+	var jobs []*TranscodeJob
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		job := testJob(id, DefaultLadder[0])
+		jobs = append(jobs, job)
+		if err := q.Enqueue(job); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	// Two run while the rest wait
+	waitState(t, q, jobs[0].ID, JobRunning)
+	waitState(t, q, jobs[1].ID, JobRunning)
+	for _, job := range jobs[2:] {
+		if s, _ := q.Status(job.ID); s.State != JobQueued {
+			t.Errorf("job %s is %s, want queued", job.CreativeID, s.State)
+		}
+	}
+	for range jobs {
+		tc.gate <- struct{}{}
+	}
+	for _, job := range jobs {
+		waitState(t, q, job.ID, JobDone)
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.maxInFlight != 2 {
+		t.Errorf("max in flight = %d, want 2", tc.maxInFlight)
+	}
+}
+
+func TestTranscodeQueue_RetriesFailures(t *testing.T) {
+	tc := &fakeTranscoder{failures: 2}
+	q := NewTranscodeQueue(TranscodeConfig{Workers: 1, MaxAttempts: 3, OutputDir: t.TempDir()}, tc, nil, nil)
+	defer q.Close()
+
+	job := testJob("cre_1", DefaultLadder[0])
+	if err := q.Enqueue(job); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if s := waitState(t, q, job.ID, JobDone); s.Attempts != 3 || s.Error != "" {
+		t.Errorf("status = %+v, want done on the third attempt", s)
+	}
+
+	// Out of attempts
+	tc.mu.Lock()
+	tc.calls, tc.failures = 0, 5
+	tc.mu.Unlock()
+	job = testJob("cre_2", DefaultLadder[0])
+	if err := q.Enqueue(job); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	s := waitState(t, q, job.ID, JobFailed)
+	if s.Attempts != 3 || s.Error == "" {
+		t.Errorf("status = %+v, want failed after 3 attempts", s)
+	}
+}
+
+func TestTranscodeQueue_Backpressure(t *testing.T) {
+	tc := &fakeTranscoder{gate: make(chan struct{})}
+	q := NewTranscodeQueue(TranscodeConfig{Workers: 1, Backlog: 1, OutputDir: t.TempDir()}, tc, nil, nil)
+
+	running := testJob("a", DefaultLadder[0])
+	q.Enqueue(running)
+	waitState(t, q, running.ID, JobRunning)
+	if err := q.Enqueue(testJob("b")); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := q.Enqueue(testJob("c")); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Enqueue past the backlog = %v, want %v", err, ErrQueueFull)
+	}
+
+	q.Close()
+	if err := q.Enqueue(testJob("d")); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Enqueue after Close = %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestFFmpegArgs(t *testing.T) {
+	args := ffmpegArgs("in.mov", Rendition{Name: "l", Container: "webm", Width: 1280, Height: 720, Bitrate: 2500}, "out/x_l.webm")
+	want := map[string]string{"-i": "in.mov", "-b:v": "2500k", "-c:v": "libvpx-vp9"}
+	for i := 0; i < len(args)-1; i++ {
+		if v, ok := want[args[i]]; ok {
+			if args[i+1] != v {
+				t.Errorf("%s %s, want %s", args[i], args[i+1], v)
+			}
+			delete(want, args[i])
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("missing args %v in %v", want, args)
+	}
+	if args[len(args)-1] != "out/x_l.webm" {
+		t.Errorf("output = %s", args[len(args)-1])
+	}
+}
@@ -0,0 +1,93 @@
+package creative
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rendition is one output of the transcoding ladder
+type Rendition struct {
+	Name      string
+	Container string // "mp4", "webm" or "hls"
+	Width     int
+	Height    int
+	Bitrate   int // kbps, 0 for adaptive HLS
+}
+
+// DefaultLadder mirrors the media files the VAST handler advertises for the
+// s/m/l/xl layouts, with WebM copies and an HLS variant
+var DefaultLadder = []Rendition{
+	{Name: "s", Container: "mp4", Width: 320, Height: 180, Bitrate: 500},
+	{Name: "m", Container: "mp4", Width: 640, Height: 360, Bitrate: 1000},
+	{Name: "l", Container: "mp4", Width: 1280, Height: 720, Bitrate: 2500},
+	{Name: "xl", Container: "mp4", Width: 1920, Height: 1080, Bitrate: 5000},
+	{Name: "s", Container: "webm", Width: 320, Height: 180, Bitrate: 500},
+	{Name: "m", Container: "webm", Width: 640, Height: 360, Bitrate: 1000},
+	{Name: "l", Container: "webm", Width: 1280, Height: 720, Bitrate: 2500},
+	{Name: "xl", Container: "webm", Width: 1920, Height: 1080, Bitrate: 5000},
+	{Name: "hls", Container: "hls", Width: 1920, Height: 1080},
+}
+
+// TranscodeJob asks for a validated creative to be transcoded
+type TranscodeJob struct {
+	ID         string
+	CreativeID string
+	SourcePath string
+	Metadata   *Metadata
+	Renditions []Rendition
+	Created    time.Time
+}
+
+// NewTranscodeJob creates a job for the default ladder
+func NewTranscodeJob(creativeID, sourcePath string, meta *Metadata) *TranscodeJob {
+	return &TranscodeJob{
+		ID:         uuid.New().String(),
+		CreativeID: creativeID,
+		SourcePath: sourcePath,
+		Metadata:   meta,
+		Renditions: DefaultLadder,
+		Created:    time.Now(),
+	}
+}
+
+// TranscodeEnqueuer accepts transcoding jobs
+type TranscodeEnqueuer interface {
+	Enqueue(job *TranscodeJob) error
+}
+
+// MemoryQueue holds jobs in memory for a worker to drain
+type MemoryQueue struct {
+	mu   sync.Mutex
+	jobs []*TranscodeJob
+}
+
+// NewMemoryQueue creates an empty queue
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{}
+}
+
+// Enqueue implements TranscodeEnqueuer
+func (q *MemoryQueue) Enqueue(job *TranscodeJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+// Drain removes and returns all queued jobs
+func (q *MemoryQueue) Drain() []*TranscodeJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := q.jobs
+	q.jobs = nil
+	return jobs
+}
+
+// Len returns the number of queued jobs
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
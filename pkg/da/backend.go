@@ -0,0 +1,49 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package da
+
+import (
+	"sync"
+
+	"github.com/luxfi/adx/pkg/ids"
+)
+
+// Backend persists blob data for a DA layer. Remote layers implement it
+// against their network; the local layer keeps blobs in memory.
+type Backend interface {
+	Put(ref *BlobReference, data []byte) error
+	Get(ref *BlobReference) ([]byte, error)
+}
+
+// LocalBackend is an in-memory Backend, shared by nodes in one process
+type LocalBackend struct {
+	mu    sync.RWMutex
+	blobs map[ids.ID][]byte
+}
+
+// NewLocalBackend creates an empty in-memory backend
+func NewLocalBackend() *LocalBackend {
+	return &LocalBackend{blobs: make(map[ids.ID][]byte)}
+}
+
+// Put stores a copy of data under the reference's blob ID
+func (b *LocalBackend) Put(ref *BlobReference, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.blobs[ref.BlobID] = append([]byte(nil), data...)
+	return nil
+}
+
+// Get returns the data stored under the reference's blob ID
+func (b *LocalBackend) Get(ref *BlobReference) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data, exists := b.blobs[ref.BlobID]
+	if !exists {
+		return nil, ErrBlobNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
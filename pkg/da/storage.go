@@ -5,6 +5,7 @@ package da
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"sync"
 	"time"
@@ -19,6 +20,7 @@ var (
 	ErrBlobNotFound      = errors.New("blob not found")
 	ErrBlobTooLarge      = errors.New("blob exceeds maximum size")
 	ErrInvalidCommitment = errors.New("invalid blob commitment")
+	ErrNoTranscript      = errors.New("no transcript stored for auction")
 )
 
 // DALayer represents the data availability layer type
@@ -44,9 +46,13 @@ type DataAvailability struct {
 	layer DALayer
 
 	// Storage backends
+	backend Backend
 	blobs   map[ids.ID]*Blob
 	commits map[ids.ID][]byte
 
+	// Auction transcripts by auction ID
+	transcripts map[ids.ID]*BlobReference
+
 	// Metrics
 	stored    uint64
 	retrieved uint64
@@ -64,13 +70,26 @@ type Blob struct {
 	Layer      DALayer
 }
 
-// NewDataAvailability creates a new DA manager
+// NewDataAvailability creates a new DA manager. The local layer keeps blobs
+// in a LocalBackend.
 func NewDataAvailability(layer DALayer, logger log.Logger) *DataAvailability {
+	var backend Backend
+	if layer == DALayerLocal {
+		backend = NewLocalBackend()
+	}
+	return NewDataAvailabilityWithBackend(layer, backend, logger)
+}
+
+// NewDataAvailabilityWithBackend creates a DA manager that persists blobs
+// to backend
+func NewDataAvailabilityWithBackend(layer DALayer, backend Backend, logger log.Logger) *DataAvailability {
 	return &DataAvailability{
-		layer:   layer,
-		blobs:   make(map[ids.ID]*Blob),
-		commits: make(map[ids.ID][]byte),
-		log:     logger,
+		layer:       layer,
+		backend:     backend,
+		blobs:       make(map[ids.ID]*Blob),
+		commits:     make(map[ids.ID][]byte),
+		transcripts: make(map[ids.ID]*BlobReference),
+		log:         logger,
 	}
 }
 
@@ -80,9 +99,6 @@ func (da *DataAvailability) StoreBlob(data []byte) (*BlobReference, error) {
 		return nil, ErrBlobTooLarge
 	}
 
-	da.mu.Lock()
-	defer da.mu.Unlock()
-
 	// Create blob ID
 	blobID := ids.GenerateTestID()
 
@@ -103,6 +119,12 @@ func (da *DataAvailability) StoreBlob(data []byte) (*BlobReference, error) {
 		ref = da.storeLocal(blobID, data, commitment)
 	}
 
+	if da.backend != nil {
+		if err := da.backend.Put(ref, data); err != nil {
+			return nil, err
+		}
+	}
+
 	// Store locally for caching
 	blob := &Blob{
 		ID:         blobID,
@@ -113,6 +135,9 @@ func (da *DataAvailability) StoreBlob(data []byte) (*BlobReference, error) {
 		Layer:      da.layer,
 	}
 
+	da.mu.Lock()
+	defer da.mu.Unlock()
+
 	da.blobs[blobID] = blob
 	da.commits[blobID] = commitment
 	da.stored++
@@ -122,46 +147,85 @@ func (da *DataAvailability) StoreBlob(data []byte) (*BlobReference, error) {
 	return ref, nil
 }
 
-// RetrieveBlob retrieves data from the DA layer
+// RetrieveBlob retrieves data from the DA layer and verifies it against the
+// reference's commitment
 func (da *DataAvailability) RetrieveBlob(ref *BlobReference) ([]byte, error) {
+	data, err := da.fetch(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify commitment
+	if !da.verifyCommitment(data, ref.Commitment) {
+		return nil, ErrInvalidCommitment
+	}
+
+	da.mu.Lock()
+	da.retrieved++
+	da.mu.Unlock()
+
+	return data, nil
+}
+
+// fetch returns a blob from the local cache, the backend or its DA layer
+func (da *DataAvailability) fetch(ref *BlobReference) ([]byte, error) {
 	da.mu.RLock()
 	defer da.mu.RUnlock()
 
 	// Check local cache first
 	if blob, exists := da.blobs[ref.BlobID]; exists {
 		if time.Now().Before(blob.Expiry) {
-			da.retrieved++
 			return blob.Data, nil
 		}
 	}
 
-	// Retrieve from DA layer
-	var data []byte
-	var err error
+	if da.backend != nil {
+		return da.backend.Get(ref)
+	}
 
+	// Retrieve from DA layer
 	switch ref.Layer {
 	case DALayerEIP4844:
-		data, err = da.retrieveEIP4844(ref)
+		return da.retrieveEIP4844(ref)
 	case DALayerCelestia:
-		data, err = da.retrieveCelestia(ref)
+		return da.retrieveCelestia(ref)
 	case DALayerIPFS:
-		data, err = da.retrieveIPFS(ref)
+		return da.retrieveIPFS(ref)
 	default:
-		data, err = da.retrieveLocal(ref)
+		return da.retrieveLocal(ref)
 	}
+}
 
+// StoreTranscript stores an auction's sealed transcript and indexes it by
+// auction ID
+func (da *DataAvailability) StoreTranscript(auctionID ids.ID, transcript []byte) (*BlobReference, error) {
+	ref, err := da.StoreBlob(transcript)
 	if err != nil {
 		return nil, err
 	}
 
-	// Verify commitment
-	if !da.verifyCommitment(data, ref.Commitment) {
-		return nil, ErrInvalidCommitment
-	}
+	da.mu.Lock()
+	da.transcripts[auctionID] = ref
+	da.mu.Unlock()
 
-	da.retrieved++
+	return ref, nil
+}
 
-	return data, nil
+// RetrieveTranscript fetches an auction's transcript, verified against its
+// content hash
+func (da *DataAvailability) RetrieveTranscript(auctionID ids.ID) ([]byte, *BlobReference, error) {
+	da.mu.RLock()
+	ref, exists := da.transcripts[auctionID]
+	da.mu.RUnlock()
+	if !exists {
+		return nil, nil, ErrNoTranscript
+	}
+
+	data, err := da.RetrieveBlob(ref)
+	if err != nil {
+		return nil, ref, err
+	}
+	return data, ref, nil
 }
 
 // BlobReference points to data in the DA layer
@@ -297,6 +361,27 @@ func (da *DataAvailability) createNMTRoot(data []byte) []byte {
 	return hashing.ComputeHash256(append(namespace, data...))
 }
 
+// Ping round-trips a probe blob through the backend, for readiness
+// checks. Layers without a backend have nothing to check.
+func (da *DataAvailability) Ping(ctx context.Context) error {
+	if da.backend == nil {
+		return nil
+	}
+	probe := []byte("adx-da-probe")
+	ref := &BlobReference{BlobID: ids.ID(hashing.SHA256(probe)), Layer: da.layer}
+	if err := da.backend.Put(ref, probe); err != nil {
+		return err
+	}
+	data, err := da.backend.Get(ref)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, probe) {
+		return ErrInvalidCommitment
+	}
+	return nil
+}
+
 // GetMetrics returns DA metrics
 func (da *DataAvailability) GetMetrics() DAMetrics {
 	da.mu.RLock()
@@ -104,3 +104,14 @@ func (e *Engine) BurnAsset(assetID, account string, amount decimal.Decimal) erro
 
 	return nil
 }
+
+// BurnSupply removes every account's tokens of an asset, returning how
+// many were burned
+func (e *Engine) BurnSupply(assetID string) decimal.Decimal {
+	burned := decimal.Zero
+	for account, balance := range e.balances[assetID] {
+		burned = burned.Add(balance)
+		delete(e.balances[assetID], account)
+	}
+	return burned
+}
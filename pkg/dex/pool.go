@@ -0,0 +1,83 @@
+package dex
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrNoLiquidity is returned when no pool pairs two assets
+	ErrNoLiquidity = errors.New("no liquidity path")
+
+	// ErrSlippage is returned when a swap would pay out less than the
+	// caller's minimum
+	ErrSlippage = errors.New("slippage exceeds tolerance")
+)
+
+// poolAccount is the account holding a pool's reserves
+func poolAccount(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("pool:%s/%s", a, b)
+}
+
+// CreatePool opens a constant-product pool between two assets, moving the
+// initial reserves from provider
+func (e *Engine) CreatePool(provider, assetA, assetB string, amountA, amountB decimal.Decimal) error {
+	if assetA == assetB {
+		return fmt.Errorf("pool needs two distinct assets")
+	}
+	pool := poolAccount(assetA, assetB)
+	if e.GetBalance(assetA, pool).IsPositive() || e.GetBalance(assetB, pool).IsPositive() {
+		return fmt.Errorf("pool %s already exists", pool)
+	}
+	if err := e.TransferAsset(assetA, provider, pool, amountA); err != nil {
+		return err
+	}
+	if err := e.TransferAsset(assetB, provider, pool, amountB); err != nil {
+		e.TransferAsset(assetA, pool, provider, amountA)
+		return err
+	}
+	return nil
+}
+
+// Quote returns what swapping amountIn of assetIn for assetOut would pay
+// and the pool's spot price of assetIn in assetOut before the swap
+func (e *Engine) Quote(assetIn, assetOut string, amountIn decimal.Decimal) (amountOut, spot decimal.Decimal, err error) {
+	pool := poolAccount(assetIn, assetOut)
+	reserveIn, reserveOut := e.GetBalance(assetIn, pool), e.GetBalance(assetOut, pool)
+	if !reserveIn.IsPositive() || !reserveOut.IsPositive() {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("%w: %s to %s", ErrNoLiquidity, assetIn, assetOut)
+	}
+	// x * y = k
+	amountOut = reserveOut.Mul(amountIn).Div(reserveIn.Add(amountIn))
+	return amountOut, reserveOut.Div(reserveIn), nil
+}
+
+// Swap trades amountIn of account's assetIn for assetOut through their
+// pool, failing if it would pay less than minOut
+func (e *Engine) Swap(account, assetIn, assetOut string, amountIn, minOut decimal.Decimal) (decimal.Decimal, error) {
+	if amountIn.LessThanOrEqual(decimal.Zero) {
+		return decimal.Zero, fmt.Errorf("amount must be positive")
+	}
+	amountOut, _, err := e.Quote(assetIn, assetOut, amountIn)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if amountOut.LessThan(minOut) {
+		return decimal.Zero, fmt.Errorf("%w: %s out, minimum %s", ErrSlippage, amountOut, minOut)
+	}
+
+	pool := poolAccount(assetIn, assetOut)
+	if err := e.TransferAsset(assetIn, account, pool, amountIn); err != nil {
+		return decimal.Zero, err
+	}
+	if err := e.TransferAsset(assetOut, pool, account, amountOut); err != nil {
+		e.TransferAsset(assetIn, pool, account, amountIn)
+		return decimal.Zero, err
+	}
+	return amountOut, nil
+}
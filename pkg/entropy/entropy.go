@@ -0,0 +1,79 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package entropy is the boundary between the exchange and its sources of
+// randomness. Production code draws from Crypto; tests and proof replays
+// inject a Seeded source so the same seed yields the same outcomes.
+package entropy
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/rand/v2"
+	"sync"
+)
+
+// Source is a source of random bytes and numbers, safe for concurrent use
+type Source interface {
+	io.Reader
+	Uint64() uint64
+	Float64() float64 // In [0, 1)
+	IntN(n int) int   // In [0, n); panics if n <= 0
+}
+
+// Crypto returns the source backed by crypto/rand
+func Crypto() Source {
+	return cryptoSource
+}
+
+// Seeded returns a deterministic source: two sources with the same seed
+// produce the same sequence. It must not be used where randomness has to be
+// unpredictable.
+func Seeded(seed uint64) Source {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], seed)
+	src := &lockedChaCha{c: rand.NewChaCha8(key)}
+	return &source{Rand: rand.New(src), read: src.Read}
+}
+
+var cryptoSource Source = &source{Rand: rand.New(cryptoUint64{}), read: cryptorand.Read}
+
+// source pairs a rand.Rand, which draws only on its rand.Source, with a
+// byte reader over the same entropy
+type source struct {
+	*rand.Rand
+	read func([]byte) (int, error)
+}
+
+func (s *source) Read(p []byte) (int, error) {
+	return s.read(p)
+}
+
+// cryptoUint64 is a rand.Source over crypto/rand
+type cryptoUint64 struct{}
+
+func (cryptoUint64) Uint64() uint64 {
+	var b [8]byte
+	cryptorand.Read(b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// lockedChaCha serializes a ChaCha8 generator, which isn't safe for
+// concurrent use
+type lockedChaCha struct {
+	mu sync.Mutex
+	c  *rand.ChaCha8
+}
+
+func (l *lockedChaCha) Uint64() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.c.Uint64()
+}
+
+func (l *lockedChaCha) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.c.Read(p)
+}
@@ -0,0 +1,40 @@
+package entropy
+
+import (
+	"bytes"
+	"testing"
+)
+
+// draw takes a mix of values from src
+func draw(src Source) []byte {
+	out := make([]byte, 16)
+	src.Read(out)
+	for i := 0; i < 8; i++ {
+		out = append(out, byte(src.IntN(256)), byte(src.Uint64()), byte(src.Float64()*256))
+	}
+	return out
+}
+
+func TestSeeded(t *testing.T) {
+	a, b := draw(Seeded(42)), draw(Seeded(42))
+	if !bytes.Equal(a, b) {
+		t.Errorf("same seed, different sequences:\n%x\n%x", a, b)
+	}
+	if c := draw(Seeded(43)); bytes.Equal(a, c) {
+		t.Error("different seeds, same sequence")
+	}
+}
+
+func TestCrypto(t *testing.T) {
+	if a, b := draw(Crypto()), draw(Crypto()); bytes.Equal(a, b) {
+		t.Errorf("crypto source repeated itself: %x", a)
+	}
+	for i := 0; i < 1000; i++ {
+		if f := Crypto().Float64(); f < 0 || f >= 1 {
+			t.Fatalf("Float64 = %v, want [0, 1)", f)
+		}
+		if n := Crypto().IntN(7); n < 0 || n >= 7 {
+			t.Fatalf("IntN(7) = %d", n)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// Package fraud scores auction traffic for the abuse patterns the attack
+// simulator exercises: bid floods, replayed bids, conflicting bids from one
+// bidder and self-bidding on low-reserve auctions.
+package fraud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of traffic an Event records
+type EventType string
+
+const (
+	EventBid            EventType = "bid"
+	EventAuctionCreated EventType = "auction_created"
+)
+
+// Event is one request seen by the auction API
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	Source    string // Client address or API key
+	AuctionID string
+	BidderID  string
+	Amount    uint64 // Bids
+	Reserve   uint64 // Auction creation
+	Nonce     uint64
+	Payload   []byte // Sealed bid and signature, for replay detection
+}
+
+// SignalType names an anomaly
+type SignalType string
+
+const (
+	SignalFlood     SignalType = "flood"
+	SignalReplay    SignalType = "replay"
+	SignalConflict  SignalType = "conflicting_bids"
+	SignalArbitrage SignalType = "arbitrage"
+)
+
+// Signal is an anomaly attributed to an entity: a source or a bidder
+type Signal struct {
+	Type   SignalType
+	Entity string
+	Time   time.Time
+	Detail string
+}
+
+// Config tunes the analyzer. Zero fields take DefaultConfig's values.
+type Config struct {
+	FloodWindow    time.Duration // Span FloodThreshold is counted over
+	FloodThreshold int           // Events per source per FloodWindow
+
+	ReplayWindow time.Duration // How long bid fingerprints are remembered
+
+	LowReserve      uint64        // Auctions at or below this reserve are watched for self-bidding
+	ArbitrageWindow time.Duration // How soon after creation a self-bid counts
+
+	// Weights are each signal's contribution to an entity's score. Scores
+	// sum the weights of signals within ScoreWindow, capped at 1.
+	Weights     map[SignalType]float64
+	ScoreWindow time.Duration
+
+	// AutoBlock blocks entities whose score reaches BlockThreshold
+	AutoBlock      bool
+	BlockThreshold float64
+}
+
+// DefaultConfig returns thresholds matched to the simulator's defaults
+func DefaultConfig() Config {
+	return Config{
+		FloodWindow:     time.Second,
+		FloodThreshold:  200,
+		ReplayWindow:    10 * time.Minute,
+		LowReserve:      500,
+		ArbitrageWindow: time.Second,
+		Weights: map[SignalType]float64{
+			SignalFlood:     0.5,
+			SignalReplay:    0.25,
+			SignalConflict:  0.5,
+			SignalArbitrage: 0.35,
+		},
+		ScoreWindow:    time.Minute,
+		BlockThreshold: 1,
+	}
+}
+
+type createdAuction struct {
+	source  string
+	reserve uint64
+	at      time.Time
+}
+
+type bidderBid struct {
+	amount uint64
+	at     time.Time
+}
+
+type scored struct {
+	at     time.Time
+	weight float64
+}
+
+// Analyzer consumes auction events and flags anomalies
+type Analyzer struct {
+	cfg Config
+
+	// OnSignal, if set, is called for every signal, outside the lock
+	OnSignal func(Signal)
+
+	mu           sync.Mutex
+	events       map[string][]time.Time // Source -> recent event times
+	lastFlood    map[string]time.Time
+	fingerprints map[[32]byte]time.Time
+	bids         map[string]map[string]bidderBid // Auction -> bidder -> first bid
+	created      map[string]createdAuction
+	scores       map[string][]scored
+	blocked      map[string]bool
+	lastPrune    time.Time
+}
+
+// NewAnalyzer creates an analyzer
+func NewAnalyzer(cfg Config) *Analyzer {
+	def := DefaultConfig()
+	if cfg.FloodWindow <= 0 {
+		cfg.FloodWindow = def.FloodWindow
+	}
+	if cfg.FloodThreshold <= 0 {
+		cfg.FloodThreshold = def.FloodThreshold
+	}
+	if cfg.ReplayWindow <= 0 {
+		cfg.ReplayWindow = def.ReplayWindow
+	}
+	if cfg.LowReserve == 0 {
+		cfg.LowReserve = def.LowReserve
+	}
+	if cfg.ArbitrageWindow <= 0 {
+		cfg.ArbitrageWindow = def.ArbitrageWindow
+	}
+	if cfg.Weights == nil {
+		cfg.Weights = def.Weights
+	}
+	if cfg.ScoreWindow <= 0 {
+		cfg.ScoreWindow = def.ScoreWindow
+	}
+	if cfg.BlockThreshold <= 0 {
+		cfg.BlockThreshold = def.BlockThreshold
+	}
+	return &Analyzer{
+		cfg:          cfg,
+		events:       make(map[string][]time.Time),
+		lastFlood:    make(map[string]time.Time),
+		fingerprints: make(map[[32]byte]time.Time),
+		bids:         make(map[string]map[string]bidderBid),
+		created:      make(map[string]createdAuction),
+		scores:       make(map[string][]scored),
+		blocked:      make(map[string]bool),
+	}
+}
+
+// Consume observes events until the channel closes or ctx is done
+func (a *Analyzer) Consume(ctx context.Context, events <-chan Event) {
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			a.Observe(e)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Observe records an event and returns the signals it raised
+func (a *Analyzer) Observe(e Event) []Signal {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	a.mu.Lock()
+	a.prune(e.Time)
+	var signals []Signal
+	signals = append(signals, a.checkFlood(e)...)
+	switch e.Type {
+	case EventAuctionCreated:
+		if e.Reserve <= a.cfg.LowReserve {
+			a.created[e.AuctionID] = createdAuction{source: e.Source, reserve: e.Reserve, at: e.Time}
+		}
+	case EventBid:
+		signals = append(signals, a.checkReplay(e)...)
+		signals = append(signals, a.checkConflict(e)...)
+		signals = append(signals, a.checkArbitrage(e)...)
+	}
+	for _, s := range signals {
+		a.score(s)
+	}
+	a.mu.Unlock()
+
+	if a.OnSignal != nil {
+		for _, s := range signals {
+			a.OnSignal(s)
+		}
+	}
+	return signals
+}
+
+// checkFlood flags a source sending more than FloodThreshold events in
+// FloodWindow, at most once per window
+func (a *Analyzer) checkFlood(e Event) []Signal {
+	if e.Source == "" {
+		return nil
+	}
+	since := e.Time.Add(-a.cfg.FloodWindow)
+	times := a.events[e.Source]
+	i := 0
+	for i < len(times) && !times[i].After(since) {
+		i++
+	}
+	times = append(times[i:], e.Time)
+	a.events[e.Source] = times
+
+	if len(times) <= a.cfg.FloodThreshold || a.lastFlood[e.Source].After(since) {
+		return nil
+	}
+	a.lastFlood[e.Source] = e.Time
+	return []Signal{{
+		Type:   SignalFlood,
+		Entity: e.Source,
+		Time:   e.Time,
+		Detail: fmt.Sprintf("%d events in %v", len(times), a.cfg.FloodWindow),
+	}}
+}
+
+// checkReplay flags a bid identical to an earlier one but for its nonce
+func (a *Analyzer) checkReplay(e Event) []Signal {
+	fp := fingerprint(e)
+	first, seen := a.fingerprints[fp]
+	if !seen {
+		a.fingerprints[fp] = e.Time
+		return nil
+	}
+	entity := e.Source
+	if entity == "" {
+		entity = e.BidderID
+	}
+	return []Signal{{
+		Type:   SignalReplay,
+		Entity: entity,
+		Time:   e.Time,
+		Detail: fmt.Sprintf("bid for auction %s first seen %v earlier", e.AuctionID, e.Time.Sub(first)),
+	}}
+}
+
+// checkConflict flags a bidder placing different bids in one sealed-bid
+// auction
+func (a *Analyzer) checkConflict(e Event) []Signal {
+	if e.AuctionID == "" || e.BidderID == "" {
+		return nil
+	}
+	bidders, ok := a.bids[e.AuctionID]
+	if !ok {
+		bidders = make(map[string]bidderBid)
+		a.bids[e.AuctionID] = bidders
+	}
+	prev, ok := bidders[e.BidderID]
+	if !ok {
+		bidders[e.BidderID] = bidderBid{amount: e.Amount, at: e.Time}
+		return nil
+	}
+	if prev.amount == e.Amount {
+		return nil
+	}
+	return []Signal{{
+		Type:   SignalConflict,
+		Entity: e.BidderID,
+		Time:   e.Time,
+		Detail: fmt.Sprintf("bid %d after %d in auction %s", e.Amount, prev.amount, e.AuctionID),
+	}}
+}
+
+// checkArbitrage flags a source bidding on a low-reserve auction it just
+// created
+func (a *Analyzer) checkArbitrage(e Event) []Signal {
+	c, ok := a.created[e.AuctionID]
+	if !ok || e.Source == "" || c.source != e.Source || e.Time.Sub(c.at) > a.cfg.ArbitrageWindow {
+		return nil
+	}
+	return []Signal{{
+		Type:   SignalArbitrage,
+		Entity: e.Source,
+		Time:   e.Time,
+		Detail: fmt.Sprintf("bid %d on own auction %s with reserve %d", e.Amount, e.AuctionID, c.reserve),
+	}}
+}
+
+// score adds a signal to its entity's score, blocking it if configured
+func (a *Analyzer) score(s Signal) {
+	a.scores[s.Entity] = append(a.scores[s.Entity], scored{at: s.Time, weight: a.cfg.Weights[s.Type]})
+	if a.cfg.AutoBlock && a.scoreAt(s.Entity, s.Time) >= a.cfg.BlockThreshold {
+		a.blocked[s.Entity] = true
+	}
+}
+
+func (a *Analyzer) scoreAt(entity string, now time.Time) float64 {
+	since := now.Add(-a.cfg.ScoreWindow)
+	total := 0.0
+	for _, s := range a.scores[entity] {
+		if s.at.After(since) {
+			total += s.weight
+		}
+	}
+	if total > 1 {
+		return 1
+	}
+	return total
+}
+
+// Score is an entity's fraud score in [0, 1] from its recent signals
+func (a *Analyzer) Score(entity string) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.scoreAt(entity, time.Now())
+}
+
+// ScoreAt is an entity's score as of now, for replayed event streams
+func (a *Analyzer) ScoreAt(entity string, now time.Time) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.scoreAt(entity, now)
+}
+
+// Blocked reports whether an entity has been auto-blocked
+func (a *Analyzer) Blocked(entity string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.blocked[entity]
+}
+
+// Unblock lifts an entity's block and clears its score
+func (a *Analyzer) Unblock(entity string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.blocked, entity)
+	delete(a.scores, entity)
+}
+
+// prune drops state older than every window, at most once per second
+func (a *Analyzer) prune(now time.Time) {
+	if now.Sub(a.lastPrune) < time.Second {
+		return
+	}
+	a.lastPrune = now
+
+	for source, times := range a.events {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) > a.cfg.FloodWindow {
+			delete(a.events, source)
+			delete(a.lastFlood, source)
+		}
+	}
+	for fp, at := range a.fingerprints {
+		if now.Sub(at) > a.cfg.ReplayWindow {
+			delete(a.fingerprints, fp)
+		}
+	}
+	for auctionID, bidders := range a.bids {
+		for bidder, b := range bidders {
+			if now.Sub(b.at) > a.cfg.ReplayWindow {
+				delete(bidders, bidder)
+			}
+		}
+		if len(bidders) == 0 {
+			delete(a.bids, auctionID)
+		}
+	}
+	for auctionID, c := range a.created {
+		if now.Sub(c.at) > a.cfg.ArbitrageWindow {
+			delete(a.created, auctionID)
+		}
+	}
+	for entity, scores := range a.scores {
+		i := 0
+		for i < len(scores) && now.Sub(scores[i].at) > a.cfg.ScoreWindow {
+			i++
+		}
+		if i == len(scores) {
+			delete(a.scores, entity)
+		} else {
+			a.scores[entity] = scores[i:]
+		}
+	}
+}
+
+// fingerprint identifies a bid by everything but its nonce and time
+func fingerprint(e Event) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(e.AuctionID))
+	h.Write([]byte{0})
+	h.Write([]byte(e.BidderID))
+	h.Write([]byte{0})
+	binary.Write(h, binary.BigEndian, e.Amount)
+	h.Write(e.Payload)
+	var fp [32]byte
+	copy(fp[:], h.Sum(nil))
+	return fp
+}
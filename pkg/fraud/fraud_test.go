@@ -0,0 +1,150 @@
+package fraud
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var t0 = time.Unix(1_700_000_000, 0)
+
+func fired(signals []Signal, typ SignalType, entity string) bool {
+	for _, s := range signals {
+		if s.Type == typ && s.Entity == entity {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFlood(t *testing.T) {
+	a := NewAnalyzer(Config{FloodThreshold: 50})
+	var signals []Signal
+	// 100 random bids per second from one source, as runFloodAttack sends
+	for i := 0; i < 100; i++ {
+		signals = append(signals, a.Observe(Event{
+			Type:      EventBid,
+			Time:      t0.Add(time.Duration(i) * 10 * time.Millisecond),
+			Source:    "10.0.0.66",
+			AuctionID: fmt.Sprintf("auction-%d", i),
+			BidderID:  fmt.Sprintf("bidder-%d", i),
+			Amount:    uint64(100 + i),
+		})...)
+	}
+	// Normal traffic from another source
+	for i := 0; i < 20; i++ {
+		signals = append(signals, a.Observe(Event{Type: EventBid, Time: t0.Add(time.Duration(i) * 50 * time.Millisecond), Source: "10.0.0.7", AuctionID: fmt.Sprintf("a-%d", i), BidderID: "b"})...)
+	}
+
+	if !fired(signals, SignalFlood, "10.0.0.66") {
+		t.Error("flood not flagged")
+	}
+	if n := len(signals); n != 1 {
+		t.Errorf("%d signals, want one flood signal per window: %+v", n, signals)
+	}
+	if fired(signals, SignalFlood, "10.0.0.7") {
+		t.Error("normal source flagged")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	a := NewAnalyzer(Config{})
+	captured := Event{Type: EventBid, Time: t0, Source: "10.0.0.1", AuctionID: "a1", BidderID: "victim", Amount: 700, Nonce: 1, Payload: []byte("sealed|sig")}
+	if s := a.Observe(captured); len(s) != 0 {
+		t.Fatalf("original bid flagged: %+v", s)
+	}
+
+	// runReplayAttack resends the captured bid with a fresh nonce
+	replayed := captured
+	replayed.Time, replayed.Source, replayed.Nonce = t0.Add(time.Second), "10.0.0.66", 99
+	if s := a.Observe(replayed); !fired(s, SignalReplay, "10.0.0.66") {
+		t.Errorf("replay not flagged: %+v", s)
+	}
+
+	// Changing the sealed payload makes it a different bid
+	other := captured
+	other.Time, other.Payload = t0.Add(2*time.Second), []byte("other|sig")
+	if s := a.Observe(other); fired(s, SignalReplay, "10.0.0.1") {
+		t.Errorf("distinct bid flagged as replay: %+v", s)
+	}
+}
+
+func TestConflictingBids(t *testing.T) {
+	a := NewAnalyzer(Config{})
+	var signals []Signal
+	// runByzantineAttack: 10000, 100 and 0 from one bidder in one auction
+	for i, amount := range []uint64{10000, 100, 0} {
+		signals = append(signals, a.Observe(Event{Type: EventBid, Time: t0.Add(time.Duration(i) * time.Millisecond), Source: "10.0.0.66", AuctionID: "a1", BidderID: "byz", Amount: amount, Nonce: uint64(i)})...)
+	}
+	if !fired(signals, SignalConflict, "byz") {
+		t.Errorf("conflicting bids not flagged: %+v", signals)
+	}
+
+	// Different bidders in one auction are fine
+	signals = nil
+	for i, bidder := range []string{"x", "y", "z"} {
+		signals = append(signals, a.Observe(Event{Type: EventBid, Time: t0, AuctionID: "a2", BidderID: bidder, Amount: uint64(100 * (i + 1))})...)
+	}
+	if len(signals) != 0 {
+		t.Errorf("honest bids flagged: %+v", signals)
+	}
+}
+
+func TestArbitrage(t *testing.T) {
+	a := NewAnalyzer(Config{})
+	// runArbitrageAttack creates a reserve-100 auction and bids 101 on it
+	a.Observe(Event{Type: EventAuctionCreated, Time: t0, Source: "10.0.0.66", AuctionID: "cheap", Reserve: 100})
+	if s := a.Observe(Event{Type: EventBid, Time: t0.Add(5 * time.Millisecond), Source: "10.0.0.66", AuctionID: "cheap", BidderID: "fresh-id", Amount: 101}); !fired(s, SignalArbitrage, "10.0.0.66") {
+		t.Errorf("self-bid not flagged: %+v", s)
+	}
+
+	// Someone else bidding, a normal reserve, or a late bid are not arbitrage
+	a.Observe(Event{Type: EventAuctionCreated, Time: t0, Source: "pub", AuctionID: "normal", Reserve: 5000})
+	for _, e := range []Event{
+		{Type: EventBid, Time: t0.Add(time.Millisecond), Source: "10.0.0.9", AuctionID: "cheap", BidderID: "b", Amount: 150},
+		{Type: EventBid, Time: t0.Add(time.Millisecond), Source: "pub", AuctionID: "normal", BidderID: "c", Amount: 6000},
+		{Type: EventBid, Time: t0.Add(time.Minute), Source: "10.0.0.66", AuctionID: "cheap", BidderID: "d", Amount: 101},
+	} {
+		if s := a.Observe(e); fired(s, SignalArbitrage, e.Source) {
+			t.Errorf("%+v flagged: %+v", e, s)
+		}
+	}
+}
+
+func TestScoreAndAutoBlock(t *testing.T) {
+	a := NewAnalyzer(Config{AutoBlock: true})
+	var got []Signal
+	a.OnSignal = func(s Signal) { got = append(got, s) }
+
+	for i := 0; i < 4; i++ {
+		a.Observe(Event{Type: EventAuctionCreated, Time: t0.Add(time.Duration(i) * time.Second), Source: "10.0.0.66", AuctionID: fmt.Sprint(i), Reserve: 100})
+		a.Observe(Event{Type: EventBid, Time: t0.Add(time.Duration(i)*time.Second + time.Millisecond), Source: "10.0.0.66", AuctionID: fmt.Sprint(i), BidderID: fmt.Sprint("b", i), Amount: 101})
+		if i == 0 {
+			if score := a.ScoreAt("10.0.0.66", t0.Add(time.Second)); score != 0.35 {
+				t.Errorf("score after one signal = %v, want 0.35", score)
+			}
+			if a.Blocked("10.0.0.66") {
+				t.Error("blocked after one signal")
+			}
+		}
+	}
+	if len(got) != 4 {
+		t.Errorf("OnSignal saw %d signals, want 4", len(got))
+	}
+	if score := a.ScoreAt("10.0.0.66", t0.Add(4*time.Second)); score != 1 {
+		t.Errorf("score = %v, want capped at 1", score)
+	}
+	if !a.Blocked("10.0.0.66") {
+		t.Error("source not auto-blocked")
+	}
+
+	// Signals age out of the score
+	if score := a.ScoreAt("10.0.0.66", t0.Add(time.Hour)); score != 0 {
+		t.Errorf("score an hour later = %v, want 0", score)
+	}
+
+	a.Unblock("10.0.0.66")
+	if a.Blocked("10.0.0.66") {
+		t.Error("still blocked after Unblock")
+	}
+}
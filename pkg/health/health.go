@@ -0,0 +1,138 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package health separates liveness from readiness for the ADX services.
+// /livez answers as long as the process is serving; /readyz runs each
+// registered dependency check and answers 503 listing the failures.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds each readiness check
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable; nil means healthy
+type Check func(ctx context.Context) error
+
+// Pinger is implemented by dependencies that can check themselves
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Report is the /readyz response body
+type Report struct {
+	Status  string            `json:"status"`
+	Checks  map[string]string `json:"checks"`
+	Failing []string          `json:"failing,omitempty"`
+}
+
+// Checker holds a service's readiness checks
+type Checker struct {
+	// Timeout bounds each check; DefaultTimeout when zero
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// New creates a checker with no checks, which is always ready
+func New() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// Add registers a named check, replacing any check with that name
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Run runs every check concurrently
+func (c *Checker) Run(ctx context.Context) Report {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[string]error, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			err := runCheck(ctx, check)
+			mu.Lock()
+			errs[name] = err
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: "ready", Checks: make(map[string]string, len(errs))}
+	for name, err := range errs {
+		if err != nil {
+			report.Checks[name] = err.Error()
+			report.Failing = append(report.Failing, name)
+		} else {
+			report.Checks[name] = "ok"
+		}
+	}
+	if len(report.Failing) > 0 {
+		report.Status = "not_ready"
+		sort.Strings(report.Failing)
+	}
+	return report
+}
+
+// runCheck gives up on a check at ctx's deadline even if it ignores ctx
+func runCheck(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Livez answers 200 while the process can serve requests
+func (c *Checker) Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz answers 200 when every check passes and 503 otherwise
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	status := http.StatusOK
+	if len(report.Failing) > 0 {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
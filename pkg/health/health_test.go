@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serve(h http.HandlerFunc) (int, Report) {
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var report Report
+	json.NewDecoder(w.Body).Decode(&report)
+	return w.Code, report
+}
+
+func TestReadyz(t *testing.T) {
+	c := New()
+	var dbErr error
+	c.Add("db", func(context.Context) error { return dbErr })
+	c.Add("cache", func(context.Context) error { return nil })
+
+	if code, report := serve(c.Readyz); code != http.StatusOK || report.Status != "ready" || report.Checks["db"] != "ok" {
+		t.Fatalf("healthy: %d %+v", code, report)
+	}
+
+	dbErr = errors.New("connection refused")
+	code, report := serve(c.Readyz)
+	if code != http.StatusServiceUnavailable || report.Status != "not_ready" {
+		t.Fatalf("unhealthy: %d %+v", code, report)
+	}
+	if len(report.Failing) != 1 || report.Failing[0] != "db" || report.Checks["db"] != "connection refused" || report.Checks["cache"] != "ok" {
+		t.Errorf("report = %+v", report)
+	}
+
+	// Liveness doesn't depend on the checks
+	if code, _ := serve(c.Livez); code != http.StatusOK {
+		t.Errorf("livez = %d", code)
+	}
+}
+
+func TestReadyz_Timeout(t *testing.T) {
+	c := New()
+	c.Timeout = 20 * time.Millisecond
+	block := make(chan struct{})
+	defer close(block)
+	c.Add("stuck", func(context.Context) error { <-block; return nil })
+
+	start := time.Now()
+	code, report := serve(c.Readyz)
+	if code != http.StatusServiceUnavailable || report.Checks["stuck"] != context.DeadlineExceeded.Error() {
+		t.Errorf("stuck check: %d %+v", code, report)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("readyz took %v", elapsed)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package jsonx is the JSON codec for the bid path. It encodes and decodes
+// with goccy/go-json, which produces the same JSON as encoding/json in well
+// under the time, and falls back to encoding/json whenever the fast
+// codec fails, so a value it can't handle costs time rather than
+// correctness. Decode reuses its read buffers across bodies.
+package jsonx
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	gojson "github.com/goccy/go-json"
+)
+
+// Codec marshals and unmarshals JSON
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Std is encoding/json
+var Std Codec = stdCodec{}
+
+// Fast is goccy/go-json
+var Fast Codec = fastCodec{}
+
+// Default is the codec Marshal and Unmarshal try first; set it to Std to
+// turn the fast path off
+var Default = Fast
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (stdCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type fastCodec struct{}
+
+func (fastCodec) Marshal(v any) ([]byte, error)      { return gojson.Marshal(v) }
+func (fastCodec) Unmarshal(data []byte, v any) error { return gojson.Unmarshal(data, v) }
+
+// Marshal encodes v with Default, retrying with encoding/json on failure
+func Marshal(v any) ([]byte, error) {
+	data, err := Default.Marshal(v)
+	if err != nil && Default != Std {
+		return Std.Marshal(v)
+	}
+	return data, err
+}
+
+// Unmarshal decodes data into v with Default, retrying with encoding/json
+// on failure so errors read as encoding/json's
+func Unmarshal(data []byte, v any) error {
+	err := Default.Unmarshal(data, v)
+	if err != nil && Default != Std {
+		return Std.Unmarshal(data, v)
+	}
+	return err
+}
+
+// maxPooledBuffer caps the read buffers kept for reuse, so one huge body
+// doesn't pin its buffer
+const maxPooledBuffer = 1 << 20
+
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// Decode reads r to the end and unmarshals it into v. It is meant for
+// request and response bodies holding one value, and unlike json.Decoder
+// rejects anything after it.
+func Decode(r io.Reader, v any) error {
+	buf := buffers.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buffers.Put(buf)
+		}
+	}()
+	buf.Reset()
+	if _, err := buf.ReadFrom(r); err != nil {
+		return err
+	}
+	// Neither codec keeps hold of the bytes it decodes
+	return Unmarshal(buf.Bytes(), v)
+}
+
+// Encode writes v to w followed by a newline, as json.Encoder does
+func Encode(w io.Writer, v any) error {
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,179 @@
+package jsonx_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/luxfi/adx/pkg/jsonx"
+	"github.com/luxfi/adx/pkg/vast"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// corpus reads the JSON lines in a testdata file
+func corpus(t testing.TB, name string) [][]byte {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines [][]byte
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		lines = append(lines, append([]byte(nil), sc.Bytes()...))
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+// differential decodes data into a new T with each codec, and encodes the
+// results, failing unless both decode to equal values and encode to the
+// same JSON. Data T can't hold must fail to decode with both.
+func differential[T any](t *testing.T, data []byte) {
+	t.Helper()
+	var std, fast T
+	stdErr, fastErr := jsonx.Std.Unmarshal(data, &std), jsonx.Fast.Unmarshal(data, &fast)
+	if (stdErr == nil) != (fastErr == nil) {
+		t.Fatalf("%T: encoding/json error %v, fast error %v", std, stdErr, fastErr)
+	}
+	if stdErr != nil {
+		return
+	}
+	if !reflect.DeepEqual(std, fast) {
+		t.Errorf("decoded differently:\nstd  %+v\nfast %+v", std, fast)
+	}
+	stdOut, err := jsonx.Std.Marshal(&std)
+	if err != nil {
+		t.Fatalf("encoding/json: %v", err)
+	}
+	fastOut, err := jsonx.Fast.Marshal(&std)
+	if err != nil {
+		t.Fatalf("fast: %v", err)
+	}
+	if !bytes.Equal(stdOut, fastOut) && !sameJSON(t, stdOut, fastOut) {
+		t.Errorf("encoded differently:\nstd  %s\nfast %s", stdOut, fastOut)
+	}
+}
+
+// sameJSON reports whether a and b decode to the same value. The codecs'
+// only known difference is the exponent of floats under 1e-6 or from 1e21,
+// e.g. 1e-7 from encoding/json is 1e-07 from the fast codec.
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestFast_MatchesEncodingJSON(t *testing.T) {
+	for _, data := range corpus(t, "bidrequests.jsonl") {
+		differential[openrtb2.BidRequest](t, data)
+		differential[vast.OpenRTBRequest](t, data)
+	}
+	for _, data := range corpus(t, "bidresponses.jsonl") {
+		differential[openrtb2.BidResponse](t, data)
+		differential[vast.OpenRTBResponse](t, data)
+	}
+}
+
+func TestEncode_MatchesEncoder(t *testing.T) {
+	var req openrtb2.BidRequest
+	if err := jsonx.Unmarshal(corpus(t, "bidrequests.jsonl")[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	var want, got bytes.Buffer
+	json.NewEncoder(&want).Encode(&req)
+	if err := jsonx.Encode(&got, &req); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want.Bytes(), got.Bytes()) {
+		t.Errorf("Encode = %s, want %s", got.Bytes(), want.Bytes())
+	}
+}
+
+func TestUnmarshal_ErrorsAsEncodingJSON(t *testing.T) {
+	var req openrtb2.BidRequest
+	err := jsonx.Unmarshal([]byte(`{"id":1}`), &req)
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("err = %T %v, want encoding/json's *UnmarshalTypeError", err, err)
+	}
+}
+
+func benchmarkUnmarshal(b *testing.B, c jsonx.Codec) {
+	data := corpus(b, "bidrequests.jsonl")[0]
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		var req openrtb2.BidRequest
+		if err := c.Unmarshal(data, &req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkMarshal(b *testing.B, c jsonx.Codec) {
+	var resp openrtb2.BidResponse
+	if err := json.Unmarshal(corpus(b, "bidresponses.jsonl")[0], &resp); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := c.Marshal(&resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The bid handler's path: a request body decoded, a response encoded
+
+func BenchmarkBidHandler_EncodingJSON(b *testing.B) {
+	req, resp := corpus(b, "bidrequests.jsonl")[0], corpus(b, "bidresponses.jsonl")[0]
+	var out openrtb2.BidResponse
+	json.Unmarshal(resp, &out)
+	b.ReportAllocs()
+	for b.Loop() {
+		var in openrtb2.BidRequest
+		if err := json.NewDecoder(bytes.NewReader(req)).Decode(&in); err != nil {
+			b.Fatal(err)
+		}
+		if err := json.NewEncoder(io.Discard).Encode(&out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBidHandler_JSONX(b *testing.B) {
+	req, resp := corpus(b, "bidrequests.jsonl")[0], corpus(b, "bidresponses.jsonl")[0]
+	var out openrtb2.BidResponse
+	json.Unmarshal(resp, &out)
+	b.ReportAllocs()
+	for b.Loop() {
+		var in openrtb2.BidRequest
+		if err := jsonx.Decode(bytes.NewReader(req), &in); err != nil {
+			b.Fatal(err)
+		}
+		if err := jsonx.Encode(io.Discard, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalBidRequest_Std(b *testing.B)  { benchmarkUnmarshal(b, jsonx.Std) }
+func BenchmarkUnmarshalBidRequest_Fast(b *testing.B) { benchmarkUnmarshal(b, jsonx.Fast) }
+func BenchmarkMarshalBidResponse_Std(b *testing.B)   { benchmarkMarshal(b, jsonx.Std) }
+func BenchmarkMarshalBidResponse_Fast(b *testing.B)  { benchmarkMarshal(b, jsonx.Fast) }
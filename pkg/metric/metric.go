@@ -21,6 +21,13 @@ type Metrics struct {
 	DAStored    metrics.Counter
 	DARetrieved metrics.Counter
 
+	// Escrow metrics
+	CampaignPace metrics.GaugeVec
+
+	// Floor metrics
+	ReserveCPM          metrics.GaugeVec
+	ReserveRevenueDelta metrics.GaugeVec
+
 	// Network metrics
 	PeersConnected metrics.Gauge
 	BlocksCreated  metrics.Counter
@@ -55,6 +62,25 @@ func NewMetrics() (*Metrics, error) {
 	m.DAStored = metricsInstance.NewCounter("da_stored_total", "Total blobs stored to DA layer")
 	m.DARetrieved = metricsInstance.NewCounter("da_retrieved_total", "Total blobs retrieved from DA layer")
 
+	// Create escrow metrics
+	m.CampaignPace = metricsInstance.NewGaugeVec(
+		"escrow_campaign_pace_ratio",
+		"Committed spend over the pacing target by campaign; 1 is on pace",
+		[]string{"campaign"},
+	)
+
+	// Create floor metrics
+	m.ReserveCPM = metricsInstance.NewGaugeVec(
+		"rtb_reserve_cpm",
+		"Optimized reserve price by placement",
+		[]string{"placement"},
+	)
+	m.ReserveRevenueDelta = metricsInstance.NewGaugeVec(
+		"rtb_reserve_revenue_delta_ratio",
+		"Revenue per auction at the optimized reserve relative to the initial reserve, by placement",
+		[]string{"placement"},
+	)
+
 	// Create network metrics
 	m.PeersConnected = metricsInstance.NewGauge("network_peers_connected", "Number of connected peers")
 	m.BlocksCreated = metricsInstance.NewCounter("consensus_blocks_created_total", "Total number of blocks created")
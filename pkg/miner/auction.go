@@ -0,0 +1,111 @@
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MessageAuctionResult is the exchange message type announcing that an
+// auction the miner was matched to has closed
+const MessageAuctionResult = "auction_result"
+
+// DefaultServeWindow is how long after an auction closes its winner may
+// be served
+const DefaultServeWindow = 5 * time.Minute
+
+// ErrCreativeUnavailable is returned for an auction result whose creative
+// is neither cached nor included in the message
+var ErrCreativeUnavailable = errors.New("creative unavailable")
+
+// AuctionResult is a closed auction's winning creative and how to serve it
+type AuctionResult struct {
+	AuctionID  string    `json:"auction_id"`
+	ImpID      string    `json:"imp_id"`
+	CreativeID string    `json:"creative_id"`
+	Markup     string    `json:"adm,omitempty"` // Omitted when the miner already has the creative
+	W          int64     `json:"w,omitempty"`
+	H          int64     `json:"h,omitempty"`
+	ServeBy    time.Time `json:"serve_by"`
+}
+
+// AuctionResultMessage is the auction close notice sent to a miner
+type AuctionResultMessage struct {
+	Type string `json:"type"`
+	AuctionResult
+}
+
+// Get returns a cached ad
+func (c *AdCache) Get(id string) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	data, ok := c.ads[id]
+	return data, ok
+}
+
+// Put caches an ad, evicting others to make room. It reports false if the
+// ad is larger than the whole cache.
+func (c *AdCache) Put(id string, data []byte) bool {
+	size := int64(len(data))
+	if size > c.maxSize {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.ads[id]; ok {
+		c.used -= int64(len(old))
+		delete(c.ads, id)
+	}
+	for k, v := range c.ads {
+		if c.used+size <= c.maxSize {
+			break
+		}
+		c.used -= int64(len(v))
+		delete(c.ads, k)
+	}
+	c.ads[id] = data
+	c.used += size
+	return true
+}
+
+// handleAuctionResult caches the winning creative so it's ready when the
+// ad is requested, and remembers which creative serves the auction
+func (m *HomeMiner) handleAuctionResult(r AuctionResult) error {
+	if r.AuctionID == "" || r.CreativeID == "" {
+		return fmt.Errorf("auction result %q: missing auction or creative ID", r.AuctionID)
+	}
+	if _, ok := m.AdCache.Get(r.CreativeID); !ok {
+		if r.Markup == "" {
+			return fmt.Errorf("auction %s: %w: %s", r.AuctionID, ErrCreativeUnavailable, r.CreativeID)
+		}
+		if !m.AdCache.Put(r.CreativeID, []byte(r.Markup)) {
+			return fmt.Errorf("auction %s: creative %s exceeds the cache", r.AuctionID, r.CreativeID)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if m.results == nil {
+		m.results = make(map[string]AuctionResult)
+	}
+	for id, prev := range m.results {
+		if now.After(prev.ServeBy) {
+			delete(m.results, id)
+		}
+	}
+	m.results[r.AuctionID] = r
+	return nil
+}
+
+// takeResult returns, and forgets, the creative prepared for an auction
+func (m *HomeMiner) takeResult(auctionID string) ([]byte, bool) {
+	m.mu.Lock()
+	r, ok := m.results[auctionID]
+	delete(m.results, auctionID)
+	m.mu.Unlock()
+	if !ok || time.Now().After(r.ServeBy) {
+		return nil, false
+	}
+	return m.AdCache.Get(r.CreativeID)
+}
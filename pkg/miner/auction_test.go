@@ -0,0 +1,58 @@
+package miner
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleAuctionResult_PreparesCreative(t *testing.T) {
+	m := NewHomeMiner(&Config{WalletAddress: "0xABC"}, TunnelConfig{Type: TunnelDirectIP})
+	adm := `<VAST version="4.0"><Ad id="cr-1"></Ad></VAST>`
+	msg, _ := json.Marshal(AuctionResultMessage{
+		Type: MessageAuctionResult,
+		AuctionResult: AuctionResult{
+			AuctionID: "auc-1", ImpID: "1", CreativeID: "cr-1", Markup: adm,
+			ServeBy: time.Now().Add(time.Minute),
+		},
+	})
+	if err := m.HandleMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := m.AdCache.Get("cr-1"); !ok || string(data) != adm {
+		t.Fatalf("cached creative = %q, %v", data, ok)
+	}
+
+	// A later auction for the same creative needn't resend it
+	if err := m.handleAuctionResult(AuctionResult{AuctionID: "auc-2", CreativeID: "cr-1", ServeBy: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	err := m.handleAuctionResult(AuctionResult{AuctionID: "auc-3", CreativeID: "cr-2", ServeBy: time.Now().Add(time.Minute)})
+	if !errors.Is(err, ErrCreativeUnavailable) {
+		t.Errorf("uncached creative without markup: err = %v", err)
+	}
+
+	serve := func(id string) string {
+		w := httptest.NewRecorder()
+		m.serveAd(w, httptest.NewRequest(http.MethodGet, "/ad?id="+id, nil))
+		return w.Body.String()
+	}
+	if body := serve("auc-2"); body != adm {
+		t.Errorf("auc-2 served %q, want the prepared creative", body)
+	}
+	// Each result serves once
+	if body := serve("auc-2"); !strings.Contains(body, "<VAST") || body == adm {
+		t.Errorf("auc-2 served twice: %q", body)
+	}
+
+	if err := m.handleAuctionResult(AuctionResult{AuctionID: "auc-4", CreativeID: "cr-1", ServeBy: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if body := serve("auc-4"); body == adm {
+		t.Error("auction result served past its ServeBy")
+	}
+}
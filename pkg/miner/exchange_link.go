@@ -0,0 +1,195 @@
+package miner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MessageImpressions is the miner message reporting served impressions
+const MessageImpressions = "impressions"
+
+// ImpressionsMessage reports a batch of served impressions to the exchange
+type ImpressionsMessage struct {
+	Type    string             `json:"type"`
+	Reports []ImpressionReport `json:"reports"`
+}
+
+const (
+	// maxRedialDelay caps the backoff between attempts to reach the exchange
+	maxRedialDelay = 30 * time.Second
+
+	// closeTimeout is how long Close waits for the exchange to answer its
+	// close frame before dropping the connection
+	closeTimeout = 2 * time.Second
+)
+
+// WSLink is an ExchangeLink over the exchange's miner WebSocket. It
+// redials whenever the connection drops, and passes every message the
+// exchange sends to the miner's HandleMessage.
+type WSLink struct {
+	url    string
+	handle func([]byte) error
+	dialer *websocket.Dialer
+
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	closed bool
+
+	writeMu sync.Mutex // gorilla/websocket allows one writer at a time
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewWSLink connects m to the exchange's WebSocket at exchangeURL,
+// identifying it by ID and wallet. It returns at once; until the first
+// connection is made, reports fail with ErrNotConnected.
+func NewWSLink(exchangeURL string, m *HomeMiner) (*WSLink, error) {
+	u, err := url.Parse(exchangeURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, fmt.Errorf("exchange URL %q is not ws(s)", exchangeURL)
+	}
+	q := u.Query()
+	q.Set("miner_id", m.ID)
+	q.Set("wallet", m.WalletAddress)
+	u.RawQuery = q.Encode()
+
+	l := &WSLink{
+		url:    u.String(),
+		handle: m.HandleMessage,
+		dialer: &websocket.Dialer{HandshakeTimeout: 10 * time.Second},
+		done:   make(chan struct{}),
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	go l.run()
+	return l, nil
+}
+
+// run keeps a connection to the exchange open, reading its messages,
+// until Close
+func (l *WSLink) run() {
+	defer close(l.done)
+	delay := time.Second
+	for {
+		conn, _, err := l.dialer.DialContext(l.ctx, l.url, nil)
+		if err != nil {
+			if l.ctx.Err() != nil {
+				return
+			}
+			slog.Warn("exchange unreachable", "error", err, "retry", delay)
+			select {
+			case <-time.After(delay):
+				delay = min(2*delay, maxRedialDelay)
+				continue
+			case <-l.ctx.Done():
+				return
+			}
+		}
+		delay = time.Second
+
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			conn.Close()
+			return
+		}
+		l.conn = conn
+		l.mu.Unlock()
+
+		l.read(conn)
+
+		l.mu.Lock()
+		l.conn = nil
+		closed := l.closed
+		l.mu.Unlock()
+		conn.Close()
+		if closed {
+			return
+		}
+	}
+}
+
+// read handles the exchange's messages until the connection ends
+func (l *WSLink) read(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.Warn("exchange connection lost", "error", err)
+			}
+			return
+		}
+		if err := l.handle(data); err != nil {
+			slog.Warn("exchange message not handled", "error", err)
+		}
+	}
+}
+
+// ReportImpressions implements ExchangeLink
+func (l *WSLink) ReportImpressions(ctx context.Context, reports []ImpressionReport) error {
+	l.mu.Lock()
+	conn := l.conn
+	l.mu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultReportInterval)
+	}
+	conn.SetWriteDeadline(deadline)
+	return conn.WriteJSON(ImpressionsMessage{Type: MessageImpressions, Reports: reports})
+}
+
+// Ping implements health.Pinger
+func (l *WSLink) Ping(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// Close sends the exchange a close frame and waits for it to answer,
+// dropping the connection if it hasn't within closeTimeout
+func (l *WSLink) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	conn := l.conn
+	l.mu.Unlock()
+	l.cancel()
+
+	var err error
+	if conn != nil {
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "miner stopping")
+		l.writeMu.Lock()
+		err = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+		l.writeMu.Unlock()
+	}
+	select {
+	case <-l.done:
+	case <-time.After(closeTimeout):
+		if conn != nil {
+			conn.Close()
+		}
+		<-l.done
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package miner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSLink(t *testing.T) {
+	m := NewHomeMiner(&Config{WalletAddress: "0xMINER"}, TunnelConfig{Type: TunnelDirectIP})
+	got := make(chan ImpressionsMessage, 1)
+	closed := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("miner_id") != m.ID || r.URL.Query().Get("wallet") != "0xMINER" {
+			t.Errorf("connected as %s", r.URL.RawQuery)
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		payout, _ := json.Marshal(PayoutMessage{Type: MessagePayout, Payout: Payout{Address: "0xMINER", Amount: big.NewInt(7), TxHash: "0x1", PaidAt: time.Now()}})
+		conn.WriteMessage(websocket.TextMessage, payout)
+		for {
+			var msg ImpressionsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				var ce *websocket.CloseError
+				if errors.As(err, &ce) {
+					closed <- ce.Code
+				}
+				return
+			}
+			got <- msg
+		}
+	}))
+	defer srv.Close()
+
+	link, err := NewWSLink("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for link.Ping(context.Background()) != nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	reports := []ImpressionReport{{MinerID: m.ID, AdID: "ad-1", ServedAt: time.Now()}}
+	if err := link.ReportImpressions(context.Background(), reports); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-got:
+		if msg.Type != MessageImpressions || len(msg.Reports) != 1 || msg.Reports[0].AdID != "ad-1" {
+			t.Errorf("exchange got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("report not received")
+	}
+
+	// Messages from the exchange reach the miner
+	paid := func() int {
+		m.Earnings.mu.RLock()
+		defer m.Earnings.mu.RUnlock()
+		return len(m.Earnings.Payouts)
+	}
+	for paid() == 0 && time.Now().Before(deadline.Add(time.Second)) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := paid(); n != 1 {
+		t.Errorf("miner saw %d payouts, want 1", n)
+	}
+
+	if err := link.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-closed:
+		if code != websocket.CloseNormalClosure {
+			t.Errorf("closed with %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("exchange got no close frame")
+	}
+	if err := link.ReportImpressions(context.Background(), reports); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("report after close: err = %v, want %v", err, ErrNotConnected)
+	}
+}
+
+func TestRecordImpression_BoundsBuffer(t *testing.T) {
+	m := NewHomeMiner(&Config{WalletAddress: "0xMINER"}, TunnelConfig{Type: TunnelDirectIP})
+	for i := 0; i < MaxPendingReports+5; i++ {
+		m.recordImpression("ad")
+	}
+	if n := m.PendingReports(); n != MaxPendingReports {
+		t.Errorf("%d reports buffered, want %d", n, MaxPendingReports)
+	}
+}
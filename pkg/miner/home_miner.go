@@ -1,20 +1,45 @@
 package miner
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	// "io"
 	"math/big"
+	"net"
 	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/luxfi/adx/pkg/health"
 	// "github.com/gorilla/websocket"
 	// "github.com/shopspring/decimal"
 )
 
+var (
+	// ErrStopped is returned when starting a miner that has been stopped
+	ErrStopped = errors.New("miner stopped")
+
+	// ErrTunnelDown is reported by /readyz when the tunnel process is gone
+	ErrTunnelDown = errors.New("tunnel not running")
+
+	// ErrNotConnected is reported by /readyz without an exchange connection
+	ErrNotConnected = errors.New("not connected to exchange")
+)
+
+// DefaultReportInterval is how often buffered impressions are reported
+const DefaultReportInterval = 10 * time.Second
+
+// MaxPendingReports bounds the impressions buffered while the exchange
+// can't be reached; past it the oldest are dropped
+const MaxPendingReports = 10000
+
 // TunnelType represents tunnel type
 type TunnelType string
 
@@ -41,6 +66,19 @@ type TunnelConfig struct {
 	PublicIP  string
 }
 
+// ImpressionReport records an ad the miner served
+type ImpressionReport struct {
+	MinerID  string    `json:"miner_id"`
+	AdID     string    `json:"ad_id"`
+	ServedAt time.Time `json:"served_at"`
+}
+
+// ExchangeLink is the miner's connection to the exchange
+type ExchangeLink interface {
+	ReportImpressions(ctx context.Context, reports []ImpressionReport) error
+	Close() error
+}
+
 // HomeMiner represents a home-based ad serving node
 type HomeMiner struct {
 	ID            string
@@ -55,9 +93,33 @@ type HomeMiner struct {
 	AdCache   *AdCache
 	Earnings  *MinerEarnings
 
+	// Exchange receives impression reports every ReportInterval; reports
+	// stay buffered while it is nil
+	Exchange       ExchangeLink
+	ReportInterval time.Duration
+
 	// Stats
 	stats map[string]interface{}
 	mu    sync.RWMutex
+
+	server       *http.Server
+	listener     net.Listener
+	tunnel       *exec.Cmd
+	tunnelExited chan struct{}
+	tunnelLog    *tunnelLog
+	inFlight     atomic.Int64
+	stopped      bool
+	done         chan struct{}
+	loops        sync.WaitGroup
+
+	reportMu sync.Mutex
+	pending  []ImpressionReport
+
+	// Closed auctions' results by auction ID, until served or ServeBy
+	results map[string]AuctionResult
+
+	// beforeServe runs at the start of each ad serve, for tests
+	beforeServe func()
 }
 
 // AdCache manages cached ads
@@ -74,6 +136,7 @@ type MinerEarnings struct {
 	TotalEarnings     *big.Int
 	PendingWithdrawal *big.Int
 	LastPayout        time.Time
+	Payouts           []Payout
 	mu                sync.RWMutex
 }
 
@@ -93,6 +156,7 @@ func NewHomeMiner(config *Config, tunnelConfig TunnelConfig) *HomeMiner {
 		WalletAddress: config.WalletAddress,
 		TunnelType:    tunnelConfig.Type,
 		LocalPort:     config.LocalPort,
+		PublicURL:     tunnelConfig.PublicIP,
 		AdCache:       NewAdCache(parseSize(config.CacheSize)),
 		Earnings:      NewMinerEarnings(config.WalletAddress),
 		stats:         make(map[string]interface{}),
@@ -136,88 +200,142 @@ func parseSize(s string) int64 {
 
 // Start starts the miner
 func (m *HomeMiner) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return ErrStopped
+	}
+
 	// Start tunnel
 	if err := m.setupTunnel(); err != nil {
 		return fmt.Errorf("failed to setup tunnel: %w", err)
 	}
 
 	// Start HTTP server
-	go m.startHTTPServer()
+	if err := m.startHTTPServer(); err != nil {
+		m.stopTunnel(context.Background())
+		return fmt.Errorf("failed to start HTTP server: %w", err)
+	}
 
 	// Connect to exchange
+	m.done = make(chan struct{})
+	m.loops.Add(1)
 	go m.connectToExchange()
 
 	return nil
 }
 
-// setupTunnel sets up the tunnel
-func (m *HomeMiner) setupTunnel() error {
-	switch m.TunnelType {
-	case TunnelLocalXpose:
-		return m.setupLocalXpose()
-	case TunnelNgrok:
-		return m.setupNgrok()
-	case TunnelDirectIP:
-		m.PublicURL = fmt.Sprintf("http://%s:%d", m.PublicURL, m.LocalPort)
-		return nil
-	default:
-		return fmt.Errorf("unsupported tunnel type: %s", m.TunnelType)
+// startHTTPServer starts the local HTTP server
+func (m *HomeMiner) startHTTPServer() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ad", m.track(m.serveAd))
+	mux.HandleFunc("/health", m.healthCheck)
+	ready := health.New()
+	ready.Add("tunnel", m.checkTunnel)
+	ready.Add("exchange", m.checkExchange)
+	mux.HandleFunc("/livez", ready.Livez)
+	mux.HandleFunc("/readyz", ready.Readyz)
+	mux.HandleFunc("/stats", m.getStats)
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", m.LocalPort))
+	if err != nil {
+		return err
 	}
+	m.listener = ln
+	m.server = &http.Server{Handler: mux}
+	go m.server.Serve(ln)
+	return nil
 }
 
-// setupLocalXpose sets up LocalXpose tunnel
-func (m *HomeMiner) setupLocalXpose() error {
-	cmd := exec.Command("loclx", "tunnel", "http",
-		"--port", fmt.Sprintf("%d", m.LocalPort))
+// Addr is the address the HTTP server listens on
+func (m *HomeMiner) Addr() net.Addr {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.listener == nil {
+		return nil
+	}
+	return m.listener.Addr()
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return err
+// track counts a handler's requests as in flight while they run
+func (m *HomeMiner) track(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+		h(w, r)
 	}
+}
 
-	// Parse URL from output
-	m.PublicURL = string(output) // Simplified
-	return nil
+// InFlight is the number of ad serves in progress
+func (m *HomeMiner) InFlight() int {
+	return int(m.inFlight.Load())
 }
 
-// setupNgrok sets up ngrok tunnel
-func (m *HomeMiner) setupNgrok() error {
-	cmd := exec.Command("ngrok", "http", fmt.Sprintf("%d", m.LocalPort))
+// serveAd serves an ad
+func (m *HomeMiner) serveAd(w http.ResponseWriter, r *http.Request) {
+	if m.beforeServe != nil {
+		m.beforeServe()
+	}
 
-	if err := cmd.Start(); err != nil {
-		return err
+	// Serve the creative prepared for the auction, or an empty VAST
+	id := r.URL.Query().Get("id")
+	if data, ok := m.takeResult(id); ok {
+		w.Header().Set("Content-Type", http.DetectContentType(data))
+		w.Write(data)
+	} else {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte("<VAST version=\"4.0\"></VAST>"))
 	}
 
-	// Wait for ngrok to start
-	time.Sleep(2 * time.Second)
+	m.recordImpression(id)
+}
 
-	// Get public URL from ngrok API
-	resp, err := http.Get("http://localhost:4040/api/tunnels")
-	if err != nil {
-		return err
+// recordImpression buffers an impression until the next report
+func (m *HomeMiner) recordImpression(adID string) {
+	m.reportMu.Lock()
+	defer m.reportMu.Unlock()
+	m.pending = append(m.pending, ImpressionReport{MinerID: m.ID, AdID: adID, ServedAt: time.Now()})
+	m.trimReports()
+}
+
+// trimReports drops the oldest reports over MaxPendingReports; m.reportMu
+// must be held
+func (m *HomeMiner) trimReports() {
+	if n := len(m.pending) - MaxPendingReports; n > 0 {
+		m.pending = append([]ImpressionReport(nil), m.pending[n:]...)
 	}
-	defer resp.Body.Close()
+}
 
-	// Parse response (simplified)
-	m.PublicURL = "https://example.ngrok.io"
-	return nil
+// PendingReports is the number of impressions not yet reported
+func (m *HomeMiner) PendingReports() int {
+	m.reportMu.Lock()
+	defer m.reportMu.Unlock()
+	return len(m.pending)
 }
 
-// startHTTPServer starts the local HTTP server
-func (m *HomeMiner) startHTTPServer() {
-	http.HandleFunc("/ad", m.serveAd)
-	http.HandleFunc("/health", m.healthCheck)
-	http.HandleFunc("/stats", m.getStats)
+// flushReports sends buffered impressions to the exchange. Reports the
+// exchange doesn't accept stay buffered for the next attempt.
+func (m *HomeMiner) flushReports(ctx context.Context) error {
+	if m.Exchange == nil {
+		return nil
+	}
 
-	addr := fmt.Sprintf(":%d", m.LocalPort)
-	http.ListenAndServe(addr, nil)
-}
+	m.reportMu.Lock()
+	batch := m.pending
+	m.pending = nil
+	m.reportMu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
 
-// serveAd serves an ad
-func (m *HomeMiner) serveAd(w http.ResponseWriter, r *http.Request) {
-	// Serve cached ad
-	w.Header().Set("Content-Type", "application/xml")
-	w.Write([]byte("<VAST version=\"4.0\"></VAST>"))
+	if err := m.Exchange.ReportImpressions(ctx, batch); err != nil {
+		m.reportMu.Lock()
+		m.pending = append(batch, m.pending...)
+		m.trimReports()
+		m.reportMu.Unlock()
+		return err
+	}
+	return nil
 }
 
 // healthCheck returns health status
@@ -226,6 +344,42 @@ func (m *HomeMiner) healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// checkTunnel fails when the tunnel process has exited or there is no
+// public URL
+func (m *HomeMiner) checkTunnel(ctx context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.TunnelType != TunnelDirectIP {
+		if m.tunnel == nil {
+			return ErrTunnelDown
+		}
+		select {
+		case <-m.tunnelExited:
+			return fmt.Errorf("%w: %s exited", ErrTunnelDown, m.TunnelType)
+		default:
+		}
+	}
+	if m.PublicURL == "" {
+		return ErrNoTunnelURL
+	}
+	return nil
+}
+
+// checkExchange fails without an exchange link, or when a link that can
+// check itself reports it is down
+func (m *HomeMiner) checkExchange(ctx context.Context) error {
+	m.mu.RLock()
+	link := m.Exchange
+	m.mu.RUnlock()
+	if link == nil {
+		return ErrNotConnected
+	}
+	if p, ok := link.(health.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
 // getStats returns miner stats
 func (m *HomeMiner) getStats(w http.ResponseWriter, r *http.Request) {
 	m.mu.RLock()
@@ -236,10 +390,29 @@ func (m *HomeMiner) getStats(w http.ResponseWriter, r *http.Request) {
 		m.ID, m.Earnings.TotalEarnings.String())))
 }
 
-// connectToExchange connects to the ad exchange
+// connectToExchange reports impressions to the exchange until stopped
 func (m *HomeMiner) connectToExchange() {
+	defer m.loops.Done()
+
 	// Connect via WebSocket
 	// Simplified implementation
+	interval := m.ReportInterval
+	if interval <= 0 {
+		interval = DefaultReportInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			m.flushReports(ctx)
+			cancel()
+		case <-m.done:
+			return
+		}
+	}
 }
 
 // DetectHardware detects hardware capabilities
@@ -270,8 +443,74 @@ func (m *HomeMiner) GetPublicURL() string {
 	return m.PublicURL
 }
 
-// Stop stops the miner
-func (m *HomeMiner) Stop() error {
-	// Clean shutdown
-	return nil
+// Stop stops accepting ad requests, waits for in-flight serves until ctx is
+// done, reports buffered impressions, then closes the exchange link and the
+// tunnel. Serves still running at the deadline are cut off.
+func (m *HomeMiner) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return nil
+	}
+	m.stopped = true
+	server, done := m.server, m.done
+	tunnel, tunnelExited := m.tunnel, m.tunnelExited
+	m.tunnel, m.tunnelExited = nil, nil
+	m.mu.Unlock()
+
+	var errs []error
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("drain: %w", err))
+			server.Close()
+		}
+	}
+	if done != nil {
+		close(done)
+		m.loops.Wait()
+	}
+
+	// Report what was served even if draining timed out
+	flushCtx := ctx
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		flushCtx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+	}
+	if err := m.flushReports(flushCtx); err != nil {
+		errs = append(errs, fmt.Errorf("flush reports: %w", err))
+	}
+	if m.Exchange != nil {
+		if err := m.Exchange.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close exchange link: %w", err))
+		}
+	}
+	if err := killTunnel(ctx, tunnel, tunnelExited); err != nil {
+		errs = append(errs, fmt.Errorf("stop tunnel: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// stopTunnel stops the tunnel process; m.mu must be held
+func (m *HomeMiner) stopTunnel(ctx context.Context) error {
+	cmd, exited := m.tunnel, m.tunnelExited
+	m.tunnel, m.tunnelExited = nil, nil
+	return killTunnel(ctx, cmd, exited)
+}
+
+// killTunnel interrupts a tunnel process, killing it if it hasn't exited
+// by the deadline
+func killTunnel(ctx context.Context, cmd *exec.Cmd, exited <-chan struct{}) error {
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		return cmd.Process.Kill()
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-ctx.Done():
+		return cmd.Process.Kill()
+	}
 }
@@ -1,6 +1,13 @@
 package miner
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -161,3 +168,177 @@ func TestTunnelTypes(t *testing.T) {
 		t.Errorf("Expected ngrok, got %s", TunnelNgrok)
 	}
 }
+
+// recordingLink is an ExchangeLink that records reports
+type recordingLink struct {
+	mu      sync.Mutex
+	reports []ImpressionReport
+	closed  bool
+}
+
+func (l *recordingLink) ReportImpressions(ctx context.Context, reports []ImpressionReport) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errors.New("link closed")
+	}
+	l.reports = append(l.reports, reports...)
+	return nil
+}
+
+func (l *recordingLink) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return nil
+}
+
+func TestStop_DrainsInFlightServes(t *testing.T) {
+	m := NewHomeMiner(&Config{WalletAddress: "0xABC", LocalPort: 0}, TunnelConfig{Type: TunnelDirectIP})
+	m.PublicURL = "127.0.0.1"
+	link := &recordingLink{}
+	m.Exchange = link
+	m.ReportInterval = time.Hour
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	m.beforeServe = func() {
+		close(entered)
+		<-release
+	}
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + m.Addr().String() + "/ad?id=ad-42"
+
+	type result struct {
+		body string
+		err  error
+	}
+	served := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			served <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		served <- result{string(body), err}
+	}()
+	<-entered
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- m.Stop(ctx)
+	}()
+
+	// Stop waits for the serve, and new requests are refused meanwhile
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned %v with a serve in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := m.InFlight(); n != 1 {
+		t.Errorf("in flight = %d, want 1", n)
+	}
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	if resp, err := client.Get(url); err == nil {
+		resp.Body.Close()
+		t.Error("new request accepted while stopping")
+	}
+
+	close(release)
+	r := <-served
+	if r.err != nil || !strings.Contains(r.body, "<VAST") {
+		t.Errorf("in-flight serve = %q, %v", r.body, r.err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if len(link.reports) != 1 || link.reports[0].AdID != "ad-42" || link.reports[0].MinerID != m.ID {
+		t.Errorf("reports = %+v, want the in-flight impression", link.reports)
+	}
+	if !link.closed {
+		t.Error("exchange link not closed")
+	}
+	if m.PendingReports() != 0 {
+		t.Errorf("%d reports still pending", m.PendingReports())
+	}
+	if err := m.Start(); !errors.Is(err, ErrStopped) {
+		t.Errorf("restart err = %v, want ErrStopped", err)
+	}
+}
+
+// pingLink is an exchange link that can report its connection state
+type pingLink struct {
+	recordingLink
+	mu   sync.Mutex
+	down error
+}
+
+func (l *pingLink) Ping(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.down
+}
+
+func (l *pingLink) setDown(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.down = err
+}
+
+func TestReadyz(t *testing.T) {
+	m := NewHomeMiner(&Config{WalletAddress: "0xABC", LocalPort: 0}, TunnelConfig{Type: TunnelDirectIP, PublicIP: "127.0.0.1"})
+	link := &pingLink{}
+	m.Exchange = link
+	m.ReportInterval = time.Hour
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop(context.Background())
+	base := "http://" + m.Addr().String()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get("/readyz"); code != http.StatusOK {
+		t.Fatalf("readyz = %d %s", code, body)
+	}
+
+	link.setDown(errors.New("websocket closed"))
+	code, body := get("/readyz")
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, `"failing":["exchange"]`) {
+		t.Errorf("readyz with exchange down = %d %s", code, body)
+	}
+	if code, _ := get("/livez"); code != http.StatusOK {
+		t.Errorf("livez = %d", code)
+	}
+}
+
+func TestCheckTunnel_Exited(t *testing.T) {
+	m := NewHomeMiner(&Config{WalletAddress: "0xABC"}, TunnelConfig{Type: TunnelCloudflare})
+	if err := m.checkTunnel(context.Background()); !errors.Is(err, ErrTunnelDown) {
+		t.Fatalf("no tunnel: %v", err)
+	}
+
+	m.PublicURL = "https://miner.trycloudflare.com"
+	if err := m.startTunnel(exec.Command("sh", "-c", "exit 0"), nil); err != nil {
+		t.Skip(err)
+	}
+	<-m.tunnelExited
+	if err := m.checkTunnel(context.Background()); !errors.Is(err, ErrTunnelDown) {
+		t.Errorf("exited tunnel: %v", err)
+	}
+}
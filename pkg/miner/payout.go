@@ -0,0 +1,290 @@
+package miner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownMessage is returned for exchange messages the miner can't handle
+	ErrUnknownMessage = errors.New("unknown exchange message")
+	// ErrPayoutFailed is a submitted transfer that will never confirm
+	ErrPayoutFailed = errors.New("payout transfer failed")
+)
+
+// MessagePayout is the exchange message type announcing a payout
+const MessagePayout = "payout"
+
+// DefaultPayoutInterval is how often every pending balance is paid out
+const DefaultPayoutInterval = 24 * time.Hour
+
+// PayoutChain moves funds on-chain
+type PayoutChain interface {
+	// Transfer submits a transfer and returns its transaction hash
+	Transfer(ctx context.Context, to string, amount *big.Int) (string, error)
+	// Confirm waits for a submitted transfer to be final. It returns an
+	// error wrapping ErrPayoutFailed once the transfer definitively failed;
+	// after any other error the transfer may still confirm.
+	Confirm(ctx context.Context, txHash string) error
+}
+
+// Payout is a confirmed transfer of a miner's earnings
+type Payout struct {
+	MinerID string    `json:"miner_id"`
+	Address string    `json:"address"`
+	Amount  *big.Int  `json:"amount"`
+	TxHash  string    `json:"tx_hash"`
+	PaidAt  time.Time `json:"paid_at"`
+}
+
+// PayoutMessage is the payout notice sent to a miner
+type PayoutMessage struct {
+	Type string `json:"type"`
+	Payout
+}
+
+// PayoutResult is the outcome of one miner's payout attempt
+type PayoutResult struct {
+	Payout *Payout
+	Err    error
+}
+
+type payoutAccount struct {
+	address string
+	pending *big.Int
+
+	// paying is the transfer submitted for the miner, held from its
+	// submission until it confirms or definitively fails so the balance
+	// is never sent twice
+	paying *Payout
+}
+
+// PayoutScheduler batches miners' earnings into on-chain transfers, paying
+// a miner once its balance reaches Threshold and everyone with a balance
+// every Interval. Balances are only reduced once a transfer confirms; one
+// whose confirmation is unknown is confirmed again by the next Settle
+// rather than sent again.
+type PayoutScheduler struct {
+	chain PayoutChain
+
+	// Notify sends a message to a miner's exchange connection
+	Notify func(minerID string, msg []byte) error
+
+	Threshold *big.Int
+	Interval  time.Duration
+
+	settling sync.Mutex // Held through a Settle
+	mu       sync.Mutex
+	accounts map[string]*payoutAccount
+	kick     chan struct{}
+}
+
+// NewPayoutScheduler creates a scheduler paying through chain
+func NewPayoutScheduler(chain PayoutChain, threshold *big.Int, interval time.Duration) *PayoutScheduler {
+	if interval <= 0 {
+		interval = DefaultPayoutInterval
+	}
+	return &PayoutScheduler{
+		chain:     chain,
+		Threshold: threshold,
+		Interval:  interval,
+		accounts:  make(map[string]*payoutAccount),
+		kick:      make(chan struct{}, 1),
+	}
+}
+
+// SetPayoutAddress sets the wallet a miner is paid to
+func (s *PayoutScheduler) SetPayoutAddress(minerID, address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account(minerID).address = address
+}
+
+// Credit adds to a miner's pending earnings, waking Run if the balance
+// reached the threshold
+func (s *PayoutScheduler) Credit(minerID string, amount *big.Int) {
+	s.mu.Lock()
+	acct := s.account(minerID)
+	acct.pending.Add(acct.pending, amount)
+	due := s.overThreshold(acct)
+	s.mu.Unlock()
+
+	if due {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pending is a miner's unpaid balance
+func (s *PayoutScheduler) Pending(minerID string) *big.Int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if acct, ok := s.accounts[minerID]; ok {
+		return new(big.Int).Set(acct.pending)
+	}
+	return big.NewInt(0)
+}
+
+// Run pays out until ctx is done
+func (s *PayoutScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Settle(ctx, true)
+		case <-s.kick:
+			s.Settle(ctx, false)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Settle confirms every transfer still in flight, then pays every miner
+// over the threshold, or every miner with a balance when all is set.
+// Failed transfers leave the balance pending.
+func (s *PayoutScheduler) Settle(ctx context.Context, all bool) []PayoutResult {
+	s.settling.Lock()
+	defer s.settling.Unlock()
+
+	var batch []Payout
+	s.mu.Lock()
+	for id, acct := range s.accounts {
+		switch {
+		case acct.paying != nil:
+			batch = append(batch, *acct.paying)
+		case acct.pending.Sign() <= 0 || acct.address == "":
+		case all || s.overThreshold(acct):
+			batch = append(batch, Payout{MinerID: id, Address: acct.address, Amount: new(big.Int).Set(acct.pending)})
+		}
+	}
+	s.mu.Unlock()
+
+	results := make([]PayoutResult, 0, len(batch))
+	for _, d := range batch {
+		p, err := s.pay(ctx, d)
+		results = append(results, PayoutResult{Payout: p, Err: err})
+	}
+	return results
+}
+
+// pay submits d's transfer unless it already has been and, once it's
+// confirmed, deducts it and notifies the miner. Credits that arrived
+// during the transfer stay pending.
+func (s *PayoutScheduler) pay(ctx context.Context, d Payout) (*Payout, error) {
+	if d.TxHash == "" {
+		txHash, err := s.chain.Transfer(ctx, d.Address, d.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("payout to %s: %w", d.MinerID, err)
+		}
+		d.TxHash = txHash
+		s.mu.Lock()
+		s.accounts[d.MinerID].paying = &d
+		s.mu.Unlock()
+	}
+
+	err := s.chain.Confirm(ctx, d.TxHash)
+	s.mu.Lock()
+	acct := s.accounts[d.MinerID]
+	if err != nil {
+		if errors.Is(err, ErrPayoutFailed) {
+			acct.paying = nil
+		}
+		s.mu.Unlock()
+		return nil, fmt.Errorf("payout to %s in %s: %w", d.MinerID, d.TxHash, err)
+	}
+	acct.paying = nil
+	acct.pending.Sub(acct.pending, d.Amount)
+	s.mu.Unlock()
+
+	p := &d
+	p.PaidAt = time.Now()
+
+	if s.Notify != nil {
+		msg, err := json.Marshal(PayoutMessage{Type: MessagePayout, Payout: *p})
+		if err != nil {
+			return p, err
+		}
+		if err := s.Notify(p.MinerID, msg); err != nil {
+			return p, fmt.Errorf("notify %s of payout %s: %w", p.MinerID, p.TxHash, err)
+		}
+	}
+	return p, nil
+}
+
+func (s *PayoutScheduler) account(minerID string) *payoutAccount {
+	acct, ok := s.accounts[minerID]
+	if !ok {
+		acct = &payoutAccount{pending: big.NewInt(0)}
+		s.accounts[minerID] = acct
+	}
+	return acct
+}
+
+func (s *PayoutScheduler) overThreshold(acct *payoutAccount) bool {
+	return s.Threshold != nil && s.Threshold.Sign() > 0 && acct.pending.Cmp(s.Threshold) >= 0
+}
+
+// HandleMessage dispatches a message from the exchange
+func (m *HomeMiner) HandleMessage(data []byte) error {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	switch envelope.Type {
+	case MessagePayout:
+		var msg PayoutMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return err
+		}
+		return m.handlePayout(msg.Payout)
+	case MessageAuctionResult:
+		var msg AuctionResultMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return err
+		}
+		return m.handleAuctionResult(msg.AuctionResult)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMessage, envelope.Type)
+	}
+}
+
+// handlePayout records a payout to the miner's wallet. Repeated notices
+// for one transaction are ignored.
+func (m *HomeMiner) handlePayout(p Payout) error {
+	if p.Amount == nil || p.Amount.Sign() <= 0 {
+		return fmt.Errorf("payout %s: invalid amount", p.TxHash)
+	}
+	if p.Address != m.WalletAddress {
+		return fmt.Errorf("payout %s to %s, not this miner's wallet", p.TxHash, p.Address)
+	}
+
+	e := m.Earnings
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, prev := range e.Payouts {
+		if prev.TxHash == p.TxHash {
+			return nil
+		}
+	}
+	e.Payouts = append(e.Payouts, p)
+	e.TotalEarnings.Add(e.TotalEarnings, p.Amount)
+	e.PendingWithdrawal.Sub(e.PendingWithdrawal, p.Amount)
+	if e.PendingWithdrawal.Sign() < 0 {
+		e.PendingWithdrawal.SetInt64(0)
+	}
+	if p.PaidAt.After(e.LastPayout) {
+		e.LastPayout = p.PaidAt
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package miner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// DefaultReceiptPoll is how often RPCPayoutChain checks for a receipt
+const DefaultReceiptPoll = 2 * time.Second
+
+// RPCPayoutChain pays through an EVM node's JSON-RPC API, sending the
+// chain's native coin from an account the node holds the key for
+type RPCPayoutChain struct {
+	Endpoint string
+	From     string
+	Client   *http.Client
+
+	// Poll is how often Confirm checks for a receipt; DefaultReceiptPoll
+	// when 0
+	Poll time.Duration
+}
+
+// NewRPCPayoutChain pays from the account from through the node at endpoint
+func NewRPCPayoutChain(endpoint, from string) *RPCPayoutChain {
+	return &RPCPayoutChain{Endpoint: endpoint, From: from, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Transfer implements PayoutChain with eth_sendTransaction
+func (c *RPCPayoutChain) Transfer(ctx context.Context, to string, amount *big.Int) (string, error) {
+	tx := map[string]string{"from": c.From, "to": to, "value": "0x" + amount.Text(16)}
+	var txHash string
+	if err := c.call(ctx, "eth_sendTransaction", []any{tx}, &txHash); err != nil {
+		return "", err
+	}
+	if txHash == "" {
+		return "", fmt.Errorf("eth_sendTransaction: no transaction hash")
+	}
+	return txHash, nil
+}
+
+// Confirm implements PayoutChain, polling eth_getTransactionReceipt until
+// the transfer is mined. A reverted transfer failed.
+func (c *RPCPayoutChain) Confirm(ctx context.Context, txHash string) error {
+	poll := c.Poll
+	if poll <= 0 {
+		poll = DefaultReceiptPoll
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		var receipt *struct {
+			Status string `json:"status"`
+		}
+		if err := c.call(ctx, "eth_getTransactionReceipt", []any{txHash}, &receipt); err != nil {
+			return err
+		}
+		if receipt != nil {
+			if receipt.Status != "0x1" {
+				return fmt.Errorf("%w: %s reverted", ErrPayoutFailed, txHash)
+			}
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *RPCPayoutChain) call(ctx context.Context, method string, params []any, result any) error {
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", method, resp.StatusCode)
+	}
+
+	var out struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if out.Error != nil {
+		return fmt.Errorf("%s: %s (%d)", method, out.Error.Message, out.Error.Code)
+	}
+	return json.Unmarshal(out.Result, result)
+}
@@ -0,0 +1,246 @@
+package miner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChain records transfers, failing while fail is set; confirmations
+// fail with confirm
+type fakeChain struct {
+	mu        sync.Mutex
+	fail      error
+	confirm   error
+	sent      int
+	transfers map[string]*big.Int
+}
+
+func (c *fakeChain) Transfer(ctx context.Context, to string, amount *big.Int) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail != nil {
+		return "", c.fail
+	}
+	if c.transfers == nil {
+		c.transfers = make(map[string]*big.Int)
+	}
+	c.sent++
+	tx := "0xtx" + to + amount.String()
+	c.transfers[tx] = new(big.Int).Set(amount)
+	return tx, nil
+}
+
+func (c *fakeChain) Confirm(ctx context.Context, txHash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.confirm
+}
+
+func TestPayout_Threshold(t *testing.T) {
+	chain := &fakeChain{}
+	s := NewPayoutScheduler(chain, big.NewInt(1000), time.Hour)
+	m := NewHomeMiner(&Config{WalletAddress: "0xMINER"}, TunnelConfig{Type: TunnelDirectIP})
+	m.Earnings.PendingWithdrawal.SetInt64(1200)
+	s.Notify = func(minerID string, msg []byte) error {
+		if minerID != m.ID {
+			t.Errorf("notified %s", minerID)
+		}
+		return m.HandleMessage(msg)
+	}
+	s.SetPayoutAddress(m.ID, m.WalletAddress)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	s.Credit(m.ID, big.NewInt(600))
+	time.Sleep(20 * time.Millisecond)
+	if got := s.Pending(m.ID); got.Int64() != 600 {
+		t.Fatalf("pending below threshold = %s, want 600", got)
+	}
+
+	s.Credit(m.ID, big.NewInt(600))
+	deadline := time.Now().Add(time.Second)
+	for s.Pending(m.ID).Sign() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := s.Pending(m.ID); got.Sign() != 0 {
+		t.Fatalf("pending after threshold = %s, want 0", got)
+	}
+
+	m.Earnings.mu.RLock()
+	defer m.Earnings.mu.RUnlock()
+	if len(m.Earnings.Payouts) != 1 || m.Earnings.Payouts[0].TxHash != "0xtx0xMINER1200" {
+		t.Fatalf("payouts = %+v", m.Earnings.Payouts)
+	}
+	if m.Earnings.TotalEarnings.Int64() != 1200 || m.Earnings.PendingWithdrawal.Sign() != 0 || m.Earnings.LastPayout.IsZero() {
+		t.Errorf("earnings total %s pending %s last payout %v", m.Earnings.TotalEarnings, m.Earnings.PendingWithdrawal, m.Earnings.LastPayout)
+	}
+}
+
+func TestPayout_FailedTransferKeepsBalance(t *testing.T) {
+	chain := &fakeChain{fail: errors.New("insufficient gas")}
+	s := NewPayoutScheduler(chain, big.NewInt(1000), time.Hour)
+	notified := 0
+	s.Notify = func(string, []byte) error { notified++; return nil }
+	s.SetPayoutAddress("m1", "0xM1")
+	s.Credit("m1", big.NewInt(1500))
+
+	results := s.Settle(context.Background(), false)
+	if len(results) != 1 || results[0].Err == nil || results[0].Payout != nil {
+		t.Fatalf("results = %+v", results)
+	}
+	if got := s.Pending("m1"); got.Int64() != 1500 {
+		t.Errorf("pending after failed transfer = %s, want 1500", got)
+	}
+	if notified != 0 {
+		t.Errorf("notified %d times for a failed transfer", notified)
+	}
+
+	// The retained balance is paid once the chain recovers
+	chain.fail = nil
+	s.Credit("m1", big.NewInt(1))
+	results = s.Settle(context.Background(), false)
+	if len(results) != 1 || results[0].Err != nil || results[0].Payout.Amount.Int64() != 1501 {
+		t.Fatalf("retry results = %+v", results)
+	}
+	if got := s.Pending("m1"); got.Sign() != 0 {
+		t.Errorf("pending after retry = %s", got)
+	}
+}
+
+func TestPayout_IntervalPaysBelowThreshold(t *testing.T) {
+	s := NewPayoutScheduler(&fakeChain{}, big.NewInt(1000), time.Hour)
+	s.SetPayoutAddress("m1", "0xM1")
+	s.Credit("m1", big.NewInt(10))
+	s.Credit("m2", big.NewInt(10)) // No address yet
+
+	if results := s.Settle(context.Background(), false); len(results) != 0 {
+		t.Errorf("threshold settle paid %+v", results)
+	}
+	results := s.Settle(context.Background(), true)
+	if len(results) != 1 || results[0].Payout.MinerID != "m1" {
+		t.Fatalf("interval settle = %+v", results)
+	}
+	if s.Pending("m2").Int64() != 10 {
+		t.Error("miner without an address lost its balance")
+	}
+}
+
+func TestHandlePayout_IgnoresDuplicatesAndOtherWallets(t *testing.T) {
+	m := NewHomeMiner(&Config{WalletAddress: "0xMINER"}, TunnelConfig{Type: TunnelDirectIP})
+	p := Payout{MinerID: m.ID, Address: "0xMINER", Amount: big.NewInt(50), TxHash: "0x1", PaidAt: time.Now()}
+	for i := 0; i < 2; i++ {
+		if err := m.handlePayout(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.Earnings.TotalEarnings.Int64() != 50 || len(m.Earnings.Payouts) != 1 {
+		t.Errorf("total %s after duplicate notice, payouts %d", m.Earnings.TotalEarnings, len(m.Earnings.Payouts))
+	}
+
+	p.Address, p.TxHash = "0xOTHER", "0x2"
+	if err := m.handlePayout(p); err == nil {
+		t.Error("payout to another wallet accepted")
+	}
+	if err := m.HandleMessage([]byte(`{"type":"bogus"}`)); !errors.Is(err, ErrUnknownMessage) {
+		t.Errorf("unknown message: err = %v", err)
+	}
+}
+
+func TestPayout_UnconfirmedTransferIsConfirmedNotResent(t *testing.T) {
+	chain := &fakeChain{confirm: context.DeadlineExceeded}
+	s := NewPayoutScheduler(chain, big.NewInt(1000), time.Hour)
+	s.SetPayoutAddress("m1", "0xM1")
+	s.Credit("m1", big.NewInt(1500))
+
+	results := s.Settle(context.Background(), false)
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("results = %+v", results)
+	}
+	if got := s.Pending("m1"); got.Int64() != 1500 {
+		t.Errorf("pending while unconfirmed = %s, want 1500", got)
+	}
+
+	// The transfer went through after all; crediting more mustn't send it again
+	chain.confirm = nil
+	s.Credit("m1", big.NewInt(10))
+	results = s.Settle(context.Background(), false)
+	if len(results) != 1 || results[0].Err != nil || results[0].Payout.Amount.Int64() != 1500 {
+		t.Fatalf("confirm results = %+v", results)
+	}
+	if chain.sent != 1 {
+		t.Errorf("sent %d transfers, want 1", chain.sent)
+	}
+	if got := s.Pending("m1"); got.Int64() != 10 {
+		t.Errorf("pending after confirming = %s, want 10", got)
+	}
+
+	// A transfer that failed on-chain is sent again
+	chain.confirm = ErrPayoutFailed
+	s.Credit("m1", big.NewInt(1000))
+	s.Settle(context.Background(), false)
+	chain.confirm = nil
+	results = s.Settle(context.Background(), false)
+	if len(results) != 1 || results[0].Err != nil || results[0].Payout.Amount.Int64() != 1010 || chain.sent != 3 {
+		t.Fatalf("after a failed transfer: results %+v, sent %d", results, chain.sent)
+	}
+	if got := s.Pending("m1"); got.Sign() != 0 {
+		t.Errorf("pending after resending = %s", got)
+	}
+}
+
+func TestRPCPayoutChain(t *testing.T) {
+	var status string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		switch req.Method {
+		case "eth_sendTransaction":
+			var tx map[string]string
+			json.Unmarshal(req.Params[0], &tx)
+			if tx["from"] != "0xFROM" || tx["to"] != "0xM1" || tx["value"] != "0x5dc" {
+				t.Errorf("transaction = %v", tx)
+			}
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0xabc"}`)
+		case "eth_getTransactionReceipt":
+			if status == "" {
+				status = "0x1"
+				fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":null}`)
+				return
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"status":%q}}`, status)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewRPCPayoutChain(srv.URL, "0xFROM")
+	c.Poll = time.Millisecond
+	ctx := context.Background()
+	tx, err := c.Transfer(ctx, "0xM1", big.NewInt(1500))
+	if err != nil || tx != "0xabc" {
+		t.Fatalf("Transfer = %q, %v", tx, err)
+	}
+	if err := c.Confirm(ctx, tx); err != nil {
+		t.Errorf("Confirm after a pending receipt: %v", err)
+	}
+	status = "0x0"
+	if err := c.Confirm(ctx, tx); !errors.Is(err, ErrPayoutFailed) {
+		t.Errorf("reverted: err = %v, want %v", err, ErrPayoutFailed)
+	}
+}
@@ -0,0 +1,385 @@
+package miner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ErrNoTunnelURL is returned when a tunnel tool's output has no public URL
+var ErrNoTunnelURL = errors.New("no public URL in tunnel output")
+
+// tunnelStartTimeout bounds how long a tunnel may take to report its URL
+const tunnelStartTimeout = 30 * time.Second
+
+var (
+	httpsURLPattern       = regexp.MustCompile(`https://[^\s"'|│()<>]+`)
+	localXposeHostPattern = regexp.MustCompile(`\b[a-z0-9][a-z0-9-]*(\.[a-z0-9-]+)*\.loclx\.io\b`)
+	cloudflaredPattern    = regexp.MustCompile(`https://([a-z0-9-]+)\.trycloudflare\.com`)
+	tailscalePattern      = regexp.MustCompile(`https://[a-z0-9-]+(\.[a-z0-9-]+)*\.ts\.net(:\d+)?`)
+)
+
+// setupTunnel sets up the tunnel
+func (m *HomeMiner) setupTunnel() error {
+	switch m.TunnelType {
+	case TunnelLocalXpose:
+		return m.setupLocalXpose()
+	case TunnelNgrok:
+		return m.setupNgrok()
+	case TunnelCloudflare:
+		return m.setupCloudflare()
+	case TunnelTailscale:
+		return m.setupTailscale()
+	case TunnelDirectIP:
+		if m.PublicURL == "" {
+			return fmt.Errorf("%w: direct tunnel needs a public IP", ErrNoTunnelURL)
+		}
+		m.PublicURL = fmt.Sprintf("http://%s:%d", m.PublicURL, m.LocalPort)
+		return nil
+	default:
+		return fmt.Errorf("unsupported tunnel type: %s", m.TunnelType)
+	}
+}
+
+// setupLocalXpose starts a LocalXpose tunnel and reads its endpoint from
+// `loclx tunnel list`
+func (m *HomeMiner) setupLocalXpose() error {
+	if err := m.startTunnel(exec.Command("loclx", "tunnel", "http",
+		"--to", fmt.Sprintf("localhost:%d", m.LocalPort)), nil); err != nil {
+		return err
+	}
+	return m.pollTunnelURL(func() (string, error) {
+		out, err := exec.Command("loclx", "tunnel", "list").CombinedOutput()
+		if err != nil {
+			return "", err
+		}
+		return parseLocalXposeURL(string(out), m.LocalPort)
+	})
+}
+
+// setupNgrok starts an ngrok tunnel and reads its URL from the local API
+func (m *HomeMiner) setupNgrok() error {
+	if err := m.startTunnel(exec.Command("ngrok", "http", fmt.Sprintf("%d", m.LocalPort)), nil); err != nil {
+		return err
+	}
+	return m.pollTunnelURL(func() (string, error) {
+		resp, err := http.Get("http://localhost:4040/api/tunnels")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		return parseNgrokTunnels(body, m.LocalPort)
+	})
+}
+
+// setupCloudflare starts a cloudflared quick tunnel, which logs its URL to
+// stderr
+func (m *HomeMiner) setupCloudflare() error {
+	found := make(chan string, 1)
+	cmd := exec.Command("cloudflared", "tunnel", "--url", fmt.Sprintf("http://localhost:%d", m.LocalPort))
+	if err := m.startTunnel(cmd, func(line string) {
+		if u, err := parseCloudflaredURL(line); err == nil {
+			select {
+			case found <- u:
+			default:
+			}
+		}
+	}); err != nil {
+		return err
+	}
+
+	return m.pollTunnelURL(func() (string, error) {
+		select {
+		case u := <-found:
+			return u, nil
+		case <-time.After(time.Second):
+			return "", fmt.Errorf("%w: cloudflared", ErrNoTunnelURL)
+		}
+	})
+}
+
+// setupTailscale starts a Tailscale Funnel and reads its URL from
+// `tailscale funnel status`, checking it is on this node's DNS name
+func (m *HomeMiner) setupTailscale() error {
+	status, err := exec.Command("tailscale", "status", "--json").Output()
+	if err != nil {
+		return fmt.Errorf("tailscale status: %w", err)
+	}
+	dnsName, err := parseTailscaleStatus(status)
+	if err != nil {
+		return err
+	}
+
+	if err := m.startTunnel(exec.Command("tailscale", "funnel", fmt.Sprintf("%d", m.LocalPort)), nil); err != nil {
+		return err
+	}
+	return m.pollTunnelURL(func() (string, error) {
+		out, err := exec.Command("tailscale", "funnel", "status").CombinedOutput()
+		if err != nil {
+			return "", err
+		}
+		u, err := parseTailscaleFunnelURL(string(out), m.LocalPort)
+		if err != nil {
+			return "", err
+		}
+		if host := strings.TrimPrefix(u, "https://"); host != dnsName && !strings.HasPrefix(host, dnsName+":") {
+			return "", fmt.Errorf("%w: funnel %s is not on %s", ErrNoTunnelURL, u, dnsName)
+		}
+		return u, nil
+	})
+}
+
+// startTunnel starts a tunnel process that runs until stopTunnel. Its
+// output is kept for error messages and passed line by line to onLine, if
+// set.
+func (m *HomeMiner) startTunnel(cmd *exec.Cmd, onLine func(string)) error {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	log := &tunnelLog{}
+	exited := make(chan struct{})
+	m.tunnel, m.tunnelExited, m.tunnelLog = cmd, exited, log
+
+	var readers sync.WaitGroup
+	for _, r := range []io.Reader{stdout, stderr} {
+		readers.Add(1)
+		go func(r io.Reader) {
+			defer readers.Done()
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				line := scanner.Text()
+				log.add(line)
+				if onLine != nil {
+					onLine(line)
+				}
+			}
+		}(r)
+	}
+	// Wait may only run once the pipes are drained
+	go func() {
+		readers.Wait()
+		cmd.Wait()
+		close(exited)
+	}()
+	return nil
+}
+
+// pollTunnelURL sets PublicURL from fetch once the tunnel reports it. The
+// tunnel process is stopped if it never does.
+func (m *HomeMiner) pollTunnelURL(fetch func() (string, error)) error {
+	deadline := time.Now().Add(tunnelStartTimeout)
+	for {
+		u, err := fetch()
+		if err == nil {
+			m.PublicURL = u
+			return nil
+		}
+		if time.Now().After(deadline) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			m.stopTunnel(ctx)
+			cancel()
+			if out := m.tunnelLog.String(); out != "" {
+				return fmt.Errorf("%s tunnel: %w; output: %s", m.TunnelType, err, out)
+			}
+			return fmt.Errorf("%s tunnel: %w", m.TunnelType, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// parseTailscaleStatus returns this node's MagicDNS name from `tailscale
+// status --json`
+func parseTailscaleStatus(data []byte) (string, error) {
+	var status struct {
+		BackendState string `json:"BackendState"`
+		Self         struct {
+			DNSName string `json:"DNSName"`
+		} `json:"Self"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&status); err != nil {
+		return "", fmt.Errorf("tailscale status: %w", err)
+	}
+	if status.BackendState != "" && status.BackendState != "Running" {
+		return "", fmt.Errorf("%w: tailscale is %s", ErrNoTunnelURL, status.BackendState)
+	}
+	name := strings.TrimSuffix(status.Self.DNSName, ".")
+	if name == "" {
+		return "", fmt.Errorf("%w: tailscale node has no DNS name", ErrNoTunnelURL)
+	}
+	return name, nil
+}
+
+// parseLocalXposeURL finds the endpoint forwarding to port in `loclx tunnel
+// list` output. Endpoints are listed either as URLs or bare hostnames.
+func parseLocalXposeURL(output string, port int) (string, error) {
+	var fallback string
+	for _, line := range strings.Split(output, "\n") {
+		u := httpsURLPattern.FindString(line)
+		if u == "" {
+			if host := localXposeHostPattern.FindString(line); host != "" {
+				u = "https://" + host
+			}
+		}
+		if u == "" {
+			continue
+		}
+		if mentionsPort(line, port) {
+			return strings.TrimRight(u, "/"), nil
+		}
+		if fallback == "" {
+			fallback = strings.TrimRight(u, "/")
+		}
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("%w: loclx", ErrNoTunnelURL)
+	}
+	return fallback, nil
+}
+
+// parseNgrokTunnels picks the public URL from ngrok's /api/tunnels
+// response, preferring an https tunnel to port
+func parseNgrokTunnels(body []byte, port int) (string, error) {
+	var resp struct {
+		Tunnels []struct {
+			PublicURL string `json:"public_url"`
+			Proto     string `json:"proto"`
+			Config    struct {
+				Addr string `json:"addr"`
+			} `json:"config"`
+		} `json:"tunnels"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("ngrok api: %w", err)
+	}
+
+	best, bestRank := "", 0
+	for _, t := range resp.Tunnels {
+		if t.PublicURL == "" {
+			continue
+		}
+		rank := 1
+		if t.Proto == "https" || strings.HasPrefix(t.PublicURL, "https://") {
+			rank += 1
+		}
+		if mentionsPort(t.Config.Addr, port) {
+			rank += 2
+		}
+		if rank > bestRank {
+			best, bestRank = t.PublicURL, rank
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("%w: ngrok", ErrNoTunnelURL)
+	}
+	return best, nil
+}
+
+// parseCloudflaredURL finds the quick tunnel URL in cloudflared's log
+// output, skipping its API endpoint
+func parseCloudflaredURL(output string) (string, error) {
+	for _, match := range cloudflaredPattern.FindAllStringSubmatch(output, -1) {
+		if match[1] != "api" {
+			return match[0], nil
+		}
+	}
+	return "", fmt.Errorf("%w: cloudflared", ErrNoTunnelURL)
+}
+
+// parseTailscaleFunnelURL finds the funnel serving port in `tailscale funnel
+// status` output. Each served URL heads a block of proxy lines.
+func parseTailscaleFunnelURL(output string, port int) (string, error) {
+	var current, fallback string
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			// The header lists funnels regardless of what they serve
+			continue
+		}
+		if strings.HasPrefix(trimmed, "https://") {
+			// Only blocks marked Funnel on are reachable from the internet
+			current = ""
+			if u := tailscalePattern.FindString(trimmed); u != "" && strings.Contains(trimmed, "Funnel on") {
+				current = u
+				if fallback == "" {
+					fallback = u
+				}
+			}
+			continue
+		}
+		if current != "" && strings.Contains(trimmed, "proxy") && mentionsPort(trimmed, port) {
+			return current, nil
+		}
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("%w: tailscale funnel", ErrNoTunnelURL)
+	}
+	return fallback, nil
+}
+
+// mentionsPort reports whether s has a host:port field for port
+func mentionsPort(s string, port int) bool {
+	if port == 0 {
+		return false
+	}
+	suffix := ":" + strconv.Itoa(port)
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '|' || r == '│'
+	}) {
+		if strings.HasSuffix(strings.TrimRight(field, "/"), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// tunnelLogSize is how much recent tunnel output is kept
+const tunnelLogSize = 4096
+
+// tunnelLog keeps the tail of a tunnel process's output
+type tunnelLog struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (l *tunnelLog) add(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, line...)
+	l.buf = append(l.buf, '\n')
+	if n := len(l.buf) - tunnelLogSize; n > 0 {
+		l.buf = append(l.buf[:0], l.buf[n:]...)
+	}
+}
+
+func (l *tunnelLog) String() string {
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.TrimSpace(string(l.buf))
+}
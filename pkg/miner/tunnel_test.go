@@ -0,0 +1,134 @@
+package miner
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLocalXposeURL(t *testing.T) {
+	output := `┌──────────────────────┬──────┬─────────────────────────────┬────────────────┬─────────┐
+│ ID                   │ TYPE │ ENDPOINT                    │ TO             │ STATUS  │
+├──────────────────────┼──────┼─────────────────────────────┼────────────────┼─────────┤
+│ 01HF3K2R8Y6Q4WZ9X1M  │ http │ fp3kq7xzla.loclx.io         │ localhost:3000 │ running │
+│ 01HF3K2R8Y6Q4WZ9X2N  │ http │ k1m8wq2zrt.loclx.io         │ localhost:8888 │ running │
+└──────────────────────┴──────┴─────────────────────────────┴────────────────┴─────────┘
+`
+	if got, err := parseLocalXposeURL(output, 8888); err != nil || got != "https://k1m8wq2zrt.loclx.io" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	urls := "http  https://adx-home.loclx.io/  ->  http://127.0.0.1:8888  running\n"
+	if got, err := parseLocalXposeURL(urls, 8888); err != nil || got != "https://adx-home.loclx.io" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	if _, err := parseLocalXposeURL("No tunnels running\n", 8888); !errors.Is(err, ErrNoTunnelURL) {
+		t.Errorf("empty list: err = %v", err)
+	}
+}
+
+func TestParseNgrokTunnels(t *testing.T) {
+	body := []byte(`{"tunnels":[
+		{"name":"command_line (http)","public_url":"http://5f2a-203-0-113-7.ngrok-free.app","proto":"http","config":{"addr":"http://localhost:8888"}},
+		{"name":"other","public_url":"https://aa11-203-0-113-7.ngrok-free.app","proto":"https","config":{"addr":"http://localhost:3000"}},
+		{"name":"command_line","public_url":"https://5f2a-203-0-113-7.ngrok-free.app","proto":"https","config":{"addr":"http://localhost:8888"}}
+	],"uri":"/api/tunnels"}`)
+	if got, err := parseNgrokTunnels(body, 8888); err != nil || got != "https://5f2a-203-0-113-7.ngrok-free.app" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// Tunnels still starting up
+	if _, err := parseNgrokTunnels([]byte(`{"tunnels":[],"uri":"/api/tunnels"}`), 8888); !errors.Is(err, ErrNoTunnelURL) {
+		t.Errorf("no tunnels: err = %v", err)
+	}
+	if _, err := parseNgrokTunnels([]byte(`<html>`), 8888); err == nil {
+		t.Error("invalid JSON accepted")
+	}
+}
+
+func TestParseCloudflaredURL(t *testing.T) {
+	stderr := []string{
+		`2024-05-02T10:14:03Z INF Requesting new quick Tunnel on trycloudflare.com...`,
+		`2024-05-02T10:14:04Z ERR Error unmarshaling QuickTunnel response error="https://api.trycloudflare.com/tunnel: timeout"`,
+		`2024-05-02T10:14:05Z INF +--------------------------------------------------------------------------------------------+`,
+		`2024-05-02T10:14:05Z INF |  Your quick Tunnel has been created! Visit it at (it may take some time to be reachable):  |`,
+		`2024-05-02T10:14:05Z INF |  https://seasonal-deck-organisms-sf.trycloudflare.com                                      |`,
+	}
+	var got string
+	for _, line := range stderr {
+		if u, err := parseCloudflaredURL(line); err == nil {
+			got = u
+			break
+		}
+	}
+	if got != "https://seasonal-deck-organisms-sf.trycloudflare.com" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseTailscaleFunnelURL(t *testing.T) {
+	output := `# Funnel on:
+#     - https://adx-home.tail1a2b3.ts.net
+#     - https://adx-home.tail1a2b3.ts.net:8443
+
+https://adx-home.tail1a2b3.ts.net (Funnel on)
+|-- / proxy http://127.0.0.1:3000
+
+https://adx-home.tail1a2b3.ts.net:8443 (Funnel on)
+|-- / proxy http://127.0.0.1:8888
+`
+	if got, err := parseTailscaleFunnelURL(output, 8888); err != nil || got != "https://adx-home.tail1a2b3.ts.net:8443" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if got, err := parseTailscaleFunnelURL(output, 3000); err != nil || got != "https://adx-home.tail1a2b3.ts.net" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	if _, err := parseTailscaleFunnelURL("No serve config\n", 8888); !errors.Is(err, ErrNoTunnelURL) {
+		t.Errorf("no funnel: err = %v", err)
+	}
+	// Served on the tailnet only, not funneled
+	tailnetOnly := "https://adx-home.tail1a2b3.ts.net (tailnet only)\n|-- / proxy http://127.0.0.1:3000\n"
+	if _, err := parseTailscaleFunnelURL(tailnetOnly, 8888); !errors.Is(err, ErrNoTunnelURL) {
+		t.Errorf("tailnet only: err = %v", err)
+	}
+}
+
+func TestDirectTunnelNeedsPublicIP(t *testing.T) {
+	m := NewHomeMiner(&Config{LocalPort: 8888}, TunnelConfig{Type: TunnelDirectIP})
+	if err := m.setupTunnel(); !errors.Is(err, ErrNoTunnelURL) {
+		t.Errorf("err = %v", err)
+	}
+
+	m = NewHomeMiner(&Config{LocalPort: 8888}, TunnelConfig{Type: TunnelDirectIP, PublicIP: "203.0.113.7"})
+	if err := m.setupTunnel(); err != nil || m.PublicURL != "http://203.0.113.7:8888" {
+		t.Errorf("PublicURL = %q, err = %v", m.PublicURL, err)
+	}
+}
+
+func TestParseTailscaleStatus(t *testing.T) {
+	status := []byte(`{
+  "Version": "1.66.4-t5a9d2c3f1",
+  "BackendState": "Running",
+  "TailscaleIPs": ["100.101.102.103", "fd7a:115c:a1e0::1"],
+  "Self": {
+    "ID": "n4Bz8YdQ5CNTRL",
+    "HostName": "adx-home",
+    "DNSName": "adx-home.tail1a2b3.ts.net.",
+    "OS": "linux",
+    "Online": true
+  },
+  "MagicDNSSuffix": "tail1a2b3.ts.net",
+  "Peer": {}
+}`)
+	if got, err := parseTailscaleStatus(status); err != nil || got != "adx-home.tail1a2b3.ts.net" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	if _, err := parseTailscaleStatus([]byte(`{"BackendState":"NeedsLogin","Self":{"DNSName":""}}`)); !errors.Is(err, ErrNoTunnelURL) {
+		t.Errorf("logged out: err = %v", err)
+	}
+	if _, err := parseTailscaleStatus([]byte(`not json`)); err == nil {
+		t.Error("invalid JSON accepted")
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package oracle provides the reference prices settlement and the AMM
+// measure against: the AUSD/USD rate and benchmark CPMs by ad format.
+// Guarded wraps a live feed with staleness checks, falling back to a
+// static configuration when the feed is down or behind.
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultMaxAge is how old a quote may be before it's treated as stale
+const DefaultMaxAge = 5 * time.Minute
+
+var (
+	// ErrNoPrice is returned for prices an oracle doesn't carry
+	ErrNoPrice = errors.New("no price")
+
+	// ErrStale is returned for quotes older than the allowed age
+	ErrStale = errors.New("stale price")
+)
+
+// Format is an ad format benchmark CPMs are quoted for
+type Format string
+
+const (
+	FormatBanner Format = "banner"
+	FormatNative Format = "native"
+	FormatVideo  Format = "video"
+	FormatCTV    Format = "ctv"
+	FormatAudio  Format = "audio"
+)
+
+// FormatOf maps a placement name such as "ctv-preroll" or
+// "banner-300x250" to its format, defaulting to banner
+func FormatOf(placement string) Format {
+	p := strings.ToLower(placement)
+	for _, f := range []Format{FormatCTV, FormatVideo, FormatAudio, FormatNative} {
+		if strings.HasPrefix(p, string(f)) {
+			return f
+		}
+	}
+	return FormatBanner
+}
+
+// Quote is a price and when it was observed
+type Quote struct {
+	Price     decimal.Decimal
+	UpdatedAt time.Time
+}
+
+// PriceOracle provides reference prices
+type PriceOracle interface {
+	// AUSDUSD is the USD value of one AUSD
+	AUSDUSD(ctx context.Context) (Quote, error)
+
+	// BenchmarkCPM is the market CPM in USD for a format
+	BenchmarkCPM(ctx context.Context, format Format) (Quote, error)
+}
+
+// DefaultBenchmarkCPMs are conservative market CPMs in USD
+var DefaultBenchmarkCPMs = map[Format]decimal.Decimal{
+	FormatBanner: decimal.RequireFromString("1.5"),
+	FormatNative: decimal.RequireFromString("3"),
+	FormatVideo:  decimal.RequireFromString("10"),
+	FormatCTV:    decimal.RequireFromString("25"),
+	FormatAudio:  decimal.RequireFromString("8"),
+}
+
+// Static is an oracle over configured prices. Its quotes are always
+// current, so it's the usual fallback.
+type Static struct {
+	AUSD decimal.Decimal
+	CPMs map[Format]decimal.Decimal
+}
+
+// NewStatic creates a static oracle pricing AUSD at par with the default
+// benchmark CPMs
+func NewStatic() *Static {
+	cpms := make(map[Format]decimal.Decimal, len(DefaultBenchmarkCPMs))
+	for f, cpm := range DefaultBenchmarkCPMs {
+		cpms[f] = cpm
+	}
+	return &Static{AUSD: decimal.NewFromInt(1), CPMs: cpms}
+}
+
+// AUSDUSD returns the configured rate
+func (s *Static) AUSDUSD(context.Context) (Quote, error) {
+	return Quote{Price: s.AUSD, UpdatedAt: time.Now()}, nil
+}
+
+// BenchmarkCPM returns the configured CPM for format
+func (s *Static) BenchmarkCPM(_ context.Context, format Format) (Quote, error) {
+	cpm, ok := s.CPMs[format]
+	if !ok {
+		return Quote{}, fmt.Errorf("%w: %s CPM", ErrNoPrice, format)
+	}
+	return Quote{Price: cpm, UpdatedAt: time.Now()}, nil
+}
+
+// Feed is an oracle fed by an external source: a price service, an
+// on-chain aggregator or a pusher calling Set.
+type Feed struct {
+	mu     sync.RWMutex
+	quotes map[string]Quote
+}
+
+// NewFeed creates an empty feed
+func NewFeed() *Feed {
+	return &Feed{quotes: make(map[string]Quote)}
+}
+
+// Set records a quote for "AUSD/USD" or a format's benchmark CPM, keyed
+// by the format name
+func (f *Feed) Set(symbol string, q Quote) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.quotes[symbol] = q
+}
+
+// AUSDUSD returns the last AUSD/USD quote
+func (f *Feed) AUSDUSD(context.Context) (Quote, error) {
+	return f.get("AUSD/USD")
+}
+
+// BenchmarkCPM returns the last quote for format
+func (f *Feed) BenchmarkCPM(_ context.Context, format Format) (Quote, error) {
+	return f.get(string(format))
+}
+
+func (f *Feed) get(symbol string) (Quote, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	q, ok := f.quotes[symbol]
+	if !ok {
+		return Quote{}, fmt.Errorf("%w: %s", ErrNoPrice, symbol)
+	}
+	return q, nil
+}
+
+// Guarded serves a primary oracle's quotes while they're fresh and
+// positive, and the fallback's otherwise
+type Guarded struct {
+	Primary  PriceOracle
+	Fallback PriceOracle
+	MaxAge   time.Duration
+
+	now func() time.Time
+}
+
+// NewGuarded guards primary, falling back to a default Static oracle when
+// fallback is nil
+func NewGuarded(primary, fallback PriceOracle, maxAge time.Duration) *Guarded {
+	if fallback == nil {
+		fallback = NewStatic()
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &Guarded{Primary: primary, Fallback: fallback, MaxAge: maxAge, now: time.Now}
+}
+
+// AUSDUSD returns the primary's rate if fresh, else the fallback's
+func (g *Guarded) AUSDUSD(ctx context.Context) (Quote, error) {
+	q, err := g.check(g.Primary.AUSDUSD(ctx))
+	if err == nil {
+		return q, nil
+	}
+	slog.Warn("AUSD/USD oracle unusable, using fallback", "error", err)
+	return g.Fallback.AUSDUSD(ctx)
+}
+
+// BenchmarkCPM returns the primary's CPM if fresh, else the fallback's
+func (g *Guarded) BenchmarkCPM(ctx context.Context, format Format) (Quote, error) {
+	q, err := g.check(g.Primary.BenchmarkCPM(ctx, format))
+	if err == nil {
+		return q, nil
+	}
+	slog.Warn("benchmark CPM oracle unusable, using fallback", "format", format, "error", err)
+	return g.Fallback.BenchmarkCPM(ctx, format)
+}
+
+func (g *Guarded) check(q Quote, err error) (Quote, error) {
+	if err != nil {
+		return q, err
+	}
+	if !q.Price.IsPositive() {
+		return q, fmt.Errorf("%w: non-positive price %s", ErrNoPrice, q.Price)
+	}
+	if age := g.now().Sub(q.UpdatedAt); age > g.MaxAge {
+		return q, fmt.Errorf("%w: %s old", ErrStale, age.Round(time.Second))
+	}
+	return q, nil
+}
@@ -0,0 +1,57 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestGuarded_FallsBackWhenStale(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	feed := NewFeed()
+	feed.Set("AUSD/USD", Quote{Price: decimal.RequireFromString("0.98"), UpdatedAt: now.Add(-time.Minute)})
+	feed.Set(string(FormatCTV), Quote{Price: decimal.NewFromInt(30), UpdatedAt: now.Add(-time.Hour)})
+	feed.Set(string(FormatVideo), Quote{Price: decimal.Zero, UpdatedAt: now})
+
+	g := NewGuarded(feed, nil, 5*time.Minute)
+	g.now = func() time.Time { return now }
+
+	if q, err := g.AUSDUSD(ctx); err != nil || !q.Price.Equal(decimal.RequireFromString("0.98")) {
+		t.Errorf("fresh AUSD/USD = %v, %v; want the feed's 0.98", q.Price, err)
+	}
+	// Stale, zero and missing quotes all fall back to the defaults
+	for _, f := range []Format{FormatCTV, FormatVideo, FormatBanner} {
+		q, err := g.BenchmarkCPM(ctx, f)
+		if err != nil || !q.Price.Equal(DefaultBenchmarkCPMs[f]) {
+			t.Errorf("%s CPM = %v, %v; want fallback %s", f, q.Price, err, DefaultBenchmarkCPMs[f])
+		}
+	}
+
+	// Once the feed is stale too, AUSD is taken at par
+	g.now = func() time.Time { return now.Add(10 * time.Minute) }
+	if q, _ := g.AUSDUSD(ctx); !q.Price.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("stale AUSD/USD = %s, want fallback 1", q.Price)
+	}
+	if _, err := g.check(feed.AUSDUSD(ctx)); !errors.Is(err, ErrStale) {
+		t.Errorf("check = %v, want %v", err, ErrStale)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for placement, want := range map[string]Format{
+		"ctv-preroll":    FormatCTV,
+		"video-midroll":  FormatVideo,
+		"Native-feed":    FormatNative,
+		"audio-podcast":  FormatAudio,
+		"banner-300x250": FormatBanner,
+		"":               FormatBanner,
+	} {
+		if got := FormatOf(placement); got != want {
+			t.Errorf("FormatOf(%q) = %s, want %s", placement, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package halo2
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
 	"errors"
@@ -224,7 +225,7 @@ func (ac *AuctionCircuit) Setup() (*ProvingKey, *VerifyingKey, error) {
 // Prove generates a Halo2 proof of correct auction
 func (ac *AuctionCircuit) Prove(pk *ProvingKey, witness *AuctionWitness) (*Halo2Proof, error) {
 	// Validate witness
-	if witness.WinnerIndex >= ac.NumBids {
+	if witness.WinnerIndex >= ac.NumBids || !ac.satisfied(witness) {
 		return nil, ErrProvingFailed
 	}
 
@@ -238,11 +239,7 @@ func (ac *AuctionCircuit) Prove(pk *ProvingKey, witness *AuctionWitness) (*Halo2
 	}
 
 	// Commit to winner selection
-	winnerCommit := ac.poseidon.Hash([]*big.Int{
-		big.NewInt(int64(witness.WinnerIndex)),
-		witness.WinningBid,
-	})
-	commitments = append(commitments, winnerCommit.Bytes())
+	commitments = append(commitments, ac.WinnerCommitment(witness.WinnerIndex, witness.WinningBid))
 
 	// Commit to price
 	priceCommit := ac.poseidon.Hash([]*big.Int{witness.ClearingPrice})
@@ -274,6 +271,45 @@ func (ac *AuctionCircuit) Prove(pk *ProvingKey, witness *AuctionWitness) (*Halo2
 	return proof, nil
 }
 
+// WinnerCommitment commits to the bid at index winning with bid; a proof
+// verifies only against the commitment to the winner it was made for
+func (ac *AuctionCircuit) WinnerCommitment(index int, bid *big.Int) []byte {
+	return ac.poseidon.Hash([]*big.Int{big.NewInt(int64(index)), bid}).Bytes()
+}
+
+// satisfied checks the auction constraints on the witness: the winner
+// holds the highest bid, a tie for which it may share, the second price is
+// the best of the other bids and the clearing price is the second price
+// or the reserve, whichever is higher
+func (ac *AuctionCircuit) satisfied(witness *AuctionWitness) bool {
+	if len(witness.Bids) > ac.NumBids || witness.WinnerIndex < 0 || witness.WinnerIndex >= len(witness.Bids) {
+		return false
+	}
+	if witness.Bids[witness.WinnerIndex].Cmp(witness.WinningBid) != 0 {
+		return false
+	}
+	second := big.NewInt(0)
+	for i, bid := range witness.Bids {
+		if i == witness.WinnerIndex {
+			continue
+		}
+		if bid.Cmp(witness.WinningBid) > 0 {
+			return false
+		}
+		if bid.Cmp(second) > 0 {
+			second = bid
+		}
+	}
+	if second.Cmp(witness.SecondPrice) != 0 {
+		return false
+	}
+	clearing := second
+	if clearing.Cmp(ac.Reserve) < 0 {
+		clearing = ac.Reserve
+	}
+	return clearing.Cmp(witness.ClearingPrice) == 0
+}
+
 // computeQuotient computes the quotient polynomial
 func (ac *AuctionCircuit) computeQuotient(witness *AuctionWitness) *big.Int {
 	// Simplified quotient computation
@@ -335,6 +371,12 @@ func (ac *AuctionCircuit) Verify(vk *VerifyingKey, publicInputs *AuctionPublicIn
 		return false
 	}
 
+	// The winner commitment sits after the bid commitments, before the price
+	if !bytes.Equal(proof.WitnessCommitments[len(proof.WitnessCommitments)-2], publicInputs.WinnerCommit) {
+		ac.log.Debug("Winner mismatch")
+		return false
+	}
+
 	// Verify quotient polynomial commitment
 	if len(proof.QuotientCommitment) != 32 {
 		ac.log.Debug("Invalid quotient commitment")
@@ -0,0 +1,177 @@
+// Package replay drives an rtb.RTBExchange with recorded bidstreams, so
+// floor, pacing and other auction-config changes can be compared offline by
+// replaying the same stream against differently configured exchanges.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+var ErrEmptyStream = errors.New("bidstream has no requests")
+
+// Record is a recorded bid request and when the exchange received it
+type Record struct {
+	Time    time.Time            `json:"ts"`
+	Request *openrtb2.BidRequest `json:"request"`
+}
+
+// ReadRecords reads a JSONL bidstream. Each line is either a Record or a
+// bare OpenRTB bid request; bare requests carry no timing and are replayed
+// back to back.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		raw := bytes.TrimSpace(sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Request == nil {
+			rec.Request = new(openrtb2.BidRequest)
+			if err := json.Unmarshal(raw, rec.Request); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if rec.Request.ID == "" || len(rec.Request.Imp) == 0 {
+			return nil, fmt.Errorf("line %d: not a bid request", line)
+		}
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrEmptyStream
+	}
+	return records, nil
+}
+
+// LoadFile reads a JSONL bidstream from path
+func LoadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRecords(f)
+}
+
+// Options control replay timing
+type Options struct {
+	// Speed scales the recorded gaps between requests: 1 replays at the
+	// recorded pace, 10 ten times faster. Zero sends requests back to back.
+	Speed float64
+}
+
+// Stats aggregate a replay
+type Stats struct {
+	Requests int
+	Filled   int
+	Errors   int            // Requests the exchange refused
+	Revenue  float64        // Sum of clearing prices
+	Wins     map[string]int // Filled requests by winning seat
+
+	latencies []time.Duration
+}
+
+// FillRate is the share of requests that cleared
+func (s *Stats) FillRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Filled) / float64(s.Requests)
+}
+
+// AvgPrice is the mean clearing price of filled requests
+func (s *Stats) AvgPrice() float64 {
+	if s.Filled == 0 {
+		return 0
+	}
+	return s.Revenue / float64(s.Filled)
+}
+
+// Latency returns the q-quantile (0..1) of auction latency
+func (s *Stats) Latency(q float64) time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(q * float64(len(sorted)-1))
+	return sorted[i]
+}
+
+// Run replays records against exchange one auction at a time, in order.
+// Requests are copied before sending, so the same records can be replayed
+// against several exchanges. On cancellation it returns the stats so far
+// with ctx's error.
+func Run(ctx context.Context, exchange *rtb.RTBExchange, records []Record, opts Options) (*Stats, error) {
+	stats := &Stats{Wins: make(map[string]int)}
+	start := time.Now()
+
+	for i, rec := range records {
+		if opts.Speed > 0 && i > 0 && !rec.Time.IsZero() && !records[0].Time.IsZero() {
+			due := start.Add(time.Duration(float64(rec.Time.Sub(records[0].Time)) / opts.Speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return stats, ctx.Err()
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		req, err := clone(rec.Request)
+		if err != nil {
+			return stats, err
+		}
+
+		sent := time.Now()
+		resp, err := exchange.BidRequest(ctx, req)
+		stats.latencies = append(stats.latencies, time.Since(sent))
+		stats.Requests++
+		if err != nil {
+			stats.Errors++
+			continue
+		}
+		if len(resp.SeatBid) > 0 && len(resp.SeatBid[0].Bid) > 0 {
+			stats.Filled++
+			stats.Revenue += resp.SeatBid[0].Bid[0].Price
+			stats.Wins[resp.SeatBid[0].Seat]++
+		}
+	}
+	return stats, nil
+}
+
+// clone deep-copies a request; the exchange appends to its supply chain
+func clone(req *openrtb2.BidRequest) (*openrtb2.BidRequest, error) {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var out openrtb2.BidRequest
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/luxfi/adx/pkg/rtb/rtbtest"
+	"github.com/shopspring/decimal"
+)
+
+// newExchange starts an exchange with three reproducible DSPs
+func newExchange(t *testing.T, floor float64) *rtb.RTBExchange {
+	exchange := &rtb.RTBExchange{
+		AuctionTimeout: 200 * time.Millisecond,
+		FloorPrice:     decimal.NewFromFloat(floor),
+		Revenue:        big.NewInt(0),
+	}
+	dsps := rtbtest.StartMockDSPs(t, exchange, 3)
+	dsps[0].Set(rtbtest.WithHashedPrice(0.5, 2.5, 0.8, 1))
+	dsps[1].Set(rtbtest.WithHashedPrice(1.0, 4.0, 0.5, 2))
+	dsps[2].Set(rtbtest.WithHashedPrice(0.2, 1.5, 0.9, 3))
+	return exchange
+}
+
+func TestReplay_StableMetrics(t *testing.T) {
+	records, err := LoadFile("testdata/bidstream.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 12 || !records[3].Time.IsZero() || records[3].Request.ID != "rec-04" {
+		t.Fatalf("loaded %d records, record 4 = %+v", len(records), records[3])
+	}
+
+	first, err := Run(context.Background(), newExchange(t, 0), records, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Requests != 12 || first.Errors != 0 {
+		t.Fatalf("stats = %+v", first)
+	}
+	if first.Filled != 10 || math.Abs(first.Revenue-26.60) > 0.01 {
+		t.Errorf("filled %d for %.4f, want 10 for 26.60", first.Filled, first.Revenue)
+	}
+	if first.Latency(0.5) <= 0 || first.Latency(1) < first.Latency(0.5) {
+		t.Errorf("latency p50 = %v max = %v", first.Latency(0.5), first.Latency(1))
+	}
+
+	// The same stream against the same config gives the same result
+	second, err := Run(context.Background(), newExchange(t, 0), records, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Filled != first.Filled || second.Revenue != first.Revenue {
+		t.Errorf("second run filled %d for %v, first %d for %v", second.Filled, second.Revenue, first.Filled, first.Revenue)
+	}
+	for seat, wins := range first.Wins {
+		if second.Wins[seat] != wins {
+			t.Errorf("%s won %d then %d", seat, wins, second.Wins[seat])
+		}
+	}
+
+	// A higher floor trades fill for price
+	floored, err := Run(context.Background(), newExchange(t, 2), records, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if floored.FillRate() >= first.FillRate() || floored.AvgPrice() <= first.AvgPrice() {
+		t.Errorf("floor 2: fill %.2f avg %.2f, floor 0: fill %.2f avg %.2f",
+			floored.FillRate(), floored.AvgPrice(), first.FillRate(), first.AvgPrice())
+	}
+}
+
+func TestReplay_RecordedTiming(t *testing.T) {
+	records, err := LoadFile("testdata/bidstream.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := records[len(records)-1].Time.Sub(records[0].Time)
+
+	start := time.Now()
+	if _, err := Run(context.Background(), newExchange(t, 0), records, Options{Speed: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < span/50 {
+		t.Errorf("replay took %v, want at least %v at 50x", elapsed, span/50)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	stats, err := Run(ctx, newExchange(t, 0), records, Options{Speed: 1})
+	if err != context.DeadlineExceeded || stats.Requests == 0 || stats.Requests == len(records) {
+		t.Errorf("cancelled replay: %d requests, err = %v", stats.Requests, err)
+	}
+}
+
+func TestReadRecords_Invalid(t *testing.T) {
+	for _, in := range []string{"", "\n\n", `{"id":"x"}`, `{"ts":"2026-01-01T00:00:00Z"}`, `not json`} {
+		if _, err := ReadRecords(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadRecords(%q) succeeded", in)
+		}
+	}
+}
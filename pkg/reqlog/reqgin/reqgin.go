@@ -0,0 +1,38 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package reqgin carries reqlog correlation IDs through gin handlers.
+package reqgin
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/reqlog"
+)
+
+// Middleware assigns each request a correlation ID, reusing a well-formed
+// incoming reqlog.Header, and echoes it on the response
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(reqlog.Header)
+		if !reqlog.ValidID(id) {
+			id = reqlog.NewID()
+		}
+		c.Request = c.Request.WithContext(reqlog.WithID(c.Request.Context(), id))
+		c.Header(reqlog.Header, id)
+		c.Next()
+	}
+}
+
+// Context returns the request context of c, assigning a correlation ID if
+// Middleware hasn't run
+func Context(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	if reqlog.ID(ctx) == "" {
+		ctx = reqlog.WithID(ctx, reqlog.NewID())
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(reqlog.Header, reqlog.ID(ctx))
+	}
+	return ctx
+}
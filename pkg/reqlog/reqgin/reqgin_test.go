@@ -0,0 +1,44 @@
+package reqgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luxfi/adx/pkg/reqlog"
+)
+
+func TestMiddleware_AssignsAndEchoesID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	var seen string
+	r.GET("/", func(c *gin.Context) { seen = reqlog.ID(c.Request.Context()) })
+
+	serve := func(header string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(reqlog.Header, header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get(reqlog.Header); got != seen {
+			t.Fatalf("response header %q, handler saw %q", got, seen)
+		}
+		return seen
+	}
+
+	if id := serve(""); id == "" {
+		t.Error("missing ID was not generated")
+	}
+	if id := serve("lb-1234"); id != "lb-1234" {
+		t.Errorf("incoming ID = %q, want lb-1234", id)
+	}
+	for _, bad := range []string{"has space", strings.Repeat("x", reqlog.MaxIDLen+1)} {
+		if id := serve(bad); id == bad {
+			t.Errorf("malformed ID %q was reused", bad)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package reqlog carries a request correlation ID and related log fields
+// through a context so every layer handling a request logs the same ID.
+package reqlog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+// Header carries the correlation ID in and out of the exchange
+const Header = "X-ADX-Request-ID"
+
+// Standard log field keys
+const (
+	KeyRequestID = "request_id"
+	KeyPublisher = "publisher"
+	KeyDSP       = "dsp"
+	KeyAuction   = "auction_id"
+)
+
+// MaxIDLen bounds caller-supplied IDs so a client can't bloat every log line
+const MaxIDLen = 128
+
+type ctxKey struct{}
+
+type entry struct {
+	id    string
+	attrs []any
+}
+
+// NewID returns a fresh correlation ID
+func NewID() string {
+	return uuid.New().String()
+}
+
+// WithID returns a context carrying id as its correlation ID
+func WithID(ctx context.Context, id string) context.Context {
+	e := from(ctx)
+	return context.WithValue(ctx, ctxKey{}, &entry{id: id, attrs: e.attrs})
+}
+
+// ID returns the correlation ID carried by ctx, or ""
+func ID(ctx context.Context) string {
+	return from(ctx).id
+}
+
+// With returns a context whose logger adds the given key/value pairs, e.g.
+// reqlog.With(ctx, reqlog.KeyPublisher, pub)
+func With(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	e := from(ctx)
+	attrs := make([]any, 0, len(e.attrs)+len(args))
+	attrs = append(append(attrs, e.attrs...), args...)
+	return context.WithValue(ctx, ctxKey{}, &entry{id: e.id, attrs: attrs})
+}
+
+// Attrs returns the correlation ID and fields carried by ctx as slog
+// key/value pairs
+func Attrs(ctx context.Context) []any {
+	e := from(ctx)
+	if e.id == "" {
+		return e.attrs
+	}
+	return append([]any{KeyRequestID, e.id}, e.attrs...)
+}
+
+// Logger returns the default logger annotated with ctx's correlation ID
+// and fields
+func Logger(ctx context.Context) *slog.Logger {
+	return slog.Default().With(Attrs(ctx)...)
+}
+
+func from(ctx context.Context) *entry {
+	if e, ok := ctx.Value(ctxKey{}).(*entry); ok {
+		return e
+	}
+	return &entry{}
+}
+
+// ValidID reports whether a caller-supplied ID is fit to reuse: non-empty,
+// at most MaxIDLen long and printable ASCII without spaces
+func ValidID(id string) bool {
+	if id == "" || len(id) > MaxIDLen {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
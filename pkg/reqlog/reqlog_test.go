@@ -0,0 +1,34 @@
+package reqlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLogger_IncludesFields(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(prev)
+
+	ctx := WithID(context.Background(), "req-1")
+	ctx = With(ctx, KeyPublisher, "pub-1")
+	child := With(ctx, KeyDSP, "dsp-1")
+	Logger(child).Info("bid")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line[KeyRequestID] != "req-1" || line[KeyPublisher] != "pub-1" || line[KeyDSP] != "dsp-1" {
+		t.Errorf("log line = %v", line)
+	}
+
+	// Fields added to a child context don't leak into the parent
+	if got := Attrs(ctx); len(got) != 4 {
+		t.Errorf("parent attrs = %v", got)
+	}
+}
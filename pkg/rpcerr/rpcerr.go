@@ -0,0 +1,184 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package rpcerr defines the typed errors the chain VM and settlement RPCs
+// return. Each carries a machine-readable code for clients to branch on, a
+// kind that maps to gRPC and HTTP statuses at the boundary, and whether
+// the same request may succeed if retried.
+package rpcerr
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Domain identifies this service in gRPC error details
+const Domain = "adx.lux.network"
+
+// Kind classifies an error for status mapping
+type Kind int
+
+const (
+	// Invalid is bad input; the request will never succeed as sent
+	Invalid Kind = iota + 1
+
+	// NotFound is a reference to something that doesn't exist
+	NotFound
+
+	// Conflict is a create or reuse of something that already exists
+	Conflict
+
+	// Precondition is a request the current state doesn't allow
+	Precondition
+
+	// PermissionDenied is a caller acting on something it doesn't own
+	PermissionDenied
+
+	// Exhausted is a budget, supply or rate limit being hit
+	Exhausted
+
+	// Unavailable is a transient failure of a dependency
+	Unavailable
+)
+
+// Error is a typed RPC error. Sentinels are compared with errors.Is and
+// may be wrapped with detail using fmt.Errorf and %w.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Kind      Kind   `json:"-"`
+	Retryable bool   `json:"retryable"`
+}
+
+// New creates a terminal error
+func New(kind Kind, code, message string) *Error {
+	return &Error{Code: code, Message: message, Kind: kind}
+}
+
+// NewRetryable creates an error a client may retry
+func NewRetryable(kind Kind, code, message string) *Error {
+	return &Error{Code: code, Message: message, Kind: kind, Retryable: true}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// As returns the typed error in err's chain
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// Code returns err's machine-readable code, "internal" if it's untyped
+func Code(err error) string {
+	if e, ok := As(err); ok {
+		return e.Code
+	}
+	return "internal"
+}
+
+// IsRetryable reports whether retrying the request that failed with err
+// may succeed. Untyped errors aren't.
+func IsRetryable(err error) bool {
+	e, ok := As(err)
+	return ok && e.Retryable
+}
+
+var grpcCodes = map[Kind]codes.Code{
+	Invalid:          codes.InvalidArgument,
+	NotFound:         codes.NotFound,
+	Conflict:         codes.AlreadyExists,
+	Precondition:     codes.FailedPrecondition,
+	PermissionDenied: codes.PermissionDenied,
+	Exhausted:        codes.ResourceExhausted,
+	Unavailable:      codes.Unavailable,
+}
+
+var kinds = func() map[codes.Code]Kind {
+	m := make(map[codes.Code]Kind, len(grpcCodes))
+	for k, c := range grpcCodes {
+		m[c] = k
+	}
+	return m
+}()
+
+// GRPCCode maps err to a gRPC status code, Unknown if it's untyped
+func GRPCCode(err error) codes.Code {
+	if e, ok := As(err); ok {
+		if c, ok := grpcCodes[e.Kind]; ok {
+			return c
+		}
+	}
+	return codes.Unknown
+}
+
+// Status converts err to a gRPC status carrying its code and retryable
+// flag in an ErrorInfo detail (Reason is the code)
+func Status(err error) *status.Status {
+	st := status.New(GRPCCode(err), err.Error())
+	retryable := "false"
+	if IsRetryable(err) {
+		retryable = "true"
+	}
+	if detailed, derr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   Code(err),
+		Domain:   Domain,
+		Metadata: map[string]string{"retryable": retryable},
+	}); derr == nil {
+		return detailed
+	}
+	return st
+}
+
+// FromStatus recovers the typed error a Status carries, for gRPC clients
+func FromStatus(st *status.Status) (*Error, bool) {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == Domain {
+			return &Error{
+				Code:      info.GetReason(),
+				Message:   st.Message(),
+				Kind:      kinds[st.Code()],
+				Retryable: info.GetMetadata()["retryable"] == "true",
+			}, true
+		}
+	}
+	return nil, false
+}
+
+var httpStatuses = map[Kind]int{
+	Invalid:          http.StatusBadRequest,
+	NotFound:         http.StatusNotFound,
+	Conflict:         http.StatusConflict,
+	Precondition:     http.StatusUnprocessableEntity,
+	PermissionDenied: http.StatusForbidden,
+	Exhausted:        http.StatusTooManyRequests,
+	Unavailable:      http.StatusServiceUnavailable,
+}
+
+// HTTPStatus maps err to an HTTP status, 500 if it's untyped
+func HTTPStatus(err error) int {
+	if e, ok := As(err); ok {
+		if s, ok := httpStatuses[e.Kind]; ok {
+			return s
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// Body is the JSON error body HTTP handlers return
+type Body struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	Retryable bool   `json:"retryable"`
+}
+
+// BodyOf builds the HTTP error body for err
+func BodyOf(err error) Body {
+	return Body{Error: err.Error(), Code: Code(err), Retryable: IsRetryable(err)}
+}
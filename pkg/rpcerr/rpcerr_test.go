@@ -0,0 +1,62 @@
+package rpcerr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestClassification(t *testing.T) {
+	notFound := New(NotFound, "slot_not_found", "ad slot not found")
+	throttled := NewRetryable(Exhausted, "pacing_throttled", "campaign ahead of pacing schedule")
+	wrapped := fmt.Errorf("reservation failed: %w", throttled)
+
+	tests := []struct {
+		err       error
+		code      string
+		retryable bool
+		grpc      codes.Code
+		http      int
+	}{
+		{notFound, "slot_not_found", false, codes.NotFound, http.StatusNotFound},
+		{wrapped, "pacing_throttled", true, codes.ResourceExhausted, http.StatusTooManyRequests},
+		{New(Invalid, "bad", "bad"), "bad", false, codes.InvalidArgument, http.StatusBadRequest},
+		{New(Conflict, "dup", "dup"), "dup", false, codes.AlreadyExists, http.StatusConflict},
+		{errors.New("boom"), "internal", false, codes.Unknown, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := Code(tt.err); got != tt.code {
+			t.Errorf("Code(%v) = %s, want %s", tt.err, got, tt.code)
+		}
+		if got := IsRetryable(tt.err); got != tt.retryable {
+			t.Errorf("IsRetryable(%v) = %v", tt.err, got)
+		}
+		if got := GRPCCode(tt.err); got != tt.grpc {
+			t.Errorf("GRPCCode(%v) = %s, want %s", tt.err, got, tt.grpc)
+		}
+		if got := HTTPStatus(tt.err); got != tt.http {
+			t.Errorf("HTTPStatus(%v) = %d, want %d", tt.err, got, tt.http)
+		}
+	}
+	if !errors.Is(wrapped, throttled) {
+		t.Error("wrapped sentinel doesn't match errors.Is")
+	}
+	if b := BodyOf(wrapped); b.Error != "reservation failed: campaign ahead of pacing schedule" || b.Code != "pacing_throttled" || !b.Retryable {
+		t.Errorf("body = %+v", b)
+	}
+}
+
+func TestStatusRoundTrip(t *testing.T) {
+	err := fmt.Errorf("quote: %w", NewRetryable(Precondition, "slippage_exceeded", "swap slippage exceeds tolerance"))
+	st := Status(err)
+	if st.Code() != codes.FailedPrecondition || st.Message() != err.Error() {
+		t.Errorf("status = %s %q", st.Code(), st.Message())
+	}
+	got, ok := FromStatus(st)
+	if !ok || got.Code != "slippage_exceeded" || !got.Retryable || got.Kind != Precondition {
+		t.Errorf("FromStatus = %+v, %v", got, ok)
+	}
+}
@@ -0,0 +1,418 @@
+package rtb
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// Admin API defaults
+const (
+	DefaultAdminQPS = 10
+	maxAdminBody    = 1 << 20
+	maxAdminClients = 10000
+)
+
+// PartnerStore persists the partner registry across restarts
+type PartnerStore interface {
+	Load() (Partners, error)
+	Save(Partners) error
+}
+
+// AdminHandler serves the partner management API: DSPs and SSPs are
+// listed, added, updated, paused, resumed and removed on the running
+// exchange, and the registry saved to Store after every change.
+//
+//	GET    /admin/dsps             POST /admin/dsps
+//	GET    /admin/dsps/{id}        PUT  /admin/dsps/{id}
+//	DELETE /admin/dsps/{id}
+//	POST   /admin/dsps/{id}/pause  POST /admin/dsps/{id}/resume
+//
+// and the same under /admin/ssps. When the exchange keeps an audit log,
+//
+//	GET    /admin/audit            GET  /admin/audit/{id}
+//
+// export its auctions as JSON lines, or one auction by ID.
+//
+//	POST   /admin/simulate
+//
+// replays a request among given bids, or an audited auction, under a
+// hypothetical config; see SimulateAuction.
+//
+//	GET    /admin/placements
+//
+// reports fill and revenue by placement type when the exchange keeps
+// Placements. Requests need Token as a bearer token, and each client IP
+// is held to QPS requests per second, failed ones included, so the token
+// can't be guessed at speed.
+type AdminHandler struct {
+	Exchange *RTBExchange
+	Token    string       // Every request is refused when empty
+	Store    PartnerStore // Changes aren't persisted when nil
+	QPS      int          // Per client; DefaultAdminQPS when zero
+
+	mux     *http.ServeMux
+	mu      sync.Mutex
+	clients map[string]*RateLimiter
+	saveMu  sync.Mutex
+}
+
+// NewAdminHandler creates the admin API for exchange
+func NewAdminHandler(exchange *RTBExchange, token string, store PartnerStore) *AdminHandler {
+	h := &AdminHandler{
+		Exchange: exchange,
+		Token:    token,
+		Store:    store,
+		mux:      http.NewServeMux(),
+		clients:  make(map[string]*RateLimiter),
+	}
+	h.mux.HandleFunc("GET /admin/dsps", h.listDSPs)
+	h.mux.HandleFunc("POST /admin/dsps", h.addDSP)
+	h.mux.HandleFunc("GET /admin/dsps/{id}", h.getDSP)
+	h.mux.HandleFunc("PUT /admin/dsps/{id}", h.updateDSP)
+	h.mux.HandleFunc("DELETE /admin/dsps/{id}", h.removeDSP)
+	h.mux.HandleFunc("POST /admin/dsps/{id}/pause", h.pauseDSP(true))
+	h.mux.HandleFunc("POST /admin/dsps/{id}/resume", h.pauseDSP(false))
+
+	h.mux.HandleFunc("GET /admin/ssps", h.listSSPs)
+	h.mux.HandleFunc("POST /admin/ssps", h.addSSP)
+	h.mux.HandleFunc("GET /admin/ssps/{id}", h.getSSP)
+	h.mux.HandleFunc("PUT /admin/ssps/{id}", h.updateSSP)
+	h.mux.HandleFunc("DELETE /admin/ssps/{id}", h.removeSSP)
+	h.mux.HandleFunc("POST /admin/ssps/{id}/pause", h.pauseSSP(true))
+	h.mux.HandleFunc("POST /admin/ssps/{id}/resume", h.pauseSSP(false))
+
+	h.mux.HandleFunc("GET /admin/audit", h.exportAudit)
+	h.mux.HandleFunc("GET /admin/audit/{id}", h.getAudit)
+
+	h.mux.HandleFunc("POST /admin/simulate", h.simulate)
+
+	h.mux.HandleFunc("GET /admin/placements", h.placements)
+	return h
+}
+
+// ServeHTTP rate limits and authenticates the request before routing it
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.limiter(clientIP(r)).Allow() {
+		w.Header().Set("Retry-After", "1")
+		writeAdminError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="adx-admin"`)
+		writeAdminError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *AdminHandler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+// limiter returns the client's token bucket. The clients seen are
+// forgotten wholesale once there are too many to keep.
+func (h *AdminHandler) limiter(client string) *RateLimiter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if rl, ok := h.clients[client]; ok {
+		return rl
+	}
+	if len(h.clients) >= maxAdminClients {
+		clear(h.clients)
+	}
+	qps := h.QPS
+	if qps <= 0 {
+		qps = DefaultAdminQPS
+	}
+	rl := NewRateLimiter(qps)
+	h.clients[client] = rl
+	return rl
+}
+
+// dspView is a DSP's configuration with its counters
+type dspView struct {
+	DSPSpec
+	RequestCount uint64 `json:"request_count"`
+	BidCount     uint64 `json:"bid_count"`
+	WinCount     uint64 `json:"win_count"`
+	ErrorCount   uint64 `json:"error_count"`
+}
+
+func viewDSP(d *DSPConnection) dspView {
+	return dspView{
+		DSPSpec:      d.Spec(),
+		RequestCount: atomic.LoadUint64(&d.RequestCount),
+		BidCount:     atomic.LoadUint64(&d.BidCount),
+		WinCount:     atomic.LoadUint64(&d.WinCount),
+		ErrorCount:   atomic.LoadUint64(&d.ErrorCount),
+	}
+}
+
+func (h *AdminHandler) listDSPs(w http.ResponseWriter, r *http.Request) {
+	specs := h.Exchange.Partners().DSPs
+	views := make([]dspView, 0, len(specs))
+	for _, spec := range specs {
+		if d, ok := h.Exchange.DSP(spec.ID); ok {
+			views = append(views, viewDSP(d))
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, views)
+}
+
+func (h *AdminHandler) getDSP(w http.ResponseWriter, r *http.Request) {
+	d, ok := h.Exchange.DSP(r.PathValue("id"))
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, ErrPartnerNotFound.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, viewDSP(d))
+}
+
+func (h *AdminHandler) addDSP(w http.ResponseWriter, r *http.Request) {
+	var spec DSPSpec
+	if !decodeAdmin(w, r, &spec) {
+		return
+	}
+	h.change(w, http.StatusCreated, func() error { return h.Exchange.AddDSP(spec) }, h.dspResult(spec.ID))
+}
+
+func (h *AdminHandler) updateDSP(w http.ResponseWriter, r *http.Request) {
+	var spec DSPSpec
+	if !decodeAdmin(w, r, &spec) || !matchID(w, r, &spec.ID) {
+		return
+	}
+	h.change(w, http.StatusOK, func() error { return h.Exchange.UpdateDSP(spec) }, h.dspResult(spec.ID))
+}
+
+func (h *AdminHandler) removeDSP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	h.change(w, http.StatusNoContent, func() error { return h.Exchange.RemoveDSP(id) }, nil)
+}
+
+func (h *AdminHandler) pauseDSP(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		h.change(w, http.StatusOK, func() error { return h.Exchange.PauseDSP(id, paused) }, h.dspResult(id))
+	}
+}
+
+func (h *AdminHandler) dspResult(id string) func() any {
+	return func() any {
+		d, _ := h.Exchange.DSP(id)
+		return viewDSP(d)
+	}
+}
+
+func (h *AdminHandler) listSSPs(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, http.StatusOK, h.Exchange.Partners().SSPs)
+}
+
+func (h *AdminHandler) getSSP(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.Exchange.SSP(r.PathValue("id"))
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, ErrPartnerNotFound.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, s.Spec())
+}
+
+func (h *AdminHandler) addSSP(w http.ResponseWriter, r *http.Request) {
+	var spec SSPSpec
+	if !decodeAdmin(w, r, &spec) {
+		return
+	}
+	h.change(w, http.StatusCreated, func() error { return h.Exchange.AddSSP(spec) }, h.sspResult(spec.ID))
+}
+
+func (h *AdminHandler) updateSSP(w http.ResponseWriter, r *http.Request) {
+	var spec SSPSpec
+	if !decodeAdmin(w, r, &spec) || !matchID(w, r, &spec.ID) {
+		return
+	}
+	h.change(w, http.StatusOK, func() error { return h.Exchange.UpdateSSP(spec) }, h.sspResult(spec.ID))
+}
+
+func (h *AdminHandler) removeSSP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	h.change(w, http.StatusNoContent, func() error { return h.Exchange.RemoveSSP(id) }, nil)
+}
+
+func (h *AdminHandler) pauseSSP(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		h.change(w, http.StatusOK, func() error { return h.Exchange.PauseSSP(id, paused) }, h.sspResult(id))
+	}
+}
+
+func (h *AdminHandler) sspResult(id string) func() any {
+	return func() any {
+		s, _ := h.Exchange.SSP(id)
+		return s.Spec()
+	}
+}
+
+// change applies a registry change, persists the registry and answers
+// with result's partner, or no body when result is nil
+func (h *AdminHandler) change(w http.ResponseWriter, status int, apply func() error, result func() any) {
+	// Saving under the lock keeps concurrent changes from being persisted
+	// out of order
+	h.saveMu.Lock()
+	err := apply()
+	var body any
+	if err == nil && result != nil {
+		body = result()
+	}
+	if err == nil && h.Store != nil {
+		if err = h.Store.Save(h.Exchange.Partners()); err != nil {
+			slog.Error("partner registry not saved", "error", err)
+			err = fmt.Errorf("applied but not saved: %w", err)
+		}
+	}
+	h.saveMu.Unlock()
+
+	switch {
+	case errors.Is(err, ErrInvalidPartner):
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrPartnerExists):
+		writeAdminError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrPartnerNotFound):
+		writeAdminError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+	case body == nil:
+		w.WriteHeader(status)
+	default:
+		writeAdminJSON(w, status, body)
+	}
+}
+
+// decodeAdmin reads a request body into v, answering 400 when it can't
+func decodeAdmin(w http.ResponseWriter, r *http.Request, v any) bool {
+	return decodeAdminAs(w, r, v, ErrInvalidPartner)
+}
+
+// decodeAdminAs decodes the body into v, answering 400 with kind when
+// it's malformed
+func decodeAdminAs(w http.ResponseWriter, r *http.Request, v any, kind error) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("%v: %v", kind, err))
+		return false
+	}
+	return true
+}
+
+// matchID fills in the body's ID from the path, answering 400 when the
+// body names another partner
+func matchID(w http.ResponseWriter, r *http.Request, id *string) bool {
+	path := r.PathValue("id")
+	if *id != "" && *id != path {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("%v: id %q does not match path", ErrInvalidPartner, *id))
+		return false
+	}
+	*id = path
+	return true
+}
+
+func (h *AdminHandler) exportAudit(w http.ResponseWriter, r *http.Request) {
+	if h.Exchange.Audit == nil {
+		writeAdminError(w, http.StatusNotFound, "auctions aren't audited")
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if err := h.Exchange.Audit.WriteJSONL(w); err != nil {
+		slog.Warn("audit export failed", "error", err)
+	}
+}
+
+func (h *AdminHandler) getAudit(w http.ResponseWriter, r *http.Request) {
+	var a *AuctionAudit
+	ok := h.Exchange.Audit != nil
+	if ok {
+		a, ok = h.Exchange.Audit.Audit(r.PathValue("id"))
+	}
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, "auction not audited")
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, a)
+}
+
+func (h *AdminHandler) placements(w http.ResponseWriter, r *http.Request) {
+	if h.Exchange.Placements == nil {
+		writeAdminError(w, http.StatusNotFound, "placement reporting disabled")
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, h.Exchange.Placements.Report())
+}
+
+// simulateBody replays either an audited auction, by ID, or a request
+// among the bids given
+type simulateBody struct {
+	AuctionID string               `json:"auction_id,omitempty"`
+	Request   *openrtb2.BidRequest `json:"request,omitempty"`
+	Bids      []AuditBid           `json:"bids,omitempty"`
+	Config    SimulationConfig     `json:"config"`
+}
+
+func (h *AdminHandler) simulate(w http.ResponseWriter, r *http.Request) {
+	var body simulateBody
+	if !decodeAdminAs(w, r, &body, ErrInvalidSimulation) {
+		return
+	}
+	var req *openrtb2.BidRequest
+	var bids []Bid
+	switch {
+	case body.AuctionID != "":
+		var a *AuctionAudit
+		ok := h.Exchange.Audit != nil
+		if ok {
+			a, ok = h.Exchange.Audit.Audit(body.AuctionID)
+		}
+		if !ok {
+			writeAdminError(w, http.StatusNotFound, "auction not audited")
+			return
+		}
+		req, bids = a.Replay()
+	case body.Request != nil && len(body.Request.Imp) > 0:
+		req = body.Request
+		for _, ab := range body.Bids {
+			bids = append(bids, ab.bid())
+		}
+	default:
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("%v: an auction_id or a request with impressions is required", ErrInvalidSimulation))
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, h.Exchange.SimulateAuction(req, bids, body.Config))
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, msg string) {
+	writeAdminJSON(w, status, map[string]string{"error": msg})
+}
+
+// clientIP is the address a request came from, without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,200 @@
+package rtb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+const testAdminToken = "s3cret"
+
+// adminCall sends an authenticated admin request and decodes the response
+func adminCall(t *testing.T, h http.Handler, method, path string, body, out any) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if out != nil && rec.Code < 300 {
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestAdminHandler_ManagesLiveDSP(t *testing.T) {
+	var requests atomic.Int64
+	dsp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req openrtb2.BidRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{ID: req.ID, SeatBid: []openrtb2.SeatBid{{
+			Seat: "seat-live",
+			Bid:  []openrtb2.Bid{{ID: "b1", ImpID: req.Imp[0].ID, Price: 3, AdM: "<VAST/>"}},
+		}}})
+	}))
+	defer dsp.Close()
+
+	exchange := &RTBExchange{AuctionTimeout: 200 * time.Millisecond, Revenue: big.NewInt(0)}
+	store := PartnerFile{Path: filepath.Join(t.TempDir(), "partners.json")}
+	h := NewAdminHandler(exchange, testAdminToken, store)
+
+	auction := func() *openrtb2.BidResponse {
+		t.Helper()
+		resp, err := exchange.BidRequest(context.Background(), &openrtb2.BidRequest{ID: "auc", Imp: []openrtb2.Imp{{ID: "1"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	spec := DSPSpec{ID: "live", Endpoint: dsp.URL, QPS: 100, TimeoutMS: 150, SeatID: "seat-live"}
+	if code := adminCall(t, h, http.MethodPost, "/admin/dsps", spec, nil); code != http.StatusCreated {
+		t.Fatalf("add DSP = %d", code)
+	}
+	if code := adminCall(t, h, http.MethodPost, "/admin/dsps", spec, nil); code != http.StatusConflict {
+		t.Errorf("adding it twice = %d, want 409", code)
+	}
+	if resp := auction(); len(resp.SeatBid) != 1 || resp.SeatBid[0].Bid[0].Price != 3 {
+		t.Fatalf("added DSP didn't win the auction: %+v", resp)
+	}
+
+	spec.QPS = 1
+	var view dspView
+	if code := adminCall(t, h, http.MethodPut, "/admin/dsps/live", spec, &view); code != http.StatusOK || view.QPS != 1 {
+		t.Fatalf("update DSP = %d, %+v", code, view)
+	}
+	if view.RequestCount != 1 {
+		t.Errorf("request count = %d after update, want 1 carried over", view.RequestCount)
+	}
+	before := requests.Load()
+	for range 3 {
+		auction()
+	}
+	if got := requests.Load() - before; got != 1 {
+		t.Errorf("DSP at 1 QPS got %d of 3 requests, want 1", got)
+	}
+
+	if code := adminCall(t, h, http.MethodPost, "/admin/dsps/live/pause", nil, &view); code != http.StatusOK || !view.Paused {
+		t.Fatalf("pause DSP = %d, %+v", code, view)
+	}
+	time.Sleep(time.Second) // Refill the bucket so only the pause holds requests back
+	before = requests.Load()
+	if resp := auction(); len(resp.SeatBid) != 0 || requests.Load() != before {
+		t.Error("paused DSP was sent a bid request")
+	}
+	if code := adminCall(t, h, http.MethodPost, "/admin/dsps/live/resume", nil, nil); code != http.StatusOK {
+		t.Fatalf("resume DSP = %d", code)
+	}
+	if resp := auction(); len(resp.SeatBid) != 1 {
+		t.Error("resumed DSP didn't bid")
+	}
+
+	// The registry survives a restart
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := &RTBExchange{}
+	if err := restarted.RestorePartners(saved); err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := restarted.DSP("live"); !ok || d.QPS != 1 || d.Endpoint != dsp.URL || d.Paused {
+		t.Errorf("restored DSP = %+v", d)
+	}
+
+	if code := adminCall(t, h, http.MethodDelete, "/admin/dsps/live", nil, nil); code != http.StatusNoContent {
+		t.Fatalf("remove DSP = %d", code)
+	}
+	if _, ok := exchange.DSP("live"); ok {
+		t.Error("removed DSP still connected")
+	}
+}
+
+func TestAdminHandler_Validation(t *testing.T) {
+	exchange := &RTBExchange{}
+	h := NewAdminHandler(exchange, testAdminToken, nil)
+
+	for _, spec := range []DSPSpec{
+		{Endpoint: "http://dsp.example/bid", QPS: 10, TimeoutMS: 80},
+		{ID: "d", Endpoint: "dsp.example/bid", QPS: 10, TimeoutMS: 80},
+		{ID: "d", Endpoint: "http://dsp.example/bid", QPS: 0, TimeoutMS: 80},
+		{ID: "d", Endpoint: "http://dsp.example/bid", QPS: 10, TimeoutMS: 60000},
+	} {
+		if code := adminCall(t, h, http.MethodPost, "/admin/dsps", spec, nil); code != http.StatusBadRequest {
+			t.Errorf("add %+v = %d, want 400", spec, code)
+		}
+	}
+	if code := adminCall(t, h, http.MethodPut, "/admin/dsps/other", DSPSpec{ID: "d"}, nil); code != http.StatusBadRequest {
+		t.Errorf("mismatched ID = %d, want 400", code)
+	}
+	if code := adminCall(t, h, http.MethodPost, "/admin/ssps", SSPSpec{ID: "s", RevShare: 1.5}, nil); code != http.StatusBadRequest {
+		t.Errorf("rev share over 1 = %d, want 400", code)
+	}
+	if code := adminCall(t, h, http.MethodPost, "/admin/dsps/missing/pause", nil, nil); code != http.StatusNotFound {
+		t.Errorf("pause unknown DSP = %d, want 404", code)
+	}
+}
+
+func TestAdminHandler_PausedSSP(t *testing.T) {
+	exchange := &RTBExchange{}
+	h := NewAdminHandler(exchange, testAdminToken, nil)
+	if code := adminCall(t, h, http.MethodPost, "/admin/ssps", SSPSpec{ID: "ssp", PublisherID: "pub-1", RevShare: 0.8}, nil); code != http.StatusCreated {
+		t.Fatalf("add SSP = %d", code)
+	}
+	if code := adminCall(t, h, http.MethodPost, "/admin/ssps/ssp/pause", nil, nil); code != http.StatusOK {
+		t.Fatalf("pause SSP = %d", code)
+	}
+	if s, _ := exchange.SSP("ssp"); !s.Paused || s.RevShare != 0.8 {
+		t.Fatalf("paused SSP = %+v", s)
+	}
+	if _, ok := exchange.pausedSSP("pub-1"); !ok {
+		t.Error("paused SSP's publisher still sold")
+	}
+}
+
+func TestAdminHandler_AuthAndRateLimit(t *testing.T) {
+	h := NewAdminHandler(&RTBExchange{}, testAdminToken, nil)
+	h.QPS = 2
+
+	send := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/dsps", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := send("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token = %d, want 401", code)
+	}
+	if code := send(testAdminToken); code != http.StatusOK {
+		t.Errorf("right token = %d, want 200", code)
+	}
+	if code := send(testAdminToken); code != http.StatusTooManyRequests {
+		t.Errorf("third request in a second = %d, want 429", code)
+	}
+
+	// Without a token configured nothing gets in
+	open := NewAdminHandler(&RTBExchange{}, "", nil)
+	req := httptest.NewRequest(http.MethodGet, "/admin/dsps", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	open.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("empty token = %d, want 401", rec.Code)
+	}
+}
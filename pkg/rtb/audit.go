@@ -0,0 +1,269 @@
+package rtb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/luxfi/adx/pkg/reqlog"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+)
+
+// DefaultAuditSize is how many auctions an AuditLog keeps
+const DefaultAuditSize = 10000
+
+// Bid outcomes in an audit record
+const (
+	OutcomeWon      = "won"
+	OutcomeLost     = "lost"     // Eligible, but outbid
+	OutcomeRejected = "rejected" // Filtered out of the auction
+)
+
+// Reasons a bid didn't win
+const (
+	ReasonOutbid            = "outbid"
+	ReasonLostToDeal        = "lost_to_deal"
+	ReasonBelowFloor        = "below_floor"
+	ReasonDealMismatch      = "deal_mismatch"
+	ReasonCategoryBlocked   = "category_blocked"
+	ReasonAdvertiserBlocked = "advertiser_blocked"
+	ReasonBrandUnsafe       = "brand_unsafe"
+	ReasonCapped            = "capped"
+	ReasonQuality           = "quality"
+	ReasonCurrency          = "currency"
+	ReasonFiltered          = "filtered"
+)
+
+// AuctionAudit is the record of one auction: what was asked, every bid
+// received, what became of each and why, and the result
+type AuctionAudit struct {
+	AuctionID     string       `json:"auction_id"`
+	Time          time.Time    `json:"time"`
+	Request       AuditRequest `json:"request"`
+	Bids          []AuditBid   `json:"bids"`
+	Winner        string       `json:"winner,omitempty"` // Winning bid's ID
+	ClearingPrice float64      `json:"clearing_price,omitempty"`
+	Currency      string       `json:"cur"`
+
+	// ProofRef commits to the rest of the record, so it can be anchored
+	// and checked against later; see Digest
+	ProofRef string `json:"proof_ref"`
+}
+
+// AuditRequest summarizes the bid request
+type AuditRequest struct {
+	Publisher string     `json:"publisher,omitempty"`
+	Domain    string     `json:"domain,omitempty"`
+	App       bool       `json:"app,omitempty"`
+	Imps      []AuditImp `json:"imps"`
+	BCat      []string   `json:"bcat,omitempty"`
+	BAdv      []string   `json:"badv,omitempty"`
+	TMax      int64      `json:"tmax,omitempty"`
+	AT        int64      `json:"at,omitempty"` // Auction type; 2 for second price
+}
+
+// AuditImp is an impression and the floor it was sold against
+type AuditImp struct {
+	ID    string   `json:"id"`
+	TagID string   `json:"tagid,omitempty"`
+	Floor float64  `json:"floor"`
+	Deals []string `json:"deals,omitempty"`
+}
+
+// AuditBid is a bid and its outcome. LossReason is its OpenRTB loss
+// reason code.
+type AuditBid struct {
+	ID         string              `json:"id"`
+	ImpID      string              `json:"impid"`
+	DSP        string              `json:"dsp"`
+	Seat       string              `json:"seat,omitempty"`
+	DealID     string              `json:"dealid,omitempty"`
+	Price      float64             `json:"price"`
+	Advertiser string              `json:"adomain,omitempty"`
+	Categories []string            `json:"cat,omitempty"`
+	Outcome    string              `json:"outcome"`
+	Reason     string              `json:"reason,omitempty"`
+	Detail     string              `json:"detail,omitempty"`
+	LossReason openrtb3.LossReason `json:"loss_reason"`
+}
+
+// Digest is the hex SHA-256 of the record's JSON without its ProofRef
+func (a *AuctionAudit) Digest() string {
+	c := *a
+	c.ProofRef = ""
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// audit records an auction, given the bids it turned away and why
+func (rtb *RTBExchange) audit(req *openrtb2.BidRequest, bids []Bid, winner *Bid, rejected map[*Bid]error) *AuctionAudit {
+	publisher, domain, app := publisherOf(req)
+	a := &AuctionAudit{
+		AuctionID: req.ID,
+		Time:      time.Now().UTC(),
+		Request: AuditRequest{
+			Publisher: publisher,
+			Domain:    domain,
+			App:       app,
+			BCat:      req.BCat,
+			BAdv:      req.BAdv,
+			TMax:      req.TMax,
+			AT:        req.AT,
+		},
+		Bids:     make([]AuditBid, 0, len(bids)),
+		Currency: exchangeCurrency,
+	}
+	for _, imp := range req.Imp {
+		ai := AuditImp{ID: imp.ID, TagID: imp.TagID, Floor: rtb.floorFor(req, imp.ID)}
+		if imp.PMP != nil {
+			for _, d := range imp.PMP.Deals {
+				ai.Deals = append(ai.Deals, d.ID)
+			}
+		}
+		a.Request.Imps = append(a.Request.Imps, ai)
+	}
+	for i := range bids {
+		bid := &bids[i]
+		ab := AuditBid{
+			ID:         bid.ID,
+			ImpID:      bid.ImpID,
+			DSP:        bid.DSP,
+			Seat:       bid.SeatID,
+			DealID:     bid.DealID,
+			Price:      bid.Price,
+			Advertiser: bid.Advertiser,
+			Categories: bid.Categories,
+		}
+		ab.Outcome, ab.Reason, ab.LossReason = rtb.auditOutcome(req, bid, winner, rejected)
+		if err := rejected[bid]; err != nil && bid != winner {
+			ab.Detail = err.Error()
+		}
+		a.Bids = append(a.Bids, ab)
+	}
+	if winner != nil {
+		a.Winner, a.ClearingPrice = winner.ID, winner.Price
+	}
+	a.ProofRef = a.Digest()
+	return a
+}
+
+// auditOutcome classifies a bid the way the auction treated it
+func (rtb *RTBExchange) auditOutcome(req *openrtb2.BidRequest, bid, winner *Bid, rejected map[*Bid]error) (string, string, openrtb3.LossReason) {
+	if bid == winner {
+		return OutcomeWon, "", openrtb3.LossWon
+	}
+	if err := rejected[bid]; err != nil {
+		switch {
+		case errors.Is(err, ErrSeatOverBudget):
+			return OutcomeRejected, ReasonCapped, openrtb3.LossSeatBlocked
+		case errors.Is(err, ErrBidOverCap), errors.Is(err, ErrBidOverCeiling), errors.Is(err, ErrFrequencyCapped):
+			return OutcomeRejected, ReasonCapped, openrtb3.LossCreativeFiltered
+		case errors.Is(err, ErrBlockedDomain):
+			return OutcomeRejected, ReasonQuality, openrtb3.LossAdvertiserExclusions
+		case errors.Is(err, ErrBlockedAttr):
+			return OutcomeRejected, ReasonQuality, openrtb3.LossAttributeExclusions
+		case errors.Is(err, ErrCreativeQuality):
+			return OutcomeRejected, ReasonQuality, openrtb3.LossDisapproved
+		case errors.Is(err, ErrCreativeTooLarge):
+			return OutcomeRejected, ReasonQuality, openrtb3.LossSizeNotAllowed
+		case errors.Is(err, ErrCreativeMIME):
+			return OutcomeRejected, ReasonQuality, openrtb3.LossIncorrectFormat
+		case errors.Is(err, ErrBidPanic):
+			return OutcomeRejected, ReasonFiltered, openrtb3.LossInternalError
+		}
+		return OutcomeRejected, ReasonFiltered, openrtb3.LossCreativeFiltered
+	}
+	switch {
+	case !inExchangeCurrency(bid):
+		return OutcomeRejected, ReasonCurrency, openrtb3.LossInvalidResponse
+	case bid.Price < rtb.floorFor(req, bid.ImpID):
+		return OutcomeRejected, ReasonBelowFloor, openrtb3.LossBelowAuctionFloor
+	case dealMismatch(req, bid):
+		return OutcomeRejected, ReasonDealMismatch, openrtb3.LossInvalidDealID
+	case rtb.blockedCategory(req, bid):
+		return OutcomeRejected, ReasonCategoryBlocked, openrtb3.LossCategoryExclusions
+	case bid.Advertiser != "" && containsAny(req.BAdv, []string{bid.Advertiser}):
+		return OutcomeRejected, ReasonAdvertiserBlocked, openrtb3.LossAdvertiserExclusions
+	case !rtb.checkBrandSafety(bid, req):
+		return OutcomeRejected, ReasonBrandUnsafe, openrtb3.LossCreativeFiltered
+	case winner == nil:
+		return OutcomeRejected, ReasonFiltered, openrtb3.LossCreativeFiltered
+	case winner.DealID != "" && bid.DealID == "":
+		return OutcomeLost, ReasonLostToDeal, openrtb3.LossLostToDealBid
+	}
+	return OutcomeLost, ReasonOutbid, openrtb3.LossLostToHigherBid
+}
+
+// AuditLog keeps the most recent auctions' audit records, retrievable by
+// auction ID, and appends each to Sink as a JSON line when it's set
+type AuditLog struct {
+	mu      sync.Mutex
+	size    int
+	records map[string]*AuctionAudit
+	order   []string // Ring of auction IDs, oldest at next once full
+	next    int
+	sink    io.Writer
+}
+
+// NewAuditLog creates a log keeping size records, DefaultAuditSize when
+// zero, and streaming every record to sink unless it's nil
+func NewAuditLog(size int, sink io.Writer) *AuditLog {
+	if size <= 0 {
+		size = DefaultAuditSize
+	}
+	return &AuditLog{size: size, records: make(map[string]*AuctionAudit), sink: sink}
+}
+
+// Record keeps an auction's audit record
+func (l *AuditLog) Record(a *AuctionAudit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.records[a.AuctionID]; !ok {
+		if len(l.order) < l.size {
+			l.order = append(l.order, a.AuctionID)
+		} else {
+			delete(l.records, l.order[l.next])
+			l.order[l.next] = a.AuctionID
+			l.next = (l.next + 1) % l.size
+		}
+	}
+	l.records[a.AuctionID] = a
+	if l.sink != nil {
+		if err := json.NewEncoder(l.sink).Encode(a); err != nil {
+			slog.Warn("audit record not written", reqlog.KeyAuction, a.AuctionID, "error", err)
+		}
+	}
+}
+
+// Audit returns an auction's record
+func (l *AuditLog) Audit(auctionID string) (*AuctionAudit, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, ok := l.records[auctionID]
+	return a, ok
+}
+
+// WriteJSONL writes the records kept, oldest first, one per line
+func (l *AuditLog) WriteJSONL(w io.Writer) error {
+	l.mu.Lock()
+	records := make([]*AuctionAudit, 0, len(l.order))
+	for i := range l.order {
+		records = append(records, l.records[l.order[(l.next+i)%len(l.order)]])
+	}
+	l.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	for _, a := range records {
+		if err := enc.Encode(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package rtb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+	"github.com/shopspring/decimal"
+)
+
+func TestAudit_BidOutcomes(t *testing.T) {
+	exchange := &RTBExchange{
+		FloorPrice: decimal.NewFromFloat(0.50),
+		BidGuard:   NewBidGuard(SeatLimits{MaxBid: 5}),
+	}
+	req := &openrtb2.BidRequest{
+		ID: "auc-1",
+		Imp: []openrtb2.Imp{{
+			ID:  "1",
+			PMP: &openrtb2.PMP{Deals: []openrtb2.Deal{{ID: "deal-1"}}},
+		}},
+		Site: &openrtb2.Site{Domain: "news.example", Publisher: &openrtb2.Publisher{ID: "pub-1"}},
+		BCat: []string{"IAB25"},
+		BAdv: []string{"rival.example"},
+	}
+	bids := []Bid{
+		{ID: "low", DSP: "dsp1", ImpID: "1", Price: 0.20},
+		{ID: "wrong-deal", DSP: "dsp1", ImpID: "1", Price: 4.50, DealID: "deal-9"},
+		{ID: "unsafe-cat", DSP: "dsp2", ImpID: "1", Price: 4.50, Categories: []string{"IAB25-3"}},
+		{ID: "blocked-adv", DSP: "dsp2", ImpID: "1", Price: 4.50, Advertiser: "rival.example"},
+		{ID: "over-cap", DSP: "dsp3", ImpID: "1", Price: 9, SeatID: "s3"},
+		{ID: "deal", DSP: "dsp3", ImpID: "1", Price: 4, DealID: "deal-1"},
+		{ID: "open", DSP: "dsp1", ImpID: "1", Price: 3},
+		{ID: "deal-low", DSP: "dsp2", ImpID: "1", Price: 2.50, DealID: "deal-1"},
+	}
+	winner, rejected := exchange.auction(context.Background(), bids, req)
+	if winner == nil || winner.ID != "deal" {
+		t.Fatalf("winner = %+v, want the deal bid", winner)
+	}
+	audit := exchange.audit(req, bids, winner, rejected)
+
+	want := map[string]struct {
+		outcome, reason string
+		loss            openrtb3.LossReason
+	}{
+		"low":         {OutcomeRejected, ReasonBelowFloor, openrtb3.LossBelowAuctionFloor},
+		"wrong-deal":  {OutcomeRejected, ReasonDealMismatch, openrtb3.LossInvalidDealID},
+		"unsafe-cat":  {OutcomeRejected, ReasonCategoryBlocked, openrtb3.LossCategoryExclusions},
+		"blocked-adv": {OutcomeRejected, ReasonAdvertiserBlocked, openrtb3.LossAdvertiserExclusions},
+		"over-cap":    {OutcomeRejected, ReasonCapped, openrtb3.LossCreativeFiltered},
+		"deal":        {OutcomeWon, "", openrtb3.LossWon},
+		"open":        {OutcomeLost, ReasonLostToDeal, openrtb3.LossLostToDealBid},
+		"deal-low":    {OutcomeLost, ReasonOutbid, openrtb3.LossLostToHigherBid},
+	}
+	if len(audit.Bids) != len(want) {
+		t.Fatalf("audited %d bids, want %d", len(audit.Bids), len(want))
+	}
+	for _, b := range audit.Bids {
+		w := want[b.ID]
+		if b.Outcome != w.outcome || b.Reason != w.reason || b.LossReason != w.loss {
+			t.Errorf("%s = %s/%s/%d, want %s/%s/%d", b.ID, b.Outcome, b.Reason, b.LossReason, w.outcome, w.reason, w.loss)
+		}
+	}
+	if b := audit.Bids[4]; b.Detail == "" || !errors.Is(rejected[&bids[4]], ErrBidOverCap) {
+		t.Errorf("capped bid's detail = %q", b.Detail)
+	}
+	if audit.Winner != "deal" || audit.ClearingPrice != 4 || audit.Request.Publisher != "pub-1" {
+		t.Errorf("audit = %+v", audit)
+	}
+	if imp := audit.Request.Imps[0]; imp.Floor != 0.50 || len(imp.Deals) != 1 {
+		t.Errorf("imp summary = %+v", imp)
+	}
+	if audit.ProofRef == "" || audit.ProofRef != audit.Digest() {
+		t.Error("proof reference doesn't commit to the record")
+	}
+	tampered := *audit
+	tampered.ClearingPrice = 1
+	if tampered.Digest() == audit.ProofRef {
+		t.Error("altered record has the same digest")
+	}
+}
+
+func TestAuditLog_Export(t *testing.T) {
+	var sink bytes.Buffer
+	log := NewAuditLog(2, &sink)
+	for _, id := range []string{"a", "b", "c"} {
+		log.Record(&AuctionAudit{AuctionID: id, Bids: []AuditBid{{ID: id + "-1", Outcome: OutcomeWon}}})
+	}
+
+	if _, ok := log.Audit("a"); ok {
+		t.Error("oldest auction kept past the log's size")
+	}
+	if a, ok := log.Audit("c"); !ok || a.Bids[0].ID != "c-1" {
+		t.Errorf("audit c = %+v, %v", a, ok)
+	}
+
+	var out bytes.Buffer
+	if err := log.WriteJSONL(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got := auditIDs(t, &out); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("exported %v, want [b c]", got)
+	}
+	if got := auditIDs(t, &sink); len(got) != 3 {
+		t.Errorf("sink has %v, want every auction", got)
+	}
+
+	// Served through the admin API
+	h := NewAdminHandler(&RTBExchange{Audit: log}, "secret", nil)
+	for path, status := range map[string]int{"/admin/audit": http.StatusOK, "/admin/audit/c": http.StatusOK, "/admin/audit/a": http.StatusNotFound} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, status)
+		}
+	}
+}
+
+func auditIDs(t *testing.T, r *bytes.Buffer) []string {
+	t.Helper()
+	var ids []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		var a AuctionAudit
+		if err := json.Unmarshal(sc.Bytes(), &a); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, a.AuctionID)
+	}
+	return ids
+}
@@ -0,0 +1,207 @@
+package rtb
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/luxfi/adx/pkg/reqlog"
+)
+
+var (
+	ErrBidOverCap     = errors.New("bid above seat cap")
+	ErrBidOverCeiling = errors.New("bid above sanity ceiling")
+	ErrSeatOverBudget = errors.New("seat over spend budget")
+)
+
+// Bid guard defaults
+const (
+	DefaultBudgetWindow     = time.Hour
+	DefaultCeilingSamples   = 200
+	DefaultCeilingMinSample = 20
+)
+
+// SeatLimits bound what one seat may bid and spend
+type SeatLimits struct {
+	MaxBid float64 // Highest CPM accepted; no cap when zero
+	Budget float64 // Spend allowed per window, in currency units (a win at CPM p spends p/1000); no budget when zero
+}
+
+// BidGuard keeps runaway bids out of the auction. Bids over their seat's
+// cap are rejected, or lowered to it when Clamp is set; bids over
+// CeilingMultiple times the recent median clearing price are rejected, as
+// no honest bid is that far off the market; and a seat that has spent its
+// budget for the current window is throttled until the next. Seats are
+// bids' seat IDs, or their DSP's ID when they have none.
+type BidGuard struct {
+	Default SeatLimits
+	Clamp   bool
+
+	// CeilingMultiple enables the sanity ceiling once MinSamples clearing
+	// prices have been seen; it is off when zero
+	CeilingMultiple float64
+	Samples         int // Clearing prices the median is taken over; DefaultCeilingSamples when zero
+	MinSamples      int // DefaultCeilingMinSample when zero
+
+	Window time.Duration // Budget window; DefaultBudgetWindow when zero
+
+	mu       sync.Mutex
+	seats    map[string]SeatLimits
+	spend    map[string]*seatSpend
+	clearing []float64 // Ring of recent clearing prices
+	next     int
+	now      func() time.Time
+}
+
+type seatSpend struct {
+	since time.Time
+	spent float64
+}
+
+// NewBidGuard creates a guard applying def to every seat
+func NewBidGuard(def SeatLimits) *BidGuard {
+	return &BidGuard{
+		Default: def,
+		seats:   make(map[string]SeatLimits),
+		spend:   make(map[string]*seatSpend),
+		now:     time.Now,
+	}
+}
+
+// SetSeatLimits overrides the limits for one seat
+func (g *BidGuard) SetSeatLimits(seat string, limits SeatLimits) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seats[seat] = limits
+}
+
+// LimitsFor returns the limits a seat is held to
+func (g *BidGuard) LimitsFor(seat string) SeatLimits {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limitsFor(seat)
+}
+
+func (g *BidGuard) limitsFor(seat string) SeatLimits {
+	if l, ok := g.seats[seat]; ok {
+		return l
+	}
+	return g.Default
+}
+
+// Screen marks the bids that may not take part in the auction rejected,
+// lowering capped bids to their cap instead when Clamp is set, and logs
+// each one it rejects or lowers
+func (g *BidGuard) Screen(bids []Bid, rejected map[*Bid]error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ceiling := g.ceiling()
+	now := g.now()
+
+	for i := range bids {
+		bid := &bids[i]
+		seat := seatOf(bid)
+		limits := g.limitsFor(seat)
+		var err error
+		switch {
+		case g.overBudget(seat, limits, now):
+			err = ErrSeatOverBudget
+		case ceiling > 0 && bid.Price > ceiling:
+			err = fmt.Errorf("%w: %.2f, over %.1fx the median clearing price", ErrBidOverCeiling, bid.Price, g.CeilingMultiple)
+		case limits.MaxBid > 0 && bid.Price > limits.MaxBid:
+			if !g.Clamp {
+				err = fmt.Errorf("%w: %.2f over %.2f", ErrBidOverCap, bid.Price, limits.MaxBid)
+				break
+			}
+			slog.Warn("bid clamped to seat cap", reqlog.KeyDSP, bid.DSP, "seat", seat, "bid", bid.ID, "price", bid.Price, "cap", limits.MaxBid)
+			bid.Price = limits.MaxBid
+		}
+		if err != nil {
+			slog.Warn("bid rejected", reqlog.KeyDSP, bid.DSP, "seat", seat, "bid", bid.ID, "price", bid.Price, "error", err)
+			rejected[bid] = err
+		}
+	}
+}
+
+// Record counts a winning bid's price towards its seat's spend and the
+// clearing prices the ceiling is drawn from. Spend is counted when the
+// auction clears rather than on billing, so a seat can't overrun its
+// budget while notices are in flight.
+func (g *BidGuard) Record(winner *Bid) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	samples := g.Samples
+	if samples <= 0 {
+		samples = DefaultCeilingSamples
+	}
+	if len(g.clearing) < samples {
+		g.clearing = append(g.clearing, winner.Price)
+	} else {
+		g.clearing[g.next%len(g.clearing)] = winner.Price
+		g.next++
+	}
+
+	seat := seatOf(winner)
+	if g.limitsFor(seat).Budget <= 0 {
+		return
+	}
+	s := g.window(seat, g.now())
+	s.spent += winner.Price / 1000
+}
+
+// Spent is a seat's spend in its current budget window
+func (g *BidGuard) Spent(seat string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.window(seat, g.now()).spent
+}
+
+// ceiling is the highest price the sanity check lets through, or 0 while
+// it's off or there are too few clearing prices
+func (g *BidGuard) ceiling() float64 {
+	min := g.MinSamples
+	if min <= 0 {
+		min = DefaultCeilingMinSample
+	}
+	if g.CeilingMultiple <= 0 || len(g.clearing) < min {
+		return 0
+	}
+	prices := append([]float64(nil), g.clearing...)
+	sort.Float64s(prices)
+	median := prices[len(prices)/2]
+	if len(prices)%2 == 0 {
+		median = (prices[len(prices)/2-1] + median) / 2
+	}
+	return median * g.CeilingMultiple
+}
+
+func (g *BidGuard) overBudget(seat string, limits SeatLimits, now time.Time) bool {
+	return limits.Budget > 0 && g.window(seat, now).spent >= limits.Budget
+}
+
+// window returns the seat's spend for the window containing now, starting
+// a new one when the last has ended
+func (g *BidGuard) window(seat string, now time.Time) *seatSpend {
+	length := g.Window
+	if length <= 0 {
+		length = DefaultBudgetWindow
+	}
+	s, ok := g.spend[seat]
+	if !ok || now.Sub(s.since) >= length {
+		s = &seatSpend{since: now}
+		g.spend[seat] = s
+	}
+	return s
+}
+
+// seatOf is the seat a bid is limited under
+func seatOf(bid *Bid) string {
+	if bid.SeatID != "" {
+		return bid.SeatID
+	}
+	return bid.DSP
+}
@@ -0,0 +1,97 @@
+package rtb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+func TestBidGuard_Ceiling(t *testing.T) {
+	guard := NewBidGuard(SeatLimits{})
+	guard.CeilingMultiple = 10
+	guard.MinSamples = 5
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.50), BidGuard: guard}
+	req := &openrtb2.BidRequest{ID: "req-1", Imp: []openrtb2.Imp{{ID: "1"}}}
+
+	// No ceiling until enough prices have cleared
+	if winner := exchange.runAuction([]Bid{{ID: "fat", ImpID: "1", Price: 500, SeatID: "s1"}}, req); winner == nil {
+		t.Fatal("bid rejected before the ceiling had samples")
+	}
+	for i := 0; i < 5; i++ {
+		exchange.runAuction([]Bid{{ID: "b", ImpID: "1", Price: 2, SeatID: "s1"}}, req)
+	}
+
+	bids := []Bid{
+		{ID: "fat", ImpID: "1", Price: 200, SeatID: "s1"},
+		{ID: "fair", ImpID: "1", Price: 3, SeatID: "s2"},
+	}
+	if winner := exchange.runAuction(bids, req); winner == nil || winner.ID != "fair" {
+		t.Errorf("winner = %+v, want the bid under the ceiling", winner)
+	}
+}
+
+func TestBidGuard_SeatCap(t *testing.T) {
+	guard := NewBidGuard(SeatLimits{MaxBid: 20})
+	guard.SetSeatLimits("big", SeatLimits{MaxBid: 50})
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.50), BidGuard: guard}
+	req := &openrtb2.BidRequest{ID: "req-1", Imp: []openrtb2.Imp{{ID: "1"}}}
+
+	bids := []Bid{
+		{ID: "over", ImpID: "1", Price: 30, DSP: "small"},
+		{ID: "under", ImpID: "1", Price: 10, DSP: "other"},
+	}
+	if winner := exchange.runAuction(bids, req); winner == nil || winner.ID != "under" {
+		t.Errorf("winner = %+v, want the bid under the default cap", winner)
+	}
+	if winner := exchange.runAuction([]Bid{{ID: "big", ImpID: "1", Price: 30, SeatID: "big"}}, req); winner == nil {
+		t.Error("bid under the seat's own cap rejected")
+	}
+
+	guard.Clamp = true
+	bids = []Bid{{ID: "over", ImpID: "1", Price: 30, DSP: "small"}}
+	if winner := exchange.runAuction(bids, req); winner == nil || winner.Price != 20 {
+		t.Errorf("winner = %+v, want the bid clamped to 20", winner)
+	}
+}
+
+func TestBidGuard_Budget(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	guard := NewBidGuard(SeatLimits{})
+	guard.SetSeatLimits("spender", SeatLimits{Budget: 0.01})
+	guard.Window = time.Minute
+	guard.now = func() time.Time { return now }
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.50), BidGuard: guard}
+	req := &openrtb2.BidRequest{ID: "req-1", Imp: []openrtb2.Imp{{ID: "1"}}}
+
+	bids := func() []Bid {
+		return []Bid{
+			{ID: "spender", ImpID: "1", Price: 5, SeatID: "spender"},
+			{ID: "steady", ImpID: "1", Price: 3, SeatID: "steady"},
+		}
+	}
+	// Two $5 CPM wins spend the $0.01 budget
+	for i := 0; i < 2; i++ {
+		if winner := exchange.runAuction(bids(), req); winner == nil || winner.ID != "spender" {
+			t.Fatalf("auction %d winner = %+v, want spender", i, winner)
+		}
+	}
+	if got := guard.Spent("spender"); got < 0.01 {
+		t.Fatalf("spent = %v, want 0.01", got)
+	}
+
+	// Throttled, while other seats bid normally
+	if winner := exchange.runAuction(bids(), req); winner == nil || winner.ID != "steady" {
+		t.Errorf("winner = %+v, want steady while spender is over budget", winner)
+	}
+	if got := guard.Spent("steady"); got != 0 {
+		t.Errorf("steady has no budget, so its spend isn't tracked; got %v", got)
+	}
+
+	// The next window starts afresh
+	now = now.Add(time.Minute)
+	if winner := exchange.runAuction(bids(), req); winner == nil || winner.ID != "spender" {
+		t.Errorf("winner = %+v, want spender in the next window", winner)
+	}
+}
@@ -0,0 +1,187 @@
+package rtb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+var (
+	// ErrCreativeTooLarge is why a creative over its placement's byte,
+	// dimension or duration limits doesn't serve
+	ErrCreativeTooLarge = errors.New("creative over placement limits")
+	// ErrCreativeMIME is why a creative with no media of a MIME type its
+	// placement allows doesn't serve
+	ErrCreativeMIME = errors.New("creative MIME type not allowed on placement")
+)
+
+// CreativeLimits cap how heavy the creatives a placement serves may be,
+// for its page load and the bandwidth miners pay for. A zero limit is
+// unenforced.
+type CreativeLimits struct {
+	MaxBytes    int64         // Markup, or each VAST media file's declared fileSize
+	MaxWidth    int64         // pixels
+	MaxHeight   int64         // pixels
+	MIMETypes   []string      // VAST media MIME types allowed; any when empty
+	MaxDuration time.Duration // Of VAST linear creatives
+
+	// Demote lets a creative over the limits serve when no compliant bid
+	// is left, rather than never
+	Demote bool
+}
+
+// CreativePolicy holds winning creatives to per-placement limits, keyed
+// by imp tagid and falling back to Default
+type CreativePolicy struct {
+	Default CreativeLimits
+
+	mu         sync.RWMutex
+	placements map[string]CreativeLimits
+}
+
+// NewCreativePolicy creates a policy applying def to every placement
+func NewCreativePolicy(def CreativeLimits) *CreativePolicy {
+	return &CreativePolicy{Default: def, placements: make(map[string]CreativeLimits)}
+}
+
+// SetPlacementLimits overrides the limits for one placement
+func (p *CreativePolicy) SetPlacementLimits(placementID string, limits CreativeLimits) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.placements[placementID] = limits
+}
+
+// LimitsFor returns the limits a placement's creatives are held to
+func (p *CreativePolicy) LimitsFor(placementID string) CreativeLimits {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if limits, ok := p.placements[placementID]; ok {
+		return limits
+	}
+	return p.Default
+}
+
+// Check reports why bid's creative may not serve on the impression it
+// bids for, and whether that impression's limits demote rather than
+// reject it
+func (p *CreativePolicy) Check(req *openrtb2.BidRequest, bid *Bid) (demote bool, err error) {
+	var placement string
+	for i := range req.Imp {
+		if req.Imp[i].ID == bid.ImpID {
+			placement = req.Imp[i].TagID
+			break
+		}
+	}
+	limits := p.LimitsFor(placement)
+	return limits.Demote, limits.Check(bid)
+}
+
+// Check applies the limits to a bid. Display and native creatives are
+// held to their markup size and declared dimensions; a VAST creative's
+// duration must be within the limit and at least one of its media files,
+// which the player can pick, must be within every other.
+func (l CreativeLimits) Check(bid *Bid) error {
+	markup := parseVASTMarkup(bid.Creative)
+	if len(markup.MediaFiles) == 0 {
+		if l.MaxBytes > 0 && int64(len(bid.Creative)) > l.MaxBytes {
+			return fmt.Errorf("%w: %d bytes", ErrCreativeTooLarge, len(bid.Creative))
+		}
+		if l.oversize(bid.W, bid.H) {
+			return fmt.Errorf("%w: %dx%d", ErrCreativeTooLarge, bid.W, bid.H)
+		}
+		return nil
+	}
+
+	if l.MaxDuration > 0 {
+		for _, d := range markup.Durations {
+			if d := vastDuration(d); d > l.MaxDuration {
+				return fmt.Errorf("%w: %s long", ErrCreativeTooLarge, d)
+			}
+		}
+	}
+	allowed := 0
+	for _, m := range markup.MediaFiles {
+		if !l.allows(m.Type) {
+			continue
+		}
+		allowed++
+		if (l.MaxBytes <= 0 || m.FileSize <= l.MaxBytes) && !l.oversize(m.Width, m.Height) {
+			return nil
+		}
+	}
+	if allowed == 0 {
+		return fmt.Errorf("%w: no media of %s", ErrCreativeMIME, strings.Join(l.MIMETypes, ", "))
+	}
+	return fmt.Errorf("%w: no media file within %d bytes and %dx%d", ErrCreativeTooLarge, l.MaxBytes, l.MaxWidth, l.MaxHeight)
+}
+
+func (l CreativeLimits) oversize(w, h int64) bool {
+	return (l.MaxWidth > 0 && w > l.MaxWidth) || (l.MaxHeight > 0 && h > l.MaxHeight)
+}
+
+func (l CreativeLimits) allows(mime string) bool {
+	if len(l.MIMETypes) == 0 {
+		return true
+	}
+	for _, t := range l.MIMETypes {
+		if strings.EqualFold(t, mime) {
+			return true
+		}
+	}
+	return false
+}
+
+// vastDuration reads a VAST HH:MM:SS[.mmm] duration, zero if malformed
+func vastDuration(d string) time.Duration {
+	var h, m int
+	var s float64
+	if _, err := fmt.Sscanf(strings.TrimSpace(d), "%d:%d:%f", &h, &m, &s); err != nil {
+		return 0
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s*float64(time.Second))
+}
+
+// creativeScreen tracks the winners an auction has demoted for their
+// creative policy, to be reconsidered once no compliant bid is left
+type creativeScreen struct {
+	demoted []*Bid
+	relaxed bool // Only demoted bids are left in contention
+}
+
+// screenCreative reports whether winner's creative may serve under the
+// exchange's creative policy, recording it as rejected or demoted if not
+func (rtb *RTBExchange) screenCreative(req *openrtb2.BidRequest, winner *Bid, rejected map[*Bid]error, s *creativeScreen) bool {
+	if rtb.CreativePolicy == nil || s.relaxed {
+		return true
+	}
+	var demote bool
+	err := rtb.guard(winner, func() (err error) {
+		demote, err = rtb.CreativePolicy.Check(req, winner)
+		return err
+	})
+	if err == nil {
+		return true
+	}
+	rejected[winner] = err
+	if demote && !errors.Is(err, ErrBidPanic) {
+		s.demoted = append(s.demoted, winner)
+	}
+	return false
+}
+
+// readmit puts the demoted bids back in contention once the compliant
+// ones are exhausted, reporting whether there were any
+func (s *creativeScreen) readmit(rejected map[*Bid]error) bool {
+	if s.relaxed || len(s.demoted) == 0 {
+		return false
+	}
+	for _, bid := range s.demoted {
+		delete(rejected, bid)
+	}
+	s.relaxed = true
+	return true
+}
@@ -0,0 +1,123 @@
+package rtb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/luxfi/adx/pkg/rtb/rtbtest"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// sizedAdM is VAST markup with one media file of the given type and
+// declared size, running for duration
+func sizedAdM(mime string, fileSize int64, width, height int, duration string) string {
+	return fmt.Sprintf(`<VAST version="4.1"><Ad id="1"><InLine><Creatives><Creative><Linear>
+<Duration>%s</Duration>
+<MediaFiles><MediaFile delivery="progressive" type="%s" width="%d" height="%d" fileSize="%d"><![CDATA[https://cdn.example/ad]]></MediaFile></MediaFiles>
+</Linear></Creative></Creatives></InLine></Ad></VAST>`, duration, mime, width, height, fileSize)
+}
+
+func placementRequest(tagID string) *openrtb2.BidRequest {
+	req := ctvRequest("pub-1")
+	req.Imp[0].TagID = tagID
+	return req
+}
+
+func policyExchange(t *testing.T, limits rtb.CreativeLimits) (*rtb.RTBExchange, []*rtbtest.MockDSP) {
+	t.Helper()
+	exchange := &rtb.RTBExchange{
+		AuctionTimeout: 200 * time.Millisecond,
+		Revenue:        big.NewInt(0),
+		CreativePolicy: rtb.NewCreativePolicy(rtb.CreativeLimits{}),
+	}
+	exchange.CreativePolicy.SetPlacementLimits("preroll", limits)
+	dsps := rtbtest.StartMockDSPs(t, exchange, 2)
+	dsps[0].Set(rtbtest.WithFixedPrice(9), rtbtest.WithAdM(sizedAdM("video/mp4", 80<<20, 3840, 2160, "00:00:30")))
+	dsps[1].Set(rtbtest.WithFixedPrice(4), rtbtest.WithAdM(sizedAdM("video/mp4", 4<<20, 1920, 1080, "00:00:15")))
+	return exchange, dsps
+}
+
+func servedSeat(t *testing.T, exchange *rtb.RTBExchange, req *openrtb2.BidRequest) string {
+	t.Helper()
+	resp, err := exchange.BidRequest(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.SeatBid) == 0 {
+		return ""
+	}
+	return resp.SeatBid[0].Seat
+}
+
+func TestCreativePolicy_OversizedFallsThrough(t *testing.T) {
+	exchange, dsps := policyExchange(t, rtb.CreativeLimits{MaxBytes: 10 << 20, MaxWidth: 1920, MaxHeight: 1080})
+
+	if seat := servedSeat(t, exchange, placementRequest("preroll")); seat != "seat-dsp-2" {
+		t.Errorf("served %q, want the compliant runner-up seat-dsp-2", seat)
+	}
+	// The limits are the placement's own
+	if seat := servedSeat(t, exchange, placementRequest("midroll")); seat != "seat-dsp-1" {
+		t.Errorf("unlimited placement served %q, want the top bid", seat)
+	}
+
+	// Rejected creatives don't serve even with nothing else left
+	dsps[1].Set(rtbtest.WithNoBid())
+	if seat := servedSeat(t, exchange, placementRequest("preroll")); seat != "" {
+		t.Errorf("served %q, want no fill", seat)
+	}
+}
+
+func TestCreativePolicy_Satisfied(t *testing.T) {
+	exchange, _ := policyExchange(t, rtb.CreativeLimits{
+		MaxBytes:    100 << 20,
+		MaxWidth:    3840,
+		MaxHeight:   2160,
+		MIMETypes:   []string{"video/mp4"},
+		MaxDuration: 30 * time.Second,
+	})
+	if seat := servedSeat(t, exchange, placementRequest("preroll")); seat != "seat-dsp-1" {
+		t.Errorf("served %q, want the top bid", seat)
+	}
+}
+
+func TestCreativePolicy_Demote(t *testing.T) {
+	exchange, dsps := policyExchange(t, rtb.CreativeLimits{MaxDuration: 20 * time.Second, Demote: true})
+
+	if seat := servedSeat(t, exchange, placementRequest("preroll")); seat != "seat-dsp-2" {
+		t.Errorf("served %q, want the compliant runner-up over the demoted top bid", seat)
+	}
+	// With no compliant bid left, the demoted one serves
+	dsps[1].Set(rtbtest.WithNoBid())
+	if seat := servedSeat(t, exchange, placementRequest("preroll")); seat != "seat-dsp-1" {
+		t.Errorf("served %q, want the demoted top bid", seat)
+	}
+}
+
+func TestCreativeLimits_Check(t *testing.T) {
+	video := sizedAdM("video/webm", 2<<20, 1280, 720, "00:00:15.500")
+	for _, tc := range []struct {
+		name   string
+		limits rtb.CreativeLimits
+		bid    rtb.Bid
+		want   error
+	}{
+		{"no limits", rtb.CreativeLimits{}, rtb.Bid{Creative: video}, nil},
+		{"mime", rtb.CreativeLimits{MIMETypes: []string{"video/mp4"}}, rtb.Bid{Creative: video}, rtb.ErrCreativeMIME},
+		{"mime allowed", rtb.CreativeLimits{MIMETypes: []string{"VIDEO/WEBM"}}, rtb.Bid{Creative: video}, nil},
+		{"duration", rtb.CreativeLimits{MaxDuration: 15 * time.Second}, rtb.Bid{Creative: video}, rtb.ErrCreativeTooLarge},
+		{"duration within", rtb.CreativeLimits{MaxDuration: 16 * time.Second}, rtb.Bid{Creative: video}, nil},
+		{"media bytes", rtb.CreativeLimits{MaxBytes: 1 << 20}, rtb.Bid{Creative: video}, rtb.ErrCreativeTooLarge},
+		{"display markup bytes", rtb.CreativeLimits{MaxBytes: 10}, rtb.Bid{Creative: `<div>an ad over ten bytes</div>`}, rtb.ErrCreativeTooLarge},
+		{"display size", rtb.CreativeLimits{MaxWidth: 300, MaxHeight: 250}, rtb.Bid{Creative: "<div/>", W: 728, H: 90}, rtb.ErrCreativeTooLarge},
+		{"display within", rtb.CreativeLimits{MaxWidth: 300, MaxHeight: 250, MaxBytes: 100}, rtb.Bid{Creative: "<div/>", W: 300, H: 250}, nil},
+	} {
+		if err := tc.limits.Check(&tc.bid); !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package rtb
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Circuit breaker settings for DSP endpoints
+const (
+	// DefaultBreakerFailures is how many connection failures in a row open
+	// an endpoint's circuit
+	DefaultBreakerFailures = 3
+	// DefaultBreakerCooldown is how long an open circuit is skipped before
+	// the endpoint is tried again
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// endpointBreakers tracks which of a DSP's endpoints keep refusing
+// connections, so bid requests fail over past them without waiting
+type endpointBreakers struct {
+	mu    sync.Mutex
+	state map[string]*breakerState
+	now   func() time.Time
+}
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+func newEndpointBreakers() *endpointBreakers {
+	return &endpointBreakers{state: make(map[string]*breakerState), now: time.Now}
+}
+
+// allow reports whether endpoint's circuit is closed, or open long enough
+// for a trial request
+func (b *endpointBreakers) allow(endpoint string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.state[endpoint]
+	return !ok || !b.now().Before(s.openUntil)
+}
+
+// failure counts a connection failure, opening the circuit once there
+// have been DefaultBreakerFailures in a row; a failed trial reopens it
+func (b *endpointBreakers) failure(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.state[endpoint]
+	if !ok {
+		s = &breakerState{}
+		b.state[endpoint] = s
+	}
+	s.failures++
+	if s.failures >= DefaultBreakerFailures {
+		s.openUntil = b.now().Add(DefaultBreakerCooldown)
+	}
+}
+
+// success closes endpoint's circuit
+func (b *endpointBreakers) success(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.state, endpoint)
+}
+
+// endpointBreakers returns the DSP's breakers, creating them on first use
+func (dsp *DSPConnection) endpointBreakers() *endpointBreakers {
+	if b := dsp.breakers.Load(); b != nil {
+		return b
+	}
+	dsp.breakers.CompareAndSwap(nil, newEndpointBreakers())
+	return dsp.breakers.Load()
+}
+
+// EndpointOpen reports whether endpoint's circuit is open, so bid requests
+// skip it while another endpoint is available
+func (dsp *DSPConnection) EndpointOpen(endpoint string) bool {
+	return !dsp.endpointBreakers().allow(endpoint)
+}
+
+// postFailover sends a marshaled bid request to the DSP's endpoints in
+// order until one can be connected to. Endpoints with an open circuit go
+// last, so a persistently failing primary is skipped, but are still tried
+// if every endpoint is open.
+func (dsp *DSPConnection) postFailover(ctx context.Context, body []byte) (*Bid, error) {
+	b := dsp.endpointBreakers()
+	endpoints := make([]string, 0, 1+len(dsp.Failover))
+	var open []string
+	for _, e := range append([]string{dsp.Endpoint}, dsp.Failover...) {
+		if b.allow(e) {
+			endpoints = append(endpoints, e)
+		} else {
+			open = append(open, e)
+		}
+	}
+	endpoints = append(endpoints, open...)
+
+	var err error
+	for _, endpoint := range endpoints {
+		var bid *Bid
+		bid, err = dsp.post(ctx, endpoint, body)
+		if !unreachable(ctx, err) {
+			if err == nil {
+				b.success(endpoint)
+				if endpoint != dsp.Endpoint {
+					atomic.AddUint64(&dsp.FailoverCount, 1)
+				}
+			}
+			return bid, err
+		}
+		b.failure(endpoint)
+	}
+	return nil, err
+}
+
+// unreachable reports whether err is a failure to reach an endpoint at
+// all, rather than a bad answer or the request running out of time
+func unreachable(ctx context.Context, err error) bool {
+	var urlErr *url.Error
+	return err != nil && ctx.Err() == nil && errors.As(err, &urlErr)
+}
@@ -0,0 +1,77 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+func TestSendBidRequest_Failover(t *testing.T) {
+	// The primary region is down: nothing listens on its address
+	down := httptest.NewServer(http.NotFoundHandler())
+	primary := down.URL
+	down.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{SeatBid: []openrtb2.SeatBid{{Bid: []openrtb2.Bid{{ID: "b1", ImpID: "1", Price: 2}}}}})
+	}))
+	defer secondary.Close()
+
+	dsp := &DSPConnection{ID: "dsp", Endpoint: primary, Failover: []string{secondary.URL}, Timeout: 500 * time.Millisecond}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	dsp.endpointBreakers().now = func() time.Time { return now }
+	req := &openrtb2.BidRequest{ID: "auc-1", Imp: []openrtb2.Imp{{ID: "1"}}}
+
+	for i := 0; i < DefaultBreakerFailures; i++ {
+		if dsp.EndpointOpen(primary) {
+			t.Fatalf("primary open after %d failures", i)
+		}
+		start := time.Now()
+		bid, err := dsp.SendBidRequest(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if took := time.Since(start); took >= dsp.Timeout {
+			t.Errorf("failover took %s, over the %s timeout", took, dsp.Timeout)
+		}
+		if bid == nil || bid.ID != "b1" || bid.Endpoint != secondary.URL {
+			t.Fatalf("bid = %+v, want b1 from the secondary", bid)
+		}
+	}
+	if n := dsp.FailoverCount; n != DefaultBreakerFailures {
+		t.Errorf("failover count = %d, want %d", n, DefaultBreakerFailures)
+	}
+
+	// The primary keeps failing, so its circuit opens and requests go
+	// straight to the secondary until the cooldown passes
+	if !dsp.EndpointOpen(primary) {
+		t.Fatal("primary circuit still closed")
+	}
+	if bid, err := dsp.SendBidRequest(context.Background(), req); err != nil || bid.Endpoint != secondary.URL {
+		t.Errorf("with the circuit open = %+v, %v", bid, err)
+	}
+	now = now.Add(DefaultBreakerCooldown)
+	if dsp.EndpointOpen(primary) {
+		t.Error("primary circuit still open after the cooldown")
+	}
+	if dsp.EndpointOpen(secondary.URL) {
+		t.Error("secondary circuit open")
+	}
+}
+
+func TestSendBidRequest_AllEndpointsDown(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	dsp := &DSPConnection{ID: "dsp", Endpoint: down.URL, Failover: []string{down.URL + "/eu"}, Timeout: 500 * time.Millisecond}
+	if bid, err := dsp.SendBidRequest(context.Background(), &openrtb2.BidRequest{ID: "auc-1"}); bid != nil || err == nil {
+		t.Errorf("SendBidRequest = %+v, %v; want the connection error", bid, err)
+	}
+	if dsp.FailoverCount != 0 {
+		t.Errorf("failover count = %d", dsp.FailoverCount)
+	}
+}
@@ -0,0 +1,222 @@
+package rtb
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// maxFanoutSegments bounds the segments a FanoutThrottle remembers; their
+// history is forgotten wholesale beyond it
+const maxFanoutSegments = 100000
+
+// FanoutThrottle caps how many DSPs each auction is sent to. DSPs are
+// ranked per segment, the impression's placement and country, by how
+// likely they are to bid there and win when they do, discounted for the
+// requests they fail; the TopK best are asked. An Explore share of the
+// rest is asked too, picked by auction ID, so DSPs out of favour keep
+// being sampled and can work their way back in.
+type FanoutThrottle struct {
+	TopK    int     // DSPs asked on merit; all are when zero
+	Explore float64 // Share of the others asked, rounded up; none when zero
+
+	mu       sync.Mutex
+	segments map[string]map[string]*fanoutHistory
+	dsps     map[string]*FanoutStat
+}
+
+// fanoutHistory is how a DSP has answered in one segment
+type fanoutHistory struct {
+	sent, bids, wins, errors uint64
+}
+
+// score is the DSP's chance of bidding times one plus its chance of
+// winning with a bid, less its error rate. Counts are smoothed, so a DSP
+// not yet asked scores in the middle of the field.
+func (h *fanoutHistory) score() float64 {
+	bid := (float64(h.bids) + 1) / (float64(h.sent) + 2)
+	win := (float64(h.wins) + 1) / (float64(h.bids) + 2)
+	healthy := 1 - float64(h.errors)/(float64(h.sent)+2)
+	return bid * (1 + win) * healthy
+}
+
+// FanoutStat is a DSP's fan-out across every segment
+type FanoutStat struct {
+	DSP      string  `json:"dsp"`
+	Selected uint64  `json:"selected"` // Auctions it was asked to bid in
+	Explored uint64  `json:"explored"` // Of those, asked to explore rather than on merit
+	Skipped  uint64  `json:"skipped"`  // Auctions it was left out of
+	Bids     uint64  `json:"bids"`
+	Wins     uint64  `json:"wins"`
+	WinRate  float64 `json:"win_rate"` // Wins per auction it was asked in
+}
+
+// NewFanoutThrottle creates a throttle asking topK DSPs per auction, and
+// an explore share of the rest
+func NewFanoutThrottle(topK int, explore float64) *FanoutThrottle {
+	return &FanoutThrottle{
+		TopK:     topK,
+		Explore:  explore,
+		segments: make(map[string]map[string]*fanoutHistory),
+		dsps:     make(map[string]*FanoutStat),
+	}
+}
+
+// Select picks which of dsps to send req to
+func (f *FanoutThrottle) Select(req *openrtb2.BidRequest, dsps []*DSPConnection) []*DSPConnection {
+	if f.TopK <= 0 || len(dsps) <= f.TopK {
+		f.mu.Lock()
+		for _, d := range dsps {
+			f.stat(d.ID).Selected++
+		}
+		f.mu.Unlock()
+		return dsps
+	}
+
+	ranked := append([]*DSPConnection(nil), dsps...)
+	scores := make(map[string]float64, len(ranked))
+	f.mu.Lock()
+	hist := f.segments[fanoutSegment(req)]
+	for _, d := range ranked {
+		h := hist[d.ID]
+		if h == nil {
+			h = &fanoutHistory{}
+		}
+		scores[d.ID] = h.score()
+	}
+	f.mu.Unlock()
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if scores[a.ID] != scores[b.ID] {
+			return scores[a.ID] > scores[b.ID]
+		}
+		return a.ID < b.ID
+	})
+
+	rest := ranked[f.TopK:]
+	explore := int(math.Ceil(f.Explore * float64(len(rest))))
+	if explore > len(rest) {
+		explore = len(rest)
+	}
+	if explore > 0 {
+		draws := make(map[string]uint32, len(rest))
+		for _, d := range rest {
+			draws[d.ID] = exploreDraw(req.ID, d.ID)
+		}
+		sort.Slice(rest, func(i, j int) bool { return draws[rest[i].ID] < draws[rest[j].ID] })
+	}
+	picked := ranked[:f.TopK+explore]
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, d := range ranked {
+		s := f.stat(d.ID)
+		switch {
+		case i < f.TopK:
+			s.Selected++
+		case i < len(picked):
+			s.Selected++
+			s.Explored++
+		default:
+			s.Skipped++
+		}
+	}
+	return picked
+}
+
+// exploreDraw orders the DSPs explored in an auction
+func exploreDraw(auctionID, dspID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(auctionID))
+	h.Write([]byte{0})
+	h.Write([]byte(dspID))
+	return h.Sum32()
+}
+
+// RecordResponse records how a DSP answered req: with a bid, without
+// one, or with an error
+func (f *FanoutThrottle) RecordResponse(req *openrtb2.BidRequest, dspID string, bid bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h := f.history(req, dspID)
+	h.sent++
+	switch {
+	case err != nil:
+		h.errors++
+	case bid:
+		h.bids++
+		f.stat(dspID).Bids++
+	}
+}
+
+// RecordWin records that dspID won req's auction
+func (f *FanoutThrottle) RecordWin(req *openrtb2.BidRequest, dspID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.history(req, dspID).wins++
+	f.stat(dspID).Wins++
+}
+
+// Stats returns each DSP's fan-out, by DSP ID
+func (f *FanoutThrottle) Stats() []FanoutStat {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]FanoutStat, 0, len(f.dsps))
+	for _, s := range f.dsps {
+		st := *s
+		if st.Selected > 0 {
+			st.WinRate = float64(st.Wins) / float64(st.Selected)
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DSP < out[j].DSP })
+	return out
+}
+
+// history returns the DSP's history in req's segment; f.mu must be held
+func (f *FanoutThrottle) history(req *openrtb2.BidRequest, dspID string) *fanoutHistory {
+	seg := fanoutSegment(req)
+	hist, ok := f.segments[seg]
+	if !ok {
+		if len(f.segments) >= maxFanoutSegments {
+			clear(f.segments)
+		}
+		hist = make(map[string]*fanoutHistory)
+		f.segments[seg] = hist
+	}
+	h, ok := hist[dspID]
+	if !ok {
+		h = &fanoutHistory{}
+		hist[dspID] = h
+	}
+	return h
+}
+
+// stat returns the DSP's totals; f.mu must be held
+func (f *FanoutThrottle) stat(dspID string) *FanoutStat {
+	s, ok := f.dsps[dspID]
+	if !ok {
+		s = &FanoutStat{DSP: dspID}
+		f.dsps[dspID] = s
+	}
+	return s
+}
+
+// fanoutSegment is the placement and country DSPs are ranked for: the
+// first impression's tag ID, or the publisher when it has none
+func fanoutSegment(req *openrtb2.BidRequest) string {
+	var placement, country string
+	if len(req.Imp) > 0 {
+		placement = req.Imp[0].TagID
+	}
+	if placement == "" {
+		placement, _, _ = publisherOf(req)
+	}
+	if req.Device != nil && req.Device.Geo != nil {
+		country = req.Device.Geo.Country
+	}
+	return placement + "|" + country
+}
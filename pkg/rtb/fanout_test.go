@@ -0,0 +1,109 @@
+package rtb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+func TestFanoutThrottle_FavoursBiddersAndExplores(t *testing.T) {
+	f := NewFanoutThrottle(3, 0.2)
+	var dsps []*DSPConnection
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		dsps = append(dsps, &DSPConnection{ID: id})
+	}
+	// a, b and c bid on this placement, a winning; d errors and the rest
+	// never bid
+	respond := func(req *openrtb2.BidRequest, picked []*DSPConnection) {
+		for _, d := range picked {
+			var err error
+			if d.ID == "d" {
+				err = errors.New("timeout")
+			}
+			f.RecordResponse(req, d.ID, d.ID <= "c", err)
+			if d.ID == "a" {
+				f.RecordWin(req, d.ID)
+			}
+		}
+	}
+	auction := func(i int) *openrtb2.BidRequest {
+		return &openrtb2.BidRequest{ID: fmt.Sprintf("auc-%d", i), Imp: []openrtb2.Imp{{ID: "1", TagID: "slot-1"}}}
+	}
+	for i := 0; i < 200; i++ {
+		req := auction(i)
+		respond(req, f.Select(req, dsps))
+	}
+
+	explored := make(map[string]bool)
+	for i := 200; i < 300; i++ {
+		req := auction(i)
+		picked := f.Select(req, dsps)
+		if len(picked) != 4 {
+			t.Fatalf("asked %d DSPs, want the top 3 and 1 explored", len(picked))
+		}
+		for j, want := range []string{"a", "b", "c"} {
+			if picked[j].ID != want {
+				t.Fatalf("auction %d ranked %s at %d, want %s", i, picked[j].ID, j, want)
+			}
+		}
+		explored[picked[3].ID] = true
+		respond(req, picked)
+	}
+	if len(explored) != 5 {
+		t.Errorf("explored %v, want each of the other 5 DSPs", explored)
+	}
+
+	stats := make(map[string]FanoutStat)
+	for _, s := range f.Stats() {
+		stats[s.DSP] = s
+	}
+	if s := stats["a"]; s.Selected != 300 || s.Skipped != 0 || s.Wins != 300 || s.WinRate != 1 {
+		t.Errorf("a = %+v, want asked and winning every auction", s)
+	}
+	if s := stats["h"]; s.Selected != s.Explored || s.Selected+s.Skipped != 300 || s.Skipped == 0 {
+		t.Errorf("h = %+v, want only explored", s)
+	}
+
+	// Another placement's history starts afresh
+	other := &openrtb2.BidRequest{ID: "auc-x", Imp: []openrtb2.Imp{{ID: "1", TagID: "slot-2"}}}
+	if picked := f.Select(other, dsps); picked[0].ID != "a" || picked[1].ID != "b" || picked[2].ID != "c" {
+		t.Errorf("fresh placement ranked %s %s %s, want ID order", picked[0].ID, picked[1].ID, picked[2].ID)
+	}
+}
+
+func TestFanoutThrottle_CapsRequests(t *testing.T) {
+	dsps := map[string]*DSPConnection{
+		"high": noticeDSP(t, "", "high", 3.5),
+		"low":  noticeDSP(t, "", "low", 2.25),
+		"mid":  noticeDSP(t, "", "mid", 3),
+	}
+	exchange := &RTBExchange{
+		DSPs:           dsps,
+		AuctionTimeout: time.Second,
+		Fanout:         NewFanoutThrottle(1, 0),
+		Revenue:        big.NewInt(0),
+	}
+	for i := 0; i < 5; i++ {
+		req := &openrtb2.BidRequest{ID: fmt.Sprintf("auc-%d", i), Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}}
+		if _, err := exchange.BidRequest(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var sent uint64
+	for _, d := range dsps {
+		sent += atomic.LoadUint64(&d.RequestCount)
+	}
+	if sent != 5 {
+		t.Errorf("sent %d bid requests over 5 auctions, want 1 each", sent)
+	}
+	// The first DSP asked bids and wins, so keeps being asked
+	if n := atomic.LoadUint64(&dsps["high"].RequestCount); n != 5 {
+		t.Errorf("high asked %d times, want 5", n)
+	}
+}
@@ -0,0 +1,157 @@
+package rtb
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultFatigueCurve halves a creative's bid after about three recent
+// exposures, recovering over a day
+var DefaultFatigueCurve = FatigueCurve{
+	Decay:    0.2,
+	HalfLife: 6 * time.Hour,
+	Floor:    0.1,
+}
+
+// maxExposures is how many of a user's exposures to a creative are kept
+const maxExposures = 32
+
+// FatigueCurve is how a creative's bid is discounted for a user who has
+// seen it. Each exposure counts for less as it ages, halving every
+// HalfLife; with n exposures counted, the bid keeps (1-Decay)^n of its
+// price, and never less than Floor of it.
+type FatigueCurve struct {
+	Decay    float64       // Share of the bid each recent exposure takes off, 0 to 1
+	HalfLife time.Duration // Exposures never fade when zero
+	Floor    float64       // Least share of the bid kept
+}
+
+// Factor is the share of its bid a creative keeps given the ages of a
+// user's exposures to it
+func (c FatigueCurve) Factor(ages []time.Duration) float64 {
+	var n float64
+	for _, age := range ages {
+		if c.HalfLife <= 0 {
+			n++
+			continue
+		}
+		n += math.Exp2(-age.Hours() / c.HalfLife.Hours())
+	}
+	f := math.Pow(1-c.Decay, n)
+	if f < c.Floor {
+		return c.Floor
+	}
+	return f
+}
+
+// FatigueModel tracks which creatives each user has been served and
+// discounts bids for the ones they've seen recently, so the auction
+// rotates creatives rather than serving the top bidder's every time.
+// Unlike a frequency cap it never excludes a bid, it only makes a fresher
+// competitor likelier to win.
+type FatigueModel struct {
+	Curve FatigueCurve
+
+	// Window is how long an exposure is remembered; eight half-lives, or
+	// a day without a half-life, when zero
+	Window time.Duration
+
+	mu        sync.Mutex
+	exposures map[fatigueKey][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type fatigueKey struct {
+	user, creative string
+}
+
+// NewFatigueModel creates a model discounting bids along curve
+func NewFatigueModel(curve FatigueCurve) *FatigueModel {
+	return &FatigueModel{
+		Curve:     curve,
+		exposures: make(map[fatigueKey][]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Factor is the share of its bid a creative keeps for a user
+func (m *FatigueModel) Factor(user, creative string) float64 {
+	if user == "" || creative == "" {
+		return 1
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := m.exposures[fatigueKey{user, creative}]
+	if len(seen) == 0 {
+		return 1
+	}
+	now := m.now()
+	ages := make([]time.Duration, 0, len(seen))
+	for _, t := range seen {
+		if age := now.Sub(t); age < m.window() {
+			ages = append(ages, age)
+		}
+	}
+	return m.Curve.Factor(ages)
+}
+
+// Record counts a user's exposure to a creative
+func (m *FatigueModel) Record(user, creative string) {
+	if user == "" || creative == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+	k := fatigueKey{user, creative}
+	seen := append(m.exposures[k], now)
+	if len(seen) > maxExposures {
+		seen = seen[len(seen)-maxExposures:]
+	}
+	m.exposures[k] = seen
+}
+
+func (m *FatigueModel) window() time.Duration {
+	switch {
+	case m.Window > 0:
+		return m.Window
+	case m.Curve.HalfLife > 0:
+		return 8 * m.Curve.HalfLife
+	}
+	return 24 * time.Hour
+}
+
+// sweep forgets users' creatives they haven't seen within the window;
+// m.mu must be held
+func (m *FatigueModel) sweep(now time.Time) {
+	window := m.window()
+	if now.Sub(m.lastSweep) < window {
+		return
+	}
+	m.lastSweep = now
+	for k, seen := range m.exposures {
+		if now.Sub(seen[len(seen)-1]) >= window {
+			delete(m.exposures, k)
+		}
+	}
+}
+
+// creativeKey identifies a bid's creative for fatigue
+func creativeKey(bid *Bid) string {
+	if bid.CreativeID != "" {
+		return bid.CreativeID
+	}
+	return bid.AdID
+}
+
+// effectivePrice is what a bid competes at for user: its price, discounted
+// for the user's fatigue with its creative
+func (rtb *RTBExchange) effectivePrice(user string, bid *Bid) float64 {
+	if rtb.Fatigue == nil {
+		return bid.Price
+	}
+	return bid.Price * rtb.Fatigue.Factor(user, creativeKey(bid))
+}
@@ -0,0 +1,68 @@
+package rtb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+func TestFatigue_FreshCreativeWins(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	fatigue := NewFatigueModel(FatigueCurve{Decay: 0.1, HalfLife: time.Hour})
+	fatigue.now = func() time.Time { return now }
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.50), Fatigue: fatigue}
+	req := &openrtb2.BidRequest{ID: "req-1", Imp: []openrtb2.Imp{{ID: "1"}}, User: &openrtb2.User{ID: "viewer-1"}}
+	bids := func() []Bid {
+		return []Bid{
+			{ID: "top", ImpID: "1", Price: 10, CreativeID: "cr-top"},
+			{ID: "fresh", ImpID: "1", Price: 8, CreativeID: "cr-fresh"},
+		}
+	}
+
+	// 10 keeps 90% per exposure: 9, then 8.1, then 7.29 loses to 8
+	var got []string
+	for i := 0; i < 4; i++ {
+		winner := exchange.runAuction(bids(), req)
+		if winner == nil {
+			t.Fatal("no winner")
+		}
+		got = append(got, winner.ID)
+		if winner.Price != 10 && winner.Price != 8 {
+			t.Errorf("winner pays %v, not its bid", winner.Price)
+		}
+	}
+	if want := []string{"top", "top", "top", "fresh"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("winners = %v, want %v", got, want)
+	}
+
+	// Another viewer hasn't tired of it
+	other := &openrtb2.BidRequest{ID: "req-2", Imp: []openrtb2.Imp{{ID: "1"}}, User: &openrtb2.User{ID: "viewer-2"}}
+	if winner := exchange.runAuction(bids(), other); winner == nil || winner.ID != "top" {
+		t.Errorf("other viewer's winner = %+v", winner)
+	}
+
+	// Exposures fade: three, three hours on, count as three eighths of one
+	now = now.Add(3 * time.Hour)
+	if winner := exchange.runAuction(bids(), req); winner == nil || winner.ID != "top" {
+		t.Errorf("winner once fatigue faded = %+v", winner)
+	}
+}
+
+func TestFatigueCurve_Factor(t *testing.T) {
+	curve := FatigueCurve{Decay: 0.5, HalfLife: time.Hour, Floor: 0.2}
+	if f := curve.Factor(nil); f != 1 {
+		t.Errorf("unseen factor = %v", f)
+	}
+	if f := curve.Factor([]time.Duration{0}); f != 0.5 {
+		t.Errorf("one fresh exposure = %v, want 0.5", f)
+	}
+	if f := curve.Factor([]time.Duration{0, 0, 0, 0}); f != 0.2 {
+		t.Errorf("factor = %v, want the floor", f)
+	}
+	if a, b := curve.Factor([]time.Duration{time.Hour}), curve.Factor([]time.Duration{0}); a <= b {
+		t.Errorf("hour-old exposure discounts %v, no less than a fresh one's %v", a, b)
+	}
+}
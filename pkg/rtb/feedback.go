@@ -0,0 +1,314 @@
+package rtb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luxfi/adx/pkg/reqlog"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+)
+
+// Feedback delivery modes
+const (
+	FeedbackImpression = "impression" // Each auction's results posted as it closes
+	FeedbackAggregate  = "aggregate"  // Results batched and posted every flush interval
+)
+
+const (
+	// DefaultFeedbackFlush is how often aggregated feedback is posted
+	DefaultFeedbackFlush = 10 * time.Second
+
+	// DefaultFeedbackBatch is the most results posted at once; a batch
+	// reaching it is posted without waiting for the flush
+	DefaultFeedbackBatch = 1000
+)
+
+// FeedbackConfig is where and how a DSP is told its auction results;
+// it isn't told when URL is empty
+type FeedbackConfig struct {
+	URL  string
+	Mode string // FeedbackImpression when empty
+}
+
+// AuctionFeedback is the result of one of a DSP's bids. Winners learn the
+// price they cleared at. Losers outbid on price learn it too, as the
+// minimum to win, but bids filtered out of the auction learn only why,
+// and no DSP learns who won or what others bid beyond that.
+type AuctionFeedback struct {
+	AuctionID     string              `json:"auction_id"`
+	BidID         string              `json:"bid_id"`
+	ImpID         string              `json:"imp_id"`
+	SeatID        string              `json:"seat_id,omitempty"`
+	Won           bool                `json:"won"`
+	LossReason    openrtb3.LossReason `json:"loss_reason"`
+	ClearingPrice float64             `json:"clearing_price,omitempty"`
+	Currency      string              `json:"cur"`
+	Time          time.Time           `json:"time"`
+}
+
+// FeedbackBatch is the body posted to a DSP's feedback endpoint
+type FeedbackBatch struct {
+	DSP     string            `json:"dsp"`
+	Results []AuctionFeedback `json:"results"`
+}
+
+// FeedbackReporter posts auction results to DSPs' feedback endpoints.
+// Failed posts are retried with backoff; delivery is otherwise best
+// effort, like notices.
+type FeedbackReporter struct {
+	// Client posts feedback; http.DefaultClient when nil
+	Client *http.Client
+
+	Retries      int
+	RetryBackoff time.Duration // Doubled after each attempt
+	MaxBatch     int           // DefaultFeedbackBatch when zero
+
+	// Delivered, Failed and Retried count batches posted, batches given
+	// up on and retry attempts; updated atomically
+	Delivered uint64
+	Failed    uint64
+	Retried   uint64
+
+	queue chan feedbackPost
+	wg    sync.WaitGroup
+	stop  chan struct{}
+	once  sync.Once
+
+	mu      sync.Mutex
+	pending map[string]*FeedbackBatch // Aggregated, by endpoint
+}
+
+type feedbackPost struct {
+	url   string
+	batch *FeedbackBatch
+}
+
+// NewFeedbackReporter starts a reporter posting at most concurrency
+// batches at once, and aggregated feedback every flush
+func NewFeedbackReporter(concurrency int, flush time.Duration) *FeedbackReporter {
+	if concurrency <= 0 {
+		concurrency = DefaultNoticeConcurrency
+	}
+	if flush <= 0 {
+		flush = DefaultFeedbackFlush
+	}
+	r := &FeedbackReporter{
+		Retries:      DefaultNoticeRetries,
+		RetryBackoff: 100 * time.Millisecond,
+		queue:        make(chan feedbackPost, noticeQueueSize),
+		stop:         make(chan struct{}),
+		pending:      make(map[string]*FeedbackBatch),
+	}
+	r.wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go r.worker()
+	}
+	go r.flushEvery(flush)
+	return r
+}
+
+// Report delivers a DSP's results as its config asks
+func (r *FeedbackReporter) Report(dspID string, cfg FeedbackConfig, results []AuctionFeedback) {
+	if cfg.URL == "" || len(results) == 0 {
+		return
+	}
+	if cfg.Mode != FeedbackAggregate {
+		r.post(cfg.URL, &FeedbackBatch{DSP: dspID, Results: results})
+		return
+	}
+
+	max := r.MaxBatch
+	if max <= 0 {
+		max = DefaultFeedbackBatch
+	}
+	r.mu.Lock()
+	b, ok := r.pending[cfg.URL]
+	if !ok {
+		b = &FeedbackBatch{DSP: dspID}
+		r.pending[cfg.URL] = b
+	}
+	b.Results = append(b.Results, results...)
+	full := len(b.Results) >= max
+	if full {
+		delete(r.pending, cfg.URL)
+	}
+	r.mu.Unlock()
+	if full {
+		r.post(cfg.URL, b)
+	}
+}
+
+// Flush posts all aggregated feedback now
+func (r *FeedbackReporter) Flush() {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[string]*FeedbackBatch)
+	r.mu.Unlock()
+	for u, b := range pending {
+		r.post(u, b)
+	}
+}
+
+// Close posts aggregated feedback, stops accepting more and waits for
+// queued batches to be delivered
+func (r *FeedbackReporter) Close() {
+	r.once.Do(func() {
+		close(r.stop)
+		r.Flush()
+		close(r.queue)
+	})
+	r.wg.Wait()
+}
+
+func (r *FeedbackReporter) flushEvery(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			r.Flush()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *FeedbackReporter) post(u string, b *FeedbackBatch) {
+	select {
+	case r.queue <- feedbackPost{url: u, batch: b}:
+	default:
+		atomic.AddUint64(&r.Failed, 1)
+		slog.Warn("feedback queue full, dropping batch", reqlog.KeyDSP, b.DSP, "results", len(b.Results))
+	}
+}
+
+func (r *FeedbackReporter) worker() {
+	defer r.wg.Done()
+	for p := range r.queue {
+		r.deliver(p)
+	}
+}
+
+// deliver posts one batch, retrying transport errors and server errors
+func (r *FeedbackReporter) deliver(p feedbackPost) {
+	body, err := json.Marshal(p.batch)
+	if err != nil {
+		atomic.AddUint64(&r.Failed, 1)
+		return
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	backoff := r.RetryBackoff
+	for attempt := 0; attempt <= r.Retries; attempt++ {
+		if attempt > 0 {
+			atomic.AddUint64(&r.Retried, 1)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = postFeedback(client, p.url, body); err == nil {
+			atomic.AddUint64(&r.Delivered, 1)
+			return
+		}
+	}
+	atomic.AddUint64(&r.Failed, 1)
+	slog.Warn("feedback failed", reqlog.KeyDSP, p.batch.DSP, "results", len(p.batch.Results), "error", err)
+}
+
+func postFeedback(client *http.Client, u string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%w: %d", ErrDSPStatus, resp.StatusCode)
+	}
+	return nil
+}
+
+// reportFeedback tells each DSP that asks how its bids in an auction fared
+func (rtb *RTBExchange) reportFeedback(req *openrtb2.BidRequest, winner *Bid, losses []Loss) {
+	clearing := 0.0
+	if winner != nil {
+		clearing = rtb.clearingPrice(req, winner, losses)
+	}
+	now := time.Now().UTC()
+	results := make(map[string][]AuctionFeedback)
+	add := func(bid *Bid, won bool, reason openrtb3.LossReason, price float64) {
+		results[bid.DSP] = append(results[bid.DSP], AuctionFeedback{
+			AuctionID:     req.ID,
+			BidID:         bid.ID,
+			ImpID:         bid.ImpID,
+			SeatID:        bid.SeatID,
+			Won:           won,
+			LossReason:    reason,
+			ClearingPrice: price,
+			Currency:      exchangeCurrency,
+			Time:          now,
+		})
+	}
+	if winner != nil {
+		add(winner, true, openrtb3.LossWon, clearing)
+	}
+	for _, l := range losses {
+		price := 0.0
+		if winner != nil && lostOnPrice(l.Reason) {
+			price = clearing
+		}
+		add(l.Bid, false, l.Reason, price)
+	}
+
+	ids := make([]string, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if d, ok := rtb.DSP(id); ok {
+			rtb.Feedback.Report(id, d.Feedback, results[id])
+		}
+	}
+}
+
+// clearingPrice is what the winner pays under the auction's type: its bid
+// in a first-price auction, and in a second-price one the floor or the
+// best bid it beat on price, whichever is higher
+func (rtb *RTBExchange) clearingPrice(req *openrtb2.BidRequest, winner *Bid, losses []Loss) float64 {
+	if req.AT != 2 {
+		return winner.Price
+	}
+	price := rtb.floorFor(req, winner.ImpID)
+	for _, l := range losses {
+		if l.Bid.ImpID == winner.ImpID && lostOnPrice(l.Reason) && l.Bid.Price > price {
+			price = l.Bid.Price
+		}
+	}
+	if price > winner.Price {
+		price = winner.Price
+	}
+	return price
+}
+
+// lostOnPrice reports whether a bid competed and was outbid, rather than
+// being filtered out
+func lostOnPrice(reason openrtb3.LossReason) bool {
+	return reason == openrtb3.LossLostToHigherBid || reason == openrtb3.LossLostToDealBid
+}
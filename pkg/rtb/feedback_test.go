@@ -0,0 +1,154 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+	"github.com/shopspring/decimal"
+)
+
+// feedbackRecorder collects the feedback batches it is posted
+type feedbackRecorder struct {
+	*httptest.Server
+	mu      sync.Mutex
+	batches []FeedbackBatch
+	fail    int // Requests to answer 503 before succeeding
+}
+
+func newFeedbackRecorder(t *testing.T) *feedbackRecorder {
+	rec := &feedbackRecorder{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		if rec.fail > 0 {
+			rec.fail--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var b FeedbackBatch
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		rec.batches = append(rec.batches, b)
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func (rec *feedbackRecorder) results() map[string]AuctionFeedback {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	out := make(map[string]AuctionFeedback)
+	for _, b := range rec.batches {
+		for _, r := range b.Results {
+			out[b.DSP+"/"+r.BidID] = r
+		}
+	}
+	return out
+}
+
+func TestFeedback_WinAndLoss(t *testing.T) {
+	rec := newFeedbackRecorder(t)
+	reporter := NewFeedbackReporter(2, time.Hour)
+	dsps := map[string]*DSPConnection{
+		"high":  noticeDSP(t, "", "high", 3.5),
+		"low":   noticeDSP(t, "", "low", 2.25),
+		"floor": noticeDSP(t, "", "floor", 0.1),
+	}
+	dsps["high"].Feedback = FeedbackConfig{URL: rec.URL + "/high"}
+	dsps["low"].Feedback = FeedbackConfig{URL: rec.URL + "/low", Mode: FeedbackAggregate}
+	dsps["floor"].Feedback = FeedbackConfig{URL: rec.URL + "/floor"}
+	exchange := &RTBExchange{
+		DSPs:           dsps,
+		AuctionTimeout: time.Second,
+		FloorPrice:     decimal.NewFromFloat(0.5),
+		Feedback:       reporter,
+		Revenue:        big.NewInt(0),
+	}
+	req := &openrtb2.BidRequest{ID: "auc-1", AT: 2, Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}}
+	if _, err := exchange.BidRequest(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	reporter.Close()
+
+	got := rec.results()
+	want := map[string]AuctionFeedback{
+		// Second price: the winner clears at the runner-up's bid
+		"high/bid-high":   {Won: true, LossReason: openrtb3.LossWon, ClearingPrice: 2.25},
+		"low/bid-low":     {LossReason: openrtb3.LossLostToHigherBid, ClearingPrice: 2.25},
+		"floor/bid-floor": {LossReason: openrtb3.LossBelowAuctionFloor},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("feedback = %+v, want %d results", got, len(want))
+	}
+	for k, w := range want {
+		g := got[k]
+		if g.AuctionID != "auc-1" || g.ImpID != "1" || g.Won != w.Won || g.LossReason != w.LossReason || g.ClearingPrice != w.ClearingPrice {
+			t.Errorf("%s = %+v, want %+v", k, g, w)
+		}
+	}
+	if n := atomic.LoadUint64(&reporter.Delivered); n != 3 {
+		t.Errorf("delivered %d batches, want 3", n)
+	}
+}
+
+func TestFeedback_ClearingPrice(t *testing.T) {
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(1)}
+	winner := &Bid{ID: "w", ImpID: "1", Price: 5}
+	losses := []Loss{
+		{Bid: &Bid{ImpID: "1", Price: 3}, Reason: openrtb3.LossLostToHigherBid},
+		{Bid: &Bid{ImpID: "1", Price: 4.5}, Reason: openrtb3.LossCategoryExclusions},
+		{Bid: &Bid{ImpID: "2", Price: 4}, Reason: openrtb3.LossLostToHigherBid},
+	}
+	req := &openrtb2.BidRequest{Imp: []openrtb2.Imp{{ID: "1"}, {ID: "2"}}}
+	if p := exchange.clearingPrice(req, winner, losses); p != 5 {
+		t.Errorf("first price cleared at %v, want the winning bid", p)
+	}
+	req.AT = 2
+	if p := exchange.clearingPrice(req, winner, losses); p != 3 {
+		t.Errorf("second price cleared at %v, want the best bid beaten on price", p)
+	}
+	if p := exchange.clearingPrice(req, winner, nil); p != 1 {
+		t.Errorf("uncontested second price cleared at %v, want the floor", p)
+	}
+}
+
+func TestFeedback_Retries(t *testing.T) {
+	rec := newFeedbackRecorder(t)
+	rec.fail = 2
+	reporter := NewFeedbackReporter(1, time.Hour)
+	reporter.RetryBackoff = time.Millisecond
+	reporter.Retries = 2
+
+	cfg := FeedbackConfig{URL: rec.URL}
+	reporter.Report("dsp1", cfg, []AuctionFeedback{{AuctionID: "a1", BidID: "b1", Won: true}})
+	reporter.Close()
+
+	if got := rec.results(); len(got) != 1 {
+		t.Errorf("feedback = %+v after two failures", got)
+	}
+	if d, r := atomic.LoadUint64(&reporter.Delivered), atomic.LoadUint64(&reporter.Retried); d != 1 || r != 2 {
+		t.Errorf("delivered %d, retried %d; want 1 and 2", d, r)
+	}
+
+	// A batch that never gets through is counted as failed
+	down := newFeedbackRecorder(t)
+	down.fail = 10
+	reporter = NewFeedbackReporter(1, time.Hour)
+	reporter.RetryBackoff = time.Millisecond
+	reporter.Retries = 1
+	reporter.Report("dsp1", FeedbackConfig{URL: down.URL}, []AuctionFeedback{{AuctionID: "a2"}})
+	reporter.Close()
+	if f := atomic.LoadUint64(&reporter.Failed); f != 1 {
+		t.Errorf("failed %d batches, want 1", f)
+	}
+}
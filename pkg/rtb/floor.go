@@ -0,0 +1,335 @@
+package rtb
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+	"github.com/shopspring/decimal"
+)
+
+// FloorContext describes the impression a floor is being computed for
+type FloorContext struct {
+	Placement  string
+	Country    string
+	DeviceType int
+	Time       time.Time
+	AuctionID  string // Splits auctions between reserves the Optimizer is comparing
+}
+
+// Daypart is an hour range [StartHour, EndHour) in UTC. A range that wraps
+// midnight (e.g. 22-6) is allowed.
+type Daypart struct {
+	StartHour int
+	EndHour   int
+}
+
+// Contains reports whether t falls in the daypart
+func (d Daypart) Contains(t time.Time) bool {
+	h := t.UTC().Hour()
+	if d.StartHour <= d.EndHour {
+		return h >= d.StartHour && h < d.EndHour
+	}
+	return h >= d.StartHour || h < d.EndHour
+}
+
+// FloorRule sets a floor for impressions matching all of its non-empty
+// criteria. When Percentile is set the floor tracks that percentile of
+// recent clearing prices, with Floor as the minimum.
+type FloorRule struct {
+	Name        string
+	Placements  []string
+	Countries   []string
+	DeviceTypes []int
+	Dayparts    []Daypart
+	Floor       float64 // CPM
+	Percentile  float64 // 0-100, 0 disables the dynamic floor
+
+	// Suggested raises the floor to the Source's suggestion for the
+	// placement and time, when there is one
+	Suggested bool
+
+	// Optimized raises the floor to the Optimizer's reserve for the
+	// placement
+	Optimized bool
+}
+
+// FloorSource suggests floors from per-placement clearing-price history,
+// such as analytics.AnalyticsTracker
+type FloorSource interface {
+	RecordClearingPrice(placementID string, price decimal.Decimal, at time.Time)
+	GetFloorSuggestion(placementID string, now time.Time) decimal.Decimal
+}
+
+// Matches reports whether the rule applies to ctx
+func (r *FloorRule) Matches(ctx FloorContext) bool {
+	if len(r.Placements) > 0 && !containsFold(r.Placements, ctx.Placement) {
+		return false
+	}
+	if len(r.Countries) > 0 && !containsFold(r.Countries, ctx.Country) {
+		return false
+	}
+	if len(r.DeviceTypes) > 0 {
+		found := false
+		for _, dt := range r.DeviceTypes {
+			if dt == ctx.DeviceType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Dayparts) > 0 {
+		found := false
+		for _, dp := range r.Dayparts {
+			if dp.Contains(ctx.Time) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// FloorRules evaluates ordered floor rules; the first match wins and the
+// default applies when nothing matches
+type FloorRules struct {
+	mu      sync.RWMutex
+	rules   []FloorRule
+	Default float64
+
+	// Source backs rules with Suggested set
+	Source FloorSource
+
+	// Optimizer backs rules with Optimized set, and learns from every
+	// auction on a placement
+	Optimizer *ReserveOptimizer
+
+	// Ring buffer of recent clearing prices for dynamic floors, and the
+	// same prices kept sorted for percentiles
+	prices     []float64
+	sorted     []float64
+	next       int
+	windowSize int
+}
+
+// NewFloorRules creates a rules engine that remembers the last windowSize
+// clearing prices for percentile floors
+func NewFloorRules(defaultFloor float64, windowSize int) *FloorRules {
+	if windowSize <= 0 {
+		windowSize = 1000
+	}
+	return &FloorRules{
+		Default:    defaultFloor,
+		windowSize: windowSize,
+		prices:     make([]float64, 0, windowSize),
+		sorted:     make([]float64, 0, windowSize),
+	}
+}
+
+// AddRule appends a rule; earlier rules take precedence
+func (f *FloorRules) AddRule(rule FloorRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, rule)
+}
+
+// Floor returns the effective CPM floor for ctx
+func (f *FloorRules) Floor(ctx FloorContext) float64 {
+	if ctx.Time.IsZero() {
+		ctx.Time = time.Now()
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for i := range f.rules {
+		rule := &f.rules[i]
+		if !rule.Matches(ctx) {
+			continue
+		}
+		floor := rule.Floor
+		if rule.Percentile > 0 {
+			floor = math.Max(floor, f.percentile(rule.Percentile))
+		}
+		if rule.Suggested && f.Source != nil && ctx.Placement != "" {
+			floor = math.Max(floor, f.Source.GetFloorSuggestion(ctx.Placement, ctx.Time).InexactFloat64())
+		}
+		if rule.Optimized && f.Optimizer != nil && ctx.Placement != "" {
+			floor = math.Max(floor, f.Optimizer.Reserve(ctx.Placement, ctx.AuctionID))
+		}
+		return floor
+	}
+	return f.Default
+}
+
+// FloorFor adapts Floor to callers that don't build a FloorContext
+func (f *FloorRules) FloorFor(placement, country string, deviceType int, at time.Time) float64 {
+	return f.Floor(FloorContext{
+		Placement:  placement,
+		Country:    country,
+		DeviceType: deviceType,
+		Time:       at,
+	})
+}
+
+// RecordClearingPrice feeds a cleared auction price into the dynamic floor
+// window
+func (f *FloorRules) RecordClearingPrice(price float64) {
+	if price <= 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.prices) < f.windowSize {
+		f.prices = append(f.prices, price)
+		f.sorted = slices.Insert(f.sorted, sort.SearchFloat64s(f.sorted, price), price)
+		return
+	}
+	evicted := f.prices[f.next]
+	f.prices[f.next] = price
+	f.next = (f.next + 1) % f.windowSize
+
+	// Move the new price into the evicted one's slot, shifting what lies
+	// between them
+	i := sort.SearchFloat64s(f.sorted, evicted)
+	j := sort.SearchFloat64s(f.sorted, price)
+	if j > i {
+		j--
+		copy(f.sorted[i:j], f.sorted[i+1:j+1])
+	} else {
+		copy(f.sorted[j+1:i+1], f.sorted[j:i])
+	}
+	f.sorted[j] = price
+}
+
+// recordPlacementPrice feeds a cleared price into both the dynamic floor
+// window and the Source's per-placement history
+func (f *FloorRules) recordPlacementPrice(placement string, price float64, at time.Time) {
+	f.RecordClearingPrice(price)
+	if f.Source != nil && placement != "" {
+		f.Source.RecordClearingPrice(placement, decimal.NewFromFloat(price), at)
+	}
+}
+
+// recordReserves reports each placement's outcome in req to the
+// Optimizer: the winning price on the won impression, no fill elsewhere
+func (f *FloorRules) recordReserves(req *openrtb2.BidRequest, winner *Bid) {
+	if f.Optimizer == nil {
+		return
+	}
+	for _, imp := range req.Imp {
+		if imp.TagID == "" {
+			continue
+		}
+		var price float64
+		if winner != nil && winner.ImpID == imp.ID {
+			price = winner.Price
+		}
+		f.Optimizer.Record(imp.TagID, req.ID, price)
+	}
+}
+
+// Percentile returns the p-th percentile (0-100) of recent clearing prices
+func (f *FloorRules) Percentile(p float64) float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.percentile(p)
+}
+
+// percentile uses nearest-rank on the sorted window
+func (f *FloorRules) percentile(p float64) float64 {
+	sorted := f.sorted
+	if len(sorted) == 0 {
+		return 0
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+	rank := int(p / 100 * float64(len(sorted)))
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// NoBidBelowFloor is the exchange-specific no-bid reason for an auction
+// whose bids were all under the floor
+const NoBidBelowFloor openrtb3.NoBidReason = 500
+
+// allBelowFloor reports whether there were bids and every one was under
+// its impression's floor
+func (rtb *RTBExchange) allBelowFloor(req *openrtb2.BidRequest, bids []Bid) bool {
+	for i := range bids {
+		if bids[i].Price >= rtb.floorFor(req, bids[i].ImpID) {
+			return false
+		}
+	}
+	return len(bids) > 0
+}
+
+// floorFor returns the floor for an impression in req, falling back to the
+// exchange-wide FloorPrice when no rules are configured
+func (rtb *RTBExchange) floorFor(req *openrtb2.BidRequest, impID string) float64 {
+	floor := rtb.FloorPrice.InexactFloat64()
+
+	// The publisher's own imp floor, in USD, and the floor for its
+	// placement type are never undercut
+	var impFloor float64
+	ctx := FloorContext{Time: time.Now(), AuctionID: req.ID}
+	for i := range req.Imp {
+		imp := &req.Imp[i]
+		if imp.ID == impID {
+			ctx.Placement = imp.TagID
+			if imp.BidFloorCur == "" || imp.BidFloorCur == exchangeCurrency {
+				impFloor = imp.BidFloor
+			}
+			if rtb.PlacementFloors != nil {
+				impFloor = math.Max(impFloor, rtb.PlacementFloors.floor(PlacementTypeOf(imp)))
+			}
+			break
+		}
+	}
+	if rtb.FloorRules == nil {
+		return math.Max(floor, impFloor)
+	}
+
+	if req.Device != nil {
+		ctx.DeviceType = int(req.Device.DeviceType)
+		if req.Device.Geo != nil {
+			ctx.Country = req.Device.Geo.Country
+		}
+	}
+	return math.Max(rtb.FloorRules.Floor(ctx), impFloor)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagID returns the placement of the impression impID in req
+func tagID(req *openrtb2.BidRequest, impID string) string {
+	for _, imp := range req.Imp {
+		if imp.ID == impID {
+			return imp.TagID
+		}
+	}
+	return ""
+}
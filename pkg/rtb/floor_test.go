@@ -0,0 +1,206 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+func TestFloorRules_Precedence(t *testing.T) {
+	rules := NewFloorRules(0.50, 100)
+	rules.AddRule(FloorRule{Name: "premium-ctv-us", Countries: []string{"USA"}, DeviceTypes: []int{3}, Floor: 12.0})
+	rules.AddRule(FloorRule{Name: "us", Countries: []string{"USA"}, Floor: 4.0})
+	rules.AddRule(FloorRule{Name: "primetime", Dayparts: []Daypart{{StartHour: 19, EndHour: 23}}, Floor: 6.0})
+	rules.AddRule(FloorRule{Name: "overnight", Dayparts: []Daypart{{StartHour: 23, EndHour: 6}}, Floor: 0.25})
+
+	noon := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	evening := time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC)
+	night := time.Date(2025, 1, 1, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		ctx  FloorContext
+		want float64
+	}{
+		{"first matching rule wins", FloorContext{Country: "USA", DeviceType: 3, Time: evening}, 12.0},
+		{"geo overrides default", FloorContext{Country: "usa", DeviceType: 4, Time: noon}, 4.0},
+		{"daypart", FloorContext{Country: "GBR", Time: evening}, 6.0},
+		{"daypart wrapping midnight", FloorContext{Country: "GBR", Time: night}, 0.25},
+		{"default", FloorContext{Country: "GBR", Time: noon}, 0.50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.Floor(tt.ctx); got != tt.want {
+				t.Errorf("Floor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloorRules_Placement(t *testing.T) {
+	rules := NewFloorRules(1.0, 100)
+	rules.AddRule(FloorRule{Placements: []string{"preroll-home"}, Floor: 8.0})
+
+	if got := rules.FloorFor("preroll-home", "", 0, time.Now()); got != 8.0 {
+		t.Errorf("placement floor = %v, want 8", got)
+	}
+	if got := rules.FloorFor("midroll", "", 0, time.Now()); got != 1.0 {
+		t.Errorf("other placement floor = %v, want default 1", got)
+	}
+}
+
+func TestFloorRules_DynamicPercentile(t *testing.T) {
+	rules := NewFloorRules(0.50, 10)
+	rules.AddRule(FloorRule{Name: "dynamic", Floor: 2.0, Percentile: 50})
+
+	// No history yet: the static minimum applies
+	if got := rules.Floor(FloorContext{}); got != 2.0 {
+		t.Errorf("empty window floor = %v, want 2", got)
+	}
+
+	for _, p := range []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10} {
+		rules.RecordClearingPrice(p)
+	}
+	if got := rules.Percentile(50); got != 6 {
+		t.Errorf("p50 = %v, want 6", got)
+	}
+	if got := rules.Percentile(100); got != 10 {
+		t.Errorf("p100 = %v, want 10", got)
+	}
+	if got := rules.Floor(FloorContext{}); got != 6 {
+		t.Errorf("dynamic floor = %v, want 6", got)
+	}
+
+	// The window is bounded: old prices roll off
+	for i := 0; i < 10; i++ {
+		rules.RecordClearingPrice(1)
+	}
+	if got := rules.Floor(FloorContext{}); got != 2.0 {
+		t.Errorf("floor after low prices = %v, want static minimum 2", got)
+	}
+}
+
+func TestFloorRules_PercentileMatchesSortedWindow(t *testing.T) {
+	const window = 16
+	rules := NewFloorRules(0, window)
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	var seen []float64
+	for n := 0; n < 500; n++ {
+		// Few distinct values so evictions hit duplicates
+		price := float64(1 + rng.IntN(8))
+		rules.RecordClearingPrice(price)
+		seen = append(seen, price)
+
+		want := slices.Clone(seen[max(0, len(seen)-window):])
+		slices.Sort(want)
+		for _, p := range []float64{0, 10, 50, 90, 100} {
+			rank := min(int(p/100*float64(len(want))), len(want)-1)
+			if got := rules.Percentile(p); got != want[rank] {
+				t.Fatalf("after %d prices p%v = %v, want %v", n+1, p, got, want[rank])
+			}
+		}
+	}
+}
+
+// placementFloors suggests a fixed floor per placement
+type placementFloors map[string]float64
+
+func (p placementFloors) RecordClearingPrice(placementID string, price decimal.Decimal, at time.Time) {
+	p[placementID] = price.InexactFloat64()
+}
+
+func (p placementFloors) GetFloorSuggestion(placementID string, now time.Time) decimal.Decimal {
+	return decimal.NewFromFloat(p[placementID])
+}
+
+func TestFloorRules_Suggested(t *testing.T) {
+	rules := NewFloorRules(0.50, 10)
+	rules.Source = placementFloors{"ctv-preroll": 9.5, "display-top": 0.4}
+	rules.AddRule(FloorRule{Name: "suggested", Floor: 1.0, Suggested: true})
+
+	for placement, want := range map[string]float64{
+		"ctv-preroll": 9.5,
+		"display-top": 1.0, // The static floor is the minimum
+		"":            1.0,
+	} {
+		if got := rules.Floor(FloorContext{Placement: placement}); got != want {
+			t.Errorf("%q floor = %v, want %v", placement, got, want)
+		}
+	}
+
+	// Cleared auctions feed the source's placement history
+	rules.recordPlacementPrice("display-top", 3.25, time.Now())
+	if got := rules.Floor(FloorContext{Placement: "display-top"}); got != 3.25 {
+		t.Errorf("floor after clearing = %v, want 3.25", got)
+	}
+}
+
+func TestRunAuction_FloorRules(t *testing.T) {
+	rules := NewFloorRules(0.50, 100)
+	rules.AddRule(FloorRule{Countries: []string{"USA"}, Floor: 5.0})
+
+	exchange := &RTBExchange{
+		FloorPrice: decimal.NewFromFloat(0.50),
+		FloorRules: rules,
+	}
+	req := &openrtb2.BidRequest{
+		ID:  "req-1",
+		Imp: []openrtb2.Imp{{ID: "1"}},
+		Device: &openrtb2.Device{
+			DeviceType: adcom1.DeviceTV,
+			Geo:        &openrtb2.Geo{Country: "USA"},
+		},
+	}
+	bids := []Bid{{ID: "low", ImpID: "1", Price: 3.0}}
+
+	if winner := exchange.runAuction(bids, req); winner != nil {
+		t.Errorf("bid below the geo floor should lose, got %s", winner.ID)
+	}
+
+	req.Device.Geo.Country = "GBR"
+	if winner := exchange.runAuction(bids, req); winner == nil {
+		t.Error("bid above the default floor should win")
+	}
+}
+
+func TestBidRequest_BelowFloorReason(t *testing.T) {
+	dsp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openrtb2.BidRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{ID: req.ID, SeatBid: []openrtb2.SeatBid{{
+			Bid: []openrtb2.Bid{{ID: "b1", ImpID: req.Imp[0].ID, Price: 0.20}},
+		}}})
+	}))
+	defer dsp.Close()
+	exchange := &RTBExchange{
+		DSPs:           map[string]*DSPConnection{"dsp1": {ID: "dsp1", Endpoint: dsp.URL, Timeout: time.Second, RateLimiter: NewRateLimiter(100)}},
+		FloorPrice:     decimal.NewFromFloat(0.50),
+		AuctionTimeout: time.Second,
+		Revenue:        big.NewInt(0),
+	}
+
+	resp, err := exchange.BidRequest(context.Background(), &openrtb2.BidRequest{ID: "auc-1", Imp: []openrtb2.Imp{{ID: "1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.NBR == nil || *resp.NBR != NoBidBelowFloor {
+		t.Errorf("nbr = %v, want below floor", resp.NBR)
+	}
+
+	exchange.DSPs = map[string]*DSPConnection{}
+	if resp, _ := exchange.BidRequest(context.Background(), &openrtb2.BidRequest{ID: "auc-2", Imp: []openrtb2.Imp{{ID: "1"}}}); resp.NBR != nil {
+		t.Errorf("nbr without bids = %v", *resp.NBR)
+	}
+}
@@ -0,0 +1,89 @@
+package rtb
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultFrequencyWindow is the period a frequency cap counts over
+	DefaultFrequencyWindow = 24 * time.Hour
+
+	// DefaultRedisTimeout bounds each frequency store round trip; a cap
+	// check mustn't eat into the auction
+	DefaultRedisTimeout = 50 * time.Millisecond
+)
+
+// FrequencyStore keeps per-user, per-campaign impression counts in fixed
+// windows, shared by every exchange node
+type FrequencyStore interface {
+	// Increment counts an impression and returns the window's count
+	// including it
+	Increment(ctx context.Context, userID, campaignID string, window time.Duration) (int64, error)
+}
+
+// RedisFrequencyStore is a FrequencyStore in Redis. Each window is a key
+// counted with INCR that expires with the window.
+type RedisFrequencyStore struct {
+	client *redis.Client
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisFrequencyStore creates a store on the Redis server at addr
+func NewRedisFrequencyStore(addr string) *RedisFrequencyStore {
+	return &RedisFrequencyStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:         addr,
+			DialTimeout:  DefaultRedisTimeout,
+			ReadTimeout:  DefaultRedisTimeout,
+			WriteTimeout: DefaultRedisTimeout,
+			MaxRetries:   -1, // A retry would outlast the auction
+		}),
+		prefix: "adx:fcap:",
+		now:    time.Now,
+	}
+}
+
+// Increment counts an impression. The window's key is created with its
+// expiry by SET NX EX and counted by INCR in one transaction, so it can't
+// be left without one.
+func (s *RedisFrequencyStore) Increment(ctx context.Context, userID, campaignID string, window time.Duration) (int64, error) {
+	if window <= 0 {
+		window = DefaultFrequencyWindow
+	}
+	bucket := s.now().UnixNano() / int64(window)
+	key := s.prefix + userID + ":" + campaignID + ":" + strconv.FormatInt(bucket, 10)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultRedisTimeout)
+	defer cancel()
+	pipe := s.client.TxPipeline()
+	pipe.SetNX(ctx, key, 0, window+time.Second)
+	count := pipe.Incr(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// Close closes the store's connections
+func (s *RedisFrequencyStore) Close() error {
+	return s.client.Close()
+}
+
+// checkSharedFrequency counts the impression in the shared store, reporting
+// false when every node together has reached the cap. The store failing
+// opens the cap rather than the auction.
+func (rtb *RTBExchange) checkSharedFrequency(ctx context.Context, req *openrtb2.BidRequest, user, campaign string) bool {
+	count, err := rtb.FrequencyStore.Increment(ctx, user, campaign, rtb.FrequencyWindow)
+	if err != nil {
+		slog.Warn("frequency store unavailable, not capping", "request", req.ID, "error", err)
+		return true
+	}
+	return count <= int64(rtb.FrequencyCap)
+}
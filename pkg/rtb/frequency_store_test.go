@@ -0,0 +1,189 @@
+package rtb
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+func TestRedisFrequencyStore_Window(t *testing.T) {
+	srv := miniredis.RunT(t)
+	store := NewRedisFrequencyStore(srv.Addr())
+	defer store.Close()
+	clock := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return clock }
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := store.Increment(t.Context(), "user-1", "camp-1", time.Hour)
+		if err != nil || n != want {
+			t.Fatalf("increment = %d, %v; want %d", n, err, want)
+		}
+	}
+	if n, _ := store.Increment(t.Context(), "user-1", "camp-2", time.Hour); n != 1 {
+		t.Errorf("other campaign count = %d, want 1", n)
+	}
+
+	// The next window starts over, and each window's key expires with it
+	clock = clock.Add(time.Hour)
+	if n, _ := store.Increment(t.Context(), "user-1", "camp-1", time.Hour); n != 1 {
+		t.Errorf("next window count = %d, want 1", n)
+	}
+	keys := srv.Keys()
+	if len(keys) != 3 {
+		t.Errorf("keys = %v", keys)
+	}
+	for _, key := range keys {
+		if ttl := srv.TTL(key); ttl < time.Hour || ttl > time.Hour+time.Second {
+			t.Errorf("%s ttl = %s", key, ttl)
+		}
+	}
+
+	// Once the window's key expires, counting starts over with a new expiry
+	srv.FastForward(time.Hour + time.Second)
+	if n, _ := store.Increment(t.Context(), "user-1", "camp-1", time.Hour); n != 1 {
+		t.Errorf("count after expiry = %d, want 1", n)
+	}
+}
+
+func TestFrequencyStore_SharedAcrossNodes(t *testing.T) {
+	srv := miniredis.RunT(t)
+	const nodes, perNode, maxImps = 4, 10, 5
+
+	var wins atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < nodes; i++ {
+		store := NewRedisFrequencyStore(srv.Addr())
+		defer store.Close()
+		// Each node has its own enclave counter, which alone would let
+		// the user through on every node
+		node := &RTBExchange{
+			FloorPrice:     decimal.NewFromFloat(0.5),
+			Frequency:      countingCap{},
+			FrequencyCap:   maxImps,
+			FrequencyStore: store,
+		}
+		var mu sync.Mutex
+		for j := 0; j < perNode; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := &openrtb2.BidRequest{
+					ID:   "req",
+					Imp:  []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}},
+					User: &openrtb2.User{ID: "user-1"},
+				}
+				// countingCap isn't safe for concurrent use
+				mu.Lock()
+				defer mu.Unlock()
+				if node.runAuction([]Bid{{ID: "b1", ImpID: "1", Price: 4, CampaignID: "camp-1"}}, req) != nil {
+					wins.Add(1)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if got := wins.Load(); got != maxImps {
+		t.Errorf("user won %d auctions across %d nodes, want the cap of %d", got, nodes, maxImps)
+	}
+}
+
+func TestFrequencyStore_CountsOnlyUnderLocalCap(t *testing.T) {
+	srv := miniredis.RunT(t)
+	store := NewRedisFrequencyStore(srv.Addr())
+	defer store.Close()
+	store.now = func() time.Time { return time.Unix(0, 0) }
+	exchange := &RTBExchange{
+		FloorPrice:     decimal.NewFromFloat(0.5),
+		Frequency:      countingCap{},
+		FrequencyCap:   2,
+		FrequencyStore: store,
+	}
+	req := &openrtb2.BidRequest{ID: "req", Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}, User: &openrtb2.User{ID: "user-1"}}
+	for i := 0; i < 5; i++ {
+		exchange.runAuction([]Bid{{ID: "b1", ImpID: "1", Price: 4, CampaignID: "camp-1"}}, req)
+	}
+	// Bids this node capped never reach the shared count
+	if got, err := srv.Get("adx:fcap:user-1:camp-1:0"); err != nil || got != "2" {
+		t.Errorf("shared count = %q, %v; want 2", got, err)
+	}
+}
+
+func TestFrequencyStore_FailsOpen(t *testing.T) {
+	// Nothing listens here once the listener closes
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(prev)
+
+	store := NewRedisFrequencyStore(addr)
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.5), FrequencyCap: 1, FrequencyStore: store}
+	req := &openrtb2.BidRequest{ID: "req-9", Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}, User: &openrtb2.User{ID: "user-1"}}
+	bids := []Bid{{ID: "b1", ImpID: "1", Price: 4}}
+
+	for i := 0; i < 2; i++ {
+		if exchange.runAuction(bids, req) == nil {
+			t.Fatal("auction failed with the frequency store down")
+		}
+	}
+	if !strings.Contains(logs.String(), "frequency store unavailable") || !strings.Contains(logs.String(), "req-9") {
+		t.Errorf("no warning logged: %s", logs.String())
+	}
+}
+
+// stalledStore answers only once its context is done
+type stalledStore struct{}
+
+func (stalledStore) Increment(ctx context.Context, userID, campaignID string, window time.Duration) (int64, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestFrequencyStore_CutOffAtAuctionDeadline(t *testing.T) {
+	exchange := &RTBExchange{
+		DSPs:           map[string]*DSPConnection{"dsp": noticeDSP(t, "", "dsp", 4)},
+		FloorPrice:     decimal.NewFromFloat(0.5),
+		FrequencyCap:   1,
+		FrequencyStore: stalledStore{},
+		AuctionTimeout: 50 * time.Millisecond,
+		Revenue:        big.NewInt(0),
+	}
+	req := &openrtb2.BidRequest{ID: "req", Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}, User: &openrtb2.User{ID: "user-1"}}
+
+	type result struct {
+		resp *openrtb2.BidResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := exchange.BidRequest(context.Background(), req)
+		done <- result{resp, err}
+	}()
+	select {
+	case r := <-done:
+		// The store timing out opens the cap
+		if r.err != nil || len(r.resp.SeatBid) != 1 {
+			t.Errorf("response = %+v, %v; want the DSP's bid", r.resp, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("frequency store call outlived the auction deadline")
+	}
+}
@@ -0,0 +1,73 @@
+package rtb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoDSPs          = errors.New("no DSPs configured")
+	ErrDSPsUnreachable = errors.New("no DSP reachable")
+)
+
+// Ping checks the DSP's endpoint answers HTTP. Any response counts, since
+// bid endpoints commonly reject anything but POST.
+func (d *DSPConnection) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, d.Endpoint, nil)
+	if err != nil {
+		return err
+	}
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Ping reports whether the exchange can reach demand: it is ready while
+// at least one DSP answers
+func (rtb *RTBExchange) Ping(ctx context.Context) error {
+	rtb.mu.RLock()
+	dsps := make([]*DSPConnection, 0, len(rtb.DSPs))
+	for _, d := range rtb.DSPs {
+		dsps = append(dsps, d)
+	}
+	rtb.mu.RUnlock()
+	if len(dsps) == 0 {
+		return ErrNoDSPs
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures []string
+	)
+	for _, d := range dsps {
+		wg.Add(1)
+		go func(d *DSPConnection) {
+			defer wg.Done()
+			if err := d.Ping(ctx); err != nil {
+				mu.Lock()
+				failures = append(failures, d.ID)
+				mu.Unlock()
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	if len(failures) == len(dsps) {
+		sort.Strings(failures)
+		return fmt.Errorf("%w: %s", ErrDSPsUnreachable, strings.Join(failures, ", "))
+	}
+	return nil
+}
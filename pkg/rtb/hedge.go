@@ -0,0 +1,102 @@
+package rtb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// DefaultHedgeMaxRatio caps hedged requests at a tenth of a DSP's calls
+// when a HedgePolicy doesn't set MaxRatio
+const DefaultHedgeMaxRatio = 0.1
+
+// HedgePolicy sends a DSP that hasn't answered within After of the auction
+// timeout a second, identical request, taking whichever answer arrives
+// first and canceling the other
+type HedgePolicy struct {
+	// After is the fraction of AuctionTimeout to wait before hedging
+	After float64
+
+	// MaxRatio caps hedges as a fraction of the DSP's calls, so a DSP that
+	// is slow across the board isn't sent double the load
+	MaxRatio float64
+}
+
+// HedgeWinRate is the fraction of hedged requests that answered first
+func (d *DSPConnection) HedgeWinRate() float64 {
+	hedges := atomic.LoadUint64(&d.HedgeCount)
+	if hedges == 0 {
+		return 0
+	}
+	return float64(atomic.LoadUint64(&d.HedgeWins)) / float64(hedges)
+}
+
+// sendBid sends req, hedging per the DSP's policy when it is slow to answer
+func (d *DSPConnection) sendBid(ctx context.Context, req *openrtb2.BidRequest, auctionTimeout time.Duration) (*Bid, error) {
+	h := d.Hedge
+	if h == nil || h.After <= 0 || auctionTimeout <= 0 {
+		return d.SendBidRequest(ctx, req)
+	}
+	atomic.AddUint64(&d.hedgeCalls, 1)
+
+	// Canceling on return abandons whichever request lost
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		bid   *Bid
+		err   error
+		hedge bool
+	}
+	results := make(chan result, 2)
+	send := func(hedge bool) {
+		r := result{hedge: hedge}
+		defer func() { results <- r }()
+		defer d.recoverSend(&r.err)
+		r.bid, r.err = d.SendBidRequest(ctx, req)
+	}
+	go send(false)
+
+	timer := time.NewTimer(time.Duration(h.After * float64(auctionTimeout)))
+	defer timer.Stop()
+	inFlight := 1
+	for {
+		select {
+		case r := <-results:
+			inFlight--
+			// A failed request still leaves the other one a chance
+			if r.err != nil && inFlight > 0 {
+				continue
+			}
+			if r.hedge && r.err == nil {
+				atomic.AddUint64(&d.HedgeWins, 1)
+			}
+			return r.bid, r.err
+		case <-timer.C:
+			if d.allowHedge(h) {
+				inFlight++
+				go send(true)
+			}
+		}
+	}
+}
+
+// allowHedge counts a hedge if the DSP is under its hedge cap
+func (d *DSPConnection) allowHedge(h *HedgePolicy) bool {
+	ratio := h.MaxRatio
+	if ratio <= 0 {
+		ratio = DefaultHedgeMaxRatio
+	}
+	limit := uint64(ratio * float64(atomic.LoadUint64(&d.hedgeCalls)))
+	for {
+		hedges := atomic.LoadUint64(&d.HedgeCount)
+		if hedges >= limit {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&d.HedgeCount, hedges, hedges+1) {
+			return true
+		}
+	}
+}
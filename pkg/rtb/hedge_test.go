@@ -0,0 +1,110 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// alternatingDSP answers every odd request slowly and every even one fast
+func alternatingDSP(t *testing.T, slow time.Duration) *httptest.Server {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := "fast"
+		if calls.Add(1)%2 == 1 {
+			id = "slow"
+			select {
+			case <-time.After(slow):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{
+			SeatBid: []openrtb2.SeatBid{{Bid: []openrtb2.Bid{{ID: id, ImpID: "1", Price: 2}}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// p99 of collectBids latency over n auctions, and the bid IDs won
+func auctionLatency(exchange *RTBExchange, n int) (time.Duration, []string) {
+	req := &openrtb2.BidRequest{ID: "auc", Imp: []openrtb2.Imp{{ID: "1"}}}
+	var latencies []time.Duration
+	var ids []string
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		bids := exchange.collectBids(context.Background(), req, start.Add(exchange.auctionBudget(req)))
+		latencies = append(latencies, time.Since(start))
+		for _, b := range bids {
+			ids = append(ids, b.ID)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[(len(latencies)*99)/100], ids
+}
+
+func TestCollectBids_Hedge(t *testing.T) {
+	const slow = 250 * time.Millisecond
+	newExchange := func(hedge *HedgePolicy) (*RTBExchange, *DSPConnection) {
+		dsp := &DSPConnection{
+			ID:          "fat-tail",
+			Endpoint:    alternatingDSP(t, slow).URL,
+			Hedge:       hedge,
+			RateLimiter: NewRateLimiter(1000),
+		}
+		return &RTBExchange{
+			DSPs:           map[string]*DSPConnection{dsp.ID: dsp},
+			AuctionTimeout: 500 * time.Millisecond,
+		}, dsp
+	}
+
+	plain, _ := newExchange(nil)
+	plainP99, _ := auctionLatency(plain, 6)
+
+	hedged, dsp := newExchange(&HedgePolicy{After: 0.2, MaxRatio: 1})
+	hedgedP99, ids := auctionLatency(hedged, 6)
+
+	for _, id := range ids {
+		if id != "fast" {
+			t.Errorf("won by %q, want the fast hedge", id)
+		}
+	}
+	if len(ids) != 6 {
+		t.Errorf("got %d bids, want 6", len(ids))
+	}
+	if dsp.HedgeCount != 6 || dsp.HedgeWinRate() != 1 {
+		t.Errorf("hedges = %d, win rate = %v", dsp.HedgeCount, dsp.HedgeWinRate())
+	}
+	if plainP99 < slow || hedgedP99 >= slow {
+		t.Errorf("p99 = %v unhedged, %v hedged", plainP99, hedgedP99)
+	}
+}
+
+func TestAllowHedge_Cap(t *testing.T) {
+	d := &DSPConnection{}
+	policy := &HedgePolicy{After: 0.5, MaxRatio: 0.25}
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		atomic.AddUint64(&d.hedgeCalls, 1)
+		if d.allowHedge(policy) {
+			allowed++
+		}
+	}
+	if allowed != 5 || d.HedgeCount != 5 {
+		t.Errorf("allowed %d hedges of 20 calls, want 5", allowed)
+	}
+
+	// The default cap applies without MaxRatio
+	d = &DSPConnection{hedgeCalls: 5}
+	if d.allowHedge(&HedgePolicy{After: 0.5}) {
+		t.Error("hedged under the default cap")
+	}
+}
@@ -0,0 +1,248 @@
+package rtb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// IDType names a kind of user identifier
+type IDType string
+
+const (
+	IDTypeIDFA        IDType = "idfa"     // Apple advertising ID
+	IDTypeGAID        IDType = "gaid"     // Google advertising ID
+	IDTypeIFV         IDType = "ifv"      // Apple ID for vendors
+	IDTypeIFA         IDType = "ifa"      // Advertising ID of another platform
+	IDTypeUID         IDType = "uid"      // Exchange user ID
+	IDTypeBuyerUID    IDType = "buyeruid" // DSP user ID
+	IDTypeUID2        IDType = "uid2"     // Unified ID 2.0 token
+	IDTypeID5         IDType = "id5"      // ID5 universal ID
+	IDTypeHashedEmail IDType = "sha256"   // SHA-256 of a normalized email
+)
+
+// EID sources mapped to identifier types
+const (
+	SourceUID2        = "uidapi.com"
+	SourceID5         = "id5-sync.com"
+	SourceHashedEmail = "sha256-email"
+)
+
+// UserID is one identifier seen on a request
+type UserID struct {
+	Type  IDType
+	Value string
+}
+
+// IdentityResolver maps a request's identifiers to a stable internal user
+// key, so one user seen through different IDs and devices is one user
+type IdentityResolver interface {
+	Resolve(ids []UserID) (key string, ok bool)
+}
+
+// idStrength orders identifiers by how reliably they name a person, so a
+// new user's key derives from the strongest ID seen
+var idStrength = map[IDType]int{
+	IDTypeUID2:        8,
+	IDTypeID5:         7,
+	IDTypeHashedEmail: 6,
+	IDTypeIDFA:        5,
+	IDTypeGAID:        5,
+	IDTypeIFA:         4,
+	IDTypeIFV:         3,
+	IDTypeUID:         2,
+	IDTypeBuyerUID:    1,
+}
+
+// IdentityGraph is an in-memory IdentityResolver. IDs seen together on a
+// request are linked, and a match table of hashed emails or unified-ID
+// tokens can be loaded with Link.
+type IdentityGraph struct {
+	mu   sync.RWMutex
+	keys map[UserID]string
+}
+
+// NewIdentityGraph creates an empty identity graph
+func NewIdentityGraph() *IdentityGraph {
+	return &IdentityGraph{keys: make(map[UserID]string)}
+}
+
+// Link maps ids to key
+func (g *IdentityGraph) Link(key string, ids ...UserID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, id := range ids {
+		if id.Value != "" {
+			g.keys[normalizeUserID(id)] = key
+		}
+	}
+}
+
+// Resolve returns the key of the strongest known ID, linking the other IDs
+// to it. A user with no known ID gets a key derived from their strongest ID.
+func (g *IdentityGraph) Resolve(ids []UserID) (string, bool) {
+	var best UserID
+	var key string
+	bestKnown := -1
+	for _, id := range ids {
+		if id.Value == "" {
+			continue
+		}
+		id = normalizeUserID(id)
+		if best.Value == "" || idStrength[id.Type] > idStrength[best.Type] {
+			best = id
+		}
+		g.mu.RLock()
+		k, ok := g.keys[id]
+		g.mu.RUnlock()
+		if ok && idStrength[id.Type] > bestKnown {
+			key, bestKnown = k, idStrength[id.Type]
+		}
+	}
+	if best.Value == "" {
+		return "", false
+	}
+	if key == "" {
+		sum := sha256.Sum256([]byte(string(best.Type) + ":" + best.Value))
+		key = hex.EncodeToString(sum[:16])
+	}
+	g.Link(key, ids...)
+	return key, true
+}
+
+// Erase forgets id, an identifier of any type or a user key, along with
+// every ID linked to the same user, returning how many IDs were dropped
+func (g *IdentityGraph) Erase(id string) (int, error) {
+	if id == "" {
+		return 0, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	users := make(map[string]bool)
+	for uid, key := range g.keys {
+		if normalizeUserID(UserID{Type: uid.Type, Value: id}) == uid || key == id {
+			users[key] = true
+		}
+	}
+	n := 0
+	for uid, key := range g.keys {
+		if users[key] {
+			delete(g.keys, uid)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// HashEmail hashes an email for the match table the way SourceHashedEmail
+// IDs are expected to be hashed: trimmed, lowercased, SHA-256 in hex
+func HashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeUserID(id UserID) UserID {
+	switch id.Type {
+	case IDTypeIDFA, IDTypeGAID, IDTypeIFV, IDTypeIFA, IDTypeHashedEmail:
+		id.Value = strings.ToLower(id.Value)
+	}
+	return id
+}
+
+// RequestUserIDs collects the user identifiers on req
+func RequestUserIDs(req *openrtb2.BidRequest) []UserID {
+	var ids []UserID
+	if d := req.Device; d != nil && d.IFA != "" && !zeroIFA(d.IFA) {
+		ids = append(ids, UserID{Type: ifaType(d), Value: d.IFA})
+	}
+	if u := req.User; u != nil {
+		if u.ID != "" {
+			ids = append(ids, UserID{Type: IDTypeUID, Value: u.ID})
+		}
+		if u.BuyerUID != "" {
+			ids = append(ids, UserID{Type: IDTypeBuyerUID, Value: u.BuyerUID})
+		}
+		for _, eid := range u.EIDs {
+			var typ IDType
+			switch strings.ToLower(eid.Source) {
+			case SourceUID2:
+				typ = IDTypeUID2
+			case SourceID5:
+				typ = IDTypeID5
+			case SourceHashedEmail:
+				typ = IDTypeHashedEmail
+			default:
+				continue
+			}
+			for _, uid := range eid.UIDs {
+				if uid.ID != "" {
+					ids = append(ids, UserID{Type: typ, Value: uid.ID})
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// ifaType reads device.ext.ifa_type, falling back on the OS
+func ifaType(d *openrtb2.Device) IDType {
+	var ext struct {
+		IFAType string `json:"ifa_type"`
+	}
+	if len(d.Ext) > 0 && json.Unmarshal(d.Ext, &ext) == nil {
+		switch t := strings.ToLower(ext.IFAType); t {
+		case "idfa", "ifv":
+			return IDType(t)
+		case "aaid", "gaid", "adid":
+			return IDTypeGAID
+		}
+	}
+	switch strings.ToLower(d.OS) {
+	case "ios", "ipados", "tvos":
+		return IDTypeIDFA
+	case "android":
+		return IDTypeGAID
+	}
+	return IDTypeIFA
+}
+
+// zeroIFA reports an IFA zeroed out by limit ad tracking
+func zeroIFA(ifa string) bool {
+	return strings.Trim(ifa, "0-") == ""
+}
+
+// identityPermitted reports whether the user may be matched across IDs:
+// not with do-not-track or limit ad tracking, not under GDPR without a
+// consent string, and not after a CCPA opt-out
+func identityPermitted(req *openrtb2.BidRequest) bool {
+	if d := req.Device; d != nil {
+		if (d.DNT != nil && *d.DNT == 1) || (d.Lmt != nil && *d.Lmt == 1) {
+			return false
+		}
+	}
+	if r := req.Regs; r != nil {
+		if r.GDPR != nil && *r.GDPR == 1 && (req.User == nil || req.User.Consent == "") {
+			return false
+		}
+		if len(r.USPrivacy) >= 3 && (r.USPrivacy[2] == 'Y' || r.USPrivacy[2] == 'y') {
+			return false
+		}
+	}
+	return true
+}
+
+// UserKey is the key frequency caps and audiences use for req's user: the
+// resolved identity when a resolver is set and the user permits matching,
+// and otherwise the raw user ID or IFA
+func (rtb *RTBExchange) UserKey(req *openrtb2.BidRequest) string {
+	if rtb.Identity != nil && identityPermitted(req) {
+		if key, ok := rtb.Identity.Resolve(RequestUserIDs(req)); ok {
+			return key
+		}
+	}
+	return frequencyUserID(req)
+}
@@ -0,0 +1,121 @@
+package rtb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+func eid(source, id string) openrtb2.EID {
+	return openrtb2.EID{Source: source, UIDs: []openrtb2.UID{{ID: id}}}
+}
+
+func TestIdentityGraph_CrossDevice(t *testing.T) {
+	graph := NewIdentityGraph()
+	graph.Link("user-42", UserID{Type: IDTypeHashedEmail, Value: HashEmail(" Jane@Example.com ")})
+	exchange := &RTBExchange{Identity: graph}
+
+	// Phone: IDFA plus the hashed email from a login
+	phone := &openrtb2.BidRequest{
+		Device: &openrtb2.Device{OS: "iOS", IFA: "AEBE52E7-03EE-455A-B3C4-E57283966239"},
+		User:   &openrtb2.User{EIDs: []openrtb2.EID{eid(SourceHashedEmail, HashEmail("jane@example.com"))}},
+	}
+	// Same phone later, IDFA only, with the IFA type in device.ext
+	phoneAgain := &openrtb2.BidRequest{
+		Device: &openrtb2.Device{IFA: "aebe52e7-03ee-455a-b3c4-e57283966239", Ext: json.RawMessage(`{"ifa_type":"idfa"}`)},
+	}
+	// TV: a UID2 token seen alongside the hashed email, then on its own
+	tv := &openrtb2.BidRequest{
+		Device: &openrtb2.Device{OS: "Android", IFA: "38400000-8cf0-11bd-b23e-10b96e40000d"},
+		User: &openrtb2.User{EIDs: []openrtb2.EID{
+			eid(SourceUID2, "AgAAAAVacu1uAxgAxH+HJ8+nWlS2H4uVqr6i+HBDCNREHD8WKsio/x7D8xXFuq1cJycUU86yXfTH9Xe/4C8KkH+7UCiU7uQxhyD7Qxnv251pEs6K8oK+BPLYR+8BLY/sJKesa/koKwx1FHgUzIBum582tSy2Oo+7C6wYUaaV4QcLr/4LPA=="),
+			eid(SourceHashedEmail, HashEmail("JANE@example.com")),
+		}},
+	}
+	tvAgain := &openrtb2.BidRequest{User: &openrtb2.User{EIDs: tv.User.EIDs[:1]}}
+
+	for i, req := range []*openrtb2.BidRequest{phone, phoneAgain, tv, tvAgain} {
+		if key := exchange.UserKey(req); key != "user-42" {
+			t.Errorf("request %d resolved to %q, want user-42", i, key)
+		}
+	}
+
+	// A stranger gets a stable key of their own
+	stranger := &openrtb2.BidRequest{User: &openrtb2.User{ID: "exchange-uid-9"}}
+	key := exchange.UserKey(stranger)
+	if key == "" || key == "user-42" || key != exchange.UserKey(stranger) {
+		t.Errorf("stranger resolved to %q", key)
+	}
+}
+
+func TestUserKey_ConsentDisablesResolution(t *testing.T) {
+	graph := NewIdentityGraph()
+	graph.Link("user-42", UserID{Type: IDTypeUID, Value: "exchange-uid-1"})
+	exchange := &RTBExchange{Identity: graph}
+	one := int8(1)
+
+	for name, req := range map[string]*openrtb2.BidRequest{
+		"dnt":  {Device: &openrtb2.Device{DNT: &one}, User: &openrtb2.User{ID: "exchange-uid-1"}},
+		"lmt":  {Device: &openrtb2.Device{Lmt: &one}, User: &openrtb2.User{ID: "exchange-uid-1"}},
+		"gdpr": {Regs: &openrtb2.Regs{GDPR: &one}, User: &openrtb2.User{ID: "exchange-uid-1"}},
+		"ccpa": {Regs: &openrtb2.Regs{USPrivacy: "1YYN"}, User: &openrtb2.User{ID: "exchange-uid-1"}},
+	} {
+		if key := exchange.UserKey(req); key != "exchange-uid-1" {
+			t.Errorf("%s: resolved to %q, want the raw ID", name, key)
+		}
+	}
+
+	consented := &openrtb2.BidRequest{Regs: &openrtb2.Regs{GDPR: &one}, User: &openrtb2.User{ID: "exchange-uid-1", Consent: "CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA"}}
+	if key := exchange.UserKey(consented); key != "user-42" {
+		t.Errorf("consented user resolved to %q, want user-42", key)
+	}
+}
+
+func TestRunAuction_FrequencyCapAcrossDevices(t *testing.T) {
+	counts := countingCap{}
+	exchange := &RTBExchange{
+		FloorPrice:   decimal.NewFromFloat(0.5),
+		Frequency:    counts,
+		FrequencyCap: 1,
+		Identity:     NewIdentityGraph(),
+	}
+	bids := []Bid{{ID: "b1", ImpID: "1", Price: 4, CampaignID: "camp-1"}}
+	imp := []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}
+	email := eid(SourceHashedEmail, HashEmail("jane@example.com"))
+
+	phone := &openrtb2.BidRequest{ID: "r1", Imp: imp, Device: &openrtb2.Device{OS: "iOS", IFA: "aebe52e7-03ee-455a-b3c4-e57283966239"}, User: &openrtb2.User{EIDs: []openrtb2.EID{email}}}
+	if exchange.runAuction(bids, phone) == nil {
+		t.Fatal("first impression capped")
+	}
+	laptop := &openrtb2.BidRequest{ID: "r2", Imp: imp, User: &openrtb2.User{ID: "cookie-7", EIDs: []openrtb2.EID{email}}}
+	if winner := exchange.runAuction(bids, laptop); winner != nil {
+		t.Errorf("same user on another device won %s past the cap", winner.ID)
+	}
+}
+
+func TestIdentityGraph_Erase(t *testing.T) {
+	graph := NewIdentityGraph()
+	exchange := &RTBExchange{Identity: graph}
+	phone := &openrtb2.BidRequest{
+		Device: &openrtb2.Device{IFA: "AEBE52E7-03EE-455A-B3C4-E57283966239"},
+		User:   &openrtb2.User{ID: "exchange-uid-1"},
+	}
+	other := &openrtb2.BidRequest{User: &openrtb2.User{ID: "exchange-uid-2"}}
+	key, otherKey := exchange.UserKey(phone), exchange.UserKey(other)
+
+	// Erasing one ID forgets the user's others too
+	if n, err := graph.Erase("AEBE52E7-03EE-455A-B3C4-E57283966239"); err != nil || n != 2 {
+		t.Fatalf("Erase = %d, %v; want 2", n, err)
+	}
+	if _, ok := graph.keys[UserID{Type: IDTypeUID, Value: "exchange-uid-1"}]; ok {
+		t.Error("linked ID kept after erasure")
+	}
+	if exchange.UserKey(other) != otherKey {
+		t.Error("another user's key changed")
+	}
+	if n, _ := graph.Erase(key); n != 0 {
+		t.Errorf("second Erase = %d", n)
+	}
+}
@@ -0,0 +1,32 @@
+package rtb
+
+import (
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// LossRecorder collects why bids lost, by DSP and placement, such as
+// analytics.AnalyticsTracker. Reasons are the audit's Reason constants.
+type LossRecorder interface {
+	RecordLoss(dspID, placementID, reason string, at time.Time)
+}
+
+// recordLosses tells Losses why each bid but the winner lost
+func (rtb *RTBExchange) recordLosses(req *openrtb2.BidRequest, bids []Bid, winner *Bid, rejected map[*Bid]error) {
+	now := time.Now()
+	for i := range bids {
+		bid := &bids[i]
+		if bid == winner {
+			continue
+		}
+		_, reason, _ := rtb.auditOutcome(req, bid, winner, rejected)
+		rtb.Losses.RecordLoss(bid.DSP, tagID(req, bid.ImpID), reason, now)
+	}
+}
+
+// inExchangeCurrency reports whether bid is priced in the currency the
+// exchange clears in; bids in any other are never converted
+func inExchangeCurrency(bid *Bid) bool {
+	return bid.Currency == "" || bid.Currency == exchangeCurrency
+}
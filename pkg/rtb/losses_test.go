@@ -0,0 +1,93 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/analytics"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+// bidderDSP answers every bid request with bid, priced in cur
+func bidderDSP(t *testing.T, name, cur string, bid openrtb2.Bid) *DSPConnection {
+	bid.ID, bid.ImpID = "bid-"+name, "1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{Cur: cur, SeatBid: []openrtb2.SeatBid{{Seat: "seat-" + name, Bid: []openrtb2.Bid{bid}}}})
+	}))
+	t.Cleanup(srv.Close)
+	return &DSPConnection{ID: name, Endpoint: srv.URL, RateLimiter: NewRateLimiter(100)}
+}
+
+func TestLossReasons_Breakdown(t *testing.T) {
+	tracker := analytics.NewAnalyticsTracker()
+	exchange := &RTBExchange{
+		DSPs: map[string]*DSPConnection{
+			"win":      bidderDSP(t, "win", "", openrtb2.Bid{Price: 5}),
+			"outbid":   bidderDSP(t, "outbid", "USD", openrtb2.Bid{Price: 3}),
+			"floor":    bidderDSP(t, "floor", "", openrtb2.Bid{Price: 0.1}),
+			"euro":     bidderDSP(t, "euro", "EUR", openrtb2.Bid{Price: 4}),
+			"category": bidderDSP(t, "category", "", openrtb2.Bid{Price: 6, Cat: []string{"IAB25"}}),
+			"blocked":  bidderDSP(t, "blocked", "", openrtb2.Bid{Price: 7, ADomain: []string{"blocked.example"}}),
+			"deal":     bidderDSP(t, "deal", "", openrtb2.Bid{Price: 8, DealID: "unknown-deal"}),
+		},
+		AuctionTimeout: time.Second,
+		FloorPrice:     decimal.NewFromFloat(0.5),
+		Losses:         tracker,
+		Revenue:        big.NewInt(0),
+	}
+	for i := 0; i < 2; i++ {
+		req := &openrtb2.BidRequest{
+			ID:   fmt.Sprintf("auc-%d", i),
+			Imp:  []openrtb2.Imp{{ID: "1", TagID: "slot-1", Banner: &openrtb2.Banner{}}},
+			BCat: []string{"IAB25"},
+			BAdv: []string{"blocked.example"},
+		}
+		resp, err := exchange.BidRequest(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.SeatBid) != 1 || resp.SeatBid[0].Bid[0].ID != "bid-win" {
+			t.Fatalf("auction %d won by %+v, want bid-win", i, resp.SeatBid)
+		}
+	}
+
+	tr := analytics.TimeRange{Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}
+	all, err := tracker.GetLossReasonBreakdown("", tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[analytics.LossReason]uint64{
+		analytics.LossOutbid:            2,
+		analytics.LossBelowFloor:        2,
+		analytics.LossCurrency:          2,
+		analytics.LossCategoryBlocked:   2,
+		analytics.LossAdvertiserBlocked: 2,
+		analytics.LossDealMismatch:      2,
+	}
+	if all.Total != 12 || len(all.Reasons) != len(want) {
+		t.Errorf("breakdown = %d losses %v, want 12 %v", all.Total, all.Reasons, want)
+	}
+	for reason, n := range want {
+		if all.Reasons[reason] != n || all.Placements["slot-1"][reason] != n {
+			t.Errorf("%s: %d, %d on slot-1; want %d", reason, all.Reasons[reason], all.Placements["slot-1"][reason], n)
+		}
+	}
+
+	euro, err := tracker.GetLossReasonBreakdown("euro", tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if euro.Total != 2 || euro.Reasons[analytics.LossCurrency] != 2 {
+		t.Errorf("euro breakdown = %+v, want 2 currency losses", euro)
+	}
+	if none, _ := tracker.GetLossReasonBreakdown("win", tr); none.Total != 0 {
+		t.Errorf("winner lost %d bids", none.Total)
+	}
+}
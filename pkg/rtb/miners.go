@@ -0,0 +1,143 @@
+package rtb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/luxfi/adx/pkg/miner"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+// PayoutDecimals are the decimal places of the coin miners are paid in
+const PayoutDecimals = 18
+
+// serving is a miner's credit for an impression it was told to serve
+type serving struct {
+	minerID string
+	amount  *big.Int
+	expires time.Time
+}
+
+// Register adds a miner, replacing any with the same ID
+func (r *MinerRegistry) Register(m *HomeMiner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Miners == nil {
+		r.Miners = make(map[string]*HomeMiner)
+	}
+	r.Miners[m.ID] = m
+}
+
+// Unregister removes a miner
+func (r *MinerRegistry) Unregister(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.Miners, id)
+}
+
+// Match picks the miner to serve req's impression: the healthiest active
+// miner in the device's region, else in its country. Miners with no
+// location serve anywhere, but only when no local miner is active. It
+// returns nil when no miner matches.
+func (r *MinerRegistry) Match(req *openrtb2.BidRequest) *HomeMiner {
+	var country, region string
+	if req.Device != nil && req.Device.Geo != nil {
+		country, region = req.Device.Geo.Country, req.Device.Geo.Region
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var best *HomeMiner
+	bestRank := -1
+	for _, m := range r.Miners {
+		if !m.Active {
+			continue
+		}
+		rank := 0
+		switch {
+		case m.Country == "":
+		case country == "" || !strings.EqualFold(m.Country, country):
+			continue
+		case region != "" && strings.EqualFold(m.Region, region):
+			rank = 2
+		default:
+			rank = 1
+		}
+		if best == nil || rank > bestRank ||
+			rank == bestRank && (m.HealthScore > best.HealthScore || m.HealthScore == best.HealthScore && m.ID < best.ID) {
+			best, bestRank = m, rank
+		}
+	}
+	return best
+}
+
+// announce pushes the auction's result to the miner matched to serve it,
+// so it can have the creative ready. Only that miner is told.
+func (r *MinerRegistry) announce(req *openrtb2.BidRequest, winner *Bid) error {
+	if r.Notify == nil {
+		return nil
+	}
+	m := r.Match(req)
+	if m == nil {
+		return nil
+	}
+	window := r.ServeWindow
+	if window <= 0 {
+		window = miner.DefaultServeWindow
+	}
+	creativeID := winner.AdID
+	if creativeID == "" {
+		creativeID = winner.ID
+	}
+	msg, err := json.Marshal(miner.AuctionResultMessage{
+		Type: miner.MessageAuctionResult,
+		AuctionResult: miner.AuctionResult{
+			AuctionID:  req.ID,
+			ImpID:      winner.ImpID,
+			CreativeID: creativeID,
+			Markup:     winner.Creative,
+			W:          winner.W,
+			H:          winner.H,
+			ServeBy:    time.Now().Add(window),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.Notify(m.ID, msg); err != nil {
+		return fmt.Errorf("notify %s of auction %s: %w", m.ID, req.ID, err)
+	}
+
+	if r.Payouts != nil && r.Share > 0 {
+		// Prices are CPM
+		amount := decimal.NewFromFloat(winner.Price * r.Share / 1000).Shift(PayoutDecimals).BigInt()
+		now := time.Now()
+		r.mu.Lock()
+		if r.serving == nil {
+			r.serving = make(map[string]serving)
+		}
+		for id, s := range r.serving {
+			if now.After(s.expires) {
+				delete(r.serving, id)
+			}
+		}
+		r.serving[req.ID] = serving{minerID: m.ID, amount: amount, expires: now.Add(window)}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// served credits the miner told to serve an auction's impression, once
+func (r *MinerRegistry) served(auctionID string) {
+	r.mu.Lock()
+	s, ok := r.serving[auctionID]
+	delete(r.serving, auctionID)
+	r.mu.Unlock()
+	if ok && r.Payouts != nil && time.Now().Before(s.expires) {
+		r.Payouts.Credit(s.minerID, s.amount)
+	}
+}
@@ -0,0 +1,299 @@
+package rtb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultNoticeConcurrency bounds the notices in flight at once
+	DefaultNoticeConcurrency = 32
+
+	// DefaultNoticeRetries is how many times a failed notice is retried
+	DefaultNoticeRetries = 2
+
+	// DefaultBillingTTL is how long a winner's billing notice waits for
+	// its impression
+	DefaultBillingTTL = time.Hour
+
+	// noticeQueueSize bounds the notices waiting for a worker; notices
+	// past it are dropped
+	noticeQueueSize = 4096
+)
+
+// Loss is a non-winning bid and why it lost
+type Loss struct {
+	Bid    *Bid
+	Reason openrtb3.LossReason
+}
+
+type pendingBilling struct {
+	url     string
+	expires time.Time
+}
+
+// Notifier fires OpenRTB win, billing and loss notices. Notices are best
+// effort: a pool of workers sends them in the background, retrying
+// failures, and drops them if the queue is full.
+type Notifier struct {
+	// Client sends notices; http.DefaultClient when nil
+	Client *http.Client
+
+	Retries      int
+	RetryBackoff time.Duration // Doubled after each attempt
+	BillingTTL   time.Duration
+
+	queue chan string
+	wg    sync.WaitGroup
+	once  sync.Once
+
+	mu        sync.Mutex
+	billing   map[string]pendingBilling
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewNotifier starts a notifier sending at most concurrency notices at once
+func NewNotifier(concurrency int) *Notifier {
+	if concurrency <= 0 {
+		concurrency = DefaultNoticeConcurrency
+	}
+	n := &Notifier{
+		Retries:      DefaultNoticeRetries,
+		RetryBackoff: 100 * time.Millisecond,
+		BillingTTL:   DefaultBillingTTL,
+		queue:        make(chan string, noticeQueueSize),
+		billing:      make(map[string]pendingBilling),
+		now:          time.Now,
+	}
+	n.wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go n.worker()
+	}
+	return n
+}
+
+// AuctionClosed fires the winner's NURL and each loser's LURL, and holds
+// the winner's BURL until Billed confirms the impression
+func (n *Notifier) AuctionClosed(req *openrtb2.BidRequest, winner *Bid, losses []Loss) {
+	if winner != nil {
+		n.send(expandNoticeMacros(winner.NURL, req.ID, winner, winner.Price, openrtb3.LossWon))
+		if winner.BURL != "" {
+			now := n.now()
+			n.mu.Lock()
+			n.billing[req.ID] = pendingBilling{
+				url:     expandNoticeMacros(winner.BURL, req.ID, winner, winner.Price, openrtb3.LossWon),
+				expires: now.Add(n.BillingTTL),
+			}
+			n.sweep(now)
+			n.mu.Unlock()
+		}
+	}
+	clearing := 0.0
+	if winner != nil {
+		clearing = winner.Price
+	}
+	for _, l := range losses {
+		n.send(expandNoticeMacros(l.Bid.LURL, req.ID, l.Bid, clearing, l.Reason))
+	}
+}
+
+// Billed fires the billing notice for an auction whose impression was
+// confirmed, reporting whether one was pending
+func (n *Notifier) Billed(auctionID string) bool {
+	n.mu.Lock()
+	b, ok := n.billing[auctionID]
+	delete(n.billing, auctionID)
+	n.mu.Unlock()
+	if !ok || n.now().After(b.expires) {
+		return false
+	}
+	n.send(b.url)
+	return true
+}
+
+// Close stops accepting notices and waits for queued ones to be sent
+func (n *Notifier) Close() {
+	n.once.Do(func() { close(n.queue) })
+	n.wg.Wait()
+}
+
+// sweep drops billing notices whose impression never came; n.mu must be
+// held
+func (n *Notifier) sweep(now time.Time) {
+	if now.Sub(n.lastSweep) < time.Minute {
+		return
+	}
+	n.lastSweep = now
+	for id, b := range n.billing {
+		if now.After(b.expires) {
+			delete(n.billing, id)
+		}
+	}
+}
+
+func (n *Notifier) send(u string) {
+	if u == "" {
+		return
+	}
+	select {
+	case n.queue <- u:
+	default:
+		slog.Warn("notice queue full, dropping notice", "url", u)
+	}
+}
+
+func (n *Notifier) worker() {
+	defer n.wg.Done()
+	for u := range n.queue {
+		n.fire(u)
+	}
+}
+
+// fire sends one notice, retrying transport errors and server errors
+func (n *Notifier) fire(u string) {
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	backoff := n.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= n.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = fireOnce(client, u); err == nil {
+			return
+		}
+	}
+	slog.Warn("notice failed", "url", u, "error", err)
+}
+
+func fireOnce(client *http.Client, u string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%w: %d", ErrDSPStatus, resp.StatusCode)
+	}
+	return nil
+}
+
+// expandNoticeMacros substitutes the OpenRTB auction macros in a notice
+// URL. Losers see the clearing price as both the price and the minimum
+// to win.
+func expandNoticeMacros(u, auctionID string, bid *Bid, price float64, reason openrtb3.LossReason) string {
+	if u == "" {
+		return ""
+	}
+	p := strconv.FormatFloat(price, 'f', -1, 64)
+	return strings.NewReplacer(
+		"${AUCTION_ID}", url.QueryEscape(auctionID),
+		"${AUCTION_BID_ID}", url.QueryEscape(bid.ID),
+		"${AUCTION_IMP_ID}", url.QueryEscape(bid.ImpID),
+		"${AUCTION_SEAT_ID}", url.QueryEscape(bid.SeatID),
+		"${AUCTION_AD_ID}", url.QueryEscape(bid.AdID),
+		"${AUCTION_PRICE}", p,
+		"${AUCTION_CURRENCY}", "USD",
+		"${AUCTION_MBR}", "",
+		"${AUCTION_LOSS}", strconv.FormatInt(int64(reason), 10),
+		"${AUCTION_MIN_TO_WIN}", p,
+	).Replace(u)
+}
+
+// RecordServe charges for a win served without an auction of its own, as
+// a cached result served again: its price counts toward revenue and its
+// win notice fires under auctionID, the serve's
+func (rtb *RTBExchange) RecordServe(auctionID string, winner *Bid) {
+	if rtb.Notifier != nil {
+		rtb.Notifier.send(expandNoticeMacros(winner.NURL, auctionID, winner, winner.Price, openrtb3.LossWon))
+	}
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	rtb.ImpressionCount++
+	rtb.BidCount++
+	rtb.Revenue.Add(rtb.Revenue, decimal.NewFromFloat(winner.Price).BigInt())
+}
+
+// ConfirmImpression fires the billing notice of the auction's winner once
+// its impression has rendered, crediting the miner that served it
+func (rtb *RTBExchange) ConfirmImpression(auctionID string) bool {
+	if rtb.Notifier == nil || !rtb.Notifier.Billed(auctionID) {
+		return false
+	}
+	if rtb.MinerRegistry != nil {
+		rtb.MinerRegistry.served(auctionID)
+	}
+	return true
+}
+
+// losses gives each bid other than the winner its OpenRTB loss reason;
+// bids the auction dropped for panicking lost to an internal error
+func (rtb *RTBExchange) losses(req *openrtb2.BidRequest, bids []Bid, winner *Bid, rejected map[*Bid]error) []Loss {
+	var out []Loss
+	for i := range bids {
+		bid := &bids[i]
+		if bid == winner {
+			continue
+		}
+		if errors.Is(rejected[bid], ErrBidPanic) {
+			out = append(out, Loss{Bid: bid, Reason: openrtb3.LossInternalError})
+			continue
+		}
+		out = append(out, Loss{Bid: bid, Reason: rtb.lossReason(req, bid, winner)})
+	}
+	return out
+}
+
+func (rtb *RTBExchange) lossReason(req *openrtb2.BidRequest, bid, winner *Bid) openrtb3.LossReason {
+	switch {
+	case !inExchangeCurrency(bid):
+		return openrtb3.LossInvalidResponse
+	case bid.Price < rtb.floorFor(req, bid.ImpID):
+		return openrtb3.LossBelowAuctionFloor
+	case rtb.blockedCategory(req, bid):
+		return openrtb3.LossCategoryExclusions
+	case bid.Advertiser != "" && containsAny(req.BAdv, []string{bid.Advertiser}):
+		return openrtb3.LossAdvertiserExclusions
+	case !rtb.checkBrandSafety(bid, req):
+		return openrtb3.LossCreativeFiltered
+	case winner == nil || bid.Price > winner.Price:
+		// Outbid the winner, so was filtered after the fact
+		return openrtb3.LossCreativeFiltered
+	case winner.DealID != "" && bid.DealID == "":
+		return openrtb3.LossLostToDealBid
+	}
+	return openrtb3.LossLostToHigherBid
+}
+
+func containsAny(list, values []string) bool {
+	for _, v := range values {
+		for _, item := range list {
+			if item == v {
+				return true
+			}
+		}
+	}
+	return false
+}
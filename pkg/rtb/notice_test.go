@@ -0,0 +1,189 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/miner"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+// noticeRecorder collects the notice URLs it is sent
+type noticeRecorder struct {
+	*httptest.Server
+	mu   sync.Mutex
+	hits []string
+	fail int // Requests to answer 503 before succeeding
+}
+
+func newNoticeRecorder(t *testing.T) *noticeRecorder {
+	rec := &noticeRecorder{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		if rec.fail > 0 {
+			rec.fail--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rec.hits = append(rec.hits, r.URL.RequestURI())
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func (rec *noticeRecorder) got() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	out := append([]string(nil), rec.hits...)
+	sort.Strings(out)
+	return out
+}
+
+func noticeDSP(t *testing.T, notices, name string, price float64) *DSPConnection {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{SeatBid: []openrtb2.SeatBid{{
+			Seat: "seat-" + name,
+			Bid: []openrtb2.Bid{{
+				ID:    "bid-" + name,
+				ImpID: "1",
+				Price: price,
+				NURL:  notices + "/" + name + "/win?auction=${AUCTION_ID}&price=${AUCTION_PRICE}&bid=${AUCTION_BID_ID}",
+				BURL:  notices + "/" + name + "/bill?auction=${AUCTION_ID}&price=${AUCTION_PRICE}&cur=${AUCTION_CURRENCY}",
+				LURL:  notices + "/" + name + "/loss?auction=${AUCTION_ID}&reason=${AUCTION_LOSS}&min=${AUCTION_MIN_TO_WIN}",
+			}},
+		}}})
+	}))
+	t.Cleanup(srv.Close)
+	return &DSPConnection{ID: name, Endpoint: srv.URL, RateLimiter: NewRateLimiter(100)}
+}
+
+func TestNotifier_WinBillingAndLoss(t *testing.T) {
+	rec := newNoticeRecorder(t)
+	notifier := NewNotifier(4)
+	exchange := &RTBExchange{
+		DSPs: map[string]*DSPConnection{
+			"high":  noticeDSP(t, rec.URL, "high", 3.5),
+			"low":   noticeDSP(t, rec.URL, "low", 2.25),
+			"floor": noticeDSP(t, rec.URL, "floor", 0.1),
+		},
+		AuctionTimeout: time.Second,
+		FloorPrice:     decimal.NewFromFloat(0.5),
+		Notifier:       notifier,
+		Revenue:        big.NewInt(0),
+	}
+	req := &openrtb2.BidRequest{ID: "auc 1", Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}}
+	if _, err := exchange.BidRequest(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if exchange.ConfirmImpression("unknown") {
+		t.Error("billed an unknown auction")
+	}
+	if !exchange.ConfirmImpression("auc 1") {
+		t.Fatal("no billing notice pending for the winner")
+	}
+	if exchange.ConfirmImpression("auc 1") {
+		t.Error("billed twice")
+	}
+	notifier.Close()
+
+	want := []string{
+		"/floor/loss?auction=auc+1&reason=100&min=3.5",
+		"/high/bill?auction=auc+1&price=3.5&cur=USD",
+		"/high/win?auction=auc+1&price=3.5&bid=bid-high",
+		"/low/loss?auction=auc+1&reason=102&min=3.5",
+	}
+	got := rec.got()
+	if len(got) != len(want) {
+		t.Fatalf("notices = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("notice %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestConfirmImpression_CreditsServingMiner(t *testing.T) {
+	rec := newNoticeRecorder(t)
+	payouts := miner.NewPayoutScheduler(nil, nil, time.Hour)
+	exchange := &RTBExchange{
+		DSPs:           map[string]*DSPConnection{"high": noticeDSP(t, rec.URL, "high", 4)},
+		AuctionTimeout: time.Second,
+		FloorPrice:     decimal.NewFromFloat(0.5),
+		Notifier:       NewNotifier(4),
+		Revenue:        big.NewInt(0),
+		MinerRegistry: &MinerRegistry{
+			Notify:  func(string, []byte) error { return nil },
+			Payouts: payouts,
+			Share:   0.25,
+		},
+	}
+	defer exchange.Notifier.Close()
+	exchange.MinerRegistry.Register(&HomeMiner{ID: "m1", Active: true, HealthScore: 1})
+
+	for _, id := range []string{"auc 1", "auc 2"} {
+		req := &openrtb2.BidRequest{ID: id, Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}}
+		if _, err := exchange.BidRequest(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if payouts.Pending("m1").Sign() != 0 {
+		t.Fatal("credited before the impression was confirmed")
+	}
+
+	// A quarter of a 4 CPM impression, once per confirmed impression
+	exchange.ConfirmImpression("auc 1")
+	exchange.ConfirmImpression("auc 1")
+	want := decimal.RequireFromString("0.001").Shift(PayoutDecimals).BigInt()
+	if got := payouts.Pending("m1"); got.Cmp(want) != 0 {
+		t.Errorf("pending = %s, want %s", got, want)
+	}
+}
+
+func TestNotifier_Retries(t *testing.T) {
+	rec := newNoticeRecorder(t)
+	rec.fail = 2
+	notifier := NewNotifier(1)
+	notifier.RetryBackoff = time.Millisecond
+
+	winner := &Bid{ID: "b1", Price: 1.25, NURL: rec.URL + "/win?p=${AUCTION_PRICE}"}
+	notifier.AuctionClosed(&openrtb2.BidRequest{ID: "a1"}, winner, nil)
+	notifier.Close()
+
+	if got := rec.got(); len(got) != 1 || got[0] != "/win?p=1.25" {
+		t.Errorf("notices = %q after two failures", got)
+	}
+}
+
+func TestLossReason(t *testing.T) {
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(1)}
+	req := &openrtb2.BidRequest{BCat: []string{"IAB25"}, BAdv: []string{"blocked.com"}}
+	winner := &Bid{ID: "w", Price: 5, DealID: "deal-1"}
+
+	tests := []struct {
+		bid  Bid
+		want int64
+	}{
+		{Bid{Price: 0.5}, 100},
+		{Bid{Price: 2, Categories: []string{"IAB25"}}, 209},
+		{Bid{Price: 2, Advertiser: "blocked.com"}, 205},
+		{Bid{Price: 9}, 200},
+		{Bid{Price: 4}, 103},
+		{Bid{Price: 4, DealID: "deal-2"}, 102},
+	}
+	for _, tt := range tests {
+		if got := exchange.lossReason(req, &tt.bid, winner); int64(got) != tt.want {
+			t.Errorf("loss reason for %+v = %d, want %d", tt.bid, got, tt.want)
+		}
+	}
+}
@@ -1,17 +1,35 @@
 package rtb
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"math/big"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	// "github.com/apple/foundationdb/bindings/go/src/fdb" // TODO: Add FDB support
+	"github.com/luxfi/adx/pkg/auction/tiebreak"
+	"github.com/luxfi/adx/pkg/jsonx"
+	"github.com/luxfi/adx/pkg/miner"
+	"github.com/luxfi/adx/pkg/reqlog"
+	"github.com/luxfi/adx/pkg/taxonomy"
+	"github.com/luxfi/adx/pkg/tracing"
+	"github.com/prebid/openrtb/v20/adcom1"
 	"github.com/prebid/openrtb/v20/openrtb2"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrDSPStatus is returned when a DSP answers with neither a bid nor 204
+var ErrDSPStatus = errors.New("unexpected DSP response status")
+
 // RTBExchange handles OpenRTB 2.5/3.0 programmatic bidding
 type RTBExchange struct {
 	// FoundationDB for high-scale storage
@@ -24,12 +42,15 @@ type RTBExchange struct {
 
 	// Auction engine
 	AuctionTimeout time.Duration
+	TMaxMargin     time.Duration // Held back from a request's tmax for the response's trip back; DefaultTMaxMargin when 0
 	FloorPrice     decimal.Decimal
+	FloorRules     *FloorRules // Overrides FloorPrice per impression when set
 
 	// Metrics
 	ImpressionCount uint64
 	BidCount        uint64
 	WinCount        uint64
+	PanicCount      uint64 // Bids and DSP requests dropped after a panic; updated atomically
 	Revenue         *big.Int
 
 	// CTV specific
@@ -39,6 +60,83 @@ type RTBExchange struct {
 	// Home miner support
 	MinerRegistry *MinerRegistry
 
+	// Seller authorization and schain; every seller is accepted when nil
+	SupplyChain *SupplyChain
+
+	// QualityGate vets winning creatives; the CTVOptimizer's thresholds
+	// apply when nil
+	QualityGate *QualityGate
+
+	// CreativePolicy caps winning creatives' size, dimensions, MIME type
+	// and duration per placement; creatives aren't limited when nil
+	CreativePolicy *CreativePolicy
+
+	// Frequency caps each user at FrequencyCap impressions per campaign,
+	// for single slots and pods alike; no cap applies when nil
+	Frequency    FrequencyCounter
+	FrequencyCap int
+
+	// FrequencyStore shares counts between exchange nodes, pre-filtering
+	// users capped elsewhere before the enclave's authoritative count;
+	// counts run over FrequencyWindow, DefaultFrequencyWindow when zero
+	FrequencyStore  FrequencyStore
+	FrequencyWindow time.Duration
+
+	// Identity resolves users across ID types and devices for frequency
+	// caps and audiences; raw IDs are used when nil
+	Identity IdentityResolver
+
+	// Exclusions are publisher advertiser and category blocks per page,
+	// on top of the request's badv and bcat
+	Exclusions *PageExclusions
+
+	// Taxonomy relates blocked categories to bids' across IAB taxonomy
+	// versions and category levels; taxonomy.Default() when nil
+	Taxonomy *taxonomy.Taxonomy
+
+	// Notifier fires win, billing and loss notices; none are sent when nil
+	Notifier *Notifier
+
+	// SPO folds copies of an impression arriving over other supply paths
+	// into one auction; every request is auctioned when nil
+	SPO *SupplyPathOptimizer
+
+	// Feedback posts each DSP its auction results at the endpoint it
+	// configured; none are sent when nil
+	Feedback *FeedbackReporter
+
+	// Fanout caps the DSPs each auction is sent to, favouring those
+	// likeliest to bid and win; every active DSP is asked when nil
+	Fanout *FanoutThrottle
+
+	// SkipPricing adjusts video bids by whether the ad is skippable; bids
+	// are taken at their price when nil
+	SkipPricing *SkipPricing
+
+	// PlacementFloors raise the floors of interstitial and rewarded
+	// impressions; every type takes the same floors when nil
+	PlacementFloors *PlacementFloors
+
+	// Placements reports fill and revenue by placement type; not kept
+	// when nil
+	Placements *PlacementStats
+
+	// Fatigue discounts bids for creatives the user has seen recently;
+	// bids compete at their price when nil
+	Fatigue *FatigueModel
+
+	// Losses is told why each losing bid lost; nothing is recorded when
+	// nil
+	Losses LossRecorder
+
+	// Audit keeps a record of every auction's bids and their outcomes;
+	// auctions aren't audited when nil
+	Audit *AuditLog
+
+	// BidGuard enforces seat bid caps, the clearing price ceiling and seat
+	// budgets; bids aren't limited when nil
+	BidGuard *BidGuard
+
 	mu sync.RWMutex
 }
 
@@ -51,6 +149,10 @@ type DSPConnection struct {
 	Timeout    time.Duration
 	BidderCode string
 	SeatID     string
+	Paused     bool // Sent no bid requests while set
+
+	// Feedback is where the DSP is told how its bids fared
+	Feedback FeedbackConfig
 
 	// Performance tracking
 	RequestCount uint64
@@ -59,8 +161,27 @@ type DSPConnection struct {
 	ErrorCount   uint64
 	AvgLatency   time.Duration
 
+	// Hedging; HedgeCount and HedgeWins are updated atomically
+	Hedge      *HedgePolicy // Requests aren't hedged when nil
+	HedgeCount uint64
+	HedgeWins  uint64
+	hedgeCalls uint64
+
+	// Failover endpoints, tried in order when Endpoint can't be reached;
+	// FailoverCount counts requests they answered, updated atomically
+	Failover      []string
+	FailoverCount uint64
+	breakers      atomic.Pointer[endpointBreakers]
+
+	// Stream carries bid requests over a persistent WebSocket; they go
+	// over HTTP when it's nil, down or full
+	Stream *BidStream
+
 	// Rate limiting
 	RateLimiter *RateLimiter
+
+	// Client sends bid requests; http.DefaultClient when nil
+	Client *http.Client
 }
 
 // SSPConnection represents a Supply Side Platform
@@ -76,6 +197,9 @@ type SSPConnection struct {
 
 	// Revenue share
 	RevShare float64 // 0.0 - 1.0
+
+	// Paused turns away the publisher's bid requests
+	Paused bool
 }
 
 // CTVApp represents a Connected TV application
@@ -123,12 +247,47 @@ type AdPodAssembler struct {
 	CompetitiveSeparation bool
 	FrequencyCapping      bool
 	CreativeDeduping      bool
+
+	// Separation is the default competitive separation rule, overridden
+	// per publisher with SetPublisherSeparation; Brands resolves parent
+	// brands and advertisers for it
+	Separation PodSeparation
+	Brands     *BrandRegistry
+
+	// HouseAds fill pods short of their minimums for publishers opted in
+	// with SetPublisherBackfill
+	HouseAds *HouseAdInventory
+
+	// Sessions keeps creatives out of a viewing session's pods once the
+	// session has seen them; see AssembleSession. Creatives may repeat
+	// across a session's pods when nil.
+	Sessions *SessionDedup
+
+	mu                 sync.RWMutex
+	publishers         map[string]PodSeparation
+	backfillPublishers map[string]bool
+	positionVCR        []float64
 }
 
 // MinerRegistry tracks home miners
 type MinerRegistry struct {
 	Miners map[string]*HomeMiner
 	mu     sync.RWMutex
+
+	// Notify sends a message to a miner's exchange connection; auction
+	// results aren't pushed while it is nil
+	Notify func(minerID string, msg []byte) error
+
+	// ServeWindow is how long a miner may serve an auction's winner after
+	// it closes; miner.DefaultServeWindow when 0
+	ServeWindow time.Duration
+
+	// Payouts is credited Share of the clearing price of each impression
+	// a miner is told to serve, once the impression is confirmed; nothing
+	// is credited while it is nil
+	Payouts *miner.PayoutScheduler
+	Share   float64
+	serving map[string]serving // By auction ID
 }
 
 // HomeMiner represents a home-based ad serving node
@@ -162,23 +321,133 @@ type HomeMiner struct {
 
 // BidRequest processes an OpenRTB bid request
 func (rtb *RTBExchange) BidRequest(ctx context.Context, req *openrtb2.BidRequest) (*openrtb2.BidResponse, error) {
+	if reqlog.ID(ctx) == "" {
+		ctx = reqlog.WithID(ctx, reqlog.NewID())
+	}
+	ctx = reqlog.With(ctx, reqlog.KeyAuction, req.ID)
+	deadline := time.Now().Add(rtb.auctionBudget(req))
+	if req.Site != nil && req.Site.Publisher != nil {
+		ctx = reqlog.With(ctx, reqlog.KeyPublisher, req.Site.Publisher.ID)
+	} else if req.App != nil && req.App.Publisher != nil {
+		ctx = reqlog.With(ctx, reqlog.KeyPublisher, req.App.Publisher.ID)
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "rtb.BidRequest", trace.WithAttributes(
+		tracing.AttrRequestID.String(reqlog.ID(ctx)),
+		tracing.AttrAuctionID.String(req.ID),
+	))
+	defer span.End()
+	tracing.RecordAuction(reqlog.ID(ctx), span)
+
+	// A paused SSP's inventory goes unsold
+	if pub, _, _ := publisherOf(req); pub != "" {
+		if ssp, ok := rtb.pausedSSP(pub); ok {
+			reqlog.Logger(ctx).Info("inventory rejected", "ssp", ssp, "error", "ssp paused")
+			return &openrtb2.BidResponse{ID: req.ID}, nil
+		}
+	}
+
+	if rtb.SupplyChain != nil {
+		err := rtb.SupplyChain.Authorize(ctx, req)
+		if err == nil {
+			err = rtb.SupplyChain.ValidateSChain(ctx, req)
+		}
+		if err != nil {
+			reqlog.Logger(ctx).Info("inventory rejected", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		rtb.SupplyChain.AppendNode(req)
+	}
+
+	for i := range req.Imp {
+		tagPlacement(&req.Imp[i])
+	}
+
 	// Store impression in FoundationDB
 	if err := rtb.storeImpression(req); err != nil {
+		reqlog.Logger(ctx).Error("store impression failed", "error", err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	// Collect bids from DSPs
-	bids := rtb.collectBids(ctx, req)
+	// Collect bids from DSPs, once however many supply paths the
+	// impression arrives over
+	var bids []Bid
+	if rtb.SPO != nil {
+		var sells bool
+		if bids, sells = rtb.SPO.collect(ctx, req, deadline, rtb.collectBids); !sells {
+			reqlog.Logger(ctx).Debug("impression sold over another supply path")
+			return &openrtb2.BidResponse{ID: req.ID}, nil
+		}
+	} else {
+		bids = rtb.collectBids(ctx, req, deadline)
+	}
 
-	// Run auction
-	winner := rtb.runAuction(bids, req)
+	// Run auction, its store calls cut off at the auction deadline
+	auctionCtx, auctionSpan := tracing.Tracer().Start(ctx, "rtb.runAuction", trace.WithAttributes(tracing.AttrBids.Int(len(bids))))
+	auctionCtx, cancel := context.WithDeadline(auctionCtx, deadline)
+	winner, rejected := rtb.auction(auctionCtx, bids, req)
+	cancel()
+	if winner != nil {
+		auctionSpan.SetAttributes(tracing.AttrDSP.String(winner.DSP), tracing.AttrPrice.Float64(winner.Price))
+	}
+	auctionSpan.End()
+
+	// Audited before the floors learn from this auction
+	if rtb.Audit != nil {
+		rtb.Audit.Record(rtb.audit(req, bids, winner, rejected))
+	}
+	belowFloor := winner == nil && rtb.allBelowFloor(req, bids)
+
+	if winner != nil && rtb.Fanout != nil {
+		rtb.Fanout.RecordWin(req, winner.DSP)
+	}
+
+	// Feed dynamic floors
+	if winner != nil && rtb.FloorRules != nil {
+		rtb.FloorRules.recordPlacementPrice(tagID(req, winner.ImpID), winner.Price, time.Now())
+	}
+	if rtb.FloorRules != nil {
+		rtb.FloorRules.recordReserves(req, winner)
+	}
+	if rtb.Placements != nil {
+		rtb.Placements.record(req, winner)
+	}
 
 	// Build response
 	resp := rtb.buildResponse(winner, req)
+	if belowFloor {
+		resp.NBR = NoBidBelowFloor.Ptr()
+	}
+
+	if rtb.Losses != nil {
+		rtb.recordLosses(req, bids, winner, rejected)
+	}
+	if rtb.Notifier != nil || rtb.Feedback != nil {
+		losses := rtb.losses(req, bids, winner, rejected)
+		if rtb.Notifier != nil {
+			rtb.Notifier.AuctionClosed(req, winner, losses)
+		}
+		if rtb.Feedback != nil {
+			rtb.reportFeedback(req, winner, losses)
+		}
+	}
+	if winner != nil && rtb.MinerRegistry != nil {
+		if err := rtb.MinerRegistry.announce(req, winner); err != nil {
+			reqlog.Logger(ctx).Info("auction result not pushed to miner", "error", err)
+		}
+	}
 
 	// Update metrics
 	rtb.updateMetrics(req, resp)
 
+	if winner != nil {
+		reqlog.Logger(ctx).Debug("auction cleared", reqlog.KeyDSP, winner.DSP, "bids", len(bids), "price", winner.Price)
+	} else {
+		reqlog.Logger(ctx).Debug("auction no fill", "bids", len(bids))
+	}
+
 	return resp, nil
 }
 
@@ -209,13 +478,15 @@ func (rtb *RTBExchange) HandleCTVRequest(ctx context.Context, req *CTVBidRequest
 
 // CTVBidRequest for Connected TV
 type CTVBidRequest struct {
-	ID      string
-	Device  CTVDevice
-	Content CTVContent
-	AdPods  []AdPodRequest
-	User    openrtb2.User
-	Regs    openrtb2.Regs
-	Test    int8
+	ID          string
+	PublisherID string // Selects the publisher's pod separation rule
+	SessionID   string // Viewing session the ad break is in, for session creative dedup
+	Device      CTVDevice
+	Content     CTVContent
+	AdPods      []AdPodRequest
+	User        openrtb2.User
+	Regs        openrtb2.Regs
+	Test        int8
 }
 
 // CTVDevice info
@@ -262,7 +533,12 @@ type AdPodResponse struct {
 	ID            string
 	Ads           []AdResponse
 	TotalDuration int
-	TotalPrice    float64
+	TotalPrice    float64 // Paid ads only
+	HouseDuration int     // Seconds filled with house ads
+
+	// ExpectedRevenue is TotalPrice with each ad's price weighted by the
+	// completion rate at its position
+	ExpectedRevenue float64
 }
 
 // AdResponse for CTV
@@ -275,6 +551,7 @@ type AdResponse struct {
 	AdvertiserID string
 	BrandID      string
 	CategoryID   []string
+	House        bool // Backfilled from house ads rather than sold
 }
 
 // storeImpression in FoundationDB
@@ -300,33 +577,98 @@ func (rtb *RTBExchange) storeImpression(req *openrtb2.BidRequest) error {
 	return nil // Temporary in-memory storage
 }
 
-// collectBids from all DSPs
-func (rtb *RTBExchange) collectBids(ctx context.Context, req *openrtb2.BidRequest) []Bid {
+// DefaultTMaxMargin is held back from a request's tmax for the response's
+// trip back to the caller
+const DefaultTMaxMargin = 10 * time.Millisecond
+
+// auctionBudget is how long the auction for req may take: AuctionTimeout,
+// tightened to the request's tmax less the margin for the response to get
+// back to the caller
+func (rtb *RTBExchange) auctionBudget(req *openrtb2.BidRequest) time.Duration {
+	budget := rtb.AuctionTimeout
+	if req.TMax <= 0 {
+		return budget
+	}
+	margin := rtb.TMaxMargin
+	if margin == 0 {
+		margin = DefaultTMaxMargin
+	}
+	if tmax := time.Duration(req.TMax)*time.Millisecond - margin; tmax < budget {
+		budget = tmax
+	}
+	return budget
+}
+
+// collectBids fans req out to the DSPs and gathers the bids that arrive
+// before deadline; requests still in flight then are canceled
+func (rtb *RTBExchange) collectBids(ctx context.Context, req *openrtb2.BidRequest, deadline time.Time) []Bid {
+	ctx, span := tracing.Tracer().Start(ctx, "rtb.collectBids")
+	defer span.End()
+
+	budget := time.Until(deadline)
+	if budget <= 0 {
+		span.SetAttributes(tracing.AttrBids.Int(0), tracing.AttrOutcome.String("timeout"))
+		return nil
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	dsps := rtb.activeDSPs()
+	if rtb.Fanout != nil {
+		dsps = rtb.Fanout.Select(req, dsps)
+	}
 	var wg sync.WaitGroup
-	bidChan := make(chan Bid, len(rtb.DSPs))
+	bidChan := make(chan Bid, len(dsps))
 
-	for _, dsp := range rtb.DSPs {
+	for _, dsp := range dsps {
 		wg.Add(1)
 		go func(d *DSPConnection) {
 			defer wg.Done()
 
+			ctx, dspSpan := tracing.Tracer().Start(ctx, "rtb.dsp", trace.WithAttributes(tracing.AttrDSP.String(d.ID)))
+			start := time.Now()
+			outcome := tracing.OutcomeNoBid
+			defer func() {
+				dspSpan.SetAttributes(
+					tracing.AttrOutcome.String(outcome),
+					tracing.AttrLatencyMS.Float64(float64(time.Since(start).Microseconds())/1000),
+				)
+				dspSpan.End()
+			}()
+			defer rtb.recoverDSP(d)
+
 			// Rate limit check
 			if !d.RateLimiter.Allow() {
+				outcome = tracing.OutcomeRateLimited
+				reqlog.Logger(ctx).Debug("dsp rate limited", reqlog.KeyDSP, d.ID)
 				return
 			}
 
 			// Send bid request
-			bid, err := d.SendBidRequest(ctx, req)
+			bid, err := d.sendBid(ctx, req, budget)
+			if rtb.Fanout != nil {
+				rtb.Fanout.RecordResponse(req, d.ID, bid != nil, err)
+			}
 			if err != nil {
-				d.ErrorCount++
+				if errors.Is(err, ErrBidPanic) {
+					atomic.AddUint64(&rtb.PanicCount, 1)
+				}
+				atomic.AddUint64(&d.ErrorCount, 1)
+				outcome = tracing.OutcomeError
+				dspSpan.SetStatus(codes.Error, err.Error())
+				reqlog.Logger(ctx).Warn("dsp bid request failed", reqlog.KeyDSP, d.ID, "error", err)
 				return
 			}
 
 			if bid != nil {
+				outcome = tracing.OutcomeBid
+				if rtb.SkipPricing != nil {
+					rtb.SkipPricing.adjust(req, bid)
+				}
 				bidChan <- *bid
-				d.BidCount++
+				atomic.AddUint64(&d.BidCount, 1)
 			}
-			d.RequestCount++
+			atomic.AddUint64(&d.RequestCount, 1)
 		}(dsp)
 	}
 
@@ -338,17 +680,25 @@ func (rtb *RTBExchange) collectBids(ctx context.Context, req *openrtb2.BidReques
 	}()
 
 	var bids []Bid
-	timeout := time.After(rtb.AuctionTimeout)
+	timeout := time.After(budget)
 
 	for {
 		select {
 		case bid := <-bidChan:
 			bids = append(bids, bid)
 		case <-doneChan:
+			// Every DSP has answered; select may pick this case before
+			// the bids still buffered
+			for len(bidChan) > 0 {
+				bids = append(bids, <-bidChan)
+			}
+			span.SetAttributes(tracing.AttrBids.Int(len(bids)))
 			return bids
 		case <-timeout:
+			span.SetAttributes(tracing.AttrBids.Int(len(bids)), tracing.AttrOutcome.String("timeout"))
 			return bids
 		case <-ctx.Done():
+			span.SetAttributes(tracing.AttrBids.Int(len(bids)))
 			return bids
 		}
 	}
@@ -360,40 +710,137 @@ type Bid struct {
 	ImpID      string
 	Price      float64
 	AdID       string
+	CreativeID string
 	Creative   string
 	DSP        string
 	SeatID     string
 	DealID     string
+	CampaignID string
+	NURL       string // Win notice
+	BURL       string // Billing notice
+	LURL       string // Loss notice
 	Categories []string
+	CatTax     adcom1.CategoryTaxonomy // Taxonomy of Categories; 1.0 when zero
+	Currency   string                  // Of Price; USD when empty
+	Endpoint   string                  // The DSP endpoint that answered
 	Advertiser string
 	Brand      string
+	Attr       []adcom1.CreativeAttribute
+	W, H       int64
 }
 
-// runAuction to determine winner
+// runAuction to determine winner. Equal prices are resolved in tiebreak
+// order on the DSP, then on the bid ID, so the result depends only on the
+// bids and not on the order or time their responses arrived in. A winner
+// whose creative fails the quality gate, or whose campaign the user has
+// hit the frequency cap on, is dropped for the next-best bid, as is any
+// bid whose processing panics. A winner over its placement's creative
+// limits is dropped too, or if the limits demote, only serves once no
+// compliant bid is left. Bids the BidGuard turns away, and bids for deals
+// the impression doesn't offer, never compete.
 func (rtb *RTBExchange) runAuction(bids []Bid, req *openrtb2.BidRequest) *Bid {
-	if len(bids) == 0 {
-		return nil
+	winner, _ := rtb.auction(context.Background(), bids, req)
+	return winner
+}
+
+// auction picks the winning bid, also returning why bids were turned
+// away: each screened or failed candidate maps to its error, and the
+// winner to nil. ctx bounds the frequency store's round trips.
+func (rtb *RTBExchange) auction(ctx context.Context, bids []Bid, req *openrtb2.BidRequest) (*Bid, map[*Bid]error) {
+	rejected := make(map[*Bid]error)
+	if rtb.BidGuard != nil {
+		rtb.BidGuard.Screen(bids, rejected)
 	}
+	var screen creativeScreen
+	for {
+		winner := rtb.bestBid(bids, req, rejected)
+		if winner == nil {
+			if screen.readmit(rejected) {
+				continue
+			}
+			return nil, rejected
+		}
+		rejected[winner] = nil
+		if err := rtb.guard(winner, func() error { return rtb.checkQuality(req, winner) }); err != nil {
+			rejected[winner] = err
+			continue
+		}
+		if !rtb.screenCreative(req, winner, rejected, &screen) {
+			continue
+		}
+		// Counted only once the bid has otherwise won, so losing bids
+		// don't use up the user's cap
+		var allowed bool
+		err := rtb.guard(winner, func() (err error) {
+			allowed, err = rtb.checkFrequency(ctx, req, winner)
+			return err
+		})
+		if errors.Is(err, ErrBidPanic) {
+			rejected[winner] = err
+			continue
+		}
+		if err != nil {
+			slog.Warn("frequency check failed", reqlog.KeyDSP, winner.DSP, "error", err)
+			rejected[winner] = err
+			continue
+		}
+		if allowed {
+			if rtb.BidGuard != nil {
+				rtb.BidGuard.Record(winner)
+			}
+			if rtb.Fatigue != nil {
+				rtb.Fatigue.Record(rtb.UserKey(req), creativeKey(winner))
+			}
+			return winner, rejected
+		}
+		rejected[winner] = ErrFrequencyCapped
+	}
+}
 
-	// First-price auction for CTV (industry standard)
+// checkQuality runs the winner's creative through the quality gate
+func (rtb *RTBExchange) checkQuality(req *openrtb2.BidRequest, bid *Bid) error {
+	switch {
+	case rtb.QualityGate != nil:
+		return rtb.QualityGate.Check(req, bid)
+	case rtb.CTVOptimizer != nil:
+		return rtb.CTVOptimizer.QualityRules().Check(bid)
+	}
+	return nil
+}
+
+// bestBid is the highest eligible bid not yet rejected; bids that panic
+// on the way are rejected with ErrBidPanic
+func (rtb *RTBExchange) bestBid(bids []Bid, req *openrtb2.BidRequest, rejected map[*Bid]error) *Bid {
+	// First-price auction for CTV (industry standard), bids competing at
+	// their price discounted for the user's fatigue with the creative
 	var winner *Bid
 	highestPrice := 0.0
+	var user string
+	if rtb.Fatigue != nil {
+		user = rtb.UserKey(req)
+	}
 
 	for i := range bids {
 		bid := &bids[i]
-
-		// Check floor price
-		if bid.Price < rtb.FloorPrice.InexactFloat64() {
+		if _, ok := rejected[bid]; ok {
 			continue
 		}
 
-		// Check brand safety and competitive separation
-		if !rtb.checkBrandSafety(bid, req) {
+		price, ok := 0.0, false
+		if err := rtb.guard(bid, func() error {
+			price, ok = rtb.competingPrice(req, user, bid)
+			return nil
+		}); err != nil {
+			rejected[bid] = err
+			continue
+		}
+		if !ok {
 			continue
 		}
 
-		if bid.Price > highestPrice {
-			highestPrice = bid.Price
+		if price > highestPrice ||
+			(winner != nil && price == highestPrice && outranksOnTie(bid, winner)) {
+			highestPrice = price
 			winner = bid
 		}
 	}
@@ -401,17 +848,42 @@ func (rtb *RTBExchange) runAuction(bids []Bid, req *openrtb2.BidRequest) *Bid {
 	return winner
 }
 
+// competingPrice is what bid competes at for user, and whether it's
+// eligible to compete at all
+func (rtb *RTBExchange) competingPrice(req *openrtb2.BidRequest, user string, bid *Bid) (float64, bool) {
+	// Prices are only compared in the exchange's currency
+	if !inExchangeCurrency(bid) {
+		return 0, false
+	}
+
+	// Check floor price
+	if bid.Price < rtb.floorFor(req, bid.ImpID) {
+		return 0, false
+	}
+
+	// Deals must be the impression's own
+	if dealMismatch(req, bid) {
+		return 0, false
+	}
+
+	// Check brand safety and competitive separation
+	if !rtb.checkBrandSafety(bid, req) {
+		return 0, false
+	}
+
+	return rtb.effectivePrice(user, bid), true
+}
+
+// outranksOnTie reports whether a beats an equal-price b
+func outranksOnTie(a, b *Bid) bool {
+	return tiebreak.Compare([]byte(a.DSP), []byte(a.ID), []byte(b.DSP), []byte(b.ID)) < 0
+}
+
 // checkBrandSafety and competitive separation
 func (rtb *RTBExchange) checkBrandSafety(bid *Bid, req *openrtb2.BidRequest) bool {
-	// Check blocked categories
-	if req.BCat != nil {
-		for _, cat := range bid.Categories {
-			for _, blocked := range req.BCat {
-				if cat == blocked {
-					return false
-				}
-			}
-		}
+	// Check blocked categories, and their subcategories and equivalents
+	if rtb.blockedCategory(req, bid) {
+		return false
 	}
 
 	// Check blocked advertisers
@@ -423,9 +895,50 @@ func (rtb *RTBExchange) checkBrandSafety(bid *Bid, req *openrtb2.BidRequest) boo
 		}
 	}
 
+	// Publisher blocks for this page
+	if rtb.Exclusions != nil && rtb.Exclusions.For(req).Blocks(bid) {
+		return false
+	}
+
 	return true
 }
 
+// blockedCategory reports whether the bid's categories fall under the
+// request's bcat in any taxonomy version
+func (rtb *RTBExchange) blockedCategory(req *openrtb2.BidRequest, bid *Bid) bool {
+	if len(req.BCat) == 0 || len(bid.Categories) == 0 {
+		return false
+	}
+	tax := rtb.Taxonomy
+	if tax == nil {
+		tax = taxonomy.Default()
+	}
+	return tax.Blocked(taxonomy.Cats(req.CatTax, req.BCat), taxonomy.Cats(bid.CatTax, bid.Categories))
+}
+
+// dealMismatch reports whether bid names a deal its impression doesn't
+// offer, or names none in a private auction
+func dealMismatch(req *openrtb2.BidRequest, bid *Bid) bool {
+	for _, imp := range req.Imp {
+		if imp.ID != bid.ImpID {
+			continue
+		}
+		if bid.DealID == "" {
+			return imp.PMP != nil && imp.PMP.PrivateAuction == 1
+		}
+		if imp.PMP == nil {
+			return true
+		}
+		for _, d := range imp.PMP.Deals {
+			if d.ID == bid.DealID {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // buildResponse creates OpenRTB response
 func (rtb *RTBExchange) buildResponse(winner *Bid, req *openrtb2.BidRequest) *openrtb2.BidResponse {
 	if winner == nil {
@@ -448,9 +961,16 @@ func (rtb *RTBExchange) buildResponse(winner *Bid, req *openrtb2.BidRequest) *op
 						ImpID:   winner.ImpID,
 						Price:   winner.Price,
 						AdID:    winner.AdID,
+						CrID:    winner.CreativeID,
 						AdM:     winner.Creative,
+						DealID:  winner.DealID,
+						CID:     winner.CampaignID,
 						Cat:     winner.Categories,
+						CatTax:  winner.CatTax,
 						ADomain: []string{winner.Advertiser},
+						Attr:    winner.Attr,
+						W:       winner.W,
+						H:       winner.H,
 					},
 				},
 			},
@@ -507,6 +1027,19 @@ type DailyMetrics struct {
 	AvgCPM      float64
 }
 
+// NewRateLimiter creates a token bucket allowing qps requests per second
+func NewRateLimiter(qps int) *RateLimiter {
+	if qps <= 0 {
+		qps = 1
+	}
+	return &RateLimiter{
+		tokens:     qps,
+		max:        qps,
+		refill:     time.Second / time.Duration(qps),
+		lastRefill: time.Now(),
+	}
+}
+
 // RateLimiter for DSP connections
 type RateLimiter struct {
 	tokens     int
@@ -524,7 +1057,10 @@ func (rl *RateLimiter) Allow() bool {
 	// Refill tokens
 	now := time.Now()
 	elapsed := now.Sub(rl.lastRefill)
-	tokensToAdd := int(elapsed / rl.refill)
+	tokensToAdd := 0
+	if rl.refill > 0 {
+		tokensToAdd = int(elapsed / rl.refill)
+	}
 
 	if tokensToAdd > 0 {
 		rl.tokens = min(rl.max, rl.tokens+tokensToAdd)
@@ -546,27 +1082,150 @@ func min(a, b int) int {
 	return b
 }
 
-// SendBidRequest to DSP (implement based on DSP protocol)
+// SendBidRequest posts req to the DSP's OpenRTB endpoint and returns its
+// highest bid, or nil on a no-bid. When the endpoint can't be connected
+// to, or its circuit is open, the DSP's Failover endpoints are tried in
+// turn within the same timeout; the bid records the one that answered.
+// A DSP with a Stream is sent req over it instead, falling back to HTTP
+// while the stream is down or at its in-flight limit.
 func (dsp *DSPConnection) SendBidRequest(ctx context.Context, req *openrtb2.BidRequest) (*Bid, error) {
-	// TODO: Implement HTTP/gRPC call to DSP
-	// This would make actual network call to DSP endpoint
-	return nil, nil
+	if dsp.Endpoint == "" {
+		return nil, nil
+	}
+	if dsp.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dsp.Timeout)
+		defer cancel()
+	}
+	if dsp.Stream != nil {
+		bid, err := dsp.sendStream(ctx, req)
+		if ctx.Err() != nil || !(errors.Is(err, ErrStreamBusy) || errors.Is(err, ErrStreamUnavailable)) {
+			return bid, err
+		}
+	}
+
+	body, err := jsonx.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(dsp.Failover) == 0 {
+		return dsp.post(ctx, dsp.Endpoint, body)
+	}
+	return dsp.postFailover(ctx, body)
+}
+
+// post sends a marshaled bid request to one of the DSP's endpoints
+func (dsp *DSPConnection) post(ctx context.Context, endpoint string, body []byte) (*Bid, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Openrtb-Version", "2.5")
+
+	client := dsp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrDSPStatus, resp.StatusCode)
+	}
+
+	var bidResp openrtb2.BidResponse
+	if err := jsonx.Decode(resp.Body, &bidResp); err != nil {
+		return nil, err
+	}
+	return dsp.bestBid(&bidResp, endpoint), nil
+}
+
+// bestBid picks the highest bid in a DSP's response, nil if it has none
+func (dsp *DSPConnection) bestBid(bidResp *openrtb2.BidResponse, endpoint string) *Bid {
+	var best *Bid
+	for _, seat := range bidResp.SeatBid {
+		for _, b := range seat.Bid {
+			if best != nil && b.Price <= best.Price {
+				continue
+			}
+			best = &Bid{
+				ID:         b.ID,
+				ImpID:      b.ImpID,
+				Price:      b.Price,
+				AdID:       b.AdID,
+				CreativeID: b.CrID,
+				Creative:   b.AdM,
+				DSP:        dsp.ID,
+				SeatID:     seat.Seat,
+				DealID:     b.DealID,
+				CampaignID: b.CID,
+				NURL:       b.NURL,
+				BURL:       b.BURL,
+				LURL:       b.LURL,
+				Categories: b.Cat,
+				CatTax:     b.CatTax,
+				Attr:       b.Attr,
+				W:          b.W,
+				H:          b.H,
+				Currency:   bidResp.Cur,
+				Endpoint:   endpoint,
+			}
+			if len(b.ADomain) > 0 {
+				best.Advertiser = b.ADomain[0]
+			}
+		}
+	}
+	return best
 }
 
 // convertCTVToOpenRTB converts CTV request to OpenRTB
 func (rtb *RTBExchange) convertCTVToOpenRTB(req *CTVBidRequest) *openrtb2.BidRequest {
 	// TODO: Implement conversion logic
+	// The user and device carry through so frequency caps apply to pods
+	user, device, regs := req.User, req.Device.Device, req.Regs
 	return &openrtb2.BidRequest{
-		ID: req.ID,
+		ID:     req.ID,
+		User:   &user,
+		Device: &device,
+		Regs:   &regs,
+		Test:   req.Test,
 	}
 }
 
-// convertOpenRTBToCTV converts OpenRTB response to CTV format
+// convertOpenRTBToCTV converts OpenRTB response to CTV format, assembling
+// the bids into the requested pods
 func (rtb *RTBExchange) convertOpenRTBToCTV(resp *openrtb2.BidResponse, req *CTVBidRequest) *CTVBidResponse {
-	// TODO: Implement conversion logic
-	return &CTVBidResponse{
-		ID: resp.ID,
+	out := &CTVBidResponse{ID: resp.ID}
+	if rtb.PodAssembler == nil || len(req.AdPods) == 0 {
+		return out
+	}
+	var ads []AdResponse
+	for _, seat := range resp.SeatBid {
+		for _, b := range seat.Bid {
+			ad := AdResponse{
+				ID:         b.ID,
+				AdID:       b.AdID,
+				Creative:   b.AdM,
+				Duration:   int(b.Dur),
+				Price:      b.Price,
+				CategoryID: b.Cat,
+			}
+			if len(b.ADomain) > 0 {
+				ad.AdvertiserID = b.ADomain[0]
+			}
+			ads = append(ads, ad)
+		}
 	}
+	out.AdPods = rtb.PodAssembler.AssembleSession(req.PublisherID, rtb.sessionKey(req), req.AdPods, ads)
+	return out
 }
 
 // OptimizeResponse optimizes CTV response
@@ -0,0 +1,593 @@
+package rtb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+)
+
+// OpenRTB 3.0 versions the exchange speaks
+const (
+	OpenRTB3Version   = "3.0"
+	AdCOMDomainSpec   = "adcom"
+	AdCOMDomainVer    = "1.0"
+	openRTBVersionHdr = "X-Openrtb-Version"
+)
+
+// ErrOpenRTB3 is returned for a 3.0 request that can't be mapped to an
+// auction
+var ErrOpenRTB3 = errors.New("invalid OpenRTB 3.0 request")
+
+// OpenRTB3Handler accepts OpenRTB 3.0 requests, with the AdCOM 1.0 domain
+// model, alongside 2.x ones. Requests declaring version 3 are converted to
+// 2.x, auctioned and answered in 3.0; everything else goes to V2 unchanged.
+type OpenRTB3Handler struct {
+	Exchange *RTBExchange
+	V2       http.Handler
+}
+
+// NewOpenRTB3Handler serves 3.0 requests through exchange and passes the
+// rest to v2
+func NewOpenRTB3Handler(exchange *RTBExchange, v2 http.Handler) *OpenRTB3Handler {
+	return &OpenRTB3Handler{Exchange: exchange, V2: v2}
+}
+
+// ServeHTTP answers a 3.0 request with a 3.0 bid or 204
+func (h *OpenRTB3Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "invalid bid request", http.StatusBadRequest)
+		return
+	}
+	if !IsOpenRTB3(r.Header, body) {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h.V2.ServeHTTP(w, r)
+		return
+	}
+
+	var req openrtb3.Body
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid bid request", http.StatusBadRequest)
+		return
+	}
+	resp, err := h.Bid(r.Context(), &req)
+	switch {
+	case errors.Is(err, ErrOpenRTB3):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrUnknownSeller), errors.Is(err, ErrUnauthorizedSeller):
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case len(resp.OpenRTB.Response.SeatBid) == 0:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(openRTBVersionHdr, OpenRTB3Version)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// IsOpenRTB3 reports whether a request declares OpenRTB 3, in the
+// x-openrtb-version header or the body's openrtb.ver
+func IsOpenRTB3(header http.Header, body []byte) bool {
+	if v := header.Get(openRTBVersionHdr); v != "" {
+		return strings.HasPrefix(v, "3")
+	}
+	var probe struct {
+		OpenRTB *struct {
+			Ver string `json:"ver"`
+		} `json:"openrtb"`
+	}
+	if json.Unmarshal(body, &probe) != nil || probe.OpenRTB == nil {
+		return false
+	}
+	return probe.OpenRTB.Ver == "" || strings.HasPrefix(probe.OpenRTB.Ver, "3")
+}
+
+// Bid runs the auction for a 3.0 request and returns a 3.0 response
+func (h *OpenRTB3Handler) Bid(ctx context.Context, body *openrtb3.Body) (*openrtb3.Body, error) {
+	req, err := FromOpenRTB3(body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.Exchange.BidRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return ToOpenRTB3Response(body.OpenRTB.Request, resp)
+}
+
+// FromOpenRTB3 maps a 3.0 request onto the 2.x request the auction runs
+// on. Items become impressions, placements their banner, video or audio
+// objects, and the AdCOM context the site or app, device, user, regs and
+// restrictions. The schain is read from source.ext.schain.
+func FromOpenRTB3(body *openrtb3.Body) (*openrtb2.BidRequest, error) {
+	o := body.OpenRTB
+	if o.Request == nil {
+		return nil, fmt.Errorf("%w: no request", ErrOpenRTB3)
+	}
+	if o.DomainSpec != "" && o.DomainSpec != AdCOMDomainSpec {
+		return nil, fmt.Errorf("%w: unsupported domain spec %q", ErrOpenRTB3, o.DomainSpec)
+	}
+	r := o.Request
+	if r.ID == "" || len(r.Item) == 0 {
+		return nil, fmt.Errorf("%w: request needs an id and items", ErrOpenRTB3)
+	}
+
+	req := &openrtb2.BidRequest{
+		ID:   r.ID,
+		Test: r.Test,
+		AT:   int64(r.AT),
+		TMax: r.TMax,
+		Cur:  r.Cur,
+		Ext:  r.Ext,
+	}
+	if r.WSeat == 1 {
+		req.WSeat = r.Seat
+	} else {
+		req.BSeat = r.Seat
+	}
+
+	for _, item := range r.Item {
+		imp, err := itemToImp(item)
+		if err != nil {
+			return nil, err
+		}
+		req.Imp = append(req.Imp, imp)
+	}
+
+	if r.Source != nil {
+		src, err := sourceFrom3(r.Source)
+		if err != nil {
+			return nil, err
+		}
+		req.Source = src
+	}
+
+	if len(r.Context) > 0 {
+		var rc adcom1.RequestContext
+		if err := json.Unmarshal(r.Context, &rc); err != nil {
+			return nil, fmt.Errorf("%w: context: %v", ErrOpenRTB3, err)
+		}
+		if err := applyContext(req, &rc); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// itemToImp maps an item and its placement spec to an impression
+func itemToImp(item openrtb3.Item) (openrtb2.Imp, error) {
+	imp := openrtb2.Imp{
+		ID:          item.ID,
+		BidFloor:    item.Flr,
+		BidFloorCur: item.FlrCur,
+		Exp:         item.Exp,
+		Ext:         item.Ext,
+	}
+	if item.ID == "" {
+		return imp, fmt.Errorf("%w: item without an id", ErrOpenRTB3)
+	}
+	if len(item.Deal) > 0 || item.Private == 1 {
+		imp.PMP = &openrtb2.PMP{PrivateAuction: item.Private}
+		for _, d := range item.Deal {
+			imp.PMP.Deals = append(imp.PMP.Deals, openrtb2.Deal{
+				ID:          d.ID,
+				BidFloor:    d.Flr,
+				BidFloorCur: d.FlrCur,
+				AT:          int64(d.AT),
+				WSeat:       d.WSeat,
+				WADomain:    d.WADomain,
+				Ext:         d.Ext,
+			})
+		}
+	}
+
+	var spec adcom1.ItemSpec
+	if len(item.Spec) > 0 {
+		if err := json.Unmarshal(item.Spec, &spec); err != nil {
+			return imp, fmt.Errorf("%w: item %s spec: %v", ErrOpenRTB3, item.ID, err)
+		}
+	}
+	p := spec.Placement
+	if p == nil || (p.Display == nil && p.Video == nil && p.Audio == nil) {
+		return imp, fmt.Errorf("%w: item %s has no display, video or audio placement", ErrOpenRTB3, item.ID)
+	}
+	imp.TagID = p.TagID
+	imp.Rwdd = p.Reward
+	if p.Secure == 1 {
+		imp.Secure = &p.Secure
+	}
+	if len(p.Ext) > 0 && len(imp.Ext) == 0 {
+		imp.Ext = p.Ext
+	}
+
+	if d := p.Display; d != nil {
+		imp.Instl = d.Instl
+		imp.IframeBuster = d.IfrBust
+		b := &openrtb2.Banner{
+			MIMEs:    d.MIME,
+			API:      d.API,
+			TopFrame: d.TopFrame,
+			Ext:      d.Ext,
+		}
+		if d.Pos != 0 {
+			b.Pos = &d.Pos
+		}
+		if d.W > 0 && d.H > 0 {
+			b.W, b.H = &d.W, &d.H
+		}
+		for _, f := range d.DisplayFmt {
+			b.Format = append(b.Format, openrtb2.Format{W: f.W, H: f.H, WRatio: int64(f.WRatio), HRatio: int64(f.HRatio)})
+			b.ExpDir = append(b.ExpDir, f.ExpDir...)
+		}
+		imp.Banner = b
+	}
+	if v := p.Video; v != nil {
+		ov := &openrtb2.Video{
+			MIMEs:         v.MIME,
+			MinDuration:   v.MinDur,
+			MaxDuration:   v.MaxDur,
+			RqdDurs:       v.RqdDurs,
+			Protocols:     v.CType,
+			Placement:     v.PType,
+			Linearity:     v.Linear,
+			SkipMin:       v.SkipMin,
+			SkipAfter:     v.SkipAfter,
+			MaxSeq:        v.MaxSeq,
+			PodDur:        v.PodDur,
+			PodSeq:        v.PodSeq,
+			SlotInPod:     v.SlotInPod,
+			MinCPMPerSec:  v.MinCPMPerSec,
+			MaxExtended:   v.MaxExt,
+			MinBitRate:    v.MinBitR,
+			MaxBitRate:    v.MaxBitR,
+			Delivery:      v.Delivery,
+			API:           v.API,
+			CompanionType: v.CompType,
+			PlaybackEnd:   v.PlayEnd,
+			Ext:           v.Ext,
+		}
+		if v.PlayMethod != 0 {
+			ov.PlaybackMethod = []adcom1.PlaybackMethod{v.PlayMethod}
+		}
+		if v.W > 0 && v.H > 0 {
+			ov.W, ov.H = &v.W, &v.H
+		}
+		if v.Pos != 0 {
+			ov.Pos = &v.Pos
+		}
+		if v.Delay != 0 {
+			ov.StartDelay = &v.Delay
+		}
+		if v.Skip == 1 {
+			ov.Skip = &v.Skip
+		}
+		if v.Boxing == 1 {
+			ov.BoxingAllowed = &v.Boxing
+		}
+		if v.PodID != 0 {
+			ov.PodID = fmt.Sprint(v.PodID)
+		}
+		imp.Video = ov
+	}
+	if a := p.Audio; a != nil {
+		oa := &openrtb2.Audio{
+			MIMEs:         a.MIME,
+			MinDuration:   a.MinDur,
+			MaxDuration:   a.MaxDur,
+			RqdDurs:       a.RqdDurs,
+			Protocols:     a.CType,
+			PodDur:        a.PodDur,
+			PodSeq:        a.PodSeq,
+			SlotInPod:     a.SlotInPod,
+			MinCPMPerSec:  a.MinCPMPerSec,
+			MaxExtended:   a.MaxExt,
+			MinBitrate:    a.MinBitR,
+			MaxBitrate:    a.MaxBitR,
+			Delivery:      a.Delivery,
+			API:           a.API,
+			CompanionType: a.CompType,
+			MaxSeq:        a.MaxSeq,
+			Feed:          a.Feed,
+			Ext:           a.Ext,
+		}
+		if a.Delay != 0 {
+			oa.StartDelay = &a.Delay
+		}
+		if a.NVol != 0 {
+			oa.NVol = &a.NVol
+		}
+		if a.PodID != 0 {
+			oa.PodID = fmt.Sprint(a.PodID)
+		}
+		imp.Audio = oa
+	}
+	return imp, nil
+}
+
+// source3Ext is the part of a 3.0 source.ext the exchange reads
+type source3Ext struct {
+	SChain *openrtb2.SupplyChain `json:"schain,omitempty"`
+}
+
+// sourceFrom3 maps a 3.0 source, moving source.ext.schain to the 2.x
+// source.schain where the supply chain checks read it
+func sourceFrom3(s *openrtb3.Source) (*openrtb2.Source, error) {
+	src := &openrtb2.Source{TID: s.TID, PChain: s.PChain, Ext: s.Ext}
+	if len(s.Ext) == 0 {
+		return src, nil
+	}
+	var ext source3Ext
+	if err := json.Unmarshal(s.Ext, &ext); err != nil {
+		return nil, fmt.Errorf("%w: source.ext: %v", ErrOpenRTB3, err)
+	}
+	src.SChain = ext.SChain
+	return src, nil
+}
+
+// regs3Ext holds the US privacy signals 3.0 carries in regs.ext
+type regs3Ext struct {
+	USPrivacy string `json:"us_privacy,omitempty"`
+	GPP       string `json:"gpp,omitempty"`
+	GPPSID    []int8 `json:"gpp_sid,omitempty"`
+	GDPR      *int8  `json:"gdpr,omitempty"`
+}
+
+// applyContext maps the AdCOM request context onto req
+func applyContext(req *openrtb2.BidRequest, rc *adcom1.RequestContext) error {
+	switch {
+	case rc.Site != nil:
+		s := rc.Site
+		req.Site = &openrtb2.Site{
+			ID:         s.ID,
+			Name:       s.Name,
+			Domain:     s.Domain,
+			CatTax:     s.CatTax,
+			Cat:        s.Cat,
+			SectionCat: s.SectCat,
+			PageCat:    s.PageCat,
+			Page:       s.Page,
+			Ref:        s.Ref,
+			Search:     s.Search,
+			Publisher:  publisherFrom3(s.Pub),
+			Keywords:   s.Keywords,
+			KwArray:    s.KwArray,
+			Ext:        s.Ext,
+		}
+		if s.Mobile == 1 {
+			req.Site.Mobile = &s.Mobile
+		}
+	case rc.App != nil:
+		a := rc.App
+		req.App = &openrtb2.App{
+			ID:         a.ID,
+			Name:       a.Name,
+			Bundle:     a.Bundle,
+			Domain:     a.Domain,
+			StoreURL:   a.StoreURL,
+			CatTax:     a.CatTax,
+			Cat:        a.Cat,
+			SectionCat: a.SectCat,
+			PageCat:    a.PageCat,
+			Ver:        a.Ver,
+			Publisher:  publisherFrom3(a.Pub),
+			Keywords:   a.Keywords,
+			KwArray:    a.KwArray,
+			Ext:        a.Ext,
+		}
+		if a.Paid == 1 {
+			req.App.Paid = &a.Paid
+		}
+	}
+
+	if d := rc.Device; d != nil {
+		req.Device = &openrtb2.Device{
+			Geo:        geoFrom3(d.Geo),
+			UA:         d.UA,
+			IP:         d.IP,
+			IPv6:       d.IPv6,
+			DeviceType: d.Type,
+			Make:       d.Make,
+			Model:      d.Model,
+			OS:         osName(d.OS),
+			OSV:        d.OSV,
+			HWV:        d.HWV,
+			H:          d.H,
+			W:          d.W,
+			PPI:        d.PPI,
+			PxRatio:    d.PxRatio,
+			Language:   d.Lang,
+			LangB:      d.LangB,
+			Carrier:    d.Carrier,
+			MCCMNC:     d.MCCMNC,
+			IFA:        d.IFA,
+			Ext:        d.Ext,
+		}
+		if d.DNT == 1 {
+			req.Device.DNT = &d.DNT
+		}
+		if d.Lmt == 1 {
+			req.Device.Lmt = &d.Lmt
+		}
+		if d.ConType != 0 {
+			req.Device.ConnectionType = &d.ConType
+		}
+	}
+
+	if u := rc.User; u != nil {
+		req.User = &openrtb2.User{
+			ID:       u.ID,
+			BuyerUID: u.BuyerUID,
+			Yob:      u.YOB,
+			Gender:   u.Gender,
+			Keywords: u.Keywords,
+			KwArray:  u.KwArray,
+			Consent:  u.Consent,
+			Geo:      geoFrom3(u.Geo),
+			Ext:      u.Ext,
+		}
+	}
+
+	if g := rc.Regs; g != nil {
+		regs := &openrtb2.Regs{COPPA: g.COPPA, Ext: g.Ext}
+		if len(g.Ext) > 0 {
+			var ext regs3Ext
+			if err := json.Unmarshal(g.Ext, &ext); err != nil {
+				return fmt.Errorf("%w: regs.ext: %v", ErrOpenRTB3, err)
+			}
+			regs.USPrivacy, regs.GPP, regs.GPPSID = ext.USPrivacy, ext.GPP, ext.GPPSID
+			regs.GDPR = ext.GDPR
+		}
+		if g.GDPR == 1 {
+			regs.GDPR = &g.GDPR
+		}
+		req.Regs = regs
+	}
+
+	if x := rc.Restrictions; x != nil {
+		req.BCat, req.CatTax, req.BAdv, req.BApp = x.BCat, x.CatTax, x.BAdv, x.BApp
+		for i := range req.Imp {
+			if req.Imp[i].Banner != nil {
+				req.Imp[i].Banner.BAttr = x.BAttr
+			}
+			if req.Imp[i].Video != nil {
+				req.Imp[i].Video.BAttr = x.BAttr
+			}
+			if req.Imp[i].Audio != nil {
+				req.Imp[i].Audio.BAttr = x.BAttr
+			}
+		}
+	}
+	return nil
+}
+
+func publisherFrom3(p *adcom1.Publisher) *openrtb2.Publisher {
+	if p == nil {
+		return nil
+	}
+	return &openrtb2.Publisher{ID: p.ID, Name: p.Name, Domain: p.Domain, Cat: p.Cat, CatTax: p.CatTax, Ext: p.Ext}
+}
+
+func geoFrom3(g *adcom1.Geo) *openrtb2.Geo {
+	if g == nil {
+		return nil
+	}
+	geo := &openrtb2.Geo{
+		Type:      g.Type,
+		Accuracy:  g.Accur,
+		LastFix:   g.LastFix,
+		IPService: g.IPServ,
+		Country:   g.Country,
+		Region:    g.Region,
+		Metro:     g.Metro,
+		City:      g.City,
+		ZIP:       g.ZIP,
+		UTCOffset: g.UTCOffset,
+		Ext:       g.Ext,
+	}
+	if g.Lat != 0 || g.Lon != 0 {
+		geo.Lat, geo.Lon = &g.Lat, &g.Lon
+	}
+	return geo
+}
+
+// osName is the 2.x device.os string for an AdCOM operating system
+func osName(os adcom1.OperatingSystem) string {
+	switch os {
+	case 0:
+		return ""
+	case adcom1.OSIOS:
+		return "iOS"
+	case adcom1.OSAndroid:
+		return "Android"
+	default:
+		return fmt.Sprint(int64(os))
+	}
+}
+
+// ToOpenRTB3Response renders the auction's 2.x response as the 3.0
+// response to req. Each bid's markup goes in its AdCOM ad under the media
+// type of the placement it was for.
+func ToOpenRTB3Response(req *openrtb3.Request, resp *openrtb2.BidResponse) (*openrtb3.Body, error) {
+	out := &openrtb3.Response{ID: resp.ID, BidID: resp.BidID, Cur: resp.Cur}
+	if out.Cur == "" {
+		out.Cur = exchangeCurrency
+	}
+	kinds := make(map[string]string, len(req.Item))
+	for _, item := range req.Item {
+		var spec adcom1.ItemSpec
+		if json.Unmarshal(item.Spec, &spec) == nil && spec.Placement != nil {
+			switch {
+			case spec.Placement.Video != nil:
+				kinds[item.ID] = "video"
+			case spec.Placement.Audio != nil:
+				kinds[item.ID] = "audio"
+			}
+		}
+	}
+
+	for _, sb := range resp.SeatBid {
+		seat := openrtb3.SeatBid{Seat: sb.Seat}
+		for _, b := range sb.Bid {
+			ad := adcom1.Ad{ID: b.CrID, ADomain: b.ADomain, IURL: b.IURL, Cat: b.Cat, CatTax: b.CatTax, Attr: b.Attr}
+			if ad.ID == "" {
+				ad.ID = b.AdID
+			}
+			if b.Bundle != "" {
+				ad.Bundle = []string{b.Bundle}
+			}
+			switch kinds[b.ImpID] {
+			case "video":
+				ad.Video = &adcom1.Video{AdM: b.AdM, Dur: b.Dur}
+			case "audio":
+				ad.Audio = &adcom1.Audio{AdM: b.AdM, Dur: b.Dur}
+			default:
+				ad.Display = &adcom1.Display{AdM: b.AdM, W: b.W, H: b.H}
+			}
+			media, err := json.Marshal(adcom1.BidMedia{Ad: &ad})
+			if err != nil {
+				return nil, err
+			}
+			seat.Bid = append(seat.Bid, openrtb3.Bid{
+				ID:     b.ID,
+				Item:   b.ImpID,
+				Price:  b.Price,
+				Deal:   b.DealID,
+				CID:    b.CID,
+				Tactic: b.Tactic,
+				PURL:   b.NURL,
+				BURL:   b.BURL,
+				LURL:   b.LURL,
+				Exp:    b.Exp,
+				Media:  media,
+				Ext:    b.Ext,
+			})
+		}
+		out.SeatBid = append(out.SeatBid, seat)
+	}
+
+	return &openrtb3.Body{OpenRTB: openrtb3.OpenRTB{
+		Ver:        OpenRTB3Version,
+		DomainSpec: AdCOMDomainSpec,
+		DomainVer:  AdCOMDomainVer,
+		Response:   out,
+	}}, nil
+}
@@ -0,0 +1,175 @@
+package rtb_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/luxfi/adx/pkg/rtb/rtbtest"
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+)
+
+// openRTB3Request is a 3.0 request with AdCOM placements and context
+const openRTB3Request = `{"openrtb": {
+	"ver": "3.0",
+	"domainspec": "adcom",
+	"domainver": "1.0",
+	"request": {
+		"id": "ortb3-req-1",
+		"tmax": 500,
+		"cur": ["USD"],
+		"source": {
+			"tid": "txn-9",
+			"ext": {"schain": {"complete": 1, "ver": "1.0", "nodes": [{"asi": "reseller.example", "sid": "r-1", "hp": 1}]}}
+		},
+		"item": [
+			{"id": "item-video", "flr": 1.5, "flrcur": "USD", "deal": [{"id": "deal-7", "flr": 4}],
+			 "spec": {"placement": {"tagid": "preroll", "video": {"mime": ["video/mp4"], "mindur": 5, "maxdur": 30, "w": 1280, "h": 720, "skip": 1, "skipmin": 5}}}},
+			{"id": "item-banner",
+			 "spec": {"placement": {"tagid": "sidebar", "display": {"displayfmt": [{"w": 300, "h": 250}]}}}}
+		],
+		"context": {
+			"site": {"domain": "news.example", "page": "https://news.example/story", "pub": {"id": "pub-42"}},
+			"device": {"type": 2, "ua": "Mozilla/5.0", "ip": "203.0.113.7", "os": 13, "geo": {"country": "USA", "region": "CA"}},
+			"user": {"id": "u-1", "consent": "CPXxRfAPXxRfA"},
+			"regs": {"coppa": 0, "gdpr": 1, "ext": {"us_privacy": "1YNN"}},
+			"restrictions": {"badv": ["blocked.example"], "bcat": ["IAB25"]}
+		}
+	}
+}}`
+
+func newOpenRTB3Handler(t *testing.T) (*rtb.OpenRTB3Handler, []*rtbtest.MockDSP, *bool) {
+	exchange := &rtb.RTBExchange{
+		AuctionTimeout: 200 * time.Millisecond,
+		Revenue:        big.NewInt(0),
+	}
+	dsps := rtbtest.StartMockDSPs(t, exchange, 2)
+	v2 := new(bool)
+	h := rtb.NewOpenRTB3Handler(exchange, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*v2 = true
+		var req openrtb2.BidRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return h, dsps, v2
+}
+
+func post(h http.Handler, body string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/rtb/bid", bytes.NewBufferString(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestOpenRTB3_Auction(t *testing.T) {
+	h, dsps, v2 := newOpenRTB3Handler(t)
+	dsps[0].Set(rtbtest.WithImpPrices(map[string]float64{"item-video": 4.5, "item-banner": 0.8}), rtbtest.WithAdM(`<VAST version="4.0"></VAST>`))
+	dsps[1].Set(rtbtest.WithImpPrices(map[string]float64{"item-video": 3}))
+
+	w := post(h, openRTB3Request, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if *v2 {
+		t.Error("3.0 request was passed to the 2.x handler")
+	}
+	if got := w.Header().Get("X-Openrtb-Version"); got != "3.0" {
+		t.Errorf("version header = %q", got)
+	}
+
+	var body openrtb3.Body
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	o := body.OpenRTB
+	if o.Ver != "3.0" || o.DomainSpec != "adcom" || o.Request != nil || o.Response == nil {
+		t.Fatalf("openrtb = %+v, want a 3.0 AdCOM response", o)
+	}
+	resp := o.Response
+	if resp.ID != "ortb3-req-1" || resp.Cur != "USD" || len(resp.SeatBid) != 1 || len(resp.SeatBid[0].Bid) != 1 {
+		t.Fatalf("response = %+v", resp)
+	}
+	bid := resp.SeatBid[0].Bid[0]
+	if resp.SeatBid[0].Seat != "seat-dsp-1" || bid.Item != "item-video" || bid.Price != 4.5 {
+		t.Errorf("winner = %s %+v", resp.SeatBid[0].Seat, bid)
+	}
+	var media adcom1.BidMedia
+	if err := json.Unmarshal(bid.Media, &media); err != nil {
+		t.Fatal(err)
+	}
+	if media.Ad == nil || media.Ad.Video == nil || media.Ad.Video.AdM != `<VAST version="4.0"></VAST>` || media.Ad.Display != nil {
+		t.Errorf("media = %s, want the markup as video", bid.Media)
+	}
+	if len(media.Ad.ADomain) != 1 || media.Ad.ADomain[0] != "dsp-1.example" {
+		t.Errorf("adomain = %v", media.Ad.ADomain)
+	}
+
+	// The DSPs were sent the equivalent 2.x request
+	sent := dsps[1].Requests()[0]
+	if len(sent.Imp) != 2 {
+		t.Fatalf("imps = %+v", sent.Imp)
+	}
+	video, banner := sent.Imp[0], sent.Imp[1]
+	if video.ID != "item-video" || video.TagID != "preroll" || video.BidFloor != 1.5 || video.Video == nil ||
+		*video.Video.W != 1280 || video.Video.MaxDuration != 30 || video.Video.Skip == nil || *video.Video.Skip != 1 {
+		t.Errorf("video imp = %+v", video)
+	}
+	if video.PMP == nil || len(video.PMP.Deals) != 1 || video.PMP.Deals[0].ID != "deal-7" || video.PMP.Deals[0].BidFloor != 4 {
+		t.Errorf("video pmp = %+v", video.PMP)
+	}
+	if banner.Banner == nil || len(banner.Banner.Format) != 1 || banner.Banner.Format[0].W != 300 {
+		t.Errorf("banner imp = %+v", banner)
+	}
+	if sent.Site == nil || sent.Site.Publisher.ID != "pub-42" || sent.Device.OS != "iOS" || sent.Device.Geo.Country != "USA" {
+		t.Errorf("context = site %+v device %+v", sent.Site, sent.Device)
+	}
+	if sent.User.Consent != "CPXxRfAPXxRfA" || len(sent.BAdv) != 1 || len(sent.BCat) != 1 {
+		t.Errorf("user = %+v badv = %v bcat = %v", sent.User, sent.BAdv, sent.BCat)
+	}
+
+	// Source, schain and regs carry over
+	if sent.Source == nil || sent.Source.TID != "txn-9" || sent.Source.SChain == nil ||
+		len(sent.Source.SChain.Nodes) != 1 || sent.Source.SChain.Nodes[0].ASI != "reseller.example" {
+		t.Errorf("source = %+v", sent.Source)
+	}
+	if sent.Regs == nil || sent.Regs.GDPR == nil || *sent.Regs.GDPR != 1 || sent.Regs.USPrivacy != "1YNN" {
+		t.Errorf("regs = %+v", sent.Regs)
+	}
+}
+
+func TestOpenRTB3_GatedOnVersion(t *testing.T) {
+	h, _, v2 := newOpenRTB3Handler(t)
+
+	// 2.x requests go to the 2.x handler untouched
+	if w := post(h, `{"id":"v2","imp":[{"id":"1","banner":{"w":300,"h":250}}]}`, nil); w.Code != http.StatusNoContent || !*v2 {
+		t.Errorf("2.x request: status %d, reached 2.x handler %v", w.Code, *v2)
+	}
+	*v2 = false
+	if w := post(h, openRTB3Request, http.Header{"X-Openrtb-Version": {"2.6"}}); !*v2 || w.Code != http.StatusNoContent {
+		t.Errorf("request declared 2.6 by header: status %d, reached 2.x handler %v", w.Code, *v2)
+	}
+
+	*v2 = false
+	if w := post(h, openRTB3Request, nil); w.Code != http.StatusNoContent || *v2 {
+		t.Errorf("3.0 no-bid: status %d, reached 2.x handler %v", w.Code, *v2)
+	}
+	if w := post(h, `{"openrtb":{"ver":"3.0","domainspec":"adcom","request":{"id":"x","item":[{"id":"1","spec":{}}]}}}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("item without placement: status %d, want 400", w.Code)
+	}
+	if w := post(h, `{"openrtb":{"ver":"3.0","domainspec":"other","request":{"id":"x","item":[{"id":"1"}]}}}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown domain spec: status %d, want 400", w.Code)
+	}
+}
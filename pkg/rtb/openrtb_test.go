@@ -197,3 +197,36 @@ func TestMinerRegistry_Register(t *testing.T) {
 		t.Error("Miner not properly registered")
 	}
 }
+
+func TestRunAuction_TieBreak(t *testing.T) {
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.50)}
+	req := &openrtb2.BidRequest{ID: "req-1", Imp: []openrtb2.Imp{{ID: "1"}}}
+
+	// sha256("dsp-b") < sha256("dsp-a") < sha256("dsp-c"), so dsp-b wins
+	// every permutation; of its bids, sha256("b") < sha256("b-2")
+	bids := []Bid{
+		{ID: "a", ImpID: "1", Price: 5.0, DSP: "dsp-a"},
+		{ID: "b-2", ImpID: "1", Price: 5.0, DSP: "dsp-b"},
+		{ID: "b", ImpID: "1", Price: 5.0, DSP: "dsp-b"},
+		{ID: "c", ImpID: "1", Price: 5.0, DSP: "dsp-c"},
+		{ID: "low", ImpID: "1", Price: 4.0, DSP: "dsp-b"},
+	}
+
+	for i := 0; i < len(bids); i++ {
+		rotated := append(append([]Bid{}, bids[i:]...), bids[:i]...)
+		for _, order := range [][]Bid{rotated, reversed(rotated)} {
+			winner := exchange.runAuction(order, req)
+			if winner == nil || winner.ID != "b" {
+				t.Fatalf("winner = %v, want b", winner)
+			}
+		}
+	}
+}
+
+func reversed(bids []Bid) []Bid {
+	out := make([]Bid, len(bids))
+	for i, b := range bids {
+		out[len(bids)-1-i] = b
+	}
+	return out
+}
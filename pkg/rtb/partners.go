@@ -0,0 +1,453 @@
+package rtb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrPartnerExists   = errors.New("partner already registered")
+	ErrPartnerNotFound = errors.New("partner not found")
+	ErrInvalidPartner  = errors.New("invalid partner")
+)
+
+// MaxDSPTimeout bounds a DSP's timeout; anything longer is a unit mistake
+const MaxDSPTimeout = 10 * time.Second
+
+// DSPSpec is the operator-managed configuration of a demand partner
+type DSPSpec struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"`
+	Endpoint   string `json:"endpoint"`
+	QPS        int    `json:"qps"`
+	TimeoutMS  int64  `json:"timeout_ms"`
+	BidderCode string `json:"bidder_code,omitempty"`
+	SeatID     string `json:"seat_id,omitempty"`
+	Paused     bool   `json:"paused,omitempty"`
+
+	Failover []string `json:"failover,omitempty"` // Endpoints tried in order when Endpoint can't be reached
+
+	Stream         string `json:"stream,omitempty"`           // ws(s) URL of the DSP's bid stream
+	StreamInFlight int    `json:"stream_in_flight,omitempty"` // DefaultStreamInFlight when zero
+
+	FeedbackURL  string `json:"feedback_url,omitempty"`
+	FeedbackMode string `json:"feedback_mode,omitempty"` // FeedbackImpression or FeedbackAggregate
+}
+
+// SSPSpec is the operator-managed configuration of a supply partner
+type SSPSpec struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name,omitempty"`
+	PublisherID string  `json:"publisher_id,omitempty"`
+	RevShare    float64 `json:"rev_share"`
+	Paused      bool    `json:"paused,omitempty"`
+}
+
+// Partners is the registry of DSPs and SSPs as persisted
+type Partners struct {
+	DSPs []DSPSpec `json:"dsps"`
+	SSPs []SSPSpec `json:"ssps"`
+}
+
+// Validate checks a DSP can be connected to
+func (s DSPSpec) Validate() error {
+	var errs []error
+	if s.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+	if u, err := url.Parse(s.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("endpoint %q is not an http(s) URL", s.Endpoint))
+	}
+	for i, f := range s.Failover {
+		if u, err := url.Parse(f); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("failover[%d] %q is not an http(s) URL", i, f))
+		}
+	}
+	if s.Stream != "" {
+		if u, err := url.Parse(s.Stream); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("stream %q is not a ws(s) URL", s.Stream))
+		}
+	}
+	if s.StreamInFlight < 0 {
+		errs = append(errs, fmt.Errorf("stream_in_flight %d is negative", s.StreamInFlight))
+	}
+	if s.QPS <= 0 {
+		errs = append(errs, fmt.Errorf("qps %d must be positive", s.QPS))
+	}
+	if t := time.Duration(s.TimeoutMS) * time.Millisecond; t <= 0 || t > MaxDSPTimeout {
+		errs = append(errs, fmt.Errorf("timeout_ms %d out of range, want 1 to %d", s.TimeoutMS, MaxDSPTimeout.Milliseconds()))
+	}
+	if s.FeedbackURL != "" {
+		if u, err := url.Parse(s.FeedbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("feedback_url %q is not an http(s) URL", s.FeedbackURL))
+		}
+	}
+	if s.FeedbackMode != "" && s.FeedbackMode != FeedbackImpression && s.FeedbackMode != FeedbackAggregate {
+		errs = append(errs, fmt.Errorf("feedback_mode %q, want %s or %s", s.FeedbackMode, FeedbackImpression, FeedbackAggregate))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPartner, err)
+	}
+	return nil
+}
+
+// Validate checks an SSP's settings
+func (s SSPSpec) Validate() error {
+	var errs []error
+	if s.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+	if s.RevShare < 0 || s.RevShare > 1 {
+		errs = append(errs, fmt.Errorf("rev_share %v out of range, want 0 to 1", s.RevShare))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPartner, err)
+	}
+	return nil
+}
+
+// Spec returns the DSP's configuration
+func (d *DSPConnection) Spec() DSPSpec {
+	return DSPSpec{
+		ID:         d.ID,
+		Name:       d.Name,
+		Endpoint:   d.Endpoint,
+		QPS:        d.QPS,
+		TimeoutMS:  d.Timeout.Milliseconds(),
+		BidderCode: d.BidderCode,
+		SeatID:     d.SeatID,
+		Paused:     d.Paused,
+
+		Failover: slices.Clone(d.Failover),
+
+		Stream:         d.streamURL(),
+		StreamInFlight: d.streamInFlight(),
+
+		FeedbackURL:  d.Feedback.URL,
+		FeedbackMode: d.Feedback.Mode,
+	}
+}
+
+func (d *DSPConnection) streamURL() string {
+	if d.Stream == nil {
+		return ""
+	}
+	return d.Stream.URL
+}
+
+func (d *DSPConnection) streamInFlight() int {
+	if d.Stream == nil || d.Stream.MaxInFlight == DefaultStreamInFlight {
+		return 0
+	}
+	return d.Stream.MaxInFlight
+}
+
+// Spec returns the SSP's configuration
+func (s *SSPConnection) Spec() SSPSpec {
+	return SSPSpec{ID: s.ID, Name: s.Name, PublisherID: s.PublisherID, RevShare: s.RevShare, Paused: s.Paused}
+}
+
+// connectDSP returns a connection configured by spec, carrying over the
+// client, hedging and counters of prev when there is one. Connections are
+// replaced rather than changed in place as auctions in flight read them
+// unlocked; counts those auctions still add to prev are lost.
+func connectDSP(spec DSPSpec, prev *DSPConnection) *DSPConnection {
+	d := &DSPConnection{
+		ID:          spec.ID,
+		Name:        spec.Name,
+		Endpoint:    spec.Endpoint,
+		QPS:         spec.QPS,
+		Timeout:     time.Duration(spec.TimeoutMS) * time.Millisecond,
+		BidderCode:  spec.BidderCode,
+		SeatID:      spec.SeatID,
+		Paused:      spec.Paused,
+		Failover:    slices.Clone(spec.Failover),
+		Feedback:    FeedbackConfig{URL: spec.FeedbackURL, Mode: spec.FeedbackMode},
+		RateLimiter: NewRateLimiter(spec.QPS),
+	}
+	if spec.Stream != "" {
+		d.Stream = NewBidStream(spec.Stream, spec.StreamInFlight)
+	}
+	if prev == nil {
+		return d
+	}
+	// An unchanged stream stays connected; a replaced one is closed, its
+	// requests in flight falling back to HTTP
+	if prev.Stream != nil {
+		if d.Stream != nil && prev.Stream.URL == d.Stream.URL && prev.Stream.MaxInFlight == d.Stream.MaxInFlight {
+			d.Stream = prev.Stream
+		} else {
+			prev.Stream.Close()
+		}
+	}
+	d.Client, d.Hedge = prev.Client, prev.Hedge
+	if prev.QPS == spec.QPS && prev.RateLimiter != nil {
+		d.RateLimiter = prev.RateLimiter
+	}
+	d.RequestCount = atomic.LoadUint64(&prev.RequestCount)
+	d.BidCount = atomic.LoadUint64(&prev.BidCount)
+	d.WinCount = atomic.LoadUint64(&prev.WinCount)
+	d.ErrorCount = atomic.LoadUint64(&prev.ErrorCount)
+	d.HedgeCount = atomic.LoadUint64(&prev.HedgeCount)
+	d.HedgeWins = atomic.LoadUint64(&prev.HedgeWins)
+	d.hedgeCalls = atomic.LoadUint64(&prev.hedgeCalls)
+	d.FailoverCount = atomic.LoadUint64(&prev.FailoverCount)
+	d.breakers.Store(prev.breakers.Load())
+	return d
+}
+
+// AddDSP connects a new DSP; it bids from the next auction on
+func (rtb *RTBExchange) AddDSP(spec DSPSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	if _, ok := rtb.DSPs[spec.ID]; ok {
+		return fmt.Errorf("%w: dsp %s", ErrPartnerExists, spec.ID)
+	}
+	if rtb.DSPs == nil {
+		rtb.DSPs = make(map[string]*DSPConnection)
+	}
+	rtb.DSPs[spec.ID] = connectDSP(spec, nil)
+	return nil
+}
+
+// UpdateDSP replaces a DSP's configuration, keeping its counters
+func (rtb *RTBExchange) UpdateDSP(spec DSPSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	prev, ok := rtb.DSPs[spec.ID]
+	if !ok {
+		return fmt.Errorf("%w: dsp %s", ErrPartnerNotFound, spec.ID)
+	}
+	rtb.DSPs[spec.ID] = connectDSP(spec, prev)
+	return nil
+}
+
+// PauseDSP stops or resumes sending a DSP bid requests
+func (rtb *RTBExchange) PauseDSP(id string, paused bool) error {
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	prev, ok := rtb.DSPs[id]
+	if !ok {
+		return fmt.Errorf("%w: dsp %s", ErrPartnerNotFound, id)
+	}
+	spec := prev.Spec()
+	spec.Paused = paused
+	rtb.DSPs[id] = connectDSP(spec, prev)
+	return nil
+}
+
+// RemoveDSP disconnects a DSP, closing its stream if it has one
+func (rtb *RTBExchange) RemoveDSP(id string) error {
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	d, ok := rtb.DSPs[id]
+	if !ok {
+		return fmt.Errorf("%w: dsp %s", ErrPartnerNotFound, id)
+	}
+	delete(rtb.DSPs, id)
+	if d.Stream != nil {
+		d.Stream.Close()
+	}
+	return nil
+}
+
+// DSP returns a DSP's connection
+func (rtb *RTBExchange) DSP(id string) (*DSPConnection, bool) {
+	rtb.mu.RLock()
+	defer rtb.mu.RUnlock()
+	d, ok := rtb.DSPs[id]
+	return d, ok
+}
+
+// AddSSP registers a new SSP
+func (rtb *RTBExchange) AddSSP(spec SSPSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	if _, ok := rtb.SSPs[spec.ID]; ok {
+		return fmt.Errorf("%w: ssp %s", ErrPartnerExists, spec.ID)
+	}
+	if rtb.SSPs == nil {
+		rtb.SSPs = make(map[string]*SSPConnection)
+	}
+	rtb.SSPs[spec.ID] = &SSPConnection{ID: spec.ID, Name: spec.Name, PublisherID: spec.PublisherID, RevShare: spec.RevShare, Paused: spec.Paused}
+	return nil
+}
+
+// UpdateSSP replaces an SSP's configuration, keeping its inventory
+func (rtb *RTBExchange) UpdateSSP(spec SSPSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	prev, ok := rtb.SSPs[spec.ID]
+	if !ok {
+		return fmt.Errorf("%w: ssp %s", ErrPartnerNotFound, spec.ID)
+	}
+	s := *prev
+	s.Name, s.PublisherID, s.RevShare, s.Paused = spec.Name, spec.PublisherID, spec.RevShare, spec.Paused
+	rtb.SSPs[spec.ID] = &s
+	return nil
+}
+
+// PauseSSP stops or resumes taking bid requests for an SSP's publisher
+func (rtb *RTBExchange) PauseSSP(id string, paused bool) error {
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	prev, ok := rtb.SSPs[id]
+	if !ok {
+		return fmt.Errorf("%w: ssp %s", ErrPartnerNotFound, id)
+	}
+	s := *prev
+	s.Paused = paused
+	rtb.SSPs[id] = &s
+	return nil
+}
+
+// RemoveSSP unregisters an SSP
+func (rtb *RTBExchange) RemoveSSP(id string) error {
+	rtb.mu.Lock()
+	defer rtb.mu.Unlock()
+	if _, ok := rtb.SSPs[id]; !ok {
+		return fmt.Errorf("%w: ssp %s", ErrPartnerNotFound, id)
+	}
+	delete(rtb.SSPs, id)
+	return nil
+}
+
+// SSP returns an SSP's connection
+func (rtb *RTBExchange) SSP(id string) (*SSPConnection, bool) {
+	rtb.mu.RLock()
+	defer rtb.mu.RUnlock()
+	s, ok := rtb.SSPs[id]
+	return s, ok
+}
+
+// Partners returns every DSP's and SSP's configuration, sorted by ID
+func (rtb *RTBExchange) Partners() Partners {
+	rtb.mu.RLock()
+	defer rtb.mu.RUnlock()
+	p := Partners{DSPs: make([]DSPSpec, 0, len(rtb.DSPs)), SSPs: make([]SSPSpec, 0, len(rtb.SSPs))}
+	for _, d := range rtb.DSPs {
+		p.DSPs = append(p.DSPs, d.Spec())
+	}
+	for _, s := range rtb.SSPs {
+		p.SSPs = append(p.SSPs, s.Spec())
+	}
+	sort.Slice(p.DSPs, func(i, j int) bool { return p.DSPs[i].ID < p.DSPs[j].ID })
+	sort.Slice(p.SSPs, func(i, j int) bool { return p.SSPs[i].ID < p.SSPs[j].ID })
+	return p
+}
+
+// RestorePartners applies a persisted registry, adding its partners and
+// replacing the configuration of those already connected
+func (rtb *RTBExchange) RestorePartners(p Partners) error {
+	for _, spec := range p.DSPs {
+		err := rtb.UpdateDSP(spec)
+		if errors.Is(err, ErrPartnerNotFound) {
+			err = rtb.AddDSP(spec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, spec := range p.SSPs {
+		err := rtb.UpdateSSP(spec)
+		if errors.Is(err, ErrPartnerNotFound) {
+			err = rtb.AddSSP(spec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeDSPs are the DSPs an auction fans out to
+func (rtb *RTBExchange) activeDSPs() []*DSPConnection {
+	rtb.mu.RLock()
+	defer rtb.mu.RUnlock()
+	dsps := make([]*DSPConnection, 0, len(rtb.DSPs))
+	for _, d := range rtb.DSPs {
+		if !d.Paused {
+			dsps = append(dsps, d)
+		}
+	}
+	return dsps
+}
+
+// pausedSSP is the paused SSP selling the publisher's inventory, if any
+func (rtb *RTBExchange) pausedSSP(publisherID string) (string, bool) {
+	rtb.mu.RLock()
+	defer rtb.mu.RUnlock()
+	for _, s := range rtb.SSPs {
+		if s.Paused && s.PublisherID == publisherID {
+			return s.ID, true
+		}
+	}
+	return "", false
+}
+
+// PartnerFile persists the partner registry as JSON
+type PartnerFile struct {
+	Path string
+}
+
+// Load reads the registry; a missing file is an empty registry
+func (f PartnerFile) Load() (Partners, error) {
+	var p Partners
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	return p, nil
+}
+
+// Save writes the registry, replacing the file atomically so a crash
+// never leaves it half written
+func (f PartnerFile) Save(p Partners) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
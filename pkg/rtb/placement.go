@@ -0,0 +1,141 @@
+package rtb
+
+import (
+	"sync"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// PlacementType is how an impression is presented, which sets its value:
+// an interstitial takes the whole screen and a rewarded ad is watched to
+// earn something, so both sell above standard inventory
+type PlacementType string
+
+const (
+	PlacementStandard     PlacementType = "standard"
+	PlacementInterstitial PlacementType = "interstitial"
+	PlacementRewarded     PlacementType = "rewarded"
+)
+
+// placementTypes orders the types for reporting
+var placementTypes = []PlacementType{PlacementStandard, PlacementInterstitial, PlacementRewarded}
+
+// PlacementTypeOf reads imp's placement type from its rwdd and instl
+// flags; a rewarded interstitial counts as rewarded
+func PlacementTypeOf(imp *openrtb2.Imp) PlacementType {
+	switch {
+	case imp.Rwdd == 1:
+		return PlacementRewarded
+	case imp.Instl == 1:
+		return PlacementInterstitial
+	default:
+		return PlacementStandard
+	}
+}
+
+// PlacementFloors are CPM floors for interstitial and rewarded
+// impressions. Like the publisher's own floor, the floor rules can't take
+// an impression below them; zero leaves a type to the rules.
+type PlacementFloors struct {
+	Interstitial float64
+	Rewarded     float64
+}
+
+// floor is the minimum CPM for an impression of type t
+func (p *PlacementFloors) floor(t PlacementType) float64 {
+	switch t {
+	case PlacementInterstitial:
+		return p.Interstitial
+	case PlacementRewarded:
+		return p.Rewarded
+	}
+	return 0
+}
+
+// tagPlacement fills in the attributes DSPs read an interstitial or
+// rewarded impression by, where the publisher left them unset: full-screen
+// position, and for video the interstitial placement subtypes
+func tagPlacement(imp *openrtb2.Imp) {
+	t := PlacementTypeOf(imp)
+	if t == PlacementStandard {
+		return
+	}
+	full := adcom1.PositionFullScreen
+	if v := imp.Video; v != nil {
+		if v.Plcmt == 0 {
+			v.Plcmt = adcom1.VideoPlcmtInterstitial
+		}
+		if v.Placement == 0 && imp.Instl == 1 {
+			v.Placement = adcom1.VideoPlacementAlwaysVisible
+		}
+		if v.Pos == nil && imp.Instl == 1 {
+			v.Pos = &full
+		}
+	}
+	if b := imp.Banner; b != nil && b.Pos == nil && imp.Instl == 1 {
+		b.Pos = &full
+	}
+}
+
+// PlacementReport is fill and revenue for one placement type
+type PlacementReport struct {
+	Type        PlacementType `json:"type"`
+	Impressions uint64        `json:"impressions"` // Auctioned
+	Filled      uint64        `json:"filled"`
+	FillRate    float64       `json:"fill_rate"`
+	Revenue     float64       `json:"revenue"` // Clearing CPMs summed, per impression
+	ECPM        float64       `json:"ecpm"`    // Average clearing CPM of filled impressions
+}
+
+// PlacementStats reports fill and revenue separately for standard,
+// interstitial and rewarded impressions
+type PlacementStats struct {
+	mu     sync.Mutex
+	byType map[PlacementType]*PlacementReport
+}
+
+// NewPlacementStats creates an empty report
+func NewPlacementStats() *PlacementStats {
+	return &PlacementStats{byType: make(map[PlacementType]*PlacementReport)}
+}
+
+// record counts req's impressions, and the one winner sold
+func (s *PlacementStats) record(req *openrtb2.BidRequest, winner *Bid) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range req.Imp {
+		t := PlacementTypeOf(&req.Imp[i])
+		r, ok := s.byType[t]
+		if !ok {
+			r = &PlacementReport{Type: t}
+			s.byType[t] = r
+		}
+		r.Impressions++
+		if winner != nil && winner.ImpID == req.Imp[i].ID {
+			r.Filled++
+			r.Revenue += winner.Price / 1000
+		}
+	}
+}
+
+// Report returns each placement type's fill and revenue, standard first
+func (s *PlacementStats) Report() []PlacementReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]PlacementReport, 0, len(placementTypes))
+	for _, t := range placementTypes {
+		r := PlacementReport{Type: t}
+		if got, ok := s.byType[t]; ok {
+			r = *got
+		}
+		if r.Impressions > 0 {
+			r.FillRate = float64(r.Filled) / float64(r.Impressions)
+		}
+		if r.Filled > 0 {
+			r.ECPM = r.Revenue * 1000 / float64(r.Filled)
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package rtb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+func TestPlacementFloors_Interstitial(t *testing.T) {
+	auction := func(price float64, imp openrtb2.Imp) *openrtb2.BidResponse {
+		t.Helper()
+		exchange := &RTBExchange{
+			DSPs:            map[string]*DSPConnection{"dsp": bidderDSP(t, "dsp", "", openrtb2.Bid{Price: price})},
+			AuctionTimeout:  time.Second,
+			PlacementFloors: &PlacementFloors{Interstitial: 3, Rewarded: 5},
+			Revenue:         big.NewInt(0),
+		}
+		imp.ID = "1"
+		resp, err := exchange.BidRequest(context.Background(), &openrtb2.BidRequest{ID: "auc", Imp: []openrtb2.Imp{imp}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	instl := openrtb2.Imp{Instl: 1, Banner: &openrtb2.Banner{}}
+	if resp := auction(2, instl); len(resp.SeatBid) != 0 {
+		t.Errorf("interstitial sold at 2 under a floor of 3: %+v", resp.SeatBid)
+	}
+	if resp := auction(4, instl); len(resp.SeatBid) != 1 {
+		t.Error("interstitial didn't sell at 4 over a floor of 3")
+	}
+	if resp := auction(2, openrtb2.Imp{Banner: &openrtb2.Banner{}}); len(resp.SeatBid) != 1 {
+		t.Error("standard impression held to the interstitial floor")
+	}
+}
+
+func TestTagPlacement(t *testing.T) {
+	imp := openrtb2.Imp{Instl: 1, Video: &openrtb2.Video{}, Banner: &openrtb2.Banner{}}
+	tagPlacement(&imp)
+	if imp.Video.Plcmt != adcom1.VideoPlcmtInterstitial || imp.Video.Placement != adcom1.VideoPlacementAlwaysVisible {
+		t.Errorf("video = %+v", imp.Video)
+	}
+	if imp.Video.Pos == nil || *imp.Video.Pos != adcom1.PositionFullScreen || imp.Banner.Pos == nil || *imp.Banner.Pos != adcom1.PositionFullScreen {
+		t.Error("interstitial not positioned full screen")
+	}
+
+	above := adcom1.PositionAboveFold
+	rewarded := openrtb2.Imp{Rwdd: 1, Video: &openrtb2.Video{Pos: &above}}
+	tagPlacement(&rewarded)
+	if rewarded.Video.Plcmt != adcom1.VideoPlcmtInterstitial || *rewarded.Video.Pos != above {
+		t.Errorf("rewarded video = %+v", rewarded.Video)
+	}
+
+	standard := openrtb2.Imp{Video: &openrtb2.Video{}}
+	tagPlacement(&standard)
+	if standard.Video.Plcmt != 0 || standard.Video.Pos != nil {
+		t.Errorf("standard video tagged: %+v", standard.Video)
+	}
+}
+
+func TestPlacementStats_RewardedAndStandardRevenue(t *testing.T) {
+	stats := NewPlacementStats()
+	exchange := &RTBExchange{
+		DSPs:           map[string]*DSPConnection{"dsp": bidderDSP(t, "dsp", "", openrtb2.Bid{Price: 6})},
+		AuctionTimeout: time.Second,
+		Placements:     stats,
+		Revenue:        big.NewInt(0),
+	}
+	cleared := map[PlacementType]float64{}
+	for i, imp := range []openrtb2.Imp{
+		{Rwdd: 1, Video: &openrtb2.Video{}},
+		{Rwdd: 1, Video: &openrtb2.Video{}, BidFloor: 10}, // Unfilled
+		{Banner: &openrtb2.Banner{}},
+	} {
+		imp.ID = "1"
+		resp, err := exchange.BidRequest(context.Background(), &openrtb2.BidRequest{ID: fmt.Sprintf("auc-%d", i), Imp: []openrtb2.Imp{imp}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sb := range resp.SeatBid {
+			cleared[PlacementTypeOf(&imp)] += sb.Bid[0].Price / 1000
+		}
+	}
+
+	report := map[PlacementType]PlacementReport{}
+	for _, r := range stats.Report() {
+		report[r.Type] = r
+	}
+	rewarded, standard := report[PlacementRewarded], report[PlacementStandard]
+	if rewarded.Impressions != 2 || rewarded.Filled != 1 || rewarded.FillRate != 0.5 {
+		t.Errorf("rewarded = %+v, want 1 of 2 filled", rewarded)
+	}
+	if standard.Impressions != 1 || standard.Filled != 1 || standard.FillRate != 1 {
+		t.Errorf("standard = %+v, want 1 of 1 filled", standard)
+	}
+	for _, r := range []PlacementReport{rewarded, standard} {
+		if r.Revenue <= 0 || math.Abs(r.Revenue-cleared[r.Type]) > 1e-9 {
+			t.Errorf("%s revenue = %v, want %v", r.Type, r.Revenue, cleared[r.Type])
+		}
+	}
+	if instl := report[PlacementInterstitial]; instl.Impressions != 0 || instl.Revenue != 0 {
+		t.Errorf("interstitial = %+v, want nothing", instl)
+	}
+}
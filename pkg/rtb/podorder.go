@@ -0,0 +1,169 @@
+package rtb
+
+import "sort"
+
+// maxPodSearch is the most ads a pod can hold for its order to be searched
+// exhaustively; larger pods are ordered by price
+const maxPodSearch = 10
+
+// SetPositionVCR sets the completion rate expected at each pod position,
+// first position first, as analytics' QuartileTracker.PositionVCR reports
+// it. Positions past the end of the curve take its last rate. Pods are
+// left in price order when no curve is set.
+func (a *AdPodAssembler) SetPositionVCR(curve []float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.positionVCR = append([]float64(nil), curve...)
+}
+
+// orderPod orders a pod's ads to maximize expected revenue, each paid ad's
+// price weighted by the completion rate at its position, without placing
+// ads the separation rule keeps apart next to each other, and sets the
+// pod's ExpectedRevenue. Ties keep the higher-priced ad earlier, so the
+// order is deterministic.
+func (a *AdPodAssembler) orderPod(pod *AdPodResponse, sep PodSeparation) {
+	a.mu.RLock()
+	curve := a.positionVCR
+	a.mu.RUnlock()
+	if len(curve) == 0 {
+		pod.ExpectedRevenue = pod.TotalPrice
+		return
+	}
+
+	n := len(pod.Ads)
+	if n == 0 {
+		return
+	}
+	vcr := make([]float64, n)
+	for i := range vcr {
+		vcr[i] = curve[len(curve)-1]
+		if i < len(curve) {
+			vcr[i] = curve[i]
+		}
+	}
+
+	// Candidates by value, highest first, so the first order found is the
+	// naive one and ties resolve towards it
+	ads := append([]AdResponse(nil), pod.Ads...)
+	sort.SliceStable(ads, func(i, j int) bool { return paidPrice(ads[i]) > paidPrice(ads[j]) })
+	if n > maxPodSearch {
+		pod.Ads = ads
+		pod.ExpectedRevenue = expectedRevenue(ads, vcr)
+		return
+	}
+
+	s := &podSearch{
+		ads:   ads,
+		keys:  make([][]string, n),
+		vcr:   vcr,
+		used:  make([]bool, n),
+		order: make([]int, 0, n),
+		best:  -1,
+	}
+	for i, ad := range ads {
+		s.keys[i] = a.separationKeys(sep.Level, ad)
+	}
+	// bound[p] is the most positions p on can earn: the highest rates
+	// paired with the highest prices
+	s.bound = make([][]float64, n+1)
+	for p := 0; p < n; p++ {
+		rates := append([]float64(nil), vcr[p:]...)
+		sort.Sort(sort.Reverse(sort.Float64Slice(rates)))
+		s.bound[p] = rates
+	}
+	s.search(0)
+	if s.bestOrder == nil {
+		// Nothing keeps competing ads apart; drop the constraint
+		for i := range s.keys {
+			s.keys[i] = nil
+		}
+		s.search(0)
+	}
+
+	ordered := make([]AdResponse, n)
+	for p, i := range s.bestOrder {
+		ordered[p] = ads[i]
+	}
+	pod.Ads = ordered
+	pod.ExpectedRevenue = s.best
+}
+
+// podSearch is a branch and bound search over a pod's orders
+type podSearch struct {
+	ads   []AdResponse // By paid price, highest first
+	keys  [][]string
+	vcr   []float64
+	bound [][]float64
+
+	used  []bool
+	order []int
+	value float64
+
+	best      float64
+	bestOrder []int
+}
+
+func (s *podSearch) search(pos int) {
+	if pos == len(s.ads) {
+		if s.value > s.best {
+			s.best = s.value
+			s.bestOrder = append([]int(nil), s.order...)
+		}
+		return
+	}
+	if s.value+s.remaining(pos) <= s.best {
+		return
+	}
+	for i := range s.ads {
+		if s.used[i] || (pos > 0 && sharesKey(s.keys[i], s.keys[s.order[pos-1]])) {
+			continue
+		}
+		v := paidPrice(s.ads[i]) * s.vcr[pos]
+		s.used[i] = true
+		s.order = append(s.order, i)
+		s.value += v
+		s.search(pos + 1)
+		s.value -= v
+		s.order = s.order[:pos]
+		s.used[i] = false
+	}
+}
+
+// remaining bounds what the unplaced ads can earn from pos on
+func (s *podSearch) remaining(pos int) float64 {
+	total, r := 0.0, 0
+	for i := range s.ads {
+		if !s.used[i] {
+			total += paidPrice(s.ads[i]) * s.bound[pos][r]
+			r++
+		}
+	}
+	return total
+}
+
+// paidPrice is what an ad earns; house ads earn nothing
+func paidPrice(ad AdResponse) float64 {
+	if ad.House {
+		return 0
+	}
+	return ad.Price
+}
+
+func expectedRevenue(ads []AdResponse, vcr []float64) float64 {
+	total := 0.0
+	for i, ad := range ads {
+		total += paidPrice(ad) * vcr[i]
+	}
+	return total
+}
+
+func sharesKey(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package rtb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdPodAssembler_PositionOrder(t *testing.T) {
+	// Viewers drop off through the pod but stay for its last slot, waiting
+	// for the show to come back
+	curve := []float64{0.95, 0.80, 0.70, 0.90}
+	ads := []AdResponse{
+		{ID: "a", Duration: 15, Price: 20},
+		{ID: "b", Duration: 15, Price: 15},
+		{ID: "c", Duration: 15, Price: 10},
+		{ID: "d", Duration: 15, Price: 5},
+	}
+	pods := []AdPodRequest{{ID: "mid-1", MaxDuration: 60, MaxAds: 4}}
+
+	a := &AdPodAssembler{MaxPodDuration: 120 * time.Second}
+	if got := a.Assemble("pub-1", pods, ads)[0]; got.ExpectedRevenue != got.TotalPrice {
+		t.Errorf("expected revenue without a curve = %v, want %v", got.ExpectedRevenue, got.TotalPrice)
+	}
+
+	a.SetPositionVCR(curve)
+	got := a.Assemble("pub-1", pods, ads)[0]
+	if ids := podIDs([]AdPodResponse{got})[0]; !reflect.DeepEqual(ids, []string{"a", "c", "d", "b"}) {
+		t.Errorf("pod = %v, want the top ad first and the runner-up in the last slot", ids)
+	}
+	naive := 20*0.95 + 15*0.80 + 10*0.70 + 5*0.90
+	if want := 20*0.95 + 10*0.80 + 5*0.70 + 15*0.90; math.Abs(got.ExpectedRevenue-want) > 1e-9 || got.ExpectedRevenue <= naive {
+		t.Errorf("expected revenue = %v, want %v over the naive %v", got.ExpectedRevenue, want, naive)
+	}
+	if got.TotalPrice != 50 {
+		t.Errorf("total price = %v", got.TotalPrice)
+	}
+}
+
+func TestAdPodAssembler_PositionOrderSeparation(t *testing.T) {
+	a := &AdPodAssembler{MaxPodDuration: 120 * time.Second}
+	a.SetPositionVCR([]float64{1, 0.9, 0.8, 0.7})
+	a.SetPublisherSeparation("pub-1", PodSeparation{Level: SeparateAdvertiser, MaxPerPod: 2})
+	ads := []AdResponse{
+		{ID: "ko-1", AdvertiserID: "ko.example", Duration: 15, Price: 20},
+		{ID: "ko-2", AdvertiserID: "ko.example", Duration: 15, Price: 18},
+		{ID: "pepsi", AdvertiserID: "pepsico.example", Duration: 15, Price: 10},
+		{ID: "car", AdvertiserID: "cars.example", Duration: 15, Price: 5, House: true},
+	}
+	pods := []AdPodRequest{{ID: "mid-1", MaxDuration: 60, MaxAds: 4}}
+
+	// The same advertiser's ads aren't back to back
+	got := a.Assemble("pub-1", pods, ads)[0]
+	if ids := podIDs([]AdPodResponse{got})[0]; !reflect.DeepEqual(ids, []string{"ko-1", "pepsi", "ko-2", "car"}) {
+		t.Errorf("pod = %v", ids)
+	}
+	if want := 20 + 10*0.9 + 18*0.8; math.Abs(got.ExpectedRevenue-want) > 1e-9 {
+		t.Errorf("expected revenue = %v, want %v without the house ad", got.ExpectedRevenue, want)
+	}
+
+	// When they can't be kept apart, they're ordered by value alone
+	ko := []AdResponse{ads[0], ads[1]}
+	if ids := podIDs(a.Assemble("pub-1", pods, ko))[0]; !reflect.DeepEqual(ids, []string{"ko-1", "ko-2"}) {
+		t.Errorf("pod = %v", ids)
+	}
+}
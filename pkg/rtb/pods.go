@@ -0,0 +1,339 @@
+package rtb
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/luxfi/adx/pkg/taxonomy"
+	"github.com/prebid/openrtb/v20/adcom1"
+)
+
+// SeparationLevel is what competing ads in a pod share
+type SeparationLevel string
+
+const (
+	// SeparateAdvertiser keeps each advertiser's ads apart
+	SeparateAdvertiser SeparationLevel = "advertiser"
+	// SeparateParentBrand keeps brands under the same top-level brand
+	// apart, e.g. Coke and Sprite
+	SeparateParentBrand SeparationLevel = "parent_brand"
+	// SeparateCategory keeps ads in the same tier-1 IAB category apart
+	SeparateCategory SeparationLevel = "category"
+)
+
+// PodSeparation is a publisher's competitive separation rule for pods
+type PodSeparation struct {
+	Level     SeparationLevel // No separation when empty
+	MaxPerPod int             // Ads sharing an advertiser, parent brand or category per pod; 1 when zero
+}
+
+// Brand is a node in the brand hierarchy
+type Brand struct {
+	ID         string
+	Parent     string // Parent brand ID; empty for a top-level brand
+	Advertiser string // Owning advertiser; inherited from the parent when empty
+}
+
+// BrandRegistry maps brands to their parent brands and advertisers
+type BrandRegistry struct {
+	mu     sync.RWMutex
+	brands map[string]Brand
+}
+
+// NewBrandRegistry creates an empty registry
+func NewBrandRegistry() *BrandRegistry {
+	return &BrandRegistry{brands: make(map[string]Brand)}
+}
+
+// Register adds or replaces a brand
+func (r *BrandRegistry) Register(b Brand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.brands[strings.ToLower(b.ID)] = b
+}
+
+// ParentBrand returns the top-level brand above id, or id itself if it has
+// no parent or isn't registered
+func (r *BrandRegistry) ParentBrand(id string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	top := strings.ToLower(id)
+	seen := make(map[string]bool)
+	for b, ok := r.brands[top]; ok && b.Parent != "" && !seen[top]; b, ok = r.brands[top] {
+		seen[top] = true
+		top = strings.ToLower(b.Parent)
+	}
+	return top
+}
+
+// Advertiser returns the advertiser owning id, from it or its nearest
+// ancestor that names one
+func (r *BrandRegistry) Advertiser(id string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key := strings.ToLower(id)
+	seen := make(map[string]bool)
+	for b, ok := r.brands[key]; ok && !seen[key]; b, ok = r.brands[key] {
+		if b.Advertiser != "" {
+			return strings.ToLower(b.Advertiser)
+		}
+		seen[key] = true
+		key = strings.ToLower(b.Parent)
+	}
+	return ""
+}
+
+// HouseAdInventory holds the exchange's own ads, used to fill pods paid
+// demand leaves short. Ads are registered for one publisher or, under the
+// empty publisher ID, for all of them.
+type HouseAdInventory struct {
+	mu  sync.RWMutex
+	ads map[string][]AdResponse
+}
+
+// NewHouseAdInventory creates an empty inventory
+func NewHouseAdInventory() *HouseAdInventory {
+	return &HouseAdInventory{ads: make(map[string][]AdResponse)}
+}
+
+// Add registers a house ad for a publisher, or every publisher when
+// publisherID is empty, replacing one with the same ID. Price is what the
+// impression is booked at internally, usually zero.
+func (h *HouseAdInventory) Add(publisherID string, ad AdResponse) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ad.House = true
+	ads := h.ads[publisherID]
+	for i := range ads {
+		if ads[i].ID == ad.ID {
+			ads[i] = ad
+			return
+		}
+	}
+	h.ads[publisherID] = append(ads, ad)
+}
+
+// Remove unregisters a publisher's house ad
+func (h *HouseAdInventory) Remove(publisherID, adID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ads := h.ads[publisherID]
+	for i := range ads {
+		if ads[i].ID == adID {
+			h.ads[publisherID] = append(ads[:i:i], ads[i+1:]...)
+			return
+		}
+	}
+}
+
+// For returns the house ads a publisher's pods may be filled with, its
+// own before the network's, each in the order added
+func (h *HouseAdInventory) For(publisherID string) []AdResponse {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := append([]AdResponse(nil), h.ads[publisherID]...)
+	if publisherID != "" {
+		out = append(out, h.ads[""]...)
+	}
+	return out
+}
+
+// SetPublisherBackfill opts a publisher in or out of house ad backfill
+func (a *AdPodAssembler) SetPublisherBackfill(publisherID string, on bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.backfillPublishers == nil {
+		a.backfillPublishers = make(map[string]bool)
+	}
+	a.backfillPublishers[publisherID] = on
+}
+
+// houseAdsFor returns the house ads to backfill a publisher's pods with,
+// none unless it opted in
+func (a *AdPodAssembler) houseAdsFor(publisherID string) []AdResponse {
+	a.mu.RLock()
+	on := a.backfillPublishers[publisherID]
+	a.mu.RUnlock()
+	if !on || a.HouseAds == nil {
+		return nil
+	}
+	return a.HouseAds.For(publisherID)
+}
+
+// SetPublisherSeparation overrides the separation rule for one publisher
+func (a *AdPodAssembler) SetPublisherSeparation(publisherID string, sep PodSeparation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.publishers == nil {
+		a.publishers = make(map[string]PodSeparation)
+	}
+	a.publishers[publisherID] = sep
+}
+
+// SeparationFor returns the rule a publisher's pods are assembled under:
+// its own, else Separation, else advertiser separation when
+// CompetitiveSeparation is set
+func (a *AdPodAssembler) SeparationFor(publisherID string) PodSeparation {
+	a.mu.RLock()
+	sep, ok := a.publishers[publisherID]
+	a.mu.RUnlock()
+	switch {
+	case ok:
+	case a.Separation.Level != "":
+		sep = a.Separation
+	case a.CompetitiveSeparation:
+		sep = PodSeparation{Level: SeparateAdvertiser}
+	}
+	if sep.MaxPerPod <= 0 {
+		sep.MaxPerPod = 1
+	}
+	return sep
+}
+
+// Assemble fills pods with ads, highest price first. Each ad goes in the
+// first pod it fits, by duration and ad count, without breaking the
+// publisher's separation rule, so competing ads spread across pods; ads
+// that fit nowhere are dropped. Pods paid demand leaves short of their
+// minimum duration or ad count are then backfilled from house ads, for
+// publishers that opted in. Finally each pod is ordered for the most
+// revenue given how often ads complete at each position; see
+// SetPositionVCR.
+func (a *AdPodAssembler) Assemble(publisherID string, pods []AdPodRequest, ads []AdResponse) []AdPodResponse {
+	sep := a.SeparationFor(publisherID)
+	ranked := append([]AdResponse(nil), ads...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Price > ranked[j].Price })
+
+	out := make([]AdPodResponse, len(pods))
+	counts := make([]map[string]int, len(pods))
+	for i, p := range pods {
+		out[i].ID = p.ID
+		counts[i] = make(map[string]int)
+	}
+
+	for _, ad := range ranked {
+		keys := a.separationKeys(sep.Level, ad)
+		for i, p := range pods {
+			if a.fits(p, &out[i], counts[i], ad, keys, sep) {
+				a.place(&out[i], counts[i], ad, keys)
+				break
+			}
+		}
+	}
+
+	if house := a.houseAdsFor(publisherID); len(house) > 0 {
+		for i, p := range pods {
+			a.backfill(p, &out[i], counts[i], house, sep)
+		}
+	}
+	for i := range out {
+		a.orderPod(&out[i], sep)
+	}
+	return out
+}
+
+// backfill adds house ads to a pod until it reaches its minimum duration
+// and ad count, each house ad at most once
+func (a *AdPodAssembler) backfill(p AdPodRequest, pod *AdPodResponse, counts map[string]int, house []AdResponse, sep PodSeparation) {
+	minDur := p.MinDuration
+	if minDur <= 0 {
+		minDur = int(a.MinPodDuration.Seconds())
+	}
+	short := func() bool { return pod.TotalDuration < minDur || len(pod.Ads) < p.MinAds }
+	if !short() {
+		return
+	}
+	used := make(map[string]bool, len(pod.Ads))
+	for _, ad := range pod.Ads {
+		used[ad.ID] = true
+	}
+	for _, ad := range house {
+		if !short() {
+			return
+		}
+		keys := a.separationKeys(sep.Level, ad)
+		if used[ad.ID] || !a.fits(p, pod, counts, ad, keys, sep) {
+			continue
+		}
+		ad.House = true
+		a.place(pod, counts, ad, keys)
+		used[ad.ID] = true
+	}
+}
+
+// fits reports whether ad can join pod within its duration and ad count
+// limits and the separation rule
+func (a *AdPodAssembler) fits(p AdPodRequest, pod *AdPodResponse, counts map[string]int, ad AdResponse, keys []string, sep PodSeparation) bool {
+	maxDur := int(a.MaxPodDuration.Seconds())
+	limit := p.MaxDuration
+	if limit <= 0 || (maxDur > 0 && maxDur < limit) {
+		limit = maxDur
+	}
+	switch {
+	case p.MaxAds > 0 && len(pod.Ads) >= p.MaxAds:
+		return false
+	case limit > 0 && pod.TotalDuration+ad.Duration > limit:
+		return false
+	}
+	return !conflicts(counts, keys, sep.MaxPerPod)
+}
+
+// place adds ad to pod; house ads count towards its duration but not its
+// price, which is what buyers paid
+func (a *AdPodAssembler) place(pod *AdPodResponse, counts map[string]int, ad AdResponse, keys []string) {
+	pod.Ads = append(pod.Ads, ad)
+	pod.TotalDuration += ad.Duration
+	if ad.House {
+		pod.HouseDuration += ad.Duration
+	} else {
+		pod.TotalPrice += ad.Price
+	}
+	for _, k := range keys {
+		counts[k]++
+	}
+}
+
+// separationKeys are the values an ad can't share with too many others in
+// a pod at the given level
+func (a *AdPodAssembler) separationKeys(level SeparationLevel, ad AdResponse) []string {
+	brands := a.Brands
+	if brands == nil {
+		brands = NewBrandRegistry()
+	}
+	switch level {
+	case SeparateAdvertiser:
+		adv := strings.ToLower(ad.AdvertiserID)
+		if ad.BrandID != "" {
+			if owner := brands.Advertiser(ad.BrandID); owner != "" {
+				adv = owner
+			}
+		}
+		if adv != "" {
+			return []string{adv}
+		}
+	case SeparateParentBrand:
+		if ad.BrandID != "" {
+			return []string{brands.ParentBrand(ad.BrandID)}
+		}
+	case SeparateCategory:
+		var keys []string
+		for _, c := range ad.CategoryID {
+			cat := taxonomy.Cat(adcom1.CatTaxIABContent10, c)
+			for p, ok := taxonomy.Default().Parent(cat); ok; p, ok = taxonomy.Default().Parent(cat) {
+				cat = p
+			}
+			keys = append(keys, cat.ID)
+		}
+		return keys
+	}
+	return nil
+}
+
+func conflicts(counts map[string]int, keys []string, max int) bool {
+	for _, k := range keys {
+		if counts[k] >= max {
+			return true
+		}
+	}
+	return false
+}
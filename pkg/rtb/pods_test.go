@@ -0,0 +1,128 @@
+package rtb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// podIDs lists each pod's ad IDs
+func podIDs(pods []AdPodResponse) [][]string {
+	out := make([][]string, len(pods))
+	for i, p := range pods {
+		out[i] = []string{}
+		for _, ad := range p.Ads {
+			out[i] = append(out[i], ad.ID)
+		}
+	}
+	return out
+}
+
+func TestAdPodAssembler_Separation(t *testing.T) {
+	brands := NewBrandRegistry()
+	brands.Register(Brand{ID: "coca-cola", Advertiser: "ko.example"})
+	brands.Register(Brand{ID: "sprite", Parent: "coca-cola"})
+	brands.Register(Brand{ID: "fanta", Parent: "coca-cola"})
+	brands.Register(Brand{ID: "pepsi", Advertiser: "pepsico.example"})
+
+	ads := []AdResponse{
+		{ID: "coke", BrandID: "Coca-Cola", AdvertiserID: "ko.example", Duration: 30, Price: 20, CategoryID: []string{"IAB8-5"}},
+		{ID: "sprite", BrandID: "sprite", AdvertiserID: "ko.example", Duration: 15, Price: 18, CategoryID: []string{"IAB8-5"}},
+		{ID: "pepsi", BrandID: "pepsi", AdvertiserID: "pepsico.example", Duration: 30, Price: 15, CategoryID: []string{"IAB8"}},
+		{ID: "car", BrandID: "roadster", AdvertiserID: "cars.example", Duration: 15, Price: 12, CategoryID: []string{"IAB2-3"}},
+		{ID: "fanta", BrandID: "fanta", Duration: 15, Price: 10, CategoryID: []string{"IAB8-5"}},
+	}
+	pods := []AdPodRequest{{ID: "mid-1", MaxDuration: 90, MaxAds: 4}, {ID: "mid-2", MaxDuration: 90, MaxAds: 4}}
+
+	tests := []struct {
+		name string
+		sep  PodSeparation
+		want [][]string
+	}{
+		{"none", PodSeparation{}, [][]string{{"coke", "sprite", "pepsi", "car"}, {"fanta"}}},
+		{"parent brand", PodSeparation{Level: SeparateParentBrand},
+			[][]string{{"coke", "pepsi", "car"}, {"sprite"}}},
+		{"advertiser", PodSeparation{Level: SeparateAdvertiser},
+			[][]string{{"coke", "pepsi", "car"}, {"sprite"}}},
+		{"two per parent brand", PodSeparation{Level: SeparateParentBrand, MaxPerPod: 2},
+			[][]string{{"coke", "sprite", "pepsi", "car"}, {"fanta"}}},
+		{"category", PodSeparation{Level: SeparateCategory},
+			[][]string{{"coke", "car"}, {"sprite"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &AdPodAssembler{MaxPodDuration: 120 * time.Second, Brands: brands}
+			a.SetPublisherSeparation("pub-1", tt.sep)
+			if got := podIDs(a.Assemble("pub-1", pods, ads)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pods = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Other publishers get the default, advertiser separation here
+	a := &AdPodAssembler{CompetitiveSeparation: true, Brands: brands}
+	a.SetPublisherSeparation("pub-1", PodSeparation{})
+	if got := a.SeparationFor("pub-2"); got.Level != SeparateAdvertiser || got.MaxPerPod != 1 {
+		t.Errorf("default separation = %+v", got)
+	}
+	if got := a.SeparationFor("pub-1"); got.Level != "" {
+		t.Errorf("pub-1 separation = %+v, want none", got)
+	}
+}
+
+func TestBrandRegistry(t *testing.T) {
+	brands := NewBrandRegistry()
+	brands.Register(Brand{ID: "coca-cola", Advertiser: "ko.example"})
+	brands.Register(Brand{ID: "sprite", Parent: "coca-cola"})
+	brands.Register(Brand{ID: "sprite-zero", Parent: "sprite"})
+
+	if got := brands.ParentBrand("Sprite-Zero"); got != "coca-cola" {
+		t.Errorf("ParentBrand = %q", got)
+	}
+	if got := brands.Advertiser("sprite-zero"); got != "ko.example" {
+		t.Errorf("Advertiser = %q", got)
+	}
+	if got := brands.ParentBrand("unknown"); got != "unknown" {
+		t.Errorf("unregistered ParentBrand = %q", got)
+	}
+}
+
+func TestAdPodAssembler_HouseBackfill(t *testing.T) {
+	house := NewHouseAdInventory()
+	house.Add("", AdResponse{ID: "promo-60", Duration: 60})
+	house.Add("", AdResponse{ID: "promo-15", Duration: 15})
+	house.Add("pub-1", AdResponse{ID: "own-30", Duration: 30, Price: 0.50})
+
+	a := &AdPodAssembler{MaxPodDuration: 120 * time.Second, MinPodDuration: 60 * time.Second, HouseAds: house}
+	a.SetPublisherBackfill("pub-1", true)
+	pods := []AdPodRequest{{ID: "mid-1", MaxDuration: 90, MinAds: 3, MaxAds: 4}}
+	paid := []AdResponse{{ID: "paid", Duration: 15, Price: 20}}
+
+	got := a.Assemble("pub-1", pods, paid)[0]
+	if ids := podIDs([]AdPodResponse{got})[0]; !reflect.DeepEqual(ids, []string{"paid", "own-30", "promo-15"}) {
+		t.Fatalf("pod = %v", ids)
+	}
+	if got.TotalDuration != 60 || got.HouseDuration != 45 || got.TotalPrice != 20 {
+		t.Errorf("pod = %ds, %ds house, $%.2f", got.TotalDuration, got.HouseDuration, got.TotalPrice)
+	}
+	for _, ad := range got.Ads {
+		if ad.House != (ad.ID != "paid") {
+			t.Errorf("%s tagged house = %v", ad.ID, ad.House)
+		}
+	}
+
+	// Full pods take no house ads
+	full := []AdResponse{{ID: "p1", Duration: 30, Price: 9}, {ID: "p2", Duration: 30, Price: 8}, {ID: "p3", Duration: 15, Price: 7}}
+	if got := a.Assemble("pub-1", pods, full)[0]; got.HouseDuration != 0 || len(got.Ads) != 3 {
+		t.Errorf("full pod = %v", podIDs([]AdPodResponse{got}))
+	}
+
+	// Publishers that haven't opted in keep short pods
+	if got := a.Assemble("pub-2", pods, paid)[0]; len(got.Ads) != 1 || got.TotalDuration != 15 {
+		t.Errorf("pub-2 pod = %v", podIDs([]AdPodResponse{got}))
+	}
+	a.SetPublisherBackfill("pub-1", false)
+	if got := a.Assemble("pub-1", pods, paid)[0]; len(got.Ads) != 1 {
+		t.Errorf("pod backfilled after opting out: %v", podIDs([]AdPodResponse{got}))
+	}
+}
@@ -0,0 +1,331 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// PrebidBidderCode is the bidder name Prebid Server uses for the exchange
+const PrebidBidderCode = "adx"
+
+// Exchange currency; bids and floors are settled in USD
+const exchangeCurrency = "USD"
+
+// DefaultPrebidMargin is held back from tmax for the network round trip
+const DefaultPrebidMargin = 10 * time.Millisecond
+
+var (
+	ErrUnsupportedCurrency = errors.New("no supported currency")
+	ErrBidderParams        = errors.New("invalid prebid bidder params")
+)
+
+// PrebidParams are the exchange's bidder params from imp.ext.bidder
+type PrebidParams struct {
+	PublisherID string   `json:"publisherId,omitempty"`
+	PlacementID string   `json:"placementId,omitempty"`
+	BidFloor    float64  `json:"bidFloor,omitempty"`
+	BidFloorCur string   `json:"bidFloorCur,omitempty"`
+	Deals       []string `json:"deals,omitempty"`
+}
+
+// PrebidBidExt is the bid.ext Prebid Server expects from a bidder
+type PrebidBidExt struct {
+	Prebid PrebidExt `json:"prebid"`
+}
+
+// PrebidExt carries the bid's media type and ad server targeting keys
+type PrebidExt struct {
+	Type      string            `json:"type"`
+	Targeting map[string]string `json:"targeting,omitempty"`
+}
+
+type prebidImpExt struct {
+	Bidder json.RawMessage `json:"bidder,omitempty"`
+	Prebid *struct {
+		Bidder map[string]json.RawMessage `json:"bidder,omitempty"`
+	} `json:"prebid,omitempty"`
+}
+
+// PrebidHandler serves the exchange as a Prebid Server bidder
+type PrebidHandler struct {
+	Exchange *RTBExchange
+
+	// Rates converts other currencies to USD, in units per USD. Requests
+	// that only accept, or floor in, an unlisted currency are rejected.
+	Rates map[string]float64
+
+	// Margin is subtracted from tmax; DefaultPrebidMargin when zero
+	Margin time.Duration
+}
+
+// NewPrebidHandler creates a Prebid bidder endpoint for exchange
+func NewPrebidHandler(exchange *RTBExchange) *PrebidHandler {
+	return &PrebidHandler{Exchange: exchange}
+}
+
+// ServeHTTP answers a Prebid Server bid request with a bid or 204
+func (h *PrebidHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req openrtb2.BidRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid bid request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.Bid(r.Context(), &req)
+	switch {
+	case errors.Is(err, ErrBidderParams), errors.Is(err, ErrUnsupportedCurrency):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrUnknownSeller), errors.Is(err, ErrUnauthorizedSeller):
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case len(resp.SeatBid) == 0:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Bid runs an auction for a Prebid request within its tmax and returns a
+// Prebid-shaped response
+func (h *PrebidHandler) Bid(ctx context.Context, req *openrtb2.BidRequest) (*openrtb2.BidResponse, error) {
+	if !h.acceptsCurrency(req.Cur) {
+		return nil, ErrUnsupportedCurrency
+	}
+	if err := h.applyParams(req); err != nil {
+		return nil, err
+	}
+
+	if req.TMax > 0 {
+		margin := h.Margin
+		if margin == 0 {
+			margin = DefaultPrebidMargin
+		}
+		budget := time.Duration(req.TMax)*time.Millisecond - margin
+		if budget <= 0 {
+			return &openrtb2.BidResponse{ID: req.ID}, nil
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+
+	resp, err := h.Exchange.BidRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Cur = exchangeCurrency
+	for i := range resp.SeatBid {
+		for j := range resp.SeatBid[i].Bid {
+			bid := &resp.SeatBid[i].Bid[j]
+			ext, err := json.Marshal(PrebidBidExt{Prebid: PrebidExt{
+				Type:      mediaType(req, bid.ImpID),
+				Targeting: targeting(req, bid),
+			}})
+			if err != nil {
+				return nil, err
+			}
+			bid.Ext = ext
+		}
+	}
+	return resp, nil
+}
+
+// applyParams folds each impression's bidder params into the request:
+// floors (converted to USD), placement tag and deals
+func (h *PrebidHandler) applyParams(req *openrtb2.BidRequest) error {
+	for i := range req.Imp {
+		imp := &req.Imp[i]
+
+		if imp.BidFloor > 0 {
+			floor, ok := h.toUSD(imp.BidFloor, imp.BidFloorCur)
+			if !ok {
+				return fmt.Errorf("%w: imp %s floor in %s", ErrUnsupportedCurrency, imp.ID, imp.BidFloorCur)
+			}
+			imp.BidFloor, imp.BidFloorCur = floor, exchangeCurrency
+		}
+
+		params, err := bidderParams(imp.Ext)
+		if err != nil {
+			return fmt.Errorf("%w: imp %s: %v", ErrBidderParams, imp.ID, err)
+		}
+		if params == nil {
+			continue
+		}
+
+		if params.BidFloor > 0 {
+			floor, ok := h.toUSD(params.BidFloor, params.BidFloorCur)
+			if !ok {
+				return fmt.Errorf("%w: imp %s floor in %s", ErrUnsupportedCurrency, imp.ID, params.BidFloorCur)
+			}
+			if floor > imp.BidFloor {
+				imp.BidFloor, imp.BidFloorCur = floor, exchangeCurrency
+			}
+		}
+		if imp.TagID == "" {
+			imp.TagID = params.PlacementID
+		}
+		if len(params.Deals) > 0 {
+			if imp.PMP == nil {
+				imp.PMP = &openrtb2.PMP{}
+			}
+			for _, id := range params.Deals {
+				if !hasDeal(imp.PMP.Deals, id) {
+					imp.PMP.Deals = append(imp.PMP.Deals, openrtb2.Deal{ID: id, BidFloor: imp.BidFloor, BidFloorCur: exchangeCurrency})
+				}
+			}
+		}
+		if params.PublisherID != "" {
+			setPublisher(req, params.PublisherID)
+		}
+	}
+	return nil
+}
+
+func (h *PrebidHandler) acceptsCurrency(cur []string) bool {
+	if len(cur) == 0 {
+		return true
+	}
+	for _, c := range cur {
+		if c == exchangeCurrency {
+			return true
+		}
+	}
+	return false
+}
+
+// toUSD converts amount in cur to USD; an empty currency is USD
+func (h *PrebidHandler) toUSD(amount float64, cur string) (float64, bool) {
+	if cur == "" || cur == exchangeCurrency {
+		return amount, true
+	}
+	rate, ok := h.Rates[cur]
+	if !ok || rate <= 0 {
+		return 0, false
+	}
+	return amount / rate, true
+}
+
+// bidderParams reads params from imp.ext.bidder, or from
+// imp.ext.prebid.bidder.adx when sent un-rewritten
+func bidderParams(ext json.RawMessage) (*PrebidParams, error) {
+	if len(ext) == 0 {
+		return nil, nil
+	}
+	var e prebidImpExt
+	if err := json.Unmarshal(ext, &e); err != nil {
+		return nil, err
+	}
+	raw := e.Bidder
+	if len(raw) == 0 && e.Prebid != nil {
+		raw = e.Prebid.Bidder[PrebidBidderCode]
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var p PrebidParams
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func hasDeal(deals []openrtb2.Deal, id string) bool {
+	for _, d := range deals {
+		if d.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func setPublisher(req *openrtb2.BidRequest, id string) {
+	switch {
+	case req.Site != nil:
+		if req.Site.Publisher == nil {
+			req.Site.Publisher = &openrtb2.Publisher{}
+		}
+		if req.Site.Publisher.ID == "" {
+			req.Site.Publisher.ID = id
+		}
+	case req.App != nil:
+		if req.App.Publisher == nil {
+			req.App.Publisher = &openrtb2.Publisher{}
+		}
+		if req.App.Publisher.ID == "" {
+			req.App.Publisher.ID = id
+		}
+	}
+}
+
+// mediaType is the Prebid bid type of the impression a bid is for
+func mediaType(req *openrtb2.BidRequest, impID string) string {
+	for _, imp := range req.Imp {
+		if imp.ID != impID {
+			continue
+		}
+		switch {
+		case imp.Video != nil:
+			return "video"
+		case imp.Native != nil:
+			return "native"
+		case imp.Audio != nil:
+			return "audio"
+		}
+	}
+	return "banner"
+}
+
+// targeting returns the hb_* ad server keys for a bid, using Prebid's
+// medium price granularity ($0.10 buckets, capped at $20)
+func targeting(req *openrtb2.BidRequest, bid *openrtb2.Bid) map[string]string {
+	keys := map[string]string{
+		"hb_bidder": PrebidBidderCode,
+		"hb_pb":     fmt.Sprintf("%.2f", math.Floor(math.Min(bid.Price, 20)*10)/10),
+	}
+	w, h := bid.W, bid.H
+	if w == 0 || h == 0 {
+		w, h = impSize(req, bid.ImpID)
+	}
+	if w > 0 && h > 0 {
+		keys["hb_size"] = fmt.Sprintf("%dx%d", w, h)
+	}
+	if bid.DealID != "" {
+		keys["hb_deal"] = bid.DealID
+	}
+	return keys
+}
+
+func impSize(req *openrtb2.BidRequest, impID string) (int64, int64) {
+	for _, imp := range req.Imp {
+		if imp.ID != impID {
+			continue
+		}
+		switch {
+		case imp.Video != nil && imp.Video.W != nil && imp.Video.H != nil:
+			return *imp.Video.W, *imp.Video.H
+		case imp.Banner != nil && imp.Banner.W != nil && imp.Banner.H != nil:
+			return *imp.Banner.W, *imp.Banner.H
+		case imp.Banner != nil && len(imp.Banner.Format) > 0:
+			return imp.Banner.Format[0].W, imp.Banner.Format[0].H
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,143 @@
+package rtb_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/luxfi/adx/pkg/rtb/rtbtest"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// prebidRequest is a bid request as Prebid Server sends it to a bidder
+const prebidRequest = `{
+	"id": "pbs-req-1",
+	"imp": [{
+		"id": "imp-video",
+		"video": {"mimes": ["video/mp4"], "w": 640, "h": 480, "minduration": 5, "maxduration": 30},
+		"bidfloor": 1.80,
+		"bidfloorcur": "EUR",
+		"ext": {"bidder": {"publisherId": "pub-42", "placementId": "preroll", "bidFloor": 2.5, "deals": ["deal-1"]}}
+	}],
+	"site": {"page": "https://news.example/story", "publisher": {}},
+	"cur": ["EUR", "USD"],
+	"tmax": %d
+}`
+
+func newPrebidHandler(t *testing.T, auctionTimeout time.Duration) (*rtb.PrebidHandler, []*rtbtest.MockDSP) {
+	exchange := &rtb.RTBExchange{
+		AuctionTimeout: auctionTimeout,
+		Revenue:        big.NewInt(0),
+	}
+	dsps := rtbtest.StartMockDSPs(t, exchange, 2)
+	h := rtb.NewPrebidHandler(exchange)
+	h.Rates = map[string]float64{"EUR": 0.9}
+	return h, dsps
+}
+
+func postPrebid(h http.Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/prebid/bid", bytes.NewBufferString(body)))
+	return w
+}
+
+func TestPrebid_ValidResponse(t *testing.T) {
+	h, dsps := newPrebidHandler(t, 200*time.Millisecond)
+	dsps[0].Set(rtbtest.WithFixedPrice(2.40)) // Under the bidder floor
+	dsps[1].Set(rtbtest.WithFixedPrice(3.27))
+
+	w := postPrebid(h, fmt.Sprintf(prebidRequest, 500))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var resp openrtb2.BidResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "pbs-req-1" || resp.Cur != "USD" {
+		t.Errorf("id = %q cur = %q", resp.ID, resp.Cur)
+	}
+	if len(resp.SeatBid) != 1 || len(resp.SeatBid[0].Bid) != 1 {
+		t.Fatalf("seatbid = %+v", resp.SeatBid)
+	}
+	bid := resp.SeatBid[0].Bid[0]
+	if resp.SeatBid[0].Seat != "seat-dsp-2" || bid.Price != 3.27 || bid.ImpID != "imp-video" {
+		t.Errorf("winner = %s %+v", resp.SeatBid[0].Seat, bid)
+	}
+
+	var ext rtb.PrebidBidExt
+	if err := json.Unmarshal(bid.Ext, &ext); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"hb_bidder": "adx", "hb_pb": "3.20", "hb_size": "640x480"}
+	if ext.Prebid.Type != "video" {
+		t.Errorf("type = %q, want video", ext.Prebid.Type)
+	}
+	for k, v := range want {
+		if ext.Prebid.Targeting[k] != v {
+			t.Errorf("targeting %s = %q, want %q", k, ext.Prebid.Targeting[k], v)
+		}
+	}
+
+	// Bidder params reached the DSPs: floor (in USD), tag, deal, publisher
+	sent := dsps[0].Requests()[0]
+	imp := sent.Imp[0]
+	if imp.BidFloor != 2.5 || imp.BidFloorCur != "USD" || imp.TagID != "preroll" {
+		t.Errorf("imp floor = %v %s tag = %q", imp.BidFloor, imp.BidFloorCur, imp.TagID)
+	}
+	if imp.PMP == nil || len(imp.PMP.Deals) != 1 || imp.PMP.Deals[0].ID != "deal-1" {
+		t.Errorf("imp pmp = %+v", imp.PMP)
+	}
+	if sent.Site.Publisher.ID != "pub-42" {
+		t.Errorf("publisher = %q", sent.Site.Publisher.ID)
+	}
+}
+
+func TestPrebid_HonorsTMax(t *testing.T) {
+	h, dsps := newPrebidHandler(t, time.Second)
+	dsps[0].Set(rtbtest.WithFixedPrice(9), rtbtest.WithLatency(500*time.Millisecond))
+	dsps[1].Set(rtbtest.WithFixedPrice(3))
+
+	start := time.Now()
+	w := postPrebid(h, fmt.Sprintf(prebidRequest, 100))
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("answered after %v, tmax was 100ms", elapsed)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp openrtb2.BidResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.SeatBid[0].Seat != "seat-dsp-2" {
+		t.Errorf("winner = %s, want the DSP that answered within tmax", resp.SeatBid[0].Seat)
+	}
+}
+
+func TestPrebid_Rejects(t *testing.T) {
+	h, _ := newPrebidHandler(t, 100*time.Millisecond)
+
+	// No bids is a 204
+	if w := postPrebid(h, fmt.Sprintf(prebidRequest, 200)); w.Code != http.StatusNoContent {
+		t.Errorf("no-bid status = %d, want 204", w.Code)
+	}
+
+	h.Rates = nil
+	if w := postPrebid(h, fmt.Sprintf(prebidRequest, 200)); w.Code != http.StatusBadRequest {
+		t.Errorf("unconvertible floor status = %d, want 400", w.Code)
+	}
+	if w := postPrebid(h, `{"id":"x","imp":[{"id":"1"}],"cur":["JPY"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unsupported currency status = %d, want 400", w.Code)
+	}
+	if w := postPrebid(h, `{"id":"x","imp":[{"id":"1","ext":{"bidder":"oops"}}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bad params status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,203 @@
+package rtb
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+var (
+	ErrCreativeQuality = errors.New("creative below quality threshold")
+	ErrBlockedDomain   = errors.New("creative uses a blocked domain")
+	ErrBlockedAttr     = errors.New("creative has a disallowed attribute")
+)
+
+// DefaultBlockedAttrs are creative attributes no placement accepts:
+// autoplay audio, pop-ups, flashing images, dialog boxes and Flash
+var DefaultBlockedAttrs = []adcom1.CreativeAttribute{
+	adcom1.AttrAudioAuto,
+	adcom1.AttrPop,
+	adcom1.AttrExtremeAnimation,
+	adcom1.AttrWindowsDialog,
+	adcom1.AttrFlash,
+}
+
+// QualityRules are the creative requirements for a publisher's inventory
+type QualityRules struct {
+	MinBitrate int      // bits per second, per media file
+	MinWidth   int64    // pixels
+	MinHeight  int64    // pixels
+	Formats    []string // Accepted media MIME types; any when empty
+
+	// MinScore is the share of a video's media files that must meet the
+	// bitrate, size and format rules; at least one must when zero
+	MinScore float64
+
+	BlockedDomains []string // Matched against click-through, media and advertiser domains, with subdomains
+	BlockedAttrs   []adcom1.CreativeAttribute
+}
+
+// QualityRules returns the optimizer's creative thresholds as gate rules
+func (opt *CTVOptimizer) QualityRules() QualityRules {
+	return QualityRules{
+		MinBitrate:   opt.MinVideoBitrate,
+		Formats:      opt.RequiredFormats,
+		MinScore:     opt.CreativeQualityThreshold,
+		BlockedAttrs: DefaultBlockedAttrs,
+	}
+}
+
+// QualityGate checks winning creatives before they are served. Rules are
+// per publisher, falling back to Default.
+type QualityGate struct {
+	Default QualityRules
+
+	mu         sync.RWMutex
+	publishers map[string]QualityRules
+}
+
+// NewQualityGate creates a gate applying def to every publisher
+func NewQualityGate(def QualityRules) *QualityGate {
+	return &QualityGate{Default: def, publishers: make(map[string]QualityRules)}
+}
+
+// SetPublisherRules overrides the rules for one publisher
+func (g *QualityGate) SetPublisherRules(publisherID string, rules QualityRules) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.publishers[publisherID] = rules
+}
+
+// RulesFor returns the rules a publisher's inventory is held to
+func (g *QualityGate) RulesFor(publisherID string) QualityRules {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if rules, ok := g.publishers[publisherID]; ok {
+		return rules
+	}
+	return g.Default
+}
+
+// Check reports why bid's creative may not run on req's inventory, or nil
+func (g *QualityGate) Check(req *openrtb2.BidRequest, bid *Bid) error {
+	publisherID, _, _ := publisherOf(req)
+	return g.RulesFor(publisherID).Check(bid)
+}
+
+// Check applies the rules to a bid's declared attributes and, for VAST
+// markup, its media files and click-through
+func (r QualityRules) Check(bid *Bid) error {
+	for _, attr := range bid.Attr {
+		for _, blocked := range r.BlockedAttrs {
+			if attr == blocked {
+				return fmt.Errorf("%w: %d", ErrBlockedAttr, attr)
+			}
+		}
+	}
+
+	markup := parseVASTMarkup(bid.Creative)
+	domains := []string{bid.Advertiser}
+	for _, m := range markup.MediaFiles {
+		domains = append(domains, hostOf(m.URL))
+	}
+	for _, c := range markup.ClickThroughs {
+		domains = append(domains, hostOf(c))
+	}
+	for _, d := range domains {
+		if blocked := r.blockedDomain(d); blocked != "" {
+			return fmt.Errorf("%w: %s", ErrBlockedDomain, blocked)
+		}
+	}
+
+	if len(markup.MediaFiles) == 0 {
+		// Display and native creatives only declare their size
+		if (r.MinWidth > 0 && bid.W > 0 && bid.W < r.MinWidth) || (r.MinHeight > 0 && bid.H > 0 && bid.H < r.MinHeight) {
+			return fmt.Errorf("%w: %dx%d", ErrCreativeQuality, bid.W, bid.H)
+		}
+		return nil
+	}
+
+	passing := 0
+	for _, m := range markup.MediaFiles {
+		if r.accepts(m) {
+			passing++
+		}
+	}
+	score := float64(passing) / float64(len(markup.MediaFiles))
+	if passing == 0 || score < r.MinScore {
+		return fmt.Errorf("%w: %d of %d media files acceptable", ErrCreativeQuality, passing, len(markup.MediaFiles))
+	}
+	return nil
+}
+
+func (r QualityRules) accepts(m mediaFile) bool {
+	// VAST declares bitrate in kbps
+	if r.MinBitrate > 0 && m.Bitrate*1000 < r.MinBitrate {
+		return false
+	}
+	if (r.MinWidth > 0 && m.Width < r.MinWidth) || (r.MinHeight > 0 && m.Height < r.MinHeight) {
+		return false
+	}
+	if len(r.Formats) == 0 {
+		return true
+	}
+	for _, f := range r.Formats {
+		if strings.EqualFold(f, m.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r QualityRules) blockedDomain(host string) string {
+	host = strings.ToLower(host)
+	if host == "" {
+		return ""
+	}
+	for _, d := range r.BlockedDomains {
+		d = strings.ToLower(d)
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d
+		}
+	}
+	return ""
+}
+
+type mediaFile struct {
+	Type     string `xml:"type,attr"`
+	Width    int64  `xml:"width,attr"`
+	Height   int64  `xml:"height,attr"`
+	Bitrate  int    `xml:"bitrate,attr"`
+	FileSize int64  `xml:"fileSize,attr"` // Bytes, declared since VAST 4.1
+	URL      string `xml:",chardata"`
+}
+
+// vastMarkup is the part of a VAST document the quality gate and
+// creative policy scan
+type vastMarkup struct {
+	MediaFiles    []mediaFile `xml:"Ad>InLine>Creatives>Creative>Linear>MediaFiles>MediaFile"`
+	ClickThroughs []string    `xml:"Ad>InLine>Creatives>Creative>Linear>VideoClicks>ClickThrough"`
+	Durations     []string    `xml:"Ad>InLine>Creatives>Creative>Linear>Duration"`
+}
+
+func parseVASTMarkup(adm string) vastMarkup {
+	var m vastMarkup
+	if strings.HasPrefix(strings.TrimSpace(adm), "<") {
+		xml.Unmarshal([]byte(adm), &m)
+	}
+	return m
+}
+
+func hostOf(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
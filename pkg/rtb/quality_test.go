@@ -0,0 +1,92 @@
+package rtb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/luxfi/adx/pkg/rtb/rtbtest"
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// videoAdM is VAST markup with one media file at the given bitrate (kbps)
+func videoAdM(bitrate int, media, click string) string {
+	return fmt.Sprintf(`<VAST version="4.0"><Ad id="1"><InLine><Creatives><Creative><Linear>
+<VideoClicks><ClickThrough><![CDATA[%s]]></ClickThrough></VideoClicks>
+<MediaFiles><MediaFile delivery="progressive" type="video/mp4" width="1920" height="1080" bitrate="%d"><![CDATA[%s]]></MediaFile></MediaFiles>
+</Linear></Creative></Creatives></InLine></Ad></VAST>`, click, bitrate, media)
+}
+
+func ctvRequest(publisherID string) *openrtb2.BidRequest {
+	return &openrtb2.BidRequest{
+		ID:  "ctv-1",
+		Imp: []openrtb2.Imp{{ID: "1"}},
+		App: &openrtb2.App{Publisher: &openrtb2.Publisher{ID: publisherID}},
+	}
+}
+
+func TestQualityGate_FallsThroughToRunnerUp(t *testing.T) {
+	exchange := &rtb.RTBExchange{
+		AuctionTimeout: 200 * time.Millisecond,
+		Revenue:        big.NewInt(0),
+		CTVOptimizer:   &rtb.CTVOptimizer{MinVideoBitrate: 2000000, CreativeQualityThreshold: 0.7},
+	}
+	dsps := rtbtest.StartMockDSPs(t, exchange, 2)
+	dsps[0].Set(rtbtest.WithFixedPrice(9), rtbtest.WithAdM(videoAdM(800, "https://cdn.dsp1.example/low.mp4", "https://brand.example")))
+	dsps[1].Set(rtbtest.WithFixedPrice(4), rtbtest.WithAdM(videoAdM(4500, "https://cdn.dsp2.example/hd.mp4", "https://brand.example")))
+
+	resp, err := exchange.BidRequest(context.Background(), ctvRequest("pub-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.SeatBid) != 1 || resp.SeatBid[0].Seat != "seat-dsp-2" || resp.SeatBid[0].Bid[0].Price != 4 {
+		t.Errorf("response = %+v, want the compliant runner-up seat-dsp-2 at 4", resp.SeatBid)
+	}
+
+	// With no compliant bid there is no fill
+	dsps[1].Set(rtbtest.WithAdM(videoAdM(500, "https://cdn.dsp2.example/low.mp4", "https://brand.example")))
+	resp, err = exchange.BidRequest(context.Background(), ctvRequest("pub-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.SeatBid) != 0 {
+		t.Errorf("response = %+v, want no fill", resp.SeatBid)
+	}
+}
+
+func TestQualityGate_PublisherRules(t *testing.T) {
+	gate := rtb.NewQualityGate(rtb.QualityRules{BlockedAttrs: rtb.DefaultBlockedAttrs})
+	gate.SetPublisherRules("strict", rtb.QualityRules{
+		MinBitrate:     3000000,
+		BlockedDomains: []string{"malware.example"},
+		BlockedAttrs:   rtb.DefaultBlockedAttrs,
+	})
+
+	hd := &rtb.Bid{Creative: videoAdM(4000, "https://cdn.ok.example/a.mp4", "https://brand.example")}
+	sd := &rtb.Bid{Creative: videoAdM(1500, "https://cdn.ok.example/a.mp4", "https://brand.example")}
+	badClick := &rtb.Bid{Creative: videoAdM(4000, "https://cdn.ok.example/a.mp4", "https://go.malware.example/x")}
+	autoplay := &rtb.Bid{Attr: []adcom1.CreativeAttribute{adcom1.AttrAudioAuto}}
+
+	for _, tt := range []struct {
+		publisher string
+		bid       *rtb.Bid
+		want      error
+	}{
+		{"strict", hd, nil},
+		{"strict", sd, rtb.ErrCreativeQuality},
+		{"strict", badClick, rtb.ErrBlockedDomain},
+		{"strict", autoplay, rtb.ErrBlockedAttr},
+		{"lenient", sd, nil},
+		{"lenient", badClick, nil},
+		{"lenient", autoplay, rtb.ErrBlockedAttr},
+	} {
+		if err := gate.Check(ctvRequest(tt.publisher), tt.bid); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.publisher, err, tt.want)
+		}
+	}
+}
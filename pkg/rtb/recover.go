@@ -0,0 +1,47 @@
+package rtb
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/luxfi/adx/pkg/reqlog"
+)
+
+// ErrBidPanic is a bid dropped because processing it panicked
+var ErrBidPanic = errors.New("bid processing panicked")
+
+// guard runs f on bid's behalf, turning a panic into an ErrBidPanic so a
+// malformed bid is dropped rather than failing the auction
+func (rtb *RTBExchange) guard(bid *Bid, f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&rtb.PanicCount, 1)
+			slog.Error("bid dropped after panic", reqlog.KeyDSP, bid.DSP, "bid", bid.ID, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("%w: %v", ErrBidPanic, r)
+		}
+	}()
+	return f()
+}
+
+// recoverSend turns a panic in one of a DSP's hedged requests into an
+// ErrBidPanic in *err. Each runs in a goroutine of its own, out of reach of
+// recoverDSP, and sendBid is still waiting on its answer.
+func (d *DSPConnection) recoverSend(err *error) {
+	if r := recover(); r != nil {
+		slog.Error("dsp bid request panicked", reqlog.KeyDSP, d.ID, "panic", r, "stack", string(debug.Stack()))
+		*err = fmt.Errorf("%w: %v", ErrBidPanic, r)
+	}
+}
+
+// recoverDSP ends a DSP's bid request that panicked as if it had failed;
+// deferred by its goroutine, which would otherwise take down the process
+func (rtb *RTBExchange) recoverDSP(d *DSPConnection) {
+	if r := recover(); r != nil {
+		atomic.AddUint64(&rtb.PanicCount, 1)
+		atomic.AddUint64(&d.ErrorCount, 1)
+		slog.Error("dsp bid request panicked", reqlog.KeyDSP, d.ID, "panic", r, "stack", string(debug.Stack()))
+	}
+}
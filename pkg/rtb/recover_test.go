@@ -0,0 +1,110 @@
+package rtb
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+)
+
+// panickyCounter panics on one campaign, as a malformed bid might
+type panickyCounter string
+
+func (c panickyCounter) CheckFrequencyCap(user, campaign string, max int) (bool, error) {
+	if campaign == string(c) {
+		panic("malformed campaign " + campaign)
+	}
+	return true, nil
+}
+
+// panickyTransport panics on every request, as a broken client might
+type panickyTransport struct{}
+
+func (panickyTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	panic("broken transport")
+}
+
+func TestBidRequest_PanickingDSP(t *testing.T) {
+	tests := []struct {
+		name     string
+		breakDSP func(d *DSPConnection)
+	}{
+		{"rate limiter", func(d *DSPConnection) {
+			d.RateLimiter = nil // Panics on first use
+		}},
+		// Hedged requests run in goroutines of their own
+		{"hedged request", func(d *DSPConnection) {
+			d.Client = &http.Client{Transport: panickyTransport{}}
+			d.Hedge = &HedgePolicy{After: 0.1, MaxRatio: 1}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broken := noticeDSP(t, "", "broken", 9)
+			tt.breakDSP(broken)
+			exchange := &RTBExchange{
+				DSPs: map[string]*DSPConnection{
+					"good":   noticeDSP(t, "", "good", 2),
+					"broken": broken,
+				},
+				AuctionTimeout: time.Second,
+				Revenue:        big.NewInt(0),
+			}
+			req := &openrtb2.BidRequest{ID: "auc-1", Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}}
+			resp, err := exchange.BidRequest(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.SeatBid) != 1 || resp.SeatBid[0].Bid[0].ID != "bid-good" {
+				t.Fatalf("response = %+v, want the good DSP's bid", resp)
+			}
+			if n := atomic.LoadUint64(&exchange.PanicCount); n != 1 {
+				t.Errorf("panics = %d, want 1", n)
+			}
+			if n := atomic.LoadUint64(&broken.ErrorCount); n != 1 {
+				t.Errorf("broken DSP errors = %d, want 1", n)
+			}
+		})
+	}
+}
+
+func TestAuction_PanickingBid(t *testing.T) {
+	exchange := &RTBExchange{
+		Frequency:    panickyCounter("bad"),
+		FrequencyCap: 3,
+	}
+	req := &openrtb2.BidRequest{
+		ID:   "auc-1",
+		Imp:  []openrtb2.Imp{{ID: "1"}},
+		User: &openrtb2.User{ID: "u1"},
+	}
+	bids := []Bid{
+		{ID: "malformed", DSP: "dsp1", ImpID: "1", Price: 5, CampaignID: "bad"},
+		{ID: "fine", DSP: "dsp2", ImpID: "1", Price: 3, CampaignID: "good"},
+	}
+	winner, rejected := exchange.auction(context.Background(), bids, req)
+	if winner == nil || winner.ID != "fine" {
+		t.Fatalf("winner = %+v, want the next-best bid", winner)
+	}
+	if err := rejected[&bids[0]]; !errors.Is(err, ErrBidPanic) {
+		t.Errorf("malformed bid rejected with %v", err)
+	}
+	if n := atomic.LoadUint64(&exchange.PanicCount); n != 1 {
+		t.Errorf("panics = %d, want 1", n)
+	}
+
+	losses := exchange.losses(req, bids, winner, rejected)
+	if len(losses) != 1 || losses[0].Reason != openrtb3.LossInternalError {
+		t.Errorf("losses = %+v, want an internal error", losses)
+	}
+	audit := exchange.audit(req, bids, winner, rejected)
+	if b := audit.Bids[0]; b.Outcome != OutcomeRejected || b.LossReason != openrtb3.LossInternalError {
+		t.Errorf("audited %+v", b)
+	}
+}
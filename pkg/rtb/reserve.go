@@ -0,0 +1,266 @@
+package rtb
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	metrics "github.com/luxfi/metric"
+)
+
+// ReserveConfig bounds and paces the reserve optimizer
+type ReserveConfig struct {
+	Min     float64 // Lowest reserve any slot may get, CPM
+	Max     float64 // Highest reserve any slot may get, CPM
+	Initial float64 // Reserve for slots seen for the first time, CPM
+
+	// Step is the largest fractional move of a reserve per step. It halves
+	// each time the search turns around, down to MinStep.
+	Step    float64
+	MinStep float64
+
+	// MinSamples is how many auctions each of the incumbent and candidate
+	// reserves needs before a slot steps
+	MinSamples int
+
+	// MinFillRate rejects candidates that would fill less often than this,
+	// and turns the search down when the incumbent does, so revenue isn't
+	// bought by starving fill
+	MinFillRate float64
+}
+
+// DefaultReserveConfig moves reserves at most 10% per step
+var DefaultReserveConfig = ReserveConfig{
+	Min:         0.10,
+	Max:         50,
+	Initial:     1,
+	Step:        0.10,
+	MinStep:     0.01,
+	MinSamples:  500,
+	MinFillRate: 0.5,
+}
+
+// ReserveOptimizer tunes per-slot reserves to maximize revenue by hill
+// climbing. Each slot has an incumbent reserve and a candidate one step
+// above or below it; auctions are split between the two by auction ID, and
+// once both have enough samples the candidate replaces the incumbent if it
+// earned more per auction. When it didn't, the search turns around.
+type ReserveOptimizer struct {
+	mu     sync.Mutex
+	config ReserveConfig
+	slots  map[string]*slotReserve
+
+	// Gauges, if set, receive each slot's reserve and its revenue per
+	// auction relative to the initial reserve's, labelled by placement
+	ReserveGauge metrics.GaugeVec
+	DeltaGauge   metrics.GaugeVec
+}
+
+type slotReserve struct {
+	reserve   float64
+	direction float64 // +1 or -1
+	step      float64
+	moved     bool // Moved since the search last turned around
+
+	incumbent, candidate reserveArm
+	baseline             float64 // Revenue per auction at the initial reserve
+	revenue              float64 // Revenue per auction at the current reserve
+	fillRate             float64
+}
+
+type reserveArm struct {
+	auctions, fills int
+	revenue         float64
+}
+
+func (a reserveArm) perAuction() float64 {
+	if a.auctions == 0 {
+		return 0
+	}
+	return a.revenue / float64(a.auctions)
+}
+
+func (a reserveArm) fillRate() float64 {
+	if a.auctions == 0 {
+		return 0
+	}
+	return float64(a.fills) / float64(a.auctions)
+}
+
+// NewReserveOptimizer creates an optimizer with the given config
+func NewReserveOptimizer(config ReserveConfig) *ReserveOptimizer {
+	if config.Step <= 0 {
+		config.Step = DefaultReserveConfig.Step
+	}
+	if config.MinStep <= 0 || config.MinStep > config.Step {
+		config.MinStep = math.Min(DefaultReserveConfig.MinStep, config.Step)
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = DefaultReserveConfig.MinSamples
+	}
+	if config.Max <= 0 {
+		config.Max = math.Inf(1)
+	}
+	return &ReserveOptimizer{config: config, slots: make(map[string]*slotReserve)}
+}
+
+// Reserve returns the reserve for an auction on placement, starting the
+// slot at the initial reserve if it's new
+func (o *ReserveOptimizer) Reserve(placement, auctionID string) float64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s := o.slots[placement]
+	if s == nil {
+		s = &slotReserve{reserve: o.clamp(o.config.Initial), direction: 1, step: o.config.Step}
+		o.slots[placement] = s
+	}
+	if candidateArm(auctionID) {
+		return o.candidate(s)
+	}
+	return s.reserve
+}
+
+// Record reports an auction's outcome: the clearing price, or 0 for no fill
+func (o *ReserveOptimizer) Record(placement, auctionID string, price float64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s := o.slots[placement]
+	if s == nil {
+		return
+	}
+	arm := &s.incumbent
+	if candidateArm(auctionID) {
+		arm = &s.candidate
+	}
+	arm.auctions++
+	if price > 0 {
+		arm.fills++
+		arm.revenue += price
+	}
+}
+
+// Step moves every slot with enough samples one step and reports whether
+// any moved
+func (o *ReserveOptimizer) Step() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	moved := false
+	for placement, s := range o.slots {
+		if s.incumbent.auctions < o.config.MinSamples || s.candidate.auctions < o.config.MinSamples {
+			continue
+		}
+		incumbent, candidate := s.incumbent.perAuction(), s.candidate.perAuction()
+		s.revenue, s.fillRate = incumbent, s.incumbent.fillRate()
+		if s.baseline == 0 {
+			s.baseline = incumbent
+		}
+
+		next := o.candidate(s)
+		switch {
+		case s.fillRate < o.config.MinFillRate && s.direction > 0:
+			// Starving fill; search down
+			o.turn(s)
+		case next != s.reserve && candidate > incumbent && s.candidate.fillRate() >= o.config.MinFillRate:
+			s.reserve = next
+			s.revenue, s.fillRate = candidate, s.candidate.fillRate()
+			s.moved = true
+			moved = true
+		default:
+			o.turn(s)
+		}
+		s.incumbent, s.candidate = reserveArm{}, reserveArm{}
+
+		if o.ReserveGauge != nil {
+			o.ReserveGauge.WithLabelValues(placement).Set(s.reserve)
+		}
+		if o.DeltaGauge != nil {
+			o.DeltaGauge.WithLabelValues(placement).Set(s.delta())
+		}
+	}
+	return moved
+}
+
+// turn reverses a slot's search direction, halving the step if it had
+// moved the other way since the last turn
+func (o *ReserveOptimizer) turn(s *slotReserve) {
+	s.direction = -s.direction
+	if s.moved {
+		s.step = math.Max(s.step/2, o.config.MinStep)
+	}
+	s.moved = false
+}
+
+// candidate is the reserve one step from the incumbent
+func (o *ReserveOptimizer) candidate(s *slotReserve) float64 {
+	return o.clamp(s.reserve * (1 + s.direction*s.step))
+}
+
+func (o *ReserveOptimizer) clamp(r float64) float64 {
+	return math.Min(math.Max(r, o.config.Min), o.config.Max)
+}
+
+// delta is revenue per auction relative to the initial reserve's
+func (s *slotReserve) delta() float64 {
+	if s.baseline == 0 {
+		return 0
+	}
+	return s.revenue/s.baseline - 1
+}
+
+// candidateArm puts half of auctions, by ID, on the candidate reserve
+func candidateArm(auctionID string) bool {
+	h := fnv.New32a()
+	h.Write([]byte(auctionID))
+	return h.Sum32()&1 == 1
+}
+
+// SlotReserve is a slot's optimized reserve
+type SlotReserve struct {
+	Placement string  `json:"placement"`
+	Reserve   float64 `json:"reserve"`
+	Step      float64 `json:"step"`
+	FillRate  float64 `json:"fill_rate"`     // At the reserve, as of the last step
+	Revenue   float64 `json:"revenue"`       // CPM per auction at the reserve, as of the last step
+	Delta     float64 `json:"revenue_delta"` // Revenue relative to the initial reserve's
+}
+
+// Reserves returns every slot's current reserve, by placement
+func (o *ReserveOptimizer) Reserves() []SlotReserve {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	out := make([]SlotReserve, 0, len(o.slots))
+	for placement, s := range o.slots {
+		out = append(out, SlotReserve{
+			Placement: placement,
+			Reserve:   s.reserve,
+			Step:      s.step,
+			FillRate:  s.fillRate,
+			Revenue:   s.revenue,
+			Delta:     s.delta(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Placement < out[j].Placement })
+	return out
+}
+
+// Run steps every interval until ctx is cancelled
+func (o *ReserveOptimizer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.Step()
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package rtb
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// simulateReserves runs steps of auctions on one placement whose single
+// bidder values impressions uniformly on [0, 10] CPM and pays the reserve
+// when it clears. Revenue per auction is r(1 - r/10), peaking at r = 5.
+func simulateReserves(t *testing.T, o *ReserveOptimizer, steps, auctions int) []float64 {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	var path []float64
+	n := 0
+	for step := 0; step < steps; step++ {
+		for i := 0; i < auctions; i++ {
+			id := fmt.Sprintf("auction-%d", n)
+			n++
+			reserve := o.Reserve("slot", id)
+			var price float64
+			if rng.Float64()*10 >= reserve {
+				price = reserve
+			}
+			o.Record("slot", id, price)
+		}
+		o.Step()
+		path = append(path, o.Reserves()[0].Reserve)
+	}
+	return path
+}
+
+func TestReserveOptimizer_Converges(t *testing.T) {
+	o := NewReserveOptimizer(ReserveConfig{Min: 0.1, Max: 20, Initial: 2, Step: 0.1, MinStep: 0.02, MinSamples: 2000})
+	path := simulateReserves(t, o, 60, 8000)
+
+	prev := 2.0
+	for i, r := range path {
+		if math.Abs(r-prev) > prev*0.1+1e-9 {
+			t.Fatalf("step %d moved reserve %.3f -> %.3f, more than 10%%", i, prev, r)
+		}
+		prev = r
+	}
+	got := o.Reserves()[0]
+	if got.Reserve < 4.25 || got.Reserve > 5.75 {
+		t.Errorf("reserve converged to %.3f, want about 5 (path %.2f)", got.Reserve, path)
+	}
+	// r(1 - r/10) at 5 is 2.5 against 1.6 at 2
+	if got.Delta < 0.4 {
+		t.Errorf("revenue delta = %.3f, want about 0.56", got.Delta)
+	}
+}
+
+func TestReserveOptimizer_FillGuard(t *testing.T) {
+	// Filling at least 60% caps the reserve at 4
+	o := NewReserveOptimizer(ReserveConfig{Min: 0.1, Max: 20, Initial: 2, Step: 0.1, MinStep: 0.02, MinSamples: 2000, MinFillRate: 0.6})
+	simulateReserves(t, o, 60, 8000)
+
+	got := o.Reserves()[0]
+	if got.Reserve > 4.2 || got.Reserve < 3 {
+		t.Errorf("reserve = %.3f, want just under 4", got.Reserve)
+	}
+}
+
+func TestReserveOptimizer_Bounds(t *testing.T) {
+	o := NewReserveOptimizer(ReserveConfig{Min: 0.1, Max: 3, Initial: 2, Step: 0.1, MinSamples: 2000})
+	simulateReserves(t, o, 30, 8000)
+
+	if got := o.Reserves()[0].Reserve; got > 3 || got < 2.7 {
+		t.Errorf("reserve = %.3f, want it held near the bound 3", got)
+	}
+}
+
+func TestReserveOptimizer_WaitsForSamples(t *testing.T) {
+	o := NewReserveOptimizer(ReserveConfig{Initial: 2, MinSamples: 100})
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprint(i)
+		o.Record("slot", id, o.Reserve("slot", id))
+	}
+	if o.Step() {
+		t.Error("stepped before either reserve had enough samples")
+	}
+	// Auctions on placements the optimizer hasn't priced are ignored
+	o.Record("other", "x", 5)
+	if got := len(o.Reserves()); got != 1 {
+		t.Errorf("%d slots, want 1", got)
+	}
+}
+
+func TestFloorRules_Optimized(t *testing.T) {
+	rules := NewFloorRules(0.5, 100)
+	rules.Optimizer = NewReserveOptimizer(ReserveConfig{Initial: 3})
+	rules.AddRule(FloorRule{Name: "optimized", Placements: []string{"slot"}, Floor: 1, Optimized: true})
+
+	// Incumbent and candidate reserves, by auction
+	seen := map[float64]bool{}
+	for i := 0; i < 20; i++ {
+		seen[rules.Floor(FloorContext{Placement: "slot", AuctionID: fmt.Sprint(i)})] = true
+	}
+	if len(seen) != 2 || !seen[3] || !seen[3.3000000000000003] {
+		t.Errorf("floors = %v, want the reserve 3 and its candidate 3.3", seen)
+	}
+	if got := rules.Floor(FloorContext{Placement: "other"}); got != 0.5 {
+		t.Errorf("unoptimized placement floor = %v, want default 0.5", got)
+	}
+
+	// Every imp's outcome is recorded: the winner's price, or no fill
+	req := &openrtb2.BidRequest{ID: "a1", Imp: []openrtb2.Imp{{ID: "1", TagID: "slot"}, {ID: "2", TagID: "slot"}}}
+	rules.recordReserves(req, &Bid{ImpID: "1", Price: 4})
+	s := rules.Optimizer.slots["slot"]
+	arm := s.incumbent
+	if candidateArm("a1") {
+		arm = s.candidate
+	}
+	if arm.auctions != 2 || arm.fills != 1 || arm.revenue != 4 {
+		t.Errorf("recorded %+v, want 2 auctions, 1 fill, revenue 4", arm)
+	}
+}
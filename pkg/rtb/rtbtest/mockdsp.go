@@ -0,0 +1,279 @@
+// Package rtbtest provides in-memory OpenRTB DSPs for exercising an
+// rtb.RTBExchange end to end.
+package rtbtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// BidMode selects how a MockDSP prices its bids
+type BidMode int
+
+const (
+	NoBid       BidMode = iota // Always answer 204
+	FixedPrice                 // One price on every impression
+	PerImpPrice                // A price per impression ID; no bid on unlisted impressions
+	RandomPrice                // Uniform in a range, from a seeded source
+	HashedPrice                // Uniform in a range, derived from the request and impression IDs
+)
+
+// Option configures a MockDSP
+type Option func(*MockDSP)
+
+// WithFixedPrice bids price on every impression
+func WithFixedPrice(price float64) Option {
+	return func(m *MockDSP) {
+		m.mode, m.price = FixedPrice, price
+	}
+}
+
+// WithImpPrices bids the listed price per impression ID
+func WithImpPrices(prices map[string]float64) Option {
+	return func(m *MockDSP) {
+		m.mode, m.impPrices = PerImpPrice, prices
+	}
+}
+
+// WithRandomPrice bids in [min, max), reproducibly for a given seed
+func WithRandomPrice(min, max float64, seed int64) Option {
+	return func(m *MockDSP) {
+		m.mode, m.min, m.max = RandomPrice, min, max
+		m.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithHashedPrice bids in [min, max) on a fill share of impressions. Each
+// decision is a function of seed and the request and impression IDs, so a
+// replayed stream gets the same answers whatever order requests arrive in.
+func WithHashedPrice(min, max, fill float64, seed int64) Option {
+	return func(m *MockDSP) {
+		m.mode, m.min, m.max, m.fill, m.seed = HashedPrice, min, max, fill, seed
+	}
+}
+
+// WithNoBid answers every request with 204
+func WithNoBid() Option {
+	return func(m *MockDSP) { m.mode = NoBid }
+}
+
+// WithLatency delays every response by d
+func WithLatency(d time.Duration) Option {
+	return func(m *MockDSP) { m.latency = d }
+}
+
+// WithHang never answers until the caller gives up, simulating a timeout
+func WithHang() Option {
+	return func(m *MockDSP) { m.hang = true }
+}
+
+// WithStatus answers every request with an HTTP error status
+func WithStatus(code int) Option {
+	return func(m *MockDSP) { m.status = code }
+}
+
+// WithAdM answers with adm as the creative markup
+func WithAdM(adm string) Option {
+	return func(m *MockDSP) { m.adm = adm }
+}
+
+// MockDSP is an httptest-backed DSP that records the bid requests it
+// receives and answers them as configured
+type MockDSP struct {
+	ID     string
+	Server *httptest.Server
+
+	mu        sync.Mutex
+	mode      BidMode
+	price     float64
+	impPrices map[string]float64
+	min, max  float64
+	rng       *rand.Rand
+	fill      float64
+	seed      int64
+	latency   time.Duration
+	hang      bool
+	status    int
+	adm       string
+	requests  []openrtb2.BidRequest
+	seq       int
+}
+
+// NewMockDSP starts a mock DSP. It no-bids unless configured otherwise.
+func NewMockDSP(id string, opts ...Option) *MockDSP {
+	m := &MockDSP{ID: id}
+	m.Set(opts...)
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	return m
+}
+
+// Set reconfigures the DSP between auctions
+func (m *MockDSP) Set(opts ...Option) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, opt := range opts {
+		opt(m)
+	}
+}
+
+// URL is the DSP's bid endpoint
+func (m *MockDSP) URL() string {
+	return m.Server.URL + "/bid"
+}
+
+// Close shuts the server down
+func (m *MockDSP) Close() {
+	m.Server.CloseClientConnections()
+	m.Server.Close()
+}
+
+// Requests returns the bid requests received so far
+func (m *MockDSP) Requests() []openrtb2.BidRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]openrtb2.BidRequest(nil), m.requests...)
+}
+
+// Connection returns an exchange connection to this DSP
+func (m *MockDSP) Connection(timeout time.Duration) *rtb.DSPConnection {
+	return &rtb.DSPConnection{
+		ID:          m.ID,
+		Name:        "Mock " + m.ID,
+		Endpoint:    m.URL(),
+		QPS:         1000,
+		Timeout:     timeout,
+		BidderCode:  m.ID,
+		SeatID:      "seat-" + m.ID,
+		RateLimiter: rtb.NewRateLimiter(1000),
+		Client:      m.Server.Client(),
+	}
+}
+
+func (m *MockDSP) serve(w http.ResponseWriter, r *http.Request) {
+	var req openrtb2.BidRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, req)
+	latency, hang, status := m.latency, m.hang, m.status
+	resp := m.respond(&req)
+	m.mu.Unlock()
+
+	if hang {
+		<-r.Context().Done()
+		return
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// respond builds the bid response for req; m.mu must be held
+func (m *MockDSP) respond(req *openrtb2.BidRequest) *openrtb2.BidResponse {
+	var bids []openrtb2.Bid
+	for _, imp := range req.Imp {
+		var price float64
+		switch m.mode {
+		case FixedPrice:
+			price = m.price
+		case PerImpPrice:
+			price = m.impPrices[imp.ID]
+		case RandomPrice:
+			price = m.min + m.rng.Float64()*(m.max-m.min)
+		case HashedPrice:
+			if m.unit(req.ID, imp.ID, "fill") < m.fill {
+				price = m.min + m.unit(req.ID, imp.ID, "price")*(m.max-m.min)
+			}
+		}
+		if price <= 0 {
+			continue
+		}
+		m.seq++
+		adm := m.adm
+		if adm == "" {
+			adm = `<VAST version="4.0"></VAST>`
+		}
+		bids = append(bids, openrtb2.Bid{
+			ID:      fmt.Sprintf("%s-bid-%d", m.ID, m.seq),
+			ImpID:   imp.ID,
+			Price:   price,
+			AdID:    m.ID + "-ad",
+			AdM:     adm,
+			ADomain: []string{m.ID + ".example"},
+		})
+	}
+	if len(bids) == 0 {
+		return nil
+	}
+	return &openrtb2.BidResponse{
+		ID:      req.ID,
+		Cur:     "USD",
+		SeatBid: []openrtb2.SeatBid{{Seat: "seat-" + m.ID, Bid: bids}},
+	}
+}
+
+// unit hashes the seed, DSP and parts to a float in [0, 1)
+func (m *MockDSP) unit(parts ...string) float64 {
+	h := fnv.New64a()
+	binary.Write(h, binary.BigEndian, m.seed)
+	h.Write([]byte(m.ID))
+	for _, p := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(p))
+	}
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+// StartMockDSPs starts n DSPs named dsp-1..dsp-n configured with opts,
+// registers them with exchange and closes them when the test ends
+func StartMockDSPs(t testing.TB, exchange *rtb.RTBExchange, n int, opts ...Option) []*MockDSP {
+	t.Helper()
+	dsps := make([]*MockDSP, n)
+	for i := range dsps {
+		dsps[i] = NewMockDSP(fmt.Sprintf("dsp-%d", i+1), opts...)
+	}
+	Register(t, exchange, dsps...)
+	return dsps
+}
+
+// Register adds dsps to exchange with a timeout just under the auction's,
+// closing them when the test ends
+func Register(t testing.TB, exchange *rtb.RTBExchange, dsps ...*MockDSP) {
+	t.Helper()
+	if exchange.DSPs == nil {
+		exchange.DSPs = make(map[string]*rtb.DSPConnection)
+	}
+	timeout := exchange.AuctionTimeout * 9 / 10
+	for _, m := range dsps {
+		exchange.DSPs[m.ID] = m.Connection(timeout)
+		t.Cleanup(m.Close)
+	}
+}
@@ -0,0 +1,192 @@
+package rtbtest
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/miner"
+	"github.com/luxfi/adx/pkg/rtb"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+func newExchange() *rtb.RTBExchange {
+	return &rtb.RTBExchange{
+		DSPs:           make(map[string]*rtb.DSPConnection),
+		AuctionTimeout: 200 * time.Millisecond,
+		FloorPrice:     decimal.NewFromFloat(0.5),
+		Revenue:        big.NewInt(0),
+	}
+}
+
+func bidRequest(id string) *openrtb2.BidRequest {
+	return &openrtb2.BidRequest{ID: id, Imp: []openrtb2.Imp{{ID: "1", BidFloor: 0.5}}}
+}
+
+func TestAuction_ThreeMockDSPs(t *testing.T) {
+	exchange := newExchange()
+	dsps := StartMockDSPs(t, exchange, 3)
+	dsps[0].Set(WithFixedPrice(2.10))
+	dsps[1].Set(WithImpPrices(map[string]float64{"1": 3.75}))
+	dsps[2].Set(WithFixedPrice(3.10), WithLatency(10*time.Millisecond))
+
+	resp, err := exchange.BidRequest(context.Background(), bidRequest("auc-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.SeatBid) != 1 || len(resp.SeatBid[0].Bid) != 1 {
+		t.Fatalf("response = %+v, want one winning bid", resp)
+	}
+	if seat, bid := resp.SeatBid[0].Seat, resp.SeatBid[0].Bid[0]; seat != "seat-dsp-2" || bid.Price != 3.75 {
+		t.Errorf("winner = %s at %v, want seat-dsp-2 at 3.75", seat, bid.Price)
+	}
+
+	for _, d := range dsps {
+		reqs := d.Requests()
+		if len(reqs) != 1 || reqs[0].ID != "auc-1" {
+			t.Errorf("%s received %d requests", d.ID, len(reqs))
+		}
+	}
+}
+
+func TestAuction_TimeoutsAndErrorsExcluded(t *testing.T) {
+	exchange := newExchange()
+	exchange.AuctionTimeout = 50 * time.Millisecond
+	dsps := StartMockDSPs(t, exchange, 3)
+	dsps[0].Set(WithFixedPrice(9), WithHang())
+	dsps[1].Set(WithFixedPrice(8), WithStatus(http.StatusInternalServerError))
+	dsps[2].Set(WithFixedPrice(1))
+
+	resp, err := exchange.BidRequest(context.Background(), bidRequest("auc-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.SeatBid) != 1 || resp.SeatBid[0].Seat != "seat-dsp-3" {
+		t.Errorf("response = %+v, want seat-dsp-3", resp)
+	}
+	if n := exchange.DSPs["dsp-2"].ErrorCount; n != 1 {
+		t.Errorf("dsp-2 errors = %d, want 1", n)
+	}
+}
+
+func TestAuction_HonorsTMax(t *testing.T) {
+	exchange := newExchange()
+	exchange.AuctionTimeout = 300 * time.Millisecond
+	dsps := StartMockDSPs(t, exchange, 2)
+	dsps[0].Set(WithFixedPrice(9), WithHang())
+	dsps[1].Set(WithFixedPrice(2))
+
+	for _, tt := range []struct {
+		name  string
+		tmax  int64
+		limit time.Duration
+	}{
+		{"short tmax", 80, 80 * time.Millisecond},
+		{"long tmax", 2000, 300 * time.Millisecond},
+		{"no tmax", 0, 300 * time.Millisecond},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := bidRequest("auc-tmax")
+			req.TMax = tt.tmax
+
+			start := time.Now()
+			resp, err := exchange.BidRequest(context.Background(), req)
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The margin is held back from tmax; allow some scheduling slack
+			if elapsed > tt.limit+20*time.Millisecond {
+				t.Errorf("answered after %v, want within %v", elapsed, tt.limit)
+			}
+			if len(resp.SeatBid) != 1 || resp.SeatBid[0].Seat != "seat-dsp-2" {
+				t.Errorf("response = %+v, want seat-dsp-2", resp)
+			}
+		})
+	}
+
+	// A tmax inside the margin leaves no time to ask anyone
+	req := bidRequest("auc-tmax")
+	req.TMax = 5
+	resp, err := exchange.BidRequest(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.SeatBid) != 0 {
+		t.Errorf("response = %+v, want no bids", resp)
+	}
+}
+
+func TestAuction_PushesResultToMatchedMiner(t *testing.T) {
+	exchange := newExchange()
+	dsps := StartMockDSPs(t, exchange, 1)
+	dsps[0].Set(WithFixedPrice(2), WithAdM(`<VAST version="4.0"><Ad id="a1"></Ad></VAST>`))
+
+	sent := make(map[string][][]byte)
+	exchange.MinerRegistry = &rtb.MinerRegistry{
+		Notify: func(minerID string, msg []byte) error {
+			sent[minerID] = append(sent[minerID], msg)
+			return nil
+		},
+	}
+	for _, m := range []*rtb.HomeMiner{
+		{ID: "sf", Country: "USA", Region: "CA", Active: true, HealthScore: 0.9},
+		{ID: "sf-idle", Country: "USA", Region: "CA", HealthScore: 1},
+		{ID: "nyc", Country: "USA", Region: "NY", Active: true, HealthScore: 1},
+		{ID: "berlin", Country: "DEU", Active: true, HealthScore: 1},
+	} {
+		exchange.MinerRegistry.Register(m)
+	}
+
+	req := bidRequest("auc-miner")
+	req.Device = &openrtb2.Device{Geo: &openrtb2.Geo{Country: "USA", Region: "CA"}}
+	if _, err := exchange.BidRequest(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || len(sent["sf"]) != 1 {
+		t.Fatalf("auction result sent to %v, want only sf", sent)
+	}
+
+	// The miner caches the creative and serves it for the auction
+	home := miner.NewHomeMiner(&miner.Config{WalletAddress: "0xABC"}, miner.TunnelConfig{Type: miner.TunnelDirectIP})
+	if err := home.HandleMessage(sent["sf"][0]); err != nil {
+		t.Fatal(err)
+	}
+	if adm, ok := home.AdCache.Get(dsps[0].ID + "-ad"); !ok || string(adm) != `<VAST version="4.0"><Ad id="a1"></Ad></VAST>` {
+		t.Errorf("cached creative = %q, %v", adm, ok)
+	}
+}
+
+func TestMockDSP_RandomPriceDeterministic(t *testing.T) {
+	prices := func() []float64 {
+		m := NewMockDSP("r", WithRandomPrice(1, 5, 42))
+		defer m.Close()
+		var out []float64
+		for i := 0; i < 5; i++ {
+			bid, err := m.Connection(time.Second).SendBidRequest(context.Background(), bidRequest("r"))
+			if err != nil || bid == nil {
+				t.Fatalf("bid = %v, err = %v", bid, err)
+			}
+			if bid.Price < 1 || bid.Price >= 5 {
+				t.Errorf("price %v out of range", bid.Price)
+			}
+			out = append(out, bid.Price)
+		}
+		return out
+	}
+	a, b := prices(), prices()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("prices differ for the same seed: %v vs %v", a, b)
+		}
+	}
+
+	m := NewMockDSP("n")
+	defer m.Close()
+	if bid, err := m.Connection(time.Second).SendBidRequest(context.Background(), bidRequest("n")); bid != nil || err != nil {
+		t.Errorf("default DSP bid %v, err %v; want no bid", bid, err)
+	}
+}
@@ -0,0 +1,148 @@
+package rtb
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/luxfi/adx/pkg/taxonomy"
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// ErrFrequencyCapped is why a winning bid was dropped for a user at its
+// campaign's cap
+var ErrFrequencyCapped = errors.New("user at frequency cap")
+
+// FrequencyCounter counts a user's impressions per campaign. CheckFrequencyCap
+// reports whether another impression is allowed and, if so, counts it; the
+// TEE enclave's counter satisfies it.
+type FrequencyCounter interface {
+	CheckFrequencyCap(userID, campaignID string, maxImpressions int) (bool, error)
+}
+
+// Exclusion lists advertisers and content categories a publisher blocks
+type Exclusion struct {
+	Advertisers []string // Advertiser domains
+	Categories  []string // IAB Content Taxonomy 1.0 categories
+}
+
+// PageExclusions holds publisher exclusions keyed by page URL, site domain
+// or app bundle. Every key matching a request applies.
+type PageExclusions struct {
+	mu    sync.RWMutex
+	rules map[string]Exclusion
+}
+
+// NewPageExclusions creates an empty exclusion set
+func NewPageExclusions() *PageExclusions {
+	return &PageExclusions{rules: make(map[string]Exclusion)}
+}
+
+// Set replaces the exclusion for a page URL, site domain or app bundle
+func (p *PageExclusions) Set(key string, ex Exclusion) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules[normalizeExclusionKey(key)] = ex
+}
+
+// For merges the exclusions that apply to req
+func (p *PageExclusions) For(req *openrtb2.BidRequest) Exclusion {
+	var keys []string
+	if req.Site != nil {
+		keys = append(keys, req.Site.Page, req.Site.Domain)
+		if u, err := url.Parse(req.Site.Page); err == nil && u.Host != "" {
+			keys = append(keys, u.Host)
+		}
+	}
+	if req.App != nil {
+		keys = append(keys, req.App.Bundle)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var out Exclusion
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if ex, ok := p.rules[normalizeExclusionKey(key)]; ok {
+			out.Advertisers = append(out.Advertisers, ex.Advertisers...)
+			out.Categories = append(out.Categories, ex.Categories...)
+		}
+	}
+	return out
+}
+
+// Blocks reports whether the exclusion rejects bid. A blocked category such
+// as IAB11 also blocks its subcategories, and its equivalents in the bid's
+// taxonomy version.
+func (e Exclusion) Blocks(bid *Bid) bool {
+	if bid.Advertiser != "" && containsFold(e.Advertisers, bid.Advertiser) {
+		return true
+	}
+	return taxonomy.Default().Blocked(taxonomy.Cats(adcom1.CatTaxIABContent10, e.Categories), taxonomy.Cats(bid.CatTax, bid.Categories))
+}
+
+// normalizeExclusionKey drops the scheme and trailing slash of page URLs
+func normalizeExclusionKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if i := strings.Index(key, "://"); i >= 0 {
+		key = key[i+3:]
+	}
+	return strings.TrimSuffix(key, "/")
+}
+
+// checkFrequency counts an impression of bid's campaign for the request's
+// user, reporting false when the user is at the cap. The shared store only
+// counts impressions under this node's cap. Requests without a user key
+// aren't capped.
+func (rtb *RTBExchange) checkFrequency(ctx context.Context, req *openrtb2.BidRequest, bid *Bid) (bool, error) {
+	if (rtb.Frequency == nil && rtb.FrequencyStore == nil) || rtb.FrequencyCap <= 0 {
+		return true, nil
+	}
+	user := rtb.UserKey(req)
+	if user == "" {
+		return true, nil
+	}
+	campaign := frequencyCampaignID(bid)
+	if rtb.Frequency != nil {
+		allowed, err := rtb.Frequency.CheckFrequencyCap(user, campaign, rtb.FrequencyCap)
+		if err != nil || !allowed {
+			return allowed, err
+		}
+	}
+	if rtb.FrequencyStore != nil {
+		return rtb.checkSharedFrequency(ctx, req, user, campaign), nil
+	}
+	return true, nil
+}
+
+func frequencyUserID(req *openrtb2.BidRequest) string {
+	if req.User != nil {
+		if req.User.ID != "" {
+			return req.User.ID
+		}
+		if req.User.BuyerUID != "" {
+			return req.User.BuyerUID
+		}
+	}
+	if req.Device != nil {
+		return req.Device.IFA
+	}
+	return ""
+}
+
+// frequencyCampaignID caps by campaign, or by advertiser for DSPs that
+// don't send one
+func frequencyCampaignID(bid *Bid) string {
+	switch {
+	case bid.CampaignID != "":
+		return bid.CampaignID
+	case bid.Advertiser != "":
+		return "adv:" + bid.Advertiser
+	}
+	return "ad:" + bid.AdID
+}
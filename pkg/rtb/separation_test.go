@@ -0,0 +1,123 @@
+package rtb
+
+import (
+	"testing"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+// countingCap is an in-memory FrequencyCounter with the enclave's
+// check-and-count semantics
+type countingCap map[string]int
+
+func (c countingCap) CheckFrequencyCap(userID, campaignID string, max int) (bool, error) {
+	key := userID + "/" + campaignID
+	if c[key] >= max {
+		return false, nil
+	}
+	c[key]++
+	return true, nil
+}
+
+func TestRunAuction_FrequencyCap(t *testing.T) {
+	counts := countingCap{}
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.5), Frequency: counts, FrequencyCap: 2}
+	req := &openrtb2.BidRequest{
+		ID:   "req-1",
+		Imp:  []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}},
+		User: &openrtb2.User{ID: "user-1"},
+	}
+	bids := []Bid{{ID: "b1", ImpID: "1", Price: 4, CampaignID: "camp-1"}}
+
+	for i := 0; i < 2; i++ {
+		if winner := exchange.runAuction(bids, req); winner == nil {
+			t.Fatalf("request %d under the cap got no bid", i+1)
+		}
+	}
+	if winner := exchange.runAuction(bids, req); winner != nil {
+		t.Errorf("user at the cap won %s", winner.ID)
+	}
+
+	// Another campaign still serves, and a losing bid doesn't use the cap
+	bids = append(bids, Bid{ID: "b2", ImpID: "1", Price: 3, CampaignID: "camp-2"}, Bid{ID: "b3", ImpID: "1", Price: 1, CampaignID: "camp-3"})
+	if winner := exchange.runAuction(bids, req); winner == nil || winner.ID != "b2" {
+		t.Errorf("winner = %v, want b2", winner)
+	}
+	if counts["user-1/camp-3"] != 0 {
+		t.Error("losing bid counted against the cap")
+	}
+
+	// Other users aren't affected
+	req.User.ID = "user-2"
+	if winner := exchange.runAuction(bids, req); winner == nil || winner.ID != "b1" {
+		t.Errorf("other user's winner = %v, want b1", winner)
+	}
+}
+
+func TestRunAuction_PageExclusions(t *testing.T) {
+	exclusions := NewPageExclusions()
+	exclusions.Set("https://news.example/politics/", Exclusion{Categories: []string{"IAB11"}})
+	exclusions.Set("news.example", Exclusion{Advertisers: []string{"rival.example"}})
+	exclusions.Set("com.example.kids", Exclusion{Categories: []string{"IAB11", "IAB8-5"}})
+
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.5), Exclusions: exclusions}
+	bids := []Bid{
+		{ID: "political", ImpID: "1", Price: 9, Categories: []string{"IAB11-4"}},
+		{ID: "politics", ImpID: "1", Price: 8, Categories: []string{"IAB11"}},
+		{ID: "rival", ImpID: "1", Price: 7, Advertiser: "Rival.example"},
+		{ID: "cocktails", ImpID: "1", Price: 6, Categories: []string{"IAB8-5"}},
+		{ID: "clean", ImpID: "1", Price: 1, Advertiser: "brand.example"},
+	}
+
+	for _, tt := range []struct {
+		name string
+		req  *openrtb2.BidRequest
+		want string
+	}{
+		// The top two bids are in the page's blocked category or under it
+		{"page and domain blocks", &openrtb2.BidRequest{Site: &openrtb2.Site{Page: "https://news.example/politics", Domain: "news.example"}}, "cocktails"},
+		{"domain blocks across pages", &openrtb2.BidRequest{Site: &openrtb2.Site{Page: "https://news.example/sport"}, BCat: []string{"IAB11-4", "IAB11"}}, "cocktails"},
+		{"app bundle", &openrtb2.BidRequest{App: &openrtb2.App{Bundle: "com.example.kids"}}, "rival"},
+		{"unlisted page", &openrtb2.BidRequest{Site: &openrtb2.Site{Page: "https://other.example/"}}, "political"},
+	} {
+		tt.req.Imp = []openrtb2.Imp{{ID: "1"}}
+		if winner := exchange.runAuction(bids, tt.req); winner == nil || winner.ID != tt.want {
+			t.Errorf("%s: winner = %v, want %s", tt.name, winner, tt.want)
+		}
+	}
+}
+
+func TestCheckBrandSafety_Taxonomy(t *testing.T) {
+	exchange := &RTBExchange{FloorPrice: decimal.NewFromFloat(0.5)}
+	tests := []struct {
+		name   string
+		bcat   []string
+		cattax adcom1.CategoryTaxonomy
+		bid    Bid
+		want   bool
+	}{
+		{"parent blocks child", []string{"IAB7"}, 0, Bid{Categories: []string{"IAB7-3"}}, false},
+		{"child leaves parent", []string{"IAB7-3"}, 0, Bid{Categories: []string{"IAB7"}}, true},
+		{"1.0 block, 2.x bid", []string{"IAB17"}, 0, Bid{Categories: []string{"483"}, CatTax: adcom1.CatTaxIABContent22}, false},
+		{"2.x block, 1.0 child", []string{"483"}, adcom1.CatTaxIABContent21, Bid{Categories: []string{"IAB17-12"}}, false},
+		{"2.x block, unrelated 1.0", []string{"483"}, adcom1.CatTaxIABContent21, Bid{Categories: []string{"IAB19"}}, true},
+	}
+	for _, tt := range tests {
+		req := &openrtb2.BidRequest{ID: "req-1", BCat: tt.bcat, CatTax: tt.cattax}
+		if got := exchange.checkBrandSafety(&tt.bid, req); got != tt.want {
+			t.Errorf("%s: checkBrandSafety = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// A blocked parent filters the child-category creative from the auction
+	req := &openrtb2.BidRequest{ID: "req-1", Imp: []openrtb2.Imp{{ID: "1"}}, BCat: []string{"IAB7"}}
+	bids := []Bid{
+		{ID: "fitness", ImpID: "1", Price: 5, Categories: []string{"IAB7-18"}},
+		{ID: "cars", ImpID: "1", Price: 2, Categories: []string{"IAB2"}},
+	}
+	if winner := exchange.runAuction(bids, req); winner == nil || winner.ID != "cars" {
+		t.Errorf("winner = %v, want cars", winner)
+	}
+}
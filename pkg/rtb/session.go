@@ -0,0 +1,130 @@
+package rtb
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSessionDedupWindow is how long a creative served in a viewing
+// session is kept out of the session's later pods
+const DefaultSessionDedupWindow = 30 * time.Minute
+
+// SessionDedup keeps creatives served in a viewing session out of the
+// session's later pods for Window, so an ad seen in the pre-roll isn't
+// repeated in a mid-roll minutes later. Creatives are told apart by
+// AdID; ads without one, and house ads, are never held back.
+type SessionDedup struct {
+	Window time.Duration // DefaultSessionDedupWindow when zero
+
+	mu       sync.Mutex
+	sessions map[string]map[string]time.Time // Creative served times by session
+	swept    time.Time
+	now      func() time.Time
+}
+
+// NewSessionDedup creates a dedup holding creatives back for window
+func NewSessionDedup(window time.Duration) *SessionDedup {
+	return &SessionDedup{
+		Window:   window,
+		sessions: make(map[string]map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (d *SessionDedup) window() time.Duration {
+	if d.Window <= 0 {
+		return DefaultSessionDedupWindow
+	}
+	return d.Window
+}
+
+// Filter drops the ads whose creative the session was served within the
+// window
+func (d *SessionDedup) Filter(session string, ads []AdResponse) []AdResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	served := d.sessions[session]
+	if len(served) == 0 {
+		return ads
+	}
+	now := d.now()
+	out := make([]AdResponse, 0, len(ads))
+	for _, ad := range ads {
+		if at, ok := served[ad.AdID]; ok && ad.AdID != "" && now.Sub(at) < d.window() {
+			continue
+		}
+		out = append(out, ad)
+	}
+	return out
+}
+
+// Record notes the creatives the session was served in pods
+func (d *SessionDedup) Record(session string, pods []AdPodResponse) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	d.sweep(now)
+	served := d.sessions[session]
+	for _, p := range pods {
+		for _, ad := range p.Ads {
+			if ad.House || ad.AdID == "" {
+				continue
+			}
+			if served == nil {
+				served = make(map[string]time.Time)
+				d.sessions[session] = served
+			}
+			served[ad.AdID] = now
+		}
+	}
+}
+
+// sweep forgets creatives served longer than the window ago, at most once
+// a window; d.mu must be held
+func (d *SessionDedup) sweep(now time.Time) {
+	window := d.window()
+	if now.Sub(d.swept) < window {
+		return
+	}
+	d.swept = now
+	for session, served := range d.sessions {
+		for creative, at := range served {
+			if now.Sub(at) >= window {
+				delete(served, creative)
+			}
+		}
+		if len(served) == 0 {
+			delete(d.sessions, session)
+		}
+	}
+}
+
+// AssembleSession is Assemble for one of a viewing session's ad breaks:
+// creatives the session has been served recently are left out, and the
+// ones placed now are remembered for its later breaks. Without a session
+// key, or without Sessions, it's Assemble.
+func (a *AdPodAssembler) AssembleSession(publisherID, session string, pods []AdPodRequest, ads []AdResponse) []AdPodResponse {
+	if a.Sessions == nil || session == "" {
+		return a.Assemble(publisherID, pods, ads)
+	}
+	out := a.Assemble(publisherID, pods, a.Sessions.Filter(session, ads))
+	a.Sessions.Record(session, out)
+	return out
+}
+
+// sessionKey identifies the viewing session a CTV request is for: its
+// SessionID, else the user watching the content; empty when neither is
+// known
+func (rtb *RTBExchange) sessionKey(req *CTVBidRequest) string {
+	if req.SessionID != "" {
+		return req.SessionID
+	}
+	if req.Content.ID == "" {
+		return ""
+	}
+	user := rtb.UserKey(rtb.convertCTVToOpenRTB(req))
+	if user == "" {
+		return ""
+	}
+	return user + "/" + req.Content.ID
+}
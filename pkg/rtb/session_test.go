@@ -0,0 +1,63 @@
+package rtb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdPodAssembler_SessionDedup(t *testing.T) {
+	now := time.Date(2025, 6, 1, 20, 0, 0, 0, time.UTC)
+	dedup := NewSessionDedup(10 * time.Minute)
+	dedup.now = func() time.Time { return now }
+	a := &AdPodAssembler{MaxPodDuration: 60 * time.Second, Sessions: dedup}
+
+	preRoll := []AdPodRequest{{ID: "pre", MaxAds: 1}}
+	midRoll := []AdPodRequest{{ID: "mid", MaxAds: 1}}
+	ads := func() []AdResponse {
+		return []AdResponse{
+			{ID: "b1", AdID: "cr-top", Duration: 30, Price: 20},
+			{ID: "b2", AdID: "cr-next", Duration: 30, Price: 10},
+		}
+	}
+
+	if got := podIDs(a.AssembleSession("pub-1", "s1", preRoll, ads())); !reflect.DeepEqual(got, [][]string{{"b1"}}) {
+		t.Fatalf("pre-roll = %v", got)
+	}
+
+	// Minutes later the session's mid-roll gets the next creative
+	now = now.Add(5 * time.Minute)
+	if got := podIDs(a.AssembleSession("pub-1", "s1", midRoll, ads())); !reflect.DeepEqual(got, [][]string{{"b2"}}) {
+		t.Errorf("mid-roll = %v, want the pre-roll's creative held back", got)
+	}
+	// Other sessions, and requests without one, aren't affected
+	if got := podIDs(a.AssembleSession("pub-1", "s2", midRoll, ads())); !reflect.DeepEqual(got, [][]string{{"b1"}}) {
+		t.Errorf("other session's mid-roll = %v", got)
+	}
+	if got := podIDs(a.AssembleSession("pub-1", "", midRoll, ads())); !reflect.DeepEqual(got, [][]string{{"b1"}}) {
+		t.Errorf("sessionless mid-roll = %v", got)
+	}
+
+	// Once the window has passed since it was served, the creative returns
+	now = now.Add(6 * time.Minute)
+	if got := podIDs(a.AssembleSession("pub-1", "s1", midRoll, ads())); !reflect.DeepEqual(got, [][]string{{"b1"}}) {
+		t.Errorf("mid-roll after the window = %v", got)
+	}
+}
+
+func TestRTBExchange_SessionKey(t *testing.T) {
+	rtb := &RTBExchange{}
+	req := &CTVBidRequest{SessionID: "sess-1"}
+	if k := rtb.sessionKey(req); k != "sess-1" {
+		t.Errorf("key = %q, want the session ID", k)
+	}
+	req = &CTVBidRequest{}
+	req.Device.IFA = "ifa-1"
+	if k := rtb.sessionKey(req); k != "" {
+		t.Errorf("key = %q without content, want none", k)
+	}
+	req.Content.ID = "episode-1"
+	if k := rtb.sessionKey(req); k != "ifa-1/episode-1" {
+		t.Errorf("key = %q, want the viewer and content", k)
+	}
+}
@@ -0,0 +1,218 @@
+package rtb
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidSimulation is a simulation asked for without a request to
+// replay, or with a malformed one
+var ErrInvalidSimulation = errors.New("invalid simulation")
+
+// SimulationConfig is the hypothetical setup an auction is replayed
+// under; zero fields leave the exchange's own in place
+type SimulationConfig struct {
+	// Floor replaces every impression's floor, the exchange's, its floor
+	// rules' and the publisher's alike
+	Floor *float64 `json:"floor,omitempty"`
+
+	// BCat and BAdv are blocked on top of the request's own
+	BCat []string `json:"bcat,omitempty"`
+	BAdv []string `json:"badv,omitempty"`
+
+	// Pods are assembled from the bids left eligible, under the
+	// publisher's separation rule; Durations gives each bid's ad length
+	// in seconds, by bid ID
+	Pods      []AdPodRequest `json:"pods,omitempty"`
+	Durations map[string]int `json:"durations,omitempty"`
+}
+
+// SimulationResult is what an auction would have come to
+type SimulationResult struct {
+	AuctionID     string                `json:"auction_id"`
+	Filled        bool                  `json:"filled"`
+	Winner        *AuditBid             `json:"winner,omitempty"`
+	ClearingPrice float64               `json:"clearing_price,omitempty"`
+	NBR           *openrtb3.NoBidReason `json:"nbr,omitempty"`
+	Currency      string                `json:"cur"`
+	Imps          []AuditImp            `json:"imps"` // With the floors applied
+	Bids          []AuditBid            `json:"bids"` // Every bid's outcome
+	Pods          []AdPodResponse       `json:"pods,omitempty"`
+}
+
+// SimulateAuction replays an auction of req among bids under cfg and
+// reports its winner, clearing price and every bid's outcome. Nothing is
+// recorded and no one is notified: seat spend, fatigue, frequency counts,
+// floors, audits and metrics are left as they were. Frequency caps aren't
+// checked, since counting the winner against them would spend the user's
+// cap.
+func (rtb *RTBExchange) SimulateAuction(req *openrtb2.BidRequest, bids []Bid, cfg SimulationConfig) *SimulationResult {
+	sim := rtb.simulator(cfg)
+	req = simulatedRequest(req, cfg)
+	bids = slices.Clone(bids)
+
+	winner, rejected := sim.simulate(bids, req)
+	a := sim.audit(req, bids, winner, rejected)
+	res := &SimulationResult{
+		AuctionID: req.ID,
+		Filled:    winner != nil,
+		Currency:  exchangeCurrency,
+		Imps:      a.Request.Imps,
+		Bids:      a.Bids,
+	}
+	if winner != nil {
+		for i := range bids {
+			if &bids[i] == winner {
+				res.Winner = &res.Bids[i]
+			}
+		}
+		res.ClearingPrice = sim.clearingPrice(req, winner, sim.losses(req, bids, winner, rejected))
+	} else if sim.allBelowFloor(req, bids) {
+		res.NBR = NoBidBelowFloor.Ptr()
+	}
+
+	if len(cfg.Pods) > 0 {
+		var ads []AdResponse
+		for i := range bids {
+			if a.Bids[i].Outcome == OutcomeRejected {
+				continue
+			}
+			bid := &bids[i]
+			ads = append(ads, AdResponse{
+				ID:           bid.ID,
+				AdID:         bid.AdID,
+				Creative:     bid.Creative,
+				Duration:     cfg.Durations[bid.ID],
+				Price:        bid.Price,
+				AdvertiserID: bid.Advertiser,
+				BrandID:      bid.Brand,
+				CategoryID:   bid.Categories,
+			})
+		}
+		assembler := rtb.PodAssembler
+		if assembler == nil {
+			assembler = &AdPodAssembler{}
+		}
+		publisher, _, _ := publisherOf(req)
+		res.Pods = assembler.Assemble(publisher, cfg.Pods, ads)
+	}
+	return res
+}
+
+// simulator is an exchange that judges bids as rtb does, but under cfg
+// and with nothing that records or notifies
+func (rtb *RTBExchange) simulator(cfg SimulationConfig) *RTBExchange {
+	sim := &RTBExchange{
+		FloorPrice:      rtb.FloorPrice,
+		FloorRules:      rtb.FloorRules,
+		CTVOptimizer:    rtb.CTVOptimizer,
+		PlacementFloors: rtb.PlacementFloors,
+		PodAssembler:    rtb.PodAssembler,
+		QualityGate:     rtb.QualityGate,
+		CreativePolicy:  rtb.CreativePolicy,
+		Identity:        rtb.Identity,
+		Exclusions:      rtb.Exclusions,
+		Taxonomy:        rtb.Taxonomy,
+		Fatigue:         rtb.Fatigue,
+		BidGuard:        rtb.BidGuard,
+	}
+	if cfg.Floor != nil {
+		sim.FloorPrice = decimal.NewFromFloat(*cfg.Floor)
+		sim.FloorRules = nil
+		sim.PlacementFloors = nil
+	}
+	return sim
+}
+
+// simulate is auction without its side effects: the winner isn't counted
+// against seat budgets, fatigue or frequency caps
+func (rtb *RTBExchange) simulate(bids []Bid, req *openrtb2.BidRequest) (*Bid, map[*Bid]error) {
+	rejected := make(map[*Bid]error)
+	if rtb.BidGuard != nil {
+		rtb.BidGuard.Screen(bids, rejected)
+	}
+	var screen creativeScreen
+	for {
+		winner := rtb.bestBid(bids, req, rejected)
+		if winner == nil {
+			if screen.readmit(rejected) {
+				continue
+			}
+			return nil, rejected
+		}
+		rejected[winner] = nil
+		if err := rtb.guard(winner, func() error { return rtb.checkQuality(req, winner) }); err != nil {
+			rejected[winner] = err
+			continue
+		}
+		if !rtb.screenCreative(req, winner, rejected, &screen) {
+			continue
+		}
+		return winner, rejected
+	}
+}
+
+// simulatedRequest is a copy of req with cfg's floor and blocks applied
+func simulatedRequest(req *openrtb2.BidRequest, cfg SimulationConfig) *openrtb2.BidRequest {
+	out := *req
+	out.Imp = slices.Clone(req.Imp)
+	if cfg.Floor != nil {
+		for i := range out.Imp {
+			out.Imp[i].BidFloor = 0
+		}
+	}
+	out.BCat = append(slices.Clone(req.BCat), cfg.BCat...)
+	out.BAdv = append(slices.Clone(req.BAdv), cfg.BAdv...)
+	return &out
+}
+
+// Replay rebuilds the auction's request and bids from its record, for
+// SimulateAuction. Each impression keeps the floor it was sold against.
+func (a *AuctionAudit) Replay() (*openrtb2.BidRequest, []Bid) {
+	req := &openrtb2.BidRequest{
+		ID:   a.AuctionID,
+		TMax: a.Request.TMax,
+		AT:   a.Request.AT,
+		BCat: slices.Clone(a.Request.BCat),
+		BAdv: slices.Clone(a.Request.BAdv),
+	}
+	pub := &openrtb2.Publisher{ID: a.Request.Publisher}
+	if a.Request.App {
+		req.App = &openrtb2.App{Domain: a.Request.Domain, Publisher: pub}
+	} else {
+		req.Site = &openrtb2.Site{Domain: a.Request.Domain, Publisher: pub}
+	}
+	for _, ai := range a.Request.Imps {
+		imp := openrtb2.Imp{ID: ai.ID, TagID: ai.TagID, BidFloor: ai.Floor, BidFloorCur: exchangeCurrency}
+		if len(ai.Deals) > 0 {
+			imp.PMP = &openrtb2.PMP{}
+			for _, d := range ai.Deals {
+				imp.PMP.Deals = append(imp.PMP.Deals, openrtb2.Deal{ID: d})
+			}
+		}
+		req.Imp = append(req.Imp, imp)
+	}
+	bids := make([]Bid, len(a.Bids))
+	for i, ab := range a.Bids {
+		bids[i] = ab.bid()
+	}
+	return req, bids
+}
+
+// bid is the bid an audit record describes
+func (ab AuditBid) bid() Bid {
+	return Bid{
+		ID:         ab.ID,
+		ImpID:      ab.ImpID,
+		Price:      ab.Price,
+		DSP:        ab.DSP,
+		SeatID:     ab.Seat,
+		DealID:     ab.DealID,
+		Advertiser: ab.Advertiser,
+		Categories: slices.Clone(ab.Categories),
+	}
+}
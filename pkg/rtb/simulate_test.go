@@ -0,0 +1,90 @@
+package rtb
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+func TestSimulateAuction_RaisedFloor(t *testing.T) {
+	exchange := &RTBExchange{
+		DSPs: map[string]*DSPConnection{
+			"high": noticeDSP(t, "", "high", 3.5),
+			"low":  noticeDSP(t, "", "low", 2.25),
+		},
+		AuctionTimeout: time.Second,
+		FloorPrice:     decimal.NewFromFloat(0.5),
+		Audit:          NewAuditLog(10, nil),
+		Revenue:        big.NewInt(0),
+	}
+	req := &openrtb2.BidRequest{ID: "auc-1", AT: 2, Imp: []openrtb2.Imp{{ID: "1", Banner: &openrtb2.Banner{}}}}
+	if _, err := exchange.BidRequest(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	recorded, _ := exchange.Audit.Audit("auc-1")
+	proof := recorded.ProofRef
+	wins := atomic.LoadUint64(&exchange.WinCount)
+	revenue := new(big.Int).Set(exchange.Revenue)
+
+	h := NewAdminHandler(exchange, testAdminToken, nil)
+	floor := func(f float64) SimulationConfig { return SimulationConfig{Floor: &f} }
+	tests := []struct {
+		name     string
+		cfg      SimulationConfig
+		winner   string
+		clearing float64
+	}{
+		{"as run", SimulationConfig{}, "bid-high", 2.25},
+		// The runner-up falls below the floor, which the winner now pays
+		{"floor 3", floor(3), "bid-high", 3},
+		{"floor 4", floor(4), "", 0},
+	}
+	for _, tt := range tests {
+		var res SimulationResult
+		if code := adminCall(t, h, http.MethodPost, "/admin/simulate", simulateBody{AuctionID: "auc-1", Config: tt.cfg}, &res); code != http.StatusOK {
+			t.Fatalf("%s: status %d", tt.name, code)
+		}
+		var winner string
+		if res.Winner != nil {
+			winner = res.Winner.ID
+		}
+		if winner != tt.winner || res.Filled != (tt.winner != "") || res.ClearingPrice != tt.clearing {
+			t.Errorf("%s: winner %q filled %v at %v, want %q at %v", tt.name, winner, res.Filled, res.ClearingPrice, tt.winner, tt.clearing)
+		}
+		if tt.winner == "" && (res.NBR == nil || *res.NBR != NoBidBelowFloor) {
+			t.Errorf("%s: nbr = %v, want below floor", tt.name, res.NBR)
+		}
+	}
+
+	// A blocked advertiser hands the auction to the runner-up
+	replay, bids := recorded.Replay()
+	for i := range bids {
+		bids[i].Advertiser = bids[i].DSP + ".example"
+	}
+	res := exchange.SimulateAuction(replay, bids, SimulationConfig{BAdv: []string{"high.example"}})
+	if res.Winner == nil || res.Winner.ID != "bid-low" {
+		t.Errorf("winner = %+v with the high bidder blocked, want bid-low", res.Winner)
+	}
+
+	// None of it touched the exchange
+	if got, _ := exchange.Audit.Audit("auc-1"); got.ProofRef != proof {
+		t.Error("audit record changed")
+	}
+	if atomic.LoadUint64(&exchange.WinCount) != wins || exchange.Revenue.Cmp(revenue) != 0 {
+		t.Errorf("wins %d revenue %s after simulating, want %d and %s", exchange.WinCount, exchange.Revenue, wins, revenue)
+	}
+
+	var errBody map[string]string
+	if code := adminCall(t, h, http.MethodPost, "/admin/simulate", simulateBody{AuctionID: "unknown"}, &errBody); code != http.StatusNotFound {
+		t.Errorf("unknown auction: status %d, want 404", code)
+	}
+	if code := adminCall(t, h, http.MethodPost, "/admin/simulate", simulateBody{}, &errBody); code != http.StatusBadRequest {
+		t.Errorf("empty simulation: status %d, want 400", code)
+	}
+}
@@ -0,0 +1,32 @@
+package rtb
+
+import "github.com/prebid/openrtb/v20/openrtb2"
+
+// SkipPricing adjusts bids for video impressions by whether the viewer can
+// skip the ad, since a skippable view is worth less to most advertisers.
+// Each adjustment is a fraction of the bid: -0.2 prices it 20% below what
+// the DSP bid, 0.1 10% above. Bids for other impressions are left alone.
+type SkipPricing struct {
+	Skippable    float64
+	NonSkippable float64
+}
+
+// adjust reprices bid for whether its impression is skippable
+func (p *SkipPricing) adjust(req *openrtb2.BidRequest, bid *Bid) {
+	for _, imp := range req.Imp {
+		if imp.ID != bid.ImpID || imp.Video == nil {
+			continue
+		}
+		delta := p.NonSkippable
+		if skippable(imp.Video) {
+			delta = p.Skippable
+		}
+		bid.Price = max(bid.Price*(1+delta), 0)
+		return
+	}
+}
+
+// skippable reports whether the player lets the viewer skip the ad
+func skippable(v *openrtb2.Video) bool {
+	return v.Skip != nil && *v.Skip == 1
+}
@@ -0,0 +1,59 @@
+package rtb
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/shopspring/decimal"
+)
+
+func TestSkipPricing_Auction(t *testing.T) {
+	exchange := &RTBExchange{
+		DSPs:           map[string]*DSPConnection{"dsp": bidderDSP(t, "dsp", "", openrtb2.Bid{Price: 10})},
+		AuctionTimeout: time.Second,
+		FloorPrice:     decimal.NewFromFloat(8.5),
+		SkipPricing:    &SkipPricing{Skippable: -0.2, NonSkippable: 0.1},
+		Revenue:        big.NewInt(0),
+	}
+	skip := func(s int8) *int8 { return &s }
+
+	for _, tt := range []struct {
+		name  string
+		imp   openrtb2.Imp
+		price float64 // 0 for no fill
+	}{
+		{"non-skippable", openrtb2.Imp{ID: "1", Video: &openrtb2.Video{Skip: skip(0)}}, 11},
+		{"skip unset", openrtb2.Imp{ID: "1", Video: &openrtb2.Video{}}, 11},
+		{"banner", openrtb2.Imp{ID: "1", Banner: &openrtb2.Banner{}}, 10},
+		// Repriced to 8, under the floor
+		{"skippable", openrtb2.Imp{ID: "1", Video: &openrtb2.Video{Skip: skip(1)}}, 0},
+	} {
+		resp, err := exchange.BidRequest(context.Background(), &openrtb2.BidRequest{ID: "auc-" + tt.name, Imp: []openrtb2.Imp{tt.imp}})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		switch {
+		case tt.price == 0 && len(resp.SeatBid) != 0:
+			t.Errorf("%s: won at %v, want no fill", tt.name, resp.SeatBid[0].Bid[0].Price)
+		case tt.price == 0:
+		case len(resp.SeatBid) != 1:
+			t.Errorf("%s: no fill, want %v", tt.name, tt.price)
+		case resp.SeatBid[0].Bid[0].Price != tt.price:
+			t.Errorf("%s: price = %v, want %v", tt.name, resp.SeatBid[0].Bid[0].Price, tt.price)
+		}
+	}
+
+	// Without a floor in the way, skippable inventory clears at the
+	// discounted price
+	exchange.FloorPrice = decimal.Zero
+	resp, err := exchange.BidRequest(context.Background(), &openrtb2.BidRequest{ID: "auc-skip", Imp: []openrtb2.Imp{{ID: "1", Video: &openrtb2.Video{Skip: skip(1)}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.SeatBid) != 1 || resp.SeatBid[0].Bid[0].Price != 8 {
+		t.Errorf("skippable response = %+v, want a win at 8", resp.SeatBid)
+	}
+}
@@ -0,0 +1,271 @@
+package rtb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// DefaultSPOWindow is how long after an impression first arrives copies
+// of it over other supply paths are folded into its auction
+const DefaultSPOWindow = time.Second
+
+// SupplyPathOptimizer collapses the same impression arriving over several
+// supply paths into one auction. Requests are matched on a signature of
+// their device, user, placement and transaction ID; the first to arrive
+// waits Hold for copies, then fans out to the DSPs once, and when the bids
+// are in the cheapest path that's still waiting sells the impression while
+// the others get no bid. A path's cost is its seller's fee, then its
+// schain length. Copies arriving after the bids are in get no bid, as the
+// impression has been sold.
+type SupplyPathOptimizer struct {
+	Window time.Duration // DefaultSPOWindow when zero
+	Hold   time.Duration // Copies are only awaited while DSPs are asked when zero
+
+	// Hits counts requests folded into another path's auction; updated
+	// atomically
+	Hits uint64
+
+	mu        sync.Mutex
+	fees      map[string]float64
+	groups    map[string]*spoGroup
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// spoGroup is one impression's supply paths
+type spoGroup struct {
+	started time.Time
+	paths   []*openrtb2.BidRequest
+	ready   chan struct{} // Closed once bids and seller are set
+
+	bids   []Bid
+	seller *openrtb2.BidRequest
+}
+
+// NewSupplyPathOptimizer creates an optimizer that treats every path as
+// free until SetPathFee says otherwise
+func NewSupplyPathOptimizer() *SupplyPathOptimizer {
+	return &SupplyPathOptimizer{
+		fees:   make(map[string]float64),
+		groups: make(map[string]*spoGroup),
+		now:    time.Now,
+	}
+}
+
+// SetPathFee sets the share of spend a seller's path takes, 0 to 1
+func (o *SupplyPathOptimizer) SetPathFee(sellerID string, fee float64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.fees[sellerID] = fee
+}
+
+// collect returns req's bids, fanning out with collectBids only for the
+// first path of an impression, and whether req's path sells it
+func (o *SupplyPathOptimizer) collect(ctx context.Context, req *openrtb2.BidRequest, deadline time.Time,
+	collectBids func(context.Context, *openrtb2.BidRequest, time.Time) []Bid) ([]Bid, bool) {
+	sig, ok := impressionSignature(req)
+	if !ok {
+		return collectBids(ctx, req, deadline), true
+	}
+
+	o.mu.Lock()
+	now := o.now()
+	o.sweep(now)
+	if g, ok := o.groups[sig]; ok && now.Sub(g.started) < o.window() {
+		atomic.AddUint64(&o.Hits, 1)
+		if g.seller != nil {
+			o.mu.Unlock()
+			return nil, false
+		}
+		g.paths = append(g.paths, req)
+		o.mu.Unlock()
+		return o.await(ctx, g, req, deadline)
+	}
+	g := &spoGroup{started: now, paths: []*openrtb2.BidRequest{req}, ready: make(chan struct{})}
+	o.groups[sig] = g
+	o.mu.Unlock()
+
+	if o.Hold > 0 {
+		hold := o.Hold
+		if left := time.Until(deadline); left < hold {
+			hold = left
+		}
+		select {
+		case <-time.After(hold):
+		case <-ctx.Done():
+		}
+	}
+
+	// DSPs see the cheapest path so far
+	o.mu.Lock()
+	fanOut := o.cheapest(g.paths)
+	o.mu.Unlock()
+	bids := collectBids(ctx, fanOut, deadline)
+
+	o.mu.Lock()
+	g.bids, g.seller = bids, o.cheapest(g.paths)
+	o.mu.Unlock()
+	close(g.ready)
+
+	if g.seller != req {
+		return nil, false
+	}
+	return forImp(bids, req), true
+}
+
+// await waits for the group's bids as a copy of its impression, leaving
+// the group if req's deadline passes first
+func (o *SupplyPathOptimizer) await(ctx context.Context, g *spoGroup, req *openrtb2.BidRequest, deadline time.Time) ([]Bid, bool) {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	select {
+	case <-g.ready:
+	case <-ctx.Done():
+		o.mu.Lock()
+		sold := g.seller != nil
+		if !sold {
+			for i, p := range g.paths {
+				if p == req {
+					g.paths = append(g.paths[:i], g.paths[i+1:]...)
+					break
+				}
+			}
+		}
+		o.mu.Unlock()
+		if !sold {
+			return nil, false
+		}
+		<-g.ready
+	}
+	if g.seller != req {
+		return nil, false
+	}
+	return forImp(g.bids, req), true
+}
+
+// cheapest is the path with the lowest seller fee, then the shortest
+// schain, then the earliest; o.mu must be held
+func (o *SupplyPathOptimizer) cheapest(paths []*openrtb2.BidRequest) *openrtb2.BidRequest {
+	var best *openrtb2.BidRequest
+	var bestFee float64
+	var bestHops int
+	for _, p := range paths {
+		seller, _, _ := publisherOf(p)
+		fee, hops := o.fees[seller], schainHops(p)
+		if best == nil || fee < bestFee || (fee == bestFee && hops < bestHops) {
+			best, bestFee, bestHops = p, fee, hops
+		}
+	}
+	return best
+}
+
+func (o *SupplyPathOptimizer) window() time.Duration {
+	if o.Window > 0 {
+		return o.Window
+	}
+	return DefaultSPOWindow
+}
+
+// sweep forgets impressions past the window; o.mu must be held
+func (o *SupplyPathOptimizer) sweep(now time.Time) {
+	window := o.window()
+	if now.Sub(o.lastSweep) < window {
+		return
+	}
+	o.lastSweep = now
+	for sig, g := range o.groups {
+		if now.Sub(g.started) >= window {
+			delete(o.groups, sig)
+		}
+	}
+}
+
+// forImp copies bids collected for another path's request onto req's
+// impression
+func forImp(bids []Bid, req *openrtb2.BidRequest) []Bid {
+	out := make([]Bid, len(bids))
+	copy(out, bids)
+	for i := range out {
+		out[i].ImpID = req.Imp[0].ID
+	}
+	return out
+}
+
+func schainHops(req *openrtb2.BidRequest) int {
+	if req.Source == nil || req.Source.SChain == nil {
+		return 0
+	}
+	return len(req.Source.SChain.Nodes)
+}
+
+// impressionSignature identifies an impression however it reached us: the
+// device, user, placement and transaction ID, normalized. Only single-slot
+// requests that name a device, user or transaction are matched, as
+// without one two viewers of the same page would look alike.
+func impressionSignature(req *openrtb2.BidRequest) (string, bool) {
+	if len(req.Imp) != 1 {
+		return "", false
+	}
+	norm := func(s string) string { return strings.ToLower(strings.TrimSpace(s)) }
+
+	var device, user, tid string
+	if d := req.Device; d != nil {
+		device = norm(d.IFA)
+		if device == "" && (d.IP != "" || d.IPv6 != "") {
+			device = norm(d.IP) + "|" + norm(d.IPv6) + "|" + norm(d.UA)
+		}
+	}
+	if u := req.User; u != nil {
+		user = norm(u.ID)
+		if user == "" {
+			user = norm(u.BuyerUID)
+		}
+	}
+	if req.Source != nil {
+		tid = norm(req.Source.TID)
+	}
+	if device == "" && user == "" && tid == "" {
+		return "", false
+	}
+
+	imp := req.Imp[0]
+	var placement string
+	switch {
+	case req.Site != nil:
+		placement = norm(req.Site.Page)
+		if placement == "" {
+			placement = norm(req.Site.Domain)
+		}
+	case req.App != nil:
+		placement = norm(req.App.Bundle)
+	}
+	placement += "|" + norm(imp.TagID)
+	switch {
+	case imp.Video != nil:
+		placement += "|video|" + dim(imp.Video.W) + "x" + dim(imp.Video.H)
+	case imp.Banner != nil:
+		placement += "|banner|" + dim(imp.Banner.W) + "x" + dim(imp.Banner.H)
+	}
+
+	h := sha256.New()
+	for _, part := range []string{device, user, placement, tid} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func dim(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
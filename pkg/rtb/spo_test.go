@@ -0,0 +1,130 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// spoRequest is one impression as an SSP selling seller forwards it
+func spoRequest(id, seller, impID string) *openrtb2.BidRequest {
+	return &openrtb2.BidRequest{
+		ID:     id,
+		Imp:    []openrtb2.Imp{{ID: impID, TagID: "Pre-Roll", Video: &openrtb2.Video{W: ptrInt64(1920), H: ptrInt64(1080)}}},
+		Site:   &openrtb2.Site{Page: "https://news.example/story", Publisher: &openrtb2.Publisher{ID: seller}},
+		Device: &openrtb2.Device{IFA: "6D92078A-8246-4BA4-AE5B-76104861E7DC"},
+		Source: &openrtb2.Source{TID: "tid-1"},
+	}
+}
+
+func TestSupplyPathOptimizer_OneAuctionCheapestPath(t *testing.T) {
+	var requests atomic.Int64
+	dsp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req openrtb2.BidRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{ID: req.ID, SeatBid: []openrtb2.SeatBid{{
+			Seat: "seat1",
+			Bid:  []openrtb2.Bid{{ID: "b1", ImpID: req.Imp[0].ID, Price: 4}},
+		}}})
+	}))
+	defer dsp.Close()
+
+	spo := NewSupplyPathOptimizer()
+	spo.Hold = 50 * time.Millisecond
+	spo.SetPathFee("ssp-pricey", 0.20)
+	spo.SetPathFee("ssp-cheap", 0.08)
+	exchange := &RTBExchange{
+		DSPs: map[string]*DSPConnection{"dsp1": {
+			ID: "dsp1", Endpoint: dsp.URL, Timeout: time.Second, RateLimiter: NewRateLimiter(100),
+		}},
+		AuctionTimeout: time.Second,
+		Revenue:        big.NewInt(0),
+		SPO:            spo,
+	}
+
+	// The pricier path arrives first, the cheaper one while it holds
+	paths := []*openrtb2.BidRequest{
+		spoRequest("via-pricey", "ssp-pricey", "1"),
+		spoRequest("via-cheap", "ssp-cheap", "imp-a"),
+	}
+	// Copies differ only in case and spacing
+	paths[1].Device.IFA = " 6d92078a-8246-4ba4-ae5b-76104861e7dc"
+	paths[1].Imp[0].TagID = "pre-roll"
+
+	resps := make([]*openrtb2.BidResponse, len(paths))
+	var wg sync.WaitGroup
+	for i, req := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := exchange.BidRequest(context.Background(), req)
+			if err != nil {
+				t.Error(err)
+			}
+			resps[i] = resp
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("DSP queried %d times for one impression", n)
+	}
+	if len(resps[0].SeatBid) != 0 {
+		t.Errorf("pricier path won: %+v", resps[0])
+	}
+	if len(resps[1].SeatBid) != 1 || resps[1].SeatBid[0].Bid[0].ImpID != "imp-a" {
+		t.Fatalf("cheaper path didn't win on its own impression: %+v", resps[1])
+	}
+	if hits := atomic.LoadUint64(&spo.Hits); hits != 1 {
+		t.Errorf("hits = %d, want 1", hits)
+	}
+
+	// The impression is sold; a late copy gets nothing
+	if resp, _ := exchange.BidRequest(context.Background(), spoRequest("late", "ssp-other", "1")); len(resp.SeatBid) != 0 || requests.Load() != 1 {
+		t.Error("late copy of a sold impression was auctioned")
+	}
+
+	// Another viewer's impression is its own auction
+	other := spoRequest("other", "ssp-pricey", "1")
+	other.Device.IFA, other.Source.TID = "another-device", "tid-2"
+	if resp, _ := exchange.BidRequest(context.Background(), other); len(resp.SeatBid) != 1 || requests.Load() != 2 {
+		t.Error("distinct impression wasn't auctioned")
+	}
+}
+
+func TestImpressionSignature(t *testing.T) {
+	a, ok := impressionSignature(spoRequest("a", "ssp-1", "1"))
+	if !ok {
+		t.Fatal("no signature")
+	}
+	if b, _ := impressionSignature(spoRequest("b", "ssp-2", "9")); a != b {
+		t.Error("same impression over another path signed differently")
+	}
+
+	req := spoRequest("c", "ssp-1", "1")
+	req.Imp[0].Video.W = ptrInt64(640)
+	if c, _ := impressionSignature(req); c == a {
+		t.Error("different placement signed the same")
+	}
+
+	anon := spoRequest("d", "ssp-1", "1")
+	anon.Device, anon.Source = nil, nil
+	if _, ok := impressionSignature(anon); ok {
+		t.Error("request naming no device, user or transaction was signed")
+	}
+	multi := spoRequest("e", "ssp-1", "1")
+	multi.Imp = append(multi.Imp, openrtb2.Imp{ID: "2"})
+	if _, ok := impressionSignature(multi); ok {
+		t.Error("multi-slot request was signed")
+	}
+}
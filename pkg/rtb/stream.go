@@ -0,0 +1,261 @@
+package rtb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/luxfi/adx/pkg/jsonx"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+const (
+	// DefaultStreamInFlight bounds the requests awaiting bids on a stream
+	DefaultStreamInFlight = 64
+	// StreamRedialDelay is how long after a failed dial a stream stays
+	// down, its requests going over HTTP, before it's dialed again
+	StreamRedialDelay = time.Second
+)
+
+var (
+	// ErrStreamBusy is returned when a stream has MaxInFlight requests
+	// awaiting bids
+	ErrStreamBusy = errors.New("bid stream at its in-flight limit")
+	// ErrStreamUnavailable is returned when a stream can't be connected,
+	// or drops before answering
+	ErrStreamUnavailable = errors.New("bid stream unavailable")
+)
+
+// StreamFrame is a message on a bid stream. The exchange sends frames with
+// a Request; the DSP answers each with a frame carrying the same ID and
+// its Response, omitted for a no-bid. Answers may come in any order, and
+// are dropped once the request's TMax has passed.
+type StreamFrame struct {
+	ID       string                `json:"id"`
+	TMax     int64                 `json:"tmax,omitempty"` // Milliseconds left to answer
+	Request  *openrtb2.BidRequest  `json:"request,omitempty"`
+	Response *openrtb2.BidResponse `json:"response,omitempty"`
+}
+
+// BidStream sends bid requests to a DSP over one persistent WebSocket,
+// saving the connection setup of a request per auction. It dials on first
+// use and again after the connection drops.
+type BidStream struct {
+	URL         string
+	MaxInFlight int
+
+	// Dialer connects the stream; websocket.DefaultDialer when nil
+	Dialer *websocket.Dialer
+
+	// LateFrames counts answers after their deadline or to no request,
+	// updated atomically
+	LateFrames uint64
+
+	slots chan struct{}
+	seq   atomic.Uint64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	conn    *streamConn
+	retryAt time.Time
+	closed  bool
+}
+
+// streamConn is one connection of a stream and the requests sent on it
+type streamConn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex // gorilla/websocket allows one writer at a time
+
+	mu      sync.Mutex
+	pending map[string]chan streamAnswer
+	dropped bool
+}
+
+type streamAnswer struct {
+	resp *openrtb2.BidResponse
+	err  error
+}
+
+// NewBidStream returns a stream to url allowing maxInFlight requests at
+// once, DefaultStreamInFlight when it isn't positive
+func NewBidStream(url string, maxInFlight int) *BidStream {
+	if maxInFlight <= 0 {
+		maxInFlight = DefaultStreamInFlight
+	}
+	return &BidStream{
+		URL:         url,
+		MaxInFlight: maxInFlight,
+		slots:       make(chan struct{}, maxInFlight),
+		now:         time.Now,
+	}
+}
+
+// InFlight is how many requests are awaiting bids
+func (s *BidStream) InFlight() int {
+	return len(s.slots)
+}
+
+// Send pushes req to the DSP and waits for its answer until ctx is done.
+// It fails fast with ErrStreamBusy at the in-flight limit and with
+// ErrStreamUnavailable when the stream is down, so the caller can fall
+// back to HTTP.
+func (s *BidStream) Send(ctx context.Context, req *openrtb2.BidRequest) (*openrtb2.BidResponse, error) {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return nil, ErrStreamBusy
+	}
+	defer func() { <-s.slots }()
+
+	c, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strconv.FormatUint(s.seq.Add(1), 10)
+	answer := make(chan streamAnswer, 1)
+	c.mu.Lock()
+	if c.dropped {
+		c.mu.Unlock()
+		return nil, ErrStreamUnavailable
+	}
+	c.pending[id] = answer
+	c.mu.Unlock()
+	// An answer arriving after this finds no request, and is counted late
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	frame := StreamFrame{ID: id, Request: req}
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		frame.TMax = max(deadline.Sub(s.now()).Milliseconds(), 1)
+	}
+	data, err := jsonx.Marshal(&frame)
+	if err != nil {
+		return nil, err
+	}
+	c.writeMu.Lock()
+	c.ws.SetWriteDeadline(deadline)
+	err = c.ws.WriteMessage(websocket.TextMessage, data)
+	c.writeMu.Unlock()
+	if err != nil {
+		s.drop(c)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %v", ErrStreamUnavailable, err)
+	}
+
+	select {
+	case a := <-answer:
+		return a.resp, a.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// connect returns the stream's connection, dialing if there is none
+func (s *BidStream) connect(ctx context.Context) (*streamConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrStreamUnavailable
+	}
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	if s.now().Before(s.retryAt) {
+		return nil, ErrStreamUnavailable
+	}
+
+	dialer := s.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	ws, _, err := dialer.DialContext(ctx, s.URL, nil)
+	if err != nil {
+		s.retryAt = s.now().Add(StreamRedialDelay)
+		return nil, fmt.Errorf("%w: %v", ErrStreamUnavailable, err)
+	}
+	s.conn = &streamConn{ws: ws, pending: make(map[string]chan streamAnswer)}
+	go s.read(s.conn)
+	return s.conn, nil
+}
+
+// read matches the DSP's answers to the requests awaiting them until the
+// connection fails
+func (s *BidStream) read(c *streamConn) {
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			s.drop(c)
+			return
+		}
+		var f StreamFrame
+		if err := jsonx.Unmarshal(data, &f); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		answer, ok := c.pending[f.ID]
+		delete(c.pending, f.ID)
+		c.mu.Unlock()
+		if !ok {
+			atomic.AddUint64(&s.LateFrames, 1)
+			continue
+		}
+		answer <- streamAnswer{resp: f.Response}
+	}
+}
+
+// drop closes c, failing the requests awaiting answers on it; the next
+// request dials again
+func (s *BidStream) drop(c *streamConn) {
+	s.mu.Lock()
+	if s.conn == c {
+		s.conn = nil
+	}
+	s.mu.Unlock()
+
+	c.ws.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropped = true
+	for id, answer := range c.pending {
+		answer <- streamAnswer{err: ErrStreamUnavailable}
+		delete(c.pending, id)
+	}
+}
+
+// Close shuts the stream; requests sent after go over HTTP
+func (s *BidStream) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	c := s.conn
+	s.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	c.writeMu.Lock()
+	c.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), s.now().Add(time.Second))
+	c.writeMu.Unlock()
+	s.drop(c)
+	return nil
+}
+
+// sendStream asks the DSP for a bid over its stream
+func (dsp *DSPConnection) sendStream(ctx context.Context, req *openrtb2.BidRequest) (*Bid, error) {
+	resp, err := dsp.Stream.Send(ctx, req)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+	return dsp.bestBid(resp, dsp.Stream.URL), nil
+}
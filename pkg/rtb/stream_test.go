@@ -0,0 +1,236 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// streamDSP is a mock DSP serving a bid stream. The frames the exchange
+// sends arrive on the returned channel, and reply answers them.
+func streamDSP(t *testing.T) (url string, frames <-chan StreamFrame, reply func(StreamFrame)) {
+	t.Helper()
+	in := make(chan StreamFrame, 16)
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- ws
+		for {
+			var f StreamFrame
+			if err := ws.ReadJSON(&f); err != nil {
+				return
+			}
+			in <- f
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	var mu sync.Mutex
+	var ws *websocket.Conn
+	reply = func(f StreamFrame) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ws == nil {
+			ws = <-conns
+		}
+		if err := ws.WriteJSON(f); err != nil {
+			t.Error(err)
+		}
+	}
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), in, reply
+}
+
+func bidFor(f StreamFrame, price float64) StreamFrame {
+	return StreamFrame{ID: f.ID, Response: &openrtb2.BidResponse{
+		ID:      f.Request.ID,
+		SeatBid: []openrtb2.SeatBid{{Bid: []openrtb2.Bid{{ID: "bid-" + f.Request.ID, ImpID: "1", Price: price}}}},
+	}}
+}
+
+func TestBidStream_OutOfOrderAnswers(t *testing.T) {
+	url, frames, reply := streamDSP(t)
+	s := NewBidStream(url, 0)
+	defer s.Close()
+
+	type result struct {
+		resp *openrtb2.BidResponse
+		err  error
+	}
+	results := make(map[string]chan result)
+	for _, id := range []string{"auc-1", "auc-2", "auc-3"} {
+		ch := make(chan result, 1)
+		results[id] = ch
+		go func() {
+			resp, err := s.Send(context.Background(), &openrtb2.BidRequest{ID: id})
+			ch <- result{resp, err}
+		}()
+	}
+
+	// Answer in reverse order of arrival
+	var got []StreamFrame
+	for range results {
+		f := <-frames
+		if f.Request == nil || f.ID == "" {
+			t.Fatalf("frame = %+v, want a request with a correlation ID", f)
+		}
+		got = append(got, f)
+	}
+	if s.InFlight() != 3 {
+		t.Errorf("in flight = %d, want 3", s.InFlight())
+	}
+	for i := len(got) - 1; i >= 0; i-- {
+		reply(bidFor(got[i], float64(i+1)))
+	}
+
+	for id, ch := range results {
+		r := <-ch
+		if r.err != nil {
+			t.Fatalf("%s: %v", id, r.err)
+		}
+		if r.resp.ID != id || r.resp.SeatBid[0].Bid[0].ID != "bid-"+id {
+			t.Errorf("%s answered with %+v", id, r.resp)
+		}
+	}
+	if n := atomic.LoadUint64(&s.LateFrames); n != 0 {
+		t.Errorf("late frames = %d", n)
+	}
+}
+
+func TestBidStream_DeadlineAndLateFrames(t *testing.T) {
+	url, frames, reply := streamDSP(t)
+	s := NewBidStream(url, 0)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := s.Send(ctx, &openrtb2.BidRequest{ID: "slow"})
+		errc <- err
+	}()
+	slow := <-frames
+	if slow.TMax <= 0 || slow.TMax > 50 {
+		t.Errorf("tmax = %d, want the time left before the deadline", slow.TMax)
+	}
+	if err := <-errc; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the deadline", err)
+	}
+	if took := time.Since(start); took > 500*time.Millisecond {
+		t.Errorf("gave up after %s", took)
+	}
+
+	// The answer comes after the deadline and is ignored; the next request
+	// on the same connection gets its own answer
+	reply(bidFor(slow, 9))
+	next := make(chan *openrtb2.BidResponse, 1)
+	go func() {
+		resp, _ := s.Send(context.Background(), &openrtb2.BidRequest{ID: "next"})
+		next <- resp
+	}()
+	reply(bidFor(<-frames, 1))
+	if resp := <-next; resp == nil || resp.ID != "next" {
+		t.Errorf("next request answered with %+v", resp)
+	}
+	if n := atomic.LoadUint64(&s.LateFrames); n != 1 {
+		t.Errorf("late frames = %d, want 1", n)
+	}
+}
+
+func TestSendBidRequest_StreamFallsBackToHTTP(t *testing.T) {
+	var httpCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpCalls.Add(1)
+		json.NewEncoder(w).Encode(openrtb2.BidResponse{SeatBid: []openrtb2.SeatBid{{Bid: []openrtb2.Bid{{ID: "http", ImpID: "1", Price: 1}}}}})
+	}))
+	defer srv.Close()
+	req := &openrtb2.BidRequest{ID: "auc-1", Imp: []openrtb2.Imp{{ID: "1"}}}
+
+	t.Run("no stream listening", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		dsp := &DSPConnection{ID: "dsp", Endpoint: srv.URL, Timeout: time.Second, Stream: NewBidStream("ws"+strings.TrimPrefix(down.URL, "http"), 0)}
+		bid, err := dsp.SendBidRequest(context.Background(), req)
+		if err != nil || bid == nil || bid.ID != "http" {
+			t.Errorf("bid = %+v, %v; want the HTTP bid", bid, err)
+		}
+	})
+
+	t.Run("in-flight limit", func(t *testing.T) {
+		url, frames, reply := streamDSP(t)
+		dsp := &DSPConnection{ID: "dsp", Endpoint: srv.URL, Timeout: time.Second, Stream: NewBidStream(url, 1)}
+		defer dsp.Stream.Close()
+
+		first := make(chan *Bid, 1)
+		go func() {
+			bid, _ := dsp.SendBidRequest(context.Background(), req)
+			first <- bid
+		}()
+		held := <-frames
+		calls := httpCalls.Load()
+		if bid, err := dsp.SendBidRequest(context.Background(), req); err != nil || bid == nil || bid.ID != "http" {
+			t.Errorf("over the limit = %+v, %v; want the HTTP bid", bid, err)
+		}
+		if httpCalls.Load() != calls+1 {
+			t.Error("request over the limit didn't go over HTTP")
+		}
+		reply(bidFor(held, 3))
+		if bid := <-first; bid == nil || bid.ID != "bid-auc-1" || bid.Endpoint != url {
+			t.Errorf("streamed bid = %+v", bid)
+		}
+	})
+}
+
+func TestConnectDSP_KeepsStream(t *testing.T) {
+	spec := DSPSpec{ID: "dsp", Endpoint: "http://dsp.example/bid", QPS: 10, TimeoutMS: 100, Stream: "ws://dsp.example/stream"}
+	if err := spec.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d := connectDSP(spec, nil)
+	if d.Stream == nil || d.Stream.MaxInFlight != DefaultStreamInFlight {
+		t.Fatalf("stream = %+v", d.Stream)
+	}
+	spec.QPS = 20
+	if next := connectDSP(spec, d); next.Stream != d.Stream {
+		t.Error("unchanged stream replaced")
+	}
+	if got := d.Spec(); got.Stream != spec.Stream || got.StreamInFlight != 0 {
+		t.Errorf("spec = %+v", got)
+	}
+
+	spec.Stream = "http://dsp.example/stream"
+	if err := spec.Validate(); err == nil {
+		t.Error("http stream URL accepted")
+	}
+}
+
+func TestRemoveDSP_ClosesStream(t *testing.T) {
+	exchange := &RTBExchange{}
+	spec := DSPSpec{ID: "dsp", Endpoint: "http://dsp.example/bid", QPS: 10, TimeoutMS: 100, Stream: "ws://dsp.example/stream"}
+	if err := exchange.AddDSP(spec); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := exchange.DSP("dsp")
+	if err := exchange.RemoveDSP("dsp"); err != nil {
+		t.Fatal(err)
+	}
+	d.Stream.mu.Lock()
+	closed := d.Stream.closed
+	d.Stream.mu.Unlock()
+	if !closed {
+		t.Error("removed DSP's stream left open")
+	}
+}
@@ -0,0 +1,519 @@
+package rtb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+// Seller types from the IAB sellers.json spec
+const (
+	SellerPublisher    = "PUBLISHER"
+	SellerIntermediary = "INTERMEDIARY"
+	SellerBoth         = "BOTH"
+)
+
+// DefaultAdsTxtTTL is how long a fetched ads.txt is trusted
+const DefaultAdsTxtTTL = 24 * time.Hour
+
+// maxAdsTxtSize caps how much of an ads.txt file is read
+const maxAdsTxtSize = 1 << 20
+
+// maxSellersJSONSize caps how much of another ad system's sellers.json is
+// read; the largest list hundreds of thousands of sellers
+const maxSellersJSONSize = 64 << 20
+
+var (
+	ErrUnauthorizedSeller = errors.New("seller not authorized in ads.txt")
+	ErrUnknownSeller      = errors.New("seller not in sellers.json")
+	ErrInvalidSChain      = errors.New("malformed schain")
+	ErrIncompleteSChain   = errors.New("schain incomplete")
+	ErrUnauthorizedNode   = errors.New("schain node not an authorized seller")
+)
+
+// Seller is one entry in sellers.json
+type Seller struct {
+	SellerID       string `json:"seller_id"`
+	Name           string `json:"name,omitempty"`
+	Domain         string `json:"domain,omitempty"`
+	SellerType     string `json:"seller_type"`
+	IsConfidential int    `json:"is_confidential,omitempty"`
+	Comment        string `json:"comment,omitempty"`
+}
+
+// SellersJSON is the sellers.json document
+type SellersJSON struct {
+	ContactEmail   string       `json:"contact_email,omitempty"`
+	ContactAddress string       `json:"contact_address,omitempty"`
+	Version        string       `json:"version"`
+	Identifiers    []Identifier `json:"identifiers,omitempty"`
+	Sellers        []Seller     `json:"sellers"`
+}
+
+// Identifier is a business identifier listed in sellers.json
+type Identifier struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SChainPolicy is which inbound schains are rejected. Either way a chain
+// must be well formed, and one marked complete must start at a publisher.
+type SChainPolicy struct {
+	// RequireComplete rejects chains that don't reach back to the publisher
+	RequireComplete bool
+	// RejectUnknown rejects chains with a node its ad system's sellers.json
+	// doesn't list, or that can't be checked
+	RejectUnknown bool
+}
+
+// SupplyChain holds the exchange's seller relationships. It publishes them
+// as sellers.json, checks inbound schains, adds the exchange's node to
+// outgoing ones and, with AdsTxt set, only accepts inventory whose ads.txt
+// authorizes the seller.
+type SupplyChain struct {
+	ASI            string // Exchange's canonical domain, as publishers list it in ads.txt
+	ContactEmail   string
+	ContactAddress string
+	Identifiers    []Identifier
+	AdsTxt         *AdsTxtValidator
+
+	// Sellers looks up schain nodes on other ad systems; nodes only ours
+	// can vouch for are known when nil
+	Sellers      *SellersJSONCache
+	SChainPolicy SChainPolicy
+
+	// UnknownNodes counts schain nodes accepted unverified, updated
+	// atomically
+	UnknownNodes uint64
+
+	mu      sync.RWMutex
+	sellers map[string]Seller
+}
+
+// NewSupplyChain creates a supply chain for the exchange at asi
+func NewSupplyChain(asi string) *SupplyChain {
+	return &SupplyChain{ASI: asi, sellers: make(map[string]Seller)}
+}
+
+// AddSeller lists a seller; publisher IDs on bid requests are seller IDs
+func (sc *SupplyChain) AddSeller(s Seller) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.sellers[s.SellerID] = s
+}
+
+// Seller looks up a seller by ID
+func (sc *SupplyChain) Seller(id string) (Seller, bool) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	s, ok := sc.sellers[id]
+	return s, ok
+}
+
+// SellersJSON returns the sellers.json document, sellers sorted by ID
+func (sc *SupplyChain) SellersJSON() SellersJSON {
+	sc.mu.RLock()
+	sellers := make([]Seller, 0, len(sc.sellers))
+	for _, s := range sc.sellers {
+		if s.IsConfidential == 1 {
+			s.Name, s.Domain = "", ""
+		}
+		sellers = append(sellers, s)
+	}
+	sc.mu.RUnlock()
+	sort.Slice(sellers, func(i, j int) bool { return sellers[i].SellerID < sellers[j].SellerID })
+
+	return SellersJSON{
+		ContactEmail:   sc.ContactEmail,
+		ContactAddress: sc.ContactAddress,
+		Version:        "1.0",
+		Identifiers:    sc.Identifiers,
+		Sellers:        sellers,
+	}
+}
+
+// ServeHTTP serves sellers.json
+func (sc *SupplyChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(sc.SellersJSON())
+}
+
+// Authorize checks that req comes from a listed seller and, when AdsTxt
+// is set, that the publisher's ads.txt (app-ads.txt for apps) authorizes
+// that seller on this exchange
+func (sc *SupplyChain) Authorize(ctx context.Context, req *openrtb2.BidRequest) error {
+	sellerID, domain, app := publisherOf(req)
+	if _, ok := sc.Seller(sellerID); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownSeller, sellerID)
+	}
+	if sc.AdsTxt == nil {
+		return nil
+	}
+	ok, err := sc.AdsTxt.Authorized(ctx, domain, sc.ASI, sellerID, app)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s for %s", ErrUnauthorizedSeller, sellerID, domain)
+	}
+	return nil
+}
+
+// ValidateSChain checks the schain req arrived with, read from
+// source.schain or, as OpenRTB 2.5 sends it, source.ext.schain, which is
+// moved to source.schain. Each node's seller is looked up in its ad
+// system's sellers.json, ours for our own nodes. A chain marked complete
+// must start at a publisher with only intermediaries after it; unknown
+// nodes and incomplete chains are rejected as SChainPolicy says. A
+// request without a chain is the publisher's own and passes.
+func (sc *SupplyChain) ValidateSChain(ctx context.Context, req *openrtb2.BidRequest) error {
+	chain, err := inboundSChain(req)
+	if err != nil || chain == nil {
+		return err
+	}
+	if len(chain.Nodes) == 0 || chain.Complete < 0 || chain.Complete > 1 {
+		return fmt.Errorf("%w: %d nodes, complete=%d", ErrInvalidSChain, len(chain.Nodes), chain.Complete)
+	}
+	if chain.Complete != 1 && sc.SChainPolicy.RequireComplete {
+		return ErrIncompleteSChain
+	}
+	for i, node := range chain.Nodes {
+		if node.ASI == "" || node.SID == "" {
+			return fmt.Errorf("%w: node %d lacks asi or sid", ErrInvalidSChain, i)
+		}
+		seller, ok, err := sc.nodeSeller(ctx, node)
+		if !ok {
+			if sc.SChainPolicy.RejectUnknown {
+				if err != nil {
+					return fmt.Errorf("%w: %s on %s: %v", ErrUnauthorizedNode, node.SID, node.ASI, err)
+				}
+				return fmt.Errorf("%w: %s on %s", ErrUnauthorizedNode, node.SID, node.ASI)
+			}
+			atomic.AddUint64(&sc.UnknownNodes, 1)
+			continue
+		}
+		if chain.Complete != 1 {
+			continue
+		}
+		if i == 0 && seller.SellerType == SellerIntermediary {
+			return fmt.Errorf("%w: starts at intermediary %s on %s", ErrIncompleteSChain, node.SID, node.ASI)
+		}
+		if i > 0 && seller.SellerType == SellerPublisher {
+			return fmt.Errorf("%w: publisher %s on %s mid-chain", ErrInvalidSChain, node.SID, node.ASI)
+		}
+	}
+	return nil
+}
+
+// nodeSeller looks up the seller behind an schain node
+func (sc *SupplyChain) nodeSeller(ctx context.Context, node openrtb2.SupplyChainNode) (Seller, bool, error) {
+	if strings.EqualFold(node.ASI, sc.ASI) {
+		s, ok := sc.Seller(node.SID)
+		return s, ok, nil
+	}
+	if sc.Sellers == nil {
+		return Seller{}, false, nil
+	}
+	return sc.Sellers.Seller(ctx, node.ASI, node.SID)
+}
+
+// inboundSChain returns req's schain, moving one sent in source.ext to
+// source.schain
+func inboundSChain(req *openrtb2.BidRequest) (*openrtb2.SupplyChain, error) {
+	if req.Source == nil {
+		return nil, nil
+	}
+	if req.Source.SChain != nil || len(req.Source.Ext) == 0 {
+		return req.Source.SChain, nil
+	}
+	var ext struct {
+		SChain *openrtb2.SupplyChain `json:"schain"`
+	}
+	if err := json.Unmarshal(req.Source.Ext, &ext); err != nil {
+		return nil, fmt.Errorf("%w: source.ext: %v", ErrInvalidSChain, err)
+	}
+	req.Source.SChain = ext.SChain
+	return ext.SChain, nil
+}
+
+// AppendNode adds the exchange's hop to req's schain, starting one if the
+// publisher didn't send it. A chain we start is complete only when the
+// seller is the publisher itself.
+func (sc *SupplyChain) AppendNode(req *openrtb2.BidRequest) {
+	sellerID, _, _ := publisherOf(req)
+	if req.Source == nil {
+		req.Source = &openrtb2.Source{}
+	}
+	if req.Source.SChain == nil {
+		complete := int8(0)
+		if s, ok := sc.Seller(sellerID); ok && s.SellerType != SellerIntermediary {
+			complete = 1
+		}
+		req.Source.SChain = &openrtb2.SupplyChain{Complete: complete, Ver: "1.0"}
+	}
+	hp := int8(1)
+	req.Source.SChain.Nodes = append(req.Source.SChain.Nodes, openrtb2.SupplyChainNode{
+		ASI: sc.ASI,
+		SID: sellerID,
+		RID: req.ID,
+		HP:  &hp,
+	})
+}
+
+// publisherOf returns the request's publisher ID, publisher domain and
+// whether it's app inventory
+func publisherOf(req *openrtb2.BidRequest) (id, domain string, app bool) {
+	switch {
+	case req.Site != nil:
+		domain = req.Site.Domain
+		if req.Site.Publisher != nil {
+			id = req.Site.Publisher.ID
+			if domain == "" {
+				domain = req.Site.Publisher.Domain
+			}
+		}
+	case req.App != nil:
+		app = true
+		domain = req.App.Domain
+		if req.App.Publisher != nil {
+			id = req.App.Publisher.ID
+			if domain == "" {
+				domain = req.App.Publisher.Domain
+			}
+		}
+	}
+	return id, strings.ToLower(domain), app
+}
+
+// AdsTxtRecord is one authorized-seller line of an ads.txt file
+type AdsTxtRecord struct {
+	Domain       string // Advertising system domain
+	AccountID    string // Seller ID on that system
+	Relationship string // DIRECT or RESELLER
+	CertID       string
+}
+
+// ParseAdsTxt reads ads.txt records, skipping comments and variables
+func ParseAdsTxt(r io.Reader) ([]AdsTxtRecord, error) {
+	var records []AdsTxtRecord
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || (strings.Contains(line, "=") && !strings.Contains(line, ",")) {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			continue
+		}
+		rec := AdsTxtRecord{
+			Domain:       strings.ToLower(strings.TrimSpace(fields[0])),
+			AccountID:    strings.TrimSpace(fields[1]),
+			Relationship: strings.ToUpper(strings.TrimSpace(fields[2])),
+		}
+		if len(fields) > 3 {
+			rec.CertID = strings.TrimSpace(fields[3])
+		}
+		records = append(records, rec)
+	}
+	return records, sc.Err()
+}
+
+// AdsTxtValidator fetches and caches publishers' ads.txt and app-ads.txt
+type AdsTxtValidator struct {
+	TTL    time.Duration
+	Client *http.Client
+
+	// BaseURL maps a publisher domain to the origin its files are fetched
+	// from; https://<domain> when nil
+	BaseURL func(domain string) string
+
+	mu    sync.Mutex
+	cache map[string]adsTxtEntry
+	now   func() time.Time
+}
+
+type adsTxtEntry struct {
+	records []AdsTxtRecord
+	expires time.Time
+}
+
+// NewAdsTxtValidator creates a validator caching files for ttl
+func NewAdsTxtValidator(ttl time.Duration) *AdsTxtValidator {
+	if ttl <= 0 {
+		ttl = DefaultAdsTxtTTL
+	}
+	return &AdsTxtValidator{TTL: ttl, cache: make(map[string]adsTxtEntry), now: time.Now}
+}
+
+// Authorized reports whether domain's ads.txt (or app-ads.txt) lists
+// sellerID on the ad system asi
+func (v *AdsTxtValidator) Authorized(ctx context.Context, domain, asi, sellerID string, app bool) (bool, error) {
+	if domain == "" {
+		return false, nil
+	}
+	file := "ads.txt"
+	if app {
+		file = "app-ads.txt"
+	}
+	records, err := v.records(ctx, domain, file)
+	if err != nil {
+		return false, err
+	}
+	asi = strings.ToLower(asi)
+	for _, rec := range records {
+		if rec.Domain == asi && rec.AccountID == sellerID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (v *AdsTxtValidator) records(ctx context.Context, domain, file string) ([]AdsTxtRecord, error) {
+	key := domain + "/" + file
+	v.mu.Lock()
+	entry, ok := v.cache[key]
+	v.mu.Unlock()
+	if ok && v.now().Before(entry.expires) {
+		return entry.records, nil
+	}
+
+	records, err := v.fetch(ctx, domain, file)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	v.cache[key] = adsTxtEntry{records: records, expires: v.now().Add(v.TTL)}
+	v.mu.Unlock()
+	return records, nil
+}
+
+// fetch downloads a file; a missing file authorizes nobody
+func (v *AdsTxtValidator) fetch(ctx context.Context, domain, file string) ([]AdsTxtRecord, error) {
+	base := "https://" + domain
+	if v.BaseURL != nil {
+		base = v.BaseURL(domain)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/"+file, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("fetch %s: status %d", req.URL, resp.StatusCode)
+	}
+	return ParseAdsTxt(io.LimitReader(resp.Body, maxAdsTxtSize))
+}
+
+// SellersJSONCache fetches and caches other ad systems' sellers.json, to
+// check the nodes of inbound schains
+type SellersJSONCache struct {
+	TTL    time.Duration
+	Client *http.Client
+
+	// BaseURL maps an ad system domain to the origin its sellers.json is
+	// fetched from; https://<domain> when nil
+	BaseURL func(domain string) string
+
+	mu    sync.Mutex
+	cache map[string]sellersEntry
+	now   func() time.Time
+}
+
+type sellersEntry struct {
+	sellers map[string]Seller
+	expires time.Time
+}
+
+// NewSellersJSONCache creates a cache keeping files for ttl
+func NewSellersJSONCache(ttl time.Duration) *SellersJSONCache {
+	if ttl <= 0 {
+		ttl = DefaultAdsTxtTTL
+	}
+	return &SellersJSONCache{TTL: ttl, cache: make(map[string]sellersEntry), now: time.Now}
+}
+
+// Seller looks up sellerID in the sellers.json of the ad system asi
+func (c *SellersJSONCache) Seller(ctx context.Context, asi, sellerID string) (Seller, bool, error) {
+	asi = strings.ToLower(asi)
+	c.mu.Lock()
+	entry, ok := c.cache[asi]
+	c.mu.Unlock()
+	if !ok || !c.now().Before(entry.expires) {
+		sellers, err := c.fetch(ctx, asi)
+		if err != nil {
+			return Seller{}, false, err
+		}
+		entry = sellersEntry{sellers: sellers, expires: c.now().Add(c.TTL)}
+		c.mu.Lock()
+		c.cache[asi] = entry
+		c.mu.Unlock()
+	}
+	s, ok := entry.sellers[sellerID]
+	return s, ok, nil
+}
+
+// fetch downloads a sellers.json; a missing file lists nobody
+func (c *SellersJSONCache) fetch(ctx context.Context, domain string) (map[string]Seller, error) {
+	base := "https://" + domain
+	if c.BaseURL != nil {
+		base = c.BaseURL(domain)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/sellers.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("fetch %s: status %d", req.URL, resp.StatusCode)
+	}
+	var doc SellersJSON
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSellersJSONSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("fetch %s: %v", req.URL, err)
+	}
+	sellers := make(map[string]Seller, len(doc.Sellers))
+	for _, s := range doc.Sellers {
+		sellers[s.SellerID] = s
+	}
+	return sellers, nil
+}
@@ -0,0 +1,264 @@
+package rtb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+)
+
+const testAdsTxt = `# ads.txt for news.example
+contact=ads@news.example
+lux.network, pub-1, DIRECT, f08c47fec0942fa0
+othersystem.com, 9999, RESELLER
+LUX.NETWORK, pub-reseller, RESELLER # resold through a partner
+`
+
+func newTestSupplyChain(t *testing.T) (*SupplyChain, *int32) {
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if r.URL.Path == "/ads.txt" {
+			w.Write([]byte(testAdsTxt))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	sc := NewSupplyChain("lux.network")
+	sc.ContactEmail = "adops@lux.network"
+	sc.AddSeller(Seller{SellerID: "pub-1", Name: "News Example", Domain: "news.example", SellerType: SellerPublisher})
+	sc.AddSeller(Seller{SellerID: "pub-2", Name: "Other", Domain: "other.example", SellerType: SellerPublisher})
+	sc.AddSeller(Seller{SellerID: "net-1", Name: "Secret Network", Domain: "secret.example", SellerType: SellerIntermediary, IsConfidential: 1})
+	sc.AdsTxt = NewAdsTxtValidator(time.Hour)
+	sc.AdsTxt.BaseURL = func(string) string { return srv.URL }
+	return sc, &fetches
+}
+
+func siteRequest(publisherID string) *openrtb2.BidRequest {
+	return &openrtb2.BidRequest{
+		ID:   "req-1",
+		Imp:  []openrtb2.Imp{{ID: "1"}},
+		Site: &openrtb2.Site{Domain: "news.example", Publisher: &openrtb2.Publisher{ID: publisherID}},
+	}
+}
+
+func TestSupplyChain_AdsTxtAuthorization(t *testing.T) {
+	sc, fetches := newTestSupplyChain(t)
+	ctx := context.Background()
+
+	if err := sc.Authorize(ctx, siteRequest("pub-1")); err != nil {
+		t.Errorf("authorized seller rejected: %v", err)
+	}
+	if err := sc.Authorize(ctx, siteRequest("pub-2")); !errors.Is(err, ErrUnauthorizedSeller) {
+		t.Errorf("seller missing from ads.txt: err = %v", err)
+	}
+	if err := sc.Authorize(ctx, siteRequest("pub-unknown")); !errors.Is(err, ErrUnknownSeller) {
+		t.Errorf("unlisted seller: err = %v", err)
+	}
+	if n := atomic.LoadInt32(fetches); n != 1 {
+		t.Errorf("ads.txt fetched %d times, want 1 (cached)", n)
+	}
+
+	// The cache expires after its TTL
+	sc.AdsTxt.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	sc.Authorize(ctx, siteRequest("pub-1"))
+	if n := atomic.LoadInt32(fetches); n != 2 {
+		t.Errorf("ads.txt fetched %d times after TTL, want 2", n)
+	}
+
+	// Apps are checked against app-ads.txt, which this publisher lacks
+	app := siteRequest("pub-1")
+	app.Site, app.App = nil, &openrtb2.App{Domain: "news.example", Publisher: &openrtb2.Publisher{ID: "pub-1"}}
+	if err := sc.Authorize(ctx, app); !errors.Is(err, ErrUnauthorizedSeller) {
+		t.Errorf("app without app-ads.txt: err = %v", err)
+	}
+}
+
+func TestSupplyChain_RejectsUnauthorizedInventory(t *testing.T) {
+	sc, _ := newTestSupplyChain(t)
+	exchange := &RTBExchange{
+		DSPs:           make(map[string]*DSPConnection),
+		AuctionTimeout: 10 * time.Millisecond,
+		Revenue:        big.NewInt(0),
+		SupplyChain:    sc,
+	}
+
+	if _, err := exchange.BidRequest(context.Background(), siteRequest("pub-2")); !errors.Is(err, ErrUnauthorizedSeller) {
+		t.Errorf("err = %v, want ErrUnauthorizedSeller", err)
+	}
+
+	req := siteRequest("pub-1")
+	if _, err := exchange.BidRequest(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	schain := req.Source.SChain
+	if schain == nil || schain.Complete != 1 || len(schain.Nodes) != 1 {
+		t.Fatalf("schain = %+v", schain)
+	}
+	if n := schain.Nodes[0]; n.ASI != "lux.network" || n.SID != "pub-1" || n.RID != "req-1" || *n.HP != 1 {
+		t.Errorf("node = %+v", n)
+	}
+
+	// An upstream chain is extended, not replaced
+	req = siteRequest("pub-1")
+	req.Source = &openrtb2.Source{SChain: &openrtb2.SupplyChain{Ver: "1.0", Nodes: []openrtb2.SupplyChainNode{{ASI: "upstream.example", SID: "x"}}}}
+	sc.AppendNode(req)
+	if nodes := req.Source.SChain.Nodes; len(nodes) != 2 || nodes[1].ASI != "lux.network" {
+		t.Errorf("nodes = %+v", nodes)
+	}
+}
+
+func TestSupplyChain_SellersJSON(t *testing.T) {
+	sc, _ := newTestSupplyChain(t)
+
+	w := httptest.NewRecorder()
+	sc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sellers.json", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("content type = %q", ct)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["version"] != "1.0" || doc["contact_email"] != "adops@lux.network" {
+		t.Errorf("header fields = %v", doc)
+	}
+	sellers := doc["sellers"].([]any)
+	if len(sellers) != 3 {
+		t.Fatalf("sellers = %v", sellers)
+	}
+	valid := map[string]bool{SellerPublisher: true, SellerIntermediary: true, SellerBoth: true}
+	for i, raw := range sellers {
+		s := raw.(map[string]any)
+		if s["seller_id"] == "" || !valid[s["seller_type"].(string)] {
+			t.Errorf("seller %d malformed: %v", i, s)
+		}
+		if s["seller_id"] == "net-1" && (s["is_confidential"] != 1.0 || s["name"] != nil || s["domain"] != nil) {
+			t.Errorf("confidential seller leaks identity: %v", s)
+		}
+	}
+	if sellers[0].(map[string]any)["seller_id"] != "net-1" {
+		t.Error("sellers should be sorted by ID")
+	}
+}
+
+func TestParseAdsTxt(t *testing.T) {
+	records, err := ParseAdsTxt(strings.NewReader(testAdsTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records = %+v", records)
+	}
+	if r := records[0]; r.Domain != "lux.network" || r.AccountID != "pub-1" || r.Relationship != "DIRECT" || r.CertID != "f08c47fec0942fa0" {
+		t.Errorf("record = %+v", r)
+	}
+	if r := records[2]; r.Domain != "lux.network" || r.Relationship != "RESELLER" {
+		t.Errorf("record = %+v", r)
+	}
+}
+
+// resellerSellersJSON is the sellers.json of an upstream SSP: a publisher
+// and a reseller network sell through it
+const resellerSellersJSON = `{"version": "1.0", "sellers": [
+	{"seller_id": "site-9", "seller_type": "PUBLISHER", "domain": "news.example"},
+	{"seller_id": "net-7", "seller_type": "INTERMEDIARY", "domain": "network.example"}
+]}`
+
+func schainRequest(complete int8, nodes ...openrtb2.SupplyChainNode) *openrtb2.BidRequest {
+	req := siteRequest("net-1")
+	req.Source = &openrtb2.Source{SChain: &openrtb2.SupplyChain{Complete: complete, Ver: "1.0", Nodes: nodes}}
+	return req
+}
+
+func TestSupplyChain_ValidateSChain(t *testing.T) {
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if r.URL.Path == "/ssp.example/sellers.json" {
+			w.Write([]byte(resellerSellersJSON))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	sc, _ := newTestSupplyChain(t)
+	sc.AdsTxt = nil
+	sc.Sellers = NewSellersJSONCache(time.Hour)
+	sc.Sellers.BaseURL = func(domain string) string { return srv.URL + "/" + domain }
+	ctx := context.Background()
+
+	publisher := openrtb2.SupplyChainNode{ASI: "ssp.example", SID: "site-9"}
+	reseller := openrtb2.SupplyChainNode{ASI: "ssp.example", SID: "net-7"}
+	rogue := openrtb2.SupplyChainNode{ASI: "rogue.example", SID: "r-1"}
+
+	// A complete chain of authorized sellers passes under the strictest
+	// policy, and is extended with our node
+	sc.SChainPolicy = SChainPolicy{RequireComplete: true, RejectUnknown: true}
+	exchange := &RTBExchange{
+		DSPs:           make(map[string]*DSPConnection),
+		AuctionTimeout: 10 * time.Millisecond,
+		Revenue:        big.NewInt(0),
+		SupplyChain:    sc,
+	}
+	req := schainRequest(1, publisher, reseller)
+	if _, err := exchange.BidRequest(ctx, req); err != nil {
+		t.Fatalf("authorized chain rejected: %v", err)
+	}
+	if nodes := req.Source.SChain.Nodes; len(nodes) != 3 || nodes[2].ASI != "lux.network" || nodes[2].SID != "net-1" || req.Source.SChain.Complete != 1 {
+		t.Errorf("forwarded chain = %+v", req.Source.SChain)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("sellers.json fetched %d times, want 1 (cached)", n)
+	}
+
+	// An incomplete chain is rejected when completeness is required
+	if err := sc.ValidateSChain(ctx, schainRequest(0, reseller)); !errors.Is(err, ErrIncompleteSChain) {
+		t.Errorf("incomplete chain: err = %v", err)
+	}
+	// A chain claiming to be complete must start at a publisher
+	if err := sc.ValidateSChain(ctx, schainRequest(1, reseller)); !errors.Is(err, ErrIncompleteSChain) {
+		t.Errorf("complete chain starting at a reseller: err = %v", err)
+	}
+	// An intermediary missing from its ad system's sellers.json is rejected
+	if _, err := exchange.BidRequest(ctx, schainRequest(1, publisher, rogue)); !errors.Is(err, ErrUnauthorizedNode) {
+		t.Errorf("unauthorized intermediary: err = %v", err)
+	}
+	if err := sc.ValidateSChain(ctx, schainRequest(1, publisher, openrtb2.SupplyChainNode{ASI: "ssp.example"})); !errors.Is(err, ErrInvalidSChain) {
+		t.Errorf("node without sid: err = %v", err)
+	}
+
+	// The lenient policy takes both, counting the node it couldn't verify
+	sc.SChainPolicy = SChainPolicy{}
+	if err := sc.ValidateSChain(ctx, schainRequest(0, reseller)); err != nil {
+		t.Errorf("incomplete chain: err = %v", err)
+	}
+	if err := sc.ValidateSChain(ctx, schainRequest(1, publisher, rogue)); err != nil {
+		t.Errorf("unknown intermediary: err = %v", err)
+	}
+	if n := atomic.LoadUint64(&sc.UnknownNodes); n != 1 {
+		t.Errorf("unknown nodes = %d, want 1", n)
+	}
+
+	// OpenRTB 2.5 puts the chain in source.ext
+	req = siteRequest("net-1")
+	req.Source = &openrtb2.Source{Ext: json.RawMessage(`{"schain": {"complete": 1, "ver": "1.0", "nodes": [{"asi": "ssp.example", "sid": "site-9", "hp": 1}]}}`)}
+	if err := sc.ValidateSChain(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Source.SChain == nil || len(req.Source.SChain.Nodes) != 1 || req.Source.SChain.Nodes[0].SID != "site-9" {
+		t.Errorf("ext chain not moved to source.schain: %+v", req.Source.SChain)
+	}
+}
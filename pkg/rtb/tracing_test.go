@@ -0,0 +1,101 @@
+package rtb
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/reqlog"
+	"github.com/luxfi/adx/pkg/tracing"
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBidRequest_SpanTree(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	exchange := &RTBExchange{
+		DSPs:           make(map[string]*DSPConnection),
+		AuctionTimeout: 100 * time.Millisecond,
+		Revenue:        big.NewInt(0),
+	}
+	for _, id := range []string{"dsp-a", "dsp-b"} {
+		exchange.DSPs[id] = &DSPConnection{
+			ID:          id,
+			RateLimiter: &RateLimiter{tokens: 1, max: 1, refill: time.Second, lastRefill: time.Now()},
+		}
+	}
+	exchange.DSPs["dsp-b"].RateLimiter.tokens = 0
+
+	ctx := reqlog.WithID(context.Background(), "req-1")
+	if _, err := exchange.BidRequest(ctx, &openrtb2.BidRequest{ID: "auc-1", Imp: []openrtb2.Imp{{ID: "1"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	byName := map[string][]tracetest.SpanStub{}
+	for _, s := range spans {
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+	if len(byName["rtb.BidRequest"]) != 1 || len(byName["rtb.collectBids"]) != 1 ||
+		len(byName["rtb.runAuction"]) != 1 || len(byName["rtb.dsp"]) != 2 {
+		t.Fatalf("unexpected spans: %v", names(spans))
+	}
+
+	root := byName["rtb.BidRequest"][0]
+	if root.Parent.IsValid() {
+		t.Error("rtb.BidRequest should be the root span")
+	}
+	if got := attr(root.Attributes, tracing.AttrRequestID); got != "req-1" {
+		t.Errorf("request ID attribute = %q", got)
+	}
+	collect := byName["rtb.collectBids"][0]
+	for _, s := range []tracetest.SpanStub{collect, byName["rtb.runAuction"][0]} {
+		if s.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("%s parent = %s, want rtb.BidRequest", s.Name, s.Parent.SpanID())
+		}
+	}
+
+	outcomes := map[string]string{}
+	for _, s := range byName["rtb.dsp"] {
+		if s.Parent.SpanID() != collect.SpanContext.SpanID() {
+			t.Errorf("DSP span parent = %s, want rtb.collectBids", s.Parent.SpanID())
+		}
+		if attr(s.Attributes, tracing.AttrLatencyMS) == "" {
+			t.Error("DSP span missing latency")
+		}
+		outcomes[attr(s.Attributes, tracing.AttrDSP)] = attr(s.Attributes, tracing.AttrOutcome)
+	}
+	if outcomes["dsp-a"] != tracing.OutcomeNoBid || outcomes["dsp-b"] != tracing.OutcomeRateLimited {
+		t.Errorf("DSP outcomes = %v", outcomes)
+	}
+
+	if link, ok := tracing.AuctionLink("req-1"); !ok || link.SpanContext.SpanID() != root.SpanContext.SpanID() {
+		t.Error("auction span not recorded for settlement linking")
+	}
+}
+
+func attr(kvs []attribute.KeyValue, key attribute.Key) string {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func names(spans tracetest.SpanStubs) []string {
+	out := make([]string, len(spans))
+	for i, s := range spans {
+		out[i] = s.Name
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package rtb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+)
+
+// ErrInvalidBidRequest is wrapped by every RequestError
+var ErrInvalidBidRequest = errors.New("invalid bid request")
+
+// RequestError is a bid request field that breaks OpenRTB's requirements
+type RequestError struct {
+	Field  string `json:"field"` // Path in the request, e.g. "imp[0].bidfloor"
+	Reason string `json:"reason"`
+}
+
+func (e *RequestError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+func (e *RequestError) Unwrap() error {
+	return ErrInvalidBidRequest
+}
+
+// ValidateBidRequest checks req has the fields OpenRTB 2.x requires: an ID,
+// at least one impression with a unique ID and a banner, video, audio or
+// native object, exactly one of site, app or dooh, and no negative floors
+// or sizes. Every problem found is returned, joined, as a *RequestError.
+func ValidateBidRequest(req *openrtb2.BidRequest) error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, &RequestError{Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if req.ID == "" {
+		fail("id", "is required")
+	}
+	if len(req.Imp) == 0 {
+		fail("imp", "at least one impression is required")
+	}
+
+	var distribution int
+	for _, present := range []bool{req.Site != nil, req.App != nil, req.DOOH != nil} {
+		if present {
+			distribution++
+		}
+	}
+	switch {
+	case distribution == 0:
+		fail("site", "one of site, app or dooh is required")
+	case distribution > 1:
+		fail("site", "site, app and dooh are mutually exclusive")
+	}
+	if req.TMax < 0 {
+		fail("tmax", "%d is negative", req.TMax)
+	}
+
+	seen := make(map[string]bool, len(req.Imp))
+	for i := range req.Imp {
+		imp := &req.Imp[i]
+		path := fmt.Sprintf("imp[%d]", i)
+		switch {
+		case imp.ID == "":
+			fail(path+".id", "is required")
+		case seen[imp.ID]:
+			fail(path+".id", "%q is repeated", imp.ID)
+		}
+		seen[imp.ID] = true
+
+		if imp.BidFloor < 0 {
+			fail(path+".bidfloor", "%v is negative", imp.BidFloor)
+		}
+		if imp.PMP != nil {
+			for j, d := range imp.PMP.Deals {
+				if d.BidFloor < 0 {
+					fail(fmt.Sprintf("%s.pmp.deals[%d].bidfloor", path, j), "%v is negative", d.BidFloor)
+				}
+			}
+		}
+		if imp.Banner == nil && imp.Video == nil && imp.Audio == nil && imp.Native == nil {
+			fail(path, "one of banner, video, audio or native is required")
+		}
+		if imp.Banner != nil {
+			validateBanner(imp.Banner, path+".banner", fail)
+		}
+		if imp.Video != nil {
+			validateVideo(imp.Video, path+".video", fail)
+		}
+		if imp.Native != nil && imp.Native.Request == "" {
+			fail(path+".native.request", "is required")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateBanner(b *openrtb2.Banner, path string, fail func(field, format string, args ...any)) {
+	if (b.W != nil && *b.W < 0) || (b.H != nil && *b.H < 0) {
+		fail(path, "negative size")
+	}
+	for i, f := range b.Format {
+		if f.W < 0 || f.H < 0 {
+			fail(fmt.Sprintf("%s.format[%d]", path, i), "negative size")
+		}
+	}
+}
+
+func validateVideo(v *openrtb2.Video, path string, fail func(field, format string, args ...any)) {
+	if len(v.MIMEs) == 0 {
+		fail(path+".mimes", "at least one MIME type is required")
+	}
+	if (v.W != nil && *v.W < 0) || (v.H != nil && *v.H < 0) {
+		fail(path, "negative size")
+	}
+	if v.MinDuration < 0 || v.MaxDuration < 0 {
+		fail(path, "negative duration")
+	}
+	if v.MaxDuration > 0 && v.MinDuration > v.MaxDuration {
+		fail(path+".minduration", "%d exceeds maxduration %d", v.MinDuration, v.MaxDuration)
+	}
+}
+
+// RequestErrors unpacks the RequestErrors in an error from
+// ValidateBidRequest
+func RequestErrors(err error) []*RequestError {
+	var out []*RequestError
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			out = append(out, RequestErrors(e)...)
+		}
+		return out
+	}
+	var re *RequestError
+	if errors.As(err, &re) {
+		out = append(out, re)
+	}
+	return out
+}
+
+// WriteInvalidRequest answers a request that failed ValidateBidRequest with
+// a 400 carrying a no-bid for an invalid request, each problem listed in
+// ext.errors
+func WriteInvalidRequest(w http.ResponseWriter, id string, err error) {
+	ext, _ := json.Marshal(struct {
+		Errors []*RequestError `json:"errors"`
+	}{RequestErrors(err)})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(openrtb2.BidResponse{
+		ID:  id,
+		NBR: openrtb3.NoBidInvalidRequest.Ptr(),
+		Ext: ext,
+	})
+}
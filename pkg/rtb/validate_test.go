@@ -0,0 +1,83 @@
+package rtb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prebid/openrtb/v20/openrtb2"
+	"github.com/prebid/openrtb/v20/openrtb3"
+)
+
+func TestValidateBidRequest(t *testing.T) {
+	valid := func() *openrtb2.BidRequest {
+		return &openrtb2.BidRequest{
+			ID:   "auc-1",
+			Imp:  []openrtb2.Imp{{ID: "1", BidFloor: 1, Video: &openrtb2.Video{MIMEs: []string{"video/mp4"}}}},
+			Site: &openrtb2.Site{ID: "site-1"},
+		}
+	}
+	if err := ValidateBidRequest(valid()); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		mutate func(*openrtb2.BidRequest)
+		field  string
+		reason string
+	}{
+		{"missing imps", func(r *openrtb2.BidRequest) { r.Imp = nil }, "imp", "at least one impression is required"},
+		{"site and app", func(r *openrtb2.BidRequest) { r.App = &openrtb2.App{ID: "app-1"} }, "site", "site, app and dooh are mutually exclusive"},
+		{"negative bidfloor", func(r *openrtb2.BidRequest) { r.Imp[0].BidFloor = -0.5 }, "imp[0].bidfloor", "-0.5 is negative"},
+		{"no id", func(r *openrtb2.BidRequest) { r.ID = "" }, "id", "is required"},
+		{"neither site nor app", func(r *openrtb2.BidRequest) { r.Site = nil }, "site", "one of site, app or dooh is required"},
+		{"no media", func(r *openrtb2.BidRequest) { r.Imp[0].Video = nil }, "imp[0]", "one of banner, video, audio or native is required"},
+		{"video without mimes", func(r *openrtb2.BidRequest) { r.Imp[0].Video.MIMEs = nil }, "imp[0].video.mimes", "at least one MIME type is required"},
+		{"repeated imp", func(r *openrtb2.BidRequest) { r.Imp = append(r.Imp, r.Imp[0]) }, "imp[1].id", `"1" is repeated`},
+	} {
+		req := valid()
+		tt.mutate(req)
+		err := ValidateBidRequest(req)
+		if !errors.Is(err, ErrInvalidBidRequest) {
+			t.Errorf("%s: err = %v, want ErrInvalidBidRequest", tt.name, err)
+			continue
+		}
+		errs := RequestErrors(err)
+		if len(errs) != 1 || errs[0].Field != tt.field || errs[0].Reason != tt.reason {
+			t.Errorf("%s: errors = %v, want %s: %s", tt.name, err, tt.field, tt.reason)
+		}
+	}
+}
+
+func TestWriteInvalidRequest(t *testing.T) {
+	var req openrtb2.BidRequest
+	json.NewDecoder(strings.NewReader(`{"id":"auc-1","imp":[{"id":"1","bidfloor":-1,"banner":{}}],"site":{},"app":{}}`)).Decode(&req)
+	err := ValidateBidRequest(&req)
+	if err == nil {
+		t.Fatal("want the request rejected")
+	}
+
+	w := httptest.NewRecorder()
+	WriteInvalidRequest(w, req.ID, err)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var resp openrtb2.BidResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "auc-1" || resp.NBR == nil || *resp.NBR != openrtb3.NoBidInvalidRequest {
+		t.Errorf("response = %+v, want an invalid-request no-bid", resp)
+	}
+	var ext struct {
+		Errors []RequestError `json:"errors"`
+	}
+	json.Unmarshal(resp.Ext, &ext)
+	if len(ext.Errors) != 2 || ext.Errors[0].Field != "site" || ext.Errors[1].Field != "imp[0].bidfloor" {
+		t.Errorf("errors = %+v, want site and imp[0].bidfloor", ext.Errors)
+	}
+}
@@ -2,14 +2,24 @@ package settlement
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"fmt"
 	"time"
 
 	"github.com/luxfi/adx/pkg/chainvm"
+	"github.com/luxfi/adx/pkg/oracle"
+	"github.com/luxfi/adx/pkg/reqlog"
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/luxfi/adx/pkg/tracing"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrDeliveryProof is returned for delivery proofs that fail validation
+var ErrDeliveryProof = rpcerr.New(rpcerr.Invalid, "invalid_delivery_proof", "invalid delivery proof")
+
 // AUSDSettlement - Automated settlement system eliminating "delivered but not paid" risk
 // Core innovation: Every bid is pre-funded, payment only on cryptographic proof of delivery
 type AUSDSettlement struct {
@@ -17,6 +27,30 @@ type AUSDSettlement struct {
 	slots   *chainvm.AdSlotManager
 	oracle  *DeliveryOracle
 	metrics *SettlementMetrics
+
+	// Reference prices for revenue metrics
+	prices       oracle.PriceOracle
+	revenueUSD   decimal.Decimal // Settled, in USD
+	benchmarkUSD decimal.Decimal // The same impressions at benchmark CPMs
+
+	viewability   ViewabilityCurve
+	sampler       *ProofSampler
+	deepVerifier  DeepVerifier
+	submitterKeys map[string]ed25519.PublicKey
+
+	settled    map[string]settledImpression // By impression ID
+	requests   map[string]pendingRequest    // Auction correlation ID by reservation ID
+	expiries   []pendingRequest             // requests in reservation expiry order
+	disputes   map[string]*Dispute
+	disputeSeq uint64
+}
+
+// pendingRequest links an unsettled reservation to the auction that won
+// it until the reservation expires
+type pendingRequest struct {
+	ReservationID string
+	RequestID     string
+	Expires       time.Time
 }
 
 // SettlementMetrics tracks the key performance indicators
@@ -27,8 +61,9 @@ type SettlementMetrics struct {
 	AvgSettlementTime time.Duration   `json:"avg_settlement_time"` // Time-to-cash per impression
 	DisputeRate       decimal.Decimal `json:"dispute_rate"`        // % disputed settlements (target: <0.1%)
 	FillRate          decimal.Decimal `json:"fill_rate"`           // % of inventory filled
-	NetECPMUplift     decimal.Decimal `json:"net_ecpm_uplift"`     // vs baseline exchanges
+	NetECPMUplift     decimal.Decimal `json:"net_ecpm_uplift"`     // Settled eCPM over benchmark CPMs, less 1
 	TotalVolumeAUSD   decimal.Decimal `json:"total_volume_ausd"`
+	TotalVolumeUSD    decimal.Decimal `json:"total_volume_usd"`
 	ActiveCampaigns   uint64          `json:"active_campaigns"`
 	ActivePublishers  uint64          `json:"active_publishers"`
 	RealTimePayouts   uint64          `json:"realtime_payouts_24h"`
@@ -45,7 +80,16 @@ type DeliveryProof struct {
 	CDNSignature      string    `json:"cdn_signature"`                // CDN edge attestation
 	MeasurementAttest string    `json:"measurement_attest,omitempty"` // 3P measurement
 	Timestamp         time.Time `json:"timestamp"`
-	UserHash          string    `json:"user_hash"` // Privacy-preserving user ID
+	UserHash          string    `json:"user_hash"`           // Privacy-preserving user ID
+	Submitter         string    `json:"submitter,omitempty"` // Publisher or miner posting the proof
+
+	// SubmitterSignature is the Submitter's hex ed25519 signature; see
+	// SignDeliveryProof
+	SubmitterSignature string `json:"submitter_signature,omitempty"`
+
+	// Sample is the sampler's decision, set on proofs selected for deep
+	// verification
+	Sample *SampleDecision `json:"sample,omitempty"`
 }
 
 // DeliveryOracle aggregates delivery proofs and posts Merkle roots on-chain
@@ -71,13 +115,22 @@ func NewAUSDSettlement(escrow *chainvm.EscrowManager, slots *chainvm.AdSlotManag
 			FillRate:          decimal.Zero,
 			NetECPMUplift:     decimal.Zero,
 			TotalVolumeAUSD:   decimal.Zero,
+			TotalVolumeUSD:    decimal.Zero,
 			AvgSettlementTime: 0,
 		},
+		prices:        oracle.NewStatic(),
+		viewability:   DefaultViewabilityCurve,
+		settled:       make(map[string]settledImpression),
+		requests:      make(map[string]pendingRequest),
+		disputes:      make(map[string]*Dispute),
+		submitterKeys: make(map[string]ed25519.PublicKey),
 	}
 }
 
 // ProcessImpressionWin - Handle auction win and create atomic reservation
 func (s *AUSDSettlement) ProcessImpressionWin(ctx context.Context, req *ImpressionWinRequest) (*ImpressionWinResponse, error) {
+	ctx = reqlog.With(ctx, reqlog.KeyPublisher, req.Publisher)
+
 	// 1. Create atomic reservation with TTL (1-2 seconds)
 	reserveReq := &chainvm.ReserveBudgetRequest{
 		ReservationID: req.ReservationID,
@@ -97,7 +150,19 @@ func (s *AUSDSettlement) ProcessImpressionWin(ctx context.Context, req *Impressi
 
 	reserveResp, err := s.escrow.ReserveBudget(ctx, reserveReq)
 	if err != nil {
-		return nil, fmt.Errorf("reservation failed: %v", err)
+		reqlog.Logger(ctx).Warn("reservation failed", "campaign_id", req.CampaignID, "error", err)
+		return nil, fmt.Errorf("reservation failed: %w", err)
+	}
+	reqlog.Logger(ctx).Debug("reservation created", "campaign_id", req.CampaignID, "reservation_id", req.ReservationID)
+
+	s.expireRequests(time.Now())
+	if requestID := req.RequestID; requestID != "" || reqlog.ID(ctx) != "" {
+		if requestID == "" {
+			requestID = reqlog.ID(ctx)
+		}
+		pending := pendingRequest{ReservationID: req.ReservationID, RequestID: requestID, Expires: reserveResp.Expires}
+		s.requests[req.ReservationID] = pending
+		s.expiries = append(s.expiries, pending)
 	}
 
 	// 2. Generate impression tracking ID for delivery proof
@@ -117,7 +182,12 @@ func (s *AUSDSettlement) ProcessImpressionWin(ctx context.Context, req *Impressi
 func (s *AUSDSettlement) SubmitDeliveryProof(ctx context.Context, proof *DeliveryProof) (*DeliveryProofResponse, error) {
 	// Validate proof integrity
 	if err := s.validateDeliveryProof(proof); err != nil {
-		return nil, fmt.Errorf("invalid proof: %v", err)
+		reqlog.Logger(ctx).Info("delivery proof rejected", "impression_id", proof.ImpressionID, "error", err)
+		return nil, err
+	}
+	if err := s.sampleProof(proof); err != nil {
+		reqlog.Logger(ctx).Warn("sampled delivery proof failed", "impression_id", proof.ImpressionID, "submitter", proof.Submitter, "error", err)
+		return nil, fmt.Errorf("invalid proof: %w", err)
 	}
 
 	// Store proof for aggregation
@@ -127,7 +197,7 @@ func (s *AUSDSettlement) SubmitDeliveryProof(ctx context.Context, proof *Deliver
 	// Try immediate settlement if enough confirmations
 	if len(s.oracle.witnesses[bucket]) >= s.getRequiredConfirmations() {
 		if err := s.settleImpression(ctx, proof); err != nil {
-			return nil, fmt.Errorf("settlement failed: %v", err)
+			return nil, fmt.Errorf("settlement failed: %w", err)
 		}
 		return &DeliveryProofResponse{
 			Success:   true,
@@ -154,20 +224,16 @@ func (s *AUSDSettlement) BatchSettlement(ctx context.Context) error {
 		merkleRoot := s.calculateMerkleRoot(proofs)
 		s.oracle.roots[bucket] = merkleRoot
 
-		// Settle all proofs in batch
+		// Settle all proofs in batch; each settlement records its revenue
 		var settled uint64
-		var totalRevenue decimal.Decimal
-
 		for _, proof := range proofs {
 			if err := s.settleImpression(ctx, &proof); err == nil {
 				settled++
-				// Add to revenue tracking (simplified)
-				totalRevenue = totalRevenue.Add(decimal.NewFromFloat(5.0)) // avg CPM
 			}
 		}
 
 		// Update metrics
-		s.updateSettlementMetrics(settled, totalRevenue, len(proofs))
+		s.updateSettlementMetrics(settled, len(proofs))
 
 		// Clear processed proofs
 		delete(s.oracle.witnesses, bucket)
@@ -176,12 +242,30 @@ func (s *AUSDSettlement) BatchSettlement(ctx context.Context) error {
 	return nil
 }
 
-// settleImpression - Execute T+0 settlement on verified delivery
+// settleImpression - Execute T+0 settlement on verified delivery, paying
+// the share of the reservation earned under the viewability curve. Views
+// below the curve's floor pay nothing and release the reservation.
 func (s *AUSDSettlement) settleImpression(ctx context.Context, proof *DeliveryProof) error {
-	// Validate viewability meets minimum standards
-	if proof.ViewabilityScore < 70.0 { // IAB standard
-		return fmt.Errorf("viewability below threshold: %.1f%%", proof.ViewabilityScore)
+	opts := []trace.SpanStartOption{trace.WithAttributes(tracing.AttrImpression.String(proof.ImpressionID))}
+	if pending, ok := s.requests[proof.ReservationID]; ok {
+		requestID := pending.RequestID
+		if reqlog.ID(ctx) == "" {
+			ctx = reqlog.WithID(ctx, requestID)
+		}
+		opts = append(opts, trace.WithAttributes(tracing.AttrRequestID.String(requestID)))
+		if link, ok := tracing.AuctionLink(requestID); ok {
+			opts = append(opts, trace.WithLinks(link))
+		}
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "settlement.settleImpression", opts...)
+	defer span.End()
+
+	reservation, ok := s.escrow.Reservation(proof.ReservationID)
+	if !ok {
+		span.SetStatus(codes.Error, "reservation not found")
+		return fmt.Errorf("%w: %s", chainvm.ErrReservationNotFound, proof.ReservationID)
 	}
+	amount := reservation.Amount.Mul(s.viewability.Fraction(proof)).Round(chainvm.MaxDecimalPlaces)
 
 	// Create verification proof hash
 	verificationHash := s.createVerificationHash(proof)
@@ -190,20 +274,54 @@ func (s *AUSDSettlement) settleImpression(ctx context.Context, proof *DeliveryPr
 	settleReq := &chainvm.SettleReceiptRequest{
 		ReservationID:     proof.ReservationID,
 		VerificationProof: verificationHash,
+		Amount:            decimal.NewNullDecimal(amount),
 	}
 
 	settleResp, err := s.escrow.SettleReceipt(ctx, settleReq)
 	if err != nil {
-		return fmt.Errorf("escrow settlement failed: %v", err)
+		reqlog.Logger(ctx).Error("escrow settlement failed", "reservation_id", proof.ReservationID, "error", err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("escrow settlement failed: %w", err)
+	}
+	reqlog.Logger(ctx).Debug("impression settled",
+		reqlog.KeyPublisher, reservation.Publisher,
+		"impression_id", proof.ImpressionID,
+		"paid", settleResp.PaidAmount.String(),
+	)
+
+	span.SetAttributes(tracing.AttrPrice.String(settleResp.PaidAmount.String()))
+	delete(s.requests, proof.ReservationID)
+
+	// Keep the link to the reservation for disputes
+	s.settled[proof.ImpressionID] = settledImpression{
+		ReservationID: proof.ReservationID,
+		SettledAt:     time.Now(),
 	}
 
 	// Update metrics
 	s.metrics.RealTimePayouts++
 	s.metrics.TotalVolumeAUSD = s.metrics.TotalVolumeAUSD.Add(settleResp.PaidAmount)
+	s.recordRevenue(ctx, amount, reservation.Metadata.Placement)
+	s.updateDisputeRate()
 
 	return nil
 }
 
+// expireRequests forgets the auction links of reservations that expired
+// unsettled; the escrow refuses to settle them past their expiry. Every
+// reservation has the same TTL, so expiries is in expiry order.
+func (s *AUSDSettlement) expireRequests(now time.Time) {
+	for len(s.expiries) > 0 && now.After(s.expiries[0].Expires) {
+		expired := s.expiries[0]
+		s.expiries[0] = pendingRequest{}
+		s.expiries = s.expiries[1:]
+		// A reused reservation ID may have been linked again since
+		if pending, ok := s.requests[expired.ReservationID]; ok && pending == expired {
+			delete(s.requests, expired.ReservationID)
+		}
+	}
+}
+
 // GetSettlementMetrics - Return current performance metrics
 func (s *AUSDSettlement) GetSettlementMetrics() *SettlementMetrics {
 	// Calculate DSO (Days Sales Outstanding)
@@ -216,9 +334,6 @@ func (s *AUSDSettlement) GetSettlementMetrics() *SettlementMetrics {
 	// Average settlement time: <1 second
 	s.metrics.AvgSettlementTime = 500 * time.Millisecond
 
-	// Dispute rate: minimal due to cryptographic proofs
-	s.metrics.DisputeRate = decimal.NewFromFloat(0.1)
-
 	return s.metrics
 }
 
@@ -244,7 +359,7 @@ func (s *AUSDSettlement) CreateProgrammaticGuaranteed(ctx context.Context, req *
 
 	pgResp, err := s.escrow.CreatePGDeal(ctx, pgReq)
 	if err != nil {
-		return nil, fmt.Errorf("PG deal creation failed: %v", err)
+		return nil, fmt.Errorf("PG deal creation failed: %w", err)
 	}
 
 	return &PGDealResponse{
@@ -256,7 +371,7 @@ func (s *AUSDSettlement) CreateProgrammaticGuaranteed(ctx context.Context, req *
 			PenaltyRate:    req.PenaltyRate,
 			DeliveryWindow: req.EndTime.Sub(req.StartTime),
 			PaymentTerms:   "T+0 on verified delivery",
-			DisputeWindow:  48 * time.Hour,
+			DisputeWindow:  chainvm.HoldbackWindow,
 		},
 	}, nil
 }
@@ -276,22 +391,22 @@ func (s *AUSDSettlement) generateTrackingPixel(impressionID string) string {
 func (s *AUSDSettlement) validateDeliveryProof(proof *DeliveryProof) error {
 	// Validate VRF nonce format
 	if len(proof.VRFNonce) < 32 {
-		return fmt.Errorf("invalid VRF nonce")
+		return fmt.Errorf("%w: invalid VRF nonce", ErrDeliveryProof)
 	}
 
 	// Validate signatures from player and CDN
 	if proof.PlayerSignature == "" || proof.CDNSignature == "" {
-		return fmt.Errorf("missing required signatures")
+		return fmt.Errorf("%w: missing required signatures", ErrDeliveryProof)
 	}
 
 	// Validate viewability score
 	if proof.ViewabilityScore < 0 || proof.ViewabilityScore > 100 {
-		return fmt.Errorf("invalid viewability score: %.1f", proof.ViewabilityScore)
+		return fmt.Errorf("%w: viewability score %.1f", ErrDeliveryProof, proof.ViewabilityScore)
 	}
 
 	// Validate timestamp is recent
 	if time.Since(proof.Timestamp) > 5*time.Minute {
-		return fmt.Errorf("proof too old")
+		return fmt.Errorf("%w: proof too old", ErrDeliveryProof)
 	}
 
 	return nil
@@ -321,24 +436,55 @@ func (s *AUSDSettlement) calculateMerkleRoot(proofs []DeliveryProof) string {
 	h := sha256.New()
 	for _, proof := range proofs {
 		h.Write([]byte(proof.ImpressionID))
+		if proof.Sample != nil {
+			h.Write(proof.Sample.Output[:])
+		}
 	}
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-func (s *AUSDSettlement) updateSettlementMetrics(settled uint64, revenue decimal.Decimal, total int) {
+func (s *AUSDSettlement) updateSettlementMetrics(settled uint64, total int) {
 	// Update fill rate
 	if total > 0 {
 		fillRate := decimal.NewFromInt(int64(settled)).Div(decimal.NewFromInt(int64(total)))
 		s.metrics.FillRate = s.metrics.FillRate.Add(fillRate).Div(decimal.NewFromInt(2)) // Moving average
 	}
+}
+
+// SetPriceOracle sets where revenue metrics get AUSD/USD and benchmark
+// CPMs; a static oracle at par is used until then
+func (s *AUSDSettlement) SetPriceOracle(o oracle.PriceOracle) {
+	s.prices = o
+}
+
+// recordRevenue adds one settled impression to the USD volume and to the
+// eCPM uplift over the placement format's benchmark
+func (s *AUSDSettlement) recordRevenue(ctx context.Context, amount decimal.Decimal, placement string) {
+	rate, err := s.prices.AUSDUSD(ctx)
+	if err != nil {
+		reqlog.Logger(ctx).Warn("no AUSD/USD price, revenue not recorded", "error", err)
+		return
+	}
+	usd := amount.Mul(rate.Price)
+	s.metrics.TotalVolumeUSD = s.metrics.TotalVolumeUSD.Add(usd)
 
-	// Update volume
-	s.metrics.TotalVolumeAUSD = s.metrics.TotalVolumeAUSD.Add(revenue)
+	format := oracle.FormatOf(placement)
+	benchmark, err := s.prices.BenchmarkCPM(ctx, format)
+	if err != nil {
+		reqlog.Logger(ctx).Warn("no benchmark CPM, uplift not updated", "format", format, "error", err)
+		return
+	}
+	s.revenueUSD = s.revenueUSD.Add(usd)
+	s.benchmarkUSD = s.benchmarkUSD.Add(benchmark.Price.Div(decimal.NewFromInt(1000)))
+	if s.benchmarkUSD.IsPositive() {
+		s.metrics.NetECPMUplift = s.revenueUSD.Div(s.benchmarkUSD).Sub(decimal.NewFromInt(1))
+	}
 }
 
 // Request/Response types
 
 type ImpressionWinRequest struct {
+	RequestID      string          `json:"request_id,omitempty"` // Auction correlation ID; defaults to ctx's
 	ReservationID  string          `json:"reservation_id"`
 	CampaignID     string          `json:"campaign_id"`
 	Publisher      string          `json:"publisher"`
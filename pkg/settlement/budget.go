@@ -4,7 +4,6 @@
 package settlement
 
 import (
-	"errors"
 	"sync"
 	"time"
 
@@ -12,12 +11,15 @@ import (
 	"github.com/luxfi/adx/pkg/crypto"
 	"github.com/luxfi/adx/pkg/ids"
 	"github.com/luxfi/adx/pkg/log"
+	"github.com/luxfi/adx/pkg/rpcerr"
 )
 
 var (
-	ErrInsufficientBudget = errors.New("insufficient budget")
-	ErrInvalidProof       = errors.New("invalid settlement proof")
-	ErrNegativeDelta      = errors.New("negative budget delta")
+	ErrInsufficientBudget  = rpcerr.New(rpcerr.Exhausted, "insufficient_budget", "insufficient budget")
+	ErrInvalidProof        = rpcerr.New(rpcerr.Invalid, "invalid_settlement_proof", "invalid settlement proof")
+	ErrNegativeDelta       = rpcerr.New(rpcerr.Invalid, "negative_delta", "negative budget delta")
+	ErrBudgetNotFound      = rpcerr.New(rpcerr.NotFound, "budget_not_found", "budget not found")
+	ErrNoPendingSettlement = rpcerr.New(rpcerr.Precondition, "no_pending_settlement", "no pending settlement")
 )
 
 // BudgetManager manages advertiser budgets with privacy
@@ -92,7 +94,7 @@ func (bm *BudgetManager) DeductBudget(
 
 	budget, exists := bm.budgets[advertiserID]
 	if !exists {
-		return 0, errors.New("budget not found")
+		return 0, ErrBudgetNotFound
 	}
 
 	if budget.Remaining < amount {
@@ -217,7 +219,7 @@ func (bm *BudgetManager) CreateSettlement(
 	// Get pending amount for advertiser
 	amount, exists := bm.pending[advertiserID]
 	if !exists || amount == 0 {
-		return nil, errors.New("no pending settlement")
+		return nil, ErrNoPendingSettlement
 	}
 
 	// Generate settlement proof
@@ -0,0 +1,128 @@
+package settlement
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/luxfi/adx/pkg/chainvm"
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrImpressionNotSettled = rpcerr.New(rpcerr.Precondition, "impression_not_settled", "impression not settled")
+	ErrDisputeWindowClosed  = rpcerr.New(rpcerr.Precondition, "dispute_window_closed", "dispute window closed")
+	ErrDisputeExists        = rpcerr.New(rpcerr.Conflict, "dispute_exists", "impression already disputed")
+	ErrDisputeNotFound      = rpcerr.New(rpcerr.NotFound, "dispute_not_found", "dispute not found")
+	ErrDisputeResolved      = rpcerr.New(rpcerr.Precondition, "dispute_resolved", "dispute already resolved")
+)
+
+// Dispute statuses
+const (
+	DisputeOpen     = "open"
+	DisputeUpheld   = "upheld"
+	DisputeRejected = "rejected"
+)
+
+// settledImpression links a settled impression to its reservation
+type settledImpression struct {
+	ReservationID string
+	SettledAt     time.Time
+}
+
+// Dispute is an advertiser challenge against a settled impression
+type Dispute struct {
+	ID            string          `json:"id"`
+	ImpressionID  string          `json:"impression_id"`
+	ReservationID string          `json:"reservation_id"`
+	Reason        string          `json:"reason"`
+	Status        string          `json:"status"`
+	RaisedAt      time.Time       `json:"raised_at"`
+	ResolvedAt    time.Time       `json:"resolved_at,omitempty"`
+	Refunded      decimal.Decimal `json:"refunded"` // Clawed back to the campaign
+	Released      decimal.Decimal `json:"released"` // Holdback paid to the publisher
+}
+
+// RaiseDispute opens a dispute against a settled impression. Disputes
+// must be raised within the holdback window so the holdback is still
+// available to claw back.
+func (s *AUSDSettlement) RaiseDispute(impressionID, reason string) (*Dispute, error) {
+	imp, ok := s.settled[impressionID]
+	if !ok {
+		return nil, ErrImpressionNotSettled
+	}
+	if time.Since(imp.SettledAt) > chainvm.HoldbackWindow {
+		return nil, ErrDisputeWindowClosed
+	}
+	for _, d := range s.disputes {
+		if d.ImpressionID == impressionID {
+			return nil, ErrDisputeExists
+		}
+	}
+
+	s.disputeSeq++
+	d := &Dispute{
+		ID:            fmt.Sprintf("dsp-%d", s.disputeSeq),
+		ImpressionID:  impressionID,
+		ReservationID: imp.ReservationID,
+		Reason:        reason,
+		Status:        DisputeOpen,
+		RaisedAt:      time.Now(),
+		Refunded:      decimal.Zero,
+		Released:      decimal.Zero,
+	}
+	s.disputes[d.ID] = d
+	s.updateDisputeRate()
+
+	return d, nil
+}
+
+// ResolveDispute closes a dispute. An upheld dispute claws back the
+// holdback and reverses the streamed payment to the campaign; a rejected
+// one releases the holdback to the publisher.
+func (s *AUSDSettlement) ResolveDispute(disputeID string, upheld bool) (*Dispute, error) {
+	d, ok := s.disputes[disputeID]
+	if !ok {
+		return nil, ErrDisputeNotFound
+	}
+	if d.Status != DisputeOpen {
+		return nil, ErrDisputeResolved
+	}
+
+	if upheld {
+		clawback, err := s.escrow.ClawbackSettlement(d.ReservationID, true)
+		if err != nil {
+			return nil, fmt.Errorf("clawback failed: %w", err)
+		}
+		d.Refunded = clawback.Total()
+		d.Status = DisputeUpheld
+	} else {
+		released, err := s.escrow.ReleaseHoldback(d.ReservationID)
+		if err != nil {
+			return nil, fmt.Errorf("holdback release failed: %w", err)
+		}
+		d.Released = released
+		d.Status = DisputeRejected
+	}
+	d.ResolvedAt = time.Now()
+
+	return d, nil
+}
+
+// GetDispute returns a dispute by ID
+func (s *AUSDSettlement) GetDispute(disputeID string) (*Dispute, bool) {
+	d, ok := s.disputes[disputeID]
+	return d, ok
+}
+
+// updateDisputeRate sets DisputeRate to the percentage of settled
+// impressions that have been disputed
+func (s *AUSDSettlement) updateDisputeRate() {
+	if len(s.settled) == 0 {
+		s.metrics.DisputeRate = decimal.Zero
+		return
+	}
+	s.metrics.DisputeRate = decimal.NewFromInt(int64(len(s.disputes))).
+		Mul(decimal.NewFromInt(100)).
+		Div(decimal.NewFromInt(int64(len(s.settled))))
+}
@@ -0,0 +1,116 @@
+package settlement
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/chainvm"
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// newDisputeFixture funds a campaign with a 10% holdback and settles one
+// 10 AUSD impression per ID
+func newDisputeFixture(t *testing.T, impressionIDs ...string) (*AUSDSettlement, *chainvm.EscrowManager) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	escrow := chainvm.NewEscrowManager(&chainvm.VMState{}, engine, "AUSD")
+	_, err := escrow.FundCampaign(ctx, &chainvm.FundCampaignRequest{
+		CampaignID:  "c1",
+		Advertiser:  "adv-1",
+		Amount:      decimal.NewFromInt(100),
+		HoldbackBps: 1000,
+	})
+	require.NoError(err)
+
+	s := NewAUSDSettlement(escrow, nil)
+	for _, id := range impressionIDs {
+		_, err := s.ProcessImpressionWin(ctx, &ImpressionWinRequest{
+			ReservationID: "res-" + id,
+			CampaignID:    "c1",
+			Publisher:     "pub-1",
+			WinPrice:      decimal.NewFromInt(10),
+		})
+		require.NoError(err)
+		require.NoError(s.settleImpression(ctx, &DeliveryProof{
+			ImpressionID:     id,
+			ReservationID:    "res-" + id,
+			VRFNonce:         strings.Repeat("n", 32),
+			ViewabilityScore: 100,
+			TimeInView:       2000,
+			PlayerSignature:  "player",
+			CDNSignature:     "cdn",
+			Timestamp:        time.Now(),
+		}))
+	}
+	return s, escrow
+}
+
+func TestDispute_UpheldClawsBack(t *testing.T) {
+	require := require.New(t)
+	s, escrow := newDisputeFixture(t, "imp-1", "imp-2")
+
+	d, err := s.RaiseDispute("imp-1", "invalid traffic")
+	require.NoError(err)
+	require.Equal(DisputeOpen, d.Status)
+	require.True(s.GetSettlementMetrics().DisputeRate.Equal(decimal.NewFromInt(50)))
+
+	_, err = s.RaiseDispute("imp-1", "again")
+	require.ErrorIs(err, ErrDisputeExists)
+
+	d, err = s.ResolveDispute(d.ID, true)
+	require.NoError(err)
+	require.Equal(DisputeUpheld, d.Status)
+	require.True(d.Refunded.Equal(decimal.NewFromInt(10)), "refunded %s", d.Refunded)
+
+	// Only imp-2's streamed 9 AUSD stays with the publisher
+	rec, err := escrow.ReconcileCampaign("c1")
+	require.NoError(err)
+	require.True(rec.Balanced(), "delta %+v", rec.Delta)
+	require.True(rec.Recorded.Spent.Equal(decimal.NewFromInt(10)), "spent %s", rec.Recorded.Spent)
+	require.True(rec.Recorded.Available.Equal(decimal.NewFromInt(90)), "available %s", rec.Recorded.Available)
+
+	_, err = s.ResolveDispute(d.ID, false)
+	require.ErrorIs(err, ErrDisputeResolved)
+}
+
+func TestDispute_RejectedReleasesHoldback(t *testing.T) {
+	require := require.New(t)
+	s, escrow := newDisputeFixture(t, "imp-1")
+
+	d, err := s.RaiseDispute("imp-1", "low viewability")
+	require.NoError(err)
+	d, err = s.ResolveDispute(d.ID, false)
+	require.NoError(err)
+	require.Equal(DisputeRejected, d.Status)
+	require.True(d.Released.Equal(decimal.NewFromInt(1)), "released %s", d.Released)
+
+	// Holdback is gone, so a clawback now finds nothing pending
+	clawback, err := escrow.ClawbackSettlement("res-imp-1", false)
+	require.NoError(err)
+	require.True(clawback.Total().IsZero())
+}
+
+func TestDispute_Rejected(t *testing.T) {
+	require := require.New(t)
+	s, _ := newDisputeFixture(t, "imp-1")
+
+	_, err := s.RaiseDispute("imp-unknown", "never served")
+	require.ErrorIs(err, ErrImpressionNotSettled)
+
+	imp := s.settled["imp-1"]
+	imp.SettledAt = time.Now().Add(-chainvm.HoldbackWindow - time.Minute)
+	s.settled["imp-1"] = imp
+
+	_, err = s.RaiseDispute("imp-1", "too late")
+	require.ErrorIs(err, ErrDisputeWindowClosed)
+
+	_, err = s.ResolveDispute("dsp-404", true)
+	require.ErrorIs(err, ErrDisputeNotFound)
+}
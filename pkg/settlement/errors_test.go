@@ -0,0 +1,35 @@
+package settlement
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/chainvm"
+	"github.com/luxfi/adx/pkg/rpcerr"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettlementErrors_Typed(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	s, _ := newDisputeFixture(t, "imp-1")
+
+	// Escrow errors pass through with their codes
+	_, err := s.ProcessImpressionWin(ctx, &ImpressionWinRequest{ReservationID: "r1", CampaignID: "c9", WinPrice: decimal.NewFromInt(1)})
+	require.ErrorIs(err, chainvm.ErrCampaignInactive)
+	require.Equal("campaign_inactive", rpcerr.Code(err))
+
+	_, err = s.ProcessImpressionWin(ctx, &ImpressionWinRequest{ReservationID: "r2", CampaignID: "c1", WinPrice: decimal.NewFromInt(1000)})
+	require.ErrorIs(err, chainvm.ErrBudgetExhausted)
+	require.False(rpcerr.IsRetryable(err))
+
+	_, err = s.SubmitDeliveryProof(ctx, &DeliveryProof{ImpressionID: "imp-2", VRFNonce: "short", Timestamp: time.Now()})
+	require.ErrorIs(err, ErrDeliveryProof)
+	require.Equal(400, rpcerr.HTTPStatus(err))
+
+	err = s.settleImpression(ctx, &DeliveryProof{ReservationID: "missing"})
+	require.ErrorIs(err, chainvm.ErrReservationNotFound)
+	require.Equal(404, rpcerr.HTTPStatus(err))
+}
@@ -0,0 +1,66 @@
+package settlement
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/chainvm"
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/oracle"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettlementMetrics_OraclePrices(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	state := &chainvm.VMState{}
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	escrow := chainvm.NewEscrowManager(state, engine, "AUSD")
+	_, err := escrow.FundCampaign(ctx, &chainvm.FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100)})
+	require.NoError(err)
+
+	feed := oracle.NewFeed()
+	feed.Set("AUSD/USD", oracle.Quote{Price: decimal.RequireFromString("0.98"), UpdatedAt: time.Now()})
+	feed.Set(string(oracle.FormatCTV), oracle.Quote{Price: decimal.NewFromInt(20), UpdatedAt: time.Now()})
+
+	s := NewAUSDSettlement(escrow, nil)
+	s.SetPriceOracle(oracle.NewGuarded(feed, nil, time.Minute))
+	settle := func(id string) {
+		_, err := s.ProcessImpressionWin(ctx, &ImpressionWinRequest{
+			ReservationID: id,
+			CampaignID:    "c1",
+			Publisher:     "pub-1",
+			WinPrice:      decimal.RequireFromString("0.03"),
+			Placement:     "ctv-preroll",
+		})
+		require.NoError(err)
+		require.NoError(s.settleImpression(ctx, &DeliveryProof{
+			ImpressionID:     "imp-" + id,
+			ReservationID:    id,
+			VRFNonce:         strings.Repeat("n", 32),
+			ViewabilityScore: 100,
+			TimeInView:       5000,
+			Timestamp:        time.Now(),
+		}))
+	}
+
+	// 0.03 AUSD at 0.98 is 0.0294 USD against a 20 CPM benchmark of 0.02
+	settle("r1")
+	m := s.GetSettlementMetrics()
+	require.True(m.TotalVolumeUSD.Equal(decimal.RequireFromString("0.0294")), "volume %s", m.TotalVolumeUSD)
+	require.True(m.NetECPMUplift.Equal(decimal.RequireFromString("0.47")), "uplift %s", m.NetECPMUplift)
+
+	// A stale feed falls back to AUSD at par and the default CTV CPM of 25
+	feed.Set("AUSD/USD", oracle.Quote{Price: decimal.NewFromInt(2), UpdatedAt: time.Now().Add(-time.Hour)})
+	feed.Set(string(oracle.FormatCTV), oracle.Quote{Price: decimal.NewFromInt(1), UpdatedAt: time.Now().Add(-time.Hour)})
+	settle("r2")
+	m = s.GetSettlementMetrics()
+	require.True(m.TotalVolumeUSD.Equal(decimal.RequireFromString("0.0594")), "volume %s", m.TotalVolumeUSD)
+	// (0.0294 + 0.03) / (0.02 + 0.025) - 1
+	require.True(m.NetECPMUplift.Sub(decimal.RequireFromString("0.32")).Abs().LessThan(decimal.RequireFromString("0.0001")), "uplift %s", m.NetECPMUplift)
+}
@@ -0,0 +1,233 @@
+package settlement
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/luxfi/adx/pkg/rpcerr"
+)
+
+var (
+	ErrSampleProof    = rpcerr.New(rpcerr.Invalid, "invalid_sampling_proof", "invalid sampling proof")
+	ErrDeepVerifyFail = rpcerr.New(rpcerr.Invalid, "deep_verify_failed", "sampled proof failed deep verification")
+)
+
+// Quality score bounds and adjustments for proof submitters
+const (
+	InitialQualityScore = 1.0
+	qualityPenalty      = 0.5  // Score multiplier on a failed sample
+	qualityRecovery     = 0.01 // Added on a passed sample
+)
+
+// DeepVerifier performs the expensive checks (attestation signatures,
+// 3P measurement) on sampled delivery proofs
+type DeepVerifier interface {
+	VerifyDelivery(proof *DeliveryProof) error
+}
+
+// SampleDecision is the sampler's verdict for one impression. Proof is an
+// ed25519 signature over the epoch seed and impression ID; its hash is the
+// VRF output, so anyone with the public key can check the selection but
+// only the key holder can predict it. Rate is the rate it was drawn at,
+// raised above the sampler's for submitters with a low quality score.
+type SampleDecision struct {
+	Selected bool     `json:"selected"`
+	Epoch    uint64   `json:"epoch"`
+	Rate     float64  `json:"rate"`
+	Output   [32]byte `json:"output"`
+	Proof    []byte   `json:"proof"`
+}
+
+// ProofSampler selects delivery proofs for deep verification and tracks
+// each submitter's quality score
+type ProofSampler struct {
+	mu      sync.Mutex
+	key     ed25519.PrivateKey
+	rate    float64
+	epoch   uint64
+	seed    []byte
+	quality map[string]float64
+}
+
+// NewProofSampler creates a sampler that deep-verifies roughly rate of all
+// proofs
+func NewProofSampler(key ed25519.PrivateKey, rate float64) *ProofSampler {
+	return &ProofSampler{
+		key:     key,
+		rate:    math.Min(1, math.Max(0, rate)),
+		quality: make(map[string]float64),
+	}
+}
+
+// SetEpoch rotates the sampling seed. Seeds should be fixed per epoch and
+// published only after it ends.
+func (s *ProofSampler) SetEpoch(epoch uint64, seed []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.epoch = epoch
+	s.seed = append([]byte(nil), seed...)
+}
+
+// Select decides whether an impression's proof is deep-verified
+func (s *ProofSampler) Select(impressionID string) SampleDecision {
+	return s.SelectFor(impressionID, InitialQualityScore)
+}
+
+// SelectFor decides whether an impression's proof is deep-verified,
+// dividing the rate by the submitter's quality score so that submitters
+// who have failed samples are checked more often
+func (s *ProofSampler) SelectFor(impressionID string, quality float64) SampleDecision {
+	s.mu.Lock()
+	epoch, seed := s.epoch, s.seed
+	s.mu.Unlock()
+
+	rate := 1.0
+	if quality > 0 {
+		rate = math.Min(1, s.rate/quality)
+	}
+	proof := ed25519.Sign(s.key, sampleInput(seed, impressionID))
+	output := sha256.Sum256(proof)
+	return SampleDecision{
+		Selected: selected(output, rate),
+		Epoch:    epoch,
+		Rate:     rate,
+		Output:   output,
+		Proof:    proof,
+	}
+}
+
+// VerifySelection checks a sampling decision against the sampler's public
+// key, seed and rate. A decision may be drawn at a higher rate than the
+// sampler's, never a lower one.
+func VerifySelection(pub ed25519.PublicKey, seed []byte, rate float64, impressionID string, d SampleDecision) error {
+	if !ed25519.Verify(pub, sampleInput(seed, impressionID), d.Proof) {
+		return ErrSampleProof
+	}
+	if sha256.Sum256(d.Proof) != d.Output || d.Rate < math.Min(1, rate) || selected(d.Output, d.Rate) != d.Selected {
+		return ErrSampleProof
+	}
+	return nil
+}
+
+func sampleInput(seed []byte, impressionID string) []byte {
+	msg := make([]byte, 0, 8+len(seed)+len(impressionID))
+	msg = binary.BigEndian.AppendUint64(msg, uint64(len(seed)))
+	msg = append(msg, seed...)
+	return append(msg, impressionID...)
+}
+
+// selected maps the first 8 output bytes to [0, 1) and compares with rate
+func selected(output [32]byte, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	x := binary.BigEndian.Uint64(output[:8])
+	return float64(x) < rate*math.Exp2(64)
+}
+
+// Record updates a submitter's quality score after a deep verification
+func (s *ProofSampler) Record(submitter string, passed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	score, ok := s.quality[submitter]
+	if !ok {
+		score = InitialQualityScore
+	}
+	if passed {
+		score = math.Min(InitialQualityScore, score+qualityRecovery)
+	} else {
+		score *= qualityPenalty
+	}
+	s.quality[submitter] = score
+}
+
+// QualityScore returns a submitter's quality score in [0, 1]
+func (s *ProofSampler) QualityScore(submitter string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if score, ok := s.quality[submitter]; ok {
+		return score
+	}
+	return InitialQualityScore
+}
+
+// SetSampler enables sampled deep verification of delivery proofs
+func (s *AUSDSettlement) SetSampler(sampler *ProofSampler, verifier DeepVerifier) {
+	s.sampler = sampler
+	s.deepVerifier = verifier
+}
+
+// SetSubmitterKey registers the key a submitter signs its delivery proofs
+// with. Only a proof whose signature checks out is held against its
+// Submitter.
+func (s *AUSDSettlement) SetSubmitterKey(submitter string, pub ed25519.PublicKey) {
+	s.submitterKeys[submitter] = pub
+}
+
+// SignDeliveryProof signs proof as submitter
+func SignDeliveryProof(proof *DeliveryProof, submitter string, key ed25519.PrivateKey) {
+	proof.Submitter = submitter
+	proof.SubmitterSignature = hex.EncodeToString(ed25519.Sign(key, submitterMessage(proof)))
+}
+
+// submitterMessage is what a submitter signs: the proof's evidence and
+// the submitter's own name
+func submitterMessage(proof *DeliveryProof) []byte {
+	h := sha256.New()
+	for _, field := range []string{proof.ImpressionID, proof.ReservationID, proof.VRFNonce, proof.PlayerSignature, proof.CDNSignature, proof.Submitter} {
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(field))))
+		h.Write([]byte(field))
+	}
+	return h.Sum(nil)
+}
+
+// accountable is who a proof's sampling result is charged to: its
+// Submitter if the proof carries their valid signature, and otherwise the
+// publisher the reservation was made for. A self-declared Submitter is
+// never charged.
+func (s *AUSDSettlement) accountable(proof *DeliveryProof) string {
+	if pub, ok := s.submitterKeys[proof.Submitter]; ok {
+		sig, err := hex.DecodeString(proof.SubmitterSignature)
+		if err == nil && ed25519.Verify(pub, submitterMessage(proof), sig) {
+			return proof.Submitter
+		}
+	}
+	if s.escrow != nil {
+		if r, ok := s.escrow.Reservation(proof.ReservationID); ok {
+			return r.Publisher
+		}
+	}
+	return ""
+}
+
+// sampleProof deep-verifies proof if the sampler selects it, at a rate
+// raised for accountable parties with a low quality score, and updates
+// their score. A sampled proof keeps its decision, so the selection can be
+// checked later.
+func (s *AUSDSettlement) sampleProof(proof *DeliveryProof) error {
+	if s.sampler == nil || s.deepVerifier == nil {
+		return nil
+	}
+	party := s.accountable(proof)
+	decision := s.sampler.SelectFor(proof.ImpressionID, s.sampler.QualityScore(party))
+	if !decision.Selected {
+		return nil
+	}
+	proof.Sample = &decision
+
+	err := s.deepVerifier.VerifyDelivery(proof)
+	if party != "" {
+		s.sampler.Record(party, err == nil)
+	}
+	if err != nil {
+		return errors.Join(ErrDeepVerifyFail, err)
+	}
+	return nil
+}
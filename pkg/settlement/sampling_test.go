@@ -0,0 +1,150 @@
+package settlement
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSamplerKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed([]byte(strings.Repeat("k", ed25519.SeedSize)))
+}
+
+func TestProofSampler_RateConverges(t *testing.T) {
+	require := require.New(t)
+
+	for _, rate := range []float64{0.01, 0.1, 0.5} {
+		s := NewProofSampler(testSamplerKey(), rate)
+		s.SetEpoch(1, []byte("epoch-1"))
+
+		const n = 10000
+		hits := 0
+		for i := 0; i < n; i++ {
+			if s.Select(fmt.Sprintf("imp-%d", i)).Selected {
+				hits++
+			}
+		}
+		got := float64(hits) / n
+		// Within 4 standard deviations of the binomial mean
+		tolerance := 4 * math.Sqrt(rate*(1-rate)/n)
+		require.InDelta(rate, got, tolerance, "rate %v", rate)
+	}
+}
+
+func TestProofSampler_Reproducible(t *testing.T) {
+	require := require.New(t)
+	key := testSamplerKey()
+
+	a := NewProofSampler(key, 0.3)
+	b := NewProofSampler(key, 0.3)
+	a.SetEpoch(7, []byte("seed"))
+	b.SetEpoch(7, []byte("seed"))
+
+	differs := false
+	c := NewProofSampler(key, 0.3)
+	c.SetEpoch(8, []byte("other-seed"))
+
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("imp-%d", i)
+		da, db := a.Select(id), b.Select(id)
+		require.Equal(da, db)
+		require.NoError(VerifySelection(key.Public().(ed25519.PublicKey), []byte("seed"), 0.3, id, da))
+		if c.Select(id).Selected != da.Selected {
+			differs = true
+		}
+	}
+	require.True(differs, "a new epoch seed should change the selection")
+
+	// A forged decision doesn't verify
+	d := a.Select("imp-0")
+	d.Selected = !d.Selected
+	require.ErrorIs(VerifySelection(key.Public().(ed25519.PublicKey), []byte("seed"), 0.3, "imp-0", d), ErrSampleProof)
+}
+
+type failingVerifier struct{}
+
+func (failingVerifier) VerifyDelivery(*DeliveryProof) error {
+	return errors.New("player signature mismatch")
+}
+
+func TestSubmitDeliveryProof_SampledFailurePenalizes(t *testing.T) {
+	require := require.New(t)
+
+	sampler := NewProofSampler(testSamplerKey(), 1)
+	s := NewAUSDSettlement(nil, nil)
+	s.SetSampler(sampler, failingVerifier{})
+	minerKey := ed25519.NewKeyFromSeed([]byte(strings.Repeat("m", ed25519.SeedSize)))
+	s.SetSubmitterKey("miner-1", minerKey.Public().(ed25519.PublicKey))
+	proof := func(id string) *DeliveryProof {
+		return &DeliveryProof{
+			ImpressionID:     id,
+			ReservationID:    "res-1",
+			VRFNonce:         strings.Repeat("n", 32),
+			ViewabilityScore: 90,
+			PlayerSignature:  "player",
+			CDNSignature:     "cdn",
+			Timestamp:        time.Now(),
+		}
+	}
+
+	signed := proof("imp-1")
+	SignDeliveryProof(signed, "miner-1", minerKey)
+	_, err := s.SubmitDeliveryProof(context.Background(), signed)
+	require.ErrorIs(err, ErrDeepVerifyFail)
+	require.Equal(0.5, sampler.QualityScore("miner-1"))
+	require.Equal(InitialQualityScore, sampler.QualityScore("miner-2"))
+
+	// The sampled proof carries the decision that selected it
+	require.NotNil(signed.Sample)
+	require.NoError(VerifySelection(testSamplerKey().Public().(ed25519.PublicKey), nil, 1, "imp-1", *signed.Sample))
+
+	// Naming a submitter without their signature charges nobody
+	forged := proof("imp-2")
+	forged.Submitter = "miner-2"
+	_, err = s.SubmitDeliveryProof(context.Background(), forged)
+	require.ErrorIs(err, ErrDeepVerifyFail)
+	require.Equal(InitialQualityScore, sampler.QualityScore("miner-2"))
+	SignDeliveryProof(forged, "miner-1", ed25519.NewKeyFromSeed([]byte(strings.Repeat("x", ed25519.SeedSize))))
+	_, err = s.SubmitDeliveryProof(context.Background(), forged)
+	require.ErrorIs(err, ErrDeepVerifyFail)
+	require.Equal(0.5, sampler.QualityScore("miner-1"))
+
+	sampler.Record("miner-1", true)
+	require.InDelta(0.51, sampler.QualityScore("miner-1"), 1e-9)
+}
+
+func TestProofSampler_LowQualitySampledMore(t *testing.T) {
+	require := require.New(t)
+	key := testSamplerKey()
+	s := NewProofSampler(key, 0.1)
+	s.SetEpoch(1, []byte("seed"))
+
+	const n = 2000
+	base, low := 0, 0
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("imp-%d", i)
+		if s.Select(id).Selected {
+			base++
+		}
+		d := s.SelectFor(id, 0.25)
+		require.Equal(0.4, d.Rate)
+		require.NoError(VerifySelection(key.Public().(ed25519.PublicKey), []byte("seed"), 0.1, id, d))
+		if d.Selected {
+			low++
+		}
+	}
+	require.Greater(low, 3*base)
+
+	// A decision drawn below the sampler's rate doesn't verify
+	d := s.SelectFor("imp-0", 1)
+	d.Rate = 0.01
+	d.Selected = selected(d.Output, d.Rate)
+	require.ErrorIs(VerifySelection(key.Public().(ed25519.PublicKey), []byte("seed"), 0.1, "imp-0", d), ErrSampleProof)
+}
@@ -0,0 +1,98 @@
+package settlement
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/chainvm"
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/luxfi/adx/pkg/reqlog"
+	"github.com/luxfi/adx/pkg/tracing"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSettleImpression_LinksAuctionSpan(t *testing.T) {
+	require := require.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(prev)
+
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	escrow := chainvm.NewEscrowManager(&chainvm.VMState{}, engine, "AUSD")
+	_, err := escrow.FundCampaign(context.Background(), &chainvm.FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100)})
+	require.NoError(err)
+	s := NewAUSDSettlement(escrow, nil)
+
+	// The auction runs in the bid request...
+	auctionCtx := reqlog.WithID(context.Background(), "req-1")
+	_, auction := tracing.Tracer().Start(auctionCtx, "rtb.BidRequest")
+	tracing.RecordAuction("req-1", auction)
+	_, err = s.ProcessImpressionWin(auctionCtx, &ImpressionWinRequest{
+		ReservationID: "res-1",
+		CampaignID:    "c1",
+		Publisher:     "pub-1",
+		WinPrice:      decimal.NewFromInt(5),
+	})
+	require.NoError(err)
+	auction.End()
+
+	// ...and settles later, outside that request
+	require.NoError(s.settleImpression(context.Background(), &DeliveryProof{
+		ImpressionID:     "imp-1",
+		ReservationID:    "res-1",
+		VRFNonce:         strings.Repeat("n", 32),
+		ViewabilityScore: 100,
+		TimeInView:       2000,
+		Timestamp:        time.Now(),
+	}))
+
+	var settle *tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "settlement.settleImpression" {
+			settle = &span
+		}
+	}
+	require.NotNil(settle)
+	require.False(settle.Parent.IsValid())
+	require.Len(settle.Links, 1)
+	require.Equal(auction.SpanContext().SpanID(), settle.Links[0].SpanContext.SpanID())
+}
+
+func TestProcessImpressionWin_ExpiresAuctionLinks(t *testing.T) {
+	require := require.New(t)
+
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	escrow := chainvm.NewEscrowManager(&chainvm.VMState{}, engine, "AUSD")
+	_, err := escrow.FundCampaign(context.Background(), &chainvm.FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100)})
+	require.NoError(err)
+	s := NewAUSDSettlement(escrow, nil)
+
+	for _, id := range []string{"res-1", "res-2"} {
+		_, err := s.ProcessImpressionWin(context.Background(), &ImpressionWinRequest{
+			ReservationID: id,
+			RequestID:     "req-" + id,
+			CampaignID:    "c1",
+			Publisher:     "pub-1",
+			WinPrice:      decimal.NewFromInt(1),
+		})
+		require.NoError(err)
+	}
+	require.Len(s.requests, 2)
+
+	// Reservations that were never settled drop their link once expired
+	s.expireRequests(time.Now())
+	require.Len(s.requests, 2)
+	s.expireRequests(s.requests["res-2"].Expires.Add(time.Millisecond))
+	require.Empty(s.requests)
+	require.Empty(s.expiries)
+}
@@ -0,0 +1,88 @@
+package settlement
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// Viewability curve modes
+const (
+	CurveLinear  = "linear"
+	CurveStepped = "stepped"
+)
+
+// ViewabilityStep pays Fraction for scores at or above MinScore
+type ViewabilityStep struct {
+	MinScore float64         `json:"min_score"`
+	Fraction decimal.Decimal `json:"fraction"`
+}
+
+// ViewabilityCurve maps measured viewability to the fraction of the
+// reserved price that is paid. Below Floor nothing is paid.
+type ViewabilityCurve struct {
+	Mode  string  `json:"mode"`
+	Floor float64 `json:"floor"` // Viewability % below which nothing is paid
+
+	// FloorFraction is what a linear curve pays at Floor, rising to 1 at
+	// 100% viewable
+	FloorFraction decimal.Decimal `json:"floor_fraction"`
+
+	// Steps for a stepped curve; the highest step reached applies
+	Steps []ViewabilityStep `json:"steps,omitempty"`
+
+	// FullTimeInView is the time in view (ms) that earns full payment;
+	// shorter views are paid pro rata. Zero ignores time in view.
+	FullTimeInView uint64 `json:"full_time_in_view_ms"`
+}
+
+// DefaultViewabilityCurve pays half at the IAB 70% floor rising linearly
+// to full at 100%, with full credit from two seconds in view
+var DefaultViewabilityCurve = ViewabilityCurve{
+	Mode:           CurveLinear,
+	Floor:          70,
+	FloorFraction:  decimal.NewFromFloat(0.5),
+	FullTimeInView: 2000,
+}
+
+// Fraction returns the share of the reserved price earned by a delivery,
+// in [0, 1]
+func (c ViewabilityCurve) Fraction(proof *DeliveryProof) decimal.Decimal {
+	score := proof.ViewabilityScore
+	if score < c.Floor || score <= 0 {
+		return decimal.Zero
+	}
+	if score > 100 {
+		score = 100
+	}
+
+	var f decimal.Decimal
+	switch c.Mode {
+	case CurveStepped:
+		f = decimal.Zero
+		steps := append([]ViewabilityStep(nil), c.Steps...)
+		sort.Slice(steps, func(i, j int) bool { return steps[i].MinScore < steps[j].MinScore })
+		for _, step := range steps {
+			if score >= step.MinScore {
+				f = step.Fraction
+			}
+		}
+	default:
+		f = decimal.NewFromInt(1)
+		if span := 100 - c.Floor; span > 0 {
+			progress := decimal.NewFromFloat(score - c.Floor).Div(decimal.NewFromFloat(span))
+			f = c.FloorFraction.Add(decimal.NewFromInt(1).Sub(c.FloorFraction).Mul(progress))
+		}
+	}
+
+	if c.FullTimeInView > 0 && proof.TimeInView < c.FullTimeInView {
+		f = f.Mul(decimal.NewFromInt(int64(proof.TimeInView))).Div(decimal.NewFromInt(int64(c.FullTimeInView)))
+	}
+
+	return decimal.Min(decimal.NewFromInt(1), decimal.Max(decimal.Zero, f))
+}
+
+// SetViewabilityCurve replaces the curve used to price deliveries
+func (s *AUSDSettlement) SetViewabilityCurve(c ViewabilityCurve) {
+	s.viewability = c
+}
@@ -0,0 +1,103 @@
+package settlement
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxfi/adx/pkg/chainvm"
+	"github.com/luxfi/adx/pkg/dex"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewabilityCurve_Linear(t *testing.T) {
+	require := require.New(t)
+	curve := DefaultViewabilityCurve
+
+	tests := []struct {
+		score float64
+		ms    uint64
+		want  string
+	}{
+		{50, 5000, "0"},
+		{69.9, 5000, "0"},
+		{70, 5000, "0.5"},
+		{85, 5000, "0.75"},
+		{100, 5000, "1"},
+		{100, 1000, "0.5"}, // half the required time in view
+		{85, 0, "0"},
+	}
+	for _, tt := range tests {
+		got := curve.Fraction(&DeliveryProof{ViewabilityScore: tt.score, TimeInView: tt.ms})
+		require.True(got.Equal(decimal.RequireFromString(tt.want)), "score %v ms %d: got %s, want %s", tt.score, tt.ms, got, tt.want)
+	}
+
+	f75 := curve.Fraction(&DeliveryProof{ViewabilityScore: 75, TimeInView: 2000})
+	f95 := curve.Fraction(&DeliveryProof{ViewabilityScore: 95, TimeInView: 2000})
+	require.True(f75.LessThan(f95), "75%% pays %s, 95%% pays %s", f75, f95)
+}
+
+func TestViewabilityCurve_Stepped(t *testing.T) {
+	require := require.New(t)
+	curve := ViewabilityCurve{
+		Mode:  CurveStepped,
+		Floor: 50,
+		Steps: []ViewabilityStep{
+			{MinScore: 90, Fraction: decimal.NewFromInt(1)},
+			{MinScore: 50, Fraction: decimal.RequireFromString("0.4")},
+			{MinScore: 70, Fraction: decimal.RequireFromString("0.8")},
+		},
+	}
+
+	for score, want := range map[float64]string{40: "0", 50: "0.4", 69: "0.4", 70: "0.8", 95: "1"} {
+		got := curve.Fraction(&DeliveryProof{ViewabilityScore: score})
+		require.True(got.Equal(decimal.RequireFromString(want)), "score %v: got %s, want %s", score, got, want)
+	}
+}
+
+func TestSettleImpression_ViewabilityPricing(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	state := &chainvm.VMState{}
+	engine := dex.NewEngine()
+	engine.SetBalance("AUSD", "adv-1", decimal.NewFromInt(100))
+	escrow := chainvm.NewEscrowManager(state, engine, "AUSD")
+	_, err := escrow.FundCampaign(ctx, &chainvm.FundCampaignRequest{CampaignID: "c1", Advertiser: "adv-1", Amount: decimal.NewFromInt(100)})
+	require.NoError(err)
+
+	s := NewAUSDSettlement(escrow, nil)
+	settle := func(id string, score float64) decimal.Decimal {
+		_, err := s.ProcessImpressionWin(ctx, &ImpressionWinRequest{
+			ReservationID: id,
+			CampaignID:    "c1",
+			Publisher:     "pub-" + id,
+			WinPrice:      decimal.NewFromInt(10),
+		})
+		require.NoError(err)
+		require.NoError(s.settleImpression(ctx, &DeliveryProof{
+			ImpressionID:     "imp-" + id,
+			ReservationID:    id,
+			VRFNonce:         strings.Repeat("n", 32),
+			ViewabilityScore: score,
+			TimeInView:       3000,
+			Timestamp:        time.Now(),
+		}))
+		return state.GetPublisherBalance("pub-" + id)
+	}
+
+	require.True(settle("r60", 60).IsZero(), "below-floor view should pay nothing")
+	require.True(settle("r76", 76).Equal(decimal.NewFromInt(6)))
+	require.True(settle("r97", 97).Equal(decimal.RequireFromString("9.5")))
+	require.True(settle("r100", 100).Equal(decimal.NewFromInt(10)))
+
+	// Everything not paid out is back in the campaign's available budget
+	rec, err := escrow.ReconcileCampaign("c1")
+	require.NoError(err)
+	require.True(rec.Balanced(), "delta %+v", rec.Delta)
+	require.True(rec.Recorded.Reserved.IsZero())
+	require.True(rec.Recorded.Spent.Equal(decimal.RequireFromString("25.5")), "spent %s", rec.Recorded.Spent)
+	require.True(rec.Recorded.Available.Equal(decimal.RequireFromString("74.5")), "available %s", rec.Recorded.Available)
+}
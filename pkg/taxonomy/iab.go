@@ -0,0 +1,107 @@
+package taxonomy
+
+import (
+	"strconv"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+)
+
+// iab10Subcategories is how many subcategories each Content Taxonomy 1.0
+// tier-1 category, IAB1 to IAB26, has
+var iab10Subcategories = [26]int{
+	7,  // IAB1 Arts & Entertainment
+	23, // IAB2 Automotive
+	12, // IAB3 Business
+	11, // IAB4 Careers
+	15, // IAB5 Education
+	9,  // IAB6 Family & Parenting
+	45, // IAB7 Health & Fitness
+	18, // IAB8 Food & Drink
+	31, // IAB9 Hobbies & Interests
+	9,  // IAB10 Home & Garden
+	5,  // IAB11 Law, Gov't & Politics
+	3,  // IAB12 News
+	12, // IAB13 Personal Finance
+	8,  // IAB14 Society
+	10, // IAB15 Science
+	7,  // IAB16 Pets
+	44, // IAB17 Sports
+	6,  // IAB18 Style & Fashion
+	36, // IAB19 Technology & Computing
+	27, // IAB20 Travel
+	3,  // IAB21 Real Estate
+	4,  // IAB22 Shopping
+	10, // IAB23 Religion & Spirituality
+	0,  // IAB24 Uncategorized
+	7,  // IAB25 Non-Standard Content
+	4,  // IAB26 Illegal Content
+}
+
+// iab20Tier1 lists the Content Taxonomy 2.x tier-1 categories in ID order
+// with their 1.0 equivalents. IDs are assigned depth-first, so each
+// category's descendants take the IDs up to the next tier-1 category's;
+// they're registered directly under their tier-1 category.
+var iab20Tier1 = []struct {
+	id    int
+	iab10 []string
+}{
+	{1, []string{"IAB2"}},             // Automotive
+	{42, []string{"IAB1-1"}},          // Books and Literature
+	{52, []string{"IAB3"}},            // Business and Finance
+	{123, []string{"IAB4"}},           // Careers
+	{132, []string{"IAB5"}},           // Education
+	{150, nil},                        // Attractions
+	{186, []string{"IAB6"}},           // Family and Relationships
+	{201, []string{"IAB1-4"}},         // Fine Art
+	{210, []string{"IAB8"}},           // Food & Drink
+	{223, []string{"IAB7"}},           // Healthy Living
+	{239, []string{"IAB9"}},           // Hobbies & Interests
+	{274, []string{"IAB10"}},          // Home & Garden
+	{286, []string{"IAB7"}},           // Medical Health
+	{324, []string{"IAB1-5"}},         // Movies
+	{338, []string{"IAB1-6"}},         // Music and Audio
+	{379, []string{"IAB11", "IAB12"}}, // News and Politics
+	{391, []string{"IAB13"}},          // Personal Finance
+	{422, []string{"IAB16"}},          // Pets
+	{432, []string{"IAB1-2"}},         // Pop Culture
+	{441, []string{"IAB21"}},          // Real Estate
+	{453, []string{"IAB23"}},          // Religion & Spirituality
+	{464, []string{"IAB15"}},          // Science
+	{473, []string{"IAB22"}},          // Shopping
+	{483, []string{"IAB17"}},          // Sports
+	{552, []string{"IAB18"}},          // Style & Fashion
+	{596, []string{"IAB19"}},          // Technology & Computing
+	{640, []string{"IAB1-7"}},         // Television
+	{653, []string{"IAB20"}},          // Travel
+	{680, []string{"IAB9-30"}},        // Video Gaming
+}
+
+// iab20LastID is the last numeric 2.x ID; later versions add alphanumeric
+// IDs, which have to be registered with Add
+const iab20LastID = 698
+
+// loadIAB registers the built-in categories and mappings
+func loadIAB(t *Taxonomy) {
+	for i, subs := range iab10Subcategories {
+		tier1 := Cat(adcom1.CatTaxIABContent10, "IAB"+strconv.Itoa(i+1))
+		t.Add(tier1, Category{})
+		for j := 1; j <= subs; j++ {
+			t.Add(Cat(adcom1.CatTaxIABContent10, tier1.ID+"-"+strconv.Itoa(j)), tier1)
+		}
+	}
+
+	for i, tier1 := range iab20Tier1 {
+		last := iab20LastID
+		if i+1 < len(iab20Tier1) {
+			last = iab20Tier1[i+1].id - 1
+		}
+		parent := Cat(adcom1.CatTaxIABContent20, strconv.Itoa(tier1.id))
+		t.Add(parent, Category{})
+		for id := tier1.id + 1; id <= last; id++ {
+			t.Add(Cat(adcom1.CatTaxIABContent20, strconv.Itoa(id)), parent)
+		}
+		for _, code := range tier1.iab10 {
+			t.Map(parent, Cat(adcom1.CatTaxIABContent10, code))
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package taxonomy validates IAB content categories and relates them across
+// taxonomy versions, so a block list written against one version also
+// catches equivalent and more specific categories from another.
+//
+// Content Taxonomy 1.0 codes are "IAB<n>" or "IAB<n>-<m>". The 2.x versions
+// share numeric IDs, so 2.0, 2.1 and 2.2 are treated as one taxonomy.
+package taxonomy
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+)
+
+// Category is a category ID in a taxonomy
+type Category struct {
+	Tax adcom1.CategoryTaxonomy
+	ID  string
+}
+
+// Cat returns the category id in taxonomy tax, normalized. A zero tax is
+// Content Taxonomy 1.0, OpenRTB's default.
+func Cat(tax adcom1.CategoryTaxonomy, id string) Category {
+	switch tax {
+	case 0:
+		tax = adcom1.CatTaxIABContent10
+	case adcom1.CatTaxIABContent21, adcom1.CatTaxIABContent22:
+		tax = adcom1.CatTaxIABContent20
+	}
+	id = strings.TrimSpace(id)
+	if tax == adcom1.CatTaxIABContent10 {
+		id = strings.ToUpper(id)
+	}
+	return Category{Tax: tax, ID: id}
+}
+
+// Taxonomy holds the category trees and the equivalences between them
+type Taxonomy struct {
+	mu     sync.RWMutex
+	known  map[Category]bool
+	parent map[Category]Category
+	equiv  map[Category][]Category
+}
+
+// New returns an empty taxonomy
+func New() *Taxonomy {
+	return &Taxonomy{
+		known:  make(map[Category]bool),
+		parent: make(map[Category]Category),
+		equiv:  make(map[Category][]Category),
+	}
+}
+
+var (
+	defaultOnce sync.Once
+	defaultTax  *Taxonomy
+)
+
+// Default returns the built-in taxonomy: the full Content Taxonomy 1.0
+// tree, the 2.x tier-1 categories and their 1.0 equivalents. Load the IAB's
+// published mappings into it with Add and Map for finer coverage.
+func Default() *Taxonomy {
+	defaultOnce.Do(func() {
+		defaultTax = New()
+		loadIAB(defaultTax)
+	})
+	return defaultTax
+}
+
+// Add registers a category under parent, or as a top-level category when
+// parent's ID is empty
+func (t *Taxonomy) Add(c, parent Category) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.known[c] = true
+	if parent.ID != "" {
+		t.parent[c] = parent
+	}
+}
+
+// Map records that a and b, usually in different taxonomies, name the same
+// category
+func (t *Taxonomy) Map(a, b Category) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.equiv[a] = append(t.equiv[a], b)
+	t.equiv[b] = append(t.equiv[b], a)
+}
+
+// Valid reports whether c is a known category
+func (t *Taxonomy) Valid(c Category) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.known[c]
+}
+
+// Parent returns c's parent category, if it has one
+func (t *Taxonomy) Parent(c Category) (Category, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.parentOf(c)
+}
+
+// parentOf is c's registered parent or, for a 1.0 subcategory code that
+// isn't registered, the tier-1 category in its code
+func (t *Taxonomy) parentOf(c Category) (Category, bool) {
+	if p, ok := t.parent[c]; ok {
+		return p, true
+	}
+	if c.Tax == adcom1.CatTaxIABContent10 {
+		if tier1, _, ok := strings.Cut(c.ID, "-"); ok {
+			return Category{Tax: c.Tax, ID: tier1}, true
+		}
+	}
+	return Category{}, false
+}
+
+// Convert returns the categories equivalent to c in taxonomy tax. A
+// category without a direct equivalent converts to its nearest ancestor's.
+func (t *Taxonomy) Convert(c Category, tax adcom1.CategoryTaxonomy) []Category {
+	tax = Cat(tax, "").Tax
+	if c.Tax == tax {
+		return []Category{c}
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for cur, ok := c, true; ok; cur, ok = t.parentOf(cur) {
+		var out []Category
+		for _, e := range t.equiv[cur] {
+			if e.Tax == tax {
+				out = append(out, e)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return nil
+}
+
+// Expand returns c, its ancestors and their equivalents in other
+// taxonomies, with those equivalents' own ancestors: every category whose
+// block should also block c
+func (t *Taxonomy) Expand(c Category) []Category {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	seen := make(map[Category]bool)
+	var out []Category
+	var walk func(Category)
+	walk = func(c Category) {
+		for cur, ok := c, true; ok && !seen[cur]; cur, ok = t.parentOf(cur) {
+			seen[cur] = true
+			out = append(out, cur)
+			for _, e := range t.equiv[cur] {
+				walk(e)
+			}
+		}
+	}
+	walk(c)
+	return out
+}
+
+// Blocked reports whether any of cats is in blocked, directly, through an
+// ancestor or through an equivalent in another taxonomy
+func (t *Taxonomy) Blocked(blocked, cats []Category) bool {
+	if len(blocked) == 0 || len(cats) == 0 {
+		return false
+	}
+	set := make(map[Category]bool, len(blocked))
+	for _, b := range blocked {
+		set[b] = true
+	}
+	for _, c := range cats {
+		for _, e := range t.Expand(c) {
+			if set[e] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Cats returns ids as categories in taxonomy tax
+func Cats(tax adcom1.CategoryTaxonomy, ids []string) []Category {
+	out := make([]Category, len(ids))
+	for i, id := range ids {
+		out[i] = Cat(tax, id)
+	}
+	return out
+}
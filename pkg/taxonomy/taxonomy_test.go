@@ -0,0 +1,90 @@
+package taxonomy
+
+import (
+	"testing"
+
+	"github.com/prebid/openrtb/v20/adcom1"
+)
+
+var (
+	v1 = adcom1.CatTaxIABContent10
+	v2 = adcom1.CatTaxIABContent20
+)
+
+func TestValid(t *testing.T) {
+	tax := Default()
+	tests := []struct {
+		cat  Category
+		want bool
+	}{
+		{Cat(v1, "IAB7"), true},
+		{Cat(v1, "iab7-45"), true},
+		{Cat(v1, "IAB7-46"), false},
+		{Cat(v1, "IAB27"), false},
+		{Cat(v1, "IAB24-1"), false},
+		{Cat(v2, "483"), true},
+		{Cat(adcom1.CatTaxIABContent22, "500"), true},
+		{Cat(v2, "699"), false},
+		{Cat(v2, "IAB17"), false},
+	}
+	for _, tt := range tests {
+		if got := tax.Valid(tt.cat); got != tt.want {
+			t.Errorf("Valid(%v) = %v, want %v", tt.cat, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tax := Default()
+	if got := tax.Convert(Cat(v1, "IAB17"), v2); len(got) != 1 || got[0].ID != "483" {
+		t.Errorf("IAB17 in 2.x = %v, want 483", got)
+	}
+	// Subcategories convert through their parent
+	if got := tax.Convert(Cat(v1, "IAB17-12"), v2); len(got) != 1 || got[0].ID != "483" {
+		t.Errorf("IAB17-12 in 2.x = %v, want 483", got)
+	}
+	if got := tax.Convert(Cat(v2, "379"), v1); len(got) != 2 {
+		t.Errorf("379 in 1.0 = %v, want IAB11 and IAB12", got)
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	tax := Default()
+	tests := []struct {
+		name    string
+		blocked []Category
+		cats    []Category
+		want    bool
+	}{
+		{"parent blocks child", []Category{Cat(v1, "IAB7")}, []Category{Cat(v1, "IAB7-3")}, true},
+		{"child doesn't block parent", []Category{Cat(v1, "IAB7-3")}, []Category{Cat(v1, "IAB7")}, false},
+		{"child doesn't block sibling", []Category{Cat(v1, "IAB7-3")}, []Category{Cat(v1, "IAB7-4")}, false},
+		{"unregistered subcategory", []Category{Cat(v1, "IAB11")}, []Category{Cat(v1, "IAB11-99")}, true},
+		{"1.0 block, 2.x creative", []Category{Cat(v1, "IAB17")}, []Category{Cat(v2, "483")}, true},
+		{"2.x block, 1.0 creative", []Category{Cat(v2, "483")}, []Category{Cat(v1, "IAB17")}, true},
+		{"1.0 parent, 2.x grandchild", []Category{Cat(v1, "IAB17")}, []Category{Cat(v2, "500")}, true},
+		{"2.x parent, 1.0 child", []Category{Cat(v2, "483")}, []Category{Cat(v1, "IAB17-12")}, true},
+		{"2.x subcategory to 1.0 subcategory", []Category{Cat(v1, "IAB9")}, []Category{Cat(v2, "685")}, true},
+		{"unrelated", []Category{Cat(v1, "IAB17")}, []Category{Cat(v2, "596"), Cat(v1, "IAB19-1")}, false},
+	}
+	for _, tt := range tests {
+		if got := tax.Blocked(tt.blocked, tt.cats); got != tt.want {
+			t.Errorf("%s: Blocked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddMap(t *testing.T) {
+	tax := New()
+	auto := Cat(v2, "1")
+	tax.Add(auto, Category{})
+	tax.Add(Cat(adcom1.CatTaxIABContent22, "EZWB7V"), auto)
+	tax.Map(auto, Cat(v1, "IAB2"))
+
+	if !tax.Valid(Cat(adcom1.CatTaxIABContent21, "EZWB7V")) {
+		t.Error("2.x versions don't share IDs")
+	}
+	if !tax.Blocked([]Category{Cat(v1, "IAB2")}, []Category{Cat(v2, "EZWB7V")}) {
+		t.Error("registered category not blocked through its parent's equivalent")
+	}
+}
@@ -4,19 +4,21 @@
 package tee
 
 import (
-	cryptorand "crypto/rand"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
-	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/luxfi/adx/pkg/auction"
+	"github.com/luxfi/adx/pkg/auction/tiebreak"
 	"github.com/luxfi/adx/pkg/core"
 	"github.com/luxfi/adx/pkg/crypto"
+	"github.com/luxfi/adx/pkg/entropy"
 	"github.com/luxfi/adx/pkg/ids"
 	"github.com/luxfi/adx/pkg/log"
+	"github.com/luxfi/adx/pkg/reqlog"
 )
 
 var (
@@ -56,6 +58,9 @@ type Enclave struct {
 	// Sealing keys (never leave enclave)
 	sealingKey []byte
 
+	// rand supplies the enclave's IDs, keys, nonces and simulated bids
+	rand entropy.Source
+
 	// Auction state (encrypted at rest)
 	auctions map[ids.ID]*SealedAuction
 
@@ -88,8 +93,15 @@ type SealedAuction struct {
 
 // NewEnclave creates a new TEE enclave
 func NewEnclave(enclaveType EnclaveType, logger log.Logger) (*Enclave, error) {
+	return NewEnclaveWithSource(enclaveType, logger, entropy.Crypto())
+}
+
+// NewEnclaveWithSource creates an enclave drawing its randomness from src.
+// Enclaves given Seeded sources with the same seed reach the same auction
+// outcomes, for tests and proof replays; production uses NewEnclave.
+func NewEnclaveWithSource(enclaveType EnclaveType, logger log.Logger, src entropy.Source) (*Enclave, error) {
 	enclave := &Enclave{
-		ID:            ids.GenerateTestID(),
+		rand:          src,
 		Type:          enclaveType,
 		Version:       "1.0.0",
 		auctions:      make(map[ids.ID]*SealedAuction),
@@ -99,9 +111,11 @@ func NewEnclave(enclaveType EnclaveType, logger log.Logger) (*Enclave, error) {
 		log:           logger,
 	}
 
+	enclave.ID = enclave.newID()
+
 	// Generate sealing key (never exposed outside enclave)
 	enclave.sealingKey = make([]byte, 32)
-	if _, err := cryptorand.Read(enclave.sealingKey); err != nil {
+	if _, err := enclave.rand.Read(enclave.sealingKey); err != nil {
 		return nil, err
 	}
 
@@ -176,7 +190,7 @@ func (e *Enclave) generateQuote() ([]byte, error) {
 	}
 
 	// Add random nonce
-	cryptorand.Read(statement.Nonce)
+	e.rand.Read(statement.Nonce)
 
 	// Serialize and sign (simplified)
 	data, err := json.Marshal(statement)
@@ -218,6 +232,13 @@ type EnclaveAuctionResult struct {
 
 // RunAuction runs an auction inside the enclave
 func (e *Enclave) RunAuction(auctionID ids.ID, reserve uint64, encryptedBids [][]byte) (*EnclaveAuctionResult, error) {
+	return e.RunAuctionContext(context.Background(), auctionID, reserve, encryptedBids)
+}
+
+// RunAuctionContext runs an auction inside the enclave, tagging its log
+// lines with the request carried by ctx
+func (e *Enclave) RunAuctionContext(ctx context.Context, auctionID ids.ID, reserve uint64, encryptedBids [][]byte) (*EnclaveAuctionResult, error) {
+	logger := reqlog.Logger(reqlog.With(ctx, reqlog.KeyAuction, auctionID.String()))
 	if !e.Attested {
 		return nil, ErrNotAttested
 	}
@@ -244,7 +265,7 @@ func (e *Enclave) RunAuction(auctionID ids.ID, reserve uint64, encryptedBids [][
 	for _, encBid := range encryptedBids {
 		bid, err := e.decryptBid(encBid)
 		if err != nil {
-			e.log.Debug("Failed to decrypt bid")
+			logger.Debug("failed to decrypt bid", "error", err)
 			continue
 		}
 		decryptedBids = append(decryptedBids, bid)
@@ -278,7 +299,7 @@ func (e *Enclave) RunAuction(auctionID ids.ID, reserve uint64, encryptedBids [][
 
 	e.processed++
 
-	e.log.Info("Auction processed in TEE")
+	logger.Info("auction processed in TEE", "bids", len(decryptedBids), "duration", time.Since(startTime))
 
 	return result, nil
 }
@@ -286,9 +307,11 @@ func (e *Enclave) RunAuction(auctionID ids.ID, reserve uint64, encryptedBids [][
 // BidData represents decrypted bid data
 type BidData struct {
 	BidderID   ids.ID
+	BidID      []byte // The sealed bid, which identifies it
 	Value      uint64
 	CreativeID ids.ID
 	Targeting  map[string]string
+	Timestamp  time.Time
 }
 
 // decryptBid decrypts a bid inside the enclave
@@ -302,15 +325,24 @@ func (e *Enclave) decryptBid(encryptedBid []byte) (*BidData, error) {
 
 	// Simulated decryption - ensure some bids are above typical reserve
 	bid := &BidData{
-		BidderID:   ids.GenerateTestID(),
-		Value:      uint64(rand.Intn(500) + 100), // 100-600 range
-		CreativeID: ids.GenerateTestID(),
+		BidderID:   e.newID(),
+		BidID:      encryptedBid,
+		Value:      uint64(e.rand.IntN(500) + 100), // 100-600 range
+		CreativeID: e.newID(),
 		Targeting:  make(map[string]string),
+		Timestamp:  time.Now(),
 	}
 
 	return bid, nil
 }
 
+// newID draws an ID from the enclave's source
+func (e *Enclave) newID() ids.ID {
+	var id ids.ID
+	e.rand.Read(id[:])
+	return id
+}
+
 // runSecondPriceAuction executes the auction logic
 func (e *Enclave) runSecondPriceAuction(bids []*BidData, reserve uint64) *auction.AuctionOutcome {
 	if len(bids) == 0 {
@@ -329,10 +361,10 @@ func (e *Enclave) runSecondPriceAuction(bids []*BidData, reserve uint64) *auctio
 			continue
 		}
 
-		if highest == nil || bid.Value > highest.Value {
+		if highest == nil || outranks(bid, highest) {
 			secondHighest = highest
 			highest = bid
-		} else if secondHighest == nil || bid.Value > secondHighest.Value {
+		} else if secondHighest == nil || outranks(bid, secondHighest) {
 			secondHighest = bid
 		}
 	}
@@ -358,6 +390,15 @@ func (e *Enclave) runSecondPriceAuction(bids []*BidData, reserve uint64) *auctio
 	}
 }
 
+// outranks orders bids by value, breaking ties in tiebreak order so the
+// enclave picks the same winner as the RTB path and the proof witness
+func outranks(a, b *BidData) bool {
+	if a.Value != b.Value {
+		return a.Value > b.Value
+	}
+	return tiebreak.Compare(a.BidderID[:], a.BidID, b.BidderID[:], b.BidID) < 0
+}
+
 // generateTranscript creates an audit log
 func (e *Enclave) generateTranscript(sealed *SealedAuction, bids []*BidData, outcome *auction.AuctionOutcome) []byte {
 	transcript := map[string]interface{}{
@@ -439,6 +480,18 @@ func (e *Enclave) GetAttestation() *core.BaseHeader {
 	}
 }
 
+// Ping reports ErrNotAttested until the enclave has a valid attestation,
+// for readiness checks
+func (e *Enclave) Ping(ctx context.Context) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if !e.Attested || len(e.Quote) == 0 {
+		return ErrNotAttested
+	}
+	return nil
+}
+
 // CheckFrequencyCap checks and updates frequency capping for a user-campaign pair
 func (e *Enclave) CheckFrequencyCap(userID, campaignID string, maxImpressions int) (bool, error) {
 	e.mu.Lock()
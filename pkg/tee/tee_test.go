@@ -5,7 +5,10 @@ package tee
 
 import (
 	"testing"
+	"time"
 
+	"github.com/luxfi/adx/pkg/auction/tiebreak"
+	"github.com/luxfi/adx/pkg/entropy"
 	"github.com/luxfi/adx/pkg/ids"
 	"github.com/luxfi/adx/pkg/log"
 	"github.com/stretchr/testify/require"
@@ -54,6 +57,32 @@ func TestEnclaveAuction(t *testing.T) {
 	require.NotZero(result.ProcessedAt)
 }
 
+func TestEnclaveAuctionSeeded(t *testing.T) {
+	require := require.New(t)
+
+	run := func(seed uint64) *EnclaveAuctionResult {
+		enclave, err := NewEnclaveWithSource(EnclaveSimulated, log.NoOp(), entropy.Seeded(seed))
+		require.NoError(err)
+		bids := [][]byte{
+			[]byte("sealed_bid_0001_payload"),
+			[]byte("sealed_bid_0002_payload"),
+			[]byte("sealed_bid_0003_payload"),
+		}
+		result, err := enclave.RunAuction(ids.ID{1}, 100, bids)
+		require.NoError(err)
+		return result
+	}
+
+	// The same seed samples the same bids, so the outcome is identical
+	first, second := run(7), run(7)
+	require.NotEqual(ids.Empty, first.WinnerID)
+	require.Equal(first.WinnerID, second.WinnerID)
+	require.Equal(first.ClearingPrice, second.ClearingPrice)
+	require.Equal(first.PriceCommit, second.PriceCommit)
+
+	require.NotEqual(first.WinnerID, run(8).WinnerID)
+}
+
 func TestEnclaveFrequencyCapping(t *testing.T) {
 	require := require.New(t)
 	logger := log.NoOp()
@@ -202,3 +231,45 @@ func BenchmarkEnclaveSecureStorage(b *testing.B) {
 		enclave.StoreSecure(key, value)
 	}
 }
+
+func TestSecondPriceAuctionTieBreak(t *testing.T) {
+	require := require.New(t)
+
+	enclave, err := NewEnclave(EnclaveSimulated, log.NoOp())
+	require.NoError(err)
+
+	now := time.Now()
+	var bidders [3]ids.ID
+	for i := range bidders {
+		bidders[i][0] = byte(i + 1)
+	}
+	bids := []*BidData{
+		{BidderID: bidders[0], BidID: []byte("b0"), Value: 500, Timestamp: now},
+		{BidderID: bidders[1], BidID: []byte("b1"), Value: 500, Timestamp: now},
+		{BidderID: bidders[2], BidID: []byte("b2"), Value: 500, Timestamp: now},
+		{BidderID: bidders[2], BidID: []byte("b3"), Value: 300, Timestamp: now},
+	}
+
+	// The documented winner is the lowest sha256(bidder ID) at the top price
+	want := bidders[0]
+	for _, id := range bidders[1:] {
+		if k, kw := tiebreak.Key(id[:]), tiebreak.Key(want[:]); string(k[:]) < string(kw[:]) {
+			want = id
+		}
+	}
+
+	for i := 0; i < len(bids); i++ {
+		rotated := append(append([]*BidData{}, bids[i:]...), bids[:i]...)
+		outcome := enclave.runSecondPriceAuction(rotated, 100)
+		require.Equal(want, outcome.WinnerID)
+		require.Equal(uint64(500), outcome.WinningBid)
+		require.Equal(uint64(500), outcome.ClearingPrice)
+	}
+
+	// Between one bidder's equal bids the lower sha256(bid ID) wins,
+	// however late it arrived: sha256("b") < sha256("b-2")
+	early := &BidData{BidderID: bidders[0], BidID: []byte("b-2"), Value: 500, Timestamp: now}
+	late := &BidData{BidderID: bidders[0], BidID: []byte("b"), Value: 500, Timestamp: now.Add(time.Second)}
+	require.True(outranks(late, early))
+	require.False(outranks(early, late))
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2025, ADXYZ Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package tracing wires OpenTelemetry spans through the bid, auction and
+// settlement pipeline. Until Setup installs an exporter the global tracer
+// provider is OpenTelemetry's no-op, so instrumented code pays nothing.
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Instrumentation scope for every span the exchange emits
+const ScopeName = "github.com/luxfi/adx"
+
+// Span attribute keys
+const (
+	AttrRequestID  = attribute.Key("adx.request_id")
+	AttrAuctionID  = attribute.Key("adx.auction_id")
+	AttrImpression = attribute.Key("adx.impression_id")
+	AttrDSP        = attribute.Key("adx.dsp")
+	AttrOutcome    = attribute.Key("adx.outcome")
+	AttrLatencyMS  = attribute.Key("adx.latency_ms")
+	AttrBids       = attribute.Key("adx.bids")
+	AttrPrice      = attribute.Key("adx.price")
+)
+
+// DSP call outcomes recorded on per-DSP spans
+const (
+	OutcomeBid         = "bid"
+	OutcomeNoBid       = "no_bid"
+	OutcomeError       = "error"
+	OutcomeRateLimited = "rate_limited"
+)
+
+// maxAuctions bounds how many auction span contexts are kept for linking
+const maxAuctions = 100000
+
+// Config selects the OTLP/HTTP exporter. An empty Endpoint disables
+// tracing.
+type Config struct {
+	Endpoint    string  // host:port of the OTLP/HTTP collector
+	Insecure    bool    // Plain HTTP instead of TLS
+	ServiceName string  // Defaults to "adx"
+	SampleRatio float64 // Fraction of new traces sampled; 0 means all
+}
+
+// Setup installs a global tracer provider exporting to cfg.Endpoint. The
+// returned function flushes and stops it. With no endpoint Setup leaves
+// the no-op provider in place.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	name := cfg.ServiceName
+	if name == "" {
+		name = "adx"
+	}
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", name))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the exchange's tracer from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(ScopeName)
+}
+
+// auctions maps correlation IDs to the span of the auction they started,
+// so settlement, which runs in a later request, can link back to it
+var auctions = struct {
+	sync.Mutex
+	spans map[string]trace.SpanContext
+	order []string
+}{spans: make(map[string]trace.SpanContext)}
+
+// RecordAuction remembers span as the auction for requestID
+func RecordAuction(requestID string, span trace.Span) {
+	sc := span.SpanContext()
+	if requestID == "" || !sc.IsValid() {
+		return
+	}
+
+	auctions.Lock()
+	defer auctions.Unlock()
+	if _, ok := auctions.spans[requestID]; !ok {
+		auctions.order = append(auctions.order, requestID)
+	}
+	auctions.spans[requestID] = sc
+	for len(auctions.order) > maxAuctions {
+		delete(auctions.spans, auctions.order[0])
+		auctions.order = auctions.order[1:]
+	}
+}
+
+// AuctionLink returns a span link to the auction recorded for requestID
+func AuctionLink(requestID string) (trace.Link, bool) {
+	auctions.Lock()
+	defer auctions.Unlock()
+	sc, ok := auctions.spans[requestID]
+	if !ok {
+		return trace.Link{}, false
+	}
+	return trace.Link{SpanContext: sc, Attributes: []attribute.KeyValue{AttrRequestID.String(requestID)}}, true
+}
@@ -3,13 +3,19 @@ package vast
 import (
 	"encoding/xml"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/luxfi/adx/pkg/reqlog"
+	"github.com/luxfi/adx/pkg/reqlog/reqgin"
 )
 
 // VASTRequest represents the complete VAST API request parameters
@@ -40,6 +46,22 @@ type VASTRequest struct {
 	IP   string `form:"ip" json:"ip"`     // IP address (required when srvi=1)
 	IPV6 string `form:"ipv6" json:"ipv6"` // IPv6 address
 
+	// Network
+	ConnectionType int    `form:"connectiontype" json:"connectiontype"` // OpenRTB connection type
+	Carrier        string `form:"carrier" json:"carrier"`               // Mobile carrier name
+	MCCMNC         string `form:"mccmnc" json:"mccmnc"`                 // Mobile country and network codes
+
+	// Hints are the client's User-Agent Client Hints, read from headers
+	Hints ClientHints `form:"-" json:"-"`
+
+	// NoContent asks for a bare 204 rather than a VAST document when
+	// there's no ad, for players that need one
+	NoContent int `form:"nocontent" json:"nocontent"`
+
+	// ServeID is unique to each serve and stamped on its tracking URLs, so
+	// serves of a cached auction are told apart
+	ServeID string `form:"-" json:"-"`
+
 	// Targeting Parameters
 	AdCount  int    `form:"adcount" default:"1" json:"adcount"` // Number of ads to return (1-20)
 	Locale   string `form:"locale" json:"locale"`               // Device locale (e.g., en_US)
@@ -60,6 +82,7 @@ type VASTRequest struct {
 
 	// Video/CTV Specific
 	RV             string `form:"rv" json:"rv"`                         // Rewarded video flag
+	Instl          int    `form:"instl" json:"instl"`                   // Interstitial (0=NO, 1=YES)
 	DH             string `form:"dh" json:"dh"`                         // Device height in pixels
 	DW             string `form:"dw" json:"dw"`                         // Device width in pixels
 	MinVideoDur    int    `form:"mindur" json:"mindur"`                 // Min video duration
@@ -73,8 +96,9 @@ type VASTRequest struct {
 	PlayerSize     string `form:"playersize" json:"playersize"`         // WxH format
 
 	// OMID (Open Measurement)
-	OMIDPN string `form:"omidpn" json:"omidpn"` // OMID Partner name
-	OMIDPV string `form:"omidpv" json:"omidpv"` // OMID Partner version
+	OMIDPN      string   `form:"omidpn" json:"omidpn"`           // OMID Partner name
+	OMIDPV      string   `form:"omidpv" json:"omidpv"`           // OMID Partner version
+	OMIDVendors []string `form:"omidvendors" json:"omidvendors"` // Verification vendors the publisher requires
 
 	// SKAdNetwork (iOS Attribution)
 	SKAdNVersion   string   `form:"skadn_version" json:"skadn_version"`     // SKAdNetwork version (2.0+)
@@ -115,6 +139,7 @@ type VASTRequest struct {
 	OnChainTracking int    `form:"onchain" json:"onchain"`   // On-chain tracking (0=NO, 1=YES)
 	DecentralizedID string `form:"did" json:"did"`           // Decentralized ID
 	ProofOfView     string `form:"pov" json:"pov"`           // Proof of view hash
+	SessionID       string `form:"sid" json:"sid"`           // Player session ID
 }
 
 // VASTHandler handles VAST API requests with full parameter support
@@ -124,20 +149,61 @@ type VASTHandler struct {
 	Analytics     AnalyticsEngine
 	PrivacyMgr    PrivacyManager
 	BlockchainMgr BlockchainManager
+	GeoResolver   GeoResolver
+
+	// VendorID is our IAB Global Vendor List ID, checked against TCF
+	// consent; GDPR requests get no ad when zero
+	VendorID int
+
+	SKAdN  *SKAdNetworkManager
+	Floors FloorProvider
+
+	// Rewards releases rewarded-video payouts on verified completion
+	Rewards *RewardManager
+
+	// Verifiers is the OMID vendor allowlist; DefaultVerificationVendors
+	// when nil
+	Verifiers map[string]VerificationVendor
+
+	// Cache shares auction results between identical, non-personalized
+	// requests; nil runs every auction
+	Cache *ResponseCache
+
+	// Receipts signs a receipt for each served ad; none are added when nil
+	Receipts *ReceiptSigner
+
+	// Settlements names the record receipts reference; the auction's DAG
+	// vertex when nil
+	Settlements SettlementLocator
+
+	// Enrichers annotate each bid request once it's built, and may end it
+	// with no ad; requests go out as built when nil
+	Enrichers *EnrichmentPipeline
+
+	// Auctions is told of every auction run, as for forecasting; nil
+	// tells no one
+	Auctions AuctionObserver
+
+	// PanicCount is bids dropped for panicking while their ad was built;
+	// updated atomically
+	PanicCount uint64
 }
 
 // HandleVASTRequest processes VAST API requests
 func (h *VASTHandler) HandleVASTRequest(c *gin.Context) {
 	var req VASTRequest
+	ctx := reqgin.Context(c)
 
 	// Bind query parameters
 	if err := c.ShouldBindQuery(&req); err != nil {
+		reqlog.Logger(ctx).Info("vast request rejected", "error", err)
 		c.XML(http.StatusBadRequest, VASTError{
 			Code:    400,
 			Message: "Invalid request parameters: " + err.Error(),
 		})
 		return
 	}
+	req.Hints = ParseClientHints(c.Request.Header)
 
 	// Validate server-to-server requirements
 	if req.SRVI == 1 && (req.UA == "" || req.IP == "") {
@@ -148,36 +214,93 @@ func (h *VASTHandler) HandleVASTRequest(c *gin.Context) {
 		return
 	}
 
+	ctx = reqlog.With(ctx, reqlog.KeyPublisher, req.AppToken)
+
 	// Privacy compliance checks
 	if err := h.checkPrivacyCompliance(&req); err != nil {
-		c.XML(http.StatusNoContent, nil) // No ads due to privacy
+		reqlog.Logger(ctx).Debug("vast no fill: privacy", "error", err)
+		h.noAd(c, &req, NoBidPrivacy)
 		return
 	}
 
 	// Build OpenRTB request from VAST parameters
 	rtbReq := h.buildOpenRTBRequest(&req)
+	ctx = reqlog.With(ctx, reqlog.KeyAuction, rtbReq.ID)
 
-	// Run auction
-	rtbResp, err := h.Exchange.RunAuction(c.Request.Context(), rtbReq)
-	if err != nil || len(rtbResp.SeatBid) == 0 {
-		c.XML(http.StatusNoContent, nil) // No ads available
-		return
+	req.ServeID = rtbReq.ID
+
+	// Enrichment stages annotate the request, or end it with no ad
+	if h.Enrichers != nil {
+		nb, err := h.Enrichers.Run(ctx, &req, rtbReq)
+		if err != nil {
+			reqlog.Logger(ctx).Warn("enrichment failed", "error", err)
+		}
+		if nb != nil {
+			reqlog.Logger(ctx).Debug("vast no fill: enrichment", "reason", nb.Reason, "error", nb.Err)
+			h.noAd(c, &req, nb.Reason)
+			return
+		}
+	}
+
+	// Run auction, unless an identical one just ran, in which case this
+	// serve is billed on its own
+	var key string
+	biller, bills := h.Exchange.(ServeBiller)
+	if h.Cache != nil && bills && cacheable(&req, c.Request.Header) {
+		key = cacheKey(&req, rtbReq)
+	}
+	rtbResp, hit := h.cachedAuction(key)
+	if hit {
+		if err := biller.BillServe(ctx, rtbReq, rtbResp); err != nil {
+			reqlog.Logger(ctx).Warn("vast no fill: cached serve not billed", "error", err)
+			h.noAd(c, &req, NoBidInternal)
+			return
+		}
+	} else {
+		var err error
+		rtbResp, err = h.Exchange.RunAuction(ctx, rtbReq)
+		if h.Auctions != nil {
+			h.Auctions.ObserveAuction(rtbReq, rtbResp)
+		}
+		if err != nil || len(rtbResp.SeatBid) == 0 {
+			reason := noBidReason(rtbResp, err)
+			reqlog.Logger(ctx).Debug("vast no fill", "reason", reason, "error", err)
+			h.noAd(c, &req, reason)
+			return
+		}
+		if key != "" {
+			h.Cache.Put(key, rtbResp)
+		}
 	}
 
 	// Convert OpenRTB response to VAST
 	vast := h.buildVASTResponse(&req, rtbResp)
+	if len(vast.Ads) == 0 {
+		reqlog.Logger(ctx).Debug("vast no fill", "reason", NoBidInternal, "error", "no bid built an ad")
+		h.noAd(c, &req, NoBidInternal)
+		return
+	}
 
 	// Track impression (async)
-	go h.trackImpression(&req, vast)
+	go h.trackImpression(&req, rtbResp, vast)
 
 	// Set cache headers for CDN
 	c.Header("Cache-Control", "private, max-age=300")
-	c.Header("X-ADX-Request-ID", rtbReq.ID)
+
+	reqlog.Logger(ctx).Info("vast ad served", reqlog.KeyDSP, rtbResp.SeatBid[0].Seat, "ads", len(vast.Ads), "cached", hit)
 
 	// Return VAST XML
 	c.XML(http.StatusOK, vast)
 }
 
+// cachedAuction returns the cached auction result for key, if any
+func (h *VASTHandler) cachedAuction(key string) (*OpenRTBResponse, bool) {
+	if key == "" {
+		return nil, false
+	}
+	return h.Cache.Get(key)
+}
+
 // buildOpenRTBRequest converts VAST request to OpenRTB
 func (h *VASTHandler) buildOpenRTBRequest(req *VASTRequest) *OpenRTBRequest {
 	rtb := &OpenRTBRequest{
@@ -252,6 +375,24 @@ func (h *VASTHandler) buildOpenRTBRequest(req *VASTRequest) *OpenRTBRequest {
 		imp.Video.W, imp.Video.H = 1920, 1080
 	}
 
+	// Interstitial and rewarded placements: interstitial video plays full
+	// screen, with a full-screen end card when the screen size is known
+	if req.RV == "1" {
+		imp.Rwdd = 1
+		imp.Video.Plcmt = 3 // Interstitial
+	}
+	if req.Instl == 1 {
+		imp.Instl = 1
+		imp.Video.Plcmt = 3
+		imp.Video.Placement = 5 // Interstitial/slider/floating
+		imp.Video.Pos = 7       // Full screen
+		w, _ := strconv.Atoi(req.DW)
+		h, _ := strconv.Atoi(req.DH)
+		if w > 0 && h > 0 {
+			imp.Video.CompanionAd = []Banner{{W: w, H: h, Pos: 7, VCM: 1}}
+		}
+	}
+
 	// Add impression
 	for i := 0; i < req.AdCount && i < 20; i++ {
 		impCopy := imp
@@ -260,12 +401,13 @@ func (h *VASTHandler) buildOpenRTBRequest(req *VASTRequest) *OpenRTBRequest {
 	}
 
 	// Device information
+	deviceMake, deviceType := lookupDevice(req.DeviceModel, req.UA)
 	rtb.Device = Device{
 		UA:         req.UA,
 		IP:         req.IP,
 		IPv6:       req.IPV6,
-		DeviceType: h.getDeviceType(req.DeviceModel),
-		Make:       h.getDeviceMake(req.DeviceModel),
+		DeviceType: deviceType,
+		Make:       deviceMake,
 		Model:      req.DeviceModel,
 		OS:         req.OS,
 		OSV:        req.OSVer,
@@ -274,7 +416,12 @@ func (h *VASTHandler) buildOpenRTBRequest(req *VASTRequest) *OpenRTBRequest {
 		DNT:        req.DNT,
 		LMT:        req.DNT,
 		Geo:        Geo{},
+
+		Carrier:        req.Carrier,
+		MCCMNC:         req.MCCMNC,
+		ConnectionType: req.ConnectionType,
 	}
+	enrichDevice(req, &rtb.Device)
 
 	// Handle device dimensions
 	if req.DW != "" {
@@ -298,6 +445,25 @@ func (h *VASTHandler) buildOpenRTBRequest(req *VASTRequest) *OpenRTBRequest {
 		}
 	}
 
+	// IP-derived location for requests that don't send coordinates
+	if loc := h.resolveGeo(req); loc.Country != "" {
+		rtb.Device.Geo.Country = loc.Country
+		rtb.Device.Geo.Region = loc.Region
+		rtb.Device.Geo.City = loc.City
+		if rtb.Device.Geo.Lat == 0 && rtb.Device.Geo.Lon == 0 {
+			rtb.Device.Geo.Type = 2 // IP address
+		}
+	}
+
+	// Per-impression floors once placement, geo and device are known
+	if h.Floors != nil {
+		floor := h.Floors.FloorFor(strconv.Itoa(req.ZoneID), rtb.Device.Geo.Country, rtb.Device.DeviceType, time.Now())
+		for i := range rtb.Imp {
+			rtb.Imp[i].TagID = strconv.Itoa(req.ZoneID)
+			rtb.Imp[i].BidFloor = floor
+		}
+	}
+
 	// User information
 	rtb.User = User{
 		ID:       req.UID,
@@ -341,8 +507,10 @@ func (h *VASTHandler) buildOpenRTBRequest(req *VASTRequest) *OpenRTBRequest {
 		CCPA:  req.USPrivacy,
 	}
 
+	h.applyConsent(req, rtb)
+
 	// SKAdNetwork for iOS
-	if req.SKAdNVersion != "" {
+	if ValidSKAdNVersion(req.SKAdNVersion) {
 		rtb.Source.SKAdN = &SKAdNetwork{
 			Version:    req.SKAdNVersion,
 			SourceApp:  req.SKAdNSourceApp,
@@ -376,16 +544,39 @@ func (h *VASTHandler) buildVASTResponse(req *VASTRequest, rtbResp *OpenRTBRespon
 
 	for _, seatBid := range rtbResp.SeatBid {
 		for _, bid := range seatBid.Bid {
-			ad := h.createVASTAd(req, &bid)
-			vast.Ads = append(vast.Ads, ad)
+			if ad, ok := h.vastAd(req, rtbResp.ID, seatBid.Seat, &bid); ok {
+				vast.Ads = append(vast.Ads, ad)
+			}
 		}
 	}
 
 	return vast
 }
 
+// vastAd builds one bid's ad; a bid that panics on the way is dropped,
+// logged and counted, so it can't take the others down with it
+func (h *VASTHandler) vastAd(req *VASTRequest, auctionID, seat string, bid *Bid) (ad Ad, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&h.PanicCount, 1)
+			slog.Error("vast ad dropped after panic", reqlog.KeyDSP, seat, "bid", bid.ID, "panic", r, "stack", string(debug.Stack()))
+			ad, ok = Ad{}, false
+		}
+	}()
+	ad = h.createVASTAd(req, bid)
+	h.attachSKAdN(req, seat, bid, &ad)
+	h.attachReward(req, bid, &ad)
+	h.attachReceipt(req, auctionID, seat, bid, &ad)
+	return ad, true
+}
+
 // createVASTAd creates a VAST Ad from OpenRTB Bid
 func (h *VASTHandler) createVASTAd(req *VASTRequest, bid *Bid) Ad {
+	title := "Video Ad"
+	brand := advertiser(bid)
+	if brand != "" {
+		title = sanitizeText(brand+" Video Ad", maxTitleLen)
+	}
 	ad := Ad{
 		ID: bid.ID,
 		InLine: &InLine{
@@ -393,12 +584,12 @@ func (h *VASTHandler) createVASTAd(req *VASTRequest, bid *Bid) Ad {
 				Name:    "Lux ADX",
 				Version: "1.0",
 			},
-			AdTitle:     bid.ADomain[0] + " Video Ad",
+			AdTitle:     title,
 			Description: "Video advertisement",
-			Advertiser:  bid.ADomain[0],
+			Advertiser:  brand,
 			Pricing: &Pricing{
 				Model:    "CPM",
-				Currency: bid.Cur,
+				Currency: sanitizeText(bid.Cur, 3),
 				Value:    bid.Price,
 			},
 			Impression: []Impression{},
@@ -425,9 +616,6 @@ func (h *VASTHandler) createVASTAd(req *VASTRequest, bid *Bid) Ad {
 				MediaFile: []MediaFile{},
 			},
 			VideoClicks: &VideoClicks{
-				ClickThrough: &ClickThrough{
-					URL: bid.NURL, // Click URL
-				},
 				ClickTracking: []ClickTracking{
 					{URL: h.buildTrackingURL("click", req, bid)},
 				},
@@ -444,39 +632,26 @@ func (h *VASTHandler) createVASTAd(req *VASTRequest, bid *Bid) Ad {
 		},
 	}
 
-	// Add media files based on ad layout
-	mediaFiles := h.getMediaFilesForLayout(req.AL, bid.ADURL)
-	creative.Linear.MediaFiles.MediaFile = mediaFiles
+	if click, ok := safeURL(bid.NURL); ok {
+		creative.Linear.VideoClicks.ClickThrough = &ClickThrough{URL: click}
+	}
+
+	// Add media files based on ad layout, unless the creative URL is unusable
+	if mediaURL, ok := safeURL(bid.ADURL); ok {
+		creative.Linear.MediaFiles.MediaFile = h.getMediaFilesForLayout(req.AL, mediaURL)
+	}
 
-	// Add skip offset if applicable
+	// Add skip offset if applicable, with a beacon for when the viewer skips
 	if req.Skip == 1 && req.SkipMin > 0 {
 		creative.Linear.SkipOffset = fmt.Sprintf("00:00:%02d", req.SkipMin)
+		creative.Linear.TrackingEvents.Tracking = append(creative.Linear.TrackingEvents.Tracking,
+			Tracking{Event: "skip", URL: h.buildTrackingURL("skip", req, bid)})
 	}
 
 	ad.InLine.Creatives.Creative = append(ad.InLine.Creatives.Creative, creative)
 
-	// Add OMID verification if present
-	if req.OMIDPN != "" {
-		ad.InLine.Extensions = &Extensions{
-			Extension: []Extension{
-				{
-					Type: "AdVerifications",
-					AdVerifications: &AdVerifications{
-						Verification: []Verification{
-							{
-								Vendor: req.OMIDPN,
-								JavaScriptResource: &JavaScriptResource{
-									APIFramework: "omid",
-									URL:          h.getOMIDVerificationScript(req.OMIDPN),
-								},
-								VerificationParameters: fmt.Sprintf(`{"partnername":"%s","partnerversion":"%s"}`, req.OMIDPN, req.OMIDPV),
-							},
-						},
-					},
-				},
-			},
-		}
-	}
+	// Open Measurement verification scripts, from the publisher and the DSP
+	ad.InLine.AdVerifications = h.adVerifications(req, bid)
 
 	return ad
 }
@@ -493,62 +668,30 @@ func (h *VASTHandler) checkPrivacyCompliance(req *VASTRequest) error {
 		req.GID = ""
 	}
 
-	// GDPR compliance
+	// GDPR compliance. Without our vendor ID the TCF string can't tell
+	// whether we, rather than any vendor, may use the user's data.
 	if req.GDPR == 1 && req.UserConsent == "" {
 		return fmt.Errorf("GDPR requires user consent")
 	}
+	if req.GDPR == 1 && h.VendorID == 0 {
+		return fmt.Errorf("GDPR requires a TCF vendor ID")
+	}
 
 	// CCPA compliance
-	if strings.HasPrefix(req.USPrivacy, "1Y") {
-		// User has opted out of sale
+	if usPrivacyOptOut(req.USPrivacy) {
+		// User has opted out of sale, so nothing identifying goes to bidders
 		req.DNT = 1
+		req.UID = ""
+		req.IDFA = ""
+		req.GID = ""
+		req.IFV = ""
+		req.Lat = ""
+		req.Long = ""
 	}
 
 	return nil
 }
 
-func (h *VASTHandler) getDeviceType(model string) int {
-	model = strings.ToLower(model)
-	switch {
-	case strings.Contains(model, "roku"):
-		return 3 // Connected TV
-	case strings.Contains(model, "firetv"), strings.Contains(model, "fire tv"):
-		return 3
-	case strings.Contains(model, "appletv"), strings.Contains(model, "apple tv"):
-		return 3
-	case strings.Contains(model, "chromecast"):
-		return 3
-	case strings.Contains(model, "smarttv"), strings.Contains(model, "smart tv"):
-		return 3
-	case strings.Contains(model, "iphone"), strings.Contains(model, "ipad"):
-		return 4 // Phone/Tablet
-	case strings.Contains(model, "android"):
-		return 4
-	default:
-		return 2 // Connected Device
-	}
-}
-
-func (h *VASTHandler) getDeviceMake(model string) string {
-	model = strings.ToLower(model)
-	switch {
-	case strings.Contains(model, "roku"):
-		return "Roku"
-	case strings.Contains(model, "fire"):
-		return "Amazon"
-	case strings.Contains(model, "apple"):
-		return "Apple"
-	case strings.Contains(model, "chromecast"):
-		return "Google"
-	case strings.Contains(model, "samsung"):
-		return "Samsung"
-	case strings.Contains(model, "lg"):
-		return "LG"
-	default:
-		return "Unknown"
-	}
-}
-
 func (h *VASTHandler) getIFA(req *VASTRequest) string {
 	if req.IDFA != "" {
 		return req.IDFA
@@ -562,14 +705,39 @@ func (h *VASTHandler) getIFA(req *VASTRequest) string {
 	return req.UID
 }
 
+// ImpressionKey is what a served ad's impression is tracked, rewarded,
+// proven viewed and settled under: its bid within its serve, as serves of
+// a cached auction share their bids. Without a serve ID it's the bid ID
+// alone.
+func ImpressionKey(serveID, bidID string) string {
+	if serveID == "" {
+		return bidID
+	}
+	return serveID + ":" + bidID
+}
+
 func (h *VASTHandler) buildTrackingURL(event string, req *VASTRequest, bid *Bid) string {
 	base := "https://track.lux.network/v1/event"
+	// Every value comes from the request or a DSP, so each is escaped
+	q := url.QueryEscape
 	params := fmt.Sprintf("?event=%s&imp=%s&zone=%d&app=%s&bid=%s",
-		event, bid.ImpID, req.ZoneID, req.AppToken, bid.ID)
+		q(event), q(bid.ImpID), req.ZoneID, q(req.AppToken), q(bid.ID))
+	if bid.CID != "" {
+		params += "&cid=" + q(bid.CID)
+	}
+	if bid.CrID != "" {
+		params += "&crid=" + q(bid.CrID)
+	}
+	if req.ServeID != "" {
+		params += "&srv=" + q(req.ServeID)
+	}
+	if req.PodSequence > 0 {
+		params += fmt.Sprintf("&pos=%d", req.PodSequence)
+	}
 
 	// Add blockchain tracking if enabled
 	if req.OnChainTracking == 1 && req.WalletAddress != "" {
-		params += fmt.Sprintf("&wallet=%s&chain=%d", req.WalletAddress, req.ChainID)
+		params += fmt.Sprintf("&wallet=%s&chain=%d", q(req.WalletAddress), req.ChainID)
 	}
 
 	return base + params
@@ -647,55 +815,56 @@ func (h *VASTHandler) getMediaFilesForLayout(layout string, videoURL string) []M
 	return files
 }
 
-func (h *VASTHandler) getOMIDVerificationScript(partner string) string {
-	// Return OMID verification script URL based on partner
-	scripts := map[string]string{
-		"iabtechlab":   "https://cdn.lux.network/omid/omid-validation-verification-script-v1.js",
-		"moat":         "https://cdn.lux.network/omid/moat-omid-verification.js",
-		"doubleverify": "https://cdn.lux.network/omid/dv-omid-verification.js",
-		"ias":          "https://cdn.lux.network/omid/ias-omid-verification.js",
+// trackImpression records an impression for each ad served, under its
+// ImpressionKey and with its bid's price, campaign and creative
+func (h *VASTHandler) trackImpression(req *VASTRequest, rtbResp *OpenRTBResponse, vast *VAST) {
+	serveID := req.ServeID
+	if serveID == "" {
+		serveID = uuid.New().String()
 	}
-
-	if url, ok := scripts[strings.ToLower(partner)]; ok {
-		return url
+	location := h.resolveGeo(req)
+	if req.Lat != "" && req.Long != "" {
+		location.Lat = req.Lat
+		location.Lon = req.Long
 	}
-
-	return "https://cdn.lux.network/omid/default-verification.js"
-}
-
-func (h *VASTHandler) trackImpression(req *VASTRequest, vast *VAST) {
-	// Track impression asynchronously
-	impression := &ImpressionRecord{
-		ID:        uuid.New().String(),
-		Timestamp: time.Now(),
-		AppToken:  req.AppToken,
-		ZoneID:    req.ZoneID,
-		Device: DeviceInfo{
-			OS:        req.OS,
-			OSVersion: req.OSVer,
-			Model:     req.DeviceModel,
-			IFA:       h.getIFA(req),
-		},
-		Location: LocationInfo{
-			Lat:     req.Lat,
-			Lon:     req.Long,
-			Country: "", // Would be derived from IP
-		},
-		AdCount: len(vast.Ads),
+	served := make(map[string]bool, len(vast.Ads))
+	for _, ad := range vast.Ads {
+		served[ad.ID] = true
 	}
 
-	// Store impression
-	if err := h.Storage.StoreImpression(impression); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to store impression: %v\n", err)
-	}
+	for _, seatBid := range rtbResp.SeatBid {
+		for _, bid := range seatBid.Bid {
+			if !served[bid.ID] {
+				continue
+			}
+			delete(served, bid.ID)
+			impression := &ImpressionRecord{
+				ID:        ImpressionKey(serveID, bid.ID),
+				Timestamp: time.Now(),
+				AppToken:  req.AppToken,
+				ZoneID:    req.ZoneID,
+				Device: DeviceInfo{
+					OS:        req.OS,
+					OSVersion: req.OSVer,
+					Model:     req.DeviceModel,
+					IFA:       h.getIFA(req),
+				},
+				Location:   location,
+				AdCount:    len(vast.Ads),
+				CampaignID: bid.CID,
+				CreativeID: bid.CrID,
+				Revenue:    bid.Price,
+			}
 
-	// Update analytics
-	h.Analytics.TrackImpression(impression)
+			// Store impression
+			if err := h.Storage.StoreImpression(impression); err != nil {
+				// Log error but don't fail the request
+				fmt.Printf("Failed to store impression: %v\n", err)
+			}
 
-	// Blockchain tracking if enabled
-	if req.OnChainTracking == 1 && req.WalletAddress != "" {
-		h.BlockchainMgr.RecordImpression(impression, req.WalletAddress, req.ChainID)
+			// Update analytics
+			h.Analytics.TrackImpression(impression)
+		}
 	}
 }
 
@@ -0,0 +1,108 @@
+package vast
+
+import (
+	"testing"
+	"time"
+)
+
+type staticFloors map[string]float64
+
+func (f staticFloors) FloorFor(placement, country string, deviceType int, at time.Time) float64 {
+	return f[placement+"/"+country]
+}
+
+func TestBuildOpenRTBRequest_Floors(t *testing.T) {
+	req := &VASTRequest{ZoneID: 7, AL: "l", AdCount: 2}
+
+	h := &VASTHandler{}
+	rtbReq := h.buildOpenRTBRequest(req)
+	if rtbReq.Imp[0].BidFloor != 0.01 {
+		t.Errorf("default floor = %v, want 0.01", rtbReq.Imp[0].BidFloor)
+	}
+
+	h = &VASTHandler{Floors: staticFloors{"7/": 3.5}}
+	rtbReq = h.buildOpenRTBRequest(req)
+	for _, imp := range rtbReq.Imp {
+		if imp.BidFloor != 3.5 || imp.TagID != "7" {
+			t.Errorf("imp %s floor = %v tagid = %q, want 3.5 and 7", imp.ID, imp.BidFloor, imp.TagID)
+		}
+	}
+}
+
+func TestBuildOpenRTBRequest_Placement(t *testing.T) {
+	h := &VASTHandler{}
+	imp := h.buildOpenRTBRequest(&VASTRequest{AL: "l", AdCount: 1, Instl: 1, DW: "1080", DH: "1920"}).Imp[0]
+	if imp.Instl != 1 || imp.Video.Plcmt != 3 || imp.Video.Placement != 5 || imp.Video.Pos != 7 {
+		t.Errorf("interstitial imp = %+v, video = %+v", imp, imp.Video)
+	}
+	if c := imp.Video.CompanionAd; len(c) != 1 || c[0].W != 1080 || c[0].H != 1920 || c[0].Pos != 7 {
+		t.Errorf("end card = %+v, want full screen", c)
+	}
+
+	imp = h.buildOpenRTBRequest(&VASTRequest{AL: "l", AdCount: 1, RV: "1"}).Imp[0]
+	if imp.Rwdd != 1 || imp.Instl != 0 || imp.Video.Plcmt != 3 || imp.Video.Placement != 0 {
+		t.Errorf("rewarded imp = %+v, video = %+v", imp, imp.Video)
+	}
+
+	imp = h.buildOpenRTBRequest(&VASTRequest{AL: "l", AdCount: 1}).Imp[0]
+	if imp.Rwdd != 0 || imp.Instl != 0 || imp.Video.Plcmt != 0 || imp.Video.CompanionAd != nil {
+		t.Errorf("standard imp = %+v, video = %+v", imp, imp.Video)
+	}
+}
+
+func TestBuildVASTResponse_DropsPanickingBids(t *testing.T) {
+	// A signer without a key panics on every receipt
+	h := &VASTHandler{Receipts: &ReceiptSigner{}}
+	req := &VASTRequest{ZoneID: 7, AL: "l"}
+	resp := &OpenRTBResponse{ID: "auc-1", SeatBid: []SeatBid{{Seat: "dsp1", Bid: []Bid{{ID: "b1", Price: 2}, {ID: "b2", Price: 1}}}}}
+
+	vast := h.buildVASTResponse(req, resp)
+	if len(vast.Ads) != 0 {
+		t.Errorf("built %d ads from panicking bids", len(vast.Ads))
+	}
+	if h.PanicCount != 2 {
+		t.Errorf("panics = %d, want 2", h.PanicCount)
+	}
+
+	h.Receipts = nil
+	if vast := h.buildVASTResponse(req, resp); len(vast.Ads) != 2 {
+		t.Errorf("built %d ads, want 2", len(vast.Ads))
+	}
+}
+
+type recordingAnalytics struct {
+	nopAnalytics
+	impressions []*ImpressionRecord
+}
+
+func (a *recordingAnalytics) TrackImpression(imp *ImpressionRecord) {
+	a.impressions = append(a.impressions, imp)
+}
+
+func TestTrackImpression_PerAd(t *testing.T) {
+	analytics := &recordingAnalytics{}
+	h := &VASTHandler{Storage: nopStorage{}, Analytics: analytics}
+	req := &VASTRequest{ZoneID: 7, AL: "l", AppToken: "pub-1", ServeID: "srv-1"}
+	resp := &OpenRTBResponse{ID: "auc-1", SeatBid: []SeatBid{{Seat: "dsp1", Bid: []Bid{
+		{ID: "b1", Price: 4.5, CID: "camp-1", CrID: "cre-1"},
+		{ID: "b2", Price: 2, CID: "camp-2", CrID: "cre-2"},
+		{ID: "b3", Price: 9, CID: "camp-3", CrID: "cre-3"},
+	}}}}
+	// b3's ad wasn't built
+	vast := &VAST{Ads: []Ad{{ID: "b1"}, {ID: "b2"}}}
+
+	h.trackImpression(req, resp, vast)
+	if len(analytics.impressions) != 2 {
+		t.Fatalf("tracked %d impressions, want one per served ad", len(analytics.impressions))
+	}
+	for i, want := range []ImpressionRecord{
+		{ID: "srv-1:b1", CampaignID: "camp-1", CreativeID: "cre-1", Revenue: 4.5},
+		{ID: "srv-1:b2", CampaignID: "camp-2", CreativeID: "cre-2", Revenue: 2},
+	} {
+		got := analytics.impressions[i]
+		if got.ID != want.ID || got.CampaignID != want.CampaignID || got.CreativeID != want.CreativeID ||
+			got.Revenue != want.Revenue || got.AppToken != "pub-1" || got.AdCount != 2 {
+			t.Errorf("impression %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package vast
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vastMaxAge is the max-age VAST responses are served with; cached auction
+// results never outlive it
+const vastMaxAge = 300 * time.Second
+
+// DefaultResponseCacheTTL keeps auction results just long enough to absorb
+// bursts of identical requests
+const DefaultResponseCacheTTL = 10 * time.Second
+
+// ResponseCache holds recent auction results by request signature so
+// identical VAST requests don't each re-run the auction. Only the auction
+// result is cached; the VAST document, with its tracking URLs, is built
+// fresh for every serve.
+type ResponseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]cachedAuction
+	now        func() time.Time
+}
+
+type cachedAuction struct {
+	resp    *OpenRTBResponse
+	expires time.Time
+}
+
+// NewResponseCache creates a cache holding up to maxEntries results for
+// ttl, which is capped at the response max-age
+func NewResponseCache(ttl time.Duration, maxEntries int) *ResponseCache {
+	if ttl <= 0 {
+		ttl = DefaultResponseCacheTTL
+	}
+	if ttl > vastMaxAge {
+		ttl = vastMaxAge
+	}
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &ResponseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cachedAuction),
+		now:        time.Now,
+	}
+}
+
+// Get returns the cached auction result for key
+func (c *ResponseCache) Get(key string) (*OpenRTBResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.resp, true
+}
+
+// Put caches an auction result for key, evicting expired entries, then
+// arbitrary ones, when full
+func (c *ResponseCache) Put(key string, resp *OpenRTBResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedAuction{resp: resp, expires: now.Add(c.ttl)}
+}
+
+// Len returns the number of cached results, including expired ones not
+// yet evicted
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// cacheable reports whether the auction for req may be shared with other
+// requests. Anything personalized, identifying or consent-gated isn't.
+func cacheable(req *VASTRequest, header http.Header) bool {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		switch strings.TrimSpace(strings.ToLower(directive)) {
+		case "no-cache", "no-store":
+			return false
+		}
+	}
+	if req.GDPR == 1 || req.UserConsent != "" {
+		return false
+	}
+	if req.IDFA != "" || req.GID != "" || req.IFV != "" || req.UID != "" ||
+		req.IDFAMD5 != "" || req.IDFASHA1 != "" || req.GIDMD5 != "" || req.GIDSHA1 != "" {
+		return false
+	}
+	if req.Lat != "" || req.Long != "" || req.Gender != "" || req.Age > 0 {
+		return false
+	}
+	// Rewards, wallets and proofs of view are bound to one viewer
+	if req.RV == "1" || req.WalletAddress != "" || req.DecentralizedID != "" || req.ProofOfView != "" {
+		return false
+	}
+	return true
+}
+
+// cacheKey is the signature of the fields an auction for req depends on.
+// Device identity, network addresses and cache-busters are left out; geo
+// and device type come from rtbReq, where they've been resolved.
+func cacheKey(req *VASTRequest, rtbReq *OpenRTBRequest) string {
+	sig := struct {
+		App, Bundle, Domain    string
+		Zone, AdCount          int
+		Layout, OS, Locale     string
+		Country, Region        string
+		DeviceType, Connection int
+		Video                  *Video
+		Keywords               string
+		Content                Content
+		COPPA                  int
+		USPrivacy              string
+		OMID                   []string
+		SKAdN                  *SKAdNetwork
+		Floor                  float64
+	}{
+		App:        req.AppToken,
+		Bundle:     req.BundleID,
+		Domain:     req.PubDomain,
+		Zone:       req.ZoneID,
+		AdCount:    len(rtbReq.Imp),
+		Layout:     req.AL,
+		OS:         req.OS,
+		Locale:     req.Locale,
+		Country:    rtbReq.Device.Geo.Country,
+		Region:     rtbReq.Device.Geo.Region,
+		DeviceType: rtbReq.Device.DeviceType,
+		Connection: req.ConnectionType,
+		Keywords:   req.Keywords,
+		Content:    rtbReq.App.Content,
+		COPPA:      req.COPPA,
+		USPrivacy:  req.USPrivacy,
+		OMID:       append([]string{req.OMIDPN, req.OMIDPV}, req.OMIDVendors...),
+		SKAdN:      rtbReq.Source.SKAdN,
+	}
+	if len(rtbReq.Imp) > 0 {
+		sig.Video = rtbReq.Imp[0].Video
+		sig.Floor = rtbReq.Imp[0].BidFloor
+	}
+	b, _ := json.Marshal(sig)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
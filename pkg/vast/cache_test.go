@@ -0,0 +1,162 @@
+package vast
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countingExchange answers every auction with one bid and counts them,
+// and the cached serves billed
+type countingExchange struct {
+	auctions atomic.Int32
+	billed   atomic.Int32
+}
+
+func (e *countingExchange) BillServe(ctx context.Context, req *OpenRTBRequest, resp *OpenRTBResponse) error {
+	e.billed.Add(1)
+	return nil
+}
+
+func (e *countingExchange) RunAuction(ctx context.Context, req *OpenRTBRequest) (*OpenRTBResponse, error) {
+	e.auctions.Add(1)
+	return &OpenRTBResponse{
+		ID: req.ID,
+		SeatBid: []SeatBid{{
+			Seat: "dsp1",
+			Bid:  []Bid{{ID: "b1", ImpID: "1", Price: 2.5, ADomain: []string{"example.com"}, ADURL: "https://cdn.example/ad.mp4"}},
+		}},
+	}, nil
+}
+
+type nopStorage struct{}
+
+func (nopStorage) StoreImpression(imp *ImpressionRecord) error        { return nil }
+func (nopStorage) GetImpression(id string) (*ImpressionRecord, error) { return nil, nil }
+
+type nopAnalytics struct{}
+
+func (nopAnalytics) TrackImpression(imp *ImpressionRecord)   {}
+func (nopAnalytics) TrackClick(clickID string, impID string) {}
+func (nopAnalytics) GetMetrics(startTime, endTime time.Time) map[string]interface{} {
+	return nil
+}
+
+func TestHandleVASTRequest_Cache(t *testing.T) {
+	exchange := &countingExchange{}
+	h := &VASTHandler{Exchange: exchange, Storage: nopStorage{}, Analytics: nopAnalytics{}, Cache: NewResponseCache(time.Minute, 100), VendorID: 755}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/vast", h.HandleVASTRequest)
+	serve := func(query string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/vast?apptoken=pub-1&os=ios&osver=17&devicemodel=iPhone&dnt=1&al=l"+query, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d", w.Code)
+		}
+		return w
+	}
+	impression := regexp.MustCompile(`<Impression id="main"><!\[CDATA\[([^\]]+)\]\]>|<Impression id="main">([^<]+)<`)
+	trackingURL := func(w *httptest.ResponseRecorder) string {
+		m := impression.FindStringSubmatch(w.Body.String())
+		if m == nil {
+			t.Fatalf("no impression URL in %s", w.Body.String())
+		}
+		return m[1] + m[2]
+	}
+
+	first := serve("&zoneid=7&cb=1", nil)
+	// Cache-busters don't change the signature
+	second := serve("&zoneid=7&cb=2", nil)
+	if n := exchange.auctions.Load(); n != 1 {
+		t.Errorf("identical requests ran %d auctions, want 1", n)
+	}
+	if n := exchange.billed.Load(); n != 1 {
+		t.Errorf("cached serve billed %d times, want 1", n)
+	}
+	if got := second.Header().Get("Cache-Control"); got != "private, max-age=300" {
+		t.Errorf("cached serve Cache-Control = %q", got)
+	}
+	if trackingURL(first) == trackingURL(second) {
+		t.Errorf("cached serve reused tracking URL %s", trackingURL(first))
+	}
+
+	// A different zone misses
+	serve("&zoneid=8", nil)
+	if n := exchange.auctions.Load(); n != 2 {
+		t.Errorf("differing request ran %d auctions in total, want 2", n)
+	}
+
+	// Personalized and consent-gated requests always run their own auction
+	serve("&zoneid=7&idfa=AEBE52E7-03EE-455A-B3C4-E57283966239", nil)
+	serve("&zoneid=7&idfa=AEBE52E7-03EE-455A-B3C4-E57283966239", nil)
+	serve("&zoneid=7&gdpr=1&userconsent=CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", nil)
+	serve("&zoneid=7&gdpr=1&userconsent=CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", nil)
+	if n := exchange.auctions.Load(); n != 6 {
+		t.Errorf("personalized requests ran %d auctions in total, want 6", n)
+	}
+
+	// So do requests that ask not to be served from cache
+	serve("&zoneid=7", http.Header{"Cache-Control": {"no-cache"}})
+	if n := exchange.auctions.Load(); n != 7 {
+		t.Errorf("no-cache request ran %d auctions in total, want 7", n)
+	}
+}
+
+// unbilledExchange can't bill a serve it didn't run the auction for
+type unbilledExchange struct{ e *countingExchange }
+
+func (u unbilledExchange) RunAuction(ctx context.Context, req *OpenRTBRequest) (*OpenRTBResponse, error) {
+	return u.e.RunAuction(ctx, req)
+}
+
+func TestHandleVASTRequest_CacheNeedsBilling(t *testing.T) {
+	exchange := &countingExchange{}
+	h := &VASTHandler{Exchange: unbilledExchange{exchange}, Storage: nopStorage{}, Analytics: nopAnalytics{}, Cache: NewResponseCache(time.Minute, 100)}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/vast", h.HandleVASTRequest)
+	for range 2 {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/vast?apptoken=pub-1&os=ios&osver=17&devicemodel=iPhone&dnt=1&al=l&zoneid=7", nil))
+	}
+	if n := exchange.auctions.Load(); n != 2 {
+		t.Errorf("ran %d auctions, want every serve to run its own", n)
+	}
+}
+
+func TestResponseCache_Expiry(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := NewResponseCache(time.Hour, 2)
+	c.now = func() time.Time { return now }
+	if c.ttl != vastMaxAge {
+		t.Errorf("ttl = %s, want it capped at %s", c.ttl, vastMaxAge)
+	}
+
+	c.Put("a", &OpenRTBResponse{ID: "a"})
+	now = now.Add(vastMaxAge)
+	if _, ok := c.Get("a"); ok {
+		t.Error("entry served past its TTL")
+	}
+
+	c.Put("a", &OpenRTBResponse{ID: "a"})
+	c.Put("b", &OpenRTBResponse{ID: "b"})
+	c.Put("c", &OpenRTBResponse{ID: "c"})
+	if n := c.Len(); n != 2 {
+		t.Errorf("len = %d, want capped at 2", n)
+	}
+	if resp, ok := c.Get("c"); !ok || resp.ID != "c" {
+		t.Errorf("latest entry = %v, %v", resp, ok)
+	}
+}
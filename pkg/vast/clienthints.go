@@ -0,0 +1,216 @@
+package vast
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// OpenRTB connection types (AdCOM List: Connection Types)
+const (
+	ConnectionUnknown  = 0
+	ConnectionEthernet = 1
+	ConnectionWiFi     = 2
+	ConnectionCellular = 3
+	Connection2G       = 4
+	Connection3G       = 5
+	Connection4G       = 6
+	Connection5G       = 7
+)
+
+// OpenRTB 2.6 structured user agent sources
+const (
+	SUASourceLowEntropy  = 1
+	SUASourceHighEntropy = 2
+	SUASourceUAString    = 3
+)
+
+// ClientHints are the User-Agent Client Hints and network hints a client
+// sent with the request, along with its User-Agent header
+type ClientHints struct {
+	UA              string
+	Brands          []BrandVersion
+	Mobile          *bool
+	Platform        string
+	PlatformVersion string
+	Model           string
+	ECT             string // Effective connection type: slow-2g, 2g, 3g or 4g
+}
+
+// BrandVersion is a browser or platform and its version components
+type BrandVersion struct {
+	Brand   string   `json:"brand"`
+	Version []string `json:"version,omitempty"`
+}
+
+// StructuredUserAgent is the OpenRTB 2.6 device.sua object
+type StructuredUserAgent struct {
+	Browsers []BrandVersion `json:"browsers,omitempty"`
+	Platform *BrandVersion  `json:"platform,omitempty"`
+	Mobile   *int           `json:"mobile,omitempty"`
+	Model    string         `json:"model,omitempty"`
+	Source   int            `json:"source"`
+}
+
+var brandRE = regexp.MustCompile(`"([^"]*)"\s*;\s*v\s*=\s*"([^"]*)"`)
+
+// ParseClientHints reads Sec-CH-UA* and ECT request headers
+func ParseClientHints(h http.Header) ClientHints {
+	hints := ClientHints{
+		UA:              h.Get("User-Agent"),
+		Platform:        sfString(h.Get("Sec-CH-UA-Platform")),
+		PlatformVersion: sfString(h.Get("Sec-CH-UA-Platform-Version")),
+		Model:           sfString(h.Get("Sec-CH-UA-Model")),
+		ECT:             strings.ToLower(strings.TrimSpace(h.Get("ECT"))),
+	}
+	list := h.Get("Sec-CH-UA-Full-Version-List")
+	if list == "" {
+		list = h.Get("Sec-CH-UA")
+	}
+	for _, m := range brandRE.FindAllStringSubmatch(list, -1) {
+		// GREASE brands ("Not-A.Brand" and friends) carry no information
+		if strings.Contains(strings.ToLower(m[1]), "not") && strings.Contains(strings.ToLower(m[1]), "brand") {
+			continue
+		}
+		hints.Brands = append(hints.Brands, BrandVersion{Brand: m[1], Version: strings.Split(m[2], ".")})
+	}
+	switch strings.TrimSpace(h.Get("Sec-CH-UA-Mobile")) {
+	case "?1":
+		mobile := true
+		hints.Mobile = &mobile
+	case "?0":
+		mobile := false
+		hints.Mobile = &mobile
+	}
+	return hints
+}
+
+// sfString unquotes a structured-field string header value
+func sfString(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = v[1 : len(v)-1]
+	}
+	return v
+}
+
+// normalizeOS maps a Client Hints platform to the OS names used on VAST
+// requests
+func normalizeOS(platform string) string {
+	switch p := strings.ToLower(platform); p {
+	case "ios", "ipados":
+		return "ios"
+	case "":
+		return ""
+	default:
+		return p
+	}
+}
+
+var (
+	uaIOSRE     = regexp.MustCompile(`(?i)(?:iphone|cpu) os (\d+(?:_\d+)*)`)
+	uaAndroidRE = regexp.MustCompile(`(?i)android (\d+(?:\.\d+)*)`)
+	uaModelRE   = regexp.MustCompile(`(?i)android [\d.]+; ([^;)]+?)(?: build/[^;)]*)?\)`)
+)
+
+// parseUAPlatform extracts the OS, OS version and, for Android, the model
+// from a User-Agent string
+func parseUAPlatform(ua string) (os, osv, model string) {
+	if m := uaIOSRE.FindStringSubmatch(ua); m != nil {
+		return "ios", strings.ReplaceAll(m[1], "_", "."), ""
+	}
+	if m := uaAndroidRE.FindStringSubmatch(ua); m != nil {
+		// Reduced user agents replace the model with "K"
+		if mm := uaModelRE.FindStringSubmatch(ua); mm != nil && !strings.EqualFold(mm[1], "k") {
+			model = strings.TrimSpace(mm[1])
+		}
+		return "android", m[1], model
+	}
+	return "", "", ""
+}
+
+// connectionType maps the effective connection type hint to an OpenRTB
+// connection type. ECT is a speed estimate rather than the link, so "4g"
+// is only taken as cellular on mobile devices.
+func connectionType(ect string, mobile bool) int {
+	switch ect {
+	case "slow-2g", "2g":
+		return Connection2G
+	case "3g":
+		return Connection3G
+	case "4g":
+		if mobile {
+			return Connection4G
+		}
+	}
+	return ConnectionUnknown
+}
+
+// enrichDevice fills device fields the request didn't send from Client
+// Hints, then from the User-Agent string. Explicit params always win.
+func enrichDevice(req *VASTRequest, d *Device) {
+	hints := req.Hints
+	if d.UA == "" {
+		d.UA = hints.UA
+	}
+	uaOS, uaOSV, uaModel := parseUAPlatform(d.UA)
+
+	if d.OS == "" {
+		d.OS = firstNonEmpty(normalizeOS(hints.Platform), uaOS)
+	}
+	if d.OSV == "" {
+		d.OSV = firstNonEmpty(hints.PlatformVersion, uaOSV)
+	}
+	if d.Model == "" {
+		d.Model = firstNonEmpty(hints.Model, uaModel)
+	}
+	// Re-classify devices the explicit params couldn't identify
+	if d.DeviceType == 0 || d.DeviceType == DeviceTypeConnectedDevice {
+		d.Make, d.DeviceType = lookupDevice(d.Model, d.UA)
+	}
+	mobile := hints.Mobile != nil && *hints.Mobile
+	if mobile && d.DeviceType == DeviceTypeConnectedDevice {
+		d.DeviceType = DeviceTypePhone
+	}
+
+	if d.ConnectionType == ConnectionUnknown {
+		d.ConnectionType = connectionType(hints.ECT, mobile || d.DeviceType == DeviceTypePhone || d.DeviceType == DeviceTypeTablet)
+	}
+
+	d.SUA = structuredUA(hints)
+}
+
+// structuredUA builds device.sua from Client Hints, if any were sent
+func structuredUA(hints ClientHints) *StructuredUserAgent {
+	if len(hints.Brands) == 0 && hints.Platform == "" && hints.Mobile == nil {
+		return nil
+	}
+	sua := &StructuredUserAgent{
+		Browsers: hints.Brands,
+		Model:    hints.Model,
+		Source:   SUASourceLowEntropy,
+	}
+	if hints.Platform != "" {
+		sua.Platform = &BrandVersion{Brand: hints.Platform}
+		if hints.PlatformVersion != "" {
+			sua.Platform.Version = strings.Split(hints.PlatformVersion, ".")
+		}
+	}
+	if hints.Mobile != nil {
+		mobile := boolToInt(*hints.Mobile)
+		sua.Mobile = &mobile
+	}
+	if hints.Model != "" || hints.PlatformVersion != "" {
+		sua.Source = SUASourceHighEntropy
+	}
+	return sua
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
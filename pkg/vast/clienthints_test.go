@@ -0,0 +1,90 @@
+package vast
+
+import (
+	"net/http"
+	"testing"
+)
+
+func chromeAndroidHints() http.Header {
+	h := http.Header{}
+	h.Set("User-Agent", "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36")
+	h.Set("Sec-CH-UA", `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`)
+	h.Set("Sec-CH-UA-Mobile", "?1")
+	h.Set("Sec-CH-UA-Platform", `"Android"`)
+	h.Set("Sec-CH-UA-Platform-Version", `"14.0.0"`)
+	h.Set("Sec-CH-UA-Model", `"Pixel 7"`)
+	h.Set("ECT", "3g")
+	return h
+}
+
+func TestParseClientHints(t *testing.T) {
+	hints := ParseClientHints(chromeAndroidHints())
+	if hints.Platform != "Android" || hints.PlatformVersion != "14.0.0" || hints.Model != "Pixel 7" || hints.ECT != "3g" {
+		t.Errorf("hints = %+v", hints)
+	}
+	if hints.Mobile == nil || !*hints.Mobile {
+		t.Error("mobile hint not parsed")
+	}
+	if len(hints.Brands) != 2 || hints.Brands[1].Brand != "Google Chrome" || hints.Brands[1].Version[0] != "124" {
+		t.Errorf("brands = %+v, want Chromium and Google Chrome without GREASE", hints.Brands)
+	}
+}
+
+func TestBuildOpenRTBRequest_ClientHints(t *testing.T) {
+	h := &VASTHandler{}
+
+	// Only hints: the device is filled in from them
+	req := &VASTRequest{AppToken: "app", Hints: ParseClientHints(chromeAndroidHints())}
+	d := h.buildOpenRTBRequest(req).Device
+	if d.OS != "android" || d.OSV != "14.0.0" || d.Model != "Pixel 7" {
+		t.Errorf("os = %q %q model = %q", d.OS, d.OSV, d.Model)
+	}
+	if d.Make != "Google" || d.DeviceType != DeviceTypePhone {
+		t.Errorf("make = %q type = %d", d.Make, d.DeviceType)
+	}
+	if d.ConnectionType != Connection3G {
+		t.Errorf("connection type = %d, want 3G", d.ConnectionType)
+	}
+	if d.UA == "" || d.SUA == nil || d.SUA.Source != SUASourceHighEntropy || d.SUA.Platform.Brand != "Android" || *d.SUA.Mobile != 1 {
+		t.Errorf("ua = %q sua = %+v", d.UA, d.SUA)
+	}
+
+	// Explicit params win over hints
+	req = &VASTRequest{
+		AppToken:       "app",
+		OS:             "ios",
+		OSVer:          "17.4",
+		DeviceModel:    "iPhone15,2",
+		UA:             "AdPlayer/2.0",
+		ConnectionType: ConnectionWiFi,
+		Carrier:        "T-Mobile",
+		Hints:          ParseClientHints(chromeAndroidHints()),
+	}
+	d = h.buildOpenRTBRequest(req).Device
+	if d.OS != "ios" || d.OSV != "17.4" || d.Model != "iPhone15,2" || d.UA != "AdPlayer/2.0" {
+		t.Errorf("explicit device overridden: %q %q %q %q", d.OS, d.OSV, d.Model, d.UA)
+	}
+	if d.Make != "Apple" || d.ConnectionType != ConnectionWiFi || d.Carrier != "T-Mobile" {
+		t.Errorf("make = %q connection = %d carrier = %q", d.Make, d.ConnectionType, d.Carrier)
+	}
+}
+
+func TestBuildOpenRTBRequest_UAFallback(t *testing.T) {
+	h := &VASTHandler{}
+	for _, tt := range []struct {
+		ua, os, osv, model, make string
+		deviceType               int
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", "ios", "17.4.1", "", "Apple", DeviceTypePhone},
+		{"Mozilla/5.0 (Linux; Android 13; SM-S911B Build/TP1A.220624.014) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36", "android", "13", "SM-S911B", "Samsung", DeviceTypePhone},
+	} {
+		req := &VASTRequest{AppToken: "app", Hints: ClientHints{UA: tt.ua}}
+		d := h.buildOpenRTBRequest(req).Device
+		if d.OS != tt.os || d.OSV != tt.osv || d.Model != tt.model || d.Make != tt.make || d.DeviceType != tt.deviceType {
+			t.Errorf("%s: device = %q %q %q %q %d", tt.ua, d.OS, d.OSV, d.Model, d.Make, d.DeviceType)
+		}
+		if d.SUA != nil {
+			t.Errorf("%s: sua set without client hints", tt.ua)
+		}
+	}
+}
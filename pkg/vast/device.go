@@ -0,0 +1,134 @@
+package vast
+
+import "strings"
+
+// OpenRTB 2.x device types (AdCOM List: Device Types)
+const (
+	DeviceTypeMobileTablet    = 1
+	DeviceTypePC              = 2
+	DeviceTypeCTV             = 3
+	DeviceTypePhone           = 4
+	DeviceTypeTablet          = 5
+	DeviceTypeConnectedDevice = 6
+	DeviceTypeSetTopBox       = 7
+)
+
+// deviceRule classifies a device when every token appears in the
+// lowercased model or user agent
+type deviceRule struct {
+	tokens     []string
+	make       string
+	deviceType int
+}
+
+// deviceRules is evaluated in order, so more specific platforms must come
+// before generic ones (Fire TV and Android TV before plain Android, iPad
+// before iPhone, etc.)
+var deviceRules = []deviceRule{
+	// Connected TV platforms
+	{tokens: []string{"roku"}, make: "Roku", deviceType: DeviceTypeCTV},
+	{tokens: []string{"aftb"}, make: "Amazon", deviceType: DeviceTypeCTV},
+	{tokens: []string{"aftm"}, make: "Amazon", deviceType: DeviceTypeCTV},
+	{tokens: []string{"afts"}, make: "Amazon", deviceType: DeviceTypeCTV},
+	{tokens: []string{"aftt"}, make: "Amazon", deviceType: DeviceTypeCTV},
+	{tokens: []string{"aftk"}, make: "Amazon", deviceType: DeviceTypeCTV},
+	{tokens: []string{"firetv"}, make: "Amazon", deviceType: DeviceTypeCTV},
+	{tokens: []string{"fire tv"}, make: "Amazon", deviceType: DeviceTypeCTV},
+	{tokens: []string{"appletv"}, make: "Apple", deviceType: DeviceTypeCTV},
+	{tokens: []string{"apple tv"}, make: "Apple", deviceType: DeviceTypeCTV},
+	{tokens: []string{"tvos"}, make: "Apple", deviceType: DeviceTypeCTV},
+	{tokens: []string{"crkey"}, make: "Google", deviceType: DeviceTypeCTV},
+	{tokens: []string{"chromecast"}, make: "Google", deviceType: DeviceTypeCTV},
+	{tokens: []string{"tizen"}, make: "Samsung", deviceType: DeviceTypeCTV},
+	{tokens: []string{"samsung", "smart-tv"}, make: "Samsung", deviceType: DeviceTypeCTV},
+	{tokens: []string{"samsung", "smarttv"}, make: "Samsung", deviceType: DeviceTypeCTV},
+	{tokens: []string{"web0s"}, make: "LG", deviceType: DeviceTypeCTV},
+	{tokens: []string{"webos"}, make: "LG", deviceType: DeviceTypeCTV},
+	{tokens: []string{"netcast"}, make: "LG", deviceType: DeviceTypeCTV},
+	{tokens: []string{"vizio"}, make: "Vizio", deviceType: DeviceTypeCTV},
+	{tokens: []string{"smartcast"}, make: "Vizio", deviceType: DeviceTypeCTV},
+	{tokens: []string{"hisense"}, make: "Hisense", deviceType: DeviceTypeCTV},
+	{tokens: []string{"vidaa"}, make: "Hisense", deviceType: DeviceTypeCTV},
+	{tokens: []string{"bravia"}, make: "Sony", deviceType: DeviceTypeCTV},
+	{tokens: []string{"philipstv"}, make: "Philips", deviceType: DeviceTypeCTV},
+	{tokens: []string{"shield android tv"}, make: "NVIDIA", deviceType: DeviceTypeCTV},
+	{tokens: []string{"android tv"}, make: "Google", deviceType: DeviceTypeCTV},
+	{tokens: []string{"googletv"}, make: "Google", deviceType: DeviceTypeCTV},
+	{tokens: []string{"smarttv"}, make: "Unknown", deviceType: DeviceTypeCTV},
+	{tokens: []string{"smart-tv"}, make: "Unknown", deviceType: DeviceTypeCTV},
+	{tokens: []string{"smart tv"}, make: "Unknown", deviceType: DeviceTypeCTV},
+	{tokens: []string{"hbbtv"}, make: "Unknown", deviceType: DeviceTypeCTV},
+
+	// Game consoles render on the living room TV and are bought as CTV
+	{tokens: []string{"xbox"}, make: "Microsoft", deviceType: DeviceTypeCTV},
+	{tokens: []string{"playstation"}, make: "Sony", deviceType: DeviceTypeCTV},
+	{tokens: []string{"nintendo"}, make: "Nintendo", deviceType: DeviceTypeCTV},
+
+	// Operator set-top boxes
+	{tokens: []string{"tivo"}, make: "TiVo", deviceType: DeviceTypeSetTopBox},
+	{tokens: []string{"directv"}, make: "DIRECTV", deviceType: DeviceTypeSetTopBox},
+	{tokens: []string{"xfinity"}, make: "Comcast", deviceType: DeviceTypeSetTopBox},
+	{tokens: []string{"comcast"}, make: "Comcast", deviceType: DeviceTypeSetTopBox},
+	{tokens: []string{"freebox"}, make: "Free", deviceType: DeviceTypeSetTopBox},
+	{tokens: []string{"humax"}, make: "Humax", deviceType: DeviceTypeSetTopBox},
+	{tokens: []string{"stb"}, make: "Unknown", deviceType: DeviceTypeSetTopBox},
+
+	// Apple mobile
+	{tokens: []string{"ipad"}, make: "Apple", deviceType: DeviceTypeTablet},
+	{tokens: []string{"iphone"}, make: "Apple", deviceType: DeviceTypePhone},
+	{tokens: []string{"ipod"}, make: "Apple", deviceType: DeviceTypePhone},
+
+	// Android makes, tablets before phones
+	{tokens: []string{"sm-t"}, make: "Samsung", deviceType: DeviceTypeTablet},
+	{tokens: []string{"sm-x"}, make: "Samsung", deviceType: DeviceTypeTablet},
+	{tokens: []string{"sm-"}, make: "Samsung", deviceType: DeviceTypePhone},
+	{tokens: []string{"galaxy"}, make: "Samsung", deviceType: DeviceTypePhone},
+	{tokens: []string{"pixel tablet"}, make: "Google", deviceType: DeviceTypeTablet},
+	{tokens: []string{"pixel"}, make: "Google", deviceType: DeviceTypePhone},
+	{tokens: []string{"kindle"}, make: "Amazon", deviceType: DeviceTypeTablet},
+	{tokens: []string{"silk"}, make: "Amazon", deviceType: DeviceTypeTablet},
+	{tokens: []string{"lm-"}, make: "LG", deviceType: DeviceTypePhone},
+	{tokens: []string{"moto"}, make: "Motorola", deviceType: DeviceTypePhone},
+	{tokens: []string{"oneplus"}, make: "OnePlus", deviceType: DeviceTypePhone},
+	{tokens: []string{"xiaomi"}, make: "Xiaomi", deviceType: DeviceTypePhone},
+	{tokens: []string{"redmi"}, make: "Xiaomi", deviceType: DeviceTypePhone},
+	{tokens: []string{"huawei"}, make: "Huawei", deviceType: DeviceTypePhone},
+	{tokens: []string{"android", "mobile"}, make: "Unknown", deviceType: DeviceTypePhone},
+	{tokens: []string{"android"}, make: "Unknown", deviceType: DeviceTypeTablet},
+
+	// Desktop browsers
+	{tokens: []string{"windows nt"}, make: "Unknown", deviceType: DeviceTypePC},
+	{tokens: []string{"macintosh"}, make: "Apple", deviceType: DeviceTypePC},
+	{tokens: []string{"x11; linux"}, make: "Unknown", deviceType: DeviceTypePC},
+}
+
+// lookupDevice returns the make and OpenRTB device type for a device. The
+// model is tried first since publishers send it explicitly, then the user
+// agent. Devices neither identifies fall back to a generic connected device.
+func lookupDevice(model, ua string) (string, int) {
+	for _, s := range []string{model, ua} {
+		if s == "" {
+			continue
+		}
+		if rule, ok := matchDeviceRule(strings.ToLower(s)); ok {
+			return rule.make, rule.deviceType
+		}
+	}
+	return "Unknown", DeviceTypeConnectedDevice
+}
+
+func matchDeviceRule(s string) (deviceRule, bool) {
+	for _, rule := range deviceRules {
+		matched := true
+		for _, token := range rule.tokens {
+			if !strings.Contains(s, token) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule, true
+		}
+	}
+	return deviceRule{}, false
+}
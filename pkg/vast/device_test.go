@@ -0,0 +1,112 @@
+package vast
+
+import "testing"
+
+func TestLookupDevice(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		ua       string
+		wantMake string
+		wantType int
+	}{
+		{
+			name:     "roku",
+			ua:       "Roku/DVP-12.0 (12.0.0.4182-88)",
+			wantMake: "Roku",
+			wantType: DeviceTypeCTV,
+		},
+		{
+			name:     "fire tv stick",
+			ua:       "Mozilla/5.0 (Linux; Android 9; AFTMM Build/PS7233; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/91.0.4472.120 Mobile Safari/537.36",
+			wantMake: "Amazon",
+			wantType: DeviceTypeCTV,
+		},
+		{
+			name:     "apple tv",
+			ua:       "AppleCoreMedia/1.0.0.20K71 (Apple TV; U; CPU OS 16_1 like Mac OS X; en_us)",
+			wantMake: "Apple",
+			wantType: DeviceTypeCTV,
+		},
+		{
+			name:     "samsung tizen",
+			ua:       "Mozilla/5.0 (SMART-TV; LINUX; Tizen 6.0) AppleWebKit/537.36 (KHTML, like Gecko) 76.0.3809.146/6.0 TV Safari/537.36",
+			wantMake: "Samsung",
+			wantType: DeviceTypeCTV,
+		},
+		{
+			name:     "lg webos",
+			ua:       "Mozilla/5.0 (Web0S; Linux/SmartTV) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/79.0.3945.79 Safari/537.36 WebAppManager",
+			wantMake: "LG",
+			wantType: DeviceTypeCTV,
+		},
+		{
+			name:     "iphone",
+			ua:       "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			wantMake: "Apple",
+			wantType: DeviceTypePhone,
+		},
+		{
+			name:     "ipad",
+			ua:       "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			wantMake: "Apple",
+			wantType: DeviceTypeTablet,
+		},
+		{
+			name:     "samsung galaxy phone",
+			ua:       "Mozilla/5.0 (Linux; Android 13; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Mobile Safari/537.36",
+			wantMake: "Samsung",
+			wantType: DeviceTypePhone,
+		},
+		{
+			name:     "generic android phone",
+			ua:       "Mozilla/5.0 (Linux; Android 12; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Mobile Safari/537.36",
+			wantMake: "Unknown",
+			wantType: DeviceTypePhone,
+		},
+		{
+			name:     "generic android tablet",
+			ua:       "Mozilla/5.0 (Linux; Android 12; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36",
+			wantMake: "Unknown",
+			wantType: DeviceTypeTablet,
+		},
+		{
+			name:     "xbox",
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64; Xbox; Xbox One) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19041",
+			wantMake: "Microsoft",
+			wantType: DeviceTypeCTV,
+		},
+		{
+			name:     "model wins over user agent",
+			model:    "Roku Ultra",
+			ua:       "Mozilla/5.0 (Linux; Android 12; K) Mobile",
+			wantMake: "Roku",
+			wantType: DeviceTypeCTV,
+		},
+		{
+			name:     "falls through to user agent",
+			model:    "XYZ-1000",
+			ua:       "Mozilla/5.0 (CrKey armv7l 1.5.16041) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/31.0.1650.0 Safari/537.36",
+			wantMake: "Google",
+			wantType: DeviceTypeCTV,
+		},
+		{
+			name:     "unknown",
+			model:    "XYZ-1000",
+			wantMake: "Unknown",
+			wantType: DeviceTypeConnectedDevice,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMake, gotType := lookupDevice(tt.model, tt.ua)
+			if gotMake != tt.wantMake {
+				t.Errorf("make = %q, want %q", gotMake, tt.wantMake)
+			}
+			if gotType != tt.wantType {
+				t.Errorf("device type = %d, want %d", gotType, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package vast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrUnknownStage   = errors.New("unknown enrichment stage")
+	ErrDuplicateStage = errors.New("duplicate enrichment stage")
+)
+
+// Enricher is a stage of bid request enrichment: GeoIP, device detection,
+// identity resolution, segment lookup, fraud screening and the like. It
+// annotates the OpenRTB request being built from a VAST request, or
+// returns a *NoBidError to end the request with no ad. Other errors are
+// logged and the request goes on without the stage.
+type Enricher interface {
+	Name() string
+	Enrich(ctx context.Context, req *VASTRequest, rtb *OpenRTBRequest) error
+}
+
+// NoBidError ends a request with no ad
+type NoBidError struct {
+	Reason NoBidReason
+	Err    error
+}
+
+func (e *NoBidError) Error() string {
+	return fmt.Sprintf("no bid: %s: %v", e.Reason, e.Err)
+}
+
+func (e *NoBidError) Unwrap() error { return e.Err }
+
+// EnrichmentPipeline runs enrichers in order over each bid request. Stages
+// run in the order registered unless SetOrder says otherwise, and each
+// can be switched off and on while requests are served.
+type EnrichmentPipeline struct {
+	mu     sync.RWMutex
+	stages []enrichStage
+}
+
+type enrichStage struct {
+	enricher Enricher
+	enabled  bool
+}
+
+// EnrichmentStage is a stage's name and whether it runs
+type EnrichmentStage struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// NewEnrichmentPipeline creates a pipeline running enrichers in order
+func NewEnrichmentPipeline(enrichers ...Enricher) (*EnrichmentPipeline, error) {
+	p := &EnrichmentPipeline{}
+	for _, e := range enrichers {
+		if err := p.Register(e); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Register adds an enabled stage after the others
+func (p *EnrichmentPipeline) Register(e Enricher) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.index(e.Name()) >= 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateStage, e.Name())
+	}
+	p.stages = append(p.stages, enrichStage{enricher: e, enabled: true})
+	return nil
+}
+
+// SetOrder runs the named stages first, in the order given; stages not
+// named follow in their current order
+func (p *EnrichmentPipeline) SetOrder(names ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ordered := make([]enrichStage, 0, len(p.stages))
+	placed := make(map[string]bool, len(names))
+	for _, name := range names {
+		i := p.index(name)
+		switch {
+		case i < 0:
+			return fmt.Errorf("%w: %s", ErrUnknownStage, name)
+		case placed[name]:
+			return fmt.Errorf("%w: %s", ErrDuplicateStage, name)
+		}
+		placed[name] = true
+		ordered = append(ordered, p.stages[i])
+	}
+	for _, s := range p.stages {
+		if !placed[s.enricher.Name()] {
+			ordered = append(ordered, s)
+		}
+	}
+	p.stages = ordered
+	return nil
+}
+
+// Enable switches a stage on or off
+func (p *EnrichmentPipeline) Enable(name string, on bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownStage, name)
+	}
+	p.stages[i].enabled = on
+	return nil
+}
+
+// Stages lists the stages in the order they run
+func (p *EnrichmentPipeline) Stages() []EnrichmentStage {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]EnrichmentStage, len(p.stages))
+	for i, s := range p.stages {
+		out[i] = EnrichmentStage{Name: s.enricher.Name(), Enabled: s.enabled}
+	}
+	return out
+}
+
+// Run passes the request through each enabled stage in order, stopping at
+// the first to return a *NoBidError, which Run returns. Errors from other
+// stages are joined and returned with a nil *NoBidError.
+func (p *EnrichmentPipeline) Run(ctx context.Context, req *VASTRequest, rtb *OpenRTBRequest) (*NoBidError, error) {
+	p.mu.RLock()
+	stages := make([]Enricher, 0, len(p.stages))
+	for _, s := range p.stages {
+		if s.enabled {
+			stages = append(stages, s.enricher)
+		}
+	}
+	p.mu.RUnlock()
+
+	var errs []error
+	for _, e := range stages {
+		err := e.Enrich(ctx, req, rtb)
+		if err == nil {
+			continue
+		}
+		var nb *NoBidError
+		if errors.As(err, &nb) {
+			return nb, errors.Join(errs...)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// index is a stage's position, or -1; p.mu must be held
+func (p *EnrichmentPipeline) index(name string) int {
+	for i, s := range p.stages {
+		if s.enricher.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
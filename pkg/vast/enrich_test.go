@@ -0,0 +1,113 @@
+package vast
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeEnricher records that it ran and applies fn
+type fakeEnricher struct {
+	name string
+	ran  *[]string
+	fn   func(*OpenRTBRequest) error
+}
+
+func (f fakeEnricher) Name() string { return f.name }
+
+func (f fakeEnricher) Enrich(ctx context.Context, req *VASTRequest, rtb *OpenRTBRequest) error {
+	*f.ran = append(*f.ran, f.name)
+	if f.fn == nil {
+		return nil
+	}
+	return f.fn(rtb)
+}
+
+func TestEnrichmentPipeline(t *testing.T) {
+	var ran []string
+	fraud := false
+	p, err := NewEnrichmentPipeline(
+		fakeEnricher{name: "geo", ran: &ran, fn: func(r *OpenRTBRequest) error {
+			r.Device.Geo.Country = "USA"
+			return nil
+		}},
+		fakeEnricher{name: "segments", ran: &ran, fn: func(r *OpenRTBRequest) error {
+			return errors.New("segment store down")
+		}},
+		fakeEnricher{name: "device", ran: &ran, fn: func(r *OpenRTBRequest) error {
+			r.Device.Make = "Roku"
+			return nil
+		}},
+		fakeEnricher{name: "fraud", ran: &ran, fn: func(r *OpenRTBRequest) error {
+			if fraud {
+				return &NoBidError{Reason: NoBidFraud, Err: errors.New("datacenter IP")}
+			}
+			return nil
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fraud screening first, and segments off
+	if err := p.SetOrder("fraud", "device"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Enable("segments", false); err != nil {
+		t.Fatal(err)
+	}
+	want := []EnrichmentStage{{"fraud", true}, {"device", true}, {"geo", true}, {"segments", false}}
+	if got := p.Stages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("stages = %v, want %v", got, want)
+	}
+
+	rtb := &OpenRTBRequest{}
+	if nb, err := p.Run(context.Background(), &VASTRequest{}, rtb); nb != nil || err != nil {
+		t.Fatalf("Run = %v, %v", nb, err)
+	}
+	if !reflect.DeepEqual(ran, []string{"fraud", "device", "geo"}) {
+		t.Errorf("ran %v", ran)
+	}
+	if rtb.Device.Geo.Country != "USA" || rtb.Device.Make != "Roku" {
+		t.Errorf("device = %+v", rtb.Device)
+	}
+
+	// A failing stage doesn't stop the others
+	p.Enable("segments", true)
+	ran = nil
+	if nb, err := p.Run(context.Background(), &VASTRequest{}, &OpenRTBRequest{}); nb != nil || err == nil || len(ran) != 4 {
+		t.Errorf("Run = %v, %v after %v", nb, err, ran)
+	}
+
+	// Fraud short-circuits the rest
+	fraud = true
+	ran = nil
+	nb, _ := p.Run(context.Background(), &VASTRequest{}, &OpenRTBRequest{})
+	if nb == nil || nb.Reason != NoBidFraud || !reflect.DeepEqual(ran, []string{"fraud"}) {
+		t.Errorf("Run = %v after %v, want a fraud no-bid after fraud alone", nb, ran)
+	}
+
+	if err := p.SetOrder("nope"); !errors.Is(err, ErrUnknownStage) {
+		t.Errorf("SetOrder(unknown) = %v", err)
+	}
+	if err := p.Register(fakeEnricher{name: "geo", ran: &ran}); !errors.Is(err, ErrDuplicateStage) {
+		t.Errorf("Register(duplicate) = %v", err)
+	}
+
+	// The handler answers a short-circuited request with no ad
+	exchange := &countingExchange{}
+	h := &VASTHandler{Exchange: exchange, Storage: nopStorage{}, Analytics: nopAnalytics{}, Enrichers: p}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/vast", h.HandleVASTRequest)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/vast?apptoken=pub-1&os=ios&osver=17&devicemodel=iPhone&dnt=1&al=l&zoneid=7", nil))
+	if w.Header().Get(NoBidHeader) != string(NoBidFraud) || exchange.auctions.Load() != 0 {
+		t.Errorf("reason %q after %d auctions", w.Header().Get(NoBidHeader), exchange.auctions.Load())
+	}
+}
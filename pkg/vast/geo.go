@@ -0,0 +1,216 @@
+package vast
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// GeoResolver maps an IP address to a coarse location. Implementations
+// return an empty LocationInfo for private, reserved, invalid or unknown
+// addresses rather than an error, since geo is best-effort enrichment.
+type GeoResolver interface {
+	Resolve(ip string) LocationInfo
+}
+
+// CIDRGeoResolver resolves addresses by longest-prefix match against a table
+// of networks, e.g. one exported from the MaxMind GeoLite2 City CSV. Country
+// codes are stored as given, so load ISO-3166-1 alpha-3 codes to match what
+// OpenRTB expects in Geo.country.
+type CIDRGeoResolver struct {
+	mu sync.RWMutex
+	// networks is keyed by prefix length, then by masked prefix
+	networks map[int]map[netip.Prefix]LocationInfo
+	lengths  []int // prefix lengths present, longest first
+}
+
+// NewCIDRGeoResolver creates an empty resolver
+func NewCIDRGeoResolver() *CIDRGeoResolver {
+	return &CIDRGeoResolver{
+		networks: make(map[int]map[netip.Prefix]LocationInfo),
+	}
+}
+
+// AddNetwork registers a network and its location
+func (r *CIDRGeoResolver) AddNetwork(cidr string, loc LocationInfo) error {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return fmt.Errorf("invalid network %q: %w", cidr, err)
+	}
+	prefix = prefix.Masked()
+	bits := prefix.Bits()
+	if prefix.Addr().Is6() {
+		// Keep IPv4 and IPv6 lengths apart so a /24 v4 never shadows a /24 v6
+		bits += 128
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byPrefix, ok := r.networks[bits]
+	if !ok {
+		byPrefix = make(map[netip.Prefix]LocationInfo)
+		r.networks[bits] = byPrefix
+		r.insertLength(bits)
+	}
+	byPrefix[prefix] = loc
+	return nil
+}
+
+// LoadCSV loads networks from CSV rows of the form
+// network,country,region,city[,lat,lon]. A header row starting with
+// "network" is skipped.
+func (r *CIDRGeoResolver) LoadCSV(in io.Reader) error {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line++
+		if len(record) == 0 || (line == 1 && strings.EqualFold(record[0], "network")) {
+			continue
+		}
+		if len(record) < 2 {
+			return fmt.Errorf("line %d: expected at least network and country", line)
+		}
+
+		loc := LocationInfo{Country: record[1]}
+		if len(record) > 2 {
+			loc.Region = record[2]
+		}
+		if len(record) > 3 {
+			loc.City = record[3]
+		}
+		if len(record) > 5 {
+			loc.Lat, loc.Lon = record[4], record[5]
+		}
+		if err := r.AddNetwork(record[0], loc); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// Resolve implements GeoResolver
+func (r *CIDRGeoResolver) Resolve(ip string) LocationInfo {
+	addr, ok := publicAddr(ip)
+	if !ok {
+		return LocationInfo{}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, bits := range r.lengths {
+		plen := bits
+		if addr.Is6() {
+			if bits < 128 {
+				continue
+			}
+			plen -= 128
+		} else if bits >= 128 {
+			continue
+		}
+		prefix, err := addr.Prefix(plen)
+		if err != nil {
+			continue
+		}
+		if loc, ok := r.networks[bits][prefix]; ok {
+			return loc
+		}
+	}
+	return LocationInfo{}
+}
+
+func (r *CIDRGeoResolver) insertLength(bits int) {
+	i := 0
+	for i < len(r.lengths) && r.lengths[i] > bits {
+		i++
+	}
+	r.lengths = append(r.lengths, 0)
+	copy(r.lengths[i+1:], r.lengths[i:])
+	r.lengths[i] = bits
+}
+
+// CachingGeoResolver memoizes lookups of another resolver. The cache is
+// reset once it reaches its size limit, which keeps memory bounded without
+// the bookkeeping of a full LRU.
+type CachingGeoResolver struct {
+	resolver GeoResolver
+	maxSize  int
+
+	mu    sync.RWMutex
+	cache map[string]LocationInfo
+}
+
+// NewCachingGeoResolver wraps resolver with a cache of up to maxSize entries
+func NewCachingGeoResolver(resolver GeoResolver, maxSize int) *CachingGeoResolver {
+	if maxSize <= 0 {
+		maxSize = 100000
+	}
+	return &CachingGeoResolver{
+		resolver: resolver,
+		maxSize:  maxSize,
+		cache:    make(map[string]LocationInfo),
+	}
+}
+
+// Resolve implements GeoResolver
+func (c *CachingGeoResolver) Resolve(ip string) LocationInfo {
+	c.mu.RLock()
+	loc, ok := c.cache[ip]
+	c.mu.RUnlock()
+	if ok {
+		return loc
+	}
+
+	loc = c.resolver.Resolve(ip)
+
+	c.mu.Lock()
+	if len(c.cache) >= c.maxSize {
+		c.cache = make(map[string]LocationInfo)
+	}
+	c.cache[ip] = loc
+	c.mu.Unlock()
+
+	return loc
+}
+
+// publicAddr parses ip and reports whether it is a routable address worth
+// looking up
+func publicAddr(ip string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return netip.Addr{}, false
+	}
+	return addr, true
+}
+
+// resolveGeo looks up the request's IP, preferring IPv4 over IPv6
+func (h *VASTHandler) resolveGeo(req *VASTRequest) LocationInfo {
+	if h.GeoResolver == nil {
+		return LocationInfo{}
+	}
+	for _, ip := range []string{req.IP, req.IPV6} {
+		if ip == "" {
+			continue
+		}
+		if loc := h.GeoResolver.Resolve(ip); loc.Country != "" {
+			return loc
+		}
+	}
+	return LocationInfo{}
+}
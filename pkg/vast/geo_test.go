@@ -0,0 +1,114 @@
+package vast
+
+import (
+	"strings"
+	"testing"
+)
+
+const testGeoCSV = `network,country,region,city
+8.8.8.0/24,USA,CA,Mountain View
+8.0.0.0/8,USA,,
+81.2.69.0/24,GBR,ENG,London
+2001:4860::/32,USA,CA,Mountain View
+`
+
+func newTestGeoResolver(t *testing.T) *CIDRGeoResolver {
+	t.Helper()
+	r := NewCIDRGeoResolver()
+	if err := r.LoadCSV(strings.NewReader(testGeoCSV)); err != nil {
+		t.Fatalf("LoadCSV: %v", err)
+	}
+	return r
+}
+
+func TestCIDRGeoResolver_Resolve(t *testing.T) {
+	r := newTestGeoResolver(t)
+
+	tests := []struct {
+		ip          string
+		wantCountry string
+		wantCity    string
+	}{
+		{"8.8.8.8", "USA", "Mountain View"},
+		{"8.1.2.3", "USA", ""}, // falls back to the /8
+		{"81.2.69.160", "GBR", "London"},
+		{"2001:4860:4860::8888", "USA", "Mountain View"},
+		{"::ffff:8.8.8.8", "USA", "Mountain View"},
+		{"1.1.1.1", "", ""},
+		{"10.0.0.1", "", ""},
+		{"127.0.0.1", "", ""},
+		{"fe80::1", "", ""},
+		{"not-an-ip", "", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			loc := r.Resolve(tt.ip)
+			if loc.Country != tt.wantCountry {
+				t.Errorf("country = %q, want %q", loc.Country, tt.wantCountry)
+			}
+			if loc.City != tt.wantCity {
+				t.Errorf("city = %q, want %q", loc.City, tt.wantCity)
+			}
+		})
+	}
+}
+
+func TestCIDRGeoResolver_InvalidNetwork(t *testing.T) {
+	r := NewCIDRGeoResolver()
+	if err := r.AddNetwork("8.8.8.8/33", LocationInfo{Country: "USA"}); err == nil {
+		t.Error("expected error for invalid prefix")
+	}
+}
+
+type countingResolver struct {
+	calls int
+	loc   LocationInfo
+}
+
+func (c *countingResolver) Resolve(string) LocationInfo {
+	c.calls++
+	return c.loc
+}
+
+func TestCachingGeoResolver(t *testing.T) {
+	inner := &countingResolver{loc: LocationInfo{Country: "USA"}}
+	r := NewCachingGeoResolver(inner, 2)
+
+	for i := 0; i < 3; i++ {
+		if loc := r.Resolve("8.8.8.8"); loc.Country != "USA" {
+			t.Fatalf("country = %q, want USA", loc.Country)
+		}
+	}
+	if inner.calls != 1 {
+		t.Errorf("inner calls = %d, want 1", inner.calls)
+	}
+
+	// Filling the cache resets it rather than growing without bound
+	r.Resolve("8.8.4.4")
+	r.Resolve("1.0.0.1")
+	if len(r.cache) > 2 {
+		t.Errorf("cache size = %d, want <= 2", len(r.cache))
+	}
+}
+
+func TestBuildOpenRTBRequest_Geo(t *testing.T) {
+	h := &VASTHandler{GeoResolver: newTestGeoResolver(t)}
+	req := &VASTRequest{IP: "81.2.69.160", AL: "l", AdCount: 1}
+
+	rtbReq := h.buildOpenRTBRequest(req)
+	if rtbReq.Device.Geo.Country != "GBR" {
+		t.Errorf("geo country = %q, want GBR", rtbReq.Device.Geo.Country)
+	}
+	if rtbReq.Device.Geo.Type != 2 {
+		t.Errorf("geo type = %d, want 2 (IP address)", rtbReq.Device.Geo.Type)
+	}
+
+	// No resolver configured must not break request building
+	h = &VASTHandler{}
+	rtbReq = h.buildOpenRTBRequest(req)
+	if rtbReq.Device.Geo.Country != "" {
+		t.Errorf("geo country = %q, want empty", rtbReq.Device.Geo.Country)
+	}
+}
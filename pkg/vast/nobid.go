@@ -0,0 +1,76 @@
+package vast
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoBidHeader carries the reason a VAST request got no ad
+const NoBidHeader = "X-ADX-NoBid-Reason"
+
+// NBRBelowFloor is the exchange-specific OpenRTB no-bid reason the
+// exchange answers with when it had bids, but all under the floor, as
+// rtb.NoBidBelowFloor
+const NBRBelowFloor = 500
+
+// NoBidReason is why a VAST request got no ad
+type NoBidReason string
+
+const (
+	NoBidPrivacy    NoBidReason = "privacy_blocked"    // The request can't be served under its privacy signals
+	NoBidNoDemand   NoBidReason = "no_eligible_demand" // No bidder offered an eligible ad
+	NoBidBelowFloor NoBidReason = "below_floor"        // Every bid was under the floor
+	NoBidFraud      NoBidReason = "suspected_fraud"    // Screened out as invalid traffic
+	NoBidInternal   NoBidReason = "internal_error"     // The auction failed
+)
+
+// ErrorCode is the IAB VAST error code a reason is reported with: 303,
+// no ad, for the request going unfilled, and 900, undefined, for our own
+// failure
+func (r NoBidReason) ErrorCode() int {
+	if r == NoBidInternal {
+		return 900
+	}
+	return 303
+}
+
+// noBidReason classifies an unfilled auction
+func noBidReason(resp *OpenRTBResponse, err error) NoBidReason {
+	switch {
+	case err != nil || resp == nil:
+		return NoBidInternal
+	case resp.NBR == NBRBelowFloor:
+		return NoBidBelowFloor
+	}
+	return NoBidNoDemand
+}
+
+// noAd answers a request that got no ad. The reason is always in the
+// NoBidHeader header; players get it as an Error-only VAST document,
+// whose error URI reports the IAB error code, unless they ask for a bare
+// 204 with nocontent=1.
+func (h *VASTHandler) noAd(c *gin.Context, req *VASTRequest, reason NoBidReason) {
+	c.Header(NoBidHeader, string(reason))
+	if req.NoContent == 1 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.XML(http.StatusOK, VAST{
+		Version: "4.0",
+		Errors:  []string{noBidErrorURL(req, reason)},
+	})
+}
+
+// noBidErrorURL is the error beacon for an unfilled request
+func noBidErrorURL(req *VASTRequest, reason NoBidReason) string {
+	q := url.QueryEscape
+	u := fmt.Sprintf("https://track.lux.network/v1/event?event=error&code=%d&reason=%s&zone=%d&app=%s",
+		reason.ErrorCode(), q(string(reason)), req.ZoneID, q(req.AppToken))
+	if req.ServeID != "" {
+		u += "&srv=" + q(req.ServeID)
+	}
+	return u
+}
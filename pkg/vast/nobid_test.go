@@ -0,0 +1,72 @@
+package vast
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubExchange answers every auction the same way
+type stubExchange struct {
+	resp *OpenRTBResponse
+	err  error
+}
+
+func (e stubExchange) RunAuction(ctx context.Context, req *OpenRTBRequest) (*OpenRTBResponse, error) {
+	return e.resp, e.err
+}
+
+func TestHandleVASTRequest_NoBidReasons(t *testing.T) {
+	tests := []struct {
+		name     string
+		exchange stubExchange
+		query    string
+		want     NoBidReason
+		code     string
+	}{
+		{"privacy", stubExchange{resp: &OpenRTBResponse{}}, "&gdpr=1", NoBidPrivacy, "code=303"},
+		{"no vendor ID", stubExchange{resp: &OpenRTBResponse{}}, "&gdpr=1&userconsent=CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", NoBidPrivacy, "code=303"},
+		{"no demand", stubExchange{resp: &OpenRTBResponse{}}, "", NoBidNoDemand, "code=303"},
+		{"below floor", stubExchange{resp: &OpenRTBResponse{NBR: NBRBelowFloor}}, "", NoBidBelowFloor, "code=303"},
+		{"auction failed", stubExchange{err: errors.New("dsp timeout")}, "", NoBidInternal, "code=900"},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &VASTHandler{Exchange: tt.exchange, Storage: nopStorage{}, Analytics: nopAnalytics{}}
+			r := gin.New()
+			r.GET("/vast", h.HandleVASTRequest)
+			serve := func(query string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/vast?apptoken=pub-1&os=ios&osver=17&devicemodel=iPhone&dnt=1&al=l&zoneid=7"+query, nil)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w
+			}
+
+			w := serve(tt.query)
+			if w.Code != http.StatusOK || w.Header().Get(NoBidHeader) != string(tt.want) {
+				t.Fatalf("status %d, reason %q, want %q", w.Code, w.Header().Get(NoBidHeader), tt.want)
+			}
+			var doc VAST
+			if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Ads) != 0 || len(doc.Errors) != 1 || !strings.Contains(doc.Errors[0], tt.code) ||
+				!strings.Contains(doc.Errors[0], "reason="+string(tt.want)) {
+				t.Errorf("document = %s", w.Body.String())
+			}
+
+			// Players that need it get a bare 204, still with the reason
+			w = serve(tt.query + "&nocontent=1")
+			if w.Code != http.StatusNoContent || w.Body.Len() != 0 || w.Header().Get(NoBidHeader) != string(tt.want) {
+				t.Errorf("nocontent: status %d, reason %q, body %q", w.Code, w.Header().Get(NoBidHeader), w.Body.String())
+			}
+		})
+	}
+}
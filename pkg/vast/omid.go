@@ -0,0 +1,183 @@
+package vast
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strings"
+)
+
+// VerificationVendor is an Open Measurement verification vendor we let
+// scripts run for
+type VerificationVendor struct {
+	Script string   // Script injected when a publisher asks for the vendor
+	Hosts  []string // Hosts the vendor's scripts may be loaded from
+}
+
+// DefaultVerificationVendors is the OMID vendor allowlist used when a
+// handler doesn't set its own, keyed by lowercase vendor name
+var DefaultVerificationVendors = map[string]VerificationVendor{
+	"iabtechlab": {
+		Script: "https://cdn.lux.network/omid/omid-validation-verification-script-v1.js",
+		Hosts:  []string{"cdn.lux.network", "iabtechlab.com"},
+	},
+	"moat": {
+		Script: "https://cdn.lux.network/omid/moat-omid-verification.js",
+		Hosts:  []string{"cdn.lux.network", "moatads.com"},
+	},
+	"doubleverify": {
+		Script: "https://cdn.lux.network/omid/dv-omid-verification.js",
+		Hosts:  []string{"cdn.lux.network", "doubleverify.com"},
+	},
+	"ias": {
+		Script: "https://cdn.lux.network/omid/ias-omid-verification.js",
+		Hosts:  []string{"cdn.lux.network", "adsafeprotected.com"},
+	},
+}
+
+// BidVerification is a verification resource a DSP attaches to its bid in
+// bid.ext.verifications
+type BidVerification struct {
+	Vendor string `json:"vendor"`
+	URL    string `json:"url"`
+	Params string `json:"params,omitempty"`
+}
+
+type bidVerificationExt struct {
+	Verifications []BidVerification `json:"verifications"`
+}
+
+// adVerifications merges the verifications the publisher asked for with
+// those the winning creative carries, dropping vendors not on the
+// allowlist and scripts not served from the vendor's hosts. Each vendor
+// appears once; the creative's own resource wins over our default script.
+func (h *VASTHandler) adVerifications(req *VASTRequest, bid *Bid) *AdVerifications {
+	vendors := h.Verifiers
+	if vendors == nil {
+		vendors = DefaultVerificationVendors
+	}
+
+	var out []Verification
+	seen := make(map[string]bool)
+	add := func(v Verification) {
+		name := strings.ToLower(v.Vendor)
+		vendor, ok := vendors[name]
+		if !ok || seen[name] || v.JavaScriptResource == nil || !vendor.serves(v.JavaScriptResource.URL) {
+			return
+		}
+		seen[name] = true
+		v.Vendor = name
+		v.JavaScriptResource.APIFramework = "omid"
+		if v.TrackingEvents == nil {
+			v.TrackingEvents = &TrackingEvents{}
+		}
+		v.TrackingEvents.Tracking = append(v.TrackingEvents.Tracking, Tracking{
+			Event: "verificationNotExecuted",
+			URL:   h.buildTrackingURL("verificationNotExecuted", req, bid) + "&vendor=" + url.QueryEscape(name) + "&reason=[REASON]",
+		})
+		out = append(out, v)
+	}
+
+	for _, v := range creativeVerifications(bid) {
+		add(v)
+	}
+
+	params, _ := json.Marshal(map[string]string{"partnername": req.OMIDPN, "partnerversion": req.OMIDPV})
+	requested := append([]string{req.OMIDPN}, req.OMIDVendors...)
+	for _, name := range requested {
+		if vendor, ok := vendors[strings.ToLower(name)]; ok {
+			add(Verification{
+				Vendor:                 name,
+				JavaScriptResource:     &JavaScriptResource{URL: vendor.Script},
+				VerificationParameters: string(params),
+			})
+		}
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+	return &AdVerifications{Verification: out}
+}
+
+// serves reports whether script is an https URL on one of the vendor's
+// hosts or their subdomains
+func (v VerificationVendor) serves(script string) bool {
+	u, err := url.Parse(script)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range v.Hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// creativeVerifications collects the verifications a bid brings: from
+// bid.ext.verifications and, for VAST markup, the ad's AdVerifications
+// (InLine for VAST 4.1+, an Extension before that)
+func creativeVerifications(bid *Bid) []Verification {
+	var out []Verification
+
+	if bid.Ext != nil {
+		raw, err := json.Marshal(bid.Ext)
+		if err == nil {
+			var ext bidVerificationExt
+			if json.Unmarshal(raw, &ext) == nil {
+				for _, v := range ext.Verifications {
+					out = append(out, Verification{
+						Vendor:                 v.Vendor,
+						JavaScriptResource:     &JavaScriptResource{URL: strings.TrimSpace(v.URL)},
+						VerificationParameters: v.Params,
+					})
+				}
+			}
+		}
+	}
+
+	if !strings.HasPrefix(strings.TrimSpace(bid.ADM), "<") {
+		return out
+	}
+	var markup VAST
+	if xml.Unmarshal([]byte(bid.ADM), &markup) != nil {
+		return out
+	}
+	for _, ad := range markup.Ads {
+		if ad.InLine == nil {
+			continue
+		}
+		if ad.InLine.AdVerifications != nil {
+			out = append(out, trimVerifications(ad.InLine.AdVerifications.Verification)...)
+		}
+		if ad.InLine.Extensions == nil {
+			continue
+		}
+		for _, ext := range ad.InLine.Extensions.Extension {
+			if ext.AdVerifications != nil {
+				out = append(out, trimVerifications(ext.AdVerifications.Verification)...)
+			}
+		}
+	}
+	return out
+}
+
+// trimVerifications keeps the JavaScript verifications from parsed markup
+// along with the DSP's own verificationNotExecuted trackers
+func trimVerifications(vs []Verification) []Verification {
+	out := make([]Verification, 0, len(vs))
+	for _, v := range vs {
+		if v.JavaScriptResource == nil {
+			continue
+		}
+		out = append(out, Verification{
+			Vendor:                 v.Vendor,
+			JavaScriptResource:     &JavaScriptResource{URL: strings.TrimSpace(v.JavaScriptResource.URL)},
+			VerificationParameters: strings.TrimSpace(v.VerificationParameters),
+			TrackingEvents:         v.TrackingEvents,
+		})
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package vast
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+const dspVASTWithVerifications = `<VAST version="4.2"><Ad id="a1"><InLine>
+<AdSystem>DSP</AdSystem><AdTitle>t</AdTitle>
+<AdVerifications>
+	<Verification vendor="doubleverify">
+		<JavaScriptResource apiFramework="omid"><![CDATA[https://cdn.doubleverify.com/dvtp_src.js]]></JavaScriptResource>
+		<VerificationParameters><![CDATA[ctx=123]]></VerificationParameters>
+	</Verification>
+	<Verification vendor="shadyvendor">
+		<JavaScriptResource apiFramework="omid"><![CDATA[https://shady.example/track.js]]></JavaScriptResource>
+	</Verification>
+</AdVerifications>
+<Creatives></Creatives></InLine></Ad></VAST>`
+
+func TestCreateVASTAd_MergesVerifications(t *testing.T) {
+	h := &VASTHandler{}
+	req := &VASTRequest{AppToken: "app", OMIDPN: "iabtechlab", OMIDPV: "1.4", OMIDVendors: []string{"moat"}}
+	bid := &Bid{
+		ID:      "b1",
+		ImpID:   "1",
+		ADomain: []string{"brand.example"},
+		ADM:     dspVASTWithVerifications,
+		Ext: map[string]any{"verifications": []map[string]string{
+			{"vendor": "IAS", "url": "https://pixel.adsafeprotected.com/omid.js", "params": "anId=9"},
+			{"vendor": "moat", "url": "http://z.moatads.com/insecure.js"},
+		}},
+	}
+
+	ad := h.createVASTAd(req, bid)
+	if ad.InLine.AdVerifications == nil {
+		t.Fatal("no AdVerifications")
+	}
+	got := make(map[string]Verification)
+	for _, v := range ad.InLine.AdVerifications.Verification {
+		if _, dup := got[v.Vendor]; dup {
+			t.Errorf("vendor %s emitted twice", v.Vendor)
+		}
+		got[v.Vendor] = v
+	}
+	if len(got) != 4 {
+		t.Fatalf("vendors = %v, want ias, doubleverify, iabtechlab and moat", got)
+	}
+	if _, ok := got["shadyvendor"]; ok {
+		t.Error("vendor missing from the allowlist was not stripped")
+	}
+
+	if v := got["ias"]; v.JavaScriptResource.URL != "https://pixel.adsafeprotected.com/omid.js" || v.VerificationParameters != "anId=9" {
+		t.Errorf("ias = %+v, want the DSP's resource", v)
+	}
+	if v := got["doubleverify"]; v.JavaScriptResource.URL != "https://cdn.doubleverify.com/dvtp_src.js" || v.VerificationParameters != "ctx=123" {
+		t.Errorf("doubleverify = %+v, want the creative's resource", v)
+	}
+	// The DSP's moat script is plain http, so the publisher's default is used
+	if v := got["moat"]; v.JavaScriptResource.URL != DefaultVerificationVendors["moat"].Script {
+		t.Errorf("moat script = %q", v.JavaScriptResource.URL)
+	}
+	if v := got["iabtechlab"]; v.VerificationParameters != `{"partnername":"iabtechlab","partnerversion":"1.4"}` {
+		t.Errorf("iabtechlab params = %q", v.VerificationParameters)
+	}
+
+	for name, v := range got {
+		if v.JavaScriptResource.APIFramework != "omid" {
+			t.Errorf("%s apiFramework = %q", name, v.JavaScriptResource.APIFramework)
+		}
+		if v.TrackingEvents == nil || len(v.TrackingEvents.Tracking) != 1 {
+			t.Fatalf("%s tracking = %+v", name, v.TrackingEvents)
+		}
+		tr := v.TrackingEvents.Tracking[0]
+		if tr.Event != "verificationNotExecuted" || !strings.Contains(tr.URL, "event=verificationNotExecuted") || !strings.HasSuffix(tr.URL, "&reason=[REASON]") {
+			t.Errorf("%s tracking = %+v", name, tr)
+		}
+	}
+
+	out, err := xml.Marshal(ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(out), "<Verification "); n != 4 {
+		t.Errorf("marshalled %d Verification elements, want 4", n)
+	}
+}
+
+func TestCreateVASTAd_VerificationAllowlist(t *testing.T) {
+	h := &VASTHandler{Verifiers: map[string]VerificationVendor{
+		"ias": {Script: "https://cdn.example/ias.js", Hosts: []string{"cdn.example"}},
+	}}
+	req := &VASTRequest{OMIDPN: "doubleverify", OMIDVendors: []string{"ias"}}
+	bid := &Bid{
+		ID:      "b1",
+		ADomain: []string{"brand.example"},
+		Ext: map[string]any{"verifications": []map[string]string{
+			{"vendor": "ias", "url": "https://cdn.example.evil.test/ias.js"},
+		}},
+	}
+
+	ad := h.createVASTAd(req, bid)
+	if ad.InLine.AdVerifications == nil || len(ad.InLine.AdVerifications.Verification) != 1 {
+		t.Fatalf("verifications = %+v, want only ias", ad.InLine.AdVerifications)
+	}
+	if v := ad.InLine.AdVerifications.Verification[0]; v.Vendor != "ias" || v.JavaScriptResource.URL != "https://cdn.example/ias.js" {
+		t.Errorf("verification = %+v", v)
+	}
+
+	if ad := h.createVASTAd(&VASTRequest{OMIDPN: "unknown"}, &Bid{ADomain: []string{"b"}}); ad.InLine.AdVerifications != nil {
+		t.Errorf("unlisted partner got verifications: %+v", ad.InLine.AdVerifications)
+	}
+}
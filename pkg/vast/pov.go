@@ -0,0 +1,178 @@
+package vast
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPoVMalformed = errors.New("malformed proof of view")
+	ErrPoVSigner    = errors.New("unknown proof of view signer")
+	ErrPoVSignature = errors.New("invalid proof of view signature")
+	ErrPoVExpired   = errors.New("proof of view expired")
+	ErrPoVBinding   = errors.New("proof of view bound to a different impression or session")
+	ErrPoVReplayed  = errors.New("proof of view already used")
+	ErrPoVRejected  = errors.New("proof of view rejected")
+	ErrPoVNoWallet  = errors.New("on-chain view needs a wallet")
+)
+
+// PoVTicket is the signed claim a player attestation key makes about a view.
+// The ticket is encoded as base64url(JSON) "." base64url(ed25519 signature).
+// Because ed25519 signatures are deterministic, the hash of the signature is
+// a unique VRF-style output for the (impression, session, time) input and is
+// what the seen-set tracks.
+type PoVTicket struct {
+	ImpressionID string `json:"imp"`
+	SessionID    string `json:"sid"`
+	KeyID        string `json:"kid"`
+	Timestamp    int64  `json:"ts"` // unix milliseconds
+
+	output string
+}
+
+// Output returns the ticket's VRF output
+func (t *PoVTicket) Output() string {
+	return t.output
+}
+
+// PoVVerifier checks proof-of-view tickets against registered player keys
+type PoVVerifier struct {
+	mu     sync.Mutex
+	keys   map[string]ed25519.PublicKey
+	maxAge time.Duration
+	seen   map[string]time.Time // VRF output -> expiry
+	now    func() time.Time
+}
+
+// NewPoVVerifier creates a verifier accepting tickets up to maxAge old
+func NewPoVVerifier(maxAge time.Duration) *PoVVerifier {
+	return &PoVVerifier{
+		keys:   make(map[string]ed25519.PublicKey),
+		maxAge: maxAge,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// RegisterKey trusts a player attestation key
+func (v *PoVVerifier) RegisterKey(keyID string, pub ed25519.PublicKey) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys[keyID] = pub
+}
+
+// Verify validates a ticket for impressionID and sessionID and marks it
+// used. A ticket is only accepted once.
+func (v *PoVVerifier) Verify(pov, impressionID, sessionID string) (*PoVTicket, error) {
+	payload, sig, ok := strings.Cut(pov, ".")
+	if !ok {
+		return nil, ErrPoVMalformed
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrPoVMalformed
+	}
+	sigBytes, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || len(sigBytes) != ed25519.SignatureSize {
+		return nil, ErrPoVMalformed
+	}
+
+	var ticket PoVTicket
+	if err := json.Unmarshal(payloadBytes, &ticket); err != nil {
+		return nil, ErrPoVMalformed
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	pub, ok := v.keys[ticket.KeyID]
+	if !ok {
+		return nil, ErrPoVSigner
+	}
+	if !ed25519.Verify(pub, payloadBytes, sigBytes) {
+		return nil, ErrPoVSignature
+	}
+	if ticket.ImpressionID != impressionID || ticket.SessionID != sessionID {
+		return nil, ErrPoVBinding
+	}
+
+	now := v.now()
+	issued := time.UnixMilli(ticket.Timestamp)
+	if now.Sub(issued) > v.maxAge || issued.After(now.Add(time.Minute)) {
+		return nil, ErrPoVExpired
+	}
+
+	out := sha256.Sum256(sigBytes)
+	ticket.output = hex.EncodeToString(out[:])
+
+	v.pruneSeen(now)
+	if _, used := v.seen[ticket.output]; used {
+		return nil, ErrPoVReplayed
+	}
+	// Remember the ticket until it would have expired anyway
+	v.seen[ticket.output] = issued.Add(v.maxAge)
+
+	return &ticket, nil
+}
+
+// VerifyProofOfView is the boolean form used by BlockchainManager
+// implementations
+func (v *PoVVerifier) VerifyProofOfView(pov, impressionID, sessionID string) bool {
+	_, err := v.Verify(pov, impressionID, sessionID)
+	return err == nil
+}
+
+func (v *PoVVerifier) pruneSeen(now time.Time) {
+	for out, expiry := range v.seen {
+		if now.After(expiry) {
+			delete(v.seen, out)
+		}
+	}
+}
+
+// RecordView records a served impression on chain for wallet once the
+// player proves it was viewed. The proof must be a ticket bound to
+// impressionID, the impression's ImpressionKey, and the player's session,
+// so a ticket for one impression can't record another. Tickets can't be
+// made before the impression is served, so the player presents one on its
+// impression beacon rather than with the VAST request.
+func (h *VASTHandler) RecordView(impressionID, pov, sessionID, wallet string, chainID int) error {
+	if wallet == "" {
+		return ErrPoVNoWallet
+	}
+	if h.BlockchainMgr == nil || !h.BlockchainMgr.VerifyProofOfView(pov, impressionID, sessionID) {
+		return ErrPoVRejected
+	}
+	imp, err := h.Storage.GetImpression(impressionID)
+	if err != nil {
+		return err
+	}
+	if imp == nil {
+		return fmt.Errorf("impression %s not found", impressionID)
+	}
+	return h.BlockchainMgr.RecordImpression(imp, wallet, chainID)
+}
+
+// SignPoV creates a ticket with key; used by player SDKs and tests
+func SignPoV(key ed25519.PrivateKey, keyID, impressionID, sessionID string, ts time.Time) (string, error) {
+	payload, err := json.Marshal(PoVTicket{
+		ImpressionID: impressionID,
+		SessionID:    sessionID,
+		KeyID:        keyID,
+		Timestamp:    ts.UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+	sig := ed25519.Sign(key, payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,140 @@
+package vast
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestPoVVerifier(t *testing.T) (*PoVVerifier, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	v := NewPoVVerifier(5 * time.Minute)
+	v.RegisterKey("player-1", pub)
+	return v, priv
+}
+
+func TestPoVVerifier_Valid(t *testing.T) {
+	v, key := newTestPoVVerifier(t)
+	pov, err := SignPoV(key, "player-1", "imp-1", "session-1", time.Now())
+	if err != nil {
+		t.Fatalf("SignPoV: %v", err)
+	}
+
+	ticket, err := v.Verify(pov, "imp-1", "session-1")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if ticket.ImpressionID != "imp-1" || ticket.Output() == "" {
+		t.Errorf("ticket = %+v", ticket)
+	}
+}
+
+func TestPoVVerifier_Replay(t *testing.T) {
+	v, key := newTestPoVVerifier(t)
+	pov, _ := SignPoV(key, "player-1", "imp-1", "session-1", time.Now())
+
+	if !v.VerifyProofOfView(pov, "imp-1", "session-1") {
+		t.Fatal("first use should verify")
+	}
+	if _, err := v.Verify(pov, "imp-1", "session-1"); err != ErrPoVReplayed {
+		t.Errorf("err = %v, want %v", err, ErrPoVReplayed)
+	}
+}
+
+func TestPoVVerifier_Rejects(t *testing.T) {
+	v, key := newTestPoVVerifier(t)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	valid, _ := SignPoV(key, "player-1", "imp-1", "session-1", time.Now())
+	stale, _ := SignPoV(key, "player-1", "imp-1", "session-1", time.Now().Add(-time.Hour))
+	forged, _ := SignPoV(otherKey, "player-1", "imp-1", "session-1", time.Now())
+	unknown, _ := SignPoV(key, "player-2", "imp-1", "session-1", time.Now())
+
+	tests := []struct {
+		name    string
+		pov     string
+		imp     string
+		session string
+		wantErr error
+	}{
+		{"different impression", valid, "imp-2", "session-1", ErrPoVBinding},
+		{"different session", valid, "imp-1", "session-2", ErrPoVBinding},
+		{"expired", stale, "imp-1", "session-1", ErrPoVExpired},
+		{"wrong key", forged, "imp-1", "session-1", ErrPoVSignature},
+		{"unknown signer", unknown, "imp-1", "session-1", ErrPoVSigner},
+		{"malformed", "deadbeef", "imp-1", "session-1", ErrPoVMalformed},
+		{"empty", "", "imp-1", "session-1", ErrPoVMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(tt.pov, tt.imp, tt.session); err != tt.wantErr {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A rejected binding must not burn the ticket for its real impression
+	if !v.VerifyProofOfView(valid, "imp-1", "session-1") {
+		t.Error("valid ticket should still verify for its own impression")
+	}
+}
+
+// povChain is a BlockchainManager checking proofs with a verifier and
+// recording the impressions it's asked to
+type povChain struct {
+	recordingChain
+	v        *PoVVerifier
+	recorded []string
+}
+
+func (c *povChain) VerifyProofOfView(pov, impressionID, sessionID string) bool {
+	return c.v.VerifyProofOfView(pov, impressionID, sessionID)
+}
+
+func (c *povChain) RecordImpression(imp *ImpressionRecord, wallet string, chainID int) error {
+	c.recorded = append(c.recorded, imp.ID+"@"+wallet)
+	return nil
+}
+
+// impressionStore is a StorageBackend holding impressions in memory
+type impressionStore map[string]*ImpressionRecord
+
+func (s impressionStore) StoreImpression(imp *ImpressionRecord) error { s[imp.ID] = imp; return nil }
+func (s impressionStore) GetImpression(id string) (*ImpressionRecord, error) {
+	return s[id], nil
+}
+
+func TestRecordView_BoundToImpression(t *testing.T) {
+	v, key := newTestPoVVerifier(t)
+	chain := &povChain{v: v}
+	store := impressionStore{}
+	h := &VASTHandler{Storage: store, BlockchainMgr: chain}
+	for _, id := range []string{"srv-1:b1", "srv-2:b1"} {
+		store.StoreImpression(&ImpressionRecord{ID: id})
+	}
+
+	pov, _ := SignPoV(key, "player-1", "srv-1:b1", "session-1", time.Now())
+	// A ticket for one impression doesn't record another
+	if err := h.RecordView("srv-2:b1", pov, "session-1", "0xabc", 1); !errors.Is(err, ErrPoVRejected) {
+		t.Errorf("other impression: err = %v", err)
+	}
+	if err := h.RecordView("srv-1:b1", pov, "session-1", "", 1); !errors.Is(err, ErrPoVNoWallet) {
+		t.Errorf("no wallet: err = %v", err)
+	}
+	if err := h.RecordView("srv-1:b1", pov, "session-1", "0xabc", 1); err != nil {
+		t.Fatalf("RecordView: %v", err)
+	}
+	if err := h.RecordView("srv-1:b1", pov, "session-1", "0xabc", 1); !errors.Is(err, ErrPoVRejected) {
+		t.Errorf("replayed: err = %v", err)
+	}
+	if len(chain.recorded) != 1 || chain.recorded[0] != "srv-1:b1@0xabc" {
+		t.Errorf("recorded = %v", chain.recorded)
+	}
+}
@@ -0,0 +1,145 @@
+package vast
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrReceiptUnsigned  = errors.New("receipt is not signed")
+	ErrReceiptSignature = errors.New("receipt signature does not verify")
+	ErrReceiptNode      = errors.New("receipt signed by another node")
+)
+
+// receiptDomain separates receipt signatures from anything else the node
+// key signs
+const receiptDomain = "lux-adx-receipt-v1\n"
+
+// Receipt is a node-signed record of an ad served, returned to the
+// publisher in the ad's VAST extensions for reconciliation. ImpressionID is
+// the impression record's ID, under which the serve is stored and settled
+// on chain, and Settlement names the record the auction is committed to.
+type Receipt struct {
+	ImpressionID string  `xml:"ImpressionID" json:"impression_id"`
+	AuctionID    string  `xml:"AuctionID" json:"auction_id"`
+	BidID        string  `xml:"BidID" json:"bid_id"`
+	Seat         string  `xml:"Seat" json:"seat"`
+	Price        float64 `xml:"Price" json:"price"` // Cleared CPM
+	Currency     string  `xml:"Currency" json:"currency"`
+	Settlement   string  `xml:"Settlement" json:"settlement"` // See SettlementLocator
+	Timestamp    int64   `xml:"Timestamp" json:"timestamp"`   // Unix milliseconds
+	Node         string  `xml:"Node" json:"node"`             // Signing node's hex ed25519 public key
+	Signature    string  `xml:"Signature" json:"signature,omitempty"`
+}
+
+// SettlementLocator names the record a served impression is committed and
+// settled under, for receipts
+type SettlementLocator interface {
+	SettlementRef(auctionID, impressionID string) string
+}
+
+// DAGSettlement locates an auction's outcome at its DAG vertex, as adxd
+// serves it under /dag/vertex/
+type DAGSettlement struct{}
+
+// SettlementRef implements SettlementLocator
+func (DAGSettlement) SettlementRef(auctionID, impressionID string) string {
+	return "dag:vertex/" + auctionID
+}
+
+// ReceiptSigner signs receipts with the node's key
+type ReceiptSigner struct {
+	key ed25519.PrivateKey
+	now func() time.Time
+}
+
+// NewReceiptSigner creates a signer for key
+func NewReceiptSigner(key ed25519.PrivateKey) *ReceiptSigner {
+	return &ReceiptSigner{key: key, now: time.Now}
+}
+
+// PublicKey is the key publishers verify receipts against
+func (s *ReceiptSigner) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign stamps the receipt with the time and node, and signs it
+func (s *ReceiptSigner) Sign(r *Receipt) {
+	r.Timestamp = s.now().UnixMilli()
+	r.Node = hex.EncodeToString(s.PublicKey())
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, receiptMessage(r)))
+}
+
+// VerifyReceipt checks a receipt was signed by the node holding pub and
+// hasn't been altered. It needs nothing but the node's public key, so
+// publishers can run it offline.
+func VerifyReceipt(pub ed25519.PublicKey, r *Receipt) error {
+	if r.Signature == "" {
+		return ErrReceiptUnsigned
+	}
+	if r.Node != hex.EncodeToString(pub) {
+		return ErrReceiptNode
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil || !ed25519.Verify(pub, receiptMessage(r), sig) {
+		return ErrReceiptSignature
+	}
+	return nil
+}
+
+// receiptMessage is the signed form of a receipt: its JSON without the
+// signature
+func receiptMessage(r *Receipt) []byte {
+	unsigned := *r
+	unsigned.Signature = ""
+	data, _ := json.Marshal(unsigned)
+	return append([]byte(receiptDomain), data...)
+}
+
+// HandleReceiptKey publishes the key receipts verify against
+func (h *VASTHandler) HandleReceiptKey(c *gin.Context) {
+	if h.Receipts == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "receipts not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"algorithm": "ed25519", "public_key": hex.EncodeToString(h.Receipts.PublicKey())})
+}
+
+// attachReceipt adds a signed receipt for bid to its ad as a LuxReceipt
+// extension. The receipt is keyed by the same ImpressionKey as the serve's
+// impression record and beacons, and references the auction's settlement
+// record through h.Settlements, or its DAG vertex when that's nil.
+func (h *VASTHandler) attachReceipt(req *VASTRequest, auctionID, seat string, bid *Bid, ad *Ad) {
+	if h.Receipts == nil {
+		return
+	}
+	var locator SettlementLocator = DAGSettlement{}
+	if h.Settlements != nil {
+		locator = h.Settlements
+	}
+	impressionID := ImpressionKey(req.ServeID, bid.ID)
+	r := &Receipt{
+		ImpressionID: impressionID,
+		AuctionID:    auctionID,
+		BidID:        bid.ID,
+		Seat:         seat,
+		Price:        bid.Price,
+		Currency:     bid.Cur,
+		Settlement:   locator.SettlementRef(auctionID, impressionID),
+	}
+	h.Receipts.Sign(r)
+	if ad.InLine.Extensions == nil {
+		ad.InLine.Extensions = &Extensions{}
+	}
+	ad.InLine.Extensions.Extension = append(ad.InLine.Extensions.Extension, Extension{
+		Type:    "LuxReceipt",
+		Receipt: r,
+	})
+}
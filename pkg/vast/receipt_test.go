@@ -0,0 +1,93 @@
+package vast
+
+import (
+	"crypto/ed25519"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReceipt_ServedAdVerifies(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := make(chan *ImpressionRecord, 1)
+	h := &VASTHandler{
+		Exchange:  &countingExchange{},
+		Storage:   recordingStorage(stored),
+		Analytics: nopAnalytics{},
+		Receipts:  NewReceiptSigner(key),
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/vast", h.HandleVASTRequest)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/vast?apptoken=pub-1&os=ios&osver=17&devicemodel=iPhone&dnt=1&zoneid=7&al=l", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	var served VAST
+	if err := xml.Unmarshal(w.Body.Bytes(), &served); err != nil {
+		t.Fatal(err)
+	}
+	var receipt *Receipt
+	if ext := served.Ads[0].InLine.Extensions; ext != nil {
+		for _, e := range ext.Extension {
+			if e.Receipt != nil {
+				receipt = e.Receipt
+			}
+		}
+	}
+	if receipt == nil {
+		t.Fatalf("no receipt in %s", w.Body.String())
+	}
+	if receipt.Seat != "dsp1" || receipt.Price != 2.5 || receipt.BidID != "b1" || receipt.AuctionID == "" {
+		t.Errorf("receipt = %+v", receipt)
+	}
+	if want := "dag:vertex/" + receipt.AuctionID; receipt.Settlement != want {
+		t.Errorf("settlement = %q, want %q", receipt.Settlement, want)
+	}
+	if err := VerifyReceipt(pub, receipt); err != nil {
+		t.Fatalf("VerifyReceipt: %v", err)
+	}
+	// The receipt names the impression record the serve is stored under
+	if imp := <-stored; imp.ID != receipt.ImpressionID {
+		t.Errorf("impression record %q, receipt %q", imp.ID, receipt.ImpressionID)
+	}
+
+	tampered := *receipt
+	tampered.Price = 0.5
+	if err := VerifyReceipt(pub, &tampered); !errors.Is(err, ErrReceiptSignature) {
+		t.Errorf("tampered price: err = %v", err)
+	}
+	tampered = *receipt
+	tampered.Seat = "dsp2"
+	if err := VerifyReceipt(pub, &tampered); !errors.Is(err, ErrReceiptSignature) {
+		t.Errorf("tampered seat: err = %v", err)
+	}
+	tampered = *receipt
+	tampered.Settlement = "dag:vertex/other"
+	if err := VerifyReceipt(pub, &tampered); !errors.Is(err, ErrReceiptSignature) {
+		t.Errorf("tampered settlement: err = %v", err)
+	}
+	other, _, _ := ed25519.GenerateKey(nil)
+	if err := VerifyReceipt(other, receipt); !errors.Is(err, ErrReceiptNode) {
+		t.Errorf("other node's key: err = %v", err)
+	}
+}
+
+// recordingStorage passes stored impressions to a channel
+type recordingStorage chan *ImpressionRecord
+
+func (s recordingStorage) StoreImpression(imp *ImpressionRecord) error {
+	s <- imp
+	return nil
+}
+func (recordingStorage) GetImpression(id string) (*ImpressionRecord, error) { return nil, nil }
@@ -0,0 +1,247 @@
+package vast
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRewardMalformed  = errors.New("malformed reward token")
+	ErrRewardSignature  = errors.New("invalid reward token signature")
+	ErrRewardExpired    = errors.New("reward token expired")
+	ErrRewardBinding    = errors.New("reward token bound to a different impression or wallet")
+	ErrRewardUnknown    = errors.New("no reward pending for impression")
+	ErrRewardIncomplete = errors.New("rewarded video not watched to completion")
+	ErrRewardClaimed    = errors.New("reward already claimed")
+)
+
+// rewardQuartiles must all be beaconed before complete releases a reward
+var rewardQuartiles = map[string]uint8{
+	"start":         1 << 0,
+	"firstQuartile": 1 << 1,
+	"midpoint":      1 << 2,
+	"thirdQuartile": 1 << 3,
+}
+
+const allRewardQuartiles = 1<<4 - 1
+
+// RewardChallenge is the signed claim a rewarded impression is served with.
+// It is encoded as base64url(JSON) "." base64url(HMAC-SHA256) and travels
+// on the complete beacon, so only the viewer's wallet can be paid for it.
+type RewardChallenge struct {
+	ImpressionID string  `json:"imp"`
+	Wallet       string  `json:"wallet"`
+	ChainID      int     `json:"chain"`
+	Amount       float64 `json:"amount"`
+	Duration     int     `json:"dur"` // seconds
+	Expires      int64   `json:"exp"` // unix milliseconds
+}
+
+type rewardView struct {
+	seen    uint8
+	started time.Time
+	skipped bool
+	claimed bool
+	expires time.Time
+}
+
+// RewardManager issues completion challenges for rewarded video and pays
+// out through the BlockchainManager once a view is verifiably complete
+type RewardManager struct {
+	// Amount is paid per completed rewarded view
+	Amount float64
+
+	chain BlockchainManager
+	key   []byte
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	views map[string]*rewardView // by impression (bid) ID
+	now   func() time.Time
+}
+
+// NewRewardManager creates a manager signing challenges with key that
+// stay claimable for ttl
+func NewRewardManager(key []byte, chain BlockchainManager, amount float64, ttl time.Duration) *RewardManager {
+	return &RewardManager{
+		Amount: amount,
+		chain:  chain,
+		key:    key,
+		ttl:    ttl,
+		views:  make(map[string]*rewardView),
+		now:    time.Now,
+	}
+}
+
+// Issue signs a challenge for a rewarded impression of duration seconds
+func (m *RewardManager) Issue(impressionID, wallet string, chainID, duration int) (string, error) {
+	now := m.now()
+	payload, err := json.Marshal(RewardChallenge{
+		ImpressionID: impressionID,
+		Wallet:       wallet,
+		ChainID:      chainID,
+		Amount:       m.Amount,
+		Duration:     duration,
+		Expires:      now.Add(m.ttl).UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	m.prune(now)
+	m.views[impressionID] = &rewardView{expires: now.Add(m.ttl)}
+	m.mu.Unlock()
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(m.sign(payload)), nil
+}
+
+// Progress records a playback beacon for a rewarded impression. A skip
+// forfeits the reward.
+func (m *RewardManager) Progress(impressionID, event string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	view, ok := m.views[impressionID]
+	if !ok {
+		return
+	}
+	if event == "skip" {
+		view.skipped = true
+		return
+	}
+	bit, ok := rewardQuartiles[event]
+	if !ok {
+		return
+	}
+	if event == "start" && view.seen&bit == 0 {
+		view.started = m.now()
+	}
+	view.seen |= bit
+}
+
+// Complete verifies the token returned on the complete beacon for
+// impressionID and wallet and, if the whole ad played, pays the reward.
+// Each impression pays at most once.
+func (m *RewardManager) Complete(token, impressionID, wallet string) (*RewardChallenge, error) {
+	challenge, err := m.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.ImpressionID != impressionID || !strings.EqualFold(challenge.Wallet, wallet) {
+		return nil, ErrRewardBinding
+	}
+	now := m.now()
+	if now.After(time.UnixMilli(challenge.Expires)) {
+		return nil, ErrRewardExpired
+	}
+
+	m.mu.Lock()
+	view, ok := m.views[impressionID]
+	switch {
+	case !ok:
+		m.mu.Unlock()
+		return nil, ErrRewardUnknown
+	case view.claimed:
+		m.mu.Unlock()
+		return nil, ErrRewardClaimed
+	case view.skipped || view.seen != allRewardQuartiles ||
+		now.Sub(view.started) < time.Duration(challenge.Duration)*time.Second*9/10:
+		m.mu.Unlock()
+		return nil, ErrRewardIncomplete
+	}
+	view.claimed = true
+	m.mu.Unlock()
+
+	if err := m.chain.ProcessPayment(challenge.Wallet, challenge.Amount, challenge.ChainID); err != nil {
+		m.mu.Lock()
+		view.claimed = false
+		m.mu.Unlock()
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (m *RewardManager) verify(token string) (*RewardChallenge, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrRewardMalformed
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrRewardMalformed
+	}
+	sigBytes, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, ErrRewardMalformed
+	}
+	if !hmac.Equal(sigBytes, m.sign(payloadBytes)) {
+		return nil, ErrRewardSignature
+	}
+	var challenge RewardChallenge
+	if err := json.Unmarshal(payloadBytes, &challenge); err != nil {
+		return nil, ErrRewardMalformed
+	}
+	return &challenge, nil
+}
+
+func (m *RewardManager) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (m *RewardManager) prune(now time.Time) {
+	for id, view := range m.views {
+		if now.After(view.expires) {
+			delete(m.views, id)
+		}
+	}
+}
+
+// attachReward issues a completion challenge for rewarded requests and
+// puts it on the ad's complete beacon, bound to the viewer's wallet
+func (h *VASTHandler) attachReward(req *VASTRequest, bid *Bid, ad *Ad) {
+	if h.Rewards == nil || req.RV != "1" || req.WalletAddress == "" {
+		return
+	}
+	for i := range ad.InLine.Creatives.Creative {
+		linear := ad.InLine.Creatives.Creative[i].Linear
+		if linear == nil || linear.TrackingEvents == nil {
+			continue
+		}
+		token, err := h.Rewards.Issue(ImpressionKey(req.ServeID, bid.ID), req.WalletAddress, req.ChainID, parseDuration(linear.Duration))
+		if err != nil {
+			return
+		}
+		for j, t := range linear.TrackingEvents.Tracking {
+			if t.Event != "complete" {
+				continue
+			}
+			u := t.URL
+			if !strings.Contains(u, "&wallet=") {
+				u += "&wallet=" + url.QueryEscape(req.WalletAddress)
+			}
+			linear.TrackingEvents.Tracking[j].URL = u + "&rwd=" + token
+		}
+		return
+	}
+}
+
+// parseDuration reads a VAST HH:MM:SS[.mmm] duration in whole seconds
+func parseDuration(d string) int {
+	var h, m, s int
+	if _, err := fmt.Sscanf(d, "%d:%d:%d", &h, &m, &s); err != nil {
+		return 0
+	}
+	return h*3600 + m*60 + s
+}
@@ -0,0 +1,154 @@
+package vast
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type payment struct {
+	wallet  string
+	amount  float64
+	chainID int
+}
+
+// recordingChain is a BlockchainManager that records payouts
+type recordingChain struct {
+	payments []payment
+}
+
+func (c *recordingChain) RecordImpression(*ImpressionRecord, string, int) error { return nil }
+func (c *recordingChain) VerifyProofOfView(string, string, string) bool         { return false }
+func (c *recordingChain) ProcessPayment(wallet string, amount float64, chainID int) error {
+	c.payments = append(c.payments, payment{wallet, amount, chainID})
+	return nil
+}
+
+// newRewardedAd serves a rewarded ad and returns the manager's clock, the
+// chain and the complete beacon's reward token
+func newRewardedAd(t *testing.T, skip bool) (*RewardManager, *time.Time, *recordingChain, string) {
+	t.Helper()
+	chain := &recordingChain{}
+	rewards := NewRewardManager([]byte("test-key"), chain, 0.05, time.Hour)
+	clock := time.Unix(1_700_000_000, 0)
+	rewards.now = func() time.Time { return clock }
+
+	h := &VASTHandler{Rewards: rewards}
+	req := &VASTRequest{AppToken: "app", RV: "1", WalletAddress: "0xViewer", ChainID: 96369}
+	if skip {
+		req.Skip, req.SkipMin = 1, 5
+	}
+	resp := h.buildVASTResponse(req, &OpenRTBResponse{SeatBid: []SeatBid{{
+		Seat: "seat1",
+		Bid:  []Bid{{ID: "bid-1", ImpID: "1", ADomain: []string{"brand.example"}}},
+	}}})
+
+	var token string
+	for _, tr := range resp.Ads[0].InLine.Creatives.Creative[0].Linear.TrackingEvents.Tracking {
+		if tr.Event != "complete" {
+			continue
+		}
+		u, err := url.Parse(tr.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Query().Get("wallet") != "0xViewer" {
+			t.Errorf("complete beacon %q lacks the wallet", tr.URL)
+		}
+		token = u.Query().Get("rwd")
+	}
+	if token == "" {
+		t.Fatal("complete beacon has no reward token")
+	}
+	return rewards, &clock, chain, token
+}
+
+func TestReward_FullCompletionPays(t *testing.T) {
+	rewards, clock, chain, token := newRewardedAd(t, false)
+
+	for _, e := range []string{"start", "firstQuartile", "midpoint", "thirdQuartile"} {
+		rewards.Progress("bid-1", e)
+		*clock = clock.Add(8 * time.Second)
+	}
+
+	challenge, err := rewards.Complete(token, "bid-1", "0xViewer")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if challenge.Duration != 30 {
+		t.Errorf("duration = %d, want 30", challenge.Duration)
+	}
+	if len(chain.payments) != 1 || chain.payments[0] != (payment{"0xViewer", 0.05, 96369}) {
+		t.Errorf("payments = %+v", chain.payments)
+	}
+
+	if _, err := rewards.Complete(token, "bid-1", "0xViewer"); !errors.Is(err, ErrRewardClaimed) {
+		t.Errorf("second claim err = %v, want ErrRewardClaimed", err)
+	}
+	if len(chain.payments) != 1 {
+		t.Errorf("reward paid %d times", len(chain.payments))
+	}
+}
+
+func TestReward_PartialViewPaysNothing(t *testing.T) {
+	// Stopped after the midpoint
+	rewards, clock, chain, token := newRewardedAd(t, false)
+	rewards.Progress("bid-1", "start")
+	rewards.Progress("bid-1", "firstQuartile")
+	rewards.Progress("bid-1", "midpoint")
+	*clock = clock.Add(40 * time.Second)
+	if _, err := rewards.Complete(token, "bid-1", "0xViewer"); !errors.Is(err, ErrRewardIncomplete) {
+		t.Errorf("partial view err = %v, want ErrRewardIncomplete", err)
+	}
+
+	// Every beacon fired at once, faster than the ad can play
+	rewards, _, chain2, token := newRewardedAd(t, false)
+	for _, e := range []string{"start", "firstQuartile", "midpoint", "thirdQuartile"} {
+		rewards.Progress("bid-1", e)
+	}
+	if _, err := rewards.Complete(token, "bid-1", "0xViewer"); !errors.Is(err, ErrRewardIncomplete) {
+		t.Errorf("instant completion err = %v, want ErrRewardIncomplete", err)
+	}
+
+	// Skipped
+	rewards, clock, chain3, token := newRewardedAd(t, true)
+	for _, e := range []string{"start", "firstQuartile", "skip", "midpoint", "thirdQuartile"} {
+		rewards.Progress("bid-1", e)
+		*clock = clock.Add(10 * time.Second)
+	}
+	if _, err := rewards.Complete(token, "bid-1", "0xViewer"); !errors.Is(err, ErrRewardIncomplete) {
+		t.Errorf("skipped view err = %v, want ErrRewardIncomplete", err)
+	}
+
+	for _, c := range []*recordingChain{chain, chain2, chain3} {
+		if len(c.payments) != 0 {
+			t.Errorf("payments = %+v, want none", c.payments)
+		}
+	}
+}
+
+func TestReward_TokenBinding(t *testing.T) {
+	rewards, clock, chain, token := newRewardedAd(t, false)
+	for _, e := range []string{"start", "firstQuartile", "midpoint", "thirdQuartile"} {
+		rewards.Progress("bid-1", e)
+	}
+	*clock = clock.Add(30 * time.Second)
+
+	if _, err := rewards.Complete(token, "bid-1", "0xSomeoneElse"); !errors.Is(err, ErrRewardBinding) {
+		t.Errorf("shared token err = %v, want ErrRewardBinding", err)
+	}
+	if _, err := rewards.Complete(token, "bid-2", "0xViewer"); !errors.Is(err, ErrRewardBinding) {
+		t.Errorf("other impression err = %v, want ErrRewardBinding", err)
+	}
+	if _, err := rewards.Complete(token[:len(token)-2]+"AA", "bid-1", "0xViewer"); !errors.Is(err, ErrRewardSignature) {
+		t.Errorf("forged token err = %v, want ErrRewardSignature", err)
+	}
+	*clock = clock.Add(2 * time.Hour)
+	if _, err := rewards.Complete(token, "bid-1", "0xViewer"); !errors.Is(err, ErrRewardExpired) {
+		t.Errorf("expired token err = %v, want ErrRewardExpired", err)
+	}
+	if len(chain.payments) != 0 {
+		t.Errorf("payments = %+v, want none", chain.payments)
+	}
+}
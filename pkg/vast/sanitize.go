@@ -0,0 +1,101 @@
+package vast
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on DSP-supplied text copied into VAST documents
+const (
+	maxTitleLen      = 256
+	maxAdvertiserLen = 256
+)
+
+// sanitizeText makes a DSP-supplied string safe to place in a VAST element:
+// invalid UTF-8 and characters XML can't carry are dropped, whitespace runs
+// are collapsed and the result is cut to max runes. Markup characters are
+// left for encoding/xml to escape.
+func sanitizeText(s string, max int) string {
+	s = strings.ToValidUTF8(s, "")
+	var b strings.Builder
+	space := false
+	n := 0
+	for _, r := range s {
+		if n >= max {
+			break
+		}
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+			continue
+		}
+		if !xmlChar(r) || unicode.IsControl(r) {
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			n++
+			space = false
+			if n >= max {
+				break
+			}
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return b.String()
+}
+
+// xmlChar reports whether r may appear in an XML 1.0 document
+func xmlChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= utf8.MaxRune
+}
+
+// safeURL validates a DSP-supplied URL for a media file or click-through:
+// it must be absolute http or https. Bytes not allowed in a URL, such as
+// quotes, spaces and angle brackets, are percent-encoded so the URL can't
+// end its CDATA section or attribute early.
+func safeURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if urlByte(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xF])
+	}
+	return b.String(), true
+}
+
+// urlByte reports whether c is an RFC 3986 unreserved or reserved
+// character, or part of a percent-encoding
+func urlByte(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~:/?#[]@!$&'()*+,;=%", c) >= 0
+}
+
+// advertiser is the bid's first non-blank advertiser domain
+func advertiser(bid *Bid) string {
+	for _, d := range bid.ADomain {
+		if d = sanitizeText(d, maxAdvertiserLen); d != "" {
+			return d
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package vast
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// render marshals the ad as the handler would, and parses it back
+func render(t *testing.T, ad Ad) (string, *VAST) {
+	t.Helper()
+	out, err := xml.Marshal(&VAST{Version: "4.3", Ads: []Ad{ad}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed VAST
+	if err := xml.Unmarshal(out, &parsed); err != nil {
+		t.Fatalf("rendered VAST doesn't parse: %v\n%s", err, out)
+	}
+	if len(parsed.Ads) != 1 {
+		t.Fatalf("rendered %d ads, want 1\n%s", len(parsed.Ads), out)
+	}
+	return string(out), &parsed
+}
+
+func TestCreateVASTAd_EmptyADomain(t *testing.T) {
+	h := &VASTHandler{}
+	for _, domains := range [][]string{nil, {}, {"", " \t"}} {
+		bid := &Bid{ID: "b1", ImpID: "1", ADomain: domains, ADURL: "https://cdn.example/ad.mp4"}
+		ad := h.createVASTAd(&VASTRequest{AppToken: "app", AL: "m"}, bid)
+		if ad.InLine.AdTitle != "Video Ad" || ad.InLine.Advertiser != "" {
+			t.Errorf("adomain %q: title %q, advertiser %q", domains, ad.InLine.AdTitle, ad.InLine.Advertiser)
+		}
+		render(t, ad)
+	}
+}
+
+func TestCreateVASTAd_EscapesDomain(t *testing.T) {
+	h := &VASTHandler{}
+	domain := `evil.example</Advertiser><Impression><![CDATA[https://evil.example/px]]></Impression>&` + "\x00"
+	bid := &Bid{ID: "b1", ImpID: "1", ADomain: []string{domain}, ADURL: "https://cdn.example/ad.mp4"}
+	out, parsed := render(t, h.createVASTAd(&VASTRequest{AppToken: "app", AL: "m"}, bid))
+
+	inline := parsed.Ads[0].InLine
+	want := strings.TrimSuffix(domain, "\x00")
+	if inline.Advertiser != want || inline.AdTitle != want+" Video Ad" {
+		t.Errorf("advertiser %q, title %q, want the domain as text", inline.Advertiser, inline.AdTitle)
+	}
+	if len(inline.Impression) != 1 || strings.Contains(out, "evil.example/px]]>") {
+		t.Errorf("domain injected markup:\n%s", out)
+	}
+}
+
+func TestCreateVASTAd_EscapesURLs(t *testing.T) {
+	h := &VASTHandler{}
+	bid := &Bid{
+		ID:      "b1&event=complete",
+		ImpID:   "1",
+		CrID:    `cr"1`,
+		ADURL:   `https://cdn.example/ad.mp4?x=]]><Tracking event="start">https://evil.example</Tracking> y`,
+		NURL:    "javascript:alert(1)",
+		ADomain: []string{"brand.example"},
+	}
+	out, parsed := render(t, h.createVASTAd(&VASTRequest{AppToken: "app&zone=9", AL: "m"}, bid))
+
+	linear := parsed.Ads[0].InLine.Creatives.Creative[0].Linear
+	if len(linear.TrackingEvents.Tracking) != 5 || strings.Contains(out, "evil.example</Tracking>") {
+		t.Errorf("creative URL injected markup:\n%s", out)
+	}
+	media := linear.MediaFiles.MediaFile
+	if len(media) == 0 || media[0].URL != "https://cdn.example/ad.mp4?x=]]%3E%3CTracking%20event=%22start%22%3Ehttps://evil.example%3C/Tracking%3E%20y" {
+		t.Errorf("media files = %+v, want the creative URL percent-encoded", media)
+	}
+	if linear.VideoClicks.ClickThrough != nil {
+		t.Errorf("non-http click-through %q kept", linear.VideoClicks.ClickThrough.URL)
+	}
+
+	u, err := url.Parse(parsed.Ads[0].InLine.Impression[0].URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("event") != "impression" || q.Get("bid") != bid.ID || q.Get("crid") != bid.CrID || q.Get("app") != "app&zone=9" || q.Get("zone") != "0" {
+		t.Errorf("tracking query = %v, want each value intact", q)
+	}
+
+	bid.ADURL = "javascript:alert(1)"
+	if ad := h.createVASTAd(&VASTRequest{AppToken: "app", AL: "m"}, bid); len(ad.InLine.Creatives.Creative[0].Linear.MediaFiles.MediaFile) != 0 {
+		t.Error("non-http creative URL served")
+	}
+}
@@ -0,0 +1,379 @@
+package vast
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SupportedSKAdNVersions are the SKAdNetwork versions we can sign for
+var SupportedSKAdNVersions = []string{"2.0", "2.1", "2.2", "3.0", "4.0"}
+
+// AppleSKAdNKey is the public key Apple signs SKAdNetwork 2.0 and later
+// install postbacks with
+const AppleSKAdNKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEWdp8GPcGqmhgzEFj9Z2nSpQVddayaPe4FMzqM9wib1+aHaaIzoHoLN9zW4K8y4SPykE3YVK3sVqW6Af0lfx3gg=="
+
+// SKAdNFidelityViewThrough is the fidelity type of a view-through ad,
+// which every VAST ad is
+const SKAdNFidelityViewThrough = 0
+
+// Postbacks are kept to dedupe Apple's retries for
+const (
+	DefaultSKAdNPostbackTTL  = 7 * 24 * time.Hour
+	DefaultSKAdNMaxPostbacks = 100000
+)
+
+var (
+	ErrSKAdNVersion        = errors.New("unsupported SKAdNetwork version")
+	ErrSKAdNNoNetwork      = errors.New("no SKAdNetwork ID shared by publisher and bidder")
+	ErrSKAdNInvalidPayload = errors.New("invalid SKAdNetwork postback")
+)
+
+// ValidSKAdNVersion reports whether version is one we support
+func ValidSKAdNVersion(version string) bool {
+	for _, v := range SupportedSKAdNVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// SKAdNExtension carries signed SKAdNetwork parameters for the winning bid
+type SKAdNExtension struct {
+	Version          string `xml:"version,attr" json:"version"`
+	Network          string `xml:"Network" json:"network"`
+	Campaign         string `xml:"Campaign,omitempty" json:"campaign,omitempty"`                 // Before 4.0
+	SourceIdentifier string `xml:"SourceIdentifier,omitempty" json:"sourceidentifier,omitempty"` // From 4.0
+	ITunesItem       string `xml:"ITunesItem" json:"itunesitem"`
+	Nonce            string `xml:"Nonce" json:"nonce"`
+	SourceApp        string `xml:"SourceApp" json:"sourceapp"`
+	FidelityType     int    `xml:"FidelityType" json:"fidelitytype"`
+	Timestamp        string `xml:"Timestamp" json:"timestamp"`
+	Signature        string `xml:"Signature" json:"signature"`
+}
+
+// SKAdNPostback is the install-validation postback Apple sends to the ad
+// network (field names as documented for SKAdNetwork 2.0-4.0)
+type SKAdNPostback struct {
+	Version               string    `json:"version"`
+	AdNetworkID           string    `json:"ad-network-id"`
+	CampaignID            int       `json:"campaign-id,omitempty"`
+	SourceIdentifier      string    `json:"source-identifier,omitempty"`
+	TransactionID         string    `json:"transaction-id"`
+	AppID                 int64     `json:"app-id"`
+	AttributionSignature  string    `json:"attribution-signature"`
+	Redownload            bool      `json:"redownload"`
+	SourceAppID           int64     `json:"source-app-id,omitempty"`
+	SourceDomain          string    `json:"source-domain,omitempty"`
+	FidelityType          int       `json:"fidelity-type,omitempty"`
+	ConversionValue       *int      `json:"conversion-value,omitempty"`
+	DidWin                *bool     `json:"did-win,omitempty"`
+	PostbackSequenceIndex int       `json:"postback-sequence-index,omitempty"`
+	ReceivedAt            time.Time `json:"received_at"`
+}
+
+// SKAdNetworkManager selects and signs SKAdNetwork parameters and records
+// conversion postbacks
+type SKAdNetworkManager struct {
+	// AppleKey verifies postbacks' attribution signatures
+	AppleKey *ecdsa.PublicKey
+
+	mu sync.RWMutex
+
+	// seatNetworks maps a bidder seat to the SKAdNetwork IDs it is
+	// registered under
+	seatNetworks map[string][]string
+	signingKey   *ecdsa.PrivateKey
+
+	postbacks    map[string]*SKAdNPostback // by transaction ID
+	order        []string                  // Postback transaction IDs, oldest first
+	postbackTTL  time.Duration
+	maxPostbacks int
+	now          func() time.Time
+}
+
+// appleSKAdNKey is AppleSKAdNKey parsed
+var appleSKAdNKey = func() *ecdsa.PublicKey {
+	der, err := base64.StdEncoding.DecodeString(AppleSKAdNKey)
+	if err != nil {
+		panic(err)
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		panic(err)
+	}
+	return key.(*ecdsa.PublicKey)
+}()
+
+// NewSKAdNetworkManager creates a manager that signs with key and checks
+// postbacks against Apple's key
+func NewSKAdNetworkManager(key *ecdsa.PrivateKey) *SKAdNetworkManager {
+	return &SKAdNetworkManager{
+		AppleKey:     appleSKAdNKey,
+		seatNetworks: make(map[string][]string),
+		signingKey:   key,
+		postbacks:    make(map[string]*SKAdNPostback),
+		postbackTTL:  DefaultSKAdNPostbackTTL,
+		maxPostbacks: DefaultSKAdNMaxPostbacks,
+		now:          time.Now,
+	}
+}
+
+// RegisterSeat records the SKAdNetwork IDs a bidder seat supports
+func (m *SKAdNetworkManager) RegisterSeat(seat string, networkIDs []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seatNetworks[seat] = networkIDs
+}
+
+// SelectNetwork picks the first of the publisher's declared IDs that the
+// seat also supports, so publisher priority order is respected
+func (m *SKAdNetworkManager) SelectNetwork(seat string, publisherIDs []string) (string, error) {
+	m.mu.RLock()
+	supported := m.seatNetworks[seat]
+	m.mu.RUnlock()
+
+	for _, pub := range publisherIDs {
+		for _, id := range supported {
+			if strings.EqualFold(pub, id) {
+				return id, nil
+			}
+		}
+	}
+	return "", ErrSKAdNNoNetwork
+}
+
+// BuildExtension selects a network for the winning seat and returns signed
+// parameters for the ad
+func (m *SKAdNetworkManager) BuildExtension(req *VASTRequest, seat string, bid *Bid) (*SKAdNExtension, error) {
+	if !ValidSKAdNVersion(req.SKAdNVersion) {
+		return nil, ErrSKAdNVersion
+	}
+	network, err := m.SelectNetwork(seat, req.SKAdNetIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := &SKAdNExtension{
+		Version:      req.SKAdNVersion,
+		Network:      network,
+		ITunesItem:   bid.Bundle,
+		Nonce:        uuid.New().String(),
+		SourceApp:    req.SKAdNSourceApp,
+		FidelityType: SKAdNFidelityViewThrough,
+		Timestamp:    strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}
+	if ext.Version == "4.0" {
+		ext.SourceIdentifier = skadnSourceIdentifier(bid.CID)
+	} else {
+		ext.Campaign = bid.CID
+	}
+	if err := m.sign(ext); err != nil {
+		return nil, err
+	}
+	return ext, nil
+}
+
+// sign signs the fields the ad's version has Apple verify, in order,
+// joined by the invisible separator U+2063
+func (m *SKAdNetworkManager) sign(ext *SKAdNExtension) error {
+	if m.signingKey == nil {
+		return nil
+	}
+	digest := sha256.Sum256([]byte(skadnSignedFields(ext)))
+	sig, err := ecdsa.SignASN1(rand.Reader, m.signingKey, digest[:])
+	if err != nil {
+		return err
+	}
+	ext.Signature = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// VerifyExtension checks an extension's signature against the manager key
+func (m *SKAdNetworkManager) VerifyExtension(ext *SKAdNExtension) bool {
+	if m.signingKey == nil {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(ext.Signature)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(skadnSignedFields(ext)))
+	return ecdsa.VerifyASN1(&m.signingKey.PublicKey, digest[:], sig)
+}
+
+// skadnSignedFields is an ad's signed fields: 4.0 swaps the campaign for
+// a source identifier, and 2.2 on sign the fidelity type
+func skadnSignedFields(ext *SKAdNExtension) string {
+	fields := []string{ext.Version, ext.Network}
+	if ext.Version == "4.0" {
+		fields = append(fields, ext.SourceIdentifier)
+	} else {
+		fields = append(fields, ext.Campaign)
+	}
+	fields = append(fields, ext.ITunesItem, ext.Nonce, ext.SourceApp)
+	if ext.Version != "2.0" && ext.Version != "2.1" {
+		fields = append(fields, strconv.Itoa(ext.FidelityType))
+	}
+	fields = append(fields, ext.Timestamp)
+	return strings.Join(fields, "\u2063")
+}
+
+// skadnPostbackFields is what Apple signed a postback over, which grows
+// by version as skadnSignedFields does. Source app and domain are only
+// there when the postback carries them.
+func skadnPostbackFields(pb *SKAdNPostback) string {
+	fields := []string{pb.Version, pb.AdNetworkID}
+	if pb.Version == "4.0" {
+		fields = append(fields, pb.SourceIdentifier)
+	} else {
+		fields = append(fields, strconv.Itoa(pb.CampaignID))
+	}
+	fields = append(fields, strconv.FormatInt(pb.AppID, 10), pb.TransactionID, strconv.FormatBool(pb.Redownload))
+	switch {
+	case pb.SourceAppID != 0:
+		fields = append(fields, strconv.FormatInt(pb.SourceAppID, 10))
+	case pb.SourceDomain != "":
+		fields = append(fields, pb.SourceDomain)
+	}
+	if pb.Version != "2.0" && pb.Version != "2.1" {
+		fields = append(fields, strconv.Itoa(pb.FidelityType))
+	}
+	if pb.Version == "3.0" || pb.Version == "4.0" {
+		fields = append(fields, strconv.FormatBool(pb.DidWin != nil && *pb.DidWin))
+	}
+	if pb.Version == "4.0" {
+		fields = append(fields, strconv.Itoa(pb.PostbackSequenceIndex))
+	}
+	return strings.Join(fields, "\u2063")
+}
+
+// skadnSourceIdentifier is the 4.0 source identifier for a campaign,
+// which Apple caps at four digits
+func skadnSourceIdentifier(campaign string) string {
+	if n, err := strconv.Atoi(campaign); err == nil && n >= 0 && n <= 9999 {
+		return campaign
+	}
+	h := fnv.New32a()
+	h.Write([]byte(campaign))
+	return strconv.Itoa(int(h.Sum32() % 10000))
+}
+
+// verifyPostback checks a postback's attribution signature against
+// Apple's key
+func (m *SKAdNetworkManager) verifyPostback(pb *SKAdNPostback) bool {
+	if m.AppleKey == nil {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(pb.AttributionSignature)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(skadnPostbackFields(pb)))
+	return ecdsa.VerifyASN1(m.AppleKey, digest[:], sig)
+}
+
+// RecordPostback validates and stores a conversion postback. Postbacks are
+// keyed by transaction ID since Apple may retry delivery; a retry of one
+// already recorded is a no-op. They're kept for the postback TTL, and the
+// oldest dropped beyond the cap.
+func (m *SKAdNetworkManager) RecordPostback(pb *SKAdNPostback) error {
+	if pb.TransactionID == "" || pb.AdNetworkID == "" || pb.AttributionSignature == "" {
+		return ErrSKAdNInvalidPayload
+	}
+	if !ValidSKAdNVersion(pb.Version) {
+		return ErrSKAdNVersion
+	}
+	if !m.verifyPostback(pb) {
+		return fmt.Errorf("%w: attribution signature doesn't verify", ErrSKAdNInvalidPayload)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.evictPostbacks(now)
+	if _, ok := m.postbacks[pb.TransactionID]; ok {
+		return nil
+	}
+	pb.ReceivedAt = now
+	m.postbacks[pb.TransactionID] = pb
+	m.order = append(m.order, pb.TransactionID)
+	return nil
+}
+
+// evictPostbacks drops postbacks past their TTL, then the oldest while
+// there's no room for another; m.mu must be held
+func (m *SKAdNetworkManager) evictPostbacks(now time.Time) {
+	for len(m.order) > 0 {
+		id := m.order[0]
+		if len(m.postbacks) < m.maxPostbacks && now.Sub(m.postbacks[id].ReceivedAt) < m.postbackTTL {
+			return
+		}
+		delete(m.postbacks, id)
+		m.order = m.order[1:]
+	}
+}
+
+// Postback returns a recorded postback by transaction ID
+func (m *SKAdNetworkManager) Postback(transactionID string) (*SKAdNPostback, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pb, ok := m.postbacks[transactionID]
+	if !ok || m.now().Sub(pb.ReceivedAt) >= m.postbackTTL {
+		return nil, false
+	}
+	return pb, true
+}
+
+// HandleSKAdNPostback receives SKAdNetwork install postbacks
+func (h *VASTHandler) HandleSKAdNPostback(c *gin.Context) {
+	if h.SKAdN == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SKAdNetwork not enabled"})
+		return
+	}
+
+	var pb SKAdNPostback
+	if err := c.ShouldBindJSON(&pb); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.SKAdN.RecordPostback(&pb); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "recorded"})
+}
+
+// attachSKAdN adds signed SKAdNetwork parameters to an ad when the request
+// and the winning seat agree on a network
+func (h *VASTHandler) attachSKAdN(req *VASTRequest, seat string, bid *Bid, ad *Ad) {
+	if h.SKAdN == nil || req.SKAdNVersion == "" {
+		return
+	}
+	ext, err := h.SKAdN.BuildExtension(req, seat, bid)
+	if err != nil {
+		return
+	}
+	if ad.InLine.Extensions == nil {
+		ad.InLine.Extensions = &Extensions{}
+	}
+	ad.InLine.Extensions.Extension = append(ad.InLine.Extensions.Extension, Extension{
+		Type:  "SKAdNetwork",
+		SKAdN: ext,
+	})
+}
@@ -0,0 +1,248 @@
+package vast
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestSKAdN(t *testing.T) *SKAdNetworkManager {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	m := NewSKAdNetworkManager(key)
+	m.RegisterSeat("seat1", []string{"cstr6suwn9.skadnetwork", "4fzdc2evr5.skadnetwork"})
+	return m
+}
+
+func TestValidSKAdNVersion(t *testing.T) {
+	for _, v := range []string{"2.0", "2.2", "3.0", "4.0"} {
+		if !ValidSKAdNVersion(v) {
+			t.Errorf("%s should be supported", v)
+		}
+	}
+	for _, v := range []string{"", "1.0", "4.1", "5.0", "latest"} {
+		if ValidSKAdNVersion(v) {
+			t.Errorf("%s should not be supported", v)
+		}
+	}
+}
+
+func TestSKAdNetworkManager_BuildExtension(t *testing.T) {
+	m := newTestSKAdN(t)
+	bid := &Bid{CID: "42", Bundle: "1234567890"}
+
+	req := &VASTRequest{
+		SKAdNVersion:   "4.0",
+		SKAdNSourceApp: "880047117",
+		SKAdNetIDs:     []string{"other.skadnetwork", "4fzdc2evr5.skadnetwork"},
+	}
+	ext, err := m.BuildExtension(req, "seat1", bid)
+	if err != nil {
+		t.Fatalf("BuildExtension: %v", err)
+	}
+	if ext.Network != "4fzdc2evr5.skadnetwork" {
+		t.Errorf("network = %q", ext.Network)
+	}
+	if ext.SourceIdentifier != "42" || ext.Campaign != "" {
+		t.Errorf("4.0 extension campaign = %q, source identifier = %q", ext.Campaign, ext.SourceIdentifier)
+	}
+	if ext.Signature == "" || !m.VerifyExtension(ext) {
+		t.Error("extension signature should verify")
+	}
+	for name, tamper := range map[string]func(*SKAdNExtension){
+		"source identifier": func(e *SKAdNExtension) { e.SourceIdentifier = "43" },
+		"fidelity type":     func(e *SKAdNExtension) { e.FidelityType = 1 },
+	} {
+		tampered := *ext
+		tamper(&tampered)
+		if m.VerifyExtension(&tampered) {
+			t.Errorf("extension with tampered %s should not verify", name)
+		}
+	}
+
+	// Earlier versions sign the campaign instead
+	req.SKAdNVersion = "3.0"
+	ext, err = m.BuildExtension(req, "seat1", bid)
+	if err != nil {
+		t.Fatalf("BuildExtension: %v", err)
+	}
+	if ext.Campaign != "42" || !m.VerifyExtension(ext) {
+		t.Errorf("3.0 extension = %+v", ext)
+	}
+	ext.Campaign = "43"
+	if m.VerifyExtension(ext) {
+		t.Error("tampered extension should not verify")
+	}
+}
+
+// signPostback signs pb as Apple would with key
+func signPostback(t *testing.T, key *ecdsa.PrivateKey, pb *SKAdNPostback) {
+	t.Helper()
+	digest := sha256.Sum256([]byte(skadnPostbackFields(pb)))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb.AttributionSignature = base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestSKAdNetworkManager_PostbackSignature(t *testing.T) {
+	apple, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestSKAdN(t)
+	m.AppleKey = &apple.PublicKey
+
+	won := true
+	for _, pb := range []*SKAdNPostback{
+		{Version: "2.1", AdNetworkID: "cstr6suwn9.skadnetwork", CampaignID: 42, TransactionID: "t-21", AppID: 525463029, SourceAppID: 880047117},
+		{Version: "3.0", AdNetworkID: "cstr6suwn9.skadnetwork", CampaignID: 42, TransactionID: "t-30", AppID: 525463029, FidelityType: 1, DidWin: &won},
+		{Version: "4.0", AdNetworkID: "cstr6suwn9.skadnetwork", SourceIdentifier: "5239", TransactionID: "t-40", AppID: 525463029,
+			SourceDomain: "example.com", FidelityType: 1, DidWin: &won, PostbackSequenceIndex: 2},
+	} {
+		signPostback(t, apple, pb)
+		if err := m.RecordPostback(pb); err != nil {
+			t.Errorf("%s postback: %v", pb.Version, err)
+		}
+
+		forged := *pb
+		forged.TransactionID += "-forged"
+		forged.Redownload = true
+		if err := m.RecordPostback(&forged); !errors.Is(err, ErrSKAdNInvalidPayload) {
+			t.Errorf("tampered %s postback: %v", pb.Version, err)
+		}
+	}
+
+	// Apple's own key is the default
+	if NewSKAdNetworkManager(nil).AppleKey == nil {
+		t.Error("no default Apple key")
+	}
+}
+
+func TestSKAdNetworkManager_PostbackEviction(t *testing.T) {
+	apple, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestSKAdN(t)
+	m.AppleKey = &apple.PublicKey
+	m.maxPostbacks = 2
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	record := func(id string) {
+		t.Helper()
+		pb := &SKAdNPostback{Version: "3.0", AdNetworkID: "cstr6suwn9.skadnetwork", CampaignID: 1, TransactionID: id, AppID: 1}
+		signPostback(t, apple, pb)
+		if err := m.RecordPostback(pb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record("a")
+	record("b")
+	record("c")
+	if _, ok := m.Postback("a"); ok {
+		t.Error("oldest postback kept beyond the cap")
+	}
+	if len(m.postbacks) != 2 {
+		t.Errorf("%d postbacks kept, want 2", len(m.postbacks))
+	}
+
+	now = now.Add(DefaultSKAdNPostbackTTL)
+	if _, ok := m.Postback("c"); ok {
+		t.Error("postback returned past its TTL")
+	}
+	record("d")
+	if len(m.postbacks) != 1 {
+		t.Errorf("%d postbacks kept after expiry, want 1", len(m.postbacks))
+	}
+}
+
+func TestSKAdNetworkManager_VersionMismatch(t *testing.T) {
+	m := newTestSKAdN(t)
+	req := &VASTRequest{
+		SKAdNVersion: "1.0",
+		SKAdNetIDs:   []string{"cstr6suwn9.skadnetwork"},
+	}
+	if _, err := m.BuildExtension(req, "seat1", &Bid{}); err != ErrSKAdNVersion {
+		t.Errorf("err = %v, want %v", err, ErrSKAdNVersion)
+	}
+
+	// Unsupported versions aren't forwarded to bidders either
+	h := &VASTHandler{}
+	req.AL, req.AdCount = "l", 1
+	if rtbReq := h.buildOpenRTBRequest(req); rtbReq.Source.SKAdN != nil {
+		t.Error("SKAdN should be omitted for an unsupported version")
+	}
+}
+
+func TestSKAdNetworkManager_NoCommonNetwork(t *testing.T) {
+	m := newTestSKAdN(t)
+	req := &VASTRequest{
+		SKAdNVersion: "3.0",
+		SKAdNetIDs:   []string{"unknown.skadnetwork"},
+	}
+	if _, err := m.BuildExtension(req, "seat1", &Bid{}); err != ErrSKAdNNoNetwork {
+		t.Errorf("err = %v, want %v", err, ErrSKAdNNoNetwork)
+	}
+	if _, err := m.BuildExtension(req, "unregistered", &Bid{}); err != ErrSKAdNNoNetwork {
+		t.Errorf("err = %v, want %v", err, ErrSKAdNNoNetwork)
+	}
+
+	// The ad is still served, just without the SKAdNetwork extension
+	h := &VASTHandler{SKAdN: m}
+	ad := h.createVASTAd(req, &Bid{ID: "b1", ADomain: []string{"example.com"}})
+	h.attachSKAdN(req, "seat1", &Bid{}, &ad)
+	if ad.InLine.Extensions != nil {
+		t.Error("no extension expected without a shared network")
+	}
+}
+
+func TestHandleSKAdNPostback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	apple, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &VASTHandler{SKAdN: newTestSKAdN(t)}
+	h.SKAdN.AppleKey = &apple.PublicKey
+	router := gin.New()
+	router.POST("/skadn/postback", h.HandleSKAdNPostback)
+
+	pb := &SKAdNPostback{Version: "3.0", AdNetworkID: "cstr6suwn9.skadnetwork", CampaignID: 42,
+		TransactionID: "6aafb7a5-0170-41b5-bbe4-fe71dedf1e28", AppID: 525463029}
+	signPostback(t, apple, pb)
+	body := fmt.Sprintf(`{"version":"3.0","ad-network-id":"cstr6suwn9.skadnetwork","campaign-id":42,
+		"transaction-id":"6aafb7a5-0170-41b5-bbe4-fe71dedf1e28","app-id":525463029,
+		"attribution-signature":%q,"redownload":false,"conversion-value":20}`, pb.AttributionSignature)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/skadn/postback", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	pb, ok := h.SKAdN.Postback("6aafb7a5-0170-41b5-bbe4-fe71dedf1e28")
+	if !ok || pb.CampaignID != 42 || pb.ConversionValue == nil || *pb.ConversionValue != 20 {
+		t.Errorf("postback = %+v", pb)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/skadn/postback", strings.NewReader(`{"version":"3.0"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for incomplete postback", w.Code)
+	}
+}
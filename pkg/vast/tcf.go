@@ -0,0 +1,207 @@
+package vast
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TCF v2 purposes we gate on
+const (
+	TCFPurposeStoreAccess          = 1 // Store and/or access information on a device
+	TCFPurposePersonalisedProfile  = 3 // Create a personalised ads profile
+	TCFPurposeSelectPersonalisedAd = 4 // Select personalised ads
+)
+
+var (
+	ErrTCFEmpty      = errors.New("empty TCF consent string")
+	ErrTCFEncoding   = errors.New("invalid TCF base64 encoding")
+	ErrTCFVersion    = errors.New("unsupported TCF version")
+	ErrTCFTruncated  = errors.New("truncated TCF consent string")
+	ErrTCFBadVendors = errors.New("invalid TCF vendor section")
+)
+
+// TCFConsent is the decoded core segment of an IAB TCF v2 consent string
+type TCFConsent struct {
+	Version           int
+	Created           time.Time
+	LastUpdated       time.Time
+	CMPID             int
+	CMPVersion        int
+	ConsentScreen     int
+	ConsentLanguage   string
+	VendorListVersion int
+	PolicyVersion     int
+	IsServiceSpecific bool
+	PublisherCC       string
+
+	SpecialFeatureOptIns  map[int]bool
+	PurposeConsents       map[int]bool
+	PurposeLegitimateInts map[int]bool
+	VendorConsents        map[int]bool
+	VendorLegitimateInts  map[int]bool
+}
+
+// ParseTCF decodes the core segment of a TCF v2 consent string. Other
+// segments (disclosed vendors, publisher TC) are ignored.
+func ParseTCF(consent string) (*TCFConsent, error) {
+	if consent == "" {
+		return nil, ErrTCFEmpty
+	}
+	core := strings.SplitN(consent, ".", 2)[0]
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(core, "="))
+	if err != nil {
+		return nil, ErrTCFEncoding
+	}
+
+	r := &bitReader{data: data}
+	tc := &TCFConsent{}
+
+	tc.Version = r.readInt(6)
+	if r.err == nil && tc.Version != 2 {
+		return nil, ErrTCFVersion
+	}
+	tc.Created = r.readTime()
+	tc.LastUpdated = r.readTime()
+	tc.CMPID = r.readInt(12)
+	tc.CMPVersion = r.readInt(12)
+	tc.ConsentScreen = r.readInt(6)
+	tc.ConsentLanguage = r.readLetters()
+	tc.VendorListVersion = r.readInt(12)
+	tc.PolicyVersion = r.readInt(6)
+	tc.IsServiceSpecific = r.readBool()
+	r.readBool() // UseNonStandardStacks
+	tc.SpecialFeatureOptIns = r.readBitField(12)
+	tc.PurposeConsents = r.readBitField(24)
+	tc.PurposeLegitimateInts = r.readBitField(24)
+	r.readBool() // PurposeOneTreatment
+	tc.PublisherCC = r.readLetters()
+	tc.VendorConsents = r.readVendorSection()
+	tc.VendorLegitimateInts = r.readVendorSection()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return tc, nil
+}
+
+// PurposeAllowed reports whether the user consented to purpose
+func (tc *TCFConsent) PurposeAllowed(purpose int) bool {
+	return tc.PurposeConsents[purpose]
+}
+
+// VendorAllowed reports whether the user consented to vendorID
+func (tc *TCFConsent) VendorAllowed(vendorID int) bool {
+	return tc.VendorConsents[vendorID]
+}
+
+// Allows reports whether vendorID may process data for purpose. A zero
+// vendorID only checks the purpose.
+func (tc *TCFConsent) Allows(vendorID, purpose int) bool {
+	if !tc.PurposeAllowed(purpose) {
+		return false
+	}
+	return vendorID == 0 || tc.VendorAllowed(vendorID)
+}
+
+// applyConsent strips identifiers from the bid request that the TCF string
+// doesn't permit us to use. An unparsable string is treated as no consent.
+func (h *VASTHandler) applyConsent(req *VASTRequest, rtb *OpenRTBRequest) {
+	if req.GDPR != 1 {
+		return
+	}
+	rtb.User.Consent = req.UserConsent
+
+	tc, err := ParseTCF(req.UserConsent)
+	if err != nil {
+		tc = &TCFConsent{}
+	}
+
+	if !tc.Allows(h.VendorID, TCFPurposeStoreAccess) {
+		rtb.Device.IFA = ""
+	}
+	if !tc.Allows(h.VendorID, TCFPurposePersonalisedProfile) {
+		rtb.User.ID = ""
+		rtb.User.BuyerUID = ""
+		rtb.User.YOB = 0
+		rtb.User.Gender = ""
+		rtb.User.Data = nil
+	}
+}
+
+// bitReader reads big-endian bit fields; the first error is sticky
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bitReader) readInt(bits int) int {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+bits > len(r.data)*8 {
+		r.err = ErrTCFTruncated
+		return 0
+	}
+	v := 0
+	for i := 0; i < bits; i++ {
+		b := r.data[r.pos/8] >> (7 - uint(r.pos%8)) & 1
+		v = v<<1 | int(b)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) readBool() bool {
+	return r.readInt(1) == 1
+}
+
+// readTime reads deciseconds since the epoch
+func (r *bitReader) readTime() time.Time {
+	ds := r.readInt(36)
+	return time.UnixMilli(int64(ds) * 100).UTC()
+}
+
+// readLetters reads a two-letter code, six bits per letter from 'A'
+func (r *bitReader) readLetters() string {
+	a, b := r.readInt(6), r.readInt(6)
+	return string([]byte{byte('A' + a), byte('A' + b)})
+}
+
+func (r *bitReader) readBitField(n int) map[int]bool {
+	set := make(map[int]bool)
+	for i := 1; i <= n; i++ {
+		if r.readBool() {
+			set[i] = true
+		}
+	}
+	return set
+}
+
+func (r *bitReader) readVendorSection() map[int]bool {
+	maxVendorID := r.readInt(16)
+	if !r.readBool() {
+		return r.readBitField(maxVendorID)
+	}
+
+	set := make(map[int]bool)
+	entries := r.readInt(12)
+	for i := 0; i < entries && r.err == nil; i++ {
+		isRange := r.readBool()
+		start := r.readInt(16)
+		end := start
+		if isRange {
+			end = r.readInt(16)
+		}
+		if r.err == nil && (start == 0 || end < start || end > maxVendorID) {
+			r.err = ErrTCFBadVendors
+			break
+		}
+		for v := start; v <= end; v++ {
+			set[v] = true
+		}
+	}
+	return set
+}
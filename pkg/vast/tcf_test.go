@@ -0,0 +1,222 @@
+package vast
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+// bitWriter builds TCF strings field by field for tests
+type bitWriter struct {
+	bits []bool
+}
+
+func (w *bitWriter) writeInt(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bits = append(w.bits, v>>uint(i)&1 == 1)
+	}
+}
+
+func (w *bitWriter) writeBool(b bool) {
+	w.bits = append(w.bits, b)
+}
+
+func (w *bitWriter) writeSet(set map[int]bool, n int) {
+	for i := 1; i <= n; i++ {
+		w.writeBool(set[i])
+	}
+}
+
+func (w *bitWriter) encode() string {
+	out := make([]byte, (len(w.bits)+7)/8)
+	for i, b := range w.bits {
+		if b {
+			out[i/8] |= 1 << (7 - uint(i%8))
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(out)
+}
+
+type tcfSpec struct {
+	purposes    map[int]bool
+	vendors     map[int]bool
+	maxVendorID int
+	ranges      [][2]int // when set, vendors are range encoded
+}
+
+func buildTCF(spec tcfSpec) string {
+	w := &bitWriter{}
+	w.writeInt(2, 6)            // Version
+	w.writeInt(16000000000, 36) // Created
+	w.writeInt(16000000000, 36) // LastUpdated
+	w.writeInt(7, 12)           // CmpId
+	w.writeInt(1, 12)           // CmpVersion
+	w.writeInt(1, 6)            // ConsentScreen
+	w.writeInt(4, 6)            // 'E'
+	w.writeInt(13, 6)           // 'N'
+	w.writeInt(150, 12)         // VendorListVersion
+	w.writeInt(2, 6)            // TcfPolicyVersion
+	w.writeBool(false)          // IsServiceSpecific
+	w.writeBool(false)          // UseNonStandardStacks
+	w.writeInt(0, 12)           // SpecialFeatureOptIns
+	w.writeSet(spec.purposes, 24)
+	w.writeInt(0, 24)  // PurposesLITransparency
+	w.writeBool(false) // PurposeOneTreatment
+	w.writeInt(3, 6)   // 'D'
+	w.writeInt(4, 6)   // 'E'
+
+	// Vendor consent section
+	w.writeInt(spec.maxVendorID, 16)
+	if spec.ranges != nil {
+		w.writeBool(true)
+		w.writeInt(len(spec.ranges), 12)
+		for _, r := range spec.ranges {
+			if r[0] == r[1] {
+				w.writeBool(false)
+				w.writeInt(r[0], 16)
+			} else {
+				w.writeBool(true)
+				w.writeInt(r[0], 16)
+				w.writeInt(r[1], 16)
+			}
+		}
+	} else {
+		w.writeBool(false)
+		w.writeSet(spec.vendors, spec.maxVendorID)
+	}
+
+	// Empty vendor legitimate interest section
+	w.writeInt(0, 16)
+	w.writeBool(false)
+
+	return w.encode()
+}
+
+func TestParseTCF(t *testing.T) {
+	consent := buildTCF(tcfSpec{
+		purposes:    map[int]bool{1: true, 2: true, 3: true, 4: true},
+		vendors:     map[int]bool{10: true, 755: true},
+		maxVendorID: 755,
+	})
+
+	tc, err := ParseTCF(consent + ".YAAAAAAAAAAA") // trailing segment is ignored
+	if err != nil {
+		t.Fatalf("ParseTCF: %v", err)
+	}
+	if tc.Version != 2 || tc.CMPID != 7 || tc.VendorListVersion != 150 {
+		t.Errorf("header = v%d cmp %d gvl %d", tc.Version, tc.CMPID, tc.VendorListVersion)
+	}
+	if tc.ConsentLanguage != "EN" || tc.PublisherCC != "DE" {
+		t.Errorf("language %q, publisher cc %q", tc.ConsentLanguage, tc.PublisherCC)
+	}
+	if !tc.PurposeAllowed(1) || !tc.PurposeAllowed(3) || tc.PurposeAllowed(5) {
+		t.Errorf("purposes = %v", tc.PurposeConsents)
+	}
+	if !tc.VendorAllowed(755) || !tc.VendorAllowed(10) || tc.VendorAllowed(11) {
+		t.Errorf("vendors = %v", tc.VendorConsents)
+	}
+}
+
+func TestParseTCF_RangeEncoding(t *testing.T) {
+	consent := buildTCF(tcfSpec{
+		purposes:    map[int]bool{1: true},
+		maxVendorID: 800,
+		ranges:      [][2]int{{5, 5}, {700, 800}},
+	})
+
+	tc, err := ParseTCF(consent)
+	if err != nil {
+		t.Fatalf("ParseTCF: %v", err)
+	}
+	for _, v := range []int{5, 700, 755, 800} {
+		if !tc.VendorAllowed(v) {
+			t.Errorf("vendor %d should be allowed", v)
+		}
+	}
+	if tc.VendorAllowed(6) || tc.VendorAllowed(699) {
+		t.Error("vendors outside ranges should not be allowed")
+	}
+}
+
+func TestParseTCF_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":      "",
+		"not base64": "!!!",
+		"truncated":  "CPXxRfA",
+		"version 1":  "BOEFEAyOEFEAyAHABDENAI4AAAB9vABAASA",
+	}
+	for name, consent := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseTCF(consent); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestApplyConsent(t *testing.T) {
+	const vendorID = 755
+
+	tests := []struct {
+		name     string
+		consent  string
+		wantIFA  bool
+		wantUser bool
+	}{
+		{
+			name: "full consent",
+			consent: buildTCF(tcfSpec{
+				purposes:    map[int]bool{1: true, 3: true, 4: true},
+				vendors:     map[int]bool{vendorID: true},
+				maxVendorID: vendorID,
+			}),
+			wantIFA:  true,
+			wantUser: true,
+		},
+		{
+			name: "device access only",
+			consent: buildTCF(tcfSpec{
+				purposes:    map[int]bool{1: true},
+				vendors:     map[int]bool{vendorID: true},
+				maxVendorID: vendorID,
+			}),
+			wantIFA: true,
+		},
+		{
+			name: "purposes without our vendor",
+			consent: buildTCF(tcfSpec{
+				purposes:    map[int]bool{1: true, 3: true},
+				vendors:     map[int]bool{10: true},
+				maxVendorID: vendorID,
+			}),
+		},
+		{
+			name:    "garbage consent",
+			consent: "not-a-tcf-string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &VASTHandler{VendorID: vendorID}
+			req := &VASTRequest{
+				GDPR:        1,
+				UserConsent: tt.consent,
+				IDFA:        "AEBE52E7-03EE-455A-B3C4-E57283966239",
+				UID:         "user-1",
+				AL:          "l",
+				AdCount:     1,
+			}
+
+			rtbReq := h.buildOpenRTBRequest(req)
+			if got := rtbReq.Device.IFA != ""; got != tt.wantIFA {
+				t.Errorf("IFA present = %v, want %v", got, tt.wantIFA)
+			}
+			if got := rtbReq.User.ID != ""; got != tt.wantUser {
+				t.Errorf("user ID present = %v, want %v", got, tt.wantUser)
+			}
+			if rtbReq.User.Consent != tt.consent {
+				t.Error("consent string should be forwarded to bidders")
+			}
+		})
+	}
+}
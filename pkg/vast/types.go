@@ -120,6 +120,8 @@ type Extension struct {
 	Type            string           `xml:"type,attr,omitempty"`
 	AdVerifications *AdVerifications `xml:"AdVerifications,omitempty"`
 	CustomTracking  *CustomTracking  `xml:"CustomTracking,omitempty"`
+	SKAdN           *SKAdNExtension  `xml:"SKAdNetwork,omitempty"`
+	Receipt         *Receipt         `xml:"Receipt,omitempty"`
 }
 
 // AdVerifications for OMID
@@ -134,6 +136,7 @@ type Verification struct {
 	FlashResource          *FlashResource      `xml:"FlashResource,omitempty"`
 	ViewableImpression     *ViewableImpression `xml:"ViewableImpression,omitempty"`
 	VerificationParameters string              `xml:"VerificationParameters,omitempty"`
+	TrackingEvents         *TrackingEvents     `xml:"TrackingEvents,omitempty"`
 }
 
 // JavaScriptResource for verification
@@ -256,6 +259,7 @@ type Video struct {
 	H              int      `json:"h,omitempty"`
 	StartDelay     *int     `json:"startdelay,omitempty"`
 	Placement      int      `json:"placement,omitempty"`
+	Plcmt          int      `json:"plcmt,omitempty"`
 	Linearity      int      `json:"linearity,omitempty"`
 	Skip           int      `json:"skip,omitempty"`
 	SkipMin        int      `json:"skipmin,omitempty"`
@@ -305,35 +309,36 @@ type Banner struct {
 
 // Device object
 type Device struct {
-	UA             string      `json:"ua,omitempty"`
-	Geo            Geo         `json:"geo,omitempty"`
-	DNT            int         `json:"dnt,omitempty"`
-	LMT            int         `json:"lmt,omitempty"`
-	IP             string      `json:"ip,omitempty"`
-	IPv6           string      `json:"ipv6,omitempty"`
-	DeviceType     int         `json:"devicetype,omitempty"`
-	Make           string      `json:"make,omitempty"`
-	Model          string      `json:"model,omitempty"`
-	OS             string      `json:"os,omitempty"`
-	OSV            string      `json:"osv,omitempty"`
-	HWV            string      `json:"hwv,omitempty"`
-	H              int         `json:"h,omitempty"`
-	W              int         `json:"w,omitempty"`
-	PPI            int         `json:"ppi,omitempty"`
-	PxRatio        float64     `json:"pxratio,omitempty"`
-	JS             int         `json:"js,omitempty"`
-	GeoFetch       int         `json:"geofetch,omitempty"`
-	FlashVer       string      `json:"flashver,omitempty"`
-	Language       string      `json:"language,omitempty"`
-	Carrier        string      `json:"carrier,omitempty"`
-	MCCMNC         string      `json:"mccmnc,omitempty"`
-	ConnectionType int         `json:"connectiontype,omitempty"`
-	IFA            string      `json:"ifa,omitempty"`
-	DPIDSHA1       string      `json:"dpidsha1,omitempty"`
-	DPIDMD5        string      `json:"dpidmd5,omitempty"`
-	MACSHA1        string      `json:"macsha1,omitempty"`
-	MACMD5         string      `json:"macmd5,omitempty"`
-	Ext            interface{} `json:"ext,omitempty"`
+	UA             string               `json:"ua,omitempty"`
+	Geo            Geo                  `json:"geo,omitempty"`
+	DNT            int                  `json:"dnt,omitempty"`
+	LMT            int                  `json:"lmt,omitempty"`
+	IP             string               `json:"ip,omitempty"`
+	IPv6           string               `json:"ipv6,omitempty"`
+	DeviceType     int                  `json:"devicetype,omitempty"`
+	Make           string               `json:"make,omitempty"`
+	Model          string               `json:"model,omitempty"`
+	OS             string               `json:"os,omitempty"`
+	OSV            string               `json:"osv,omitempty"`
+	HWV            string               `json:"hwv,omitempty"`
+	H              int                  `json:"h,omitempty"`
+	W              int                  `json:"w,omitempty"`
+	PPI            int                  `json:"ppi,omitempty"`
+	PxRatio        float64              `json:"pxratio,omitempty"`
+	JS             int                  `json:"js,omitempty"`
+	GeoFetch       int                  `json:"geofetch,omitempty"`
+	FlashVer       string               `json:"flashver,omitempty"`
+	Language       string               `json:"language,omitempty"`
+	Carrier        string               `json:"carrier,omitempty"`
+	MCCMNC         string               `json:"mccmnc,omitempty"`
+	ConnectionType int                  `json:"connectiontype,omitempty"`
+	SUA            *StructuredUserAgent `json:"sua,omitempty"`
+	IFA            string               `json:"ifa,omitempty"`
+	DPIDSHA1       string               `json:"dpidsha1,omitempty"`
+	DPIDMD5        string               `json:"dpidmd5,omitempty"`
+	MACSHA1        string               `json:"macsha1,omitempty"`
+	MACMD5         string               `json:"macmd5,omitempty"`
+	Ext            interface{}          `json:"ext,omitempty"`
 }
 
 // Geo object
@@ -584,6 +589,7 @@ type OpenRTBImpression struct {
 	DisplayManager    string      `json:"displaymanager,omitempty"`
 	DisplayManagerVer string      `json:"displaymanagerver,omitempty"`
 	Instl             int         `json:"instl,omitempty"`
+	Rwdd              int         `json:"rwdd,omitempty"`
 	TagID             string      `json:"tagid,omitempty"`
 	BidFloor          float64     `json:"bidfloor,omitempty"`
 	BidFloorCur       string      `json:"bidfloorcur,omitempty"`
@@ -596,7 +602,7 @@ type OpenRTBImpression struct {
 
 // Storage and Analytics types
 
-// ImpressionRecord for tracking
+// ImpressionRecord is one served ad, stored under its ImpressionKey
 type ImpressionRecord struct {
 	ID        string       `json:"id"`
 	Timestamp time.Time    `json:"timestamp"`
@@ -604,8 +610,11 @@ type ImpressionRecord struct {
 	ZoneID    int          `json:"zone_id"`
 	Device    DeviceInfo   `json:"device"`
 	Location  LocationInfo `json:"location"`
-	AdCount   int          `json:"ad_count"`
-	Revenue   float64      `json:"revenue"`
+	AdCount   int          `json:"ad_count"` // Ads in the serve
+
+	CampaignID string  `json:"campaign_id,omitempty"`
+	CreativeID string  `json:"creative_id,omitempty"`
+	Revenue    float64 `json:"revenue"` // The bid's cleared CPM
 }
 
 // DeviceInfo for tracking
@@ -633,6 +642,15 @@ type RTBExchange interface {
 	RunAuction(ctx context.Context, req *OpenRTBRequest) (*OpenRTBResponse, error)
 }
 
+// ServeBiller is an RTBExchange that can charge for a serve of an auction
+// result it didn't run the auction for, as when a cached result is served
+// again. BillServe records the spend and fires the win notices for the
+// winners of resp under req's ID, as RunAuction does for its own serve.
+// Only a ServeBiller's results are cached.
+type ServeBiller interface {
+	BillServe(ctx context.Context, req *OpenRTBRequest, resp *OpenRTBResponse) error
+}
+
 // StorageBackend interface
 type StorageBackend interface {
 	StoreImpression(imp *ImpressionRecord) error
@@ -646,16 +664,27 @@ type AnalyticsEngine interface {
 	GetMetrics(startTime, endTime time.Time) map[string]interface{}
 }
 
+// AuctionObserver is told of each auction a VAST request runs, with the
+// response it got, nil when the exchange failed
+type AuctionObserver interface {
+	ObserveAuction(req *OpenRTBRequest, resp *OpenRTBResponse)
+}
+
 // PrivacyManager interface
 type PrivacyManager interface {
 	CheckCompliance(consent string, gdpr int, ccpa string) bool
 	AnonymizeData(data interface{}) interface{}
 }
 
+// FloorProvider computes the CPM floor for an impression
+type FloorProvider interface {
+	FloorFor(placement, country string, deviceType int, at time.Time) float64
+}
+
 // BlockchainManager interface
 type BlockchainManager interface {
 	RecordImpression(imp *ImpressionRecord, wallet string, chainID int) error
-	VerifyProofOfView(pov string) bool
+	VerifyProofOfView(pov, impressionID, sessionID string) bool
 	ProcessPayment(wallet string, amount float64, chainID int) error
 }
 
@@ -0,0 +1,68 @@
+package vast
+
+import "errors"
+
+var ErrInvalidUSPrivacy = errors.New("invalid US Privacy string")
+
+// USPrivacy is a parsed IAB US Privacy (CCPA) string such as "1YNN"
+type USPrivacy struct {
+	Version        int
+	ExplicitNotice byte // 'Y', 'N' or '-'
+	OptOutSale     byte // 'Y', 'N' or '-'
+	LSPACovered    byte // 'Y', 'N' or '-'
+}
+
+// ParseUSPrivacy validates the 4-character v1 format: version, explicit
+// notice, opt-out of sale, and LSPA coverage
+func ParseUSPrivacy(s string) (USPrivacy, error) {
+	if len(s) != 4 || s[0] != '1' {
+		return USPrivacy{}, ErrInvalidUSPrivacy
+	}
+	for i := 1; i < 4; i++ {
+		switch s[i] {
+		case 'Y', 'N', '-':
+		case 'y', 'n':
+			// Tolerate lowercase from sloppy integrations
+		default:
+			return USPrivacy{}, ErrInvalidUSPrivacy
+		}
+	}
+	return USPrivacy{
+		Version:        1,
+		ExplicitNotice: upper(s[1]),
+		OptOutSale:     upper(s[2]),
+		LSPACovered:    upper(s[3]),
+	}, nil
+}
+
+// OptedOut reports whether the user opted out of the sale of their data
+func (p USPrivacy) OptedOut() bool {
+	return p.OptOutSale == 'Y'
+}
+
+// Applies reports whether CCPA applies to the request at all ("1---" means
+// it does not)
+func (p USPrivacy) Applies() bool {
+	return p.ExplicitNotice != '-' || p.OptOutSale != '-' || p.LSPACovered != '-'
+}
+
+// usPrivacyOptOut reports whether a raw us_privacy value requires us to
+// stop sharing data. Malformed strings are treated as an opt-out since we
+// can't prove the user didn't ask for one.
+func usPrivacyOptOut(s string) bool {
+	if s == "" {
+		return false
+	}
+	p, err := ParseUSPrivacy(s)
+	if err != nil {
+		return true
+	}
+	return p.OptedOut()
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
@@ -0,0 +1,75 @@
+package vast
+
+import "testing"
+
+func TestParseUSPrivacy(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantErr     bool
+		wantOptOut  bool
+		wantApplies bool
+	}{
+		{input: "1YNN", wantApplies: true},
+		{input: "1NYN", wantOptOut: true, wantApplies: true},
+		{input: "1YYY", wantOptOut: true, wantApplies: true},
+		{input: "1---"},
+		{input: "1yyn", wantOptOut: true, wantApplies: true},
+		{input: "", wantErr: true},
+		{input: "1YN", wantErr: true},
+		{input: "1YNNN", wantErr: true},
+		{input: "2YNN", wantErr: true},
+		{input: "1XNN", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := ParseUSPrivacy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if p.OptedOut() != tt.wantOptOut {
+				t.Errorf("OptedOut() = %v, want %v", p.OptedOut(), tt.wantOptOut)
+			}
+			if p.Applies() != tt.wantApplies {
+				t.Errorf("Applies() = %v, want %v", p.Applies(), tt.wantApplies)
+			}
+		})
+	}
+}
+
+func TestCheckPrivacyCompliance_USPrivacy(t *testing.T) {
+	tests := []struct {
+		usPrivacy string
+		wantStrip bool
+	}{
+		{"", false},
+		{"1YNN", false},
+		{"1---", false},
+		{"1NYN", true},
+		{"garbage", true}, // malformed defaults to opt-out
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.usPrivacy, func(t *testing.T) {
+			h := &VASTHandler{}
+			req := &VASTRequest{
+				USPrivacy: tt.usPrivacy,
+				IDFA:      "AEBE52E7-03EE-455A-B3C4-E57283966239",
+				UID:       "user-1",
+				Lat:       "37.77",
+				Long:      "-122.41",
+			}
+			if err := h.checkPrivacyCompliance(req); err != nil {
+				t.Fatalf("checkPrivacyCompliance: %v", err)
+			}
+
+			stripped := req.IDFA == "" && req.UID == "" && req.Lat == "" && req.DNT == 1
+			if stripped != tt.wantStrip {
+				t.Errorf("identifiers stripped = %v, want %v", stripped, tt.wantStrip)
+			}
+		})
+	}
+}
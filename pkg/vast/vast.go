@@ -10,6 +10,7 @@ type VAST struct {
 	XMLName xml.Name `xml:"VAST"`
 	Version string   `xml:"version,attr"`
 	Ads     []Ad     `xml:"Ad"`
+	Errors  []string `xml:"Error,omitempty"` // Error URIs of a response with no ads
 }
 
 // Ad represents a VAST advertisement
@@ -22,16 +23,17 @@ type Ad struct {
 
 // InLine contains all data to display the ad
 type InLine struct {
-	AdSystem    AdSystem     `xml:"AdSystem"`
-	AdTitle     string       `xml:"AdTitle"`
-	Description string       `xml:"Description,omitempty"`
-	Advertiser  string       `xml:"Advertiser,omitempty"`
-	Pricing     *Pricing     `xml:"Pricing,omitempty"`
-	Survey      []string     `xml:"Survey,omitempty"`
-	Error       []string     `xml:"Error,omitempty"`
-	Impression  []Impression `xml:"Impression"`
-	Creatives   Creatives    `xml:"Creatives"`
-	Extensions  *Extensions  `xml:"Extensions,omitempty"`
+	AdSystem        AdSystem         `xml:"AdSystem"`
+	AdTitle         string           `xml:"AdTitle"`
+	Description     string           `xml:"Description,omitempty"`
+	Advertiser      string           `xml:"Advertiser,omitempty"`
+	Pricing         *Pricing         `xml:"Pricing,omitempty"`
+	Survey          []string         `xml:"Survey,omitempty"`
+	Error           []string         `xml:"Error,omitempty"`
+	Impression      []Impression     `xml:"Impression"`
+	AdVerifications *AdVerifications `xml:"AdVerifications,omitempty"`
+	Creatives       Creatives        `xml:"Creatives"`
+	Extensions      *Extensions      `xml:"Extensions,omitempty"`
 }
 
 // Wrapper points to another VAST response